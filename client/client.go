@@ -5,19 +5,24 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"github.com/LINBIT/linstor-gateway/pkg/common"
 	"github.com/LINBIT/linstor-gateway/pkg/rest"
 	"github.com/moul/http2curl"
 	"io"
 	"log"
+	"math"
 	"net/http"
 	"net/url"
 	"os"
+	"strconv"
 )
 
 type Client struct {
 	httpClient *http.Client
 	baseURL    *url.URL
 	log        interface{} // must be either Logger, TestLogger, or LeveledLogger
+	// user is reported to the server for its audit log, if set.
+	user string
 
 	Iscsi  *ISCSIService
 	Nfs    *NFSService
@@ -130,6 +135,9 @@ func (c *Client) newRequest(method, path string, body interface{}) (*http.Reques
 		req.Header.Set("Content-Type", "application/json")
 	}
 	req.Header.Set("Accept", "application/json")
+	if c.user != "" {
+		req.Header.Set(rest.UserHeader, c.user)
+	}
 
 	return req, nil
 }
@@ -225,3 +233,57 @@ func (c *Client) doDELETE(ctx context.Context, url string, body interface{}) (*h
 
 	return c.do(ctx, req, nil)
 }
+
+// createPath returns the path for a create request with the given options.
+func createPath(path string, opts common.CreateOptions) string {
+	query := url.Values{}
+	if opts.KeepOnFailure {
+		query.Set("keep_on_failure", "true")
+	}
+	if opts.Overwrite {
+		query.Set("overwrite", "true")
+	}
+	if opts.SkipCapacityCheck {
+		query.Set("skip_capacity_check", "true")
+	}
+	if opts.NoStart {
+		query.Set("no_start", "true")
+	}
+	if opts.SkipCollisionCheck {
+		query.Set("skip_collision_check", "true")
+	}
+	if opts.WaitSync {
+		query.Set("wait_sync", "true")
+		if opts.SyncTimeout > 0 {
+			query.Set("sync_timeout", strconv.Itoa(int(math.Ceil(opts.SyncTimeout.Seconds()))))
+		}
+	}
+	if len(query) == 0 {
+		return path
+	}
+	return path + "?" + query.Encode()
+}
+
+// deletePath returns the path for a delete request with the given options.
+func deletePath(path string, opts common.DeleteOptions) string {
+	if opts.KeepResource {
+		return path + "?keep_resource=true"
+	}
+	return path
+}
+
+// listPath returns the path for a list request that skips the first offset
+// resources and returns at most limit. 0 means no offset or limit.
+func listPath(path string, offset, limit int) string {
+	query := url.Values{}
+	if offset > 0 {
+		query.Set("offset", strconv.Itoa(offset))
+	}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+	if len(query) == 0 {
+		return path
+	}
+	return path + "?" + query.Encode()
+}
@@ -10,6 +10,10 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"testing"
+	"time"
+
+	"github.com/LINBIT/linstor-gateway/pkg/common"
+	"github.com/LINBIT/linstor-gateway/pkg/rest"
 )
 
 func parseURL(str string) *url.URL {
@@ -95,6 +99,22 @@ func TestNewRequest(t *testing.T) {
 	}
 }
 
+func TestNewRequest_User(t *testing.T) {
+	t.Parallel()
+
+	cli, err := NewClient(Log(t), User("alice"))
+	assert.NoError(t, err)
+	req, err := cli.newRequest("POST", "/test", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "alice", req.Header.Get(rest.UserHeader))
+
+	cli, err = NewClient(Log(t))
+	assert.NoError(t, err)
+	req, err = cli.newRequest("POST", "/test", nil)
+	assert.NoError(t, err)
+	assert.NotContains(t, req.Header, rest.UserHeader)
+}
+
 func TestDo(t *testing.T) {
 	type wantData struct {
 		status int
@@ -164,3 +184,85 @@ func TestDo(t *testing.T) {
 		})
 	}
 }
+
+func TestCreatePath(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+		opts common.CreateOptions
+		want string
+	}{{
+		name: "no options",
+		want: "/api/v2/iscsi",
+	}, {
+		name: "keep on failure",
+		opts: common.CreateOptions{KeepOnFailure: true},
+		want: "/api/v2/iscsi?keep_on_failure=true",
+	}, {
+		name: "overwrite",
+		opts: common.CreateOptions{Overwrite: true},
+		want: "/api/v2/iscsi?overwrite=true",
+	}, {
+		name: "both",
+		opts: common.CreateOptions{KeepOnFailure: true, Overwrite: true},
+		want: "/api/v2/iscsi?keep_on_failure=true&overwrite=true",
+	}, {
+		name: "skip capacity check",
+		opts: common.CreateOptions{SkipCapacityCheck: true},
+		want: "/api/v2/iscsi?skip_capacity_check=true",
+	}, {
+		name: "no start",
+		opts: common.CreateOptions{NoStart: true},
+		want: "/api/v2/iscsi?no_start=true",
+	}, {
+		name: "skip collision check",
+		opts: common.CreateOptions{SkipCollisionCheck: true},
+		want: "/api/v2/iscsi?skip_collision_check=true",
+	}, {
+		name: "wait sync",
+		opts: common.CreateOptions{WaitSync: true},
+		want: "/api/v2/iscsi?wait_sync=true",
+	}, {
+		name: "wait sync with timeout",
+		opts: common.CreateOptions{WaitSync: true, SyncTimeout: 90*time.Minute + 500*time.Millisecond},
+		want: "/api/v2/iscsi?sync_timeout=5401&wait_sync=true",
+	}, {
+		name: "sync timeout without wait sync",
+		opts: common.CreateOptions{SyncTimeout: time.Hour},
+		want: "/api/v2/iscsi",
+	}}
+
+	for i := range cases {
+		tcase := &cases[i]
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tcase.want, createPath("/api/v2/iscsi", tcase.opts))
+		})
+	}
+}
+
+func TestDeletePath(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+		opts common.DeleteOptions
+		want string
+	}{{
+		name: "no options",
+		want: "/api/v2/iscsi/iqn.2021-08.com.linbit:target1",
+	}, {
+		name: "keep resource",
+		opts: common.DeleteOptions{KeepResource: true},
+		want: "/api/v2/iscsi/iqn.2021-08.com.linbit:target1?keep_resource=true",
+	}}
+
+	for i := range cases {
+		tcase := &cases[i]
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tcase.want, deletePath("/api/v2/iscsi/iqn.2021-08.com.linbit:target1", tcase.opts))
+		})
+	}
+}
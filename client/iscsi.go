@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"github.com/LINBIT/linstor-gateway/pkg/common"
 	"github.com/LINBIT/linstor-gateway/pkg/iscsi"
+	"github.com/LINBIT/linstor-gateway/pkg/reactor"
 )
 
 type ISCSIService struct {
@@ -12,53 +13,156 @@ type ISCSIService struct {
 }
 
 func (s *ISCSIService) GetAll(ctx context.Context) ([]*iscsi.ResourceConfig, error) {
+	return s.GetPage(ctx, 0, 0)
+}
+
+// GetPage is like GetAll, but skips the first offset resources and returns
+// at most limit. A limit of 0 returns all remaining resources.
+func (s *ISCSIService) GetPage(ctx context.Context, offset, limit int) ([]*iscsi.ResourceConfig, error) {
 	var configs []*iscsi.ResourceConfig
-	_, err := s.client.doGET(ctx, "/api/v2/iscsi", &configs)
+	_, err := s.client.doGET(ctx, listPath("/api/v2/iscsi", offset, limit), &configs)
 	return configs, err
 }
 
-func (s *ISCSIService) Create(ctx context.Context, config *iscsi.ResourceConfig) (*iscsi.ResourceConfig, error) {
-	var ret *iscsi.ResourceConfig
-	_, err := s.client.doPOST(ctx, "/api/v2/iscsi", config, ret)
-	return ret, err
+func (s *ISCSIService) Create(ctx context.Context, config *iscsi.ResourceConfig, opts common.CreateOptions) (*iscsi.ResourceConfig, error) {
+	var ret iscsi.ResourceConfig
+	_, err := s.client.doPOST(ctx, createPath("/api/v2/iscsi", opts), (*iscsi.ResourceConfigWithSecrets)(config), &ret)
+	return &ret, err
 }
 
 func (s *ISCSIService) Get(ctx context.Context, iqn iscsi.Iqn) (*iscsi.ResourceConfig, error) {
-	var config *iscsi.ResourceConfig
-	_, err := s.client.doGET(ctx, "/api/v2/iscsi/"+iqn.String(), config)
-	return config, err
+	var config iscsi.ResourceConfig
+	_, err := s.client.doGET(ctx, "/api/v2/iscsi/"+iqn.String(), &config)
+	return &config, err
+}
+
+// GetWithSecrets is like Get, but the returned config includes the CHAP
+// password.
+func (s *ISCSIService) GetWithSecrets(ctx context.Context, iqn iscsi.Iqn) (*iscsi.ResourceConfig, error) {
+	var config iscsi.ResourceConfig
+	_, err := s.client.doGET(ctx, "/api/v2/iscsi/"+iqn.String()+"?show_secrets=true", &config)
+	return &config, err
 }
 
-func (s *ISCSIService) Delete(ctx context.Context, iqn iscsi.Iqn) error {
-	_, err := s.client.doDELETE(ctx, "/api/v2/iscsi/"+iqn.String(), nil)
+func (s *ISCSIService) Delete(ctx context.Context, iqn iscsi.Iqn, opts common.DeleteOptions) error {
+	_, err := s.client.doDELETE(ctx, deletePath("/api/v2/iscsi/"+iqn.String(), opts), nil)
 	return err
 }
 
 func (s *ISCSIService) Start(ctx context.Context, iqn iscsi.Iqn) (*iscsi.ResourceConfig, error) {
-	var ret *iscsi.ResourceConfig
-	_, err := s.client.doPOST(ctx, "/api/v2/iscsi/"+iqn.String()+"/start", nil, ret)
-	return ret, err
+	var ret iscsi.ResourceConfig
+	_, err := s.client.doPOST(ctx, "/api/v2/iscsi/"+iqn.String()+"/start", nil, &ret)
+	return &ret, err
 }
 
 func (s *ISCSIService) Stop(ctx context.Context, iqn iscsi.Iqn) (*iscsi.ResourceConfig, error) {
-	var ret *iscsi.ResourceConfig
-	_, err := s.client.doPOST(ctx, "/api/v2/iscsi/"+iqn.String()+"/stop", nil, ret)
-	return ret, err
+	var ret iscsi.ResourceConfig
+	_, err := s.client.doPOST(ctx, "/api/v2/iscsi/"+iqn.String()+"/stop", nil, &ret)
+	return &ret, err
+}
+
+// Failover moves a running target to a different node and returns it once
+// it is running there.
+func (s *ISCSIService) Failover(ctx context.Context, iqn iscsi.Iqn) (*iscsi.ResourceConfig, error) {
+	var ret iscsi.ResourceConfig
+	_, err := s.client.doPOST(ctx, "/api/v2/iscsi/"+iqn.String()+"/failover", nil, &ret)
+	return &ret, err
+}
+
+// SetCHAP changes the CHAP credentials of a target. Empty credentials disable
+// CHAP authentication.
+func (s *ISCSIService) SetCHAP(ctx context.Context, iqn iscsi.Iqn, username, password string) (*iscsi.ResourceConfig, error) {
+	var ret iscsi.ResourceConfig
+	_, err := s.client.doPUT(ctx, "/api/v2/iscsi/"+iqn.String()+"/chap", &iscsi.CHAPCredentials{Username: username, Password: password}, &ret)
+	return &ret, err
+}
+
+// SetDescription changes the description of a target. An empty description
+// removes it.
+func (s *ISCSIService) SetDescription(ctx context.Context, iqn iscsi.Iqn, description string) (*iscsi.ResourceConfig, error) {
+	body := struct {
+		Description string `json:"description"`
+	}{Description: description}
+	var ret iscsi.ResourceConfig
+	_, err := s.client.doPUT(ctx, "/api/v2/iscsi/"+iqn.String()+"/description", body, &ret)
+	return &ret, err
+}
+
+// Repair regenerates the reactor config of a target, undoing manual changes.
+// With dryRun, the config is not changed and only the diff is returned.
+func (s *ISCSIService) Repair(ctx context.Context, iqn iscsi.Iqn, dryRun bool) (*reactor.RepairResult, error) {
+	path := "/api/v2/iscsi/" + iqn.String() + "/repair"
+	if dryRun {
+		path += "?dry_run=true"
+	}
+
+	var ret reactor.RepairResult
+	_, err := s.client.doPOST(ctx, path, nil, &ret)
+	return &ret, err
+}
+
+// RecreatePrivateVolume replaces the cluster private volume of a stopped
+// target with a new, empty one.
+func (s *ISCSIService) RecreatePrivateVolume(ctx context.Context, iqn iscsi.Iqn) (*iscsi.ResourceConfig, error) {
+	var ret iscsi.ResourceConfig
+	_, err := s.client.doPOST(ctx, "/api/v2/iscsi/"+iqn.String()+"/repair-private-volume", nil, &ret)
+	return &ret, err
+}
+
+// ShowConfig returns the reactor config of a target. With deployed, it is the
+// config registered in LINSTOR, otherwise it is regenerated as Repair would.
+func (s *ISCSIService) ShowConfig(ctx context.Context, iqn iscsi.Iqn, deployed bool) (*reactor.ConfigText, error) {
+	path := "/api/v2/iscsi/" + iqn.String() + "/config"
+	if deployed {
+		path += "?deployed=true"
+	}
+
+	var ret reactor.ConfigText
+	_, err := s.client.doGET(ctx, path, &ret)
+	return &ret, err
 }
 
 func (s *ISCSIService) GetLogicalUnit(ctx context.Context, iqn iscsi.Iqn, lun int) (*common.VolumeConfig, error) {
-	var config *common.VolumeConfig
-	_, err := s.client.doGET(ctx, fmt.Sprintf("/api/v2/iscsi/%s/%d", iqn.String(), lun), config)
-	return config, err
+	var config common.VolumeConfig
+	_, err := s.client.doGET(ctx, fmt.Sprintf("/api/v2/iscsi/%s/%d", iqn.String(), lun), &config)
+	return &config, err
 }
 
 func (s *ISCSIService) AddLogicalUnit(ctx context.Context, iqn iscsi.Iqn, volume *common.VolumeConfig) (*common.VolumeConfig, error) {
-	var ret *common.VolumeConfig
-	_, err := s.client.doPUT(ctx, fmt.Sprintf("/api/v2/iscsi/%s/%d", iqn.String(), volume.Number), volume, ret)
-	return ret, err
+	var ret common.VolumeConfig
+	_, err := s.client.doPUT(ctx, fmt.Sprintf("/api/v2/iscsi/%s/%d", iqn.String(), volume.Number), volume, &ret)
+	return &ret, err
 }
 
 func (s *ISCSIService) DeleteLogicalUnit(ctx context.Context, iqn iscsi.Iqn, lun int) error {
 	_, err := s.client.doDELETE(ctx, fmt.Sprintf("/api/v2/iscsi/%s/%d", iqn.String(), lun), nil)
 	return err
 }
+
+// DeleteLogicalUnits deletes several logical units of a stopped target at
+// once. It returns the numbers of the logical units that were deleted and of
+// the ones that did not exist.
+func (s *ISCSIService) DeleteLogicalUnits(ctx context.Context, iqn iscsi.Iqn, luns []int) (deleted, missing []int, err error) {
+	body := struct {
+		LUNs []int `json:"luns"`
+	}{LUNs: luns}
+	var ret struct {
+		Deleted []int `json:"deleted"`
+		Missing []int `json:"missing"`
+	}
+	_, err = s.client.doPOST(ctx, "/api/v2/iscsi/"+iqn.String()+"/delete-volumes", body, &ret)
+	return ret.Deleted, ret.Missing, err
+}
+
+// SetLogicalUnitExported maps a logical unit into its target, or removes it
+// from the target while keeping its data.
+func (s *ISCSIService) SetLogicalUnitExported(ctx context.Context, iqn iscsi.Iqn, lun int, exported bool) (*common.VolumeConfig, error) {
+	action := "unexport"
+	if exported {
+		action = "export"
+	}
+
+	var ret common.VolumeConfig
+	_, err := s.client.doPOST(ctx, fmt.Sprintf("/api/v2/iscsi/%s/%d/%s", iqn.String(), lun, action), nil, &ret)
+	return &ret, err
+}
@@ -1,10 +1,16 @@
 package client_test
 
 import (
+	"context"
 	"encoding/json"
+	"github.com/LINBIT/linstor-gateway/client"
 	"github.com/LINBIT/linstor-gateway/pkg/common"
 	"github.com/LINBIT/linstor-gateway/pkg/iscsi"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"reflect"
 	"strings"
 	"testing"
@@ -69,3 +75,163 @@ func TestISCSIList(t *testing.T) {
 		})
 	}
 }
+
+func TestISCSIGet(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v2/iscsi/iqn.2021-08.com.linbit:target1", r.URL.Path)
+		_, _ = w.Write([]byte(`{"iqn":"iqn.2021-08.com.linbit:target1","resource_group":"DfltRscGrp","volumes":[{"number":1,"size_kib":1024}],"service_ips":["10.43.6.223/16"]}`))
+	}))
+	defer server.Close()
+
+	base, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	cli, err := client.NewClient(client.BaseURL(base))
+	require.NoError(t, err)
+
+	cfg, err := cli.Iscsi.Get(context.Background(), iscsi.Iqn{"iqn.2021-08.com.linbit", "target1"})
+	require.NoError(t, err)
+	assert.Equal(t, &iscsi.ResourceConfig{
+		IQN:           iscsi.Iqn{"iqn.2021-08.com.linbit", "target1"},
+		ResourceGroup: "DfltRscGrp",
+		Volumes:       []common.VolumeConfig{{Number: 1, SizeKiB: 1024}},
+		ServiceIPs:    []common.IpCidr{ipnet("10.43.6.223/16")},
+	}, cfg)
+}
+
+func TestISCSISetCHAP(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPut, r.Method)
+		assert.Equal(t, "/api/v2/iscsi/iqn.2021-08.com.linbit:target1/chap", r.URL.Path)
+
+		var creds iscsi.CHAPCredentials
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&creds))
+		assert.Equal(t, iscsi.CHAPCredentials{Username: "user", Password: "secret"}, creds)
+
+		_, _ = w.Write([]byte(`{"iqn":"iqn.2021-08.com.linbit:target1","username":"user","password":"****"}`))
+	}))
+	defer server.Close()
+
+	base, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	cli, err := client.NewClient(client.BaseURL(base))
+	require.NoError(t, err)
+
+	cfg, err := cli.Iscsi.SetCHAP(context.Background(), iscsi.Iqn{"iqn.2021-08.com.linbit", "target1"}, "user", "secret")
+	require.NoError(t, err)
+	assert.Equal(t, "user", cfg.Username)
+}
+
+func TestISCSISetDescription(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPut, r.Method)
+		assert.Equal(t, "/api/v2/iscsi/iqn.2021-08.com.linbit:target1/description", r.URL.Path)
+
+		var body map[string]string
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, map[string]string{"description": "Backups for team foo"}, body)
+
+		_, _ = w.Write([]byte(`{"iqn":"iqn.2021-08.com.linbit:target1","description":"Backups for team foo"}`))
+	}))
+	defer server.Close()
+
+	base, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	cli, err := client.NewClient(client.BaseURL(base))
+	require.NoError(t, err)
+
+	cfg, err := cli.Iscsi.SetDescription(context.Background(), iscsi.Iqn{"iqn.2021-08.com.linbit", "target1"}, "Backups for team foo")
+	require.NoError(t, err)
+	assert.Equal(t, "Backups for team foo", cfg.Description)
+}
+
+func TestISCSIShowConfig(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method)
+		assert.Equal(t, "/api/v2/iscsi/iqn.2021-08.com.linbit:target1/config", r.URL.Path)
+		assert.Equal(t, "true", r.URL.Query().Get("deployed"))
+		_, _ = w.Write([]byte(`{"path":"/etc/drbd-reactor.d/linstor-gateway-iscsi-target1.toml","config":"[[promoter]]\n","deployed":true}`))
+	}))
+	defer server.Close()
+
+	base, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	cli, err := client.NewClient(client.BaseURL(base))
+	require.NoError(t, err)
+
+	result, err := cli.Iscsi.ShowConfig(context.Background(), iscsi.Iqn{"iqn.2021-08.com.linbit", "target1"}, true)
+	require.NoError(t, err)
+	assert.Equal(t, "/etc/drbd-reactor.d/linstor-gateway-iscsi-target1.toml", result.Path)
+	assert.Equal(t, "[[promoter]]\n", result.Config)
+	assert.True(t, result.Deployed)
+}
+
+func TestISCSISetLogicalUnitExported(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "/api/v2/iscsi/iqn.2021-08.com.linbit:target1/2/unexport", r.URL.Path)
+		_, _ = w.Write([]byte(`{"number":2,"size_kib":1048576,"exported":false}`))
+	}))
+	defer server.Close()
+
+	base, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	cli, err := client.NewClient(client.BaseURL(base))
+	require.NoError(t, err)
+
+	vol, err := cli.Iscsi.SetLogicalUnitExported(context.Background(), iscsi.Iqn{"iqn.2021-08.com.linbit", "target1"}, 2, false)
+	require.NoError(t, err)
+	assert.Equal(t, 2, vol.Number)
+	assert.False(t, vol.IsExported())
+}
+
+func TestISCSIDeleteLogicalUnits(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "/api/v2/iscsi/iqn.2021-08.com.linbit:target1/delete-volumes", r.URL.Path)
+
+		var body struct {
+			LUNs []int `json:"luns"`
+		}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, []int{3, 5, 7}, body.LUNs)
+
+		_, _ = w.Write([]byte(`{"deleted":[3,5],"missing":[7]}`))
+	}))
+	defer server.Close()
+
+	base, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	cli, err := client.NewClient(client.BaseURL(base))
+	require.NoError(t, err)
+
+	deleted, missing, err := cli.Iscsi.DeleteLogicalUnits(context.Background(), iscsi.Iqn{"iqn.2021-08.com.linbit", "target1"}, []int{3, 5, 7})
+	require.NoError(t, err)
+	assert.Equal(t, []int{3, 5}, deleted)
+	assert.Equal(t, []int{7}, missing)
+}
+
+func TestISCSIRecreatePrivateVolume(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "/api/v2/iscsi/iqn.2021-08.com.linbit:target1/repair-private-volume", r.URL.Path)
+		_, _ = w.Write([]byte(`{"iqn":"iqn.2021-08.com.linbit:target1","volumes":[{"number":0,"size_kib":65536,"file_system":"ext4"}]}`))
+	}))
+	defer server.Close()
+
+	base, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	cli, err := client.NewClient(client.BaseURL(base))
+	require.NoError(t, err)
+
+	cfg, err := cli.Iscsi.RecreatePrivateVolume(context.Background(), iscsi.Iqn{"iqn.2021-08.com.linbit", "target1"})
+	require.NoError(t, err)
+	assert.Equal(t, "iqn.2021-08.com.linbit:target1", cfg.IQN.String())
+	assert.Len(t, cfg.Volumes, 1)
+}
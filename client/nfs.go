@@ -2,6 +2,9 @@ package client
 
 import (
 	"context"
+	"fmt"
+
+	"github.com/LINBIT/linstor-gateway/pkg/common"
 	"github.com/LINBIT/linstor-gateway/pkg/nfs"
 )
 
@@ -10,36 +13,75 @@ type NFSService struct {
 }
 
 func (s *NFSService) GetAll(ctx context.Context) ([]*nfs.ResourceConfig, error) {
+	return s.GetPage(ctx, 0, 0)
+}
+
+// GetPage is like GetAll, but skips the first offset resources and returns
+// at most limit. A limit of 0 returns all remaining resources.
+func (s *NFSService) GetPage(ctx context.Context, offset, limit int) ([]*nfs.ResourceConfig, error) {
 	var configs []*nfs.ResourceConfig
-	_, err := s.client.doGET(ctx, "/api/v2/nfs", &configs)
+	_, err := s.client.doGET(ctx, listPath("/api/v2/nfs", offset, limit), &configs)
 	return configs, err
 }
 
-func (s *NFSService) Create(ctx context.Context, config *nfs.ResourceConfig) (*nfs.ResourceConfig, error) {
-	var ret *nfs.ResourceConfig
-	_, err := s.client.doPOST(ctx, "/api/v2/nfs", config, ret)
-	return ret, err
+func (s *NFSService) Create(ctx context.Context, config *nfs.ResourceConfig, opts common.CreateOptions) (*nfs.ResourceConfig, error) {
+	var ret nfs.ResourceConfig
+	_, err := s.client.doPOST(ctx, createPath("/api/v2/nfs", opts), config, &ret)
+	return &ret, err
 }
 
 func (s *NFSService) Get(ctx context.Context, name string) (*nfs.ResourceConfig, error) {
-	var config *nfs.ResourceConfig
-	_, err := s.client.doGET(ctx, "/api/v2/nfs/"+name, config)
-	return config, err
+	var config nfs.ResourceConfig
+	_, err := s.client.doGET(ctx, "/api/v2/nfs/"+name, &config)
+	return &config, err
 }
 
-func (s *NFSService) Delete(ctx context.Context, name string) error {
-	_, err := s.client.doDELETE(ctx, "/api/v2/nfs/"+name, nil)
+func (s *NFSService) Delete(ctx context.Context, name string, opts common.DeleteOptions) error {
+	_, err := s.client.doDELETE(ctx, deletePath("/api/v2/nfs/"+name, opts), nil)
 	return err
 }
 
 func (s *NFSService) Start(ctx context.Context, name string) (*nfs.ResourceConfig, error) {
-	var ret *nfs.ResourceConfig
-	_, err := s.client.doPOST(ctx, "/api/v2/nfs/"+name+"/start", nil, ret)
-	return ret, err
+	var ret nfs.ResourceConfig
+	_, err := s.client.doPOST(ctx, "/api/v2/nfs/"+name+"/start", nil, &ret)
+	return &ret, err
 }
 
 func (s *NFSService) Stop(ctx context.Context, name string) (*nfs.ResourceConfig, error) {
-	var ret *nfs.ResourceConfig
-	_, err := s.client.doPOST(ctx, "/api/v2/nfs/"+name+"/stop", nil, ret)
-	return ret, err
+	var ret nfs.ResourceConfig
+	_, err := s.client.doPOST(ctx, "/api/v2/nfs/"+name+"/stop", nil, &ret)
+	return &ret, err
+}
+
+func (s *NFSService) Rename(ctx context.Context, name, newName string) (*nfs.ResourceConfig, error) {
+	body := struct {
+		Name string `json:"name"`
+	}{Name: newName}
+	var ret nfs.ResourceConfig
+	_, err := s.client.doPOST(ctx, "/api/v2/nfs/"+name+"/rename", body, &ret)
+	return &ret, err
+}
+
+// SetDescription changes the description of an export. An empty description
+// removes it.
+func (s *NFSService) SetDescription(ctx context.Context, name, description string) (*nfs.ResourceConfig, error) {
+	body := struct {
+		Description string `json:"description"`
+	}{Description: description}
+	var ret nfs.ResourceConfig
+	_, err := s.client.doPUT(ctx, "/api/v2/nfs/"+name+"/description", body, &ret)
+	return &ret, err
+}
+
+// DeleteVolume deletes a volume of an existing export.
+func (s *NFSService) DeleteVolume(ctx context.Context, name string, volume int) error {
+	_, err := s.client.doDELETE(ctx, fmt.Sprintf("/api/v2/nfs/%s/%d", name, volume), nil)
+	return err
+}
+
+// AddVolume adds a volume to an existing export.
+func (s *NFSService) AddVolume(ctx context.Context, name string, volume *nfs.VolumeConfig) (*common.Volume, error) {
+	var ret common.Volume
+	_, err := s.client.doPUT(ctx, fmt.Sprintf("/api/v2/nfs/%s/%d", name, volume.Number), volume, &ret)
+	return &ret, err
 }
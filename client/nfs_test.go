@@ -1 +1,33 @@
 package client_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/LINBIT/linstor-gateway/client"
+)
+
+func TestNFSDeleteVolume(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodDelete, r.Method)
+		assert.Equal(t, "/api/v2/nfs/example/2", r.URL.Path)
+
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	base, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	cli, err := client.NewClient(client.BaseURL(base))
+	require.NoError(t, err)
+
+	err = cli.Nfs.DeleteVolume(context.Background(), "example", 2)
+	assert.NoError(t, err)
+}
@@ -12,53 +12,90 @@ type NvmeOfService struct {
 }
 
 func (s *NvmeOfService) GetAll(ctx context.Context) ([]nvmeof.ResourceConfig, error) {
+	return s.GetPage(ctx, 0, 0)
+}
+
+// GetPage is like GetAll, but skips the first offset resources and returns
+// at most limit. A limit of 0 returns all remaining resources.
+func (s *NvmeOfService) GetPage(ctx context.Context, offset, limit int) ([]nvmeof.ResourceConfig, error) {
 	var configs []nvmeof.ResourceConfig
-	_, err := s.client.doGET(ctx, "/api/v2/nvme-of", &configs)
+	_, err := s.client.doGET(ctx, listPath("/api/v2/nvme-of", offset, limit), &configs)
 	return configs, err
 }
 
-func (s *NvmeOfService) Create(ctx context.Context, config *nvmeof.ResourceConfig) (*nvmeof.ResourceConfig, error) {
-	var ret *nvmeof.ResourceConfig
-	_, err := s.client.doPOST(ctx, "/api/v2/nvme-of", config, ret)
-	return ret, err
+func (s *NvmeOfService) Create(ctx context.Context, config *nvmeof.ResourceConfig, opts common.CreateOptions) (*nvmeof.ResourceConfig, error) {
+	var ret nvmeof.ResourceConfig
+	_, err := s.client.doPOST(ctx, createPath("/api/v2/nvme-of", opts), config, &ret)
+	return &ret, err
 }
 
 func (s *NvmeOfService) Get(ctx context.Context, nqn nvmeof.Nqn) (*nvmeof.ResourceConfig, error) {
-	var config *nvmeof.ResourceConfig
-	_, err := s.client.doGET(ctx, "/api/v2/nvme-of/"+nqn.String(), config)
-	return config, err
+	var config nvmeof.ResourceConfig
+	_, err := s.client.doGET(ctx, "/api/v2/nvme-of/"+nqn.String(), &config)
+	return &config, err
 }
 
-func (s *NvmeOfService) Delete(ctx context.Context, nqn nvmeof.Nqn) error {
-	_, err := s.client.doDELETE(ctx, "/api/v2/nvme-of/"+nqn.String(), nil)
+func (s *NvmeOfService) Delete(ctx context.Context, nqn nvmeof.Nqn, opts common.DeleteOptions) error {
+	_, err := s.client.doDELETE(ctx, deletePath("/api/v2/nvme-of/"+nqn.String(), opts), nil)
 	return err
 }
 
 func (s *NvmeOfService) Start(ctx context.Context, nqn nvmeof.Nqn) (*nvmeof.ResourceConfig, error) {
-	var ret *nvmeof.ResourceConfig
-	_, err := s.client.doPOST(ctx, "/api/v2/nvme-of/"+nqn.String()+"/start", nil, ret)
-	return ret, err
+	var ret nvmeof.ResourceConfig
+	_, err := s.client.doPOST(ctx, "/api/v2/nvme-of/"+nqn.String()+"/start", nil, &ret)
+	return &ret, err
 }
 
 func (s *NvmeOfService) Stop(ctx context.Context, nqn nvmeof.Nqn) (*nvmeof.ResourceConfig, error) {
-	var ret *nvmeof.ResourceConfig
-	_, err := s.client.doPOST(ctx, "/api/v2/nvme-of/"+nqn.String()+"/stop", nil, ret)
-	return ret, err
+	var ret nvmeof.ResourceConfig
+	_, err := s.client.doPOST(ctx, "/api/v2/nvme-of/"+nqn.String()+"/stop", nil, &ret)
+	return &ret, err
+}
+
+// SetDescription changes the description of a target. An empty description
+// removes it.
+func (s *NvmeOfService) SetDescription(ctx context.Context, nqn nvmeof.Nqn, description string) (*nvmeof.ResourceConfig, error) {
+	body := struct {
+		Description string `json:"description"`
+	}{Description: description}
+	var ret nvmeof.ResourceConfig
+	_, err := s.client.doPUT(ctx, "/api/v2/nvme-of/"+nqn.String()+"/description", body, &ret)
+	return &ret, err
 }
 
 func (s *NvmeOfService) GetVolume(ctx context.Context, nqn nvmeof.Nqn, lun int) (*common.VolumeConfig, error) {
-	var config *common.VolumeConfig
-	_, err := s.client.doGET(ctx, fmt.Sprintf("/api/v2/nvme-of/%s/%d", nqn.String(), lun), config)
-	return config, err
+	var config common.VolumeConfig
+	_, err := s.client.doGET(ctx, fmt.Sprintf("/api/v2/nvme-of/%s/%d", nqn.String(), lun), &config)
+	return &config, err
 }
 
 func (s *NvmeOfService) AddVolume(ctx context.Context, nqn nvmeof.Nqn, volume *common.VolumeConfig) (*common.VolumeConfig, error) {
-	var ret *common.VolumeConfig
-	_, err := s.client.doPUT(ctx, fmt.Sprintf("/api/v2/nvme-of/%s/%d", nqn.String(), volume.Number), volume, ret)
-	return ret, err
+	var ret common.VolumeConfig
+	_, err := s.client.doPUT(ctx, fmt.Sprintf("/api/v2/nvme-of/%s/%d", nqn.String(), volume.Number), volume, &ret)
+	return &ret, err
 }
 
 func (s *NvmeOfService) DeleteVolume(ctx context.Context, nqn nvmeof.Nqn, volume int) error {
 	_, err := s.client.doDELETE(ctx, fmt.Sprintf("/api/v2/nvme-of/%s/%d", nqn.String(), volume), nil)
 	return err
 }
+
+// AddAllowedHost allows the host with the given NQN to connect to a target.
+func (s *NvmeOfService) AddAllowedHost(ctx context.Context, nqn nvmeof.Nqn, host string) (*nvmeof.ResourceConfig, error) {
+	var ret nvmeof.ResourceConfig
+	_, err := s.client.doPUT(ctx, "/api/v2/nvme-of/"+nqn.String()+"/allowed-hosts/"+host, nil, &ret)
+	return &ret, err
+}
+
+// RemoveAllowedHost removes the host with the given NQN from the hosts allowed
+// to connect to a target.
+func (s *NvmeOfService) RemoveAllowedHost(ctx context.Context, nqn nvmeof.Nqn, host string) (*nvmeof.ResourceConfig, error) {
+	req, err := s.client.newRequest("DELETE", "/api/v2/nvme-of/"+nqn.String()+"/allowed-hosts/"+host, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var ret nvmeof.ResourceConfig
+	_, err = s.client.do(ctx, req, &ret)
+	return &ret, err
+}
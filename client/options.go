@@ -37,3 +37,12 @@ func Log(logger interface{}) Option {
 		return nil
 	}
 }
+
+// User is a Client's option to report the user on whose behalf requests are
+// made. The server records it in its audit log.
+func User(name string) Option {
+	return func(c *Client) error {
+		c.user = name
+		return nil
+	}
+}
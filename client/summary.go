@@ -0,0 +1,40 @@
+package client
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/LINBIT/linstor-gateway/pkg/common"
+)
+
+// Summary returns the number of all targets and exports by state, per
+// protocol.
+func (c *Client) Summary(ctx context.Context) (common.SummaryStats, error) {
+	var stats common.SummaryStats
+
+	iscsiCfgs, err := c.Iscsi.GetAll(ctx)
+	if err != nil {
+		return stats, fmt.Errorf("failed to list iSCSI targets: %w", err)
+	}
+	for _, cfg := range iscsiCfgs {
+		stats.ISCSI.Add(cfg.Status)
+	}
+
+	nfsCfgs, err := c.Nfs.GetAll(ctx)
+	if err != nil {
+		return stats, fmt.Errorf("failed to list NFS exports: %w", err)
+	}
+	for _, cfg := range nfsCfgs {
+		stats.NFS.Add(cfg.Status)
+	}
+
+	nvmeCfgs, err := c.NvmeOf.GetAll(ctx)
+	if err != nil {
+		return stats, fmt.Errorf("failed to list NVMe-oF targets: %w", err)
+	}
+	for _, cfg := range nvmeCfgs {
+		stats.NVMeoF.Add(cfg.Status)
+	}
+
+	return stats, nil
+}
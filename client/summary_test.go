@@ -0,0 +1,46 @@
+package client_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/LINBIT/linstor-gateway/client"
+	"github.com/LINBIT/linstor-gateway/pkg/common"
+)
+
+func TestSummary(t *testing.T) {
+	t.Parallel()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v2/iscsi", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`[
+			{"iqn":"iqn.2021-08.com.linbit:target1","status":{"state":"OK","service":"Started"}},
+			{"iqn":"iqn.2021-08.com.linbit:target2","status":{"state":"Degraded","service":"Started","volumes":[{"number":1,"state":"Degraded"}]}}
+		]`))
+	})
+	mux.HandleFunc("/api/v2/nfs", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`[{"name":"export","status":{"state":"OK","service":"Stopped"}}]`))
+	})
+	mux.HandleFunc("/api/v2/nvme-of", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`[]`))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	base, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	cli, err := client.NewClient(client.BaseURL(base))
+	require.NoError(t, err)
+
+	stats, err := cli.Summary(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, common.SummaryStats{
+		ISCSI: common.Counts{Total: 2, Healthy: 1, Degraded: 1, DegradedVolumes: 1},
+		NFS:   common.Counts{Total: 1, Stopped: 1},
+	}, stats)
+}
@@ -13,16 +13,24 @@ func checkHealthCommand() *cobra.Command {
 		Use:   "check-health",
 		Short: "Check if all requirements and dependencies are met on the current system",
 		Run: func(cmd *cobra.Command, args []string) {
-			controllers := viper.GetStringSlice("linstor.controllers")
-			err := healthcheck.CheckRequirements(controllers)
+			err := setupReactorConfigDir(cmd)
+			if err != nil {
+				log.Fatalf("Health check failed: %v", err)
+			}
+
+			// the health check reports an unreachable controller itself
+			viper.Set("linstor.probe-timeout", 0)
+			lin, err := linstorClient()
+			if err != nil {
+				log.Fatalf("Health check failed: %v", err)
+			}
+			err = healthcheck.CheckRequirements(lin)
 			if err != nil {
 				fmt.Println()
 				log.Fatalf("Health check failed: %v", err)
 			}
 		},
 	}
-	cmd.Flags().StringSlice("controllers", nil, "List of LINSTOR controllers to try to connect to (default from $LS_CONTROLLERS, or localhost:3370)")
-	viper.BindPFlag("linstor.controllers", cmd.Flags().Lookup("controllers"))
-
+	addReactorConfigDirFlag(cmd)
 	return cmd
 }
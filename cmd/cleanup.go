@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/LINBIT/linstor-gateway/pkg/cleanup"
+	"github.com/LINBIT/linstor-gateway/pkg/reactor"
+)
+
+func cleanupCommand() *cobra.Command {
+	var output string
+	var dryRun, del, yes bool
+
+	cmd := &cobra.Command{
+		Use:   "cleanup",
+		Short: "Finds and removes orphaned LINSTOR resources and drbd-reactor configs",
+		Long: `Finds leftovers of failed or interrupted operations:
+
+* LINSTOR resources that were created by LINSTOR Gateway, but have no
+  drbd-reactor config.
+* drbd-reactor configs whose LINSTOR resource does not exist anymore.
+
+Resources that were kept when deleting their target or export are not
+reported. By default, orphans are only reported. With --delete, they are
+removed after asking for confirmation. Resources that are in use are never
+removed.
+
+drbd-reactor configs are only found in the directory given by
+--reactor-config-dir. If none of the LINSTOR resources has a config there,
+--delete refuses to remove anything.`,
+		Example: "linstor-gateway cleanup --dry-run",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if dryRun && del {
+				return errors.New("--dry-run and --delete can not be used together")
+			}
+
+			err := checkOutputFormat(output)
+			if err != nil {
+				return err
+			}
+
+			err = setupReactorConfigDir(cmd)
+			if err != nil {
+				return err
+			}
+
+			lin, err := linstorClient()
+			if err != nil {
+				return err
+			}
+
+			orphans, err := cleanup.Find(cmd.Context(), lin)
+			if err != nil {
+				return err
+			}
+
+			if del && len(orphans) > 0 {
+				err = cleanup.CheckDelete(cmd.Context(), lin)
+				if err != nil {
+					return err
+				}
+
+				err = confirmDestructive(yes, describeOrphans(orphans))
+				if err != nil {
+					return err
+				}
+
+				orphans, err = cleanup.Delete(cmd.Context(), lin, orphans)
+				if err != nil {
+					return err
+				}
+			}
+
+			if output == outputJSON {
+				if orphans == nil {
+					orphans = []cleanup.Orphan{}
+				}
+				return printJSON(orphans)
+			}
+
+			printOrphans(orphans, del)
+
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Only report orphans, the default")
+	cmd.Flags().BoolVar(&del, "delete", false, "Remove the orphans")
+	addYesFlag(cmd, &yes, "deleting the orphans")
+	addReactorConfigDirFlag(cmd)
+	addOutputFlag(cmd, &output)
+
+	return cmd
+}
+
+// describeOrphans describes what deleting the orphans does, for the
+// confirmation prompt.
+func describeOrphans(orphans []cleanup.Orphan) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "The following orphans will be deleted, LINSTOR resources including all their data (drbd-reactor configs were read from %s):", reactor.ConfigDir)
+	for _, o := range orphans {
+		switch o.Kind {
+		case cleanup.KindResource:
+			fmt.Fprintf(&b, "\n  LINSTOR resource %s", o.Name)
+		case cleanup.KindConfig:
+			fmt.Fprintf(&b, "\n  drbd-reactor config %s (%s)", o.Name, o.Path)
+		}
+	}
+
+	return b.String()
+}
+
+func printOrphans(orphans []cleanup.Orphan, deleted bool) {
+	if len(orphans) == 0 {
+		fmt.Println("No orphans found")
+		return
+	}
+
+	verb := "Found"
+	if deleted {
+		verb = "Deleted"
+	}
+
+	for _, o := range orphans {
+		switch o.Kind {
+		case cleanup.KindResource:
+			fmt.Printf("%s LINSTOR resource %s without a drbd-reactor config\n", verb, o.Name)
+		case cleanup.KindConfig:
+			fmt.Printf("%s drbd-reactor config %s (%s) without LINSTOR resource %s\n", verb, o.Name, o.Path, o.Resource)
+		}
+	}
+}
@@ -1,6 +1,8 @@
 package cmd
 
 import (
+	"strings"
+
 	"github.com/olekukonko/tablewriter"
 
 	"github.com/LINBIT/linstor-gateway/pkg/common"
@@ -11,6 +13,8 @@ var (
 	tableColorOk       = tablewriter.Colors{tablewriter.FgGreenColor}
 	tableColorDegraded = tablewriter.Colors{tablewriter.FgYellowColor}
 	tableColorBad      = tablewriter.Colors{tablewriter.FgRedColor, tablewriter.Bold}
+
+	tableColorTransitioning = tablewriter.Colors{tablewriter.FgCyanColor}
 )
 
 func ServiceStateColor(state common.ServiceState) tablewriter.Colors {
@@ -19,6 +23,10 @@ func ServiceStateColor(state common.ServiceState) tablewriter.Colors {
 		return tableColorOk
 	case common.ServiceStateStopped:
 		return tableColorBad
+	case common.ServiceStateTransitioning:
+		return tableColorTransitioning
+	case common.ServiceStateFailed:
+		return tableColorBad
 	default:
 		return tableColorDegraded
 	}
@@ -36,3 +44,30 @@ func ResourceStateColor(state common.ResourceState) tablewriter.Colors {
 		return tableColorDegraded
 	}
 }
+
+func SyncStateColor(vol common.VolumeState) tablewriter.Colors {
+	switch {
+	case vol.Sync == common.SyncStateUpToDate:
+		return tableColorOk
+	case vol.Resyncing():
+		return tableColorDegraded
+	case vol.Sync == "":
+		return tableColorDegraded
+	default:
+		return tableColorBad
+	}
+}
+
+func DiskStateColor(diskState string) tablewriter.Colors {
+	switch diskState {
+	case common.SyncStateUpToDate, "Diskless":
+		return tableColorOk
+	case "Outdated", "Inconsistent", "Negotiating", "Consistent":
+		return tableColorDegraded
+	default:
+		if strings.HasPrefix(diskState, common.SyncStateSyncTarget) {
+			return tableColorDegraded
+		}
+		return tableColorBad
+	}
+}
@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+)
+
+// listColumn is a column of the table printed by a list command.
+type listColumn struct {
+	// name selects the column with --columns.
+	name   string
+	header string
+	// verbose columns are only shown with --verbose, unless they are
+	// selected with --columns.
+	verbose bool
+	// merge is set for columns whose cells repeat for every volume of a
+	// resource, like its name. Repeated cells are merged.
+	merge bool
+	// wide columns are only shown with the wide output, unless they are
+	// selected with --columns.
+	wide bool
+}
+
+// columnNames returns the names of the columns, for use in help texts and
+// error messages.
+func columnNames(columns []listColumn) string {
+	names := make([]string, len(columns))
+	for i := range columns {
+		names[i] = columns[i].name
+	}
+
+	return strings.Join(names, ", ")
+}
+
+// addColumnsFlag adds the flag to select the columns of the table to a list
+// command.
+func addColumnsFlag(cmd *cobra.Command, columns []listColumn, selected *[]string) {
+	cmd.Flags().StringSliceVar(selected, "columns", nil, fmt.Sprintf("Comma separated columns to show, in this order (any of %s). Overrides --verbose", columnNames(columns)))
+}
+
+// selectColumns returns the indexes of the columns to show: the ones named in
+// the value of the flag added by addColumnsFlag, or the default columns if it
+// is empty. The wide output shows all columns.
+func selectColumns(columns []listColumn, selected []string, verbose, wide bool) ([]int, error) {
+	var indexes []int
+	if len(selected) == 0 {
+		for i := range columns {
+			if wide || (verbose || !columns[i].verbose) && !columns[i].wide {
+				indexes = append(indexes, i)
+			}
+		}
+
+		return indexes, nil
+	}
+
+	for _, name := range selected {
+		index := -1
+		for i := range columns {
+			if columns[i].name == strings.TrimSpace(name) {
+				index = i
+				break
+			}
+		}
+
+		if index < 0 {
+			return nil, fmt.Errorf("unknown column '%s', expected any of %s", name, columnNames(columns))
+		}
+
+		indexes = append(indexes, index)
+	}
+
+	return indexes, nil
+}
+
+// listTable prints the selected columns of a list table. Rows are added with
+// a cell and a color for every column, and only the selected ones are shown.
+type listTable struct {
+	table    *tablewriter.Table
+	selected []int
+}
+
+func newListTable(columns []listColumn, selected []int) *listTable {
+	header := make([]string, len(selected))
+	var merged []int
+	for i, index := range selected {
+		header[i] = columns[index].header
+		if columns[index].merge {
+			merged = append(merged, i)
+		}
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader(header)
+	table.SetHeaderColor(headerColors(len(header))...)
+	table.SetAutoMergeCellsByColumnIndex(merged)
+	table.SetAutoFormatHeaders(false)
+
+	return &listTable{table: table, selected: selected}
+}
+
+func (t *listTable) add(row []string, colors []tablewriter.Colors) {
+	selectedRow := make([]string, len(t.selected))
+	selectedColors := make([]tablewriter.Colors, len(t.selected))
+	for i, index := range t.selected {
+		selectedRow[i] = row[index]
+		selectedColors[i] = colors[index]
+	}
+
+	t.table.Rich(selectedRow, selectedColors)
+}
+
+func (t *listTable) render() {
+	t.table.Render()
+}
@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"context"
+	"io"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/LINBIT/linstor-gateway/client"
+)
+
+// completionTimeout limits how long completing the identifiers of existing
+// targets and exports may take, so that the shell does not hang if the
+// server is slow or unreachable.
+const completionTimeout = 2 * time.Second
+
+// completeExisting returns a function that completes the arguments of a
+// command with the identifiers returned by list. maxArgs is the number of
+// arguments the command takes, or 0 if it takes any number. Identifiers that
+// were already given are not completed again.
+func completeExisting(maxArgs int, list func(ctx context.Context, cli *client.Client) ([]string, error)) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if maxArgs > 0 && len(args) >= maxArgs {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+
+		// The completion runs before the client of the command is set up,
+		// and anything it logs would end up in the shell.
+		base, err := parseBaseURL(host)
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		logger := log.New()
+		logger.SetOutput(io.Discard)
+		completionCli, err := client.NewClient(client.BaseURL(base), client.Log(logger))
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+
+		ctx := cmd.Context()
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		ctx, cancel := context.WithTimeout(ctx, completionTimeout)
+		defer cancel()
+
+		ids, err := list(ctx, completionCli)
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+
+		given := make(map[string]bool, len(args))
+		for _, arg := range args {
+			given[arg] = true
+		}
+
+		var completions []string
+		for _, id := range ids {
+			if !given[id] && strings.HasPrefix(id, toComplete) {
+				completions = append(completions, id)
+			}
+		}
+
+		return completions, cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+// completeISCSITargets completes the IQNs of existing iSCSI targets.
+func completeISCSITargets(maxArgs int) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return completeExisting(maxArgs, func(ctx context.Context, cli *client.Client) ([]string, error) {
+		cfgs, err := cli.Iscsi.GetAll(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		iqns := make([]string, 0, len(cfgs))
+		for _, cfg := range cfgs {
+			iqns = append(iqns, cfg.IQN.String())
+		}
+		return iqns, nil
+	})
+}
+
+// completeNFSExports completes the names of existing NFS exports.
+func completeNFSExports(maxArgs int) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return completeExisting(maxArgs, func(ctx context.Context, cli *client.Client) ([]string, error) {
+		cfgs, err := cli.Nfs.GetAll(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		names := make([]string, 0, len(cfgs))
+		for _, cfg := range cfgs {
+			names = append(names, cfg.Name)
+		}
+		return names, nil
+	})
+}
+
+// completeNVMETargets completes the NQNs of existing NVMe-oF targets.
+func completeNVMETargets(maxArgs int) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return completeExisting(maxArgs, func(ctx context.Context, cli *client.Client) ([]string, error) {
+		cfgs, err := cli.NvmeOf.GetAll(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		nqns := make([]string, 0, len(cfgs))
+		for _, cfg := range cfgs {
+			nqns = append(nqns, cfg.NQN.String())
+		}
+		return nqns, nil
+	})
+}
@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/mattn/go-isatty"
+	"github.com/spf13/cobra"
+
+	"github.com/LINBIT/linstor-gateway/pkg/common"
+)
+
+// errAborted is returned when the user does not confirm a destructive
+// operation.
+var errAborted = errors.New("aborted, nothing was changed")
+
+// addYesFlag adds the flag to skip the confirmation of a destructive command.
+// what describes the operation, e.g. "deleting the target".
+func addYesFlag(cmd *cobra.Command, yes *bool, what string) {
+	cmd.Flags().BoolVarP(yes, "yes", "y", false, fmt.Sprintf("Do not ask for confirmation before %s. Required if stdin is not a terminal", what))
+}
+
+// confirmDestructive prints what a destructive operation is about to do and
+// asks the user to confirm it, unless yes is set. If stdin is not a terminal,
+// nobody can answer, so the operation is refused instead of blocking or
+// reading an answer from a pipe.
+func confirmDestructive(yes bool, description string) error {
+	if yes {
+		return nil
+	}
+
+	fmt.Println(description)
+	if !isatty.IsTerminal(os.Stdin.Fd()) && !isatty.IsCygwinTerminal(os.Stdin.Fd()) {
+		return errors.New("stdin is not a terminal, use --yes to confirm")
+	}
+
+	if !confirm() {
+		return errAborted
+	}
+
+	return nil
+}
+
+// confirmDelete asks the user to confirm deleting the named targets or
+// exports, unless yes is set. what names the kind of resource in plural.
+func confirmDelete(yes bool, what string, names []string, opts common.DeleteOptions) error {
+	if yes {
+		return nil
+	}
+
+	var b strings.Builder
+	if opts.KeepResource {
+		fmt.Fprintf(&b, "The following %s will be stopped and no longer managed by LINSTOR Gateway. Their LINSTOR resources and data are kept:", what)
+	} else {
+		fmt.Fprintf(&b, "The following %s and all their data will be deleted:", what)
+	}
+	for _, name := range names {
+		fmt.Fprintf(&b, "\n  %s", name)
+	}
+
+	return confirmDestructive(false, b.String())
+}
+
+// confirm asks the user whether to continue and reports whether they agreed.
+func confirm() bool {
+	fmt.Print("Continue? [y/N] ")
+
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/LINBIT/linstor-gateway/pkg/reconcile"
+)
+
+func daemonCommand() *cobra.Command {
+	cfg := reconcile.DefaultConfig
+	var metricsAddr string
+
+	cmd := &cobra.Command{
+		Use:   "daemon",
+		Short: "Periodically checks all targets and exports and remediates problems",
+		Long: `Periodically checks all iSCSI targets, NFS exports and NVMe-oF targets, and
+remediates common problems:
+
+* --repair re-registers drbd-reactor configs of iSCSI targets that drifted from
+  what LINSTOR Gateway would generate, like "iscsi repair".
+* --restart stops and starts targets and exports whose services failed to
+  start, if that is still the case at the next check.
+
+Both are disabled by default, so problems are only reported. Failed actions
+are retried with an exponential backoff, and --max-actions limits the number
+of actions per check.
+
+Every problem and action is logged. The number of degraded targets and the
+remediation results are served in the Prometheus text format on
+--metrics-addr at /metrics.`,
+		Example:     "linstor-gateway daemon --repair --restart --interval 2m",
+		Args:        cobra.NoArgs,
+		Annotations: map[string]string{annotationNoTimeout: "true"},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if cfg.Interval <= 0 {
+				return errors.New("--interval must be positive")
+			}
+
+			err := setupReactorConfigDir(cmd)
+			if err != nil {
+				return err
+			}
+
+			lin, err := linstorClient()
+			if err != nil {
+				return err
+			}
+
+			metrics := reconcile.NewMetrics()
+			if metricsAddr != "" {
+				err := serveMetrics(cmd.Context(), metricsAddr, metrics)
+				if err != nil {
+					return err
+				}
+			}
+
+			if !cfg.Actions.Repair && !cfg.Actions.Restart {
+				log.Info("all remediation actions are disabled, only reporting problems")
+			}
+
+			return reconcile.New(cfg, reconcile.AllTargets(lin), metrics).Run(cmd.Context())
+		},
+	}
+
+	cmd.Flags().DurationVar(&cfg.Interval, "interval", cfg.Interval, "Time between two checks of all targets and exports")
+	cmd.Flags().BoolVar(&cfg.Actions.Repair, "repair", false, "Re-register drifted drbd-reactor configs of iSCSI targets")
+	cmd.Flags().BoolVar(&cfg.Actions.Restart, "restart", false, "Stop and start targets and exports whose services failed to start")
+	cmd.Flags().IntVar(&cfg.MaxActions, "max-actions", cfg.MaxActions, "Maximum number of remediation actions per check, 0 for no limit")
+	cmd.Flags().DurationVar(&cfg.Backoff, "backoff", cfg.Backoff, "Time to wait before retrying a failed action on the same target, doubled after every failure")
+	cmd.Flags().DurationVar(&cfg.MaxBackoff, "max-backoff", cfg.MaxBackoff, "Maximum time to wait before retrying a failed action")
+	cmd.Flags().StringVar(&metricsAddr, "metrics-addr", ":9101", "Host and port to serve the metrics on. Empty to disable")
+	addReactorConfigDirFlag(cmd)
+	cmd.DisableAutoGenTag = true
+
+	return cmd
+}
+
+// serveMetrics serves metrics at /metrics on addr until ctx is cancelled.
+func serveMetrics(ctx context.Context, addr string, metrics http.Handler) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics)
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		err := srv.Shutdown(shutdownCtx)
+		if err != nil {
+			log.WithError(err).Warn("failed to shut down metrics server cleanly")
+		}
+	}()
+
+	go func() {
+		err := srv.Serve(listener)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.WithError(err).Error("metrics server failed")
+		}
+	}()
+
+	log.WithField("addr", listener.Addr().String()).Info("serving metrics")
+
+	return nil
+}
@@ -0,0 +1,20 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// addDescriptionFlag adds the flag to set the description of a resource to a
+// create command.
+func addDescriptionFlag(cmd *cobra.Command, description *string) {
+	cmd.Flags().StringVar(description, "description", "", "Set a free-text description, e.g. the purpose or owner of the resource")
+}
+
+// formatDescription describes the description of a resource for display.
+func formatDescription(description string) string {
+	if description == "" {
+		return "none"
+	}
+
+	return description
+}
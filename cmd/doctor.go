@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/LINBIT/linstor-gateway/pkg/healthcheck"
+	"github.com/LINBIT/linstor-gateway/pkg/reactor"
+)
+
+func doctorCommand() *cobra.Command {
+	var output, resourceGroup string
+
+	cmd := &cobra.Command{
+		Use:   "doctor",
+		Short: "Diagnoses common misconfigurations of the LINSTOR cluster and this node",
+		Long: `Checks the LINSTOR cluster and the current node for common misconfigurations:
+whether the LINSTOR controller is reachable, satellites are online and support
+DRBD, storage pools and the resource group exist, drbd-reactor is running, and
+the drbd-reactor config directory is writable.
+
+Every check is reported on its own, with a hint on how to fix it if it fails.`,
+		Example: "linstor-gateway doctor --resource-group rg1",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			err := checkOutputFormat(output)
+			if err != nil {
+				return err
+			}
+
+			// The config directory is checked by a diagnostic.
+			reactor.ConfigDir = reactorConfigDir(cmd)
+
+			// An unreachable controller is reported by a diagnostic.
+			viper.Set("linstor.probe-timeout", 0)
+			lin, err := linstorClient()
+			if err != nil {
+				return err
+			}
+
+			results := healthcheck.Diagnose(cmd.Context(), lin, resourceGroup)
+
+			if output == outputJSON {
+				err = printJSON(results)
+				if err != nil {
+					return err
+				}
+			} else {
+				printDiagnoses(results)
+			}
+
+			failed := 0
+			for _, r := range results {
+				if !r.OK {
+					failed++
+				}
+			}
+			if failed > 0 {
+				// the failed checks were reported above
+				cmd.SilenceUsage = true
+				return fmt.Errorf("%d of %d checks failed", failed, len(results))
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&resourceGroup, "resource-group", "g", "DfltRscGrp", "The LINSTOR resource group that is expected to exist")
+	addReactorConfigDirFlag(cmd)
+	addOutputFlag(cmd, &output)
+
+	return cmd
+}
+
+func printDiagnoses(results []healthcheck.Diagnosis) {
+	for _, r := range results {
+		if r.OK {
+			fmt.Printf("%s %s\n", color.GreenString("[✓]"), r.Check)
+			continue
+		}
+
+		fmt.Printf("%s %s\n", color.RedString("[✗]"), r.Check)
+		fmt.Printf("    %s\n", r.Error)
+		if r.Hint != "" {
+			fmt.Printf("    %s\n", r.Hint)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/LINBIT/linstor-gateway/pkg/common"
+)
+
+// addDrbdFlags adds the flags to choose the DRBD port and minor numbers to a
+// create command.
+func addDrbdFlags(cmd *cobra.Command, port, minor *int) {
+	cmd.Flags().IntVar(port, "drbd-port", 0, "TCP port DRBD uses for replication. By default, LINSTOR assigns a free port")
+	cmd.Flags().IntVar(minor, "drbd-minor", 0, "DRBD minor number of the cluster private volume. The other volumes use this number plus their volume number. By default, LINSTOR assigns free minor numbers")
+}
+
+// formatDrbdNumber describes a DRBD port or minor number reported by LINSTOR.
+func formatDrbdNumber(n int) string {
+	if n == 0 {
+		return "unknown"
+	}
+
+	return strconv.Itoa(n)
+}
+
+// addDrbdOptionFlag adds the flag to set DRBD options of a resource to a
+// create command.
+func addDrbdOptionFlag(cmd *cobra.Command, options *[]string) {
+	cmd.Flags().StringArrayVar(options, "drbd-option", nil, fmt.Sprintf("Set a DRBD option in the form name=value, e.g. protocol=A or on-no-quorum=suspend-io (one of %s). Can be given more than once. By default, quorum=majority and on-no-quorum=io-error are set, and the other options are inherited from the resource group", strings.Join(common.DrbdOptionNames(), ", ")))
+}
+
+// parseDrbdOptions parses the values of the flag added by addDrbdOptionFlag.
+func parseDrbdOptions(options []string) (map[string]string, error) {
+	if len(options) == 0 {
+		return nil, nil
+	}
+
+	result := make(map[string]string, len(options))
+	for _, option := range options {
+		name, value, err := common.ParseDrbdOption(option)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --drbd-option: %w", err)
+		}
+
+		if _, ok := result[name]; ok {
+			return nil, fmt.Errorf("invalid --drbd-option: option %s is set more than once", name)
+		}
+		result[name] = value
+	}
+
+	return result, nil
+}
+
+// formatDrbdProtocol describes the DRBD replication protocol of a resource.
+func formatDrbdProtocol(options map[string]string) string {
+	protocol, ok := options[common.DrbdOptionProtocol]
+	if !ok {
+		return "resource group default"
+	}
+
+	return protocol
+}
+
+// formatQuorumPolicy describes when a resource has quorum, and what happens
+// to IO when it loses quorum, e.g. "majority, io-error without quorum".
+func formatQuorumPolicy(options map[string]string) string {
+	quorum, ok := options[common.DrbdOptionQuorum]
+	if !ok {
+		quorum = "resource group default"
+	}
+
+	onNoQuorum, ok := options[common.DrbdOptionOnNoQuorum]
+	if !ok {
+		onNoQuorum = "resource group default"
+	}
+
+	return fmt.Sprintf("%s, %s without quorum", quorum, onNoQuorum)
+}
@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+
+	"github.com/LINBIT/linstor-gateway/client"
+	"github.com/LINBIT/linstor-gateway/pkg/common"
+	"github.com/LINBIT/linstor-gateway/pkg/rest"
+)
+
+// Error codes used in the JSON error output. They stay stable, so that
+// scripts can rely on them.
+const (
+	codeNotFound        = "NotFound"
+	codeAlreadyExists   = "AlreadyExists"
+	codeInvalidArgument = "InvalidArgument"
+	codeUnavailable     = "Unavailable"
+	codeInternal        = "Internal"
+	codeUnknown         = "Unknown"
+)
+
+// jsonError is the JSON representation of an error.
+type jsonError struct {
+	Error string `json:"error"`
+	Code  string `json:"code"`
+	// Errors holds the individual errors if a command operated on
+	// multiple targets and more than one of them failed.
+	Errors []jsonError `json:"errors,omitempty"`
+}
+
+// errorCode classifies err into one of the error codes.
+func errorCode(err error) string {
+	var restErr rest.Error
+	if errors.As(err, &restErr) {
+		switch restErr.Code {
+		case http.StatusText(http.StatusNotFound):
+			return codeNotFound
+		case http.StatusText(http.StatusConflict):
+			return codeAlreadyExists
+		case http.StatusText(http.StatusBadRequest):
+			return codeInvalidArgument
+		case http.StatusText(http.StatusInternalServerError):
+			return codeInternal
+		default:
+			return codeUnknown
+		}
+	}
+
+	var validationErr common.ValidationError
+	var target noTarget
+	var export noExport
+	var netErr net.Error
+	switch {
+	case errors.Is(err, client.NotFoundError), errors.Is(err, common.ErrNotFound), errors.As(err, &target), errors.As(err, &export):
+		return codeNotFound
+	case errors.Is(err, common.ErrAlreadyExists):
+		return codeAlreadyExists
+	case errors.As(err, &validationErr):
+		return codeInvalidArgument
+	case errors.As(err, &netErr):
+		return codeUnavailable
+	default:
+		return codeUnknown
+	}
+}
+
+func toJSONError(err error) jsonError {
+	var multi multiError
+	if !errors.As(err, &multi) || len(multi) < 2 {
+		if len(multi) == 1 {
+			err = multi[0]
+		}
+		return jsonError{Error: err.Error(), Code: errorCode(err)}
+	}
+
+	result := jsonError{Error: err.Error()}
+	for _, e := range multi {
+		sub := toJSONError(e)
+		if result.Code == "" {
+			result.Code = sub.Code
+		} else if result.Code != sub.Code {
+			result.Code = codeUnknown
+		}
+		result.Errors = append(result.Errors, sub)
+	}
+	return result
+}
+
+// printError prints err for the user, either as plain text or as a JSON
+// object.
+func printError(err error, asJSON bool) {
+	if !asJSON {
+		fmt.Println(err)
+		return
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	encErr := enc.Encode(toJSONError(err))
+	if encErr != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+}
@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/LINBIT/linstor-gateway/pkg/iscsi"
+)
+
+// addAllowedInitiatorsFlags adds the flags to restrict which initiators may
+// connect to an iSCSI target.
+func addAllowedInitiatorsFlags(cmd *cobra.Command, allowedInitiators *[]string, ignoreInvalid *bool) {
+	cmd.Flags().StringSliceVar(allowedInitiators, "allowed-initiators", []string{}, "Restrict which initiator IQNs are allowed to connect to the target")
+	cmd.Flags().BoolVar(ignoreInvalid, "ignore-invalid-initiators", false, "Skip invalid IQNs in --allowed-initiators with a warning instead of failing")
+}
+
+// parseAllowedInitiators parses the IQNs of the allowed initiators. If any of
+// them is invalid, an error listing all rejected values is returned, unless
+// ignoreInvalid is set, in which case they are skipped with a warning.
+func parseAllowedInitiators(values []string, ignoreInvalid bool) ([]iscsi.Iqn, error) {
+	var iqns []iscsi.Iqn
+	var rejected []string
+	for _, value := range values {
+		iqn, err := iscsi.NewIqn(value)
+		if err != nil {
+			if ignoreInvalid {
+				log.WithField("error", err).WithField("iqn", value).Warnf("Invalid IQN for allowed initiator, ignoring")
+				continue
+			}
+			rejected = append(rejected, err.Error())
+			continue
+		}
+		iqns = append(iqns, iqn)
+	}
+
+	if len(rejected) > 0 {
+		return nil, fmt.Errorf("invalid allowed initiators, use --ignore-invalid-initiators to skip them:\n  %s", strings.Join(rejected, "\n  "))
+	}
+
+	return iqns, nil
+}
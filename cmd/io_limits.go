@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/LINBIT/linstor-gateway/pkg/common"
+)
+
+// addIOLimitFlags adds the flags to limit the throughput of a target to a
+// create command.
+func addIOLimitFlags(cmd *cobra.Command, readLimit, writeLimit *string) {
+	cmd.Flags().StringVar(readLimit, "read-limit", "", "Limit the read throughput of the target, either in bytes per second (e.g. 100M) or in IO operations per second (e.g. 5000iops)")
+	cmd.Flags().StringVar(writeLimit, "write-limit", "", "Limit the write throughput of the target, either in bytes per second (e.g. 100M) or in IO operations per second (e.g. 5000iops)")
+}
+
+// parseIOLimits parses the values of the flags added by addIOLimitFlags.
+// An empty value means that the throughput is not limited.
+func parseIOLimits(readLimit, writeLimit string) (*common.IOLimit, *common.IOLimit, error) {
+	read, err := common.ParseIOLimit(readLimit)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid --read-limit: %w", err)
+	}
+
+	write, err := common.ParseIOLimit(writeLimit)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid --write-limit: %w", err)
+	}
+
+	return read, write, nil
+}
+
+// formatIOLimit describes a throughput limit, e.g. "100M/s" or "5000 IOPS".
+func formatIOLimit(limit *common.IOLimit) string {
+	switch {
+	case limit == nil:
+		return "unlimited"
+	case limit.IOPS:
+		return fmt.Sprintf("%d IOPS", limit.Value)
+	default:
+		return limit.String() + "/s"
+	}
+}
@@ -2,17 +2,17 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/fatih/color"
 	log "github.com/sirupsen/logrus"
-	"os"
 	"strconv"
 	"strings"
 
+	"github.com/LINBIT/linstor-gateway/client"
 	"github.com/LINBIT/linstor-gateway/pkg/common"
 	"github.com/LINBIT/linstor-gateway/pkg/iscsi"
 	"github.com/olekukonko/tablewriter"
-	"github.com/rck/unit"
 	"github.com/spf13/cobra"
 )
 
@@ -29,24 +29,51 @@ as well as drbd-reactor is a prerequisite to use this tool.`,
 		Args: cobra.NoArgs,
 	}
 
-	rootCmd.DisableAutoGenTag = true
-
-	rootCmd.AddCommand(createISCSICommand())
-	rootCmd.AddCommand(deleteISCSICommand())
-	rootCmd.AddCommand(listISCSICommand())
-	rootCmd.AddCommand(startISCSICommand())
-	rootCmd.AddCommand(stopISCSICommand())
-	rootCmd.AddCommand(addVolumeISCSICommand())
-	rootCmd.AddCommand(deleteVolumeISCSICommand())
-
-	return rootCmd
+	return protocolCommand(rootCmd,
+		createISCSICommand(),
+		cloneISCSICommand(),
+		deleteISCSICommand(),
+		listISCSICommand(),
+		getISCSICommand(),
+		startISCSICommand(),
+		stopISCSICommand(),
+		addVolumeISCSICommand(),
+		deleteVolumeISCSICommand(),
+		exportVolumeISCSICommand(),
+		unexportVolumeISCSICommand(),
+		validateISCSICommand(),
+		setCHAPISCSICommand(),
+		setDescriptionISCSICommand(),
+		repairISCSICommand(),
+		repairPrivateVolumeISCSICommand(),
+		showConfigISCSICommand(),
+		failoverISCSICommand(),
+	)
 }
 
 func createISCSICommand() *cobra.Command {
-	var username, password, group string
+	var username, group string
+	var password passwordFlags
 	var serviceIps []common.IpCidr
 	var allowedInitiators []string
+	var ignoreInvalidInitiators bool
 	var grossSize bool
+	var fileSystem string
+	var keepOnFailure, skipCapacityCheck, noStart bool
+	var waitSync waitSyncFlags
+	var overwrite, yes bool
+	var replicaCount int
+	var readLimit, writeLimit string
+	var layerList string
+	var storagePool string
+	var drbdPort, drbdMinor int
+	var labels []string
+	var description string
+	var drbdOptions []string
+	var cacheMode string
+	var resourceName string
+	var unexportedLuns []int
+	var noServiceIP bool
 
 	cmd := &cobra.Command{
 		Use:   "create IQN SERVICE_IPS [VOLUME_SIZE]...",
@@ -54,98 +81,406 @@ func createISCSICommand() *cobra.Command {
 		Long: `Creates a highly available iSCSI target based on LINSTOR and drbd-reactor.
 At first it creates a new resource within the LINSTOR system, using the
 specified resource group. The name of the linstor resources is derived
-from the IQN's World Wide Name, which must be unique.
+from the IQN's World Wide Name, which must be unique. Use --resource-name
+to choose a different name, e.g. to follow a naming scheme.
 After that it creates a configuration for drbd-reactor to manage the
-high availability primitives.`,
-		Example: `linstor-gateway iscsi create iqn.2019-08.com.linbit:example 192.168.122.181/24 2G`,
-		Args:    cobra.MinimumNArgs(2),
+high availability primitives.
+
+SERVICE_IPS is a comma separated list. Every service IP becomes a portal of
+the target, e.g. to make it reachable from separate networks. All portals
+belong to the same target portal group, so they accept the same initiators.
+
+With --no-service-ip, SERVICE_IPS is left out. The target then has no
+floating IP and listens on all addresses of the node it runs on, e.g. for a
+consumer that runs on the same node.`,
+		Example: `linstor-gateway iscsi create iqn.2019-08.com.linbit:example 192.168.122.181/24 2G
+linstor-gateway iscsi create iqn.2019-08.com.linbit:example 192.168.122.181/24,10.10.0.181/16 2G
+linstor-gateway iscsi create iqn.2019-08.com.linbit:internal 2G --no-service-ip`,
+		Args: cobra.MinimumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			ctx := context.Background()
+			ctx := cmd.Context()
 
-			iqn, err := iscsi.NewIqn(args[0])
+			sizeArgs := args[1:]
+			if !noServiceIP {
+				if len(args) < 2 {
+					return errors.New("missing SERVICE_IPS, use --no-service-ip to create a target without one")
+				}
+
+				for _, ipString := range strings.Split(args[1], ",") {
+					ip, err := common.ServiceIPFromString(ipString)
+					if err != nil {
+						return fmt.Errorf("invalid service IP '%s': %w", ipString, err)
+					}
+					serviceIps = append(serviceIps, ip)
+				}
+				sizeArgs = args[2:]
+			}
+
+			err := checkReplicaCount(cmd, replicaCount)
 			if err != nil {
-				return fmt.Errorf("invalid IQN '%s': %w", args[0], err)
+				return err
 			}
 
-			for _, ipString := range strings.Split(args[1], ",") {
-				ip, err := common.ServiceIPFromString(ipString)
-				if err != nil {
-					return fmt.Errorf("invalid service IP '%s': %w", ipString, err)
-				}
-				serviceIps = append(serviceIps, ip)
+			readIOLimit, writeIOLimit, err := parseIOLimits(readLimit, writeLimit)
+			if err != nil {
+				return err
+			}
+
+			layers, err := parseLayerList(layerList)
+			if err != nil {
+				return err
+			}
+
+			parsedLabels, err := parseLabels(labels)
+			if err != nil {
+				return err
+			}
+
+			parsedDrbdOptions, err := parseDrbdOptions(drbdOptions)
+			if err != nil {
+				return err
+			}
+
+			err = checkResourceName(resourceName)
+			if err != nil {
+				return err
+			}
+
+			if password.stdin && overwrite && !yes {
+				return fmt.Errorf("--password-stdin can not be combined with the confirmation of --overwrite, add --yes")
+			}
+
+			chapPassword, err := password.read()
+			if err != nil {
+				return err
+			}
+
+			iqn, err := iscsi.NewIqn(args[0])
+			if err != nil {
+				return fmt.Errorf("invalid IQN '%s': %w", args[0], err)
 			}
 
 			var volumes []common.VolumeConfig
-			for i, rawvalue := range args[2:] {
-				val, err := unit.MustNewUnit(unit.DefaultUnits).ValueFromString(rawvalue)
+			for i, rawvalue := range sizeArgs {
+				sizeKiB, err := parseVolumeSize(rawvalue)
 				if err != nil {
 					return err
 				}
 
 				volumes = append(volumes, common.VolumeConfig{
-					Number:  i + 1,
-					SizeKiB: uint64(val.Value / unit.K),
+					Number:     i + 1,
+					SizeKiB:    sizeKiB,
+					FileSystem: fileSystem,
 				})
 			}
 
-			var allowedInitiatorIqns []iscsi.Iqn
-			for _, i := range allowedInitiators {
-				iqn, err := iscsi.NewIqn(i)
-				if err != nil {
-					log.WithField("error", err).WithField("iqn", i).Warnf("Invalid IQN for allowed initiator, ignoring")
-					continue
+			for _, lun := range unexportedLuns {
+				if lun < 1 || lun > len(volumes) {
+					return fmt.Errorf("--unexported-luns: no logical unit %d, expected a number from 1 to %d", lun, len(volumes))
 				}
-				allowedInitiatorIqns = append(allowedInitiatorIqns, iqn)
+				exported := false
+				volumes[lun-1].Exported = &exported
+			}
+
+			allowedInitiatorIqns, err := parseAllowedInitiators(allowedInitiators, ignoreInvalidInitiators)
+			if err != nil {
+				return err
 			}
 
-			_, err = cli.Iscsi.Create(ctx, &iscsi.ResourceConfig{
+			rsc := &iscsi.ResourceConfig{
 				IQN:               iqn,
+				ResourceName:      resourceName,
 				Username:          username,
-				Password:          password,
+				Password:          chapPassword,
 				ServiceIPs:        serviceIps,
+				NoServiceIP:       noServiceIP,
 				Volumes:           volumes,
 				AllowedInitiators: allowedInitiatorIqns,
 				ResourceGroup:     group,
 				GrossSize:         grossSize,
-			})
+				PlacementCount:    replicaCount,
+				LayerList:         layers,
+				StoragePool:       storagePool,
+				DrbdPort:          drbdPort,
+				DrbdMinor:         drbdMinor,
+				Labels:            parsedLabels,
+				Description:       description,
+				DrbdOptions:       parsedDrbdOptions,
+				ReadLimit:         readIOLimit,
+				WriteLimit:        writeIOLimit,
+				CacheMode:         cacheMode,
+			}
+
+			if overwrite && !yes {
+				existing, err := cli.Iscsi.Get(ctx, iqn)
+				if err != nil && err != client.NotFoundError {
+					return err
+				}
+
+				if err == nil {
+					requested := *rsc
+					requested.FillDefaults()
+					requested.Volumes = append([]common.VolumeConfig{common.ClusterPrivateVolume()}, requested.Volumes...)
+					err = confirmOverwrite("target", existing, &requested)
+					if err != nil {
+						return err
+					}
+				}
+			}
+
+			created, err := cli.Iscsi.Create(ctx, rsc, common.CreateOptions{KeepOnFailure: keepOnFailure, Overwrite: overwrite, SkipCapacityCheck: skipCapacityCheck, NoStart: noStart, WaitSync: waitSync.wait, SyncTimeout: waitSync.timeout})
 			if err != nil {
 				return err
 			}
 
 			fmt.Printf("Created iSCSI target '%s'\n", iqn)
+			warnReplicas(iqn.String(), created.Status)
+			if noStart {
+				hintStart("iscsi", iqn.String())
+			}
 
 			return nil
 		},
 	}
 
 	cmd.Flags().StringVarP(&username, "username", "u", "", "Set the username to use for CHAP authentication")
-	cmd.Flags().StringVarP(&password, "password", "p", "", "Set the password to use for CHAP authentication")
+	addPasswordFlags(cmd, &password)
 	cmd.Flags().StringVarP(&group, "resource-group", "g", "DfltRscGrp", "Set the LINSTOR resource group")
-	cmd.Flags().StringSliceVar(&allowedInitiators, "allowed-initiators", []string{}, "Restrict which initiator IQNs are allowed to connect to the target")
-	cmd.Flags().BoolVar(&grossSize, "gross", false, "Make all size options specify gross size, i.e. the actual space used on disk")
+	addResourceNameFlag(cmd, "World Wide Name of the IQN", &resourceName)
+	addAllowedInitiatorsFlags(cmd, &allowedInitiators, &ignoreInvalidInitiators)
+	cmd.Flags().BoolVar(&grossSize, "gross", false, "Make all size options specify gross size, i.e. the actual space used on disk. This also applies to the internal cluster private volume")
+	cmd.Flags().StringVar(&fileSystem, "filesystem", "", fmt.Sprintf("Create a file system on the logical units (one of %s). By default, the logical units are raw block devices", strings.Join(iscsi.SupportedFileSystems, ", ")))
+	cmd.Flags().BoolVar(&keepOnFailure, "keep-on-failure", false, "Do not clean up the LINSTOR resource if creating the target fails, for debugging")
+	addSkipCapacityCheckFlag(cmd, &skipCapacityCheck)
+	addNoStartFlag(cmd, "target", &noStart)
+	addWaitSyncFlags(cmd, "target", &waitSync)
+	cmd.Flags().StringVar(&cacheMode, "cache-mode", "", fmt.Sprintf("Select whether the logical units report a volatile write cache to initiators (one of %s). By default, the LIO default is used", strings.Join(iscsi.SupportedCacheModes, ", ")))
+	cmd.Flags().IntSliceVar(&unexportedLuns, "unexported-luns", nil, "Comma separated numbers of logical units that are created, but not mapped into the target until export-volume is used")
+	addReplicaCountFlag(cmd, &replicaCount)
+	addLayerListFlag(cmd, &layerList)
+	addStoragePoolFlag(cmd, &storagePool)
+	cmd.Flags().BoolVar(&noServiceIP, "no-service-ip", false, "Create the target without service IPs. It is only reachable on the addresses of the node it runs on")
+	addDrbdFlags(cmd, &drbdPort, &drbdMinor)
+	addLabelFlag(cmd, &labels)
+	addDescriptionFlag(cmd, &description)
+	addDrbdOptionFlag(cmd, &drbdOptions)
+	addIOLimitFlags(cmd, &readLimit, &writeLimit)
+	addOverwriteFlags(cmd, "target", &overwrite, &yes)
 
 	return cmd
 }
 
+func cloneISCSICommand() *cobra.Command {
+	var serviceIPs string
+	var keepOnFailure, skipCapacityCheck, noStart bool
+	var waitSync waitSyncFlags
+
+	cmd := &cobra.Command{
+		Use:   "clone SRC_IQN DST_IQN",
+		Short: "Creates an iSCSI target with the configuration of an existing one",
+		Long: `Creates a new iSCSI target with the same configuration as an existing one.
+The logical units, CHAP credentials, allowed initiators, resource group and
+limits are copied. The data of the logical units is not copied.
+
+Two targets can not share a service IP, so the new service IPs have to be
+given with --service-ips. A service IP without a prefix length uses the
+prefix length of the service IP of the source target at the same position.`,
+		Example: `linstor-gateway iscsi clone iqn.2019-08.com.linbit:example iqn.2019-08.com.linbit:example2 --service-ips 192.168.122.182`,
+		Args:    cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+
+			srcIqn, err := iscsi.NewIqn(args[0])
+			if err != nil {
+				return fmt.Errorf("invalid IQN '%s': %w", args[0], err)
+			}
+
+			dstIqn, err := iscsi.NewIqn(args[1])
+			if err != nil {
+				return fmt.Errorf("invalid IQN '%s': %w", args[1], err)
+			}
+
+			src, err := cli.Iscsi.GetWithSecrets(ctx, srcIqn)
+			if err == client.NotFoundError {
+				return noTarget(srcIqn.String())
+			}
+			if err != nil {
+				return err
+			}
+
+			_, err = cli.Iscsi.Get(ctx, dstIqn)
+			if err == nil {
+				return fmt.Errorf("target '%s' already exists", dstIqn)
+			}
+			if err != client.NotFoundError {
+				return err
+			}
+
+			ips, err := parseCloneServiceIPs(serviceIPs, src.ServiceIPs)
+			if err != nil {
+				return err
+			}
+
+			rsc, err := src.CloneAs(dstIqn, ips)
+			if err != nil {
+				return err
+			}
+
+			created, err := cli.Iscsi.Create(ctx, rsc, common.CreateOptions{KeepOnFailure: keepOnFailure, SkipCapacityCheck: skipCapacityCheck, NoStart: noStart, WaitSync: waitSync.wait, SyncTimeout: waitSync.timeout})
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("Created iSCSI target '%s' from '%s'\n", dstIqn, srcIqn)
+			warnReplicas(dstIqn.String(), created.Status)
+			if noStart {
+				hintStart("iscsi", dstIqn.String())
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&serviceIPs, "service-ips", "", "Comma separated service IPs of the new target")
+	cmd.Flags().BoolVar(&keepOnFailure, "keep-on-failure", false, "Do not clean up the LINSTOR resource if creating the target fails, for debugging")
+	addSkipCapacityCheckFlag(cmd, &skipCapacityCheck)
+	addNoStartFlag(cmd, "target", &noStart)
+	addWaitSyncFlags(cmd, "target", &waitSync)
+	_ = cmd.MarkFlagRequired("service-ips")
+
+	return cmd
+}
+
+// parseCloneServiceIPs parses the comma separated service IPs of a cloned
+// target. An IP without a prefix length takes the prefix length of the
+// template IP at the same position, or of the last template IP.
+func parseCloneServiceIPs(raw string, templates []common.IpCidr) ([]common.IpCidr, error) {
+	var ips []common.IpCidr
+	for i, ipString := range strings.Split(raw, ",") {
+		ipString = strings.TrimSpace(ipString)
+		if !strings.Contains(ipString, "/") && len(templates) > 0 {
+			template := templates[len(templates)-1]
+			if i < len(templates) {
+				template = templates[i]
+			}
+			ipString = fmt.Sprintf("%s/%d", ipString, template.Prefix())
+		}
+
+		ip, err := common.ServiceIPFromString(ipString)
+		if err != nil {
+			return nil, fmt.Errorf("invalid service IP '%s': %w", ipString, err)
+		}
+		ips = append(ips, ip)
+	}
+
+	return ips, nil
+}
+
+// iscsiListColumns are the columns of the table printed by "iscsi list".
+var iscsiListColumns = []listColumn{
+	{name: "iqn", header: "IQN", merge: true},
+	{name: "service_ip", header: "Service IP", merge: true},
+	{name: "state", header: "Service state"},
+	{name: "lun", header: "LUN"},
+	{name: "linstor_state", header: "LINSTOR state"},
+	{name: "sync", header: "Sync"},
+	{name: "provisioned", header: "Provisioned", verbose: true},
+	{name: "allocated", header: "Allocated", verbose: true},
+	{name: "config_file", header: "Config file", verbose: true, merge: true},
+	{name: "nodes", header: "Nodes", wide: true},
+}
+
 func listISCSICommand() *cobra.Command {
-	return &cobra.Command{
+	var verbose bool
+	var output, state, selector string
+	var columns, sortBy []string
+	var offset, limit int
+
+	cmd := &cobra.Command{
 		Use:   "list",
 		Short: "Lists iSCSI targets",
 		Long: `Lists the iSCSI targets created with this tool and provides an overview
 about the existing drbd-reactor and linstor parts.`,
-		Example: "linstor-gateway iscsi list",
+		Example: "linstor-gateway iscsi list\nlinstor-gateway iscsi list --state degraded -o json\nlinstor-gateway iscsi list --selector team=foo\nlinstor-gateway iscsi list --columns iqn,service_ip,state\nlinstor-gateway iscsi list --sort state,iqn",
 		Args:    cobra.NoArgs,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			cfgs, err := cli.Iscsi.GetAll(context.TODO())
+			err := checkWideOutputFormat(output)
 			if err != nil {
 				return err
 			}
 
-			table := tablewriter.NewWriter(os.Stdout)
-			table.SetHeader([]string{"IQN", "Service IP", "Service state", "LUN", "LINSTOR state"})
-			table.SetHeaderColor(tableColorHeader, tableColorHeader, tableColorHeader, tableColorHeader, tableColorHeader)
+			err = checkStateFilter(state)
+			if err != nil {
+				return err
+			}
 
-			degradedResources := 0
+			selected, err := parseSelector(selector)
+			if err != nil {
+				return err
+			}
+
+			sortKeys, err := parseSortKeys(sortBy, "iqn")
+			if err != nil {
+				return err
+			}
+
+			shown, err := selectColumns(iscsiListColumns, columns, verbose, output == outputWide)
+			if err != nil {
+				return err
+			}
+			if output == outputJSON && len(columns) > 0 {
+				return errors.New("--columns only applies to the table output")
+			}
+
+			err = checkPage(offset, limit)
+			if err != nil {
+				return err
+			}
+
+			// filtering and sorting happen here, so the page can only be
+			// taken afterwards
+			local := state != stateFilterAll || len(selected) > 0 || len(sortKeys) > 0
+			pageOffset, pageLimit := serverPage(offset, limit, local)
+			cfgs, err := cli.Iscsi.GetPage(cmd.Context(), pageOffset, pageLimit)
+			if err != nil {
+				return err
+			}
+
+			filtered := make([]*iscsi.ResourceConfig, 0, len(cfgs))
+			for _, cfg := range cfgs {
+				if matchesStateFilter(state, &cfg.Status) && common.MatchesSelector(cfg.Labels, selected) {
+					filtered = append(filtered, cfg)
+				}
+			}
+			cfgs = filtered
+
+			if len(sortKeys) > 0 {
+				fields := make([]sortFields, len(cfgs))
+				for i, cfg := range cfgs {
+					fields[i] = sortFields{id: cfg.IQN.String(), status: &cfg.Status, sizeKiB: totalSize(cfg.Volumes)}
+					if len(cfg.ServiceIPs) > 0 {
+						fields[i].serviceIP = cfg.ServiceIPs[0].IP()
+					}
+				}
+
+				sorted := make([]*iscsi.ResourceConfig, len(cfgs))
+				for i, index := range sortOrder(fields, sortKeys) {
+					sorted[i] = cfgs[index]
+				}
+				cfgs = sorted
+			}
+
+			start, end := localPage(offset, limit, len(cfgs), local)
+			cfgs = cfgs[start:end]
+			hintNextPage(offset, limit, len(cfgs))
+
+			if output == outputJSON {
+				return printJSON(cfgs)
+			}
+
+			table := newListTable(iscsiListColumns, shown)
+
+			var counts common.Counts
 			for _, cfg := range cfgs {
 				serviceIpStrings := make([]string, len(cfg.ServiceIPs))
 				for i := range cfg.ServiceIPs {
@@ -157,27 +492,179 @@ about the existing drbd-reactor and linstor parts.`,
 						continue
 					}
 
-					table.Rich(
-						[]string{cfg.IQN.String(), strings.Join(serviceIpStrings, ", "), cfg.Status.Service.String(), strconv.Itoa(vol.Number), vol.State.String()},
-						[]tablewriter.Colors{{}, {}, ServiceStateColor(cfg.Status.Service), {}, ResourceStateColor(vol.State)},
-					)
-					if vol.State != common.ResourceStateOK {
-						degradedResources++
+					row := []string{
+						cfg.IQN.String(),
+						strings.Join(serviceIpStrings, ", "),
+						cfg.Status.Service.String(),
+						volumeNumber(cfg.Volumes, vol.Number),
+						vol.State.String(),
+						vol.Sync,
+						common.FormatSize(volumeSize(cfg.Volumes, vol.Number)),
+						formatAllocated(vol.AllocatedKiB),
+						cfg.Status.ConfigPath,
+						formatNodeVolumes(vol),
 					}
+					colors := make([]tablewriter.Colors, len(row))
+					colors[2] = ServiceStateColor(cfg.Status.Service)
+					colors[4] = ResourceStateColor(vol.State)
+					colors[5] = SyncStateColor(vol)
+
+					table.add(row, colors)
+					counts.AddVolume(vol)
 				}
 			}
 
-			table.SetAutoMergeCellsByColumnIndex([]int{0, 1})
-			table.SetAutoFormatHeaders(false)
-			table.Render()
+			table.render()
 
-			if degradedResources > 0 {
-				log.Warnf("Some resources are degraded. Run %s for possible solutions.", bold("linstor advise resource"))
+			warnVolumes(counts)
+			for _, cfg := range cfgs {
+				warnInconsistent(cfg.IQN.String(), cfg.Status, iscsiRepairRemedy(cfg.IQN.String()))
+				warnReplicas(cfg.IQN.String(), cfg.Status)
 			}
 
 			return nil
 		},
 	}
+
+	addVerboseFlag(cmd, &verbose)
+	addColumnsFlag(cmd, iscsiListColumns, &columns)
+	addSortFlag(cmd, "iqn", &sortBy)
+	addWideOutputFlag(cmd, &output)
+	addStateFilterFlag(cmd, &state)
+	addSelectorFlag(cmd, &selector)
+	addPageFlags(cmd, &offset, &limit)
+
+	return cmd
+}
+
+func getISCSICommand() *cobra.Command {
+	var output string
+	var showSecrets bool
+
+	cmd := &cobra.Command{
+		Use:   "get IQN",
+		Short: "Shows the details of an iSCSI target",
+		Long: `Shows the configuration and the current state of a single iSCSI target.
+
+Instead of the IQN, the name of the LINSTOR resource backing the target can
+be given.`,
+		Example: `linstor-gateway iscsi get iqn.2019-08.com.linbit:example
+linstor-gateway iscsi get example`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			err := checkWideOutputFormat(output)
+			if err != nil {
+				return err
+			}
+
+			iqn, err := resolveISCSITarget(cmd.Context(), args[0])
+			if err != nil {
+				return err
+			}
+
+			get := cli.Iscsi.Get
+			if showSecrets {
+				get = cli.Iscsi.GetWithSecrets
+			}
+
+			cfg, err := get(cmd.Context(), iqn)
+			if err == client.NotFoundError {
+				return noTarget(iqn.String())
+			}
+			if err != nil {
+				return err
+			}
+
+			if output == outputJSON {
+				if showSecrets {
+					return printJSON((*iscsi.ResourceConfigWithSecrets)(cfg))
+				}
+				return printJSON(cfg)
+			}
+
+			serviceIPs := make([]string, len(cfg.ServiceIPs))
+			for i := range cfg.ServiceIPs {
+				serviceIPs[i] = cfg.ServiceIPs[i].String()
+			}
+			if len(serviceIPs) == 0 {
+				serviceIPs = []string{"none"}
+			}
+			initiators := make([]string, len(cfg.AllowedInitiators))
+			for i := range cfg.AllowedInitiators {
+				initiators[i] = cfg.AllowedInitiators[i].String()
+			}
+			if len(initiators) == 0 {
+				initiators = []string{"any"}
+			}
+
+			printDetails([][2]string{
+				{"IQN", cfg.IQN.String()},
+				{"Service IPs", strings.Join(serviceIPs, ", ")},
+				{"Service state", cfg.Status.Service.String()},
+				{"Primary", cfg.Status.Primary},
+				{"Nodes", strings.Join(cfg.Status.Nodes, ", ")},
+				{"LINSTOR resource", cfg.ResourceName},
+				{"Resource group", cfg.ResourceGroup},
+				{"Placement", formatPlacement(cfg.Status.Placement)},
+				{"Replicas", formatReplicas(cfg.Status)},
+				{"Layers", formatLayerList(cfg.LayerList)},
+				{"DRBD port", formatDrbdNumber(cfg.Status.DrbdPort)},
+				{"DRBD protocol", formatDrbdProtocol(cfg.DrbdOptions)},
+				{"Quorum", formatQuorumPolicy(cfg.DrbdOptions)},
+				{"Labels", formatLabels(cfg.Labels)},
+				{"Description", formatDescription(cfg.Description)},
+				{"Gross size", strconv.FormatBool(cfg.GrossSize)},
+				{"CHAP", formatChap(cfg.Username, cfg.Password, showSecrets)},
+				{"Allowed initiators", strings.Join(initiators, ", ")},
+				{"Read limit", formatIOLimit(cfg.ReadLimit)},
+				{"Write limit", formatIOLimit(cfg.WriteLimit)},
+				{"Cache mode", formatCacheMode(cfg.CacheMode)},
+				{"Config file", cfg.Status.ConfigPath},
+			})
+			fmt.Println()
+			renderVolumes("LUN", cfg.Volumes, cfg.Status, nil, func(int) []string { return nil })
+			if output == outputWide {
+				fmt.Println()
+				renderNodeVolumes("LUN", cfg.Volumes, cfg.Status)
+			}
+			warnInconsistent(cfg.IQN.String(), cfg.Status, iscsiRepairRemedy(cfg.IQN.String()))
+			warnReplicas(cfg.IQN.String(), cfg.Status)
+
+			return nil
+		},
+		ValidArgsFunction: completeISCSITargets(1),
+	}
+
+	addWideOutputFlag(cmd, &output)
+	cmd.Flags().BoolVar(&showSecrets, "show-secrets", false, "Show the CHAP password instead of hiding it")
+
+	return cmd
+}
+
+// resolveISCSITarget returns the IQN of the target identified by arg, which is
+// either an IQN or the name of the LINSTOR resource backing the target.
+func resolveISCSITarget(ctx context.Context, arg string) (iscsi.Iqn, error) {
+	iqn, iqnErr := iscsi.NewIqn(arg)
+	if iqnErr == nil {
+		return iqn, nil
+	}
+
+	cfgs, err := cli.Iscsi.GetAll(ctx)
+	if err != nil {
+		return iscsi.Iqn{}, err
+	}
+
+	for _, cfg := range cfgs {
+		rscName := cfg.ResourceName
+		if rscName == "" {
+			rscName = cfg.IQN.WWN()
+		}
+		if rscName == arg {
+			return cfg.IQN, nil
+		}
+	}
+
+	return iscsi.Iqn{}, fmt.Errorf("no target uses '%s' as LINSTOR resource, and it is not an IQN either: %w", arg, iqnErr)
 }
 
 func startISCSICommand() *cobra.Command {
@@ -196,7 +683,11 @@ func startISCSICommand() *cobra.Command {
 					continue
 				}
 
-				_, err = cli.Iscsi.Start(context.Background(), iqn)
+				_, err = cli.Iscsi.Start(cmd.Context(), iqn)
+				if err == client.NotFoundError {
+					allErrs = append(allErrs, noTarget(iqn.String()))
+					continue
+				}
 				if err != nil {
 					allErrs = append(allErrs, err)
 					continue
@@ -207,12 +698,13 @@ func startISCSICommand() *cobra.Command {
 
 			return allErrs.Err()
 		},
+		ValidArgsFunction: completeISCSITargets(0),
 	}
 }
 
 func stopISCSICommand() *cobra.Command {
 	return &cobra.Command{
-		Use:     "stop IQN",
+		Use:     "stop IQN...",
 		Short:   "Stops an iSCSI target",
 		Long:    `Disables an iSCSI target, making it unavailable to initiators while not deleting it.`,
 		Example: `linstor-gateway iscsi stop iqn.2019-08.com.linbit:example`,
@@ -226,7 +718,11 @@ func stopISCSICommand() *cobra.Command {
 					continue
 				}
 
-				_, err = cli.Iscsi.Stop(context.Background(), iqn)
+				_, err = cli.Iscsi.Stop(cmd.Context(), iqn)
+				if err == client.NotFoundError {
+					allErrs = append(allErrs, noTarget(iqn.String()))
+					continue
+				}
 				if err != nil {
 					allErrs = append(allErrs, err)
 					continue
@@ -237,19 +733,52 @@ func stopISCSICommand() *cobra.Command {
 
 			return allErrs.Err()
 		},
+		ValidArgsFunction: completeISCSITargets(0),
 	}
 }
 
 func deleteISCSICommand() *cobra.Command {
-	return &cobra.Command{
+	var match string
+	var yes bool
+	var opts common.DeleteOptions
+
+	cmd := &cobra.Command{
 		Use:   "delete IQN...",
 		Short: "Deletes an iSCSI target",
 		Long: `Deletes an iSCSI target by stopping and deleting the corresponding
 drbd-reactor configuration and removing the LINSTOR resources. All logical units
-of the target will be deleted.`,
-		Example: "linstor-gateway iscsi delete iqn.2019-08.com.linbit:example",
-		Args:    cobra.MinimumNArgs(1),
+of the target will be deleted.
+
+With --match, all targets whose IQN matches the given glob pattern (or starts
+with it, if it contains no glob characters) are deleted instead.
+
+With --keep-resource, only the drbd-reactor configuration is removed. The
+LINSTOR resources and their data are kept, so that they can be managed
+manually.
+
+The targets to delete have to be confirmed, unless --yes is given.`,
+		Example: `linstor-gateway iscsi delete iqn.2019-08.com.linbit:example
+linstor-gateway iscsi delete --match 'iqn.2019-08.com.linbit:test-*'`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if match != "" {
+				return cobra.NoArgs(cmd, args)
+			}
+			return cobra.MinimumNArgs(1)(cmd, args)
+		},
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if match != "" {
+				var err error
+				args, err = matchISCSITargets(cmd.Context(), match)
+				if err != nil || len(args) == 0 {
+					return err
+				}
+			}
+
+			err := confirmDelete(yes, "targets", args, opts)
+			if err != nil {
+				return err
+			}
+
 			var allErrs multiError
 			for _, rawiqn := range args {
 				iqn, err := iscsi.NewIqn(rawiqn)
@@ -258,22 +787,59 @@ of the target will be deleted.`,
 					continue
 				}
 
-				err = cli.Iscsi.Delete(context.Background(), iqn)
+				err = cli.Iscsi.Delete(cmd.Context(), iqn, opts)
 				if err != nil {
 					allErrs = append(allErrs, err)
 					continue
 				}
 
 				fmt.Printf("Deleted target \"%s\"\n", iqn)
+				hintKeptResource(opts)
 			}
 
 			return allErrs.Err()
 		},
+		ValidArgsFunction: completeISCSITargets(0),
+	}
+
+	cmd.Flags().StringVar(&match, "match", "", "Delete all targets whose IQN matches this glob pattern, or starts with it if it contains no glob characters")
+	addKeepResourceFlag(cmd, "targets", &opts)
+	addYesFlag(cmd, &yes, "deleting the targets")
+
+	return cmd
+}
+
+// matchISCSITargets returns the IQNs of all targets that match pattern.
+func matchISCSITargets(ctx context.Context, pattern string) ([]string, error) {
+	cfgs, err := cli.Iscsi.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	iqns := make([]string, len(cfgs))
+	for i, cfg := range cfgs {
+		iqns[i] = cfg.IQN.String()
 	}
+
+	matches, err := matchNames(pattern, iqns)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(matches) == 0 {
+		fmt.Printf("No targets match '%s'\n", pattern)
+		return nil, nil
+	}
+
+	return matches, nil
 }
 
 func addVolumeISCSICommand() *cobra.Command {
-	return &cobra.Command{
+	var fileSystem string
+	var unexported bool
+	var grossSize bool
+
+	cmd := &cobra.Command{
 		Use:   "add-volume IQN LU_NR LU_SIZE",
 		Short: "Add a new logical unit to an existing iSCSI target",
 		Long:  "Add a new logical unit to an existing iSCSI target. The target needs to be stopped.",
@@ -289,12 +855,24 @@ func addVolumeISCSICommand() *cobra.Command {
 				return err
 			}
 
-			size, err := unit.MustNewUnit(unit.DefaultUnits).ValueFromString(args[2])
+			sizeKiB, err := parseVolumeSize(args[2])
 			if err != nil {
 				return err
 			}
 
-			_, err = cli.Iscsi.AddLogicalUnit(context.Background(), iqn, &common.VolumeConfig{Number: volNr, SizeKiB: uint64(size.Value / unit.K)})
+			volume := &common.VolumeConfig{Number: volNr, SizeKiB: sizeKiB, FileSystem: fileSystem}
+			if unexported {
+				exported := false
+				volume.Exported = &exported
+			}
+			if cmd.Flags().Changed("gross") {
+				volume.GrossSize = &grossSize
+			}
+
+			_, err = cli.Iscsi.AddLogicalUnit(cmd.Context(), iqn, volume)
+			if err == client.NotFoundError {
+				return noTarget(iqn.String())
+			}
 			if err != nil {
 				return err
 			}
@@ -303,13 +881,368 @@ func addVolumeISCSICommand() *cobra.Command {
 			return nil
 		},
 	}
+
+	cmd.Flags().StringVar(&fileSystem, "filesystem", "", fmt.Sprintf("Create a file system on the logical unit (one of %s). By default, the logical unit is a raw block device", strings.Join(iscsi.SupportedFileSystems, ", ")))
+	cmd.Flags().BoolVar(&unexported, "unexported", false, "Create the logical unit, but do not map it into the target until export-volume is used")
+	cmd.Flags().BoolVar(&grossSize, "gross", false, "Specify the size as gross size, i.e. the actual space used on disk. Has to match the size semantics the target was created with. By default, the size semantics of the target are used")
+
+	return cmd
+}
+
+func setCHAPISCSICommand() *cobra.Command {
+	var username string
+	var password passwordFlags
+	var disable bool
+
+	cmd := &cobra.Command{
+		Use:   "set-chap IQN",
+		Short: "Changes the CHAP credentials of an iSCSI target",
+		Long: `Changes the CHAP credentials of an existing iSCSI target, or disables CHAP
+authentication with --disable.
+
+The new credentials only take effect after the target is restarted. Until
+then, a running target keeps accepting the old credentials.`,
+		Example: `linstor-gateway iscsi set-chap iqn.2019-08.com.linbit:example -u user --password-file /root/chap-password
+linstor-gateway iscsi set-chap iqn.2019-08.com.linbit:example --disable`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			iqn, err := iscsi.NewIqn(args[0])
+			if err != nil {
+				return err
+			}
+
+			chapPassword, err := password.read()
+			if err != nil {
+				return err
+			}
+
+			if disable && (username != "" || chapPassword != "") {
+				return fmt.Errorf("--disable can not be combined with --username or --password")
+			}
+			if !disable && (username == "" || chapPassword == "") {
+				return fmt.Errorf("both --username and --password are required, or use --disable to turn off CHAP authentication")
+			}
+
+			cfg, err := cli.Iscsi.SetCHAP(cmd.Context(), iqn, username, chapPassword)
+			if err == client.NotFoundError {
+				return noTarget(iqn.String())
+			}
+			if err != nil {
+				return err
+			}
+
+			if disable {
+				fmt.Printf("Disabled CHAP authentication for \"%s\"\n", iqn)
+			} else {
+				fmt.Printf("Changed CHAP credentials of \"%s\"\n", iqn)
+			}
+
+			if cfg.Status.Service.Active() {
+				log.Warnf("The target is running. Restart it with \"linstor-gateway iscsi stop %[1]s\" and \"linstor-gateway iscsi start %[1]s\" for the change to take effect; connected initiators have to log in again", iqn)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&username, "username", "u", "", "Set the username to use for CHAP authentication")
+	addPasswordFlags(cmd, &password)
+	cmd.Flags().BoolVar(&disable, "disable", false, "Disable CHAP authentication")
+
+	return cmd
+}
+
+func setDescriptionISCSICommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "set-description IQN DESCRIPTION",
+		Short: "Changes the description of an iSCSI target",
+		Long: `Changes the free-text description of an existing iSCSI target. An empty
+description removes it.
+
+The description is only metadata, so the target does not need to be
+restarted.`,
+		Example: `linstor-gateway iscsi set-description iqn.2019-08.com.linbit:example "Backups for team foo, see TICKET-123"
+linstor-gateway iscsi set-description iqn.2019-08.com.linbit:example ""`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			iqn, err := iscsi.NewIqn(args[0])
+			if err != nil {
+				return err
+			}
+
+			_, err = cli.Iscsi.SetDescription(cmd.Context(), iqn, args[1])
+			if err == client.NotFoundError {
+				return noTarget(iqn.String())
+			}
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("Changed description of \"%s\"\n", iqn)
+			return nil
+		},
+		ValidArgsFunction: completeISCSITargets(1),
+	}
+}
+
+func repairISCSICommand() *cobra.Command {
+	var dryRun bool
+
+	cmd := &cobra.Command{
+		Use:   "repair IQN",
+		Short: "Regenerates the drbd-reactor configuration of an iSCSI target",
+		Long: `Regenerates the drbd-reactor configuration of an iSCSI target from its
+LINSTOR resource and registers it again. This undoes manual changes to the
+configuration and brings it in line with the current version of LINSTOR
+Gateway. The differences to the previous configuration are shown.
+
+With --dry-run, only the differences are shown and nothing is changed.`,
+		Example: `linstor-gateway iscsi repair iqn.2019-08.com.linbit:example --dry-run`,
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			iqn, err := iscsi.NewIqn(args[0])
+			if err != nil {
+				return err
+			}
+
+			result, err := cli.Iscsi.Repair(cmd.Context(), iqn, dryRun)
+			if err == client.NotFoundError {
+				return noTarget(iqn.String())
+			}
+			if err != nil {
+				return err
+			}
+
+			printRepairResult(iqn.String(), result)
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Only show the changes, do not apply them")
+
+	return cmd
+}
+
+func repairPrivateVolumeISCSICommand() *cobra.Command {
+	var yes bool
+
+	cmd := &cobra.Command{
+		Use:   "repair-private-volume IQN",
+		Short: "Replaces a corrupted cluster private volume of an iSCSI target",
+		Long: `Replaces the cluster private volume (volume 0) of an iSCSI target with a new,
+empty one, e.g. if its file system is corrupted and the target fails to start.
+The volume is deleted and created again, which also creates a new file system
+on it. The logical units and their data are not touched. The drbd-reactor
+configuration is updated for the new volume.
+
+This is an expert recovery tool. The target has to be stopped, and the state
+drbd-reactor and the resource agents keep on the cluster private volume is
+lost. Only use it if the cluster private volume is damaged on all nodes:
+otherwise, let DRBD resync it from a node where it is intact.`,
+		Example: `linstor-gateway iscsi stop iqn.2019-08.com.linbit:example
+linstor-gateway iscsi repair-private-volume iqn.2019-08.com.linbit:example
+linstor-gateway iscsi start iqn.2019-08.com.linbit:example`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			iqn, err := iscsi.NewIqn(args[0])
+			if err != nil {
+				return err
+			}
+
+			err = confirmDestructive(yes, fmt.Sprintf("The cluster private volume of \"%s\" will be deleted and created again. Its contents will be lost.", iqn))
+			if err != nil {
+				return err
+			}
+
+			_, err = cli.Iscsi.RecreatePrivateVolume(cmd.Context(), iqn)
+			if err == client.NotFoundError {
+				return noTarget(iqn.String())
+			}
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("Recreated the cluster private volume of \"%s\"\n", iqn)
+			return nil
+		},
+	}
+
+	addYesFlag(cmd, &yes, "replacing the cluster private volume")
+
+	return cmd
+}
+
+func showConfigISCSICommand() *cobra.Command {
+	var deployed bool
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "show-config IQN",
+		Short: "Shows the drbd-reactor configuration of an iSCSI target",
+		Long: `Shows the drbd-reactor configuration of an iSCSI target as LINSTOR Gateway
+would generate it from the LINSTOR resource, without changing anything.
+
+With --deployed, the configuration that is currently registered in LINSTOR is
+shown instead. Comparing both shows what "iscsi repair" would change. The CHAP
+password is redacted.`,
+		Example: `linstor-gateway iscsi show-config iqn.2019-08.com.linbit:example
+linstor-gateway iscsi show-config iqn.2019-08.com.linbit:example --deployed`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			err := checkOutputFormat(output)
+			if err != nil {
+				return err
+			}
+
+			iqn, err := iscsi.NewIqn(args[0])
+			if err != nil {
+				return err
+			}
+
+			result, err := cli.Iscsi.ShowConfig(cmd.Context(), iqn, deployed)
+			if err == client.NotFoundError {
+				return noTarget(iqn.String())
+			}
+			if err != nil {
+				return err
+			}
+
+			if output == outputJSON {
+				return printJSON(result)
+			}
+
+			fmt.Printf("# %s\n", result.Path)
+			fmt.Print(result.Config)
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&deployed, "deployed", false, "Show the configuration registered in LINSTOR instead of regenerating it")
+	addOutputFlag(cmd, &output)
+
+	return cmd
+}
+
+func failoverISCSICommand() *cobra.Command {
+	return &cobra.Command{
+		Use:     "failover IQN",
+		Aliases: []string{"move"},
+		Short:   "Moves a running iSCSI target to a different node",
+		Long: `Moves a running iSCSI target to a different node. The target is stopped on
+the node it is currently running on and started on one of the other nodes
+with a diskful replica. The command waits until the target is running on the
+new node.
+
+This can be used to test the high availability setup, or to drain a node
+before maintenance. Initiators are disconnected while the target moves.`,
+		Example: `linstor-gateway iscsi failover iqn.2019-08.com.linbit:example`,
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			iqn, err := iscsi.NewIqn(args[0])
+			if err != nil {
+				return err
+			}
+
+			cfg, err := cli.Iscsi.Failover(cmd.Context(), iqn)
+			if err == client.NotFoundError {
+				return noTarget(iqn.String())
+			}
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("Target \"%s\" is now running on %s\n", iqn, cfg.Status.Primary)
+			return nil
+		},
+	}
 }
 
 func deleteVolumeISCSICommand() *cobra.Command {
+	var yes bool
+
+	cmd := &cobra.Command{
+		Use:   "delete-volume IQN LU_NR...",
+		Short: "Delete logical units of an existing iSCSI target",
+		Long: `Delete one or more logical units of an existing iSCSI target. The target
+needs to be stopped. The configuration of the target is updated once for all
+given logical units.
+
+The remaining volumes keep their numbers, so deleting a volume from the middle
+leaves a gap in the LU numbers. LINSTOR does not allow changing the number of a
+volume definition, so the numbers cannot be compacted in place. To get
+contiguous numbers, the target has to be recreated.`,
+		Example: "linstor-gateway iscsi delete-volume iqn.2019-08.com.linbit:example 3 5 7",
+		Args:    cobra.MinimumNArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			iqn, err := iscsi.NewIqn(args[0])
+			if err != nil {
+				return err
+			}
+
+			volNrs := make([]int, 0, len(args)-1)
+			for _, arg := range args[1:] {
+				volNr, err := strconv.Atoi(arg)
+				if err != nil {
+					return err
+				}
+				volNrs = append(volNrs, volNr)
+			}
+
+			err = confirmDestructive(yes, fmt.Sprintf("%s of \"%s\" will be deleted, including all data.", numbered("Logical unit", volNrs), iqn))
+			if err != nil {
+				return err
+			}
+
+			deleted, missing, err := cli.Iscsi.DeleteLogicalUnits(cmd.Context(), iqn, volNrs)
+			if err == client.NotFoundError {
+				return noTarget(iqn.String())
+			}
+			if err != nil {
+				return err
+			}
+
+			if len(deleted) > 0 {
+				fmt.Printf("Deleted %s of \"%s\"\n", numbered("volume", deleted), iqn)
+			}
+			if len(missing) > 0 {
+				fmt.Printf("Skipped %s of \"%s\", which did not exist\n", numbered("volume", missing), iqn)
+			}
+			return nil
+		},
+	}
+
+	addYesFlag(cmd, &yes, "deleting the logical units")
+
+	return cmd
+}
+
+func exportVolumeISCSICommand() *cobra.Command {
+	return setExportedISCSICommand(true)
+}
+
+func unexportVolumeISCSICommand() *cobra.Command {
+	return setExportedISCSICommand(false)
+}
+
+func setExportedISCSICommand(exported bool) *cobra.Command {
+	use := "export-volume"
+	short := "Map a logical unit into an existing iSCSI target"
+	long := `Map a logical unit that was created with --unexported-luns or --unexported,
+or removed by unexport-volume, into an existing iSCSI target. The target needs
+to be stopped, as a running target does not pick up new logical units.`
+	done := "Exported"
+	if !exported {
+		use = "unexport-volume"
+		short = "Remove a logical unit from an existing iSCSI target, keeping its data"
+		long = `Remove a logical unit from an existing iSCSI target, so that initiators can no
+longer access it. Its data is kept, and it can be mapped into the target again
+with export-volume. The target needs to be stopped.`
+		done = "Unexported"
+	}
+
 	return &cobra.Command{
-		Use:   "delete-volume IQN LU_NR",
-		Short: "Delete a logical unit of an existing iSCSI target",
-		Long:  "Delete a logical unit of an existing iSCSI target. The target needs to be stopped.",
+		Use:   use + " IQN LU_NR",
+		Short: short,
+		Long:  long,
 		Args:  cobra.ExactArgs(2),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			iqn, err := iscsi.NewIqn(args[0])
@@ -322,13 +1255,41 @@ func deleteVolumeISCSICommand() *cobra.Command {
 				return err
 			}
 
-			err = cli.Iscsi.DeleteLogicalUnit(context.Background(), iqn, volNr)
+			_, err = cli.Iscsi.SetLogicalUnitExported(cmd.Context(), iqn, volNr, exported)
+			if err == client.NotFoundError {
+				return fmt.Errorf("no logical unit %d found for target \"%s\"", volNr, iqn)
+			}
 			if err != nil {
 				return err
 			}
 
-			fmt.Printf("Deleted volume %d of \"%s\"\n", volNr, iqn)
+			fmt.Printf("%s volume %d of \"%s\"\n", done, volNr, iqn)
 			return nil
 		},
 	}
 }
+
+func validateISCSICommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "validate IQN...",
+		Short: "Checks whether an IQN is valid",
+		Long: `Checks whether the given IQNs are valid and can be used to create an iSCSI
+target. This does not contact the LINSTOR Gateway server.`,
+		Example: "linstor-gateway iscsi validate iqn.2019-08.com.linbit:example",
+		Args:    cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var allErrs multiError
+			for _, rawiqn := range args {
+				iqn, err := iscsi.NewIqn(rawiqn)
+				if err != nil {
+					allErrs = append(allErrs, err)
+					continue
+				}
+
+				fmt.Printf("IQN \"%s\" is valid, the LINSTOR resource will be named \"%s\"\n", iqn, iqn.WWN())
+			}
+
+			return allErrs.Err()
+		},
+	}
+}
@@ -0,0 +1,23 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/LINBIT/linstor-gateway/pkg/common"
+)
+
+// addKeepResourceFlag adds the flag to keep the LINSTOR resource to a delete
+// command. kind is the name of the resource in plural, e.g. "targets".
+func addKeepResourceFlag(cmd *cobra.Command, kind string, opts *common.DeleteOptions) {
+	cmd.Flags().BoolVar(&opts.KeepResource, "keep-resource", false, fmt.Sprintf("Only remove the drbd-reactor configuration of the %s and keep their LINSTOR resources and data, e.g. to manage them manually", kind))
+}
+
+// hintKeptResource tells the user that the LINSTOR resource of a deleted
+// target or export was kept.
+func hintKeptResource(opts common.DeleteOptions) {
+	if opts.KeepResource {
+		fmt.Println("Its LINSTOR resource and data were kept")
+	}
+}
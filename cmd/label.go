@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/LINBIT/linstor-gateway/pkg/common"
+)
+
+// addLabelFlag adds the flag to set labels on a resource to a create command.
+func addLabelFlag(cmd *cobra.Command, labels *[]string) {
+	cmd.Flags().StringArrayVar(labels, "label", nil, "Set a label in the form key=value, e.g. team=foo. Can be given more than once")
+}
+
+// parseLabels parses the values of the flag added by addLabelFlag.
+func parseLabels(labels []string) (map[string]string, error) {
+	if len(labels) == 0 {
+		return nil, nil
+	}
+
+	result := make(map[string]string, len(labels))
+	for _, label := range labels {
+		key, value, err := common.ParseLabel(label)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --label: %w", err)
+		}
+
+		if _, ok := result[key]; ok {
+			return nil, fmt.Errorf("invalid --label: label %s is set more than once", key)
+		}
+		result[key] = value
+	}
+
+	return result, nil
+}
+
+// addSelectorFlag adds the flag to only list resources with certain labels to
+// a list command.
+func addSelectorFlag(cmd *cobra.Command, selector *string) {
+	cmd.Flags().StringVar(selector, "selector", "", "Only list resources with all of these labels, e.g. team=foo,env=prod")
+}
+
+// parseSelector parses the value of the flag added by addSelectorFlag.
+func parseSelector(selector string) (map[string]string, error) {
+	labels, err := common.ParseSelector(selector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --selector: %w", err)
+	}
+
+	return labels, nil
+}
+
+// formatLabels describes the labels of a resource, e.g. "env=prod,team=foo".
+func formatLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return "none"
+	}
+
+	return common.FormatLabels(labels)
+}
@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/LINBIT/linstor-gateway/pkg/common"
+)
+
+// addLayerListFlag adds the flag to override the layer list of the resource
+// group to a create command.
+func addLayerListFlag(cmd *cobra.Command, layerList *string) {
+	layers := make([]string, len(common.KnownLayers))
+	for i, layer := range common.KnownLayers {
+		layers[i] = string(layer)
+	}
+
+	cmd.Flags().StringVar(layerList, "layer-list", "", fmt.Sprintf("Comma separated list of LINSTOR layers, overriding the layers of the resource group (e.g. DRBD,STORAGE; known layers: %s). Without DRBD, the target is neither replicated nor highly available", strings.Join(layers, ", ")))
+}
+
+// parseLayerList parses the value of the flag added by addLayerListFlag and
+// warns if the resulting target would not be highly available.
+func parseLayerList(layerList string) ([]string, error) {
+	layers, err := common.ParseLayerList(layerList)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --layer-list: %w", err)
+	}
+
+	if common.WithoutDRBD(layers) {
+		log.Warnf("The layer list %s does not contain DRBD. drbd-reactor relies on DRBD to start the target on exactly one node, so the target will not be replicated and can not fail over to another node", strings.Join(layers, ","))
+	}
+
+	return layers, nil
+}
+
+// formatLayerList describes the layer list of a target, e.g. "DRBD,STORAGE".
+func formatLayerList(layers []string) string {
+	if len(layers) == 0 {
+		return "from resource group"
+	}
+
+	return strings.Join(layers, ",")
+}
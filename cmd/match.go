@@ -0,0 +1,34 @@
+package cmd
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// matchNames returns the names that match pattern. A pattern that contains
+// any of the glob characters "*", "?" or "[" is matched as a glob, as in
+// path.Match; any other pattern matches all names that start with it.
+func matchNames(pattern string, names []string) ([]string, error) {
+	glob := strings.ContainsAny(pattern, "*?[")
+	if glob {
+		_, err := path.Match(pattern, "")
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern '%s': %w", pattern, err)
+		}
+	}
+
+	var matches []string
+	for _, name := range names {
+		if glob {
+			// The pattern was checked above, so Match can not fail.
+			if ok, _ := path.Match(pattern, name); ok {
+				matches = append(matches, name)
+			}
+		} else if strings.HasPrefix(name, pattern) {
+			matches = append(matches, name)
+		}
+	}
+
+	return matches, nil
+}
@@ -2,15 +2,17 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	log "github.com/sirupsen/logrus"
 	"net"
-	"os"
+	"strconv"
+	"strings"
 
+	"github.com/LINBIT/linstor-gateway/client"
 	"github.com/LINBIT/linstor-gateway/pkg/common"
 	"github.com/LINBIT/linstor-gateway/pkg/nfs"
 	"github.com/olekukonko/tablewriter"
-	"github.com/rck/unit"
 	"github.com/spf13/cobra"
 )
 
@@ -25,21 +27,43 @@ as well as Corosync and Pacemaker's properties a prerequisite to use this tool.`
 		Args: cobra.NoArgs,
 	}
 
-	rootCmd.DisableAutoGenTag = true
-
-	rootCmd.AddCommand(createNFSCommand())
-	rootCmd.AddCommand(deleteNFSCommand())
-	rootCmd.AddCommand(listNFSCommand())
-
-	return rootCmd
-
+	return protocolCommand(rootCmd,
+		createNFSCommand(),
+		deleteNFSCommand(),
+		listNFSCommand(),
+		getNFSCommand(),
+		startNFSCommand(),
+		stopNFSCommand(),
+		renameNFSCommand(),
+		setDescriptionNFSCommand(),
+		addVolumeNFSCommand(),
+		deleteVolumeNFSCommand(),
+	)
 }
 
 func createNFSCommand() *cobra.Command {
 	resourceGroup := "DfltRscGrp"
 	allowedIPsCIDR := common.ServiceIPFromParts(net.IPv4zero, 0)
 	exportPath := "/"
+	subdirectory := ""
 	grossSize := false
+	keepOnFailure := false
+	skipCapacityCheck := false
+	skipCollisionCheck := false
+	noStart := false
+	var waitSync waitSyncFlags
+	overwrite := false
+	yes := false
+	replicaCount := 0
+	layerList := ""
+	storagePool := ""
+	drbdPort, drbdMinor := 0, 0
+	fsid := ""
+	mountOptions := ""
+	var labels []string
+	var description string
+	var drbdOptions []string
+	var clients []string
 
 	cmd := &cobra.Command{
 		Use:   "create NAME SERVICE_IP SIZE",
@@ -48,21 +72,69 @@ func createNFSCommand() *cobra.Command {
 At first it creates a new resource within the LINSTOR system under the
 specified name and using the specified resource group.
 After that it creates a drbd-reactor configuration to bring up a highly available NFS 
-export.`,
+export.
+
+SERVICE_IP may be a comma separated list of IP addresses, which are started
+and stopped together. The first one is the primary service IP.
+
+Only one NFS server can run per node, so only one export can be created.
+--skip-collision-check creates further exports anyway. This is only safe if
+every export is pinned to its own set of nodes, e.g. with resource groups
+whose storage pools are on different nodes, so that two exports never run on
+the same node, not even after a failover.`,
 		Example: `linstor-gateway nfs create example 192.168.211.122/24 2G
+linstor-gateway nfs create multinet 192.168.211.124/24,10.10.22.45/16 2G
 linstor-gateway nfs create restricted 10.10.22.44/16 2G --allowed-ips 10.10.0.0/16
+linstor-gateway nfs create shared 10.10.22.46/16 2G --client 10.10.0.0/16=rw,root_squash --client 10.20.0.0/16=ro,all_squash
+linstor-gateway nfs create projecta 192.168.211.123/24 2G --subdirectory /projectA
 `,
 		Args: cobra.ExactArgs(3),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			ctx := context.Background()
+			ctx := cmd.Context()
 
-			resource := args[0]
-			serviceIP, err := common.ServiceIPFromString(args[1])
+			err := checkReplicaCount(cmd, replicaCount)
 			if err != nil {
 				return err
 			}
 
-			size, err := unit.MustNewUnit(unit.DefaultUnits).ValueFromString(args[2])
+			layers, err := parseLayerList(layerList)
+			if err != nil {
+				return err
+			}
+
+			parsedLabels, err := parseLabels(labels)
+			if err != nil {
+				return err
+			}
+
+			parsedDrbdOptions, err := parseDrbdOptions(drbdOptions)
+			if err != nil {
+				return err
+			}
+
+			exportClients, err := parseExportClients(clients)
+			if err != nil {
+				return err
+			}
+
+			// Without --allowed-ips, only the given clients may access
+			// the export.
+			allowedIPs := []common.IpCidr{allowedIPsCIDR}
+			if len(exportClients) > 0 && !cmd.Flags().Changed("allowed-ips") {
+				allowedIPs = nil
+			}
+
+			resource := args[0]
+			var serviceIPs []common.IpCidr
+			for _, ipString := range strings.Split(args[1], ",") {
+				ip, err := common.ServiceIPFromString(ipString)
+				if err != nil {
+					return fmt.Errorf("invalid service IP '%s': %w", ipString, err)
+				}
+				serviceIPs = append(serviceIPs, ip)
+			}
+
+			sizeKiB, err := parseVolumeSize(args[2])
 			if err != nil {
 				return err
 			}
@@ -70,79 +142,612 @@ linstor-gateway nfs create restricted 10.10.22.44/16 2G --allowed-ips 10.10.0.0/
 			rsc := &nfs.ResourceConfig{
 				Name:          resource,
 				ResourceGroup: resourceGroup,
-				ServiceIP:     serviceIP,
-				AllowedIPs:    []common.IpCidr{allowedIPsCIDR},
+				ServiceIP:     serviceIPs[0],
+				ServiceIPs:    serviceIPs,
+				AllowedIPs:    allowedIPs,
+				Clients:       exportClients,
 				Volumes: []nfs.VolumeConfig{{
-					ExportPath: exportPath,
+					ExportPath:   exportPath,
+					Path:         subdirectory,
+					FSID:         fsid,
+					MountOptions: mountOptions,
 					VolumeConfig: common.VolumeConfig{
 						Number:              1,
-						SizeKiB:             uint64(size.Value / unit.K),
+						SizeKiB:             sizeKiB,
 						FileSystem:          "ext4",
 						FileSystemRootOwner: common.UidGid{Uid: 65534, Gid: 65534}, // corresponds to "nobody:nobody"
 					},
 				}},
-				GrossSize: grossSize,
+				GrossSize:      grossSize,
+				PlacementCount: replicaCount,
+				LayerList:      layers,
+				StoragePool:    storagePool,
+				DrbdPort:       drbdPort,
+				DrbdMinor:      drbdMinor,
+				Labels:         parsedLabels,
+				Description:    description,
+				DrbdOptions:    parsedDrbdOptions,
+			}
+			if overwrite && !yes {
+				existing, err := cli.Nfs.Get(ctx, resource)
+				if err != nil && err != client.NotFoundError {
+					return err
+				}
+
+				if err == nil {
+					requested := *rsc
+					requested.FillDefaults()
+					// a renamed export keeps the LINSTOR resource it was created with
+					requested.ResourceName = existing.ResourceName
+					requested.Volumes = append([]nfs.VolumeConfig{{VolumeConfig: common.ClusterPrivateVolume()}}, requested.Volumes...)
+					err = confirmOverwrite("export", existing, &requested)
+					if err != nil {
+						return err
+					}
+				}
 			}
-			_, err = cli.Nfs.Create(ctx, rsc)
+
+			if skipCollisionCheck {
+				log.Warnf("Skipping the check for other NFS exports. Only one NFS server can run per node: make sure that \"%s\" never runs on the same node as another export", resource)
+			}
+
+			created, err := cli.Nfs.Create(ctx, rsc, common.CreateOptions{KeepOnFailure: keepOnFailure, Overwrite: overwrite, SkipCapacityCheck: skipCapacityCheck, NoStart: noStart, SkipCollisionCheck: skipCollisionCheck, WaitSync: waitSync.wait, SyncTimeout: waitSync.timeout})
 			if err != nil {
 				return err
 			}
 
-			fmt.Printf("Created export '%s' at %s:%s\n", resource, serviceIP.IP().String(), nfs.ExportPath(rsc, &rsc.Volumes[0]))
+			fmt.Printf("Created export '%s' at %s:%s\n", resource, serviceIPs[0].IP().String(), nfs.ExportPath(rsc, &rsc.Volumes[0]))
+			warnReplicas(resource, created.Status)
+			if noStart {
+				hintStart("nfs", resource)
+			}
 			return nil
 		},
 	}
 
 	cmd.Flags().StringVarP(&resourceGroup, "resource-group", "r", resourceGroup, "LINSTOR resource group to use")
 	cmd.Flags().StringVarP(&exportPath, "export-path", "p", exportPath, fmt.Sprintf("Set the export path, relative to %s", nfs.ExportBasePath))
-	cmd.Flags().VarP(&allowedIPsCIDR, "allowed-ips", "", "Set the IP address mask of clients that are allowed access")
-	cmd.Flags().BoolVar(&grossSize, "gross", false, "Make all size options specify gross size, i.e. the actual space used on disk")
+	cmd.Flags().StringVar(&subdirectory, "subdirectory", subdirectory, "Export only this directory inside the volume instead of the whole file system. The directory is created if it does not exist")
+	cmd.Flags().VarP(&allowedIPsCIDR, "allowed-ips", "", fmt.Sprintf("Set the IP address mask of clients that are allowed access with the options %s", nfs.DefaultExportOptions))
+	cmd.Flags().StringArrayVar(&clients, "client", nil, "Allow access for the clients in an IP address mask with their own export options, e.g. 10.0.0.0/8=ro,root_squash. Can be given more than once. Unless --allowed-ips is also given, only these clients are allowed access")
+	cmd.Flags().BoolVar(&grossSize, "gross", false, "Make all size options specify gross size, i.e. the actual space used on disk. This also applies to the internal cluster private volume")
+	cmd.Flags().BoolVar(&keepOnFailure, "keep-on-failure", false, "Do not clean up the LINSTOR resource if creating the export fails, for debugging")
+	addSkipCapacityCheckFlag(cmd, &skipCapacityCheck)
+	addNoStartFlag(cmd, "export", &noStart)
+	addWaitSyncFlags(cmd, "export", &waitSync)
+	cmd.Flags().BoolVar(&skipCollisionCheck, "skip-collision-check", false, "Advanced: create the export even if another NFS export exists. Only safe if the exports are pinned to different nodes, as only one NFS server can run per node")
+	addReplicaCountFlag(cmd, &replicaCount)
+	addLayerListFlag(cmd, &layerList)
+	addStoragePoolFlag(cmd, &storagePool)
+	addDrbdFlags(cmd, &drbdPort, &drbdMinor)
+	addLabelFlag(cmd, &labels)
+	addDescriptionFlag(cmd, &description)
+	addDrbdOptionFlag(cmd, &drbdOptions)
+	addFSIDFlag(cmd, &fsid)
+	addMountOptionsFlag(cmd, &mountOptions)
+	addOverwriteFlags(cmd, "export", &overwrite, &yes)
 
 	return cmd
 }
 
 func deleteNFSCommand() *cobra.Command {
-	return &cobra.Command{
+	yes := false
+	var opts common.DeleteOptions
+
+	cmd := &cobra.Command{
 		Use:   "delete NAME",
 		Short: "Deletes an NFS export",
 		Long: `Deletes an NFS export by stopping and deleting the drbd-reactor config
-and removing the LINSTOR resources.`,
+and removing the LINSTOR resources.
+
+With --keep-resource, only the drbd-reactor configuration is removed. The
+LINSTOR resource and its data are kept, so that it can be managed manually.
+
+The deletion has to be confirmed, unless --yes is given.`,
 		Example: "linstor-gateway nfs delete example",
 		Args:    cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			ctx := context.Background()
+			ctx := cmd.Context()
 			resourceName := args[0]
-			err := cli.Nfs.Delete(ctx, resourceName)
+			err := confirmDelete(yes, "exports", []string{resourceName}, opts)
+			if err != nil {
+				return err
+			}
+
+			err = cli.Nfs.Delete(ctx, resourceName, opts)
 			if err != nil {
 				return err
 			}
 
 			fmt.Printf("Deleted export %s\n", resourceName)
+			hintKeptResource(opts)
 			return nil
 		},
+		ValidArgsFunction: completeNFSExports(1),
+	}
+
+	addKeepResourceFlag(cmd, "exports", &opts)
+	addYesFlag(cmd, &yes, "deleting the export")
+
+	return cmd
+}
+
+func startNFSCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:     "start NAME...",
+		Short:   "Starts an NFS export",
+		Long:    `Makes an NFS export available by starting it.`,
+		Example: "linstor-gateway nfs start example",
+		Args:    cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var allErrs multiError
+			for _, name := range args {
+				_, err := cli.Nfs.Start(cmd.Context(), name)
+				if err == client.NotFoundError {
+					allErrs = append(allErrs, noExport(name))
+					continue
+				}
+				if err != nil {
+					allErrs = append(allErrs, err)
+					continue
+				}
+
+				fmt.Printf("Started export \"%s\"\n", name)
+			}
+
+			return allErrs.Err()
+		},
+		ValidArgsFunction: completeNFSExports(0),
 	}
 }
 
-func listNFSCommand() *cobra.Command {
+func stopNFSCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:     "stop NAME...",
+		Short:   "Stops an NFS export",
+		Long:    `Disables an NFS export, making it unavailable to clients while not deleting it.`,
+		Example: "linstor-gateway nfs stop example",
+		Args:    cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var allErrs multiError
+			for _, name := range args {
+				_, err := cli.Nfs.Stop(cmd.Context(), name)
+				if err == client.NotFoundError {
+					allErrs = append(allErrs, noExport(name))
+					continue
+				}
+				if err != nil {
+					allErrs = append(allErrs, err)
+					continue
+				}
+
+				fmt.Printf("Stopped export \"%s\"\n", name)
+			}
+
+			return allErrs.Err()
+		},
+		ValidArgsFunction: completeNFSExports(0),
+	}
+}
+
+func renameNFSCommand() *cobra.Command {
 	return &cobra.Command{
+		Use:   "rename OLD_NAME NEW_NAME",
+		Short: "Renames an NFS export",
+		Long: `Renames an NFS export. The export needs to be stopped.
+
+LINSTOR does not support renaming resources, so the renamed export is still
+backed by the LINSTOR resource with the old name. The export path changes
+to reflect the new name. As the LINSTOR resource keeps the old name, no new
+export with the old name can be created while the renamed export exists.`,
+		Example: "linstor-gateway nfs rename example new-example",
+		Args:    cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			_, err := cli.Nfs.Rename(cmd.Context(), args[0], args[1])
+			if err == client.NotFoundError {
+				return noExport(args[0])
+			}
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("Renamed export \"%s\" to \"%s\"\n", args[0], args[1])
+			return nil
+		},
+	}
+}
+
+func setDescriptionNFSCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "set-description NAME DESCRIPTION",
+		Short: "Changes the description of an NFS export",
+		Long: `Changes the free-text description of an existing NFS export. An empty
+description removes it.
+
+The description is only metadata, so the export does not need to be
+restarted.`,
+		Example: `linstor-gateway nfs set-description example "Home directories, see TICKET-123"
+linstor-gateway nfs set-description example ""`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			_, err := cli.Nfs.SetDescription(cmd.Context(), args[0], args[1])
+			if err == client.NotFoundError {
+				return noExport(args[0])
+			}
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("Changed description of \"%s\"\n", args[0])
+			return nil
+		},
+		ValidArgsFunction: completeNFSExports(1),
+	}
+}
+
+func addVolumeNFSCommand() *cobra.Command {
+	var exportPath string
+	var grossSize bool
+	subdirectory := ""
+	fsid := ""
+	mountOptions := ""
+
+	cmd := &cobra.Command{
+		Use:   "add-volume NAME LUN SIZE",
+		Short: "Add a new volume to an existing NFS export",
+		Long: `Add a new volume to an existing NFS export. The volume is exported at its own
+export path. The export needs to be stopped.
+
+LUN 0 is reserved for the cluster private volume, so numbering of exported
+volumes starts at 1.`,
+		Example: "linstor-gateway nfs add-volume example 2 1G --export-path /projectB",
+		Args:    cobra.ExactArgs(3),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			lun, err := strconv.Atoi(args[1])
+			if err != nil {
+				return err
+			}
+
+			sizeKiB, err := parseVolumeSize(args[2])
+			if err != nil {
+				return err
+			}
+
+			volume := &nfs.VolumeConfig{
+				ExportPath:   exportPath,
+				Path:         subdirectory,
+				FSID:         fsid,
+				MountOptions: mountOptions,
+				VolumeConfig: common.VolumeConfig{
+					Number:              lun,
+					SizeKiB:             sizeKiB,
+					FileSystem:          "ext4",
+					FileSystemRootOwner: common.UidGid{Uid: 65534, Gid: 65534}, // corresponds to "nobody:nobody"
+				},
+			}
+			if cmd.Flags().Changed("gross") {
+				volume.GrossSize = &grossSize
+			}
+
+			_, err = cli.Nfs.AddVolume(cmd.Context(), args[0], volume)
+			if err == client.NotFoundError {
+				return noExport(args[0])
+			}
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("Added volume %d to export \"%s\"\n", lun, args[0])
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&exportPath, "export-path", "p", "", fmt.Sprintf("Set the export path, relative to %s", nfs.ExportBasePath))
+	cmd.Flags().StringVar(&subdirectory, "subdirectory", subdirectory, "Export only this directory inside the volume instead of the whole file system. The directory is created if it does not exist")
+	addFSIDFlag(cmd, &fsid)
+	addMountOptionsFlag(cmd, &mountOptions)
+	cmd.Flags().BoolVar(&grossSize, "gross", false, "Specify the size as gross size, i.e. the actual space used on disk. Has to match the size semantics the export was created with. By default, the size semantics of the export are used")
+	_ = cmd.MarkFlagRequired("export-path")
+
+	return cmd
+}
+
+func deleteVolumeNFSCommand() *cobra.Command {
+	var yes bool
+
+	cmd := &cobra.Command{
+		Use:   "delete-volume NAME VOLUME_NR",
+		Short: "Delete a volume of an existing NFS export",
+		Long: `Delete a volume of an existing NFS export, including its export path.
+The export needs to be stopped.`,
+		Example: "linstor-gateway nfs delete-volume example 2",
+		Args:    cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			volNr, err := strconv.Atoi(args[1])
+			if err != nil {
+				return err
+			}
+
+			err = confirmDestructive(yes, fmt.Sprintf("Volume %d of \"%s\" and all its data will be deleted.", volNr, args[0]))
+			if err != nil {
+				return err
+			}
+
+			err = cli.Nfs.DeleteVolume(cmd.Context(), args[0], volNr)
+			if err == client.NotFoundError {
+				return noExport(args[0])
+			}
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("Deleted volume %d of \"%s\"\n", volNr, args[0])
+			return nil
+		},
+		ValidArgsFunction: completeNFSExports(1),
+	}
+
+	addYesFlag(cmd, &yes, "deleting the volume")
+
+	return cmd
+}
+
+func getNFSCommand() *cobra.Command {
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "get NAME",
+		Short: "Shows the details of an NFS export",
+		Long: `Shows the configuration and the current state of a single NFS export.
+
+If no export has the given name, the export backed by the LINSTOR resource of
+that name is shown. The two differ after an export was renamed.`,
+		Example: "linstor-gateway nfs get example",
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			err := checkWideOutputFormat(output)
+			if err != nil {
+				return err
+			}
+
+			cfg, err := cli.Nfs.Get(cmd.Context(), args[0])
+			if err == client.NotFoundError {
+				cfg, err = findNFSExportByResource(cmd.Context(), args[0])
+			}
+			if err != nil {
+				return err
+			}
+
+			if output == outputJSON {
+				return printJSON(cfg)
+			}
+
+			allowedIPs := make([]string, len(cfg.AllowedIPs))
+			for i := range cfg.AllowedIPs {
+				allowedIPs[i] = cfg.AllowedIPs[i].String()
+			}
+
+			serviceIPs := make([]string, len(cfg.ServiceIPs))
+			for i := range cfg.ServiceIPs {
+				serviceIPs[i] = cfg.ServiceIPs[i].String()
+			}
+
+			printDetails([][2]string{
+				{"Name", cfg.Name},
+				{"LINSTOR resource", cfg.ResourceName},
+				{"Service IPs", strings.Join(serviceIPs, ", ")},
+				{"Service state", cfg.Status.Service.String()},
+				{"Primary", cfg.Status.Primary},
+				{"Nodes", strings.Join(cfg.Status.Nodes, ", ")},
+				{"Resource group", cfg.ResourceGroup},
+				{"Placement", formatPlacement(cfg.Status.Placement)},
+				{"Replicas", formatReplicas(cfg.Status)},
+				{"Layers", formatLayerList(cfg.LayerList)},
+				{"DRBD port", formatDrbdNumber(cfg.Status.DrbdPort)},
+				{"DRBD protocol", formatDrbdProtocol(cfg.DrbdOptions)},
+				{"Quorum", formatQuorumPolicy(cfg.DrbdOptions)},
+				{"Labels", formatLabels(cfg.Labels)},
+				{"Description", formatDescription(cfg.Description)},
+				{"Gross size", strconv.FormatBool(cfg.GrossSize)},
+				{"Allowed IPs", strings.Join(allowedIPs, ", ")},
+				{"Clients", formatExportClients(cfg.Clients)},
+				{"Config file", cfg.Status.ConfigPath},
+			})
+			fmt.Println()
+
+			volumes := make([]common.VolumeConfig, len(cfg.Volumes))
+			for i := range cfg.Volumes {
+				volumes[i] = cfg.Volumes[i].VolumeConfig
+			}
+			renderVolumes("Volume", volumes, cfg.Status, []string{"NFS export", "FSID", "Mount options"}, func(i int) []string {
+				if cfg.Volumes[i].Number == 0 {
+					return []string{"", "", ""}
+				}
+				return []string{nfs.ExportPath(cfg, &cfg.Volumes[i]), nfs.ExportFSID(cfg, &cfg.Volumes[i]), formatMountOptions(cfg.Volumes[i].MountOptions)}
+			})
+			if output == outputWide {
+				fmt.Println()
+				renderNodeVolumes("Volume", volumes, cfg.Status)
+			}
+			warnInconsistent(cfg.Name, cfg.Status, recreateRemedy)
+			warnReplicas(cfg.Name, cfg.Status)
+
+			return nil
+		},
+		ValidArgsFunction: completeNFSExports(1),
+	}
+
+	addWideOutputFlag(cmd, &output)
+
+	return cmd
+}
+
+// parseExportClients parses the values of the --client flag, e.g.
+// "10.0.0.0/8=ro,root_squash".
+func parseExportClients(clients []string) ([]nfs.ExportClient, error) {
+	var result []nfs.ExportClient
+	for _, raw := range clients {
+		ips, options, found := strings.Cut(raw, "=")
+		if !found {
+			return nil, fmt.Errorf("invalid --client '%s', expected IP_MASK=OPTIONS", raw)
+		}
+
+		cidr, err := common.ServiceIPFromString(ips)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --client '%s': %w", raw, err)
+		}
+
+		result = append(result, nfs.ExportClient{IPs: cidr, Options: options})
+	}
+
+	return result, nil
+}
+
+// formatExportClients describes the clients of an export with their options,
+// e.g. "10.0.0.0/8 (ro,root_squash)".
+func formatExportClients(clients []nfs.ExportClient) string {
+	if len(clients) == 0 {
+		return "none"
+	}
+
+	parts := make([]string, len(clients))
+	for i := range clients {
+		parts[i] = fmt.Sprintf("%s (%s)", clients[i].IPs.String(), clients[i].Options)
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// findNFSExportByResource returns the export backed by the LINSTOR resource
+// rscName.
+func findNFSExportByResource(ctx context.Context, rscName string) (*nfs.ResourceConfig, error) {
+	cfgs, err := cli.Nfs.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, cfg := range cfgs {
+		if cfg.ResourceName == rscName {
+			return cfg, nil
+		}
+	}
+
+	return nil, noExport(rscName)
+}
+
+type noExport string
+
+func (n noExport) Error() string {
+	return fmt.Sprintf("export \"%s\" not found", string(n))
+}
+
+// nfsListColumns are the columns of the table printed by "nfs list".
+var nfsListColumns = []listColumn{
+	{name: "resource", header: "Resource", merge: true},
+	{name: "service_ip", header: "Service IP", merge: true},
+	{name: "state", header: "Service state"},
+	{name: "export", header: "NFS export"},
+	{name: "linstor_state", header: "LINSTOR state"},
+	{name: "sync", header: "Sync"},
+	{name: "provisioned", header: "Provisioned", verbose: true},
+	{name: "allocated", header: "Allocated", verbose: true},
+	{name: "config_file", header: "Config file", verbose: true, merge: true},
+	{name: "nodes", header: "Nodes", wide: true},
+}
+
+func listNFSCommand() *cobra.Command {
+	var verbose bool
+	var output, state, selector string
+	var columns, sortBy []string
+	var offset, limit int
+
+	cmd := &cobra.Command{
 		Use:   "list",
 		Short: "Lists NFS resources",
 		Long: `Lists the NFS resources created with this tool and provides an
 overview about the existing LINSTOR resources and service status.`,
-		Example: "linstor-gateway nfs list",
+		Example: "linstor-gateway nfs list\nlinstor-gateway nfs list --state degraded -o json\nlinstor-gateway nfs list --selector team=foo\nlinstor-gateway nfs list --columns resource,export,state\nlinstor-gateway nfs list --sort state,resource",
 		Args:    cobra.NoArgs,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			ctx := context.Background()
-			list, err := cli.Nfs.GetAll(ctx)
+			ctx := cmd.Context()
+			err := checkWideOutputFormat(output)
+			if err != nil {
+				return err
+			}
+
+			err = checkStateFilter(state)
+			if err != nil {
+				return err
+			}
+
+			selected, err := parseSelector(selector)
+			if err != nil {
+				return err
+			}
+
+			sortKeys, err := parseSortKeys(sortBy, "resource")
+			if err != nil {
+				return err
+			}
+
+			shown, err := selectColumns(nfsListColumns, columns, verbose, output == outputWide)
+			if err != nil {
+				return err
+			}
+			if output == outputJSON && len(columns) > 0 {
+				return errors.New("--columns only applies to the table output")
+			}
+
+			err = checkPage(offset, limit)
+			if err != nil {
+				return err
+			}
+
+			// filtering and sorting happen here, so the page can only be
+			// taken afterwards
+			local := state != stateFilterAll || len(selected) > 0 || len(sortKeys) > 0
+			pageOffset, pageLimit := serverPage(offset, limit, local)
+			list, err := cli.Nfs.GetPage(ctx, pageOffset, pageLimit)
 			if err != nil {
 				return err
 			}
 
-			table := tablewriter.NewWriter(os.Stdout)
-			table.SetHeader([]string{"Resource", "Service IP", "Service state", "NFS export", "LINSTOR state"})
-			table.SetHeaderColor(tableColorHeader, tableColorHeader, tableColorHeader, tableColorHeader, tableColorHeader)
+			filtered := make([]*nfs.ResourceConfig, 0, len(list))
+			for _, resource := range list {
+				if matchesStateFilter(state, &resource.Status) && common.MatchesSelector(resource.Labels, selected) {
+					filtered = append(filtered, resource)
+				}
+			}
+			list = filtered
+
+			if len(sortKeys) > 0 {
+				fields := make([]sortFields, len(list))
+				for i, resource := range list {
+					volumes := make([]common.VolumeConfig, len(resource.Volumes))
+					for j := range resource.Volumes {
+						volumes[j] = resource.Volumes[j].VolumeConfig
+					}
+					fields[i] = sortFields{id: resource.Name, status: &resource.Status, serviceIP: resource.ServiceIP.IP(), sizeKiB: totalSize(volumes)}
+				}
+
+				sorted := make([]*nfs.ResourceConfig, len(list))
+				for i, index := range sortOrder(fields, sortKeys) {
+					sorted[i] = list[index]
+				}
+				list = sorted
+			}
+
+			start, end := localPage(offset, limit, len(list), local)
+			list = list[start:end]
+			hintNextPage(offset, limit, len(list))
 
-			degradedResources := 0
+			if output == outputJSON {
+				return printJSON(list)
+			}
+
+			table := newListTable(nfsListColumns, shown)
+
+			var counts common.Counts
 			for _, resource := range list {
 				for i, vol := range resource.Volumes {
 					withStatus := resource.VolumeConfig(vol.Number)
@@ -157,35 +762,65 @@ overview about the existing LINSTOR resources and service status.`,
 
 					log.Debugf("listing volume: %+v", vol)
 
-					table.Rich([]string{
+					row := []string{
 						resource.Name,
 						resource.ServiceIP.String(),
 						resource.Status.Service.String(),
 						nfs.ExportPath(resource, &vol),
 						withStatus.Status.State.String(),
-					}, []tablewriter.Colors{
-						{},
-						{},
-						ServiceStateColor(resource.Status.Service),
-						{},
-						ResourceStateColor(withStatus.Status.State),
-					})
-					if withStatus.Status.State != common.ResourceStateOK {
-						degradedResources++
+						withStatus.Status.Sync,
+						common.FormatSize(vol.SizeKiB),
+						formatAllocated(withStatus.Status.AllocatedKiB),
+						resource.Status.ConfigPath,
+						formatNodeVolumes(withStatus.Status),
 					}
+					colors := make([]tablewriter.Colors, len(row))
+					colors[2] = ServiceStateColor(resource.Status.Service)
+					colors[4] = ResourceStateColor(withStatus.Status.State)
+					colors[5] = SyncStateColor(withStatus.Status)
+
+					table.add(row, colors)
+					counts.AddVolume(withStatus.Status)
 				}
 			}
 
-			table.SetAutoMergeCellsByColumnIndex([]int{0, 1})
-			table.SetAutoFormatHeaders(false)
+			table.render() // Send output
 
-			table.Render() // Send output
-
-			if degradedResources > 0 {
-				log.Warnf("Some resources are degraded. Run %s for possible solutions.", bold("linstor advise resource"))
+			warnVolumes(counts)
+			for _, resource := range list {
+				warnInconsistent(resource.Name, resource.Status, recreateRemedy)
+				warnReplicas(resource.Name, resource.Status)
 			}
 
 			return nil
 		},
 	}
+
+	addVerboseFlag(cmd, &verbose)
+	addColumnsFlag(cmd, nfsListColumns, &columns)
+	addSortFlag(cmd, "resource", &sortBy)
+	addWideOutputFlag(cmd, &output)
+	addStateFilterFlag(cmd, &state)
+	addSelectorFlag(cmd, &selector)
+	addPageFlags(cmd, &offset, &limit)
+
+	return cmd
+}
+
+// addMountOptionsFlag adds the flag to set the mount options of a volume.
+func addMountOptionsFlag(cmd *cobra.Command, options *string) {
+	cmd.Flags().StringVar(options, "mount-options", "", "Comma separated options to mount the file system of the volume with, e.g. noatime,nodiratime. By default, the defaults of the file system are used")
+}
+
+// formatMountOptions describes the options a file system is mounted with.
+func formatMountOptions(options string) string {
+	if options == "" {
+		return "default"
+	}
+	return options
+}
+
+// addFSIDFlag adds the flag to set the fsid of an exported volume.
+func addFSIDFlag(cmd *cobra.Command, fsid *string) {
+	cmd.Flags().StringVar(fsid, "fsid", "", "Set the fsid of the export, a positive 32 bit number or a UUID. It must be unique among all exports of the NFS server. By default, a UUID derived from the export name is used, which stays the same across failovers")
 }
@@ -0,0 +1,18 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+// addNoStartFlag adds the flag to create a resource without starting it to a
+// create command. kind is the name of the resource, e.g. "target".
+func addNoStartFlag(cmd *cobra.Command, kind string, noStart *bool) {
+	cmd.Flags().BoolVar(noStart, "no-start", false, fmt.Sprintf("Create the %s without starting it, e.g. to start it later during a maintenance window", kind))
+}
+
+// hintStart tells the user how to start a resource created with --no-start.
+func hintStart(protocol, name string) {
+	fmt.Printf("It is not started yet. Start it with \"linstor-gateway %s start %s\"\n", protocol, name)
+}
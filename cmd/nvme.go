@@ -2,15 +2,14 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/LINBIT/linstor-gateway/client"
 	log "github.com/sirupsen/logrus"
-	"os"
 	"strconv"
 	"strings"
 
 	"github.com/olekukonko/tablewriter"
-	"github.com/rck/unit"
 	"github.com/spf13/cobra"
 
 	"github.com/LINBIT/linstor-gateway/pkg/common"
@@ -26,74 +25,339 @@ func nvmeCommands() *cobra.Command {
 		Args:    cobra.NoArgs,
 	}
 
-	rootCmd.DisableAutoGenTag = true
-
-	rootCmd.AddCommand(listNVMECommand())
-	rootCmd.AddCommand(createNVMECommand())
-	rootCmd.AddCommand(deleteNVMECommand())
-	rootCmd.AddCommand(startNVMECommand())
-	rootCmd.AddCommand(stopNVMECommand())
-	rootCmd.AddCommand(addVolumeNVMECommand())
-	rootCmd.AddCommand(deleteVolumeNVMECommand())
+	return protocolCommand(rootCmd,
+		listNVMECommand(),
+		getNVMECommand(),
+		createNVMECommand(),
+		deleteNVMECommand(),
+		startNVMECommand(),
+		stopNVMECommand(),
+		addVolumeNVMECommand(),
+		deleteVolumeNVMECommand(),
+		addHostNVMECommand(),
+		removeHostNVMECommand(),
+		setDescriptionNVMECommand(),
+		validateNVMECommand(),
+	)
+}
 
-	return rootCmd
+// nvmeListColumns are the columns of the table printed by "nvme list".
+var nvmeListColumns = []listColumn{
+	{name: "nqn", header: "NQN", merge: true},
+	{name: "service_ip", header: "Service IP", merge: true},
+	{name: "state", header: "Service state"},
+	{name: "namespace", header: "Namespace"},
+	{name: "linstor_state", header: "LINSTOR state"},
+	{name: "sync", header: "Sync"},
+	{name: "provisioned", header: "Provisioned", verbose: true},
+	{name: "allocated", header: "Allocated", verbose: true},
+	{name: "config_file", header: "Config file", verbose: true, merge: true},
+	{name: "nodes", header: "Nodes", wide: true},
 }
 
 func listNVMECommand() *cobra.Command {
-	return &cobra.Command{
+	var verbose bool
+	var output, state, selector string
+	var columns, sortBy []string
+	var offset, limit int
+
+	cmd := &cobra.Command{
 		Use:   "list",
 		Short: "list configured NVMe-oF targets",
 		Args:  cobra.NoArgs,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			cfgs, err := cli.NvmeOf.GetAll(context.Background())
+			err := checkWideOutputFormat(output)
+			if err != nil {
+				return err
+			}
+
+			err = checkStateFilter(state)
+			if err != nil {
+				return err
+			}
+
+			selected, err := parseSelector(selector)
+			if err != nil {
+				return err
+			}
+
+			sortKeys, err := parseSortKeys(sortBy, "nqn")
+			if err != nil {
+				return err
+			}
+
+			shown, err := selectColumns(nvmeListColumns, columns, verbose, output == outputWide)
+			if err != nil {
+				return err
+			}
+			if output == outputJSON && len(columns) > 0 {
+				return errors.New("--columns only applies to the table output")
+			}
+
+			err = checkPage(offset, limit)
+			if err != nil {
+				return err
+			}
+
+			// filtering and sorting happen here, so the page can only be
+			// taken afterwards
+			local := state != stateFilterAll || len(selected) > 0 || len(sortKeys) > 0
+			pageOffset, pageLimit := serverPage(offset, limit, local)
+			cfgs, err := cli.NvmeOf.GetPage(cmd.Context(), pageOffset, pageLimit)
 			if err != nil {
 				return err
 			}
 
-			table := tablewriter.NewWriter(os.Stdout)
-			table.SetHeader([]string{"NQN", "Service IP", "Service state", "Namespace", "LINSTOR state"})
-			table.SetHeaderColor(tableColorHeader, tableColorHeader, tableColorHeader, tableColorHeader, tableColorHeader)
+			filtered := make([]nvmeof.ResourceConfig, 0, len(cfgs))
+			for _, cfg := range cfgs {
+				if matchesStateFilter(state, &cfg.Status) && common.MatchesSelector(cfg.Labels, selected) {
+					filtered = append(filtered, cfg)
+				}
+			}
+			cfgs = filtered
+
+			if len(sortKeys) > 0 {
+				fields := make([]sortFields, len(cfgs))
+				for i := range cfgs {
+					fields[i] = sortFields{id: cfgs[i].NQN.String(), status: &cfgs[i].Status, serviceIP: cfgs[i].ServiceIP.IP(), sizeKiB: totalSize(cfgs[i].Volumes)}
+				}
+
+				sorted := make([]nvmeof.ResourceConfig, len(cfgs))
+				for i, index := range sortOrder(fields, sortKeys) {
+					sorted[i] = cfgs[index]
+				}
+				cfgs = sorted
+			}
 
-			degradedResources := 0
+			start, end := localPage(offset, limit, len(cfgs), local)
+			cfgs = cfgs[start:end]
+			hintNextPage(offset, limit, len(cfgs))
+
+			if output == outputJSON {
+				return printJSON(cfgs)
+			}
+
+			table := newListTable(nvmeListColumns, shown)
+
+			var counts common.Counts
 			for _, cfg := range cfgs {
 				for i, vol := range cfg.Status.Volumes {
 					if i == 0 {
 						log.Debugf("not displaying cluster private volume: %+v", vol)
 						continue
 					}
-					table.Rich(
-						[]string{cfg.NQN.String(), cfg.ServiceIP.String(), cfg.Status.Service.String(), strconv.Itoa(vol.Number), vol.State.String()},
-						[]tablewriter.Colors{{}, {}, ServiceStateColor(cfg.Status.Service), {}, ResourceStateColor(vol.State)},
-					)
-					if vol.State != common.ResourceStateOK {
-						degradedResources++
+					row := []string{
+						cfg.NQN.String(),
+						cfg.ServiceIP.String(),
+						cfg.Status.Service.String(),
+						strconv.Itoa(vol.Number),
+						vol.State.String(),
+						vol.Sync,
+						common.FormatSize(volumeSize(cfg.Volumes, vol.Number)),
+						formatAllocated(vol.AllocatedKiB),
+						cfg.Status.ConfigPath,
+						formatNodeVolumes(vol),
 					}
+					colors := make([]tablewriter.Colors, len(row))
+					colors[2] = ServiceStateColor(cfg.Status.Service)
+					colors[4] = ResourceStateColor(vol.State)
+					colors[5] = SyncStateColor(vol)
+
+					table.add(row, colors)
+					counts.AddVolume(vol)
 				}
 			}
 
-			table.SetAutoMergeCellsByColumnIndex([]int{0, 1})
-			table.SetAutoFormatHeaders(false)
-			table.Render()
-			if degradedResources > 0 {
-				log.Warnf("Some resources are degraded. Run %s for possible solutions.", bold("linstor advise resource"))
+			table.render()
+			warnVolumes(counts)
+			for _, cfg := range cfgs {
+				warnInconsistent(cfg.NQN.String(), cfg.Status, recreateRemedy)
+				warnReplicas(cfg.NQN.String(), cfg.Status)
 			}
 
 			return nil
 		},
 	}
+
+	addVerboseFlag(cmd, &verbose)
+	addColumnsFlag(cmd, nvmeListColumns, &columns)
+	addSortFlag(cmd, "nqn", &sortBy)
+	addWideOutputFlag(cmd, &output)
+	addStateFilterFlag(cmd, &state)
+	addSelectorFlag(cmd, &selector)
+	addPageFlags(cmd, &offset, &limit)
+
+	return cmd
+}
+
+func getNVMECommand() *cobra.Command {
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "get NQN",
+		Short: "Shows the details of an NVMe-oF target",
+		Long: `Shows the configuration and the current state of a single NVMe-oF target.
+
+Instead of the NQN, the name of the LINSTOR resource backing the target can
+be given.`,
+		Example: `linstor-gateway nvme get linbit:nvme:example
+linstor-gateway nvme get example`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			err := checkWideOutputFormat(output)
+			if err != nil {
+				return err
+			}
+
+			nqn, err := resolveNVMETarget(cmd.Context(), args[0])
+			if err != nil {
+				return err
+			}
+
+			cfg, err := cli.NvmeOf.Get(cmd.Context(), nqn)
+			if err == client.NotFoundError {
+				return noTarget(nqn.String())
+			}
+			if err != nil {
+				return err
+			}
+
+			if output == outputJSON {
+				return printJSON(cfg)
+			}
+
+			printDetails([][2]string{
+				{"NQN", cfg.NQN.String()},
+				{"Service IP", cfg.ServiceIP.String()},
+				{"Allowed hosts", formatAllowedHosts(cfg.AllowedHosts)},
+				{"Model", formatModel(cfg.Model)},
+				{"Serial number", cfg.Serial()},
+				{"Service state", cfg.Status.Service.String()},
+				{"Primary", cfg.Status.Primary},
+				{"Nodes", strings.Join(cfg.Status.Nodes, ", ")},
+				{"LINSTOR resource", cfg.ResourceName},
+				{"Resource group", cfg.ResourceGroup},
+				{"Placement", formatPlacement(cfg.Status.Placement)},
+				{"Replicas", formatReplicas(cfg.Status)},
+				{"Layers", formatLayerList(cfg.LayerList)},
+				{"DRBD port", formatDrbdNumber(cfg.Status.DrbdPort)},
+				{"DRBD protocol", formatDrbdProtocol(cfg.DrbdOptions)},
+				{"Quorum", formatQuorumPolicy(cfg.DrbdOptions)},
+				{"Labels", formatLabels(cfg.Labels)},
+				{"Description", formatDescription(cfg.Description)},
+				{"Gross size", strconv.FormatBool(cfg.GrossSize)},
+				{"Read limit", formatIOLimit(cfg.ReadLimit)},
+				{"Write limit", formatIOLimit(cfg.WriteLimit)},
+				{"Config file", cfg.Status.ConfigPath},
+			})
+			fmt.Println()
+			renderVolumes("Namespace", cfg.Volumes, cfg.Status, nil, func(int) []string { return nil })
+			if output == outputWide {
+				fmt.Println()
+				renderNodeVolumes("Namespace", cfg.Volumes, cfg.Status)
+			}
+			warnInconsistent(cfg.NQN.String(), cfg.Status, recreateRemedy)
+			warnReplicas(cfg.NQN.String(), cfg.Status)
+
+			return nil
+		},
+		ValidArgsFunction: completeNVMETargets(1),
+	}
+
+	addWideOutputFlag(cmd, &output)
+
+	return cmd
+}
+
+// resolveNVMETarget returns the NQN of the target identified by arg, which is
+// either an NQN or the name of the LINSTOR resource backing the target.
+func resolveNVMETarget(ctx context.Context, arg string) (nvmeof.Nqn, error) {
+	nqn, nqnErr := nvmeof.NewNqn(arg)
+	if nqnErr == nil {
+		return nqn, nil
+	}
+
+	cfgs, err := cli.NvmeOf.GetAll(ctx)
+	if err != nil {
+		return nvmeof.Nqn{}, err
+	}
+
+	for _, cfg := range cfgs {
+		rscName := cfg.ResourceName
+		if rscName == "" {
+			rscName = cfg.NQN.Subsystem()
+		}
+		if rscName == arg {
+			return cfg.NQN, nil
+		}
+	}
+
+	return nvmeof.Nqn{}, fmt.Errorf("no target uses '%s' as LINSTOR resource, and it is not an NQN either: %w", arg, nqnErr)
 }
 
 func createNVMECommand() *cobra.Command {
 	resourceGroup := "DfltRscGrp"
 	grossSize := false
+	keepOnFailure := false
+	skipCapacityCheck := false
+	noStart := false
+	var waitSync waitSyncFlags
+	overwrite := false
+	yes := false
+	replicaCount := 0
+	var allowedHosts []string
+	var readLimit, writeLimit string
+	var layerList string
+	var storagePool string
+	var drbdPort, drbdMinor int
+	var labels []string
+	var description string
+	var drbdOptions []string
+	var model, serialNumber string
+	var resourceName string
 
 	cmd := &cobra.Command{
-		Use:     "create NQN SERVICE_IP VOLUME_SIZE [VOLUME_SIZE]...",
-		Short:   "Create a new NVMe-oF target",
-		Long:    `Create a new NVMe-oF target. The NQN consists of <vendor>:nvme:<subsystem>.`,
-		Example: `linstor-gateway nvme create linbit:nvme:example`,
+		Use:   "create NQN SERVICE_IP VOLUME_SIZE [VOLUME_SIZE]...",
+		Short: "Create a new NVMe-oF target",
+		Long: `Create a new NVMe-oF target. The NQN consists of <vendor>:nvme:<subsystem>.
+The LINSTOR resource is named after the subsystem, unless a different name is
+given with --resource-name.
+
+Only the hosts given with --allowed-hosts may connect to the target. Without
+this option, any host that can reach the service IP may connect.`,
+		Example: `linstor-gateway nvme create linbit:nvme:example 192.168.122.181/24 2G --allowed-hosts nqn.2014-08.org.nvmexpress:uuid:0c468c4d-a385-47e0-8299-6e95051277db`,
 		Args:    cobra.MinimumNArgs(3),
 		RunE: func(cmd *cobra.Command, args []string) error {
+			err := checkReplicaCount(cmd, replicaCount)
+			if err != nil {
+				return err
+			}
+
+			readIOLimit, writeIOLimit, err := parseIOLimits(readLimit, writeLimit)
+			if err != nil {
+				return err
+			}
+
+			layers, err := parseLayerList(layerList)
+			if err != nil {
+				return err
+			}
+
+			parsedLabels, err := parseLabels(labels)
+			if err != nil {
+				return err
+			}
+
+			parsedDrbdOptions, err := parseDrbdOptions(drbdOptions)
+			if err != nil {
+				return err
+			}
+
+			err = checkResourceName(resourceName)
+			if err != nil {
+				return err
+			}
+
 			nqn, err := nvmeof.NewNqn(args[0])
 			if err != nil {
 				return err
@@ -106,44 +370,116 @@ func createNVMECommand() *cobra.Command {
 
 			var volumes []common.VolumeConfig
 			for i, rawvalue := range args[2:] {
-				val, err := unit.MustNewUnit(unit.DefaultUnits).ValueFromString(rawvalue)
+				sizeKiB, err := parseVolumeSize(rawvalue)
 				if err != nil {
 					return err
 				}
 
 				volumes = append(volumes, common.VolumeConfig{
 					Number:  i + 1,
-					SizeKiB: uint64(val.Value / unit.K)})
+					SizeKiB: sizeKiB})
 			}
 
-			_, err = cli.NvmeOf.Create(context.Background(), &nvmeof.ResourceConfig{
-				NQN:           nqn,
-				ServiceIP:     serviceIP,
-				ResourceGroup: resourceGroup,
-				Volumes:       volumes,
-				GrossSize:     grossSize,
-			})
+			rsc := &nvmeof.ResourceConfig{
+				NQN:            nqn,
+				ResourceName:   resourceName,
+				ServiceIP:      serviceIP,
+				AllowedHosts:   allowedHosts,
+				Model:          model,
+				SerialNumber:   serialNumber,
+				ResourceGroup:  resourceGroup,
+				Volumes:        volumes,
+				GrossSize:      grossSize,
+				PlacementCount: replicaCount,
+				LayerList:      layers,
+				StoragePool:    storagePool,
+				DrbdPort:       drbdPort,
+				DrbdMinor:      drbdMinor,
+				Labels:         parsedLabels,
+				Description:    description,
+				DrbdOptions:    parsedDrbdOptions,
+				ReadLimit:      readIOLimit,
+				WriteLimit:     writeIOLimit,
+			}
+
+			if overwrite && !yes {
+				existing, err := cli.NvmeOf.Get(cmd.Context(), nqn)
+				if err != nil && err != client.NotFoundError {
+					return err
+				}
+
+				if err == nil {
+					requested := *rsc
+					requested.FillDefaults()
+					requested.Volumes = append([]common.VolumeConfig{common.ClusterPrivateVolume()}, requested.Volumes...)
+					err = confirmOverwrite("target", existing, &requested)
+					if err != nil {
+						return err
+					}
+				}
+			}
+
+			created, err := cli.NvmeOf.Create(cmd.Context(), rsc, common.CreateOptions{KeepOnFailure: keepOnFailure, Overwrite: overwrite, SkipCapacityCheck: skipCapacityCheck, NoStart: noStart, WaitSync: waitSync.wait, SyncTimeout: waitSync.timeout})
 			if err != nil {
 				return err
 			}
 
 			fmt.Printf("Created target \"%s\"\n", nqn)
+			warnReplicas(nqn.String(), created.Status)
+			if noStart {
+				hintStart("nvme", nqn.String())
+			}
+			if len(allowedHosts) == 0 {
+				warnAllHostsAllowed(nqn)
+			}
 
 			return nil
 		},
 	}
 	cmd.Flags().StringVarP(&resourceGroup, "resource-group", "r", resourceGroup, "resource group to use.")
-	cmd.Flags().BoolVar(&grossSize, "gross", false, "Make all size options specify gross size, i.e. the actual space used on disk")
+	addResourceNameFlag(cmd, "subsystem part of the NQN", &resourceName)
+	cmd.Flags().BoolVar(&grossSize, "gross", false, "Make all size options specify gross size, i.e. the actual space used on disk. This also applies to the internal cluster private volume")
+	cmd.Flags().BoolVar(&keepOnFailure, "keep-on-failure", false, "Do not clean up the LINSTOR resource if creating the target fails, for debugging")
+	addSkipCapacityCheckFlag(cmd, &skipCapacityCheck)
+	addNoStartFlag(cmd, "target", &noStart)
+	addWaitSyncFlags(cmd, "target", &waitSync)
+	cmd.Flags().StringSliceVar(&allowedHosts, "allowed-hosts", nil, "NQNs of the hosts that may connect to the target. If not set, any host may connect")
+	cmd.Flags().StringVar(&model, "model", "", "Model the subsystem reports to hosts (at most 40 ASCII characters). If not set, the kernel's default is used")
+	cmd.Flags().StringVar(&serialNumber, "serial-number", "", "Serial number the subsystem reports to hosts (at most 20 ASCII characters). If not set, it is derived from the NQN")
+	addReplicaCountFlag(cmd, &replicaCount)
+	addLayerListFlag(cmd, &layerList)
+	addStoragePoolFlag(cmd, &storagePool)
+	addDrbdFlags(cmd, &drbdPort, &drbdMinor)
+	addLabelFlag(cmd, &labels)
+	addDescriptionFlag(cmd, &description)
+	addDrbdOptionFlag(cmd, &drbdOptions)
+	addIOLimitFlags(cmd, &readLimit, &writeLimit)
+	addOverwriteFlags(cmd, "target", &overwrite, &yes)
 
 	return cmd
 }
 
 func deleteNVMECommand() *cobra.Command {
-	return &cobra.Command{
+	var yes bool
+	var opts common.DeleteOptions
+
+	cmd := &cobra.Command{
 		Use:   "delete NQN...",
 		Short: "Delete existing NVMe-oF targets",
-		Args:  cobra.MinimumNArgs(1),
+		Long: `Delete existing NVMe-oF targets, including all their volumes.
+
+With --keep-resource, only the drbd-reactor configuration is removed. The
+LINSTOR resources and their data are kept, so that they can be managed
+manually.
+
+The targets to delete have to be confirmed, unless --yes is given.`,
+		Args: cobra.MinimumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
+			err := confirmDelete(yes, "targets", args, opts)
+			if err != nil {
+				return err
+			}
+
 			var allErrs multiError
 			for _, rawnqn := range args {
 				nqn, err := nvmeof.NewNqn(rawnqn)
@@ -152,9 +488,9 @@ func deleteNVMECommand() *cobra.Command {
 					continue
 				}
 
-				err = cli.NvmeOf.Delete(context.Background(), nqn)
+				err = cli.NvmeOf.Delete(cmd.Context(), nqn, opts)
 				if err == client.NotFoundError {
-					allErrs = append(allErrs, noTarget(nqn))
+					allErrs = append(allErrs, noTarget(nqn.String()))
 					continue
 				}
 				if err != nil {
@@ -163,18 +499,26 @@ func deleteNVMECommand() *cobra.Command {
 				}
 
 				fmt.Printf("Deleted target \"%s\"\n", nqn)
+				hintKeptResource(opts)
 			}
 
 			return allErrs.Err()
 		},
+		ValidArgsFunction: completeNVMETargets(0),
 	}
+
+	addKeepResourceFlag(cmd, "targets", &opts)
+	addYesFlag(cmd, &yes, "deleting the targets")
+
+	return cmd
 }
 
 func startNVMECommand() *cobra.Command {
 	return &cobra.Command{
-		Use:   "start NQN...",
-		Short: "Start a stopped NVMe-oF target",
-		Args:  cobra.MinimumNArgs(1),
+		Use:     "start NQN...",
+		Short:   "Start a stopped NVMe-oF target",
+		Example: "linstor-gateway nvme start nqn.2021-08.com.linbit:nvme:example",
+		Args:    cobra.MinimumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			var allErrs multiError
 			for _, rawnqn := range args {
@@ -184,9 +528,9 @@ func startNVMECommand() *cobra.Command {
 					continue
 				}
 
-				_, err = cli.NvmeOf.Start(context.Background(), nqn)
+				_, err = cli.NvmeOf.Start(cmd.Context(), nqn)
 				if err == client.NotFoundError {
-					allErrs = append(allErrs, noTarget(nqn))
+					allErrs = append(allErrs, noTarget(nqn.String()))
 					continue
 				}
 				if err != nil {
@@ -199,14 +543,16 @@ func startNVMECommand() *cobra.Command {
 
 			return allErrs.Err()
 		},
+		ValidArgsFunction: completeNVMETargets(0),
 	}
 }
 
 func stopNVMECommand() *cobra.Command {
 	return &cobra.Command{
-		Use:   "stop NQN...",
-		Short: "Stop a started NVMe-oF target",
-		Args:  cobra.MinimumNArgs(1),
+		Use:     "stop NQN...",
+		Short:   "Stop a started NVMe-oF target",
+		Example: "linstor-gateway nvme stop nqn.2021-08.com.linbit:nvme:example",
+		Args:    cobra.MinimumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			var allErrs multiError
 			for _, rawnqn := range args {
@@ -216,9 +562,9 @@ func stopNVMECommand() *cobra.Command {
 					continue
 				}
 
-				_, err = cli.NvmeOf.Stop(context.Background(), nqn)
+				_, err = cli.NvmeOf.Stop(cmd.Context(), nqn)
 				if err == client.NotFoundError {
-					allErrs = append(allErrs, noTarget(nqn))
+					allErrs = append(allErrs, noTarget(nqn.String()))
 					continue
 				}
 				if err != nil {
@@ -231,15 +577,19 @@ func stopNVMECommand() *cobra.Command {
 
 			return allErrs.Err()
 		},
+		ValidArgsFunction: completeNVMETargets(0),
 	}
 }
 
 func addVolumeNVMECommand() *cobra.Command {
-	return &cobra.Command{
+	var grossSize bool
+
+	cmd := &cobra.Command{
 		Use:   "add-volume NQN VOLUME_NR VOLUME_SIZE",
 		Short: "Add a new volume to an existing NVMe-oF target",
-		Long:  "Add a new volume to an existing NVMe-oF target. The target needs to be stopped.",
-		Args:  cobra.ExactArgs(3),
+		Long: `Add a new volume to an existing NVMe-oF target. The target may be running,
+in which case the new namespace is added to the running subsystem.`,
+		Args: cobra.ExactArgs(3),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			nqn, err := nvmeof.NewNqn(args[0])
 			if err != nil {
@@ -251,14 +601,19 @@ func addVolumeNVMECommand() *cobra.Command {
 				return err
 			}
 
-			size, err := unit.MustNewUnit(unit.DefaultUnits).ValueFromString(args[2])
+			sizeKiB, err := parseVolumeSize(args[2])
 			if err != nil {
 				return err
 			}
 
-			_, err = cli.NvmeOf.AddVolume(context.Background(), nqn, &common.VolumeConfig{Number: volNr, SizeKiB: uint64(size.Value / unit.K)})
+			volume := &common.VolumeConfig{Number: volNr, SizeKiB: sizeKiB}
+			if cmd.Flags().Changed("gross") {
+				volume.GrossSize = &grossSize
+			}
+
+			_, err = cli.NvmeOf.AddVolume(cmd.Context(), nqn, volume)
 			if err == client.NotFoundError {
-				return noTarget(nqn)
+				return noTarget(nqn.String())
 			}
 			if err != nil {
 				return err
@@ -268,14 +623,25 @@ func addVolumeNVMECommand() *cobra.Command {
 			return nil
 		},
 	}
+
+	cmd.Flags().BoolVar(&grossSize, "gross", false, "Specify the size as gross size, i.e. the actual space used on disk. Has to match the size semantics the target was created with. By default, the size semantics of the target are used")
+
+	return cmd
 }
 
 func deleteVolumeNVMECommand() *cobra.Command {
-	return &cobra.Command{
+	var yes bool
+
+	cmd := &cobra.Command{
 		Use:   "delete-volume NQN VOLUME_NR",
 		Short: "Delete a volume of an existing NVMe-oF target",
-		Long:  "Delete a volume of an existing NVMe-oF target. The target needs to be stopped.",
-		Args:  cobra.ExactArgs(2),
+		Long: `Delete a volume of an existing NVMe-oF target. The target needs to be stopped.
+
+The remaining volumes keep their numbers, so deleting a volume from the middle
+leaves a gap in the namespace IDs. LINSTOR does not allow changing the number of a
+volume definition, so the numbers cannot be compacted in place. To get
+contiguous numbers, the target has to be recreated.`,
+		Args: cobra.ExactArgs(2),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			nqn, err := nvmeof.NewNqn(args[0])
 			if err != nil {
@@ -287,10 +653,15 @@ func deleteVolumeNVMECommand() *cobra.Command {
 				return err
 			}
 
-			err = cli.NvmeOf.DeleteVolume(context.Background(), nqn, volNr)
+			err = confirmDestructive(yes, fmt.Sprintf("Volume %d of \"%s\" and all its data will be deleted.", volNr, nqn))
+			if err != nil {
+				return err
+			}
+
+			err = cli.NvmeOf.DeleteVolume(cmd.Context(), nqn, volNr)
 			if err != nil {
 				if err == client.NotFoundError {
-					return noTarget(nqn)
+					return noTarget(nqn.String())
 				}
 				return err
 			}
@@ -299,12 +670,169 @@ func deleteVolumeNVMECommand() *cobra.Command {
 			return nil
 		},
 	}
+
+	addYesFlag(cmd, &yes, "deleting the volume")
+
+	return cmd
+}
+
+func addHostNVMECommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "add-host NQN HOST_NQN",
+		Short: "Allow a host to connect to an NVMe-oF target",
+		Long: `Add a host NQN to the hosts that may connect to an NVMe-oF target. Once a host
+is added, only the listed hosts may connect.
+
+The change only takes effect after the target is restarted.`,
+		Example: "linstor-gateway nvme add-host linbit:nvme:example nqn.2014-08.org.nvmexpress:uuid:0c468c4d-a385-47e0-8299-6e95051277db",
+		Args:    cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			nqn, err := nvmeof.NewNqn(args[0])
+			if err != nil {
+				return err
+			}
+
+			cfg, err := cli.NvmeOf.AddAllowedHost(cmd.Context(), nqn, args[1])
+			if err == client.NotFoundError {
+				return noTarget(nqn.String())
+			}
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("Allowed host \"%s\" to connect to \"%s\"\n", args[1], nqn)
+			warnRestartNVMe(cfg)
+			return nil
+		},
+	}
+}
+
+func removeHostNVMECommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "remove-host NQN HOST_NQN",
+		Short: "Stop allowing a host to connect to an NVMe-oF target",
+		Long: `Remove a host NQN from the hosts that may connect to an NVMe-oF target.
+Removing the last host allows any host to connect.
+
+The change only takes effect after the target is restarted.`,
+		Example: "linstor-gateway nvme remove-host linbit:nvme:example nqn.2014-08.org.nvmexpress:uuid:0c468c4d-a385-47e0-8299-6e95051277db",
+		Args:    cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			nqn, err := nvmeof.NewNqn(args[0])
+			if err != nil {
+				return err
+			}
+
+			cfg, err := cli.NvmeOf.RemoveAllowedHost(cmd.Context(), nqn, args[1])
+			if err == client.NotFoundError {
+				return noTarget(nqn.String())
+			}
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("Removed host \"%s\" from \"%s\"\n", args[1], nqn)
+			if len(cfg.AllowedHosts) == 0 {
+				warnAllHostsAllowed(nqn)
+			}
+			warnRestartNVMe(cfg)
+			return nil
+		},
+	}
+}
+
+func setDescriptionNVMECommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "set-description NQN DESCRIPTION",
+		Short: "Changes the description of an NVMe-oF target",
+		Long: `Changes the free-text description of an existing NVMe-oF target. An empty
+description removes it.
+
+The description is only metadata, so the target does not need to be
+restarted.`,
+		Example: `linstor-gateway nvme set-description linbit:nvme:example "Database volumes, see TICKET-123"
+linstor-gateway nvme set-description linbit:nvme:example ""`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			nqn, err := nvmeof.NewNqn(args[0])
+			if err != nil {
+				return err
+			}
+
+			_, err = cli.NvmeOf.SetDescription(cmd.Context(), nqn, args[1])
+			if err == client.NotFoundError {
+				return noTarget(nqn.String())
+			}
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("Changed description of \"%s\"\n", nqn)
+			return nil
+		},
+		ValidArgsFunction: completeNVMETargets(1),
+	}
 }
 
-type noTarget nvmeof.Nqn
+func warnAllHostsAllowed(nqn nvmeof.Nqn) {
+	log.Warnf("Any host may connect to \"%[1]s\". Use \"linstor-gateway nvme add-host %[1]s HOST_NQN\" to restrict access", nqn)
+}
+
+func warnRestartNVMe(cfg *nvmeof.ResourceConfig) {
+	if cfg.Status.Service.Active() {
+		log.Warnf("The target is running. Restart it with \"linstor-gateway nvme stop %[1]s\" and \"linstor-gateway nvme start %[1]s\" for the change to take effect", cfg.NQN)
+	}
+}
+
+// formatModel describes the model a subsystem reports to hosts.
+func formatModel(model string) string {
+	if model == "" {
+		return "kernel default"
+	}
+
+	return model
+}
+
+// formatAllowedHosts returns the list of allowed hosts for display.
+func formatAllowedHosts(hosts []string) string {
+	if len(hosts) == 0 {
+		return "any"
+	}
+	return strings.Join(hosts, ", ")
+}
+
+type noTarget string
 
 func (n noTarget) Error() string {
-	return fmt.Sprintf("no target named %s", nvmeof.Nqn(n))
+	return fmt.Sprintf("target \"%s\" not found", string(n))
+}
+
+func validateNVMECommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "validate NQN...",
+		Short: "Checks whether an NQN is valid",
+		Long: `Checks whether the given NQNs are valid and can be used to create an NVMe-oF
+target. This does not contact the LINSTOR Gateway server.`,
+		Example: "linstor-gateway nvme validate nqn.2021-08.com.linbit:nvme:example",
+		Args:    cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var allErrs multiError
+			for _, rawnqn := range args {
+				nqn, err := nvmeof.NewNqn(rawnqn)
+				if err == nil {
+					err = nqn.Validate()
+				}
+				if err != nil {
+					allErrs = append(allErrs, err)
+					continue
+				}
+
+				fmt.Printf("NQN \"%s\" is valid, the LINSTOR resource will be named \"%s\"\n", nqn, nqn.Subsystem())
+			}
+
+			return allErrs.Err()
+		},
+	}
 }
 
 type multiError []error
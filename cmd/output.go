@@ -0,0 +1,302 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+
+	"github.com/LINBIT/linstor-gateway/pkg/common"
+	"github.com/LINBIT/linstor-gateway/pkg/reactor"
+)
+
+const (
+	outputText = "text"
+	outputJSON = "json"
+	// outputWide is the text output with the state of every volume on
+	// every node. Only the get and list commands support it.
+	outputWide = "wide"
+)
+
+// addOutputFlag registers the --output flag for choosing the output format.
+func addOutputFlag(cmd *cobra.Command, output *string) {
+	cmd.Flags().StringVarP(output, "output", "o", outputText, fmt.Sprintf("Output format (one of %s, %s)", outputText, outputJSON))
+}
+
+func checkOutputFormat(output string) error {
+	switch output {
+	case outputText, outputJSON:
+		return nil
+	default:
+		return fmt.Errorf("invalid output format '%s', expected one of %s, %s", output, outputText, outputJSON)
+	}
+}
+
+// addWideOutputFlag registers the --output flag for choosing the output
+// format, including the wide output.
+func addWideOutputFlag(cmd *cobra.Command, output *string) {
+	cmd.Flags().StringVarP(output, "output", "o", outputText, fmt.Sprintf("Output format (one of %s, %s, %s). %s adds the state of every volume on every node", outputText, outputJSON, outputWide, outputWide))
+}
+
+func checkWideOutputFormat(output string) error {
+	if output == outputWide {
+		return nil
+	}
+
+	err := checkOutputFormat(output)
+	if err != nil {
+		return fmt.Errorf("invalid output format '%s', expected one of %s, %s, %s", output, outputText, outputJSON, outputWide)
+	}
+
+	return nil
+}
+
+func printJSON(v interface{}) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// printDetails prints key/value pairs, one per line, with the values aligned.
+func printDetails(details [][2]string) {
+	width := 0
+	for _, d := range details {
+		if len(d[0]) > width {
+			width = len(d[0])
+		}
+	}
+
+	for _, d := range details {
+		fmt.Printf("%s %s\n", bold("%-*s", width+1, d[0]+":"), d[1])
+	}
+}
+
+func formatChap(username, password string, showSecrets bool) string {
+	switch {
+	case username == "" && password == "":
+		return "disabled"
+	case password == "":
+		return fmt.Sprintf("user '%s', no password", username)
+	case showSecrets:
+		return fmt.Sprintf("user '%s', password '%s'", username, password)
+	default:
+		return fmt.Sprintf("user '%s', password set", username)
+	}
+}
+
+// formatCacheMode describes the cache mode of a target. An empty mode means
+// that the default of the target implementation applies.
+func formatCacheMode(mode string) string {
+	if mode == "" {
+		return "default"
+	}
+	return mode
+}
+
+// printRepairResult shows the changes made by repairing the reactor config
+// of the given target.
+func printRepairResult(name string, result *reactor.RepairResult) {
+	switch {
+	case result.Diff == "" && result.Applied:
+		fmt.Printf("Configuration of \"%s\" was up to date, registered it again\n", name)
+	case result.Diff == "":
+		fmt.Printf("Configuration of \"%s\" is up to date\n", name)
+	case result.Applied:
+		fmt.Print(result.Diff)
+		fmt.Printf("Repaired configuration of \"%s\"\n", name)
+	default:
+		fmt.Print(result.Diff)
+		fmt.Printf("Configuration of \"%s\" would be changed, run without --dry-run to apply\n", name)
+	}
+}
+
+// formatReplicas describes how many of the requested diskful replicas are
+// deployed, e.g. "2/3".
+func formatReplicas(status common.ResourceStatus) string {
+	if status.Placement == nil || status.Placement.PlaceCount == 0 {
+		return strconv.Itoa(status.Replicas)
+	}
+
+	return fmt.Sprintf("%d/%d", status.Replicas, status.Placement.PlaceCount)
+}
+
+// formatPlacement summarizes the placement policy of a resource group, e.g.
+// "2 diskful replicas in pool1, pool2; diskless on remaining nodes".
+func formatPlacement(p *common.Placement) string {
+	if p == nil {
+		return "unknown"
+	}
+
+	var parts []string
+	replicas := "default number of diskful replicas"
+	if p.PlaceCount > 0 {
+		replicas = fmt.Sprintf("%d diskful replicas", p.PlaceCount)
+	}
+	if len(p.StoragePools) > 0 {
+		replicas += " in " + strings.Join(p.StoragePools, ", ")
+	} else {
+		replicas += " in any storage pool"
+	}
+	parts = append(parts, replicas)
+
+	if len(p.ReplicasOnSame) > 0 {
+		parts = append(parts, "same "+strings.Join(p.ReplicasOnSame, ", "))
+	}
+	if len(p.ReplicasOnDifferent) > 0 {
+		parts = append(parts, "different "+strings.Join(p.ReplicasOnDifferent, ", "))
+	}
+	if p.DisklessOnRemaining {
+		parts = append(parts, "diskless on remaining nodes")
+	}
+
+	return strings.Join(parts, "; ")
+}
+
+// renderVolumes prints a table with the configuration and runtime state of
+// each volume. numberHeader is the protocol specific name of the volume
+// number, and extra holds additional columns per volume.
+func renderVolumes(numberHeader string, volumes []common.VolumeConfig, status common.ResourceStatus, extraHeaders []string, extra func(i int) []string) {
+	states := make(map[int]common.VolumeState, len(status.Volumes))
+	for _, vol := range status.Volumes {
+		states[vol.Number] = vol
+	}
+
+	header := append([]string{numberHeader, "Provisioned", "Allocated", "File system", "DRBD minor"}, extraHeaders...)
+	header = append(header, "LINSTOR state", "Sync")
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader(header)
+	table.SetHeaderColor(headerColors(len(header))...)
+	table.SetAutoFormatHeaders(false)
+
+	for i, vol := range volumes {
+		number := formatVolumeNumber(vol)
+		if vol.Number == 0 {
+			number += " (cluster private)"
+		}
+
+		state := states[vol.Number]
+		row := append([]string{number, common.FormatSize(vol.SizeKiB), formatAllocated(state.AllocatedKiB), vol.FileSystem, formatDrbdNumber(state.DrbdMinor)}, extra(i)...)
+		row = append(row, state.State.String(), state.Sync)
+		colors := make([]tablewriter.Colors, len(row))
+		colors[len(row)-2] = ResourceStateColor(state.State)
+		colors[len(row)-1] = SyncStateColor(state)
+
+		table.Rich(row, colors)
+	}
+
+	table.Render()
+}
+
+// renderNodeVolumes prints a table with the state of every volume on every
+// node it is deployed on.
+func renderNodeVolumes(numberHeader string, volumes []common.VolumeConfig, status common.ResourceStatus) {
+	states := make(map[int]common.VolumeState, len(status.Volumes))
+	for _, vol := range status.Volumes {
+		states[vol.Number] = vol
+	}
+
+	header := []string{numberHeader, "Node", "Role", "Disk state", "Storage pool", "Allocated"}
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader(header)
+	table.SetHeaderColor(headerColors(len(header))...)
+	table.SetAutoMergeCellsByColumnIndex([]int{0})
+	table.SetAutoFormatHeaders(false)
+
+	for _, vol := range volumes {
+		for _, node := range states[vol.Number].Nodes {
+			row := []string{formatVolumeNumber(vol), node.Node, formatRole(node.Primary), node.DiskState, node.StoragePool, formatAllocated(node.AllocatedKiB)}
+			colors := make([]tablewriter.Colors, len(row))
+			colors[3] = DiskStateColor(node.DiskState)
+
+			table.Rich(row, colors)
+		}
+	}
+
+	table.Render()
+}
+
+// formatNodeVolumes describes the state of a volume on every node it is
+// deployed on, e.g. "node-a (UpToDate, primary), node-b (Diskless)".
+func formatNodeVolumes(vol common.VolumeState) string {
+	nodes := make([]string, len(vol.Nodes))
+	for i, node := range vol.Nodes {
+		state := node.DiskState
+		if node.Primary {
+			state += ", primary"
+		}
+		nodes[i] = fmt.Sprintf("%s (%s)", node.Node, state)
+	}
+
+	return strings.Join(nodes, ", ")
+}
+
+func formatRole(primary bool) string {
+	if primary {
+		return "Primary"
+	}
+	return "Secondary"
+}
+
+// formatAllocated describes the allocated size of a volume reported by
+// LINSTOR.
+func formatAllocated(kib uint64) string {
+	if kib == 0 {
+		return "unknown"
+	}
+
+	return common.FormatSize(kib)
+}
+
+// volumeSize returns the provisioned size of the volume with the given
+// number, or 0 if there is no such volume.
+func volumeSize(volumes []common.VolumeConfig, number int) uint64 {
+	for _, vol := range volumes {
+		if vol.Number == number {
+			return vol.SizeKiB
+		}
+	}
+
+	return 0
+}
+
+// formatVolumeNumber returns the number of the volume, marked if it is not
+// exported.
+func formatVolumeNumber(vol common.VolumeConfig) string {
+	if !vol.IsExported() {
+		return strconv.Itoa(vol.Number) + " (not exported)"
+	}
+
+	return strconv.Itoa(vol.Number)
+}
+
+// volumeNumber returns the number of the volume with the given number, marked
+// if it is not exported.
+func volumeNumber(volumes []common.VolumeConfig, number int) string {
+	for _, vol := range volumes {
+		if vol.Number == number {
+			return formatVolumeNumber(vol)
+		}
+	}
+
+	return strconv.Itoa(number)
+}
+
+// headerColors returns the colors for a table header with n columns.
+func headerColors(n int) []tablewriter.Colors {
+	colors := make([]tablewriter.Colors, n)
+	for i := range colors {
+		colors[i] = tableColorHeader
+	}
+
+	return colors
+}
+
+// addVerboseFlag adds the flag to show additional columns to a list command.
+func addVerboseFlag(cmd *cobra.Command, verbose *bool) {
+	cmd.Flags().BoolVar(verbose, "verbose", false, "Show additional columns, e.g. the provisioned and allocated size of each volume and the drbd-reactor config file of each resource")
+}
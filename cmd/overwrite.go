@@ -0,0 +1,28 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/LINBIT/linstor-gateway/pkg/common"
+)
+
+// addOverwriteFlags adds the flags to replace the config of an existing
+// target or export to a create command. what names the kind of resource.
+func addOverwriteFlags(cmd *cobra.Command, what string, overwrite, yes *bool) {
+	cmd.Flags().BoolVar(overwrite, "overwrite", false, fmt.Sprintf("If the %[1]s already exists with a different config, change it to match this one. A running %[1]s is stopped and started again. Volumes can be added, but not removed, and the size of existing volumes and the resource group cannot be changed. Use delete-volume to remove volumes", what))
+	addYesFlag(cmd, yes, "overwriting an existing config")
+}
+
+// confirmOverwrite prints the changes that overwriting the existing config
+// would make and asks the user to confirm them. It returns an error if the
+// user does not agree.
+func confirmOverwrite(what string, existing, requested interface{}) error {
+	diff := common.ConfigDiff(existing, requested)
+	if diff == "" {
+		return nil
+	}
+
+	return confirmDestructive(false, fmt.Sprintf("The existing %s will be changed (-existing +new):\n%s", what, diff))
+}
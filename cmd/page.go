@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// addPageFlags adds the flags to list only a page of the resources to a list
+// command.
+func addPageFlags(cmd *cobra.Command, offset, limit *int) {
+	cmd.Flags().IntVar(offset, "offset", 0, "Skip this many resources, e.g. to show the next page with --limit")
+	cmd.Flags().IntVar(limit, "limit", 0, "List at most this many resources; 0 means no limit. The resources are ordered by name, or by --sort, and the limit is applied after --state and --selector")
+}
+
+func checkPage(offset, limit int) error {
+	if offset < 0 {
+		return fmt.Errorf("--offset must not be negative, is %d", offset)
+	}
+
+	if limit < 0 {
+		return fmt.Errorf("--limit must not be negative, is %d", limit)
+	}
+
+	return nil
+}
+
+// serverPage returns the offset and limit of the page to request from the
+// server. The server pages the resources by name, so if they are filtered or
+// sorted locally, all of them are requested and paged with localPage.
+func serverPage(offset, limit int, local bool) (int, int) {
+	if local {
+		return 0, 0
+	}
+
+	return offset, limit
+}
+
+// localPage returns the bounds of the page within count resources that were
+// requested with serverPage.
+func localPage(offset, limit, count int, local bool) (int, int) {
+	if !local {
+		return 0, count
+	}
+
+	start := offset
+	if start > count {
+		start = count
+	}
+
+	end := count
+	if limit > 0 && start+limit < end {
+		end = start + limit
+	}
+
+	return start, end
+}
+
+// hintNextPage tells the user how to list the next page if the current page
+// is full.
+func hintNextPage(offset, limit, count int) {
+	if limit > 0 && count == limit {
+		log.Infof("Listed %d resources, there may be more. Use \"--offset %d\" to list the next page", count, offset+limit)
+	}
+}
@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// passwordFlags holds the different ways to pass the CHAP password to a
+// command. At most one of them may be used.
+type passwordFlags struct {
+	password string
+	file     string
+	stdin    bool
+}
+
+// addPasswordFlags adds the flags to pass the CHAP password to a command.
+// --password-file and --password-stdin keep the password out of the shell
+// history and the process list.
+func addPasswordFlags(cmd *cobra.Command, flags *passwordFlags) {
+	cmd.Flags().StringVarP(&flags.password, "password", "p", "", "Set the password to use for CHAP authentication. Prefer --password-file or --password-stdin, which do not expose it in the process list")
+	cmd.Flags().StringVar(&flags.file, "password-file", "", "Read the password to use for CHAP authentication from the first line of this file")
+	cmd.Flags().BoolVar(&flags.stdin, "password-stdin", false, "Read the password to use for CHAP authentication from the first line of stdin")
+}
+
+// read returns the password given by one of the flags added by
+// addPasswordFlags, or "" if none of them was used.
+func (p *passwordFlags) read() (string, error) {
+	given := 0
+	for _, set := range []bool{p.password != "", p.file != "", p.stdin} {
+		if set {
+			given++
+		}
+	}
+	if given > 1 {
+		return "", errors.New("only one of --password, --password-file and --password-stdin can be used")
+	}
+
+	switch {
+	case p.file != "":
+		f, err := os.Open(p.file)
+		if err != nil {
+			return "", fmt.Errorf("failed to read --password-file: %w", err)
+		}
+		defer f.Close()
+
+		password, err := readPassword(f)
+		if err != nil {
+			return "", fmt.Errorf("failed to read --password-file: %w", err)
+		}
+		return password, nil
+	case p.stdin:
+		password, err := readPassword(os.Stdin)
+		if err != nil {
+			return "", fmt.Errorf("failed to read the password from stdin: %w", err)
+		}
+		return password, nil
+	default:
+		return p.password, nil
+	}
+}
+
+// readPassword reads the first line of r, without the line ending.
+func readPassword(r io.Reader) (string, error) {
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+
+	password := strings.TrimRight(line, "\r\n")
+	if password == "" {
+		return "", errors.New("the password is empty")
+	}
+
+	return password, nil
+}
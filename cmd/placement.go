@@ -0,0 +1,31 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+const replicaCountFlag = "replica-count"
+
+// addReplicaCountFlag adds the flag to override the placement count of the
+// resource group to a create command.
+func addReplicaCountFlag(cmd *cobra.Command, count *int) {
+	cmd.Flags().IntVar(count, replicaCountFlag, 0, "Number of diskful replicas, overriding the placement count of the resource group. Diskless resources, e.g. a tie-breaker, are placed in addition and do not count")
+}
+
+// checkReplicaCount returns an error if the replica count was set explicitly
+// to an invalid value.
+func checkReplicaCount(cmd *cobra.Command, count int) error {
+	if cmd.Flags().Changed(replicaCountFlag) && count < 1 {
+		return fmt.Errorf("--%s must be at least 1, is %d", replicaCountFlag, count)
+	}
+
+	return nil
+}
+
+// addStoragePoolFlag adds the flag to override the storage pools of the
+// resource group to a create command.
+func addStoragePoolFlag(cmd *cobra.Command, storagePool *string) {
+	cmd.Flags().StringVar(storagePool, "storage-pool", "", "Place all diskful replicas in this LINSTOR storage pool, overriding the storage pools of the resource group. This narrows the placement: if the pool is not present on enough nodes, the replica count can not be satisfied")
+}
@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// protocolAliases are the aliases of the subcommands that the protocol
+// commands have in common. They are registered for every protocol, so that
+// an alias works the same for iscsi, nfs and nvme.
+var protocolAliases = map[string][]string{
+	"list":          {"ls"},
+	"get":           {"show"},
+	"delete":        {"rm"},
+	"delete-volume": {"rm-volume"},
+}
+
+// protocolCommand adds the subcommands of a protocol, e.g. "iscsi", to its
+// root command and registers the aliases the protocols have in common. All
+// protocol commands are built with it, so that a subcommand behaves the same
+// for every protocol.
+func protocolCommand(rootCmd *cobra.Command, subcommands ...*cobra.Command) *cobra.Command {
+	rootCmd.DisableAutoGenTag = true
+
+	for _, sub := range subcommands {
+		for _, alias := range protocolAliases[sub.Name()] {
+			if !sub.HasAlias(alias) {
+				sub.Aliases = append(sub.Aliases, alias)
+			}
+		}
+
+		rootCmd.AddCommand(sub)
+	}
+
+	return rootCmd
+}
@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/LINBIT/linstor-gateway/pkg/reactor"
+)
+
+const (
+	reactorConfigDirFlag = "reactor-config-dir"
+	reactorConfigDirEnv  = "LINSTOR_GATEWAY_REACTOR_CONFIG_DIR"
+)
+
+// addReactorConfigDirFlag adds the flag to change the directory of the
+// drbd-reactor configuration files to cmd.
+func addReactorConfigDirFlag(cmd *cobra.Command) {
+	cmd.Flags().String(reactorConfigDirFlag, "", "Directory on the nodes in which the drbd-reactor configuration files are placed (default from $"+reactorConfigDirEnv+", or "+reactor.DefaultConfigDir+")")
+}
+
+// setupReactorConfigDir sets the directory of the drbd-reactor configuration
+// files. It is taken from the --reactor-config-dir flag, the
+// LINSTOR_GATEWAY_REACTOR_CONFIG_DIR environment variable, or the
+// configuration file, in that order. A directory other than the default must
+// exist and be writable.
+func setupReactorConfigDir(cmd *cobra.Command) error {
+	dir := reactorConfigDir(cmd)
+	if dir != reactor.DefaultConfigDir {
+		err := reactor.CheckConfigDir(dir)
+		if err != nil {
+			return err
+		}
+	}
+
+	reactor.ConfigDir = dir
+	return nil
+}
+
+// reactorConfigDir returns the directory of the drbd-reactor configuration
+// files as configured for cmd, without checking it.
+func reactorConfigDir(cmd *cobra.Command) string {
+	dir := viper.GetString("reactor.config-dir")
+	if cmd.Flags().Changed(reactorConfigDirFlag) {
+		dir, _ = cmd.Flags().GetString(reactorConfigDirFlag)
+	}
+
+	if dir == "" {
+		dir = reactor.DefaultConfigDir
+	}
+
+	return filepath.Clean(dir)
+}
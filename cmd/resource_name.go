@@ -0,0 +1,30 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/LINBIT/linstor-gateway/pkg/common"
+)
+
+// addResourceNameFlag adds the flag to override the name of the LINSTOR
+// resource to a create command. derivedFrom names the part of the target's
+// name the LINSTOR resource is named after by default.
+func addResourceNameFlag(cmd *cobra.Command, derivedFrom string, name *string) {
+	cmd.Flags().StringVar(name, "resource-name", "", fmt.Sprintf("Name of the LINSTOR resource. By default, it is the %s. The target is still addressed by its full name", derivedFrom))
+}
+
+// checkResourceName validates the name given with --resource-name, if any.
+func checkResourceName(name string) error {
+	if name == "" {
+		return nil
+	}
+
+	err := common.ValidLinstorName(name)
+	if err != nil {
+		return fmt.Errorf("invalid --resource-name: %w", err)
+	}
+
+	return nil
+}
@@ -1,12 +1,20 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"github.com/LINBIT/linstor-gateway/client"
+	"github.com/LINBIT/linstor-gateway/pkg/linstorcontrol"
 	"net/url"
 	"os"
+	"os/signal"
+	"os/user"
 	"strconv"
 	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
@@ -21,10 +29,26 @@ var (
 )
 
 var (
-	cfgFile  string
-	loglevel string
-	host     string
-	cli      *client.Client
+	cfgFile    string
+	loglevel   string
+	logformat  string
+	jsonErrors bool
+	host       string
+	cli        *client.Client
+
+	commandTimeout time.Duration
+	// cancelCommand releases the timeout of the command context, if one was
+	// set.
+	cancelCommand context.CancelFunc = func() {}
+)
+
+// annotationNoTimeout marks commands that run until they are interrupted,
+// such as the server. --command-timeout does not apply to them.
+const annotationNoTimeout = "linstor-gateway/no-timeout"
+
+const (
+	logFormatText = "text"
+	logFormatJSON = "json"
 )
 
 const (
@@ -62,6 +86,26 @@ func parseBaseURL(urlString string) (*url.URL, error) {
 	return url.Parse(fmt.Sprintf("%s://%s:%s", scheme, host, port))
 }
 
+// setupLogging configures the level and output format of the global logger.
+func setupLogging(level, format string) error {
+	lvl, err := log.ParseLevel(level)
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case logFormatText:
+		log.SetFormatter(&log.TextFormatter{})
+	case logFormatJSON:
+		log.SetFormatter(&log.JSONFormatter{})
+	default:
+		return fmt.Errorf("invalid log format '%s', expected one of %s, %s", format, logFormatText, logFormatJSON)
+	}
+
+	log.SetLevel(lvl)
+	return nil
+}
+
 // rootCommand represents the base command when called without any subcommands
 func rootCommand() *cobra.Command {
 	if len(os.Args) < 1 {
@@ -74,17 +118,28 @@ func rootCommand() *cobra.Command {
 		Short:   "Manage linstor-gateway targets and exports",
 		Args:    cobra.NoArgs,
 		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
-			level, err := log.ParseLevel(loglevel)
+			if jsonErrors {
+				// the error is printed as JSON by Execute
+				cmd.SilenceErrors = true
+				cmd.SilenceUsage = true
+			}
+
+			err := setupLogging(loglevel, logformat)
 			if err != nil {
 				return err
 			}
-			log.SetLevel(level)
+
+			if commandTimeout > 0 && cmd.Annotations[annotationNoTimeout] == "" {
+				ctx, cancel := context.WithTimeout(cmd.Context(), commandTimeout)
+				cancelCommand = cancel
+				cmd.SetContext(ctx)
+			}
 
 			base, err := parseBaseURL(host)
 			if err != nil {
 				return err
 			}
-			cli, err = client.NewClient(client.BaseURL(base), client.Log(log.StandardLogger()))
+			cli, err = client.NewClient(client.BaseURL(base), client.Log(log.StandardLogger()), client.User(currentUser()))
 			if err != nil {
 				return fmt.Errorf("failed to connect to LINSTOR Gateway server: %w", err)
 			}
@@ -94,17 +149,127 @@ func rootCommand() *cobra.Command {
 	rootCmd.AddCommand(iscsiCommands())
 	rootCmd.AddCommand(nfsCommands())
 	rootCmd.AddCommand(nvmeCommands())
+	rootCmd.AddCommand(volumesCommands())
 	rootCmd.AddCommand(serverCommand())
+	rootCmd.AddCommand(daemonCommand())
 	rootCmd.AddCommand(versionCommand())
 	rootCmd.AddCommand(completionCommand(rootCmd))
 	rootCmd.AddCommand(docsCommand(rootCmd))
 	rootCmd.AddCommand(checkHealthCommand())
+	rootCmd.AddCommand(doctorCommand())
+	rootCmd.AddCommand(cleanupCommand())
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "/etc/linstor-gateway/linstor-gateway.toml", "Config file to load")
 	rootCmd.PersistentFlags().StringVarP(&host, "connect", "c", "http://localhost:8080", "LINSTOR Gateway server to connect to")
+	rootCmd.PersistentFlags().StringVar(&loglevel, "log-level", log.InfoLevel.String(), "Set the log level (one of panic, fatal, error, warn, info, debug, trace)")
 	rootCmd.PersistentFlags().StringVar(&loglevel, "loglevel", log.InfoLevel.String(), "Set the log level (as defined by logrus)")
+	rootCmd.PersistentFlags().MarkDeprecated("loglevel", "use --log-level instead")
+	rootCmd.PersistentFlags().StringVar(&logformat, "log-format", logFormatText, fmt.Sprintf("Set the log format (one of %s, %s)", logFormatText, logFormatJSON))
+	rootCmd.PersistentFlags().BoolVar(&jsonErrors, "json", false, "Print errors as JSON objects with a machine-readable error code")
+	rootCmd.PersistentFlags().DurationVar(&commandTimeout, "command-timeout", 0, "Abort the command if it takes longer than this; 0 means no limit. Does not apply to the server")
+	rootCmd.PersistentFlags().StringSlice("controllers", nil, "Comma-separated list of LINSTOR controllers to try to connect to (default from $LS_CONTROLLERS, or localhost:3370)")
+	viper.BindPFlag("linstor.controllers", rootCmd.PersistentFlags().Lookup("controllers"))
+	rootCmd.PersistentFlags().Bool("controllers-from-linstor-config", false, fmt.Sprintf("If no LINSTOR controllers are given otherwise, use the ones configured for the linstor client in %s", strings.Join(linstorcontrol.ClientConfigPaths, " or ")))
+	viper.BindPFlag("linstor.controllers-from-linstor-config", rootCmd.PersistentFlags().Lookup("controllers-from-linstor-config"))
+	rootCmd.PersistentFlags().Duration("probe-timeout", linstorcontrol.DefaultProbeTimeout, "How long to wait for a LINSTOR controller to respond before giving up; 0 disables the check")
+	viper.BindPFlag("linstor.probe-timeout", rootCmd.PersistentFlags().Lookup("probe-timeout"))
+	rootCmd.PersistentFlags().String("linstor-ca", "", "PEM file with the CA certificate to verify the LINSTOR controller with when using HTTPS (env $LINSTOR_GATEWAY_LINSTOR_CA)")
+	viper.BindPFlag("linstor.ca", rootCmd.PersistentFlags().Lookup("linstor-ca"))
+	rootCmd.PersistentFlags().String("linstor-cert", "", "PEM file with the client certificate to authenticate against the LINSTOR controller with (env $LINSTOR_GATEWAY_LINSTOR_CERT)")
+	viper.BindPFlag("linstor.cert", rootCmd.PersistentFlags().Lookup("linstor-cert"))
+	rootCmd.PersistentFlags().String("linstor-key", "", "PEM file with the key of the client certificate given by --linstor-cert (env $LINSTOR_GATEWAY_LINSTOR_KEY)")
+	viper.BindPFlag("linstor.key", rootCmd.PersistentFlags().Lookup("linstor-key"))
+	rootCmd.PersistentFlags().Bool("linstor-insecure-skip-verify", false, "Do not verify the certificate of the LINSTOR controller. Only use this for testing")
+	viper.BindPFlag("linstor.insecure-skip-verify", rootCmd.PersistentFlags().Lookup("linstor-insecure-skip-verify"))
+	viper.BindEnv("linstor.controllers", "LS_CONTROLLERS")
+	viper.BindEnv("linstor.ca", "LINSTOR_GATEWAY_LINSTOR_CA")
+	viper.BindEnv("linstor.cert", "LINSTOR_GATEWAY_LINSTOR_CERT")
+	viper.BindEnv("linstor.key", "LINSTOR_GATEWAY_LINSTOR_KEY")
+	viper.BindEnv("reactor.config-dir", reactorConfigDirEnv)
 	return rootCmd
 }
 
+// linstorControllers returns the list of LINSTOR controllers to connect to.
+// The list is taken from the --controllers flag, the LS_CONTROLLERS
+// environment variable, or the configuration file, in that order. Each entry
+// may itself be a comma-separated list. If none of them is set and
+// --controllers-from-linstor-config is given, the configuration of the linstor
+// client is used.
+// An empty list means that the defaults of the LINSTOR client apply.
+func linstorControllers() ([]string, error) {
+	var controllers []string
+	for _, entry := range viper.GetStringSlice("linstor.controllers") {
+		for _, c := range strings.Split(entry, ",") {
+			c = strings.TrimSpace(c)
+			if c != "" {
+				controllers = append(controllers, c)
+			}
+		}
+	}
+
+	if len(controllers) == 0 && viper.GetBool("linstor.controllers-from-linstor-config") {
+		var err error
+		controllers, err = linstorcontrol.ControllersFromClientConfig(linstorcontrol.ClientConfigPaths)
+		if err != nil {
+			return nil, err
+		}
+		log.WithField("controllers", controllers).Debug("using LINSTOR controllers from the linstor client config")
+	}
+
+	err := linstorcontrol.ValidateControllers(controllers)
+	if err != nil {
+		return nil, err
+	}
+
+	return controllers, nil
+}
+
+var (
+	linstorMu  sync.Mutex
+	linstorCli *linstorcontrol.Linstor
+)
+
+// linstorClient returns the LINSTOR client of this process. It is created on
+// first use and shared by all commands; closeLinstorClient releases it.
+func linstorClient() (*linstorcontrol.Linstor, error) {
+	linstorMu.Lock()
+	defer linstorMu.Unlock()
+
+	if linstorCli != nil {
+		return linstorCli, nil
+	}
+
+	controllers, err := linstorControllers()
+	if err != nil {
+		return nil, err
+	}
+
+	linstorcontrol.DefaultProbeTimeout = viper.GetDuration("linstor.probe-timeout")
+	linstorcontrol.DefaultTLS = linstorcontrol.TLSConfig{
+		CAFile:             viper.GetString("linstor.ca"),
+		CertFile:           viper.GetString("linstor.cert"),
+		KeyFile:            viper.GetString("linstor.key"),
+		InsecureSkipVerify: viper.GetBool("linstor.insecure-skip-verify"),
+	}
+	cli, err := linstorcontrol.Default(controllers)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize LINSTOR client: %w", err)
+	}
+
+	linstorCli = cli
+	return linstorCli, nil
+}
+
+// closeLinstorClient closes the shared LINSTOR client, if it was created.
+func closeLinstorClient() {
+	linstorMu.Lock()
+	defer linstorMu.Unlock()
+
+	if linstorCli != nil {
+		linstorCli.Close()
+		linstorCli = nil
+	}
+}
+
 func initConfig() {
 	viper.SetDefault("linstor.controllers", "")
 	viper.SetConfigType("toml")
@@ -117,8 +282,33 @@ func initConfig() {
 func Execute() {
 	cobra.OnInitialize(initConfig)
 	rootCmd := rootCommand()
-	if err := rootCmd.Execute(); err != nil {
-		fmt.Println(err)
+
+	// interrupting a command cancels all requests it has in flight
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	err := rootCmd.ExecuteContext(ctx)
+	stop()
+	cancelCommand()
+	closeLinstorClient()
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		err = fmt.Errorf("command did not finish within %s: %w", commandTimeout, err)
+	case errors.Is(err, context.Canceled) && ctx.Err() != nil:
+		err = fmt.Errorf("interrupted: %w", err)
+	}
+	if err != nil {
+		printError(err, jsonErrors)
 		os.Exit(1)
 	}
 }
+
+// currentUser returns the name of the OS user running the command, which is
+// reported to the server for its audit log.
+func currentUser() string {
+	u, err := user.Current()
+	if err != nil {
+		log.WithError(err).Debug("failed to look up the current user")
+		return ""
+	}
+
+	return u.Username
+}
@@ -1,6 +1,14 @@
 package cmd
 
 import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/LINBIT/linstor-gateway/pkg/audit"
+	"github.com/LINBIT/linstor-gateway/pkg/common"
+	"github.com/LINBIT/linstor-gateway/pkg/linstorcontrol"
+	"github.com/LINBIT/linstor-gateway/pkg/observe"
 	"github.com/LINBIT/linstor-gateway/pkg/rest"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -8,6 +16,7 @@ import (
 
 func serverCommand() *cobra.Command {
 	var addr string
+	var logTimings bool
 
 	var serverCmd = &cobra.Command{
 		Use:   "server",
@@ -18,17 +27,89 @@ https://app.swaggerhub.com/apis-docs/Linstor/linstor-gateway
 
 For example:
 linstor-gateway server --addr=":8080"`,
-		Args: cobra.NoArgs,
-		Run: func(cmd *cobra.Command, args []string) {
-			controllers := viper.GetStringSlice("linstor.controllers")
-			rest.ListenAndServe(addr, controllers)
+		Args:        cobra.NoArgs,
+		Annotations: map[string]string{annotationNoTimeout: "true"},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			err := setupReactorConfigDir(cmd)
+			if err != nil {
+				return err
+			}
+
+			linstorcontrol.DefaultRetryConfig = linstorcontrol.RetryConfig{
+				Attempts: viper.GetInt("linstor.retry-attempts"),
+				Backoff:  viper.GetDuration("linstor.retry-backoff"),
+			}
+			linstorcontrol.DefaultStartWait.Timeout = viper.GetDuration("reactor.start-timeout")
+			linstorcontrol.DefaultStartWait.MaxTimeout = viper.GetDuration("reactor.start-max-timeout")
+			if linstorcontrol.DefaultStartWait.MaxTimeout < linstorcontrol.DefaultStartWait.Timeout {
+				return errors.New("--start-max-timeout must not be shorter than --start-timeout")
+			}
+			waitTimeout := common.WaitTimeout{
+				Base:    viper.GetDuration("wait.timeout-base"),
+				PerNode: viper.GetDuration("wait.timeout-per-node"),
+				Fixed:   viper.GetDuration("wait.timeout"),
+			}
+			if waitTimeout.Base < 0 || waitTimeout.PerNode < 0 || waitTimeout.Fixed < 0 {
+				return errors.New("--timeout, --timeout-base and --timeout-per-node must not be negative")
+			}
+			if waitTimeout.For(1) == 0 {
+				return errors.New("--timeout-base or --timeout-per-node must be positive")
+			}
+			common.DefaultWaitTimeout = waitTimeout
+
+			// fails if no controller is reachable, unless the probe is disabled
+			lin, err := linstorClient()
+			if err != nil {
+				return err
+			}
+
+			var observer observe.Observer
+			if logTimings {
+				observer = observe.Log{}
+			}
+
+			var auditor *audit.Logger
+			if path := viper.GetString("audit.log"); path != "" {
+				auditor, err = audit.Open(path, viper.GetBool("audit.reads"))
+				if err != nil {
+					return err
+				}
+				defer auditor.Close()
+			}
+
+			rest.WatchInterval = viper.GetDuration("events.interval")
+			if rest.WatchInterval < time.Second {
+				return errors.New("--events-interval must be at least one second")
+			}
+
+			return rest.ListenAndServe(cmd.Context(), addr, lin, observer, auditor)
 		},
 	}
 
 	serverCmd.ResetCommands()
 	serverCmd.Flags().StringVar(&addr, "addr", ":8080", "Host and port as defined by http.ListenAndServe()")
-	serverCmd.Flags().StringSlice("controllers", nil, "List of LINSTOR controllers to try to connect to (default from $LS_CONTROLLERS, or localhost:3370)")
-	viper.BindPFlag("linstor.controllers", serverCmd.Flags().Lookup("controllers"))
+	serverCmd.Flags().Int("retry-attempts", linstorcontrol.DefaultRetryConfig.Attempts, "Number of attempts for LINSTOR calls that fail with a transient error")
+	serverCmd.Flags().Duration("retry-backoff", linstorcontrol.DefaultRetryConfig.Backoff, "Initial wait time between retries of LINSTOR calls, doubled after every attempt")
+	viper.BindPFlag("linstor.retry-attempts", serverCmd.Flags().Lookup("retry-attempts"))
+	viper.BindPFlag("linstor.retry-backoff", serverCmd.Flags().Lookup("retry-backoff"))
+	serverCmd.Flags().Duration("start-timeout", linstorcontrol.DefaultStartWait.Timeout, "How long to wait for the services of a target or export to start. Every failed start that drbd-reactor retries extends the wait by this time")
+	serverCmd.Flags().Duration("start-max-timeout", linstorcontrol.DefaultStartWait.MaxTimeout, "Maximum time to wait for the services of a target or export to start, including retries")
+	viper.BindPFlag("reactor.start-timeout", serverCmd.Flags().Lookup("start-timeout"))
+	viper.BindPFlag("reactor.start-max-timeout", serverCmd.Flags().Lookup("start-max-timeout"))
+	serverCmd.Flags().Duration("timeout-base", common.DefaultWaitTimeout.Base, "How long to wait for a resource to stop or fail over, before adding --timeout-per-node for every node it is deployed on")
+	serverCmd.Flags().Duration("timeout-per-node", common.DefaultWaitTimeout.PerNode, "Time added to --timeout-base for every node a resource is deployed on, as promotion and sync take longer on larger resources")
+	serverCmd.Flags().Duration("timeout", 0, "How long to wait for a resource to stop or fail over, regardless of the number of nodes. Overrides --timeout-base and --timeout-per-node")
+	viper.BindPFlag("wait.timeout-base", serverCmd.Flags().Lookup("timeout-base"))
+	viper.BindPFlag("wait.timeout-per-node", serverCmd.Flags().Lookup("timeout-per-node"))
+	viper.BindPFlag("wait.timeout", serverCmd.Flags().Lookup("timeout"))
+	serverCmd.Flags().String("audit-log", "", fmt.Sprintf("Write an audit record of every request that changes a target or export to this file, or to syslog if set to %q. Each record contains the user reported by the client, the time, the target and the outcome", audit.SinkSyslog))
+	serverCmd.Flags().Bool("audit-reads", false, "Also write audit records for read-only requests")
+	viper.BindPFlag("audit.log", serverCmd.Flags().Lookup("audit-log"))
+	viper.BindPFlag("audit.reads", serverCmd.Flags().Lookup("audit-reads"))
+	serverCmd.Flags().Duration("events-interval", rest.WatchInterval, "Interval in which the state of all targets and exports is polled to stream state changes on the events endpoint")
+	viper.BindPFlag("events.interval", serverCmd.Flags().Lookup("events-interval"))
+	serverCmd.Flags().BoolVar(&logTimings, "log-timings", false, "Log the duration of every request and its steps, e.g. LINSTOR calls, at debug level")
+	addReactorConfigDirFlag(serverCmd)
 	serverCmd.DisableAutoGenTag = true
 
 	return serverCmd
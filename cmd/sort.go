@@ -0,0 +1,157 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/LINBIT/linstor-gateway/pkg/common"
+)
+
+const (
+	sortKeyID        = "id"
+	sortKeyState     = "state"
+	sortKeyServiceIP = "service_ip"
+	sortKeySize      = "size"
+)
+
+var sortKeyNames = []string{sortKeyID, sortKeyState, sortKeyServiceIP, sortKeySize}
+
+// sortKey is a key given with --sort.
+type sortKey struct {
+	name       string
+	descending bool
+}
+
+// sortFields are the values of a target or export that a list can be sorted
+// by.
+type sortFields struct {
+	id        string
+	status    *common.ResourceStatus
+	serviceIP net.IP
+	// sizeKiB is the total size of the volumes, without the cluster
+	// private volume.
+	sizeKiB uint64
+}
+
+// addSortFlag adds the flag to sort the listed resources to a list command.
+// idName is the name of the identifier of the resources, e.g. "iqn", which
+// can be used instead of "id".
+func addSortFlag(cmd *cobra.Command, idName string, keys *[]string) {
+	cmd.Flags().StringSliceVar(keys, "sort", nil, fmt.Sprintf("Comma separated keys to sort by, in order of precedence (any of %s, where %s is the same as %s). Prefix a key with - to reverse the order. Sorting by state lists degraded resources first. Only the listed page is sorted", strings.Join(sortKeyNames, ", "), idName, sortKeyID))
+}
+
+// parseSortKeys parses the value of the flag added by addSortFlag.
+func parseSortKeys(keys []string, idName string) ([]sortKey, error) {
+	parsed := make([]sortKey, 0, len(keys))
+	for _, key := range keys {
+		key = strings.TrimSpace(key)
+		name := strings.TrimPrefix(key, "-")
+		descending := name != key
+		if name == idName {
+			name = sortKeyID
+		}
+
+		known := false
+		for _, n := range sortKeyNames {
+			if n == name {
+				known = true
+				break
+			}
+		}
+
+		if !known {
+			return nil, fmt.Errorf("unknown sort key '%s', expected any of %s, %s", key, strings.Join(sortKeyNames, ", "), idName)
+		}
+
+		parsed = append(parsed, sortKey{name: name, descending: descending})
+	}
+
+	return parsed, nil
+}
+
+// sortOrder returns the indexes of the resources with the given fields in the
+// order given by keys. Resources that are equal in all keys keep their order.
+func sortOrder(fields []sortFields, keys []sortKey) []int {
+	order := make([]int, len(fields))
+	for i := range order {
+		order[i] = i
+	}
+
+	sort.SliceStable(order, func(i, j int) bool {
+		a, b := &fields[order[i]], &fields[order[j]]
+		for _, key := range keys {
+			c := compareSortFields(a, b, key.name)
+			if key.descending {
+				c = -c
+			}
+			if c != 0 {
+				return c < 0
+			}
+		}
+
+		return false
+	})
+
+	return order
+}
+
+func compareSortFields(a, b *sortFields, key string) int {
+	switch key {
+	case sortKeyID:
+		return strings.Compare(a.id, b.id)
+	case sortKeyState:
+		return stateRank(a.status) - stateRank(b.status)
+	case sortKeyServiceIP:
+		return bytes.Compare(a.serviceIP.To16(), b.serviceIP.To16())
+	case sortKeySize:
+		switch {
+		case a.sizeKiB < b.sizeKiB:
+			return -1
+		case a.sizeKiB > b.sizeKiB:
+			return 1
+		}
+	}
+
+	return 0
+}
+
+// stateRank orders resources by their state, most severe first, so that
+// resources that need attention are listed first.
+func stateRank(status *common.ResourceStatus) int {
+	var rank int
+	switch status.State {
+	case common.ResourceStateBad:
+		rank = 0
+	case common.ResourceStateDegraded:
+		rank = 1
+	case common.Unknown:
+		rank = 2
+	default:
+		rank = 3
+	}
+
+	// e.g. a stopped service or missing replicas
+	if !status.Degraded() {
+		rank += 4
+	}
+
+	return rank
+}
+
+// totalSize returns the total size of the given volumes, without the cluster
+// private volume.
+func totalSize(volumes []common.VolumeConfig) uint64 {
+	var size uint64
+	for _, vol := range volumes {
+		if vol.Number != 0 {
+			size += vol.SizeKiB
+		}
+	}
+
+	return size
+}
@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/LINBIT/linstor-gateway/pkg/common"
+)
+
+const (
+	stateFilterAll      = ""
+	stateFilterOK       = "ok"
+	stateFilterDegraded = "degraded"
+)
+
+// addStateFilterFlag adds the flag to only list resources in a certain state
+// to a list command.
+func addStateFilterFlag(cmd *cobra.Command, state *string) {
+	cmd.Flags().StringVar(state, "state", stateFilterAll, fmt.Sprintf("Only list resources in this state (one of %s, %s). A resource is degraded if its service is not started, replicas are missing, or any of its volumes is not OK", stateFilterOK, stateFilterDegraded))
+}
+
+func checkStateFilter(state string) error {
+	switch state {
+	case stateFilterAll, stateFilterOK, stateFilterDegraded:
+		return nil
+	default:
+		return fmt.Errorf("invalid state '%s', expected one of %s, %s", state, stateFilterOK, stateFilterDegraded)
+	}
+}
+
+// matchesStateFilter reports whether a resource with the given status is
+// selected by the value of the flag added by addStateFilterFlag.
+func matchesStateFilter(state string, status *common.ResourceStatus) bool {
+	switch state {
+	case stateFilterOK:
+		return !status.Degraded()
+	case stateFilterDegraded:
+		return status.Degraded()
+	default:
+		return true
+	}
+}
@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/LINBIT/linstor-gateway/pkg/common"
+)
+
+// warnVolumes logs hints on how to deal with unhealthy volumes. A volume that
+// is only degraded because of a running resync does not need any intervention.
+func warnVolumes(counts common.Counts) {
+	if counts.ResyncingVolumes > 0 {
+		log.Infof("Some resources are being resynchronized. They will become healthy once the resync is finished.")
+	}
+
+	if counts.DegradedVolumes > 0 {
+		log.Warnf("Some resources are degraded. Run %s for possible solutions.", bold("linstor advise resource"))
+	}
+}
+
+// warnInconsistent logs a hint on how to deal with a target or export whose
+// LINSTOR resource and drbd-reactor config disagree. remedy explains how to
+// fix it.
+func warnInconsistent(name string, status common.ResourceStatus, remedy string) {
+	if status.Inconsistent == "" {
+		return
+	}
+
+	log.Warnf("\"%s\" is %s. %s", name, status.Inconsistent, remedy)
+}
+
+// warnReplicas logs a warning if fewer diskful replicas of a target or export
+// are deployed than its placement count requests.
+func warnReplicas(name string, status common.ResourceStatus) {
+	if status.MissingReplicas() == 0 {
+		return
+	}
+
+	log.Warnf("\"%s\" has only %s replicas deployed. Check that enough satellites are online and have free space in the storage pools. Run %s for possible solutions.", name, formatReplicas(status), bold("linstor advise resource"))
+}
+
+// iscsiRepairRemedy is the remedy for an inconsistent iSCSI target.
+func iscsiRepairRemedy(iqn string) string {
+	return fmt.Sprintf("Run %s to regenerate the drbd-reactor config from LINSTOR.", bold("linstor-gateway iscsi repair "+iqn))
+}
+
+// recreateRemedy is the remedy for an inconsistent target or export that can
+// not be repaired automatically.
+const recreateRemedy = "Delete the volumes that are missing from the drbd-reactor config in LINSTOR, or recreate it."
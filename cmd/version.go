@@ -1,29 +1,147 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
 
+	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
 
+// versionLookupTimeout limits how long the version command waits for the
+// LINSTOR controller and drbd-reactor.
+const versionLookupTimeout = 5 * time.Second
+
+// versionInfo describes the versions of LINSTOR Gateway and the components
+// it depends on. Versions that could not be determined are left empty.
+type versionInfo struct {
+	Version                 string `json:"version"`
+	BuildDate               string `json:"build_date"`
+	GitHash                 string `json:"git_hash"`
+	GoVersion               string `json:"go_version"`
+	Platform                string `json:"platform"`
+	LinstorVersion          string `json:"linstor_version,omitempty"`
+	LinstorRestVersion      string `json:"linstor_rest_api_version,omitempty"`
+	DrbdReactorVersion      string `json:"drbd_reactor_version,omitempty"`
+	LinstorVersionError     string `json:"linstor_version_error,omitempty"`
+	DrbdReactorVersionError string `json:"drbd_reactor_version_error,omitempty"`
+}
+
 func versionCommand() *cobra.Command {
-	return &cobra.Command{
+	var output string
+
+	cmd := &cobra.Command{
 		Use:   "version",
 		Short: "Print version information of LINSTOR Gateway",
-		Run: func(cmd *cobra.Command, args []string) {
-			if version == "" {
-				version = "DEV"
+		Long: `Print version information of LINSTOR Gateway and the Go version it was built
+with.
+
+If LINSTOR controllers are configured, the version of the LINSTOR controller
+is shown as well. The version of drbd-reactor is taken from the local
+installation. Both are best-effort: if they cannot be determined, the rest is
+still shown.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			err := checkOutputFormat(output)
+			if err != nil {
+				return err
 			}
-			if builddate == "" {
-				builddate = "DEV"
+
+			info := versionInfo{
+				Version:   orDev(version),
+				BuildDate: orDev(builddate),
+				GitHash:   orDev(githash),
+				GoVersion: runtime.Version(),
+				Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+			}
+
+			ctx, cancel := context.WithTimeout(cmd.Context(), versionLookupTimeout)
+			defer cancel()
+
+			controllers, err := linstorControllers()
+			if err == nil && len(controllers) > 0 {
+				info.LinstorVersion, info.LinstorRestVersion, err = linstorVersion(ctx)
 			}
-			if githash == "" {
-				githash = "DEV"
+			if err != nil {
+				log.WithError(err).Debug("failed to query LINSTOR controller version")
+				info.LinstorVersionError = err.Error()
 			}
-			fmt.Printf("LINSTOR Gateway version %s\n", version)
-			fmt.Printf("Built at %s\n", builddate)
-			fmt.Printf("Version control hash: %s\n", githash)
 
+			info.DrbdReactorVersion, err = drbdReactorVersion(ctx)
+			if err != nil {
+				log.WithError(err).Debug("failed to query drbd-reactor version")
+				info.DrbdReactorVersionError = err.Error()
+			}
+
+			if output == outputJSON {
+				return printJSON(info)
+			}
+
+			fmt.Printf("LINSTOR Gateway version %s\n", info.Version)
+			fmt.Printf("Built at %s\n", info.BuildDate)
+			fmt.Printf("Version control hash: %s\n", info.GitHash)
+			fmt.Printf("Go version: %s (%s)\n", info.GoVersion, info.Platform)
+			if info.LinstorVersionError != "" {
+				fmt.Printf("LINSTOR controller version: unknown (%s)\n", info.LinstorVersionError)
+			} else if info.LinstorVersion != "" {
+				fmt.Printf("LINSTOR controller version: %s (REST API %s)\n", info.LinstorVersion, info.LinstorRestVersion)
+			}
+			if info.DrbdReactorVersionError != "" {
+				fmt.Printf("drbd-reactor version: unknown (%s)\n", info.DrbdReactorVersionError)
+			} else {
+				fmt.Printf("drbd-reactor version: %s\n", info.DrbdReactorVersion)
+			}
+
+			return nil
 		},
 	}
+
+	addOutputFlag(cmd, &output)
+
+	return cmd
+}
+
+// orDev returns s, or "DEV" if it was not injected at build time.
+func orDev(s string) string {
+	if s == "" {
+		return "DEV"
+	}
+	return s
+}
+
+// linstorVersion returns the version and the REST API version of the
+// configured LINSTOR controller.
+func linstorVersion(ctx context.Context) (string, string, error) {
+	lin, err := linstorClient()
+	if err != nil {
+		return "", "", err
+	}
+
+	v, err := lin.Controller.GetVersion(ctx)
+	if err != nil {
+		return "", "", err
+	}
+
+	return v.Version, v.RestApiVersion, nil
+}
+
+// drbdReactorVersion returns the version of the drbd-reactor installed on
+// this machine.
+func drbdReactorVersion(ctx context.Context) (string, error) {
+	path, err := exec.LookPath("drbd-reactor")
+	if err != nil {
+		return "", fmt.Errorf("drbd-reactor is not installed")
+	}
+
+	out, err := exec.CommandContext(ctx, path, "--version").Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to run %s --version: %w", path, err)
+	}
+
+	// the output looks like "drbd-reactor 1.0.0"
+	return strings.TrimPrefix(strings.TrimSpace(string(out)), "drbd-reactor "), nil
 }
@@ -0,0 +1,29 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/rck/unit"
+	"github.com/spf13/cobra"
+)
+
+// parseVolumeSize parses a volume size such as "2G" and returns it in KiB.
+// Sizes that would be rounded down to 0 KiB are rejected.
+func parseVolumeSize(raw string) (uint64, error) {
+	val, err := unit.MustNewUnit(unit.DefaultUnits).ValueFromString(raw)
+	if err != nil {
+		return 0, err
+	}
+
+	if val.Value < unit.K {
+		return 0, fmt.Errorf("invalid volume size '%s': must be at least 1KiB", raw)
+	}
+
+	return uint64(val.Value / unit.K), nil
+}
+
+// addSkipCapacityCheckFlag adds the flag to skip checking the free space of
+// the storage pools to a create command.
+func addSkipCapacityCheckFlag(cmd *cobra.Command, skip *bool) {
+	cmd.Flags().BoolVar(skip, "skip-capacity-check", false, "Do not check that the storage pools of the resource group have enough free space. Useful for thin pools, whose reported free space does not include overprovisioning")
+}
@@ -0,0 +1,174 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+
+	"github.com/LINBIT/linstor-gateway/pkg/common"
+)
+
+const (
+	volumeSortName = "name"
+	volumeSortSize = "size"
+)
+
+// flatVolume is a single exported volume of any target or export, as shown
+// by "volumes list".
+type flatVolume struct {
+	Target  string               `json:"target"`
+	Type    string               `json:"type"`
+	Number  int                  `json:"number"`
+	SizeKiB uint64               `json:"size_kib"`
+	State   common.ResourceState `json:"state"`
+	Service common.ServiceState  `json:"service_state"`
+}
+
+// appendFlatVolumes appends the volumes of a target or export to vols,
+// skipping the cluster private volume.
+func appendFlatVolumes(vols []flatVolume, target, typ string, volumes []common.VolumeConfig, status common.ResourceStatus) []flatVolume {
+	states := make(map[int]common.VolumeState, len(status.Volumes))
+	for _, vol := range status.Volumes {
+		states[vol.Number] = vol
+	}
+
+	for _, vol := range volumes {
+		if vol.Number == 0 {
+			continue
+		}
+
+		vols = append(vols, flatVolume{
+			Target:  target,
+			Type:    typ,
+			Number:  vol.Number,
+			SizeKiB: vol.SizeKiB,
+			State:   states[vol.Number].State,
+			Service: status.Service,
+		})
+	}
+
+	return vols
+}
+
+func volumesCommands() *cobra.Command {
+	var rootCmd = &cobra.Command{
+		Use:     "volumes",
+		Version: version,
+		Short:   "Shows the volumes of all targets and exports",
+		Args:    cobra.NoArgs,
+	}
+
+	rootCmd.DisableAutoGenTag = true
+
+	rootCmd.AddCommand(listVolumesCommand())
+
+	return rootCmd
+}
+
+func listVolumesCommand() *cobra.Command {
+	var output, sortBy string
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "Lists the volumes of all iSCSI targets, NFS exports and NVMe-oF targets",
+		Long: `Lists the volumes of all iSCSI targets, NFS exports and NVMe-oF targets in a
+single table, e.g. for capacity planning. The cluster private volumes are not
+shown.`,
+		Example: "linstor-gateway volumes list\nlinstor-gateway volumes list --sort size -o json",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			err := checkOutputFormat(output)
+			if err != nil {
+				return err
+			}
+
+			if sortBy != volumeSortName && sortBy != volumeSortSize {
+				return fmt.Errorf("invalid sort order '%s', expected one of %s, %s", sortBy, volumeSortName, volumeSortSize)
+			}
+
+			iscsiCfgs, err := cli.Iscsi.GetAll(cmd.Context())
+			if err != nil {
+				return err
+			}
+
+			nfsCfgs, err := cli.Nfs.GetAll(cmd.Context())
+			if err != nil {
+				return err
+			}
+
+			nvmeCfgs, err := cli.NvmeOf.GetAll(cmd.Context())
+			if err != nil {
+				return err
+			}
+
+			vols := make([]flatVolume, 0)
+			for _, cfg := range iscsiCfgs {
+				vols = appendFlatVolumes(vols, cfg.IQN.String(), "iscsi", cfg.Volumes, cfg.Status)
+			}
+			for _, cfg := range nfsCfgs {
+				volumes := make([]common.VolumeConfig, len(cfg.Volumes))
+				for i := range cfg.Volumes {
+					volumes[i] = cfg.Volumes[i].VolumeConfig
+				}
+				vols = appendFlatVolumes(vols, cfg.Name, "nfs", volumes, cfg.Status)
+			}
+			for _, cfg := range nvmeCfgs {
+				vols = appendFlatVolumes(vols, cfg.NQN.String(), "nvme", cfg.Volumes, cfg.Status)
+			}
+
+			if sortBy == volumeSortSize {
+				sort.SliceStable(vols, func(i, j int) bool {
+					return vols[i].SizeKiB > vols[j].SizeKiB
+				})
+			}
+
+			if output == outputJSON {
+				return printJSON(vols)
+			}
+
+			header := []string{"Target", "Type", "Volume", "Size", "Service state", "LINSTOR state"}
+			table := tablewriter.NewWriter(os.Stdout)
+			table.SetHeader(header)
+			table.SetHeaderColor(headerColors(len(header))...)
+			table.SetAutoFormatHeaders(false)
+
+			var total uint64
+			for _, vol := range vols {
+				row := []string{vol.Target, vol.Type, strconv.Itoa(vol.Number), common.FormatSize(vol.SizeKiB), vol.Service.String(), vol.State.String()}
+				colors := []tablewriter.Colors{{}, {}, {}, {}, ServiceStateColor(vol.Service), ResourceStateColor(vol.State)}
+				table.Rich(row, colors)
+				total += vol.SizeKiB
+			}
+
+			table.SetFooter([]string{"", "", "Total", common.FormatSize(total), "", ""})
+			table.Render()
+
+			return nil
+		},
+	}
+
+	addOutputFlag(cmd, &output)
+	cmd.Flags().StringVar(&sortBy, "sort", volumeSortName, fmt.Sprintf("Sort order of the volumes (one of %s, %s). By default, volumes are grouped by target; %s lists the largest volumes first", volumeSortName, volumeSortSize, volumeSortSize))
+
+	return cmd
+}
+
+// numbered returns the noun followed by the given numbers, e.g. "volume 3"
+// or "volumes 3, 5".
+func numbered(noun string, nrs []int) string {
+	strs := make([]string, 0, len(nrs))
+	for _, nr := range nrs {
+		strs = append(strs, strconv.Itoa(nr))
+	}
+
+	if len(nrs) != 1 {
+		noun += "s"
+	}
+
+	return noun + " " + strings.Join(strs, ", ")
+}
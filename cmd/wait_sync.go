@@ -0,0 +1,24 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/LINBIT/linstor-gateway/pkg/common"
+)
+
+// waitSyncFlags are the values of the flags added by addWaitSyncFlags.
+type waitSyncFlags struct {
+	wait    bool
+	timeout time.Duration
+}
+
+// addWaitSyncFlags adds the flags to wait until all replicas of a new resource
+// are in sync to a create command. kind is the name of the resource, e.g.
+// "target".
+func addWaitSyncFlags(cmd *cobra.Command, kind string, flags *waitSyncFlags) {
+	cmd.Flags().BoolVar(&flags.wait, "wait-sync", false, fmt.Sprintf("Wait until all replicas of the %s are UpToDate, so that it is redundant once the command returns. If they are still syncing after --sync-timeout, the command fails, but the %s is kept", kind, kind))
+	cmd.Flags().DurationVar(&flags.timeout, "sync-timeout", common.DefaultSyncTimeout, "How long to wait for the replicas to be in sync with --wait-sync")
+}
@@ -11,6 +11,6 @@ first.run([
     'linstor-gateway', 'iscsi', 'create', 'iqn.2019-08.com.linbit:iscsi1',
     '192.168.122.220/24', '1G',
 ])
-first.run(['linstor-gateway', 'iscsi', 'delete', 'iqn.2019-08.com.linbit:iscsi1'])
+first.run(['linstor-gateway', 'iscsi', 'delete', '--yes', 'iqn.2019-08.com.linbit:iscsi1'])
 
 nodes.cleanup()
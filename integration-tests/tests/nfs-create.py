@@ -8,6 +8,6 @@ first = nodes[0]
 first.start_server()
 
 first.run(['linstor-gateway', 'nfs', 'create', 'nfs1', '192.168.122.221/24', '1G'])
-first.run(['linstor-gateway', 'nfs', 'delete', 'nfs1'])
+first.run(['linstor-gateway', 'nfs', 'delete', '--yes', 'nfs1'])
 
 nodes.cleanup()
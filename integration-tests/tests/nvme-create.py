@@ -11,6 +11,6 @@ first.run([
     'linstor-gateway', 'nvme', 'create', 'nqn.2021-08.com.linbit:nvme:nvme1',
     '192.168.122.222/24', '1G',
 ])
-first.run(['linstor-gateway', 'nvme', 'delete', 'nqn.2021-08.com.linbit:nvme:nvme1'])
+first.run(['linstor-gateway', 'nvme', 'delete', '--yes', 'nqn.2021-08.com.linbit:nvme:nvme1'])
 
 nodes.cleanup()
@@ -31,6 +31,7 @@ LINSTOR Gateway exposes highly available LINSTOR storage via iSCSI, NFS, or NVMe
 mkdir -p %{buildroot}/%{_sbindir}/
 cp %{_builddir}/%{name}-%{tarball_version}/%{name} %{buildroot}/%{_sbindir}/
 install -D -m 644 %{name}.service %{buildroot}%{_unitdir}/%{name}.service
+install -D -m 644 %{name}-mkdir@.service %{buildroot}%{_unitdir}/%{name}-mkdir@.service
 install -D -m 644 %{name}.xml %{buildroot}%{_firewalldir}/services/%{name}.xml
 
 %post
@@ -47,6 +48,7 @@ install -D -m 644 %{name}.xml %{buildroot}%{_firewalldir}/services/%{name}.xml
 %defattr(-,root,root)
 	%{_sbindir}/%{name}
 	%{_unitdir}/%{name}.service
+	%{_unitdir}/%{name}-mkdir@.service
 	%dir %{_firewalldir}
 	%dir %{_firewalldir}/services
 	%{_firewalldir}/services/%{name}.xml
@@ -0,0 +1,105 @@
+// Package audit records who changed which target or export when, and whether
+// the change succeeded.
+//
+// Records are written as JSON, one per line, to a file or to syslog. Only
+// mutating operations are audited, unless read-only operations are enabled
+// explicitly.
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/syslog"
+	"os"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+)
+
+// SinkSyslog selects syslog as the sink of a Logger.
+const SinkSyslog = "syslog"
+
+// Record describes a single operation.
+type Record struct {
+	Time time.Time `json:"time"`
+	// User is the user reported by the client. It is not authenticated.
+	User       string `json:"user,omitempty"`
+	RemoteAddr string `json:"remote_addr,omitempty"`
+	// Operation is the method and route of the request, e.g.
+	// "POST /api/v2/iscsi/{iqn}/start".
+	Operation string `json:"operation"`
+	// Target is the IQN, NQN or name of the target or export.
+	Target string `json:"target,omitempty"`
+	// Params are the other parameters of the route, e.g. the LUN.
+	Params   map[string]string `json:"params,omitempty"`
+	Status   int               `json:"status"`
+	Outcome  string            `json:"outcome"`
+	Duration time.Duration     `json:"duration"`
+}
+
+// Logger writes audit records to a file or to syslog. A nil Logger discards
+// all records.
+type Logger struct {
+	// Reads also audits read-only operations.
+	Reads bool
+
+	mu sync.Mutex
+	w  io.WriteCloser
+}
+
+// Open returns a Logger that writes to the file at path, which is created if
+// it does not exist, or to syslog if path is SinkSyslog.
+func Open(path string, reads bool) (*Logger, error) {
+	var w io.WriteCloser
+	var err error
+	if path == SinkSyslog {
+		w, err = syslog.New(syslog.LOG_INFO|syslog.LOG_DAEMON, "linstor-gateway-audit")
+	} else {
+		w, err = os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log %s: %w", path, err)
+	}
+
+	return &Logger{Reads: reads, w: w}, nil
+}
+
+// Log writes a record. Failures are logged, but do not fail the operation.
+func (l *Logger) Log(r Record) {
+	if l == nil {
+		return
+	}
+
+	b, err := json.Marshal(r)
+	if err != nil {
+		log.WithError(err).Warn("failed to encode audit record")
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	_, err = l.w.Write(append(b, '\n'))
+	if err != nil {
+		log.WithError(err).WithField("record", string(b)).Warn("failed to write audit record")
+	}
+}
+
+// Close closes the file or the connection to syslog.
+func (l *Logger) Close() error {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.w.Close()
+}
@@ -0,0 +1,63 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogger(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "audit.log")
+	records := []Record{{
+		Time:      time.Date(2022, 8, 1, 12, 0, 0, 0, time.UTC),
+		User:      "alice",
+		Operation: "POST /api/v2/iscsi/{iqn}/start",
+		Target:    "iqn.2019-08.com.linbit:example",
+		Status:    200,
+		Outcome:   OutcomeSuccess,
+	}, {
+		Time:      time.Date(2022, 8, 1, 12, 1, 0, 0, time.UTC),
+		Operation: "DELETE /api/v2/nfs/{resource}/{id}",
+		Target:    "example",
+		Params:    map[string]string{"id": "1"},
+		Status:    409,
+		Outcome:   OutcomeFailure,
+	}}
+
+	// records are appended to an existing log
+	for i := range records {
+		logger, err := Open(path, false)
+		require.NoError(t, err)
+		logger.Log(records[i])
+		assert.NoError(t, logger.Close())
+	}
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	var actual []Record
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var r Record
+		assert.NoError(t, json.Unmarshal(scanner.Bytes(), &r))
+		actual = append(actual, r)
+	}
+	assert.Equal(t, records, actual)
+}
+
+func TestLogger_Nil(t *testing.T) {
+	t.Parallel()
+
+	var logger *Logger
+	logger.Log(Record{Operation: "POST /api/v2/iscsi"})
+	assert.NoError(t, logger.Close())
+}
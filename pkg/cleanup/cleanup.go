@@ -0,0 +1,286 @@
+// Package cleanup finds and removes leftovers of failed or interrupted
+// operations: LINSTOR resources that look like they were created by LINSTOR
+// Gateway but have no drbd-reactor config, and drbd-reactor configs whose
+// LINSTOR resource is gone.
+package cleanup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/LINBIT/golinstor/client"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/LINBIT/linstor-gateway/pkg/common"
+	"github.com/LINBIT/linstor-gateway/pkg/linstorcontrol"
+	"github.com/LINBIT/linstor-gateway/pkg/reactor"
+)
+
+// Kind is the kind of an orphan.
+type Kind string
+
+const (
+	// KindResource is a LINSTOR resource without a drbd-reactor config.
+	KindResource Kind = "resource"
+	// KindConfig is a drbd-reactor config without a LINSTOR resource.
+	KindConfig Kind = "config"
+)
+
+// Orphan is a LINSTOR resource or drbd-reactor config that is not part of
+// any target or export.
+type Orphan struct {
+	Kind Kind `json:"kind"`
+	// Name is the name of the LINSTOR resource, or the ID of the
+	// drbd-reactor config.
+	Name string `json:"name"`
+	// Resource is the name of the missing LINSTOR resource of a config.
+	Resource string `json:"resource,omitempty"`
+	// Path is the path of a config.
+	Path string `json:"path,omitempty"`
+}
+
+// Find returns the orphaned LINSTOR resources and drbd-reactor configs.
+//
+// A LINSTOR resource is only considered if it has the cluster private volume
+// that LINSTOR Gateway creates. Resources that were kept when their target
+// or export was deleted are not orphans.
+func Find(ctx context.Context, cli *linstorcontrol.Linstor) ([]Orphan, error) {
+	definitions, configs, paths, err := list(ctx, cli)
+	if err != nil {
+		return nil, err
+	}
+
+	return findOrphans(definitions, configs, paths), nil
+}
+
+func list(ctx context.Context, cli *linstorcontrol.Linstor) ([]client.ResourceDefinitionWithVolumeDefinition, []reactor.PromoterConfig, []string, error) {
+	definitions, err := cli.ResourceDefinitions.GetAll(ctx, client.RDGetAllRequest{WithVolumeDefinitions: true})
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to list resource definitions: %w", err)
+	}
+
+	configs, paths, err := reactor.ListConfigs(ctx, cli.Client)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to list configs: %w", err)
+	}
+
+	return definitions, configs, paths, nil
+}
+
+// ErrAllOrphaned is returned when every LINSTOR resource created by LINSTOR
+// Gateway looks orphaned. This usually means that the drbd-reactor configs
+// were looked for in the wrong directory, so nothing is deleted.
+var ErrAllOrphaned = errors.New("none of the LINSTOR resources created by LINSTOR Gateway has a drbd-reactor config")
+
+// CheckDelete checks that the orphans can be deleted safely. It returns
+// ErrAllOrphaned if no LINSTOR resource created by LINSTOR Gateway has a
+// config, as the configs are only found in reactor.ConfigDir. Such
+// resources must be deleted manually.
+func CheckDelete(ctx context.Context, cli *linstorcontrol.Linstor) error {
+	definitions, configs, _, err := list(ctx, cli)
+	if err != nil {
+		return err
+	}
+
+	if allOrphaned(definitions, configs) {
+		return fmt.Errorf("%w in %s, check that it is the right directory", ErrAllOrphaned, reactor.ConfigDir)
+	}
+
+	return nil
+}
+
+// allOrphaned reports whether there are LINSTOR resources created by LINSTOR
+// Gateway, but none of them has a config.
+func allOrphaned(definitions []client.ResourceDefinitionWithVolumeDefinition, configs []reactor.PromoterConfig) bool {
+	configured := configuredResources(configs)
+
+	found := false
+	for i := range definitions {
+		def := &definitions[i]
+		if linstorcontrol.Kept(&def.ResourceDefinition) || !fromGateway(def.VolumeDefinitions) {
+			continue
+		}
+
+		if configured[def.Name] {
+			return false
+		}
+
+		found = true
+	}
+
+	return found
+}
+
+func findOrphans(definitions []client.ResourceDefinitionWithVolumeDefinition, configs []reactor.PromoterConfig, paths []string) []Orphan {
+	configured := configuredResources(configs)
+
+	exists := make(map[string]bool)
+	var orphans []Orphan
+	for i := range definitions {
+		def := &definitions[i]
+		exists[def.Name] = true
+
+		if configured[def.Name] || linstorcontrol.Kept(&def.ResourceDefinition) || !fromGateway(def.VolumeDefinitions) {
+			continue
+		}
+
+		orphans = append(orphans, Orphan{Kind: KindResource, Name: def.Name})
+	}
+
+	for i := range configs {
+		for name := range configs[i].Resources {
+			if exists[name] {
+				continue
+			}
+
+			orphans = append(orphans, Orphan{Kind: KindConfig, Name: configs[i].ID, Resource: name, Path: paths[i]})
+		}
+	}
+
+	sort.SliceStable(orphans, func(i, j int) bool {
+		if orphans[i].Kind != orphans[j].Kind {
+			return orphans[i].Kind == KindResource
+		}
+		return orphans[i].Name < orphans[j].Name
+	})
+
+	return orphans
+}
+
+// configuredResources returns the names of the LINSTOR resources that have
+// a config.
+func configuredResources(configs []reactor.PromoterConfig) map[string]bool {
+	configured := make(map[string]bool)
+	for i := range configs {
+		for name := range configs[i].Resources {
+			configured[name] = true
+		}
+	}
+
+	return configured
+}
+
+// configLockName returns the name that operations on the target or export
+// with the given config ID lock. Config IDs are the protocol followed by that
+// name, e.g. "iscsi-<wwn>". It differs from the LINSTOR resource name if an
+// export was renamed or a target uses a custom resource name.
+func configLockName(id string) string {
+	_, name, found := strings.Cut(id, "-")
+	if !found {
+		return id
+	}
+
+	return name
+}
+
+// fromGateway reports whether a resource with the given volume definitions
+// was created by LINSTOR Gateway.
+func fromGateway(volumes []client.VolumeDefinition) bool {
+	for i := range volumes {
+		if common.IsClusterPrivateVolume(volumes[i]) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Delete removes the orphans. Every orphan is checked again while its
+// resource is locked, and skipped if it has been adopted in the meantime or
+// if its resource is in use. Nothing is removed if CheckDelete fails.
+//
+// It returns the orphans that were removed.
+func Delete(ctx context.Context, cli *linstorcontrol.Linstor, orphans []Orphan) ([]Orphan, error) {
+	err := CheckDelete(ctx, cli)
+	if err != nil {
+		return nil, err
+	}
+
+	var deleted []Orphan
+	for _, orphan := range orphans {
+		ok, err := deleteOrphan(ctx, cli, orphan)
+		if err != nil {
+			return deleted, fmt.Errorf("failed to delete %s '%s': %w", orphan.Kind, orphan.Name, err)
+		}
+
+		if ok {
+			deleted = append(deleted, orphan)
+		}
+	}
+
+	return deleted, nil
+}
+
+func deleteOrphan(ctx context.Context, cli *linstorcontrol.Linstor, orphan Orphan) (bool, error) {
+	rscName := orphan.Name
+	if orphan.Kind == KindConfig {
+		rscName = orphan.Resource
+	}
+
+	ctx, unlock, err := cli.Lock(ctx, rscName)
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+
+	if orphan.Kind == KindConfig {
+		var unlockConfig func()
+		ctx, unlockConfig, err = cli.Lock(ctx, configLockName(orphan.Name))
+		if err != nil {
+			return false, err
+		}
+		defer unlockConfig()
+	}
+
+	current, err := Find(ctx, cli)
+	if err != nil {
+		return false, err
+	}
+
+	logger := log.WithFields(log.Fields{"kind": orphan.Kind, "name": orphan.Name})
+	if !contains(current, orphan) {
+		logger.Info("not an orphan anymore, skipping")
+		return false, nil
+	}
+
+	if orphan.Kind == KindConfig {
+		err := reactor.DeleteConfig(ctx, cli.Client, orphan.Name)
+		if err != nil {
+			return false, err
+		}
+
+		logger.Info("deleted config")
+		return true, nil
+	}
+
+	resources, err := cli.Resources.GetResourceView(ctx, &client.ListOpts{Resource: []string{rscName}})
+	if err != nil {
+		return false, fmt.Errorf("failed to fetch resources: %w", err)
+	}
+
+	if common.AnyResourcesInUse(resources) {
+		logger.Warn("resource is in use, skipping")
+		return false, nil
+	}
+
+	err = cli.ResourceDefinitions.Delete(ctx, rscName)
+	if err != nil && err != client.NotFoundError {
+		return false, err
+	}
+
+	logger.Info("deleted resource")
+	return true, nil
+}
+
+func contains(orphans []Orphan, orphan Orphan) bool {
+	for _, o := range orphans {
+		if o == orphan {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,130 @@
+package cleanup
+
+import (
+	"testing"
+
+	apiconsts "github.com/LINBIT/golinstor"
+	"github.com/LINBIT/golinstor/client"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/LINBIT/linstor-gateway/pkg/reactor"
+)
+
+func definition(name string, gateway bool, props map[string]string) client.ResourceDefinitionWithVolumeDefinition {
+	zero := int32(0)
+	vd := client.VolumeDefinition{VolumeNumber: &zero, SizeKib: 1024 * 1024}
+	if gateway {
+		vd.SizeKib = 64 * 1024
+		vd.Props = map[string]string{apiconsts.NamespcFilesystem + "/Type": "ext4"}
+	}
+
+	return client.ResourceDefinitionWithVolumeDefinition{
+		ResourceDefinition: client.ResourceDefinition{Name: name, Props: props},
+		VolumeDefinitions:  []client.VolumeDefinition{vd},
+	}
+}
+
+func config(id, resource string) reactor.PromoterConfig {
+	return reactor.PromoterConfig{ID: id, Resources: map[string]reactor.PromoterResourceConfig{resource: {}}}
+}
+
+func TestFindOrphans(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name        string
+		definitions []client.ResourceDefinitionWithVolumeDefinition
+		configs     []reactor.PromoterConfig
+		expected    []Orphan
+	}{{
+		name:        "no orphans",
+		definitions: []client.ResourceDefinitionWithVolumeDefinition{definition("res1", true, nil)},
+		configs:     []reactor.PromoterConfig{config("iscsi-res1", "res1")},
+	}, {
+		name: "resource without config",
+		definitions: []client.ResourceDefinitionWithVolumeDefinition{
+			definition("res2", true, nil),
+			definition("res1", true, nil),
+		},
+		expected: []Orphan{{Kind: KindResource, Name: "res1"}, {Kind: KindResource, Name: "res2"}},
+	}, {
+		name:        "resource not from gateway",
+		definitions: []client.ResourceDefinitionWithVolumeDefinition{definition("other", false, nil)},
+	}, {
+		name:        "kept resource",
+		definitions: []client.ResourceDefinitionWithVolumeDefinition{definition("res1", true, map[string]string{"Aux/linstor-gateway/kept": "True"})},
+	}, {
+		name:    "config without resource",
+		configs: []reactor.PromoterConfig{config("nfs-res1", "res1")},
+		expected: []Orphan{
+			{Kind: KindConfig, Name: "nfs-res1", Resource: "res1", Path: "/etc/drbd-reactor.d/linstor-gateway-nfs-res1.toml"},
+		},
+	}, {
+		name:        "both",
+		definitions: []client.ResourceDefinitionWithVolumeDefinition{definition("res2", true, nil)},
+		configs:     []reactor.PromoterConfig{config("nfs-res1", "res1")},
+		expected: []Orphan{
+			{Kind: KindResource, Name: "res2"},
+			{Kind: KindConfig, Name: "nfs-res1", Resource: "res1", Path: "/etc/drbd-reactor.d/linstor-gateway-nfs-res1.toml"},
+		},
+	}}
+
+	for i := range cases {
+		tcase := &cases[i]
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+			paths := make([]string, len(tcase.configs))
+			for j := range tcase.configs {
+				paths[j] = "/etc/drbd-reactor.d/linstor-gateway-" + tcase.configs[j].ID + ".toml"
+			}
+
+			actual := findOrphans(tcase.definitions, tcase.configs, paths)
+			assert.Equal(t, tcase.expected, actual)
+		})
+	}
+}
+
+func TestAllOrphaned(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name        string
+		definitions []client.ResourceDefinitionWithVolumeDefinition
+		configs     []reactor.PromoterConfig
+		expected    bool
+	}{{
+		name: "no resources",
+	}, {
+		name:        "some configured",
+		definitions: []client.ResourceDefinitionWithVolumeDefinition{definition("res1", true, nil), definition("res2", true, nil)},
+		configs:     []reactor.PromoterConfig{config("iscsi-res1", "res1")},
+	}, {
+		name:        "none configured",
+		definitions: []client.ResourceDefinitionWithVolumeDefinition{definition("res1", true, nil), definition("res2", true, nil)},
+		configs:     []reactor.PromoterConfig{config("nfs-other", "other")},
+		expected:    true,
+	}, {
+		name:        "only resources not from gateway",
+		definitions: []client.ResourceDefinitionWithVolumeDefinition{definition("other", false, nil)},
+	}, {
+		name:        "only kept resources",
+		definitions: []client.ResourceDefinitionWithVolumeDefinition{definition("res1", true, map[string]string{"Aux/linstor-gateway/kept": "True"})},
+	}}
+
+	for i := range cases {
+		tcase := &cases[i]
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tcase.expected, allOrphaned(tcase.definitions, tcase.configs))
+		})
+	}
+}
+
+func TestConfigLockName(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "target-1", configLockName("iscsi-target-1"))
+	assert.Equal(t, "my-export", configLockName("nfs-my-export"))
+	assert.Equal(t, "example", configLockName("nvmeof-example"))
+	assert.Equal(t, "other", configLockName("other"))
+}
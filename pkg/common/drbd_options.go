@@ -0,0 +1,111 @@
+package common
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	// DrbdOptionProtocol is the DRBD replication protocol: A, B or C.
+	DrbdOptionProtocol = "protocol"
+	// DrbdOptionQuorum is the number of nodes that need to be connected
+	// for a node to have quorum.
+	DrbdOptionQuorum = "quorum"
+	// DrbdOptionOnNoQuorum is what DRBD does with IO when a primary loses
+	// quorum.
+	DrbdOptionOnNoQuorum = "on-no-quorum"
+)
+
+// drbdOptionValues are the DRBD options that can be set on a resource, and
+// their valid values. Quorum can not be turned off, as drbd-reactor relies on
+// it to promote a resource on only one node at a time.
+var drbdOptionValues = map[string][]string{
+	DrbdOptionProtocol:   {"A", "B", "C"},
+	DrbdOptionQuorum:     {"majority", "all"},
+	DrbdOptionOnNoQuorum: {"io-error", "suspend-io"},
+}
+
+// DefaultDrbdOptions are set on every resource that does not override them.
+// drbd-reactor relies on quorum to promote a resource on only one node.
+var DefaultDrbdOptions = map[string]string{
+	DrbdOptionQuorum:     "majority",
+	DrbdOptionOnNoQuorum: "io-error",
+}
+
+// DrbdOptionNames returns the names of the DRBD options that can be set on a
+// resource, sorted.
+func DrbdOptionNames() []string {
+	names := make([]string, 0, len(drbdOptionValues))
+	for name := range drbdOptionValues {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// ParseDrbdOption parses a DRBD option in the form "name=value".
+func ParseDrbdOption(s string) (string, string, error) {
+	name, value, found := strings.Cut(s, "=")
+	if !found {
+		return "", "", ValidationError(fmt.Sprintf("invalid DRBD option %q, expected name=value", s))
+	}
+
+	err := validDrbdOption(name, value)
+	if err != nil {
+		return "", "", err
+	}
+
+	return name, value, nil
+}
+
+// EqualDrbdOptions reports whether resources created with the DRBD options a
+// and b use the same options. Options that are not set use the defaults.
+func EqualDrbdOptions(a, b map[string]string) bool {
+	for name := range drbdOptionValues {
+		if drbdOption(a, name) != drbdOption(b, name) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// drbdOption returns the value of a DRBD option, or its default if it is not
+// set.
+func drbdOption(options map[string]string, name string) string {
+	value, ok := options[name]
+	if !ok {
+		return DefaultDrbdOptions[name]
+	}
+
+	return value
+}
+
+// ValidDrbdOptions checks the DRBD options of a resource.
+func ValidDrbdOptions(options map[string]string) error {
+	for name, value := range options {
+		err := validDrbdOption(name, value)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func validDrbdOption(name, value string) error {
+	values, ok := drbdOptionValues[name]
+	if !ok {
+		return ValidationError(fmt.Sprintf("unsupported DRBD option %q, expected one of %s", name, strings.Join(DrbdOptionNames(), ", ")))
+	}
+
+	for _, v := range values {
+		if v == value {
+			return nil
+		}
+	}
+
+	return ValidationError(fmt.Sprintf("invalid value %q for DRBD option %s, expected one of %s", value, name, strings.Join(values, ", ")))
+}
@@ -0,0 +1,77 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDrbdOption(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name          string
+		input         string
+		expectedName  string
+		expectedValue string
+		wantErr       bool
+	}{{
+		name:          "protocol",
+		input:         "protocol=A",
+		expectedName:  DrbdOptionProtocol,
+		expectedValue: "A",
+	}, {
+		name:          "on-no-quorum",
+		input:         "on-no-quorum=suspend-io",
+		expectedName:  DrbdOptionOnNoQuorum,
+		expectedValue: "suspend-io",
+	}, {
+		name:    "missing value",
+		input:   "protocol",
+		wantErr: true,
+	}, {
+		name:    "unknown option",
+		input:   "max-buffers=8000",
+		wantErr: true,
+	}, {
+		name:    "invalid value",
+		input:   "protocol=D",
+		wantErr: true,
+	}, {
+		name:    "quorum off",
+		input:   "quorum=off",
+		wantErr: true,
+	}}
+
+	for i := range cases {
+		tcase := &cases[i]
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+			name, value, err := ParseDrbdOption(tcase.input)
+			if tcase.wantErr {
+				assert.Error(t, err)
+				assert.IsType(t, ValidationError(""), err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tcase.expectedName, name)
+				assert.Equal(t, tcase.expectedValue, value)
+			}
+		})
+	}
+}
+
+func TestValidDrbdOptions(t *testing.T) {
+	t.Parallel()
+	assert.NoError(t, ValidDrbdOptions(nil))
+	assert.NoError(t, ValidDrbdOptions(map[string]string{"protocol": "C", "quorum": "all", "on-no-quorum": "io-error"}))
+	assert.Error(t, ValidDrbdOptions(map[string]string{"on-no-quorum": "suspend"}))
+}
+
+func TestEqualDrbdOptions(t *testing.T) {
+	t.Parallel()
+	assert.True(t, EqualDrbdOptions(nil, nil))
+	assert.True(t, EqualDrbdOptions(nil, map[string]string{"quorum": "majority", "on-no-quorum": "io-error"}))
+	assert.True(t, EqualDrbdOptions(map[string]string{"protocol": "A"}, map[string]string{"protocol": "A", "quorum": "majority"}))
+	assert.False(t, EqualDrbdOptions(nil, map[string]string{"protocol": "C"}))
+	assert.False(t, EqualDrbdOptions(map[string]string{"quorum": "all"}, map[string]string{"quorum": "majority"}))
+}
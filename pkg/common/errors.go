@@ -0,0 +1,36 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrNotFound is returned when a requested resource does not exist.
+var ErrNotFound = errors.New("not found")
+
+// ErrAlreadyExists is returned when a resource can not be created because
+// another one with the same name exists.
+var ErrAlreadyExists = errors.New("already exists")
+
+// IncompatibleConfigError is returned when a target or export can not be
+// created because one with the same name but a different config exists. The
+// error wraps ErrAlreadyExists and describes the differences.
+func IncompatibleConfigError(existing, requested interface{}) error {
+	return fmt.Errorf("resource %w with incompatible config (-existing +requested):\n%s", ErrAlreadyExists, ConfigDiff(existing, requested))
+}
+
+// ErrNotRunning is returned when an operation requires a started target or
+// export, but it is stopped.
+var ErrNotRunning = errors.New("not running")
+
+// ErrOperationInProgress is returned when a resource can not be changed
+// because another operation on it is in progress.
+var ErrOperationInProgress = errors.New("operation in progress")
+
+// ErrEventsLost is returned when events that were requested are no longer
+// kept.
+var ErrEventsLost = errors.New("events lost")
+
+// ErrNoStoragePool is returned when LINSTOR can not place a resource because
+// the resource group does not resolve to enough usable storage pools.
+var ErrNoStoragePool = errors.New("no usable storage pool")
@@ -0,0 +1,31 @@
+package common
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIncompatibleConfigError(t *testing.T) {
+	t.Parallel()
+
+	type config struct {
+		ServiceIP string
+		SizeKiB   uint64
+		Password  string
+		Status    ResourceStatus
+	}
+
+	existing := config{ServiceIP: "192.168.0.1/24", SizeKiB: 1024, Password: "existing-secret", Status: ResourceStatus{Primary: "node1"}}
+	requested := config{ServiceIP: "192.168.0.2/24", SizeKiB: 1024, Password: "requested-secret"}
+
+	err := IncompatibleConfigError(existing, requested)
+	assert.True(t, errors.Is(err, ErrAlreadyExists))
+	// the format of the diff is deliberately unstable, so only its
+	// content is checked
+	assert.Contains(t, err.Error(), `"192.168.0.1/24"`)
+	assert.Contains(t, err.Error(), `"192.168.0.2/24"`)
+	assert.NotContains(t, err.Error(), "secret")
+	assert.NotContains(t, err.Error(), "node1")
+}
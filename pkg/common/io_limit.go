@@ -0,0 +1,121 @@
+package common
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/rck/unit"
+)
+
+// iopsSuffix marks an IOLimit in IO operations per second.
+const iopsSuffix = "iops"
+
+// limitUnit is used to parse and print throughput limits. Printing only uses
+// binary units, so that the output is deterministic.
+var limitUnit = unit.MustNewUnit(map[string]int64{
+	"B": 1,
+	"K": unit.K,
+	"M": unit.M,
+	"G": unit.G,
+	"T": unit.T,
+	"P": unit.P,
+})
+
+// IOLimit limits the throughput of a resource, either in bytes per second or
+// in IO operations per second. As text, it is written as a size with an
+// optional unit, e.g. "100M" for 100 MiB/s, or as a number followed by
+// "iops", e.g. "5000iops".
+type IOLimit struct {
+	// Value is the limit in bytes per second, or in IO operations per
+	// second if IOPS is set.
+	Value int64
+	IOPS  bool
+}
+
+// ParseIOLimit parses the text representation of an IOLimit. Sizes accept
+// the same units as volume sizes. An empty string means that there is no
+// limit, in which case nil is returned.
+func ParseIOLimit(s string) (*IOLimit, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	l := &IOLimit{}
+	lower := strings.ToLower(s)
+	if strings.HasSuffix(lower, iopsSuffix) {
+		value, err := strconv.ParseInt(strings.TrimSpace(strings.TrimSuffix(lower, iopsSuffix)), 10, 64)
+		if err != nil {
+			return nil, ValidationError(fmt.Sprintf("invalid IO limit %q: expected a number of IO operations per second", s))
+		}
+		l.Value = value
+		l.IOPS = true
+	} else {
+		value, err := unit.MustNewUnit(unit.DefaultUnits).ValueFromString(s)
+		if err != nil {
+			return nil, ValidationError(fmt.Sprintf("invalid IO limit %q: expected a size per second, e.g. 100M, or a number followed by \"%s\"", s, iopsSuffix))
+		}
+		l.Value = value.Value
+	}
+
+	if l.Value <= 0 {
+		return nil, ValidationError(fmt.Sprintf("IO limit must be positive, is %s", s))
+	}
+
+	return l, nil
+}
+
+// String implements fmt.Stringer.
+func (l IOLimit) String() string {
+	if l.IOPS {
+		return strconv.FormatInt(l.Value, 10) + iopsSuffix
+	}
+
+	if l.Value <= 0 {
+		return strconv.FormatInt(l.Value, 10)
+	}
+
+	return limitUnit.MustNewValue(l.Value, unit.None).String()
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (l IOLimit) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (l *IOLimit) UnmarshalText(text []byte) error {
+	parsed, err := ParseIOLimit(string(text))
+	if err != nil {
+		return err
+	}
+
+	if parsed == nil {
+		return ValidationError("empty IO limit")
+	}
+
+	*l = *parsed
+	return nil
+}
+
+// ValidIOLimits checks the optional read and write limits of a resource.
+func ValidIOLimits(read, write *IOLimit) error {
+	if read != nil && read.Value <= 0 {
+		return ValidationError(fmt.Sprintf("read limit must be positive, is %s", read))
+	}
+
+	if write != nil && write.Value <= 0 {
+		return ValidationError(fmt.Sprintf("write limit must be positive, is %s", write))
+	}
+
+	return nil
+}
+
+// EqualIOLimits reports whether two optional limits are the same.
+func EqualIOLimits(a, b *IOLimit) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	return *a == *b
+}
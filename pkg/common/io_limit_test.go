@@ -0,0 +1,99 @@
+package common
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseIOLimit(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name     string
+		input    string
+		expected *IOLimit
+		wantErr  bool
+	}{{
+		name:  "empty",
+		input: "",
+	}, {
+		name:     "bytes without unit",
+		input:    "1048576",
+		expected: &IOLimit{Value: 1048576},
+	}, {
+		name:     "binary unit",
+		input:    "100M",
+		expected: &IOLimit{Value: 100 * 1024 * 1024},
+	}, {
+		name:     "decimal unit",
+		input:    "10MB",
+		expected: &IOLimit{Value: 10 * 1000 * 1000},
+	}, {
+		name:     "iops",
+		input:    "5000iops",
+		expected: &IOLimit{Value: 5000, IOPS: true},
+	}, {
+		name:     "iops upper case with space",
+		input:    "5000 IOPS",
+		expected: &IOLimit{Value: 5000, IOPS: true},
+	}, {
+		name:    "negative bytes",
+		input:   "-100M",
+		wantErr: true,
+	}, {
+		name:    "negative iops",
+		input:   "-5iops",
+		wantErr: true,
+	}, {
+		name:    "zero",
+		input:   "0",
+		wantErr: true,
+	}, {
+		name:    "unknown unit",
+		input:   "100X",
+		wantErr: true,
+	}, {
+		name:    "iops without number",
+		input:   "iops",
+		wantErr: true,
+	}}
+
+	for i := range cases {
+		tcase := &cases[i]
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+			actual, err := ParseIOLimit(tcase.input)
+			if tcase.wantErr {
+				assert.Error(t, err)
+				assert.IsType(t, ValidationError(""), err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tcase.expected, actual)
+			}
+		})
+	}
+}
+
+func TestIOLimitJSON(t *testing.T) {
+	t.Parallel()
+
+	type limits struct {
+		Read  *IOLimit `json:"read,omitempty"`
+		Write *IOLimit `json:"write,omitempty"`
+	}
+
+	in := limits{Read: &IOLimit{Value: 100 * 1024 * 1024}, Write: &IOLimit{Value: 5000, IOPS: true}}
+	raw, err := json.Marshal(in)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"read": "100M", "write": "5000iops"}`, string(raw))
+
+	var out limits
+	err = json.Unmarshal(raw, &out)
+	assert.NoError(t, err)
+	assert.Equal(t, in, out)
+
+	err = json.Unmarshal([]byte(`{"read": "-1M"}`), &out)
+	assert.Error(t, err)
+}
@@ -0,0 +1,104 @@
+package common
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// maxLabelLength is the maximum length of the key and the value of a label.
+const maxLabelLength = 63
+
+// labelRegexp matches valid label keys and values. Slashes are not allowed
+// because labels are stored as LINSTOR properties, where they separate
+// namespaces.
+var labelRegexp = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9_.-]*[A-Za-z0-9])?$`)
+
+// ParseLabel parses a label in the form "key=value".
+func ParseLabel(s string) (string, string, error) {
+	key, value, found := strings.Cut(s, "=")
+	if !found {
+		return "", "", ValidationError(fmt.Sprintf("invalid label %q, expected key=value", s))
+	}
+
+	err := validLabel(key, value)
+	if err != nil {
+		return "", "", err
+	}
+
+	return key, value, nil
+}
+
+// ParseSelector parses a comma separated list of labels, e.g.
+// "team=foo,env=prod". An empty string selects everything, in which case nil
+// is returned.
+func ParseSelector(s string) (map[string]string, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	selector := make(map[string]string)
+	for _, label := range strings.Split(s, ",") {
+		key, value, err := ParseLabel(strings.TrimSpace(label))
+		if err != nil {
+			return nil, err
+		}
+
+		if _, ok := selector[key]; ok {
+			return nil, ValidationError(fmt.Sprintf("label %s is selected more than once", key))
+		}
+		selector[key] = value
+	}
+
+	return selector, nil
+}
+
+// ValidLabels checks the labels of a resource.
+func ValidLabels(labels map[string]string) error {
+	for key, value := range labels {
+		err := validLabel(key, value)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// MatchesSelector reports whether labels contains every label of the
+// selector.
+func MatchesSelector(labels, selector map[string]string) bool {
+	for key, value := range selector {
+		actual, ok := labels[key]
+		if !ok || actual != value {
+			return false
+		}
+	}
+
+	return true
+}
+
+// FormatLabels describes labels as a sorted, comma separated list, e.g.
+// "env=prod,team=foo".
+func FormatLabels(labels map[string]string) string {
+	pairs := make([]string, 0, len(labels))
+	for key, value := range labels {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+
+	return strings.Join(pairs, ",")
+}
+
+func validLabel(key, value string) error {
+	if len(key) > maxLabelLength || !labelRegexp.MatchString(key) {
+		return ValidationError(fmt.Sprintf("invalid label key %q: must be at most %d characters, consist of letters, digits, '-', '_' or '.', and start and end with a letter or digit", key, maxLabelLength))
+	}
+
+	if len(value) > maxLabelLength || !labelRegexp.MatchString(value) {
+		return ValidationError(fmt.Sprintf("invalid value %q of label %s: must be at most %d characters, consist of letters, digits, '-', '_' or '.', and start and end with a letter or digit", value, key, maxLabelLength))
+	}
+
+	return nil
+}
@@ -0,0 +1,84 @@
+package common
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSelector(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name     string
+		input    string
+		expected map[string]string
+		wantErr  bool
+	}{{
+		name:  "empty",
+		input: "",
+	}, {
+		name:     "single label",
+		input:    "team=foo",
+		expected: map[string]string{"team": "foo"},
+	}, {
+		name:     "several labels with spaces",
+		input:    "team=foo, env=prod.eu-1",
+		expected: map[string]string{"team": "foo", "env": "prod.eu-1"},
+	}, {
+		name:    "missing value",
+		input:   "team",
+		wantErr: true,
+	}, {
+		name:    "empty value",
+		input:   "team=",
+		wantErr: true,
+	}, {
+		name:    "slash in key",
+		input:   "example.com/team=foo",
+		wantErr: true,
+	}, {
+		name:    "key too long",
+		input:   strings.Repeat("a", 64) + "=foo",
+		wantErr: true,
+	}, {
+		name:    "duplicate key",
+		input:   "team=foo,team=bar",
+		wantErr: true,
+	}}
+
+	for i := range cases {
+		tcase := &cases[i]
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+			actual, err := ParseSelector(tcase.input)
+			if tcase.wantErr {
+				assert.Error(t, err)
+				assert.IsType(t, ValidationError(""), err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tcase.expected, actual)
+			}
+		})
+	}
+}
+
+func TestMatchesSelector(t *testing.T) {
+	t.Parallel()
+	labels := map[string]string{"team": "foo", "env": "prod"}
+
+	assert.True(t, MatchesSelector(labels, nil))
+	assert.True(t, MatchesSelector(nil, nil))
+	assert.True(t, MatchesSelector(labels, map[string]string{"team": "foo"}))
+	assert.True(t, MatchesSelector(labels, map[string]string{"team": "foo", "env": "prod"}))
+	assert.False(t, MatchesSelector(labels, map[string]string{"team": "bar"}))
+	assert.False(t, MatchesSelector(labels, map[string]string{"team": "foo", "zone": "a"}))
+	assert.False(t, MatchesSelector(nil, map[string]string{"team": "foo"}))
+}
+
+func TestFormatLabels(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, "", FormatLabels(nil))
+	assert.Equal(t, "env=prod,team=foo", FormatLabels(map[string]string{"team": "foo", "env": "prod"}))
+}
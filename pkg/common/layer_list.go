@@ -0,0 +1,102 @@
+package common
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/LINBIT/golinstor/devicelayerkind"
+)
+
+// KnownLayers are the LINSTOR layers that can be part of a layer list.
+var KnownLayers = []devicelayerkind.DeviceLayerKind{
+	devicelayerkind.Drbd,
+	devicelayerkind.Luks,
+	devicelayerkind.Storage,
+	devicelayerkind.Nvme,
+	devicelayerkind.Openflex,
+	devicelayerkind.Exos,
+	devicelayerkind.Writecache,
+	devicelayerkind.Cache,
+	devicelayerkind.Bcache,
+}
+
+// ParseLayerList parses a comma separated list of LINSTOR layers, e.g.
+// "drbd,storage". The layer names are case-insensitive. An empty string
+// means that the layer list of the resource group is used, in which case nil
+// is returned.
+func ParseLayerList(s string) ([]string, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	var layers []string
+	for _, layer := range strings.Split(s, ",") {
+		layers = append(layers, strings.ToUpper(strings.TrimSpace(layer)))
+	}
+
+	err := ValidLayerList(layers)
+	if err != nil {
+		return nil, err
+	}
+
+	return layers, nil
+}
+
+// ValidLayerList checks a layer list that overrides the one of the resource
+// group. An empty list means that the resource group's layer list is used.
+func ValidLayerList(layers []string) error {
+	seen := make(map[string]bool, len(layers))
+	for _, layer := range layers {
+		if !knownLayer(layer) {
+			return ValidationError(fmt.Sprintf("unknown layer %q, expected one of %s", layer, knownLayerNames()))
+		}
+
+		if seen[layer] {
+			return ValidationError(fmt.Sprintf("layer %s is listed more than once", layer))
+		}
+		seen[layer] = true
+	}
+
+	if len(layers) > 0 && layers[len(layers)-1] != string(devicelayerkind.Storage) {
+		return ValidationError(fmt.Sprintf("the last layer must be %s", devicelayerkind.Storage))
+	}
+
+	return nil
+}
+
+// WithoutDRBD reports whether the layer list overrides the one of the resource
+// group with a list that does not contain DRBD. drbd-reactor relies on DRBD
+// to decide where a target runs, so such resources are not highly available:
+// they are not replicated and can not fail over to another node.
+func WithoutDRBD(layers []string) bool {
+	if len(layers) == 0 {
+		return false
+	}
+
+	for _, layer := range layers {
+		if layer == string(devicelayerkind.Drbd) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func knownLayer(layer string) bool {
+	for _, known := range KnownLayers {
+		if layer == string(known) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func knownLayerNames() string {
+	names := make([]string, len(KnownLayers))
+	for i, known := range KnownLayers {
+		names[i] = string(known)
+	}
+
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,67 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLayerList(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name         string
+		input        string
+		expected     []string
+		expectNoDRBD bool
+		wantErr      bool
+	}{{
+		name:  "empty",
+		input: "",
+	}, {
+		name:     "drbd and storage",
+		input:    "DRBD,STORAGE",
+		expected: []string{"DRBD", "STORAGE"},
+	}, {
+		name:     "lower case with spaces",
+		input:    "drbd, luks, storage",
+		expected: []string{"DRBD", "LUKS", "STORAGE"},
+	}, {
+		name:         "storage only",
+		input:        "storage",
+		expected:     []string{"STORAGE"},
+		expectNoDRBD: true,
+	}, {
+		name:    "unknown layer",
+		input:   "drbd,zfs",
+		wantErr: true,
+	}, {
+		name:    "duplicate layer",
+		input:   "drbd,drbd,storage",
+		wantErr: true,
+	}, {
+		name:    "storage not last",
+		input:   "storage,drbd",
+		wantErr: true,
+	}, {
+		name:    "empty layer",
+		input:   "drbd,,storage",
+		wantErr: true,
+	}}
+
+	for i := range cases {
+		tcase := &cases[i]
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+			actual, err := ParseLayerList(tcase.input)
+			if tcase.wantErr {
+				assert.Error(t, err)
+				assert.IsType(t, ValidationError(""), err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tcase.expected, actual)
+				assert.Equal(t, tcase.expectNoDRBD, WithoutDRBD(actual))
+			}
+		})
+	}
+}
@@ -5,9 +5,14 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 
+	apiconsts "github.com/LINBIT/golinstor"
 	"github.com/LINBIT/golinstor/client"
+
+	"github.com/LINBIT/linstor-gateway/pkg/observe"
 )
 
 type UidGid struct {
@@ -24,6 +29,20 @@ type VolumeConfig struct {
 	SizeKiB             uint64 `json:"size_kib"`
 	FileSystem          string `json:"file_system,omitempty"`
 	FileSystemRootOwner UidGid `json:"file_system_root_owner,omitempty"`
+	// Exported is false for a volume that is created, but not made
+	// available to clients yet. If nil, the volume is exported. Only iSCSI
+	// targets support volumes that are not exported.
+	Exported *bool `json:"exported,omitempty"`
+	// GrossSize states whether SizeKiB of a volume that is added to an
+	// existing resource is a gross size. It has to match the size semantics
+	// the resource was created with. If nil, the volume uses the semantics
+	// of the resource.
+	GrossSize *bool `json:"gross_size,omitempty"`
+}
+
+// IsExported reports whether the volume is made available to clients.
+func (v VolumeConfig) IsExported() bool {
+	return v.Exported == nil || *v.Exported
 }
 
 type ResourceStatus struct {
@@ -32,6 +51,85 @@ type ResourceStatus struct {
 	Primary string        `json:"primary"`
 	Nodes   []string      `json:"nodes"`
 	Volumes []VolumeState `json:"volumes"`
+	// Placement is the placement policy of the resource group the
+	// resource belongs to. It is nil if the resource group is unknown.
+	Placement *Placement `json:"placement,omitempty"`
+	// Replicas is the number of diskful replicas that are deployed.
+	Replicas int `json:"replicas"`
+	// Inconsistent describes how LINSTOR and the drbd-reactor config of the
+	// resource disagree. It is empty if they are consistent.
+	Inconsistent string `json:"inconsistent,omitempty"`
+	// ConfigPath is the path of the drbd-reactor config file that manages
+	// the resource.
+	ConfigPath string `json:"config_path,omitempty"`
+	// DrbdPort is the TCP port DRBD uses for the resource, if known.
+	DrbdPort int `json:"drbd_port,omitempty"`
+}
+
+// MarkInconsistent records that LINSTOR and the drbd-reactor config of the
+// resource disagree, as described by err.
+func (s *ResourceStatus) MarkInconsistent(err error) {
+	s.State = ResourceStateBad
+	s.Inconsistent = err.Error()
+}
+
+// Running reports whether the service is started and the resource is in use
+// on one of the nodes.
+func (s *ResourceStatus) Running() bool {
+	return s.Service == ServiceStateStarted && s.Primary != ""
+}
+
+// Stopped reports whether the service is stopped and the resource is not in
+// use on any node.
+func (s *ResourceStatus) Stopped() bool {
+	return s.Service == ServiceStateStopped && s.Primary == ""
+}
+
+// MissingReplicas returns how many diskful replicas are missing to reach the
+// placement count of the resource. It is 0 if the placement count is unknown.
+func (s *ResourceStatus) MissingReplicas() int {
+	if s.Placement == nil || s.Placement.PlaceCount <= s.Replicas {
+		return 0
+	}
+
+	return s.Placement.PlaceCount - s.Replicas
+}
+
+// Degraded reports whether the resource needs attention: the service is not
+// started, LINSTOR and the drbd-reactor config disagree, fewer replicas than
+// requested are deployed, or the resource or any of its volumes is not in a
+// good state.
+func (s *ResourceStatus) Degraded() bool {
+	if s.Service != ServiceStateStarted || s.Inconsistent != "" || s.State != ResourceStateOK || s.MissingReplicas() > 0 {
+		return true
+	}
+
+	for _, vol := range s.Volumes {
+		if vol.State != ResourceStateOK {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Placement describes how LINSTOR places the replicas of a resource, as
+// configured in its resource group.
+type Placement struct {
+	ResourceGroup string `json:"resource_group"`
+	// PlaceCount is the number of diskful replicas.
+	PlaceCount int `json:"place_count"`
+	// StoragePools are the storage pools diskful replicas are placed in.
+	// If empty, LINSTOR may choose any storage pool.
+	StoragePools []string `json:"storage_pools,omitempty"`
+	// DisklessStoragePools are the storage pools used for diskless
+	// replicas.
+	DisklessStoragePools []string `json:"diskless_storage_pools,omitempty"`
+	ReplicasOnSame       []string `json:"replicas_on_same,omitempty"`
+	ReplicasOnDifferent  []string `json:"replicas_on_different,omitempty"`
+	// DisklessOnRemaining is set if a diskless replica is placed on every
+	// node that has no diskful replica.
+	DisklessOnRemaining bool `json:"diskless_on_remaining"`
 }
 
 type Volume struct {
@@ -42,6 +140,47 @@ type Volume struct {
 type VolumeState struct {
 	Number int           `json:"number"`
 	State  ResourceState `json:"state"`
+	// Sync is a short summary of the DRBD replication state of the volume,
+	// e.g. "UpToDate", "Disconnected", or "SyncTarget(42.10%)".
+	Sync string `json:"sync,omitempty"`
+	// DiskStates maps the node names to the DRBD disk state of the volume
+	// on the respective node.
+	DiskStates map[string]string `json:"disk_states,omitempty"`
+	// Nodes is the state of the volume on every node it is deployed on,
+	// sorted by node name.
+	Nodes []NodeVolumeState `json:"nodes,omitempty"`
+	// DrbdMinor is the DRBD minor number of the volume, if known.
+	DrbdMinor int `json:"drbd_minor,omitempty"`
+	// AllocatedKiB is the space the volume actually uses in the storage
+	// pool, on the replica that uses the most. For thinly provisioned
+	// volumes, this is usually less than the provisioned size. It is 0 if
+	// LINSTOR does not report it.
+	AllocatedKiB uint64 `json:"allocated_kib,omitempty"`
+}
+
+// NodeVolumeState is the state of a volume on a single node.
+type NodeVolumeState struct {
+	Node string `json:"node"`
+	// DiskState is the DRBD disk state of the volume on the node, e.g.
+	// "UpToDate", "Diskless" or "Outdated".
+	DiskState string `json:"disk_state"`
+	// Primary is set on the node the resource is in use on.
+	Primary     bool   `json:"primary,omitempty"`
+	StoragePool string `json:"storage_pool,omitempty"`
+	// AllocatedKiB is the space the volume uses in the storage pool of the
+	// node. It is 0 if LINSTOR does not report it.
+	AllocatedKiB uint64 `json:"allocated_kib,omitempty"`
+}
+
+const (
+	SyncStateUpToDate     = "UpToDate"
+	SyncStateDisconnected = "Disconnected"
+	SyncStateSyncTarget   = "SyncTarget"
+)
+
+// Resyncing returns true if a resync of the volume is in progress.
+func (v VolumeState) Resyncing() bool {
+	return strings.HasPrefix(v.Sync, SyncStateSyncTarget)
 }
 
 type ResourceState int
@@ -95,6 +234,14 @@ type ServiceState int
 const (
 	ServiceStateStopped ServiceState = iota
 	ServiceStateStarted
+	// ServiceStateTransitioning means that the service is being started or
+	// stopped, e.g. during a failover: DRBD is not yet promoted on any node
+	// although the service is started, or still promoted although it is
+	// stopped.
+	ServiceStateTransitioning
+	// ServiceStateFailed means that the service is started, but DRBD has not
+	// been promoted on any node for longer than starting it may take.
+	ServiceStateFailed
 )
 
 func (s ServiceState) String() string {
@@ -103,11 +250,21 @@ func (s ServiceState) String() string {
 		return "Started"
 	case ServiceStateStopped:
 		return "Stopped"
+	case ServiceStateTransitioning:
+		return "Transitioning"
+	case ServiceStateFailed:
+		return "Failed"
 	}
 
 	return "Unknown"
 }
 
+// Active reports whether the service is started, transitioning or failed,
+// i.e. whether it may be running on one of the nodes.
+func (s ServiceState) Active() bool {
+	return s != ServiceStateStopped
+}
+
 func (s ServiceState) MarshalJSON() ([]byte, error) { return json.Marshal(s.String()) }
 
 func (s *ServiceState) UnmarshalJSON(text []byte) error {
@@ -122,6 +279,10 @@ func (s *ServiceState) UnmarshalJSON(text []byte) error {
 		*s = ServiceStateStarted
 	case "Stopped":
 		*s = ServiceStateStopped
+	case "Transitioning":
+		*s = ServiceStateTransitioning
+	case "Failed":
+		*s = ServiceStateFailed
 	default:
 		return errors.New(fmt.Sprintf("unknown service state: %s", s))
 	}
@@ -143,7 +304,134 @@ func NoResourcesInUse(resources []client.ResourceWithVolumes) bool {
 	return !AnyResourcesInUse(resources)
 }
 
-func WaitUntilResourceCondition(ctx context.Context, cli *client.Client, name string, condition func([]client.ResourceWithVolumes) bool) error {
+// VolumeUpToDate returns a condition that is met once the volume with the
+// given number is UpToDate on at least one node.
+func VolumeUpToDate(number int) func([]client.ResourceWithVolumes) bool {
+	return func(resources []client.ResourceWithVolumes) bool {
+		for _, resource := range resources {
+			for _, vol := range resource.Volumes {
+				if int(vol.VolumeNumber) == number && vol.State.DiskState == "UpToDate" {
+					return true
+				}
+			}
+		}
+
+		return false
+	}
+}
+
+// InUseOn returns a condition that is met while the resource is in use on
+// the given node.
+func InUseOn(node string) func([]client.ResourceWithVolumes) bool {
+	return func(resources []client.ResourceWithVolumes) bool {
+		for _, resource := range resources {
+			if resource.NodeName == node && resource.State.InUse != nil && *resource.State.InUse {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+// NotInUseOn returns a condition that is met once the resource is no longer
+// in use on the given node.
+func NotInUseOn(node string) func([]client.ResourceWithVolumes) bool {
+	inUse := InUseOn(node)
+	return func(resources []client.ResourceWithVolumes) bool {
+		return !inUse(resources)
+	}
+}
+
+// FailoverCandidates returns the nodes a resource that is active on primary
+// can be moved to, i.e. all other nodes with a diskful replica, sorted by
+// name.
+func FailoverCandidates(resources []client.ResourceWithVolumes, primary string) []string {
+	var nodes []string
+	for _, resource := range resources {
+		if resource.NodeName == primary || isDiskless(resource.Resource) {
+			continue
+		}
+		nodes = append(nodes, resource.NodeName)
+	}
+	sort.Strings(nodes)
+
+	return nodes
+}
+
+// DiskfulReplicas returns the number of resources that store data, i.e. are
+// neither diskless nor tie breakers.
+func DiskfulReplicas(resources []client.ResourceWithVolumes) int {
+	count := 0
+	for _, resource := range resources {
+		if !isDiskless(resource.Resource) {
+			count++
+		}
+	}
+
+	return count
+}
+
+func isDiskless(resource client.Resource) bool {
+	for _, flag := range resource.Flags {
+		if flag == apiconsts.FlagDiskless || flag == apiconsts.FlagTieBreaker {
+			return true
+		}
+	}
+
+	return false
+}
+
+// WaitTimeout is how long to wait for a resource to reach a condition, e.g.
+// for its services to stop. Promoting and syncing a resource takes longer the
+// more nodes it is deployed on, so the timeout scales with the number of
+// nodes.
+type WaitTimeout struct {
+	// Base is the timeout for a resource that is not deployed on any node.
+	Base time.Duration
+	// PerNode is added to Base for every node the resource is deployed on,
+	// including diskless ones.
+	PerNode time.Duration
+	// Fixed replaces the scaled timeout if it is not 0.
+	Fixed time.Duration
+}
+
+// DefaultWaitTimeout is used by all operations that wait for a resource. It
+// waits 30 seconds for a resource on three nodes.
+var DefaultWaitTimeout = WaitTimeout{
+	Base:    15 * time.Second,
+	PerNode: 5 * time.Second,
+}
+
+// DefaultSyncTimeout is how long to wait for all replicas of a new resource to
+// be in sync if no timeout is given. A full initial sync of a large volume
+// can take much longer than the other waits.
+const DefaultSyncTimeout = time.Hour
+
+// RollbackTimeout limits how long undoing the changes of an operation may
+// take, e.g. rolling back a partially created resource. Undoing also happens
+// if the operation was cancelled.
+var RollbackTimeout = 2 * time.Minute
+
+// For returns the timeout for a resource deployed on the given number of
+// nodes.
+func (w WaitTimeout) For(nodes int) time.Duration {
+	if w.Fixed > 0 {
+		return w.Fixed
+	}
+
+	return w.Base + time.Duration(nodes)*w.PerNode
+}
+
+// WaitUntilResourceCondition polls the resource name until condition is
+// met. It gives up after the timeout for the number of nodes the resource is
+// deployed on when it is first polled.
+func WaitUntilResourceCondition(ctx context.Context, cli *client.Client, name string, timeout WaitTimeout, condition func([]client.ResourceWithVolumes) bool) (err error) {
+	ctx, end := observe.Step(ctx, "common.WaitUntilResourceCondition")
+	defer func() { end(err) }()
+
+	var deadline <-chan time.Time
+	var wait time.Duration
 	for {
 		resources, err := cli.Resources.GetResourceView(ctx, &client.ListOpts{Resource: []string{name}})
 		if err != nil {
@@ -154,6 +442,34 @@ func WaitUntilResourceCondition(ctx context.Context, cli *client.Client, name st
 			return nil
 		}
 
-		time.Sleep(3 * time.Second)
+		if deadline == nil {
+			wait = timeout.For(len(resources))
+			timer := time.NewTimer(wait)
+			defer timer.Stop()
+			deadline = timer.C
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-deadline:
+			return fmt.Errorf("timed out after %s", wait)
+		case <-time.After(3 * time.Second):
+		}
+	}
+}
+
+// RemoveVolume removes the volume with the given number from every resource
+// in resources. Volumes are matched by their volume number, not by their
+// position in the slice, as the order of volumes may differ between nodes.
+func RemoveVolume(resources []client.ResourceWithVolumes, number int) {
+	for i := range resources {
+		volumes := resources[i].Volumes[:0]
+		for _, vol := range resources[i].Volumes {
+			if int(vol.VolumeNumber) != number {
+				volumes = append(volumes, vol)
+			}
+		}
+		resources[i].Volumes = volumes
 	}
 }
@@ -1,10 +1,16 @@
 package common
 
 import (
+	"fmt"
+
+	apiconsts "github.com/LINBIT/golinstor"
 	"github.com/LINBIT/golinstor/client"
 	"github.com/LINBIT/linstor-gateway/pkg/reactor"
 	"path/filepath"
 	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
 )
 
 const (
@@ -29,6 +35,341 @@ func DevicePath(vol client.Volume) string {
 	return devPath
 }
 
+// AnyGrossSize returns true if any of the given volume definitions has the
+// GROSS_SIZE flag set.
+func AnyGrossSize(volumeDefinitions []client.VolumeDefinition) bool {
+	for _, vd := range volumeDefinitions {
+		for _, flag := range vd.Flags {
+			if flag == "GROSS_SIZE" {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// exportedProp is set to "False" on the volume definitions of volumes that
+// are not exported.
+const exportedProp = apiconsts.NamespcAuxiliary + "/linstor-gateway/exported"
+
+// ExportedProps returns the properties of a volume definition that record
+// whether the volume is exported.
+func ExportedProps(exported bool) map[string]string {
+	if exported {
+		return map[string]string{}
+	}
+
+	return map[string]string{exportedProp: "False"}
+}
+
+// ExportedPropsModify returns the change to the properties of an existing
+// volume definition that records whether the volume is exported.
+func ExportedPropsModify(exported bool) client.GenericPropsModify {
+	if exported {
+		return client.GenericPropsModify{DeleteProps: []string{exportedProp}}
+	}
+
+	return client.GenericPropsModify{OverrideProps: ExportedProps(false)}
+}
+
+// Exported returns the Exported field of the volume described by the volume
+// definition: nil if the volume is exported, false otherwise.
+func Exported(vd client.VolumeDefinition) *bool {
+	if vd.Props[exportedProp] != "False" {
+		return nil
+	}
+
+	exported := false
+	return &exported
+}
+
+// ExportedVolumeDefinitions returns the volume definitions of the volumes
+// that are exported.
+func ExportedVolumeDefinitions(volumeDefinitions []client.VolumeDefinition) []client.VolumeDefinition {
+	var exported []client.VolumeDefinition
+	for _, vd := range volumeDefinitions {
+		if Exported(vd) == nil {
+			exported = append(exported, vd)
+		}
+	}
+
+	return exported
+}
+
+// ValidExported checks that all volumes are exported, for the kinds of
+// targets and exports that do not support volumes which are not exported.
+func ValidExported(volumes []VolumeConfig) error {
+	for _, vol := range volumes {
+		if !vol.IsExported() {
+			return ValidationError(fmt.Sprintf("volume %d: volumes that are not exported are only supported for iSCSI targets", vol.Number))
+		}
+	}
+
+	return nil
+}
+
+// ValidUserVolumeNumber checks the number of a volume that is requested when a
+// resource is created. Volume 0 is the cluster private volume, which is always
+// added by linstor-gateway itself, so it can not be requested.
+func ValidUserVolumeNumber(number int) error {
+	if number == 0 {
+		return ValidationError("volume 0 is reserved for the cluster private volume, volume numbers must start at 1")
+	}
+
+	if number < 0 {
+		return ValidationError(fmt.Sprintf("volume numbers must start at 1, got %d", number))
+	}
+
+	return nil
+}
+
+// ValidGrossSize checks that a volume added to an existing resource uses the
+// same size semantics as the resource, which was created with gross sizes if
+// grossSize is true. All volumes of a resource share the same semantics, so
+// that their sizes can be compared.
+func ValidGrossSize(vol VolumeConfig, grossSize bool) error {
+	if vol.GrossSize == nil || *vol.GrossSize == grossSize {
+		return nil
+	}
+
+	if grossSize {
+		return ValidationError(fmt.Sprintf("volume %d: cannot add a volume with net size, all volumes use gross sizes", vol.Number))
+	}
+
+	return ValidationError(fmt.Sprintf("volume %d: cannot add a volume with gross size, all volumes use net sizes", vol.Number))
+}
+
+// maxDescriptionLength is the maximum length of the description of a target or
+// export.
+const maxDescriptionLength = 256
+
+// ValidDescription checks the description of a target or export. It has to
+// fit on a single line, so that it can be shown in tables.
+func ValidDescription(description string) error {
+	if utf8.RuneCountInString(description) > maxDescriptionLength {
+		return ValidationError(fmt.Sprintf("description too long (max. %d characters)", maxDescriptionLength))
+	}
+
+	for _, r := range description {
+		if unicode.IsControl(r) {
+			return ValidationError(fmt.Sprintf("description must not contain control characters such as line breaks, contains %q", r))
+		}
+	}
+
+	return nil
+}
+
+// ValidPlacementCount checks a placement count that overrides the one of the
+// resource group. 0 means that the resource group's placement count is used.
+func ValidPlacementCount(count int) error {
+	if count < 0 {
+		return ValidationError(fmt.Sprintf("placement count must be at least 1, is %d", count))
+	}
+
+	return nil
+}
+
+// Ranges of the DRBD port and minor numbers that can be requested.
+const (
+	maxDrbdPort  = 65535
+	maxDrbdMinor = 1<<20 - 1
+)
+
+// ValidDrbdNumbers checks a requested DRBD port and the minor number of the
+// first of volumeCount volumes. 0 means that LINSTOR assigns a free port or
+// minor numbers.
+func ValidDrbdNumbers(port, minor, volumeCount int) error {
+	if port < 0 || port > maxDrbdPort {
+		return ValidationError(fmt.Sprintf("DRBD port must be between 1 and %d, is %d", maxDrbdPort, port))
+	}
+
+	if minor < 0 || minor+volumeCount-1 > maxDrbdMinor {
+		return ValidationError(fmt.Sprintf("DRBD minor numbers must be between 1 and %d, are %d to %d", maxDrbdMinor, minor, minor+volumeCount-1))
+	}
+
+	return nil
+}
+
+// InconsistentVolumesError is returned if LINSTOR and the drbd-reactor config
+// of a resource disagree on the number of volumes, e.g. after a volume was
+// only partially deleted.
+type InconsistentVolumesError struct {
+	// Linstor is the number of volume definitions in LINSTOR.
+	Linstor int
+	// Configured is the number of volumes in the drbd-reactor config.
+	Configured int
+}
+
+func (e *InconsistentVolumesError) Error() string {
+	return fmt.Sprintf("inconsistent: %d linstor volumes vs %d configured", e.Linstor, e.Configured)
+}
+
+// CheckVolumeCount returns an *InconsistentVolumesError if cfg configures a
+// different number of volumes than there are volume definitions in LINSTOR.
+// volumeAgentTypes are the types of the resource agents that each configure
+// one volume, including the cluster private volume.
+func CheckVolumeCount(cfg *reactor.PromoterConfig, volumeDefinitions []client.VolumeDefinition, volumeAgentTypes ...string) error {
+	configured := cfg.CountAgents(volumeAgentTypes...)
+	if configured != len(volumeDefinitions) {
+		return &InconsistentVolumesError{Linstor: len(volumeDefinitions), Configured: configured}
+	}
+
+	return nil
+}
+
+// CreateOptions change how a target or export is created.
+type CreateOptions struct {
+	// KeepOnFailure keeps the LINSTOR resource if a later step of creating
+	// it fails, for debugging. By default, it is deleted again.
+	KeepOnFailure bool
+	// Overwrite changes an existing resource with a different config to
+	// match the requested one. By default, an error is returned.
+	Overwrite bool
+	// SkipCapacityCheck creates the resource without checking that the
+	// storage pools of the resource group have enough free space.
+	SkipCapacityCheck bool
+	// NoStart leaves a newly created resource stopped, so that it can be
+	// started later.
+	NoStart bool
+	// SkipCollisionCheck creates an NFS export even if another one exists.
+	// As only one NFS server can run per node, the exports must be pinned
+	// to different nodes by the operator.
+	SkipCollisionCheck bool
+	// WaitSync waits until all replicas of a newly created resource are
+	// UpToDate, so that it is redundant when Create returns. If they are not
+	// in sync within SyncTimeout, an error is returned, but the resource is
+	// kept, as it is still syncing. A SyncTimeout of 0 uses
+	// DefaultSyncTimeout. The resource is not locked while waiting, so
+	// other operations can already use it.
+	WaitSync    bool
+	SyncTimeout time.Duration
+}
+
+// DeleteOptions change how a target or export is deleted.
+type DeleteOptions struct {
+	// KeepResource only removes the drbd-reactor config and keeps the
+	// LINSTOR resource and its data, so that it can be managed manually.
+	KeepResource bool
+}
+
+// CheckOverwrite checks that a resource deployed in resource group oldGroup
+// with the volumes oldVolumes can be changed in place to use newGroup and
+// newVolumes. Volumes may be added, but LINSTOR can not move a resource to a
+// different resource group, and existing volumes keep their size and file
+// system. Volumes are not removed, as that deletes their data; this is left
+// to the delete-volume commands.
+func CheckOverwrite(oldGroup, newGroup string, oldVolumes, newVolumes []VolumeConfig) error {
+	if oldGroup != newGroup {
+		return ValidationError(fmt.Sprintf("cannot change the resource group from %s to %s", oldGroup, newGroup))
+	}
+
+	for i := range oldVolumes {
+		found := false
+		for j := range newVolumes {
+			if oldVolumes[i].Number != newVolumes[j].Number {
+				continue
+			}
+
+			if oldVolumes[i].SizeKiB != newVolumes[j].SizeKiB {
+				return ValidationError(fmt.Sprintf("cannot change the size of existing volume %d", oldVolumes[i].Number))
+			}
+
+			if oldVolumes[i].FileSystem != newVolumes[j].FileSystem {
+				return ValidationError(fmt.Sprintf("cannot change the file system of existing volume %d", oldVolumes[i].Number))
+			}
+
+			found = true
+		}
+
+		if !found {
+			return ValidationError(fmt.Sprintf("cannot remove existing volume %d, use delete-volume to remove it", oldVolumes[i].Number))
+		}
+	}
+
+	return nil
+}
+
+// CreateOnlySettings are the settings of a resource that are stored with its
+// LINSTOR resource definition when it is created. They can not be changed
+// later.
+type CreateOnlySettings struct {
+	PlacementCount int
+	LayerList      []string
+	StoragePool    string
+	DrbdPort       int
+	DrbdMinor      int
+	Labels         map[string]string
+	DrbdOptions    map[string]string
+}
+
+// CheckCreateOnly checks that a resource deployed with the settings deployed
+// matches the settings requested for it. A DrbdPort or DrbdMinor of 0 in
+// requested matches any port or minor, as LINSTOR assigns them then.
+func CheckCreateOnly(deployed, requested CreateOnlySettings) error {
+	if deployed.PlacementCount != requested.PlacementCount {
+		return ValidationError(fmt.Sprintf("cannot change the placement count of an existing resource from %d to %d", deployed.PlacementCount, requested.PlacementCount))
+	}
+
+	if !equalStrings(deployed.LayerList, requested.LayerList) {
+		return ValidationError(fmt.Sprintf("cannot change the layer list of an existing resource from [%s] to [%s]", strings.Join(deployed.LayerList, ","), strings.Join(requested.LayerList, ",")))
+	}
+
+	if deployed.StoragePool != requested.StoragePool {
+		return ValidationError(fmt.Sprintf("cannot change the storage pool of an existing resource from '%s' to '%s'", deployed.StoragePool, requested.StoragePool))
+	}
+
+	if requested.DrbdPort != 0 && deployed.DrbdPort != requested.DrbdPort {
+		return ValidationError(fmt.Sprintf("cannot change the DRBD port of an existing resource from %d to %d", deployed.DrbdPort, requested.DrbdPort))
+	}
+
+	if requested.DrbdMinor != 0 && deployed.DrbdMinor != requested.DrbdMinor {
+		return ValidationError(fmt.Sprintf("cannot change the DRBD minor of an existing resource from %d to %d", deployed.DrbdMinor, requested.DrbdMinor))
+	}
+
+	if !equalStringMaps(deployed.Labels, requested.Labels) {
+		return ValidationError("cannot change the labels of an existing resource")
+	}
+
+	if !EqualDrbdOptions(deployed.DrbdOptions, requested.DrbdOptions) {
+		return ValidationError("cannot change the DRBD options of an existing resource")
+	}
+
+	return nil
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func equalStringMaps(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for k, v := range a {
+		if other, ok := b[k]; !ok || other != v {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ClusterPrivateVolume returns the configuration of the "cluster private
+// volume". Note that when a resource is created with gross size semantics,
+// the GROSS_SIZE flag applies to this volume as well, so its usable size is
+// slightly smaller than clusterPrivateVolumeSizeKiB.
 func ClusterPrivateVolume() VolumeConfig {
 	return VolumeConfig{
 		Number:              0,
@@ -38,6 +379,15 @@ func ClusterPrivateVolume() VolumeConfig {
 	}
 }
 
+// IsClusterPrivateVolume reports whether the volume definition describes a
+// "cluster private volume", which every resource created by LINSTOR Gateway
+// has.
+func IsClusterPrivateVolume(vd client.VolumeDefinition) bool {
+	return vd.VolumeNumber != nil && *vd.VolumeNumber == 0 &&
+		vd.SizeKib == clusterPrivateVolumeSizeKiB &&
+		vd.Props[apiconsts.NamespcFilesystem+"/Type"] == clusterPrivateVolumeFileSystem
+}
+
 func ClusterPrivateVolumeAgent(vol VolumeConfig, deployedVol client.Volume, resource string) *reactor.ResourceAgent {
 	return &reactor.ResourceAgent{
 		Type: "ocf:heartbeat:Filesystem",
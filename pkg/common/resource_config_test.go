@@ -0,0 +1,377 @@
+package common
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/LINBIT/golinstor/client"
+	"github.com/icza/gog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/LINBIT/linstor-gateway/pkg/reactor"
+)
+
+func TestCheckOverwrite(t *testing.T) {
+	t.Parallel()
+
+	volumes := []VolumeConfig{ClusterPrivateVolume(), {Number: 1, SizeKiB: 1024}}
+
+	cases := []struct {
+		name       string
+		newGroup   string
+		newVolumes []VolumeConfig
+		wantErr    bool
+	}{{
+		name:       "unchanged",
+		newGroup:   "rg",
+		newVolumes: volumes,
+	}, {
+		name:       "added volume",
+		newGroup:   "rg",
+		newVolumes: []VolumeConfig{ClusterPrivateVolume(), {Number: 1, SizeKiB: 1024}, {Number: 2, SizeKiB: 2048}},
+	}, {
+		name:       "removed volume",
+		newGroup:   "rg",
+		newVolumes: []VolumeConfig{ClusterPrivateVolume()},
+		wantErr:    true,
+	}, {
+		name:       "different resource group",
+		newGroup:   "other",
+		newVolumes: volumes,
+		wantErr:    true,
+	}, {
+		name:       "different size",
+		newGroup:   "rg",
+		newVolumes: []VolumeConfig{ClusterPrivateVolume(), {Number: 1, SizeKiB: 2048}},
+		wantErr:    true,
+	}, {
+		name:       "different file system",
+		newGroup:   "rg",
+		newVolumes: []VolumeConfig{ClusterPrivateVolume(), {Number: 1, SizeKiB: 1024, FileSystem: "ext4"}},
+		wantErr:    true,
+	}}
+
+	for i := range cases {
+		tcase := &cases[i]
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+			err := CheckOverwrite("rg", tcase.newGroup, volumes, tcase.newVolumes)
+			if tcase.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestCheckCreateOnly(t *testing.T) {
+	t.Parallel()
+
+	deployed := CreateOnlySettings{
+		PlacementCount: 2,
+		LayerList:      []string{"DRBD", "STORAGE"},
+		StoragePool:    "thin",
+		DrbdPort:       7000,
+		DrbdMinor:      1000,
+		Labels:         map[string]string{"team": "foo"},
+		DrbdOptions:    map[string]string{"protocol": "C", "quorum": "majority", "on-no-quorum": "io-error"},
+	}
+
+	cases := []struct {
+		name      string
+		change    func(s *CreateOnlySettings)
+		wantField string
+	}{{
+		name:   "unchanged",
+		change: func(s *CreateOnlySettings) {},
+	}, {
+		name:   "port and minor assigned by LINSTOR",
+		change: func(s *CreateOnlySettings) { s.DrbdPort, s.DrbdMinor = 0, 0 },
+	}, {
+		name:   "default DRBD options",
+		change: func(s *CreateOnlySettings) { s.DrbdOptions = map[string]string{"protocol": "C"} },
+	}, {
+		name:      "placement count",
+		change:    func(s *CreateOnlySettings) { s.PlacementCount = 3 },
+		wantField: "placement count",
+	}, {
+		name:      "layer list",
+		change:    func(s *CreateOnlySettings) { s.LayerList = []string{"STORAGE"} },
+		wantField: "layer list",
+	}, {
+		name:      "storage pool",
+		change:    func(s *CreateOnlySettings) { s.StoragePool = "thick" },
+		wantField: "storage pool",
+	}, {
+		name:      "DRBD port",
+		change:    func(s *CreateOnlySettings) { s.DrbdPort = 7001 },
+		wantField: "DRBD port",
+	}, {
+		name:      "DRBD minor",
+		change:    func(s *CreateOnlySettings) { s.DrbdMinor = 1001 },
+		wantField: "DRBD minor",
+	}, {
+		name:      "labels",
+		change:    func(s *CreateOnlySettings) { s.Labels = map[string]string{"team": "bar"} },
+		wantField: "labels",
+	}, {
+		name:      "removed labels",
+		change:    func(s *CreateOnlySettings) { s.Labels = nil },
+		wantField: "labels",
+	}, {
+		name:      "DRBD options",
+		change:    func(s *CreateOnlySettings) { s.DrbdOptions = map[string]string{"protocol": "A"} },
+		wantField: "DRBD options",
+	}}
+
+	for i := range cases {
+		tcase := &cases[i]
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+			requested := deployed
+			tcase.change(&requested)
+
+			err := CheckCreateOnly(deployed, requested)
+			if tcase.wantField == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.IsType(t, ValidationError(""), err)
+				assert.ErrorContains(t, err, tcase.wantField)
+			}
+		})
+	}
+}
+
+func TestCheckVolumeCount(t *testing.T) {
+	t.Parallel()
+
+	cfg := &reactor.PromoterConfig{
+		ID: "iscsi-target1",
+		Resources: map[string]reactor.PromoterResourceConfig{
+			"target1": {
+				Start: []reactor.StartEntry{
+					&reactor.ResourceAgent{Type: "ocf:heartbeat:Filesystem", Name: ClusterPrivateVolumeAgentName},
+					&reactor.ResourceAgent{Type: "ocf:heartbeat:iSCSITarget", Name: "target"},
+					&reactor.ResourceAgent{Type: "ocf:heartbeat:iSCSILogicalUnit", Name: "lu1"},
+					&reactor.SystemdService{Name: "example.service"},
+				},
+			},
+		},
+	}
+
+	cases := []struct {
+		name              string
+		volumeDefinitions []client.VolumeDefinition
+		wantErr           error
+	}{{
+		name: "consistent",
+		volumeDefinitions: []client.VolumeDefinition{
+			{VolumeNumber: gog.Ptr(int32(0))},
+			{VolumeNumber: gog.Ptr(int32(1))},
+		},
+	}, {
+		name: "missing volume definition",
+		volumeDefinitions: []client.VolumeDefinition{
+			{VolumeNumber: gog.Ptr(int32(0))},
+		},
+		wantErr: &InconsistentVolumesError{Linstor: 1, Configured: 2},
+	}, {
+		name: "additional volume definition",
+		volumeDefinitions: []client.VolumeDefinition{
+			{VolumeNumber: gog.Ptr(int32(0))},
+			{VolumeNumber: gog.Ptr(int32(1))},
+			{VolumeNumber: gog.Ptr(int32(2))},
+		},
+		wantErr: &InconsistentVolumesError{Linstor: 3, Configured: 2},
+	}}
+
+	for i := range cases {
+		tcase := &cases[i]
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+			err := CheckVolumeCount(cfg, tcase.volumeDefinitions, "ocf:heartbeat:Filesystem", "ocf:heartbeat:iSCSILogicalUnit")
+			if tcase.wantErr != nil {
+				assert.Equal(t, tcase.wantErr, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestValidDrbdNumbers(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name        string
+		port        int
+		minor       int
+		volumeCount int
+		wantErr     bool
+	}{{
+		name:        "automatic",
+		volumeCount: 2,
+	}, {
+		name:        "port and minor",
+		port:        7010,
+		minor:       1010,
+		volumeCount: 2,
+	}, {
+		name:        "negative port",
+		port:        -1,
+		volumeCount: 2,
+		wantErr:     true,
+	}, {
+		name:        "port too large",
+		port:        65536,
+		volumeCount: 2,
+		wantErr:     true,
+	}, {
+		name:        "last minor too large",
+		minor:       maxDrbdMinor,
+		volumeCount: 2,
+		wantErr:     true,
+	}}
+
+	for i := range cases {
+		tcase := &cases[i]
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+			err := ValidDrbdNumbers(tcase.port, tcase.minor, tcase.volumeCount)
+			if tcase.wantErr {
+				assert.Error(t, err)
+				assert.IsType(t, ValidationError(""), err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestValidUserVolumeNumber(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name    string
+		number  int
+		wantErr bool
+	}{{
+		name:   "first",
+		number: 1,
+	}, {
+		name:   "large",
+		number: 100,
+	}, {
+		name:    "cluster private volume",
+		number:  0,
+		wantErr: true,
+	}, {
+		name:    "negative",
+		number:  -1,
+		wantErr: true,
+	}}
+
+	for i := range cases {
+		tcase := &cases[i]
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+			err := ValidUserVolumeNumber(tcase.number)
+			if tcase.wantErr {
+				assert.Error(t, err)
+				assert.IsType(t, ValidationError(""), err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestValidGrossSize(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name      string
+		volume    *bool
+		grossSize bool
+		wantErr   bool
+	}{{
+		name:      "inherit net",
+		grossSize: false,
+	}, {
+		name:      "inherit gross",
+		grossSize: true,
+	}, {
+		name:      "gross on gross",
+		volume:    gog.Ptr(true),
+		grossSize: true,
+	}, {
+		name:      "net on net",
+		volume:    gog.Ptr(false),
+		grossSize: false,
+	}, {
+		name:      "net on gross",
+		volume:    gog.Ptr(false),
+		grossSize: true,
+		wantErr:   true,
+	}, {
+		name:      "gross on net",
+		volume:    gog.Ptr(true),
+		grossSize: false,
+		wantErr:   true,
+	}}
+
+	for i := range cases {
+		tcase := &cases[i]
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+			err := ValidGrossSize(VolumeConfig{Number: 1, SizeKiB: 1024, GrossSize: tcase.volume}, tcase.grossSize)
+			if tcase.wantErr {
+				assert.Error(t, err)
+				assert.IsType(t, ValidationError(""), err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestValidDescription(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name        string
+		description string
+		wantErr     bool
+	}{{
+		name: "empty",
+	}, {
+		name:        "text",
+		description: "Backups for team foo, see TICKET-123",
+	}, {
+		name:        "unicode at limit",
+		description: strings.Repeat("ä", maxDescriptionLength),
+	}, {
+		name:        "too long",
+		description: strings.Repeat("a", maxDescriptionLength+1),
+		wantErr:     true,
+	}, {
+		name:        "line break",
+		description: "first\nsecond",
+		wantErr:     true,
+	}, {
+		name:        "tab",
+		description: "first\tsecond",
+		wantErr:     true,
+	}}
+
+	for i := range cases {
+		tcase := &cases[i]
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+			err := ValidDescription(tcase.description)
+			if tcase.wantErr {
+				assert.Error(t, err)
+				assert.IsType(t, ValidationError(""), err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
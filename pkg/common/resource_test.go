@@ -0,0 +1,217 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/LINBIT/golinstor/client"
+	"github.com/stretchr/testify/assert"
+)
+
+func volumesWithNumbers(numbers ...int32) []client.Volume {
+	volumes := make([]client.Volume, 0, len(numbers))
+	for _, nr := range numbers {
+		volumes = append(volumes, client.Volume{VolumeNumber: nr})
+	}
+	return volumes
+}
+
+func volumeNumbers(volumes []client.Volume) []int32 {
+	numbers := make([]int32, 0, len(volumes))
+	for _, vol := range volumes {
+		numbers = append(numbers, vol.VolumeNumber)
+	}
+	return numbers
+}
+
+func TestRemoveVolume(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name      string
+		resources [][]int32
+		remove    int
+		expected  [][]int32
+	}{{
+		name:      "last volume",
+		resources: [][]int32{{0, 1, 2, 3}, {0, 1, 2, 3}},
+		remove:    3,
+		expected:  [][]int32{{0, 1, 2}, {0, 1, 2}},
+	}, {
+		name:      "non-terminal volume",
+		resources: [][]int32{{0, 1, 2, 3}, {0, 1, 2, 3}},
+		remove:    2,
+		expected:  [][]int32{{0, 1, 3}, {0, 1, 3}},
+	}, {
+		name:      "volume after a gap",
+		resources: [][]int32{{0, 1, 3, 4}},
+		remove:    3,
+		expected:  [][]int32{{0, 1, 4}},
+	}, {
+		name:      "differing order between nodes",
+		resources: [][]int32{{0, 1, 2, 3}, {3, 2, 1, 0}},
+		remove:    1,
+		expected:  [][]int32{{0, 2, 3}, {3, 2, 0}},
+	}, {
+		name:      "nonexistent volume",
+		resources: [][]int32{{0, 1, 2}},
+		remove:    5,
+		expected:  [][]int32{{0, 1, 2}},
+	}}
+
+	for i := range cases {
+		tcase := &cases[i]
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+			resources := make([]client.ResourceWithVolumes, 0, len(tcase.resources))
+			for _, numbers := range tcase.resources {
+				resources = append(resources, client.ResourceWithVolumes{Volumes: volumesWithNumbers(numbers...)})
+			}
+
+			RemoveVolume(resources, tcase.remove)
+
+			for j := range resources {
+				assert.Equal(t, tcase.expected[j], volumeNumbers(resources[j].Volumes))
+			}
+		})
+	}
+}
+
+func TestVolumeUpToDate(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name       string
+		diskStates []string
+		expected   bool
+	}{{
+		name:       "up to date on one node",
+		diskStates: []string{"Inconsistent", "UpToDate", "Diskless"},
+		expected:   true,
+	}, {
+		name:       "not up to date anywhere",
+		diskStates: []string{"Inconsistent", "Inconsistent", "Diskless"},
+		expected:   false,
+	}, {
+		name:     "not deployed",
+		expected: false,
+	}}
+
+	for i := range cases {
+		tcase := &cases[i]
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+			var resources []client.ResourceWithVolumes
+			for _, state := range tcase.diskStates {
+				resources = append(resources, client.ResourceWithVolumes{Volumes: []client.Volume{
+					{VolumeNumber: 0, State: client.VolumeState{DiskState: "UpToDate"}},
+					{VolumeNumber: 1, State: client.VolumeState{DiskState: state}},
+				}})
+			}
+
+			assert.Equal(t, tcase.expected, VolumeUpToDate(1)(resources))
+		})
+	}
+}
+
+func TestFailoverCandidates(t *testing.T) {
+	t.Parallel()
+
+	resource := func(node string, inUse bool, flags ...string) client.ResourceWithVolumes {
+		return client.ResourceWithVolumes{Resource: client.Resource{
+			NodeName: node,
+			Flags:    flags,
+			State:    &client.ResourceState{InUse: &inUse},
+		}}
+	}
+
+	cases := []struct {
+		name      string
+		resources []client.ResourceWithVolumes
+		expected  []string
+	}{{
+		name:      "two other diskful nodes",
+		resources: []client.ResourceWithVolumes{resource("node3", false), resource("node1", true), resource("node2", false)},
+		expected:  []string{"node2", "node3"},
+	}, {
+		name:      "diskless and tie-breaker are skipped",
+		resources: []client.ResourceWithVolumes{resource("node1", true), resource("node2", false, "DISKLESS"), resource("node3", false, "DISKLESS", "TIE_BREAKER"), resource("node4", false)},
+		expected:  []string{"node4"},
+	}, {
+		name:      "no other node",
+		resources: []client.ResourceWithVolumes{resource("node1", true), resource("node2", false, "DISKLESS", "TIE_BREAKER")},
+	}}
+
+	for i := range cases {
+		tcase := &cases[i]
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tcase.expected, FailoverCandidates(tcase.resources, "node1"))
+			assert.Equal(t, len(tcase.expected)+1, DiskfulReplicas(tcase.resources))
+			assert.True(t, InUseOn("node1")(tcase.resources))
+			assert.False(t, NotInUseOn("node1")(tcase.resources))
+			assert.True(t, NotInUseOn("node2")(tcase.resources))
+		})
+	}
+}
+
+func TestResourceStatus_Degraded(t *testing.T) {
+	t.Parallel()
+	okVolumes := []VolumeState{{Number: 0, State: ResourceStateOK}, {Number: 1, State: ResourceStateOK}}
+	cases := []struct {
+		name     string
+		status   ResourceStatus
+		expected bool
+	}{{
+		name:   "healthy",
+		status: ResourceStatus{State: ResourceStateOK, Service: ServiceStateStarted, Volumes: okVolumes},
+	}, {
+		name:     "stopped",
+		status:   ResourceStatus{State: ResourceStateOK, Service: ServiceStateStopped, Volumes: okVolumes},
+		expected: true,
+	}, {
+		name: "degraded volume",
+		status: ResourceStatus{State: ResourceStateOK, Service: ServiceStateStarted, Volumes: []VolumeState{
+			{Number: 0, State: ResourceStateOK},
+			{Number: 1, State: ResourceStateDegraded},
+		}},
+		expected: true,
+	}, {
+		name:     "bad resource",
+		status:   ResourceStatus{State: ResourceStateBad, Service: ServiceStateStarted, Volumes: okVolumes},
+		expected: true,
+	}, {
+		name:     "inconsistent",
+		status:   ResourceStatus{State: ResourceStateOK, Service: ServiceStateStarted, Volumes: okVolumes, Inconsistent: "inconsistent: 2 linstor volumes vs 3 configured"},
+		expected: true,
+	}, {
+		name:     "transitioning",
+		status:   ResourceStatus{State: ResourceStateOK, Service: ServiceStateTransitioning, Volumes: okVolumes},
+		expected: true,
+	}, {
+		name:   "all replicas deployed",
+		status: ResourceStatus{State: ResourceStateOK, Service: ServiceStateStarted, Volumes: okVolumes, Placement: &Placement{PlaceCount: 2}, Replicas: 2},
+	}, {
+		name:     "missing replicas",
+		status:   ResourceStatus{State: ResourceStateOK, Service: ServiceStateStarted, Volumes: okVolumes, Placement: &Placement{PlaceCount: 3}, Replicas: 2},
+		expected: true,
+	}}
+
+	for i := range cases {
+		tcase := &cases[i]
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tcase.expected, tcase.status.Degraded())
+		})
+	}
+}
+
+func TestWaitTimeout(t *testing.T) {
+	t.Parallel()
+	scaled := WaitTimeout{Base: 15 * time.Second, PerNode: 5 * time.Second}
+	assert.Equal(t, 15*time.Second, scaled.For(0))
+	assert.Equal(t, 30*time.Second, scaled.For(3))
+	assert.Equal(t, 40*time.Second, scaled.For(5))
+
+	fixed := WaitTimeout{Base: 15 * time.Second, PerNode: 5 * time.Second, Fixed: time.Minute}
+	assert.Equal(t, time.Minute, fixed.For(0))
+	assert.Equal(t, time.Minute, fixed.For(5))
+}
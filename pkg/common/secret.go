@@ -0,0 +1,33 @@
+package common
+
+import (
+	"strings"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+// RedactedSecret is shown in place of secrets such as passwords.
+const RedactedSecret = "****"
+
+// Redact hides the given secret. An empty secret stays empty, so that it is
+// still visible whether a secret is set at all.
+func Redact(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return RedactedSecret
+}
+
+// IgnoreSecrets is a cmp.Option that excludes all password fields from a
+// comparison, so that the resulting diff can be logged.
+var IgnoreSecrets = cmp.FilterPath(func(p cmp.Path) bool {
+	field, ok := p.Last().(cmp.StructField)
+	return ok && strings.Contains(strings.ToLower(field.Name()), "password")
+}, cmp.Ignore())
+
+// ConfigDiff returns a human readable diff (-existing +requested) of two
+// configs of a target or export. Their status and secrets are left out.
+func ConfigDiff(existing, requested interface{}) string {
+	return cmp.Diff(existing, requested, cmpopts.IgnoreTypes(ResourceStatus{}), IgnoreSecrets)
+}
@@ -0,0 +1,19 @@
+package common
+
+import "github.com/rck/unit"
+
+// sizeUnit is used to print volume sizes. It only contains binary units so
+// that the output is deterministic.
+var sizeUnit = unit.MustNewUnit(map[string]int64{
+	"B": 1,
+	"K": unit.K,
+	"M": unit.M,
+	"G": unit.G,
+	"T": unit.T,
+	"P": unit.P,
+})
+
+// FormatSize describes a size in KiB with a binary unit, e.g. "2T".
+func FormatSize(sizeKiB uint64) string {
+	return sizeUnit.MustNewValue(int64(sizeKiB)*unit.K, unit.None).String()
+}
@@ -0,0 +1,79 @@
+package common
+
+// Counts tallies the targets or exports of one protocol by their state.
+type Counts struct {
+	Total int `json:"total"`
+	// Healthy is the number of targets that are not degraded.
+	Healthy int `json:"healthy"`
+	// Degraded is the number of started targets that are degraded, see
+	// ResourceStatus.Degraded.
+	Degraded int `json:"degraded"`
+	// Stopped is the number of targets whose service is stopped. They are
+	// not counted as degraded.
+	Stopped int `json:"stopped"`
+	// DegradedVolumes is the number of volumes that are not OK, without the
+	// ones that are only resyncing.
+	DegradedVolumes int `json:"degraded_volumes"`
+	// ResyncingVolumes is the number of volumes that are not OK because a
+	// resync is in progress. They become OK without intervention.
+	ResyncingVolumes int `json:"resyncing_volumes"`
+}
+
+// Add tallies a target with the given status. The cluster private volume is
+// not counted; a problem with it shows in the state of the target.
+func (c *Counts) Add(status ResourceStatus) {
+	c.Total++
+	switch {
+	case !status.Degraded():
+		c.Healthy++
+	case status.Service == ServiceStateStopped:
+		c.Stopped++
+	default:
+		c.Degraded++
+	}
+
+	for _, vol := range status.Volumes {
+		if vol.Number != 0 {
+			c.AddVolume(vol)
+		}
+	}
+}
+
+// AddVolume tallies a single volume.
+func (c *Counts) AddVolume(vol VolumeState) {
+	if vol.State == ResourceStateOK {
+		return
+	}
+
+	if vol.Resyncing() {
+		c.ResyncingVolumes++
+	} else {
+		c.DegradedVolumes++
+	}
+}
+
+// Merge adds the counts of other to c.
+func (c *Counts) Merge(other Counts) {
+	c.Total += other.Total
+	c.Healthy += other.Healthy
+	c.Degraded += other.Degraded
+	c.Stopped += other.Stopped
+	c.DegradedVolumes += other.DegradedVolumes
+	c.ResyncingVolumes += other.ResyncingVolumes
+}
+
+// SummaryStats are the Counts of all targets and exports, per protocol.
+type SummaryStats struct {
+	ISCSI  Counts `json:"iscsi"`
+	NFS    Counts `json:"nfs"`
+	NVMeoF Counts `json:"nvmeof"`
+}
+
+// All returns the counts of all protocols together.
+func (s SummaryStats) All() Counts {
+	var all Counts
+	all.Merge(s.ISCSI)
+	all.Merge(s.NFS)
+	all.Merge(s.NVMeoF)
+	return all
+}
@@ -0,0 +1,77 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCounts(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name     string
+		statuses []ResourceStatus
+		expected Counts
+	}{{
+		name:     "empty",
+		expected: Counts{},
+	}, {
+		name: "healthy",
+		statuses: []ResourceStatus{{
+			State:   ResourceStateOK,
+			Service: ServiceStateStarted,
+			Volumes: []VolumeState{{Number: 0, State: ResourceStateOK}, {Number: 1, State: ResourceStateOK}},
+		}},
+		expected: Counts{Total: 1, Healthy: 1},
+	}, {
+		name: "stopped",
+		statuses: []ResourceStatus{{
+			State:   ResourceStateOK,
+			Service: ServiceStateStopped,
+		}},
+		expected: Counts{Total: 1, Stopped: 1},
+	}, {
+		name: "degraded and resyncing volumes",
+		statuses: []ResourceStatus{{
+			State:   ResourceStateDegraded,
+			Service: ServiceStateStarted,
+			Volumes: []VolumeState{
+				{Number: 0, State: ResourceStateBad},
+				{Number: 1, State: ResourceStateDegraded, Sync: SyncStateSyncTarget + " 50%"},
+				{Number: 2, State: ResourceStateBad},
+			},
+		}},
+		expected: Counts{Total: 1, Degraded: 1, DegradedVolumes: 1, ResyncingVolumes: 1},
+	}, {
+		name: "mixed",
+		statuses: []ResourceStatus{
+			{State: ResourceStateOK, Service: ServiceStateStarted},
+			{State: ResourceStateOK, Service: ServiceStateStarted, Inconsistent: "inconsistent"},
+			{State: ResourceStateOK, Service: ServiceStateStopped},
+			{State: ResourceStateBad, Service: ServiceStateTransitioning},
+		},
+		expected: Counts{Total: 4, Healthy: 1, Degraded: 2, Stopped: 1},
+	}}
+
+	for i := range cases {
+		tcase := &cases[i]
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+			var counts Counts
+			for _, status := range tcase.statuses {
+				counts.Add(status)
+			}
+			assert.Equal(t, tcase.expected, counts)
+		})
+	}
+}
+
+func TestSummaryStats_All(t *testing.T) {
+	t.Parallel()
+	stats := SummaryStats{
+		ISCSI:  Counts{Total: 3, Healthy: 2, Degraded: 1, DegradedVolumes: 2},
+		NFS:    Counts{Total: 1, Stopped: 1},
+		NVMeoF: Counts{Total: 2, Healthy: 1, Degraded: 1, ResyncingVolumes: 1},
+	}
+	assert.Equal(t, Counts{Total: 6, Healthy: 3, Degraded: 2, Stopped: 1, DegradedVolumes: 2, ResyncingVolumes: 1}, stats.All())
+}
@@ -1,9 +1,34 @@
 package common
 
-import "fmt"
+import (
+	"fmt"
+	"regexp"
+)
 
 type ValidationError string
 
 func (v ValidationError) Error() string {
 	return fmt.Sprintf("invalid config: %s", string(v))
 }
+
+// linstorNameRegexp matches the names LINSTOR accepts for resource
+// definitions.
+var linstorNameRegexp = regexp.MustCompile(`^[[:alpha:]_][[:alnum:]_-]*$`)
+
+// ValidLinstorName checks if name can be used as the name of a LINSTOR
+// resource definition.
+func ValidLinstorName(name string) error {
+	if len(name) < 2 {
+		return ValidationError(fmt.Sprintf("LINSTOR resource name %q too short (min. 2)", name))
+	}
+
+	if len(name) > 48 {
+		return ValidationError(fmt.Sprintf("LINSTOR resource name %q too long (max. 48)", name))
+	}
+
+	if !linstorNameRegexp.MatchString(name) {
+		return ValidationError(fmt.Sprintf("LINSTOR resource name %q must start with a letter or underscore and may only contain letters, digits, underscores, and dashes", name))
+	}
+
+	return nil
+}
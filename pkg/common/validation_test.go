@@ -0,0 +1,56 @@
+package common
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidLinstorName(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name    string
+		input   string
+		wantErr bool
+	}{{
+		name:  "simple",
+		input: "example",
+	}, {
+		name:  "dashes, digits and underscores",
+		input: "_db-01_data",
+	}, {
+		name:    "too short",
+		input:   "a",
+		wantErr: true,
+	}, {
+		name:  "longest",
+		input: strings.Repeat("a", 48),
+	}, {
+		name:    "too long",
+		input:   strings.Repeat("a", 49),
+		wantErr: true,
+	}, {
+		name:    "leading digit",
+		input:   "1example",
+		wantErr: true,
+	}, {
+		name:    "dot",
+		input:   "example.com",
+		wantErr: true,
+	}}
+
+	for i := range cases {
+		tcase := &cases[i]
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+			err := ValidLinstorName(tcase.input)
+			if tcase.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
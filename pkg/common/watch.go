@@ -0,0 +1,259 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type EventType string
+
+const (
+	// EventTargetAdded is emitted when a new target or export shows up.
+	EventTargetAdded EventType = "added"
+	// EventTargetRemoved is emitted when a target or export disappears.
+	EventTargetRemoved EventType = "removed"
+	// EventStateChanged is emitted when the overall state of a target
+	// changes, e.g. from "OK" to "Degraded".
+	EventStateChanged EventType = "state"
+	// EventServiceChanged is emitted when a target is started or stopped.
+	EventServiceChanged EventType = "service"
+	// EventVolumeChanged is emitted when the state of a single volume
+	// changes.
+	EventVolumeChanged EventType = "volume"
+)
+
+// Event describes a single state transition of a target or export.
+type Event struct {
+	// Seq is the sequence number assigned by an EventLog. It increases
+	// with every event.
+	Seq      uint64    `json:"seq,omitempty"`
+	Type     EventType `json:"type"`
+	Protocol string    `json:"protocol"`
+	Target   string    `json:"target"`
+	// Volume is the number of the affected volume. It is only set for
+	// volume events.
+	Volume *int      `json:"volume,omitempty"`
+	Old    string    `json:"old,omitempty"`
+	New    string    `json:"new,omitempty"`
+	Time   time.Time `json:"time"`
+}
+
+// Watch calls list every interval and sends an event on the returned channel
+// for every difference to the previous result. The first call to list only
+// establishes the baseline; if it fails, the error is returned. The channel is
+// closed when ctx is cancelled.
+//
+// list returns the status of every target, indexed by its name.
+func Watch(ctx context.Context, protocol string, interval time.Duration, list func(ctx context.Context) (map[string]ResourceStatus, error)) (<-chan Event, error) {
+	prev, err := list(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make(chan Event)
+	go func() {
+		defer close(events)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			cur, err := list(ctx)
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				log.WithError(err).WithField("protocol", protocol).Warn("failed to list targets, will retry")
+				continue
+			}
+
+			for _, event := range DiffTargets(protocol, prev, cur, time.Now()) {
+				select {
+				case events <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+			prev = cur
+		}
+	}()
+
+	return events, nil
+}
+
+// EventLog numbers events and keeps the most recent ones, so that a consumer
+// that reconnects can catch up on the events it missed.
+type EventLog struct {
+	size int
+
+	mu     sync.Mutex
+	seq    uint64
+	events []Event
+	// appended is closed and replaced on every call to Append.
+	appended chan struct{}
+}
+
+// NewEventLog returns an EventLog that keeps the last size events.
+func NewEventLog(size int) *EventLog {
+	return &EventLog{size: size, appended: make(chan struct{})}
+}
+
+// Append assigns the next sequence number to event and records it. If the
+// log is full, the oldest event is dropped.
+func (l *EventLog) Append(event Event) Event {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.seq++
+	event.Seq = l.seq
+	l.events = append(l.events, event)
+	if len(l.events) > l.size {
+		l.events = l.events[len(l.events)-l.size:]
+	}
+
+	close(l.appended)
+	l.appended = make(chan struct{})
+
+	return event
+}
+
+// Seq returns the sequence number of the last event, or 0 if there was none.
+func (l *EventLog) Seq() uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.seq
+}
+
+// Since returns the events with a sequence number greater than seq, and a
+// channel that is closed once the next event is appended. It returns an error
+// wrapping ErrEventsLost if some of these events were already dropped, or if
+// seq was never assigned, e.g. because it is from before a restart.
+func (l *EventLog) Since(seq uint64) ([]Event, <-chan struct{}, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if seq > l.seq {
+		return nil, nil, fmt.Errorf("%w: event %d was never sent, the last event is %d", ErrEventsLost, seq, l.seq)
+	}
+
+	if len(l.events) > 0 && l.events[0].Seq > seq+1 {
+		return nil, nil, fmt.Errorf("%w: events after %d are no longer available, the oldest event is %d", ErrEventsLost, seq, l.events[0].Seq)
+	}
+
+	start := len(l.events) - int(l.seq-seq)
+	events := make([]Event, len(l.events)-start)
+	copy(events, l.events[start:])
+
+	return events, l.appended, nil
+}
+
+// DiffTargets returns the events that lead from the target states in prev to
+// the ones in cur, in a stable order.
+func DiffTargets(protocol string, prev, cur map[string]ResourceStatus, now time.Time) []Event {
+	names := make([]string, 0, len(prev)+len(cur))
+	for name := range prev {
+		names = append(names, name)
+	}
+	for name := range cur {
+		if _, ok := prev[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var events []Event
+	for _, name := range names {
+		p, inPrev := prev[name]
+		c, inCur := cur[name]
+		event := Event{Protocol: protocol, Target: name, Time: now}
+
+		switch {
+		case !inPrev:
+			event.Type = EventTargetAdded
+			event.New = c.State.String()
+			events = append(events, event)
+		case !inCur:
+			event.Type = EventTargetRemoved
+			event.Old = p.State.String()
+			events = append(events, event)
+		default:
+			events = append(events, diffStatus(event, p, c)...)
+		}
+	}
+
+	return events
+}
+
+// diffStatus returns the events for a single target that exists in both
+// results. base is used as template for every event.
+func diffStatus(base Event, prev, cur ResourceStatus) []Event {
+	var events []Event
+
+	if prev.State != cur.State {
+		event := base
+		event.Type = EventStateChanged
+		event.Old, event.New = prev.State.String(), cur.State.String()
+		events = append(events, event)
+	}
+
+	if prev.Service != cur.Service {
+		event := base
+		event.Type = EventServiceChanged
+		event.Old, event.New = prev.Service.String(), cur.Service.String()
+		events = append(events, event)
+	}
+
+	prevVolumes := make(map[int]ResourceState, len(prev.Volumes))
+	for _, vol := range prev.Volumes {
+		prevVolumes[vol.Number] = vol.State
+	}
+	curVolumes := make(map[int]ResourceState, len(cur.Volumes))
+	for _, vol := range cur.Volumes {
+		curVolumes[vol.Number] = vol.State
+	}
+
+	numbers := make([]int, 0, len(prevVolumes)+len(curVolumes))
+	for nr := range prevVolumes {
+		numbers = append(numbers, nr)
+	}
+	for nr := range curVolumes {
+		if _, ok := prevVolumes[nr]; !ok {
+			numbers = append(numbers, nr)
+		}
+	}
+	sort.Ints(numbers)
+
+	for _, nr := range numbers {
+		p, inPrev := prevVolumes[nr]
+		c, inCur := curVolumes[nr]
+		if inPrev && inCur && p == c {
+			continue
+		}
+
+		nr := nr
+		event := base
+		event.Type = EventVolumeChanged
+		event.Volume = &nr
+		if inPrev {
+			event.Old = p.String()
+		}
+		if inCur {
+			event.New = c.String()
+		}
+		events = append(events, event)
+	}
+
+	return events
+}
@@ -0,0 +1,206 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/icza/gog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffTargets(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2022, 8, 1, 12, 0, 0, 0, time.UTC)
+	ok := ResourceStatus{
+		State:   ResourceStateOK,
+		Service: ServiceStateStarted,
+		Volumes: []VolumeState{{Number: 0, State: ResourceStateOK}, {Number: 1, State: ResourceStateOK}},
+	}
+	degraded := ResourceStatus{
+		State:   ResourceStateDegraded,
+		Service: ServiceStateStarted,
+		Volumes: []VolumeState{{Number: 0, State: ResourceStateOK}, {Number: 1, State: ResourceStateDegraded}},
+	}
+	stopped := ResourceStatus{
+		State:   ResourceStateOK,
+		Service: ServiceStateStopped,
+		Volumes: ok.Volumes,
+	}
+
+	cases := []struct {
+		name     string
+		prev     map[string]ResourceStatus
+		cur      map[string]ResourceStatus
+		expected []Event
+	}{{
+		name: "no change",
+		prev: map[string]ResourceStatus{"a": ok},
+		cur:  map[string]ResourceStatus{"a": ok},
+	}, {
+		name: "added and removed",
+		prev: map[string]ResourceStatus{"a": ok},
+		cur:  map[string]ResourceStatus{"b": degraded},
+		expected: []Event{
+			{Type: EventTargetRemoved, Protocol: "iscsi", Target: "a", Old: "OK", Time: now},
+			{Type: EventTargetAdded, Protocol: "iscsi", Target: "b", New: "Degraded", Time: now},
+		},
+	}, {
+		name: "degraded",
+		prev: map[string]ResourceStatus{"a": ok},
+		cur:  map[string]ResourceStatus{"a": degraded},
+		expected: []Event{
+			{Type: EventStateChanged, Protocol: "iscsi", Target: "a", Old: "OK", New: "Degraded", Time: now},
+			{Type: EventVolumeChanged, Protocol: "iscsi", Target: "a", Volume: gog.Ptr(1), Old: "OK", New: "Degraded", Time: now},
+		},
+	}, {
+		name: "stopped",
+		prev: map[string]ResourceStatus{"a": ok},
+		cur:  map[string]ResourceStatus{"a": stopped},
+		expected: []Event{
+			{Type: EventServiceChanged, Protocol: "iscsi", Target: "a", Old: "Started", New: "Stopped", Time: now},
+		},
+	}, {
+		name: "volume added",
+		prev: map[string]ResourceStatus{"a": {Volumes: ok.Volumes[:1]}},
+		cur:  map[string]ResourceStatus{"a": {Volumes: ok.Volumes}},
+		expected: []Event{
+			{Type: EventVolumeChanged, Protocol: "iscsi", Target: "a", Volume: gog.Ptr(1), New: "OK", Time: now},
+		},
+	}}
+
+	for i := range cases {
+		tcase := &cases[i]
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+			actual := DiffTargets("iscsi", tcase.prev, tcase.cur, now)
+			assert.Equal(t, tcase.expected, actual)
+		})
+	}
+}
+
+func TestWatch(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	results := []map[string]ResourceStatus{
+		{"a": {Service: ServiceStateStarted}},
+		{"a": {Service: ServiceStateStopped}},
+	}
+	calls := 0
+	events, err := Watch(ctx, "nfs", time.Millisecond, func(ctx context.Context) (map[string]ResourceStatus, error) {
+		result := results[len(results)-1]
+		if calls < len(results) {
+			result = results[calls]
+		}
+		calls++
+		return result, nil
+	})
+	assert.NoError(t, err)
+
+	event := <-events
+	assert.Equal(t, EventServiceChanged, event.Type)
+	assert.Equal(t, "nfs", event.Protocol)
+	assert.Equal(t, "a", event.Target)
+
+	cancel()
+	for range events {
+	}
+}
+
+func eventSeqs(events []Event) []uint64 {
+	seqs := make([]uint64, 0, len(events))
+	for _, event := range events {
+		seqs = append(seqs, event.Seq)
+	}
+	return seqs
+}
+
+func TestEventLog(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name     string
+		appended int
+		since    uint64
+		expected []uint64
+		lost     bool
+	}{{
+		name:     "empty",
+		expected: []uint64{},
+	}, {
+		name:     "up to date",
+		appended: 3,
+		since:    3,
+		expected: []uint64{},
+	}, {
+		name:     "replay",
+		appended: 3,
+		since:    1,
+		expected: []uint64{2, 3},
+	}, {
+		name:     "replay all",
+		appended: 3,
+		since:    0,
+		expected: []uint64{1, 2, 3},
+	}, {
+		name:     "replay oldest kept",
+		appended: 6,
+		since:    2,
+		expected: []uint64{3, 4, 5, 6},
+	}, {
+		name:     "dropped",
+		appended: 6,
+		since:    1,
+		lost:     true,
+	}, {
+		name:     "never sent",
+		appended: 3,
+		since:    4,
+		lost:     true,
+	}}
+
+	for i := range cases {
+		tcase := &cases[i]
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+			eventLog := NewEventLog(4)
+			for i := 0; i < tcase.appended; i++ {
+				eventLog.Append(Event{Type: EventStateChanged, Target: "a"})
+			}
+			assert.Equal(t, uint64(tcase.appended), eventLog.Seq())
+
+			events, _, err := eventLog.Since(tcase.since)
+			if tcase.lost {
+				assert.True(t, errors.Is(err, ErrEventsLost))
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tcase.expected, eventSeqs(events))
+			}
+		})
+	}
+}
+
+func TestEventLog_Notify(t *testing.T) {
+	t.Parallel()
+	eventLog := NewEventLog(4)
+
+	events, appended, err := eventLog.Since(0)
+	assert.NoError(t, err)
+	assert.Empty(t, events)
+
+	select {
+	case <-appended:
+		t.Fatal("notified before an event was appended")
+	default:
+	}
+
+	event := eventLog.Append(Event{Type: EventTargetAdded, Target: "a"})
+	assert.Equal(t, uint64(1), event.Seq)
+	<-appended
+
+	events, _, err = eventLog.Since(0)
+	assert.NoError(t, err)
+	assert.Equal(t, []Event{event}, events)
+}
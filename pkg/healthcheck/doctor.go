@@ -0,0 +1,217 @@
+package healthcheck
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	apiconsts "github.com/LINBIT/golinstor"
+	"github.com/LINBIT/golinstor/client"
+	"github.com/LINBIT/golinstor/devicelayerkind"
+
+	"github.com/LINBIT/linstor-gateway/pkg/linstorcontrol"
+	"github.com/LINBIT/linstor-gateway/pkg/reactor"
+)
+
+// diagnosticTimeout limits how long a single diagnostic may take.
+const diagnosticTimeout = 5 * time.Second
+
+// errLinstorUnreachable is reported by the diagnostics that need LINSTOR if
+// the controller can not be reached.
+var errLinstorUnreachable = errors.New("skipped, the LINSTOR controller is not reachable")
+
+// Diagnosis is the result of a single diagnostic run by Diagnose.
+type Diagnosis struct {
+	Check string `json:"check"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+	// Hint describes how to fix the problem. It is only set if the
+	// diagnostic failed.
+	Hint string `json:"hint,omitempty"`
+}
+
+// diagnostic checks for one common misconfiguration.
+type diagnostic struct {
+	name string
+	hint string
+	// needsLinstor is set if the diagnostic queries the LINSTOR
+	// controller. It is skipped if the controller can not be reached.
+	needsLinstor bool
+	run          func(ctx context.Context) error
+}
+
+// Diagnose checks the LINSTOR cluster and the current node for common
+// misconfigurations, such as missing storage pools or a missing drbd-reactor.
+// resourceGroup is the resource group that is expected to exist. Every
+// diagnostic is reported on its own, even if an earlier one failed.
+func Diagnose(ctx context.Context, cli *linstorcontrol.Linstor, resourceGroup string) []Diagnosis {
+	diagnostics := append(linstorDiagnostics(cli, resourceGroup), localDiagnostics()...)
+	return runDiagnostics(ctx, diagnostics)
+}
+
+func runDiagnostics(ctx context.Context, diagnostics []diagnostic) []Diagnosis {
+	results := make([]Diagnosis, 0, len(diagnostics))
+	linstorReachable := true
+	for i, d := range diagnostics {
+		var err error
+		if d.needsLinstor && !linstorReachable {
+			err = errLinstorUnreachable
+		} else {
+			checkCtx, cancel := context.WithTimeout(ctx, diagnosticTimeout)
+			err = d.run(checkCtx)
+			cancel()
+		}
+
+		// The first diagnostic checks that the controller is reachable.
+		if i == 0 && d.needsLinstor && err != nil {
+			linstorReachable = false
+		}
+
+		result := Diagnosis{Check: d.name, OK: err == nil}
+		if err != nil {
+			result.Error = err.Error()
+			if err != errLinstorUnreachable {
+				result.Hint = d.hint
+			}
+		}
+		results = append(results, result)
+	}
+
+	return results
+}
+
+func linstorDiagnostics(cli *linstorcontrol.Linstor, resourceGroup string) []diagnostic {
+	return []diagnostic{{
+		name:         "LINSTOR controller is reachable",
+		hint:         "Pass the URL of the LINSTOR controller with --controllers or LS_CONTROLLERS, and make sure that the controller is running.",
+		needsLinstor: true,
+		run: func(ctx context.Context) error {
+			_, err := cli.Controller.GetVersion(ctx)
+			return err
+		},
+	}, {
+		name:         "At least one satellite is online",
+		hint:         "Start the satellites with \"systemctl start linstor-satellite\" and check their state with \"linstor node list\".",
+		needsLinstor: true,
+		run: func(ctx context.Context) error {
+			nodes, err := cli.Nodes.GetAll(ctx)
+			if err != nil {
+				return err
+			}
+			return checkSatellitesOnline(nodes)
+		},
+	}, {
+		name:         "Satellites support DRBD",
+		hint:         "Install DRBD 9 and drbd-utils on all satellites, then run \"linstor node reconnect\" for them.",
+		needsLinstor: true,
+		run: func(ctx context.Context) error {
+			nodes, err := cli.Nodes.GetAll(ctx)
+			if err != nil {
+				return err
+			}
+			return checkDrbdSupported(nodes)
+		},
+	}, {
+		name:         "At least one storage pool exists",
+		hint:         "Create a storage pool, e.g. with \"linstor storage-pool create lvmthin NODE POOL VG/LV\".",
+		needsLinstor: true,
+		run: func(ctx context.Context) error {
+			pools, err := cli.Nodes.GetStoragePoolView(ctx)
+			if err != nil {
+				return err
+			}
+			return checkStoragePools(pools)
+		},
+	}, {
+		name:         fmt.Sprintf("Resource group '%s' exists", resourceGroup),
+		hint:         fmt.Sprintf("Create the resource group with \"linstor resource-group create %s --storage-pool POOL --place-count 2\", or choose an existing one with --resource-group.", resourceGroup),
+		needsLinstor: true,
+		run: func(ctx context.Context) error {
+			return cli.CheckResourceGroup(ctx, resourceGroup)
+		},
+	}}
+}
+
+func localDiagnostics() []diagnostic {
+	return []diagnostic{{
+		name: "drbd-reactor is running on this node",
+		hint: "Install the drbd-reactor package on every node that should run targets or exports, and run \"systemctl enable --now drbd-reactor\".",
+		run: func(ctx context.Context) error {
+			return unitStartedAndEnabled("drbd-reactor.service")
+		},
+	}, {
+		name: "drbd-reactor config directory is writable",
+		hint: fmt.Sprintf("Create the directory with \"mkdir -p %s\", or choose another one with --reactor-config-dir.", reactor.ConfigDir),
+		run: func(ctx context.Context) error {
+			return reactor.CheckConfigDir(reactor.ConfigDir)
+		},
+	}}
+}
+
+// isSatellite reports whether LINSTOR can place resources on the node.
+func isSatellite(node *client.Node) bool {
+	return strings.EqualFold(node.Type, apiconsts.ValNodeTypeStlt) || strings.EqualFold(node.Type, apiconsts.ValNodeTypeCmbd)
+}
+
+func isOnline(node *client.Node) bool {
+	return strings.EqualFold(node.ConnectionStatus, "ONLINE")
+}
+
+func checkSatellitesOnline(nodes []client.Node) error {
+	satellites := 0
+	for i := range nodes {
+		if !isSatellite(&nodes[i]) {
+			continue
+		}
+		satellites++
+		if isOnline(&nodes[i]) {
+			return nil
+		}
+	}
+
+	if satellites == 0 {
+		return errors.New("no satellites are registered")
+	}
+
+	return fmt.Errorf("none of the %d satellites is online", satellites)
+}
+
+func checkDrbdSupported(nodes []client.Node) error {
+	var missing []string
+	for i := range nodes {
+		if !isSatellite(&nodes[i]) || !isOnline(&nodes[i]) {
+			continue
+		}
+
+		supported := false
+		for _, layer := range nodes[i].ResourceLayers {
+			if layer == devicelayerkind.Drbd {
+				supported = true
+				break
+			}
+		}
+		if !supported {
+			missing = append(missing, nodes[i].Name)
+		}
+	}
+
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("DRBD is not available on %s", strings.Join(missing, ", "))
+	}
+
+	return nil
+}
+
+func checkStoragePools(pools []client.StoragePool) error {
+	for _, pool := range pools {
+		if pool.ProviderKind != client.DISKLESS {
+			return nil
+		}
+	}
+
+	return errors.New("there are no diskful storage pools")
+}
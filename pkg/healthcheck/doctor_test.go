@@ -0,0 +1,127 @@
+package healthcheck
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/LINBIT/golinstor/client"
+	"github.com/LINBIT/golinstor/devicelayerkind"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunDiagnostics(t *testing.T) {
+	t.Parallel()
+	ok := func(ctx context.Context) error { return nil }
+	failing := func(ctx context.Context) error { return errors.New("failed") }
+
+	cases := []struct {
+		name        string
+		diagnostics []diagnostic
+		expected    []Diagnosis
+	}{{
+		name: "all ok",
+		diagnostics: []diagnostic{
+			{name: "controller", needsLinstor: true, run: ok},
+			{name: "pools", needsLinstor: true, run: ok},
+			{name: "local", run: ok},
+		},
+		expected: []Diagnosis{
+			{Check: "controller", OK: true},
+			{Check: "pools", OK: true},
+			{Check: "local", OK: true},
+		},
+	}, {
+		name: "failures are reported independently",
+		diagnostics: []diagnostic{
+			{name: "controller", needsLinstor: true, run: ok},
+			{name: "pools", hint: "create a pool", needsLinstor: true, run: failing},
+			{name: "local", run: ok},
+		},
+		expected: []Diagnosis{
+			{Check: "controller", OK: true},
+			{Check: "pools", Error: "failed", Hint: "create a pool"},
+			{Check: "local", OK: true},
+		},
+	}, {
+		name: "unreachable controller",
+		diagnostics: []diagnostic{
+			{name: "controller", hint: "start the controller", needsLinstor: true, run: failing},
+			{name: "pools", hint: "create a pool", needsLinstor: true, run: ok},
+			{name: "local", run: ok},
+		},
+		expected: []Diagnosis{
+			{Check: "controller", Error: "failed", Hint: "start the controller"},
+			{Check: "pools", Error: errLinstorUnreachable.Error()},
+			{Check: "local", OK: true},
+		},
+	}}
+
+	for i := range cases {
+		tcase := &cases[i]
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tcase.expected, runDiagnostics(context.Background(), tcase.diagnostics))
+		})
+	}
+}
+
+func TestCheckNodes(t *testing.T) {
+	t.Parallel()
+	drbd := []devicelayerkind.DeviceLayerKind{devicelayerkind.Drbd, devicelayerkind.Storage}
+
+	cases := []struct {
+		name          string
+		nodes         []client.Node
+		expectOnline  bool
+		expectDrbdErr bool
+	}{{
+		name: "online satellite",
+		nodes: []client.Node{
+			{Name: "ctrl", Type: "CONTROLLER", ConnectionStatus: "ONLINE"},
+			{Name: "node1", Type: "SATELLITE", ConnectionStatus: "ONLINE", ResourceLayers: drbd},
+			{Name: "node2", Type: "SATELLITE", ConnectionStatus: "OFFLINE"},
+		},
+		expectOnline: true,
+	}, {
+		name:         "no satellites",
+		nodes:        []client.Node{{Name: "ctrl", Type: "CONTROLLER", ConnectionStatus: "ONLINE"}},
+		expectOnline: false,
+	}, {
+		name:         "all offline",
+		nodes:        []client.Node{{Name: "node1", Type: "SATELLITE", ConnectionStatus: "OFFLINE"}},
+		expectOnline: false,
+	}, {
+		name: "without drbd",
+		nodes: []client.Node{
+			{Name: "node1", Type: "COMBINED", ConnectionStatus: "ONLINE", ResourceLayers: drbd},
+			{Name: "node2", Type: "SATELLITE", ConnectionStatus: "ONLINE", ResourceLayers: []devicelayerkind.DeviceLayerKind{devicelayerkind.Storage}},
+		},
+		expectOnline:  true,
+		expectDrbdErr: true,
+	}}
+
+	for i := range cases {
+		tcase := &cases[i]
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tcase.expectOnline, checkSatellitesOnline(tcase.nodes) == nil)
+			err := checkDrbdSupported(tcase.nodes)
+			if tcase.expectDrbdErr {
+				assert.EqualError(t, err, "DRBD is not available on node2")
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestCheckStoragePools(t *testing.T) {
+	t.Parallel()
+	assert.Error(t, checkStoragePools(nil))
+	assert.Error(t, checkStoragePools([]client.StoragePool{{StoragePoolName: "DfltDisklessStorPool", ProviderKind: client.DISKLESS}}))
+	assert.NoError(t, checkStoragePools([]client.StoragePool{
+		{StoragePoolName: "DfltDisklessStorPool", ProviderKind: client.DISKLESS},
+		{StoragePoolName: "thinpool", ProviderKind: client.LVM_THIN},
+	}))
+}
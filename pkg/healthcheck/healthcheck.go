@@ -4,6 +4,8 @@ import (
 	"errors"
 	"fmt"
 	"github.com/fatih/color"
+
+	"github.com/LINBIT/linstor-gateway/pkg/linstorcontrol"
 )
 
 var bold = color.New(color.Bold).SprintfFunc()
@@ -52,11 +54,11 @@ func contains(haystack []string, needle string) bool {
 	return false
 }
 
-func CheckRequirements(controllers []string) error {
+func CheckRequirements(cli *linstorcontrol.Linstor) error {
 	errs := 0
 	err := category(
 		"LINSTOR",
-		&checkLinstor{controllers},
+		&checkLinstor{cli},
 		&checkFileWhitelist{},
 	)
 	if err != nil {
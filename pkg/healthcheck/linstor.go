@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"github.com/LINBIT/linstor-gateway/pkg/linstorcontrol"
+	"github.com/LINBIT/linstor-gateway/pkg/reactor"
 	"github.com/fatih/color"
 	"github.com/pelletier/go-toml"
 	"github.com/spf13/viper"
@@ -15,17 +16,13 @@ import (
 const satelliteConfigFile = "/etc/linstor/linstor_satellite.toml"
 
 type checkLinstor struct {
-	controllers []string
+	cli *linstorcontrol.Linstor
 }
 
 func (c *checkLinstor) check() error {
 	ctx, done := context.WithTimeout(context.Background(), 5*time.Second)
 	defer done()
-	cli, err := linstorcontrol.Default(c.controllers)
-	if err != nil {
-		return err
-	}
-	_, err = cli.Controller.GetVersion(ctx)
+	_, err := c.cli.Controller.GetVersion(ctx)
 	if err != nil {
 		return err
 	}
@@ -65,7 +62,7 @@ func (c *checkFileWhitelist) check() error {
 	}
 
 	expect := []string{
-		"/etc/systemd/system", "/etc/systemd/system/linstor-satellite.service.d", "/etc/drbd-reactor.d",
+		"/etc/systemd/system", "/etc/systemd/system/linstor-satellite.service.d", reactor.ConfigDir,
 	}
 	if !containsAll(satelliteConfig.Files.AllowExtFiles, expect) {
 		return fmt.Errorf("unexpected allowExtFiles value")
@@ -79,7 +76,7 @@ func (c *checkFileWhitelist) format(err error) string {
 	fmt.Fprintf(&b, "      %s\n", err.Error())
 	fmt.Fprintf(&b, "      Edit the LINSTOR satellite configuration file (%s) to include the following:\n\n", bold(satelliteConfigFile))
 	fmt.Fprintf(&b, "      [files]\n")
-	fmt.Fprintf(&b, `        allowExtFiles = ["/etc/systemd/system", "/etc/systemd/system/linstor-satellite.service.d", "/etc/drbd-reactor.d"]`+"\n\n")
+	fmt.Fprintf(&b, `        allowExtFiles = ["/etc/systemd/system", "/etc/systemd/system/linstor-satellite.service.d", "%s"]`+"\n\n", reactor.ConfigDir)
 	fmt.Fprintf(&b, "      and execute %s.\n", bold("systemctl restart linstor-satellite.service"))
 	return b.String()
 }
@@ -4,6 +4,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"regexp"
+	"strings"
+
+	"github.com/LINBIT/linstor-gateway/pkg/common"
 )
 
 var (
@@ -24,6 +27,13 @@ var (
 	regexResourceName = `[[:alpha:]][[:alnum:]-]+`
 
 	regexWWN = regexp.MustCompile(`^(` + regexIQN + `):(` + regexResourceName + `)$`)
+
+	// The individual components of an IQN, used to explain which part of
+	// an invalid IQN is malformed.
+	regexIQNDate         = regexp.MustCompile(`^\d{4}-[0-1][0-9]$`)
+	regexIQNAuthority    = regexp.MustCompile(`^[^ _]*\.[^ _]*$`)
+	regexIQNResourceName = regexp.MustCompile(`^` + regexResourceName + `$`)
+	exampleIQN           = "iqn.2019-08.com.linbit:example"
 )
 
 type Iqn [2]string
@@ -54,7 +64,7 @@ func (i *Iqn) UnmarshalText(b []byte) error {
 	match := regexWWN.FindStringSubmatch(string(b))
 
 	if match == nil || len(match) != 3 {
-		return invalidIqn(b)
+		return invalidIqn{iqn: string(b), reason: diagnoseIqn(string(b))}
 	}
 
 	*i = [2]string{match[1], match[2]}
@@ -90,8 +100,56 @@ func NewIqn(s string) (Iqn, error) {
 	return iqn, nil
 }
 
-type invalidIqn string
+// diagnoseIqn returns a description of the first malformed component of an
+// invalid IQN.
+func diagnoseIqn(s string) string {
+	if s == "" {
+		return "the IQN is empty"
+	}
+
+	rest := strings.TrimPrefix(s, "iqn.")
+	if rest == s {
+		return "it does not start with \"iqn.\""
+	}
+
+	sep := strings.LastIndex(rest, ":")
+	if sep == -1 {
+		return "the unique name after the \":\" is missing"
+	}
+	prefix, name := rest[:sep], rest[sep+1:]
+
+	date, authority := prefix, ""
+	if i := strings.Index(prefix, "."); i != -1 {
+		date, authority = prefix[:i], prefix[i+1:]
+	}
+	if !regexIQNDate.MatchString(date) {
+		return fmt.Sprintf("the date \"%s\" is not of the form YYYY-MM", date)
+	}
+
+	if authority == "" {
+		return "the reversed domain name after the date is missing"
+	}
+	if !regexIQNAuthority.MatchString(authority) {
+		return fmt.Sprintf("the naming authority \"%s\" is not a reversed domain name such as \"com.linbit\" (it needs at least one \".\" and must not contain spaces or underscores)", authority)
+	}
+
+	if !regexIQNResourceName.MatchString(name) {
+		return fmt.Sprintf("the unique name \"%s\" is not valid: it must be at least 2 characters long, start with a letter, and contain only letters, digits and \"-\"", name)
+	}
+
+	return "it does not match the expected format"
+}
+
+type invalidIqn struct {
+	iqn    string
+	reason string
+}
 
 func (i invalidIqn) Error() string {
-	return fmt.Sprintf("'%s' is not a valid IQN. expected format: iqn.YYYY-MM.DOTTED.DOMAIN.NAME:UNIQUE_RESOURCE_NAME", string(i))
+	return fmt.Sprintf("'%s' is not a valid IQN: %s. expected format: iqn.YYYY-MM.DOTTED.DOMAIN.NAME:UNIQUE_RESOURCE_NAME, for example %s", i.iqn, i.reason, exampleIQN)
+}
+
+// Unwrap allows callers to detect an invalid IQN as common.ValidationError.
+func (i invalidIqn) Unwrap() error {
+	return common.ValidationError(i.reason)
 }
@@ -72,3 +72,48 @@ func TestCheckIQN(t *testing.T) {
 		})
 	}
 }
+
+func TestIQNErrorNamesComponent(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		descr    string
+		input    string
+		expected string
+	}{{
+		descr:    "missing prefix",
+		input:    "2019-08.com.linbit:example",
+		expected: `does not start with "iqn."`,
+	}, {
+		descr:    "bad date",
+		input:    "iqn.19-08.com.linbit:example",
+		expected: `the date "19-08"`,
+	}, {
+		descr:    "missing domain",
+		input:    "iqn.2019-08:example",
+		expected: "reversed domain name after the date is missing",
+	}, {
+		descr:    "underscore in domain",
+		input:    "iqn.2019-08.com.lin_bit:example",
+		expected: `the naming authority "com.lin_bit"`,
+	}, {
+		descr:    "missing unique part",
+		input:    "iqn.2019-08.com.linbit",
+		expected: "unique name after the",
+	}, {
+		descr:    "bad unique part",
+		input:    "iqn.2019-08.com.linbit:1example",
+		expected: `the unique name "1example"`,
+	}}
+
+	for i := range cases {
+		tcase := &cases[i]
+		t.Run(tcase.descr, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := iscsi.NewIqn(tcase.input)
+			assert.ErrorContains(t, err, tcase.expected)
+			assert.ErrorContains(t, err, "for example iqn.2019-08.com.linbit:example")
+		})
+	}
+}
@@ -6,9 +6,11 @@ import (
 	"errors"
 	"fmt"
 	"sort"
+	"strings"
 	"time"
 
 	"github.com/LINBIT/golinstor/client"
+	"github.com/google/go-cmp/cmp"
 	log "github.com/sirupsen/logrus"
 
 	"github.com/LINBIT/linstor-gateway/pkg/common"
@@ -30,6 +32,12 @@ func New(controllers []string) (*ISCSI, error) {
 	return &ISCSI{cli}, nil
 }
 
+// NewWithClient creates a new ISCSI instance that uses an existing LINSTOR
+// client.
+func NewWithClient(cli *linstorcontrol.Linstor) *ISCSI {
+	return &ISCSI{cli}
+}
+
 func (i *ISCSI) Get(ctx context.Context, iqn Iqn) (*ResourceConfig, error) {
 	cfg, path, err := reactor.FindConfig(ctx, i.cli.Client, fmt.Sprintf(IDFormat, iqn.WWN()))
 	if err != nil {
@@ -37,7 +45,7 @@ func (i *ISCSI) Get(ctx context.Context, iqn Iqn) (*ResourceConfig, error) {
 	}
 
 	if cfg == nil {
-		return nil, nil
+		return nil, fmt.Errorf("target \"%s\" %w", iqn, common.ErrNotFound)
 	}
 
 	resourceDefinition, resourceGroup, volumeDefinitions, resources, err := cfg.DeployedResources(ctx, i.cli.Client)
@@ -45,12 +53,20 @@ func (i *ISCSI) Get(ctx context.Context, iqn Iqn) (*ResourceConfig, error) {
 		return nil, fmt.Errorf("failed to fetch existing deployment: %w", err)
 	}
 
+	inconsistent := common.CheckVolumeCount(cfg, common.ExportedVolumeDefinitions(volumeDefinitions), volumeAgentTypes...)
+
 	deployedCfg, err := FromPromoter(cfg, resourceDefinition, volumeDefinitions)
 	if err != nil {
+		if inconsistent != nil {
+			return nil, fmt.Errorf("target \"%s\" is %w", iqn, inconsistent)
+		}
 		return nil, fmt.Errorf("unknown existing reactor config: %w", err)
 	}
 
 	deployedCfg.Status = linstorcontrol.StatusFromResources(path, resourceDefinition, resourceGroup, resources)
+	if inconsistent != nil {
+		deployedCfg.Status.MarkInconsistent(inconsistent)
+	}
 
 	return deployedCfg, nil
 }
@@ -58,9 +74,27 @@ func (i *ISCSI) Get(ctx context.Context, iqn Iqn) (*ResourceConfig, error) {
 // Create creates an iSCSI target according to the resource configuration
 // described in rsc. It automatically prepends a "cluster private volume" to the
 // list of volumes, so volume numbers must start at 1.
-func (i *ISCSI) Create(ctx context.Context, rsc *ResourceConfig) (*ResourceConfig, error) {
+//
+// If a later step fails after the LINSTOR resource was created, the resource
+// is deleted again, unless opts.KeepOnFailure is set.
+//
+// If the target already exists with a different config, an error is returned,
+// unless opts.Overwrite is set. In that case, the existing target is changed to
+// match rsc.
+//
+// Before a new resource is created, the storage pools of the resource group
+// are checked for enough free space, unless opts.SkipCapacityCheck is set. A
+// new target is started, unless opts.NoStart is set.
+func (i *ISCSI) Create(ctx context.Context, rsc *ResourceConfig, opts common.CreateOptions) (*ResourceConfig, error) {
 	rsc.FillDefaults()
 
+	for _, vol := range rsc.Volumes {
+		err := common.ValidUserVolumeNumber(vol.Number)
+		if err != nil {
+			return nil, fmt.Errorf("validation failed: %w", err)
+		}
+	}
+
 	// prepend cluster private volume; it should always be the first volume and have number 0
 	rsc.Volumes = append([]common.VolumeConfig{common.ClusterPrivateVolume()}, rsc.Volumes...)
 
@@ -69,6 +103,24 @@ func (i *ISCSI) Create(ctx context.Context, rsc *ResourceConfig) (*ResourceConfi
 		return nil, fmt.Errorf("validation failed: %w", err)
 	}
 
+	// waiting for the sync outlives the locks, which cancel their context
+	// when they are released
+	syncCtx := ctx
+
+	ctx, unlock, err := i.cli.Lock(ctx, rsc.IQN.WWN())
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	// Cleanup locks the LINSTOR resource, so that it does not delete it
+	// before the config is written.
+	ctx, unlockResource, err := i.cli.Lock(ctx, rsc.linstorResourceName())
+	if err != nil {
+		return nil, err
+	}
+	defer unlockResource()
+
 	cfg, path, err := reactor.FindConfig(ctx, i.cli.Client, fmt.Sprintf(IDFormat, rsc.IQN.WWN()))
 	if err != nil {
 		return nil, fmt.Errorf("failed to check for existing config: %w", err)
@@ -85,25 +137,77 @@ func (i *ISCSI) Create(ctx context.Context, rsc *ResourceConfig) (*ResourceConfi
 			return nil, fmt.Errorf("unknown existing reactor config: %w", err)
 		}
 
+		status := linstorcontrol.StatusFromResources(path, resourceDefinition, resourceGroup, resources)
+
+		// keep the LINSTOR resource name unless a different one is requested
+		if rsc.ResourceName == "" {
+			rsc.ResourceName = deployedCfg.ResourceName
+		}
+
 		if !rsc.Matches(deployedCfg) {
-			return nil, errors.New("resource already exists with incompatible config")
+			if !opts.Overwrite {
+				return nil, common.IncompatibleConfigError(deployedCfg, rsc)
+			}
+
+			return i.overwrite(ctx, deployedCfg, rsc, status)
 		}
 
-		deployedCfg.Status = linstorcontrol.StatusFromResources(path, resourceDefinition, resourceGroup, resources)
+		deployedCfg.Status = status
 
 		return deployedCfg, nil
 	}
 
+	rsc.ResourceName = rsc.linstorResourceName()
+
+	err = i.cli.CheckResourceGroup(ctx, rsc.ResourceGroup)
+	if err != nil {
+		return nil, err
+	}
+
+	if !opts.SkipCapacityCheck {
+		err = i.cli.CheckCapacity(ctx, rsc.ResourceGroup, rsc.PlacementCount, rsc.LayerList, rsc.StoragePool, rsc.Volumes)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	resourceDefinition, resourceGroup, deployment, err := i.cli.EnsureResource(ctx, linstorcontrol.Resource{
-		Name:          rsc.IQN.WWN(),
-		ResourceGroup: rsc.ResourceGroup,
-		Volumes:       rsc.Volumes,
-		GrossSize:     rsc.GrossSize,
+		Name:           rsc.linstorResourceName(),
+		ResourceGroup:  rsc.ResourceGroup,
+		Volumes:        rsc.Volumes,
+		FileSystem:     rsc.FileSystem(),
+		GrossSize:      rsc.GrossSize,
+		PlacementCount: rsc.PlacementCount,
+		LayerList:      rsc.LayerList,
+		StoragePool:    rsc.StoragePool,
+		DrbdPort:       rsc.DrbdPort,
+		DrbdMinor:      rsc.DrbdMinor,
+		Labels:         rsc.Labels,
+		Description:    rsc.Description,
+		DrbdOptions:    rsc.DrbdOptions,
+		IOLimits:       rsc.ioLimits(),
 	}, false)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create linstor resource: %w", err)
 	}
 
+	// Roll back everything created so far if one of the following steps
+	// fails, so that no orphaned LINSTOR resource is left behind.
+	success := false
+	defer func() {
+		if success || opts.KeepOnFailure {
+			return
+		}
+		// Roll back even if the operation was cancelled.
+		ctx, cancel := context.WithTimeout(context.Background(), common.RollbackTimeout)
+		defer cancel()
+		log.WithField("target", rsc.IQN).Info("rolling back partially created target")
+		err := i.delete(ctx, rsc.IQN, rsc.linstorResourceName(), common.DeleteOptions{})
+		if err != nil {
+			log.WithError(err).Warnf("failed to roll back target \"%s\"", rsc.IQN)
+		}
+	}()
+
 	cfg, err = rsc.ToPromoter(deployment)
 	if err != nil {
 		return nil, fmt.Errorf("failed to convert resource to promoter configuration: %w", err)
@@ -114,9 +218,25 @@ func (i *ISCSI) Create(ctx context.Context, rsc *ResourceConfig) (*ResourceConfi
 		return nil, fmt.Errorf("failed to register reactor config file: %w", err)
 	}
 
-	_, err = i.Start(ctx, rsc.IQN)
-	if err != nil {
-		return nil, fmt.Errorf("failed to start resources: %w", err)
+	if !opts.NoStart {
+		_, err = i.Start(ctx, rsc.IQN)
+		if err != nil {
+			return nil, fmt.Errorf("failed to start resources: %w", err)
+		}
+	}
+
+	success = true
+
+	if opts.WaitSync {
+		// The initial sync can take hours, don't block other operations
+		// on the new resource meanwhile.
+		unlockResource()
+		unlock()
+
+		deployment, err = i.cli.WaitSynced(syncCtx, rsc.linstorResourceName(), resourceDefinition, resourceGroup, opts.SyncTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("the target was created, but %w", err)
+		}
 	}
 
 	rsc.Status = linstorcontrol.StatusFromResources(path, resourceDefinition, resourceGroup, deployment)
@@ -124,14 +244,97 @@ func (i *ISCSI) Create(ctx context.Context, rsc *ResourceConfig) (*ResourceConfi
 	return rsc, nil
 }
 
+// overwrite changes the deployed target to match rsc. A running target is
+// stopped while its config is replaced, and started again afterwards.
+func (i *ISCSI) overwrite(ctx context.Context, deployedCfg, rsc *ResourceConfig, status common.ResourceStatus) (*ResourceConfig, error) {
+	if deployedCfg.linstorResourceName() != rsc.linstorResourceName() {
+		return nil, fmt.Errorf("failed to overwrite existing target: %w", common.ValidationError(fmt.Sprintf("cannot change the LINSTOR resource name from %s to %s", deployedCfg.linstorResourceName(), rsc.linstorResourceName())))
+	}
+
+	err := common.CheckOverwrite(deployedCfg.ResourceGroup, rsc.ResourceGroup, deployedCfg.Volumes, rsc.Volumes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to overwrite existing target: %w", err)
+	}
+
+	err = common.CheckCreateOnly(deployedCfg.createOnlySettings(), rsc.createOnlySettings())
+	if err != nil {
+		return nil, fmt.Errorf("failed to overwrite existing target: %w", err)
+	}
+
+	log.WithField("target", rsc.IQN).Infof("overwriting existing target, diff: %s", cmp.Diff(deployedCfg, rsc, common.IgnoreSecrets))
+
+	started := status.Service.Active()
+	if started {
+		_, err = i.Stop(ctx, rsc.IQN)
+		if err != nil {
+			return nil, fmt.Errorf("failed to stop target: %w", err)
+		}
+	}
+
+	_, _, deployment, err := i.cli.EnsureResource(ctx, linstorcontrol.Resource{
+		Name:           rsc.linstorResourceName(),
+		ResourceGroup:  rsc.ResourceGroup,
+		Volumes:        rsc.Volumes,
+		FileSystem:     rsc.FileSystem(),
+		GrossSize:      rsc.GrossSize,
+		PlacementCount: rsc.PlacementCount,
+		LayerList:      rsc.LayerList,
+		StoragePool:    rsc.StoragePool,
+		IOLimits:       rsc.ioLimits(),
+	}, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reconcile linstor resource: %w", err)
+	}
+
+	if rsc.Description != deployedCfg.Description {
+		err = i.cli.SetDescription(ctx, rsc.linstorResourceName(), rsc.Description)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	cfg, err := rsc.ToPromoter(deployment)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert resource to promoter configuration: %w", err)
+	}
+
+	err = reactor.EnsureConfig(ctx, i.cli.Client, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update config: %w", err)
+	}
+
+	if started {
+		return i.Start(ctx, rsc.IQN)
+	}
+
+	return i.Get(ctx, rsc.IQN)
+}
+
 func (i *ISCSI) Start(ctx context.Context, iqn Iqn) (*ResourceConfig, error) {
-	cfg, _, err := reactor.FindConfig(ctx, i.cli.Client, fmt.Sprintf(IDFormat, iqn.WWN()))
+	ctx, unlock, err := i.cli.Lock(ctx, iqn.WWN())
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	cfg, path, err := reactor.FindConfig(ctx, i.cli.Client, fmt.Sprintf(IDFormat, iqn.WWN()))
 	if err != nil {
 		return nil, fmt.Errorf("failed to find the resource configuration: %w", err)
 	}
 
 	if cfg == nil {
-		return nil, nil
+		return nil, fmt.Errorf("target \"%s\" %w", iqn, common.ErrNotFound)
+	}
+
+	resourceDefinition, resourceGroup, _, resources, err := cfg.DeployedResources(ctx, i.cli.Client)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch existing deployment: %w", err)
+	}
+
+	status := linstorcontrol.StatusFromResources(path, resourceDefinition, resourceGroup, resources)
+	if status.Running() {
+		log.WithField("target", iqn).Debug("target is already started")
+		return i.Get(ctx, iqn)
 	}
 
 	err = reactor.AttachConfig(ctx, i.cli.Client, cfg)
@@ -139,25 +342,45 @@ func (i *ISCSI) Start(ctx context.Context, iqn Iqn) (*ResourceConfig, error) {
 		return nil, fmt.Errorf("failed to detach reactor configuration: %w", err)
 	}
 
-	waitCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
-	defer cancel()
-
-	err = common.WaitUntilResourceCondition(waitCtx, i.cli.Client, iqn.WWN(), common.AnyResourcesInUse)
+	err = linstorcontrol.WaitForStart(ctx, linstorcontrol.DefaultStartWait, iqn.String(), func(ctx context.Context) (common.ResourceStatus, error) {
+		resourceDefinition, resourceGroup, _, resources, err := cfg.DeployedResources(ctx, i.cli.Client)
+		if err != nil {
+			return common.ResourceStatus{}, err
+		}
+		return linstorcontrol.StatusFromResources(path, resourceDefinition, resourceGroup, resources), nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("error waiting for resource to become used: %w", err)
+		return nil, err
 	}
 
 	return i.Get(ctx, iqn)
 }
 
 func (i *ISCSI) Stop(ctx context.Context, iqn Iqn) (*ResourceConfig, error) {
-	cfg, _, err := reactor.FindConfig(ctx, i.cli.Client, fmt.Sprintf(IDFormat, iqn.WWN()))
+	ctx, unlock, err := i.cli.Lock(ctx, iqn.WWN())
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	cfg, path, err := reactor.FindConfig(ctx, i.cli.Client, fmt.Sprintf(IDFormat, iqn.WWN()))
 	if err != nil {
 		return nil, fmt.Errorf("failed to find the resource configuration: %w", err)
 	}
 
 	if cfg == nil {
-		return nil, nil
+		return nil, fmt.Errorf("target \"%s\" %w", iqn, common.ErrNotFound)
+	}
+
+	resourceDefinition, resourceGroup, _, resources, err := cfg.DeployedResources(ctx, i.cli.Client)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch existing deployment: %w", err)
+	}
+
+	status := linstorcontrol.StatusFromResources(path, resourceDefinition, resourceGroup, resources)
+	if status.Stopped() {
+		log.WithField("target", iqn).Debug("target is already stopped")
+		return i.Get(ctx, iqn)
 	}
 
 	err = reactor.DetachConfig(ctx, i.cli.Client, cfg)
@@ -165,10 +388,7 @@ func (i *ISCSI) Stop(ctx context.Context, iqn Iqn) (*ResourceConfig, error) {
 		return nil, fmt.Errorf("failed to detach reactor configuration: %w", err)
 	}
 
-	waitCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
-	defer cancel()
-
-	err = common.WaitUntilResourceCondition(waitCtx, i.cli.Client, iqn.WWN(), common.NoResourcesInUse)
+	err = common.WaitUntilResourceCondition(ctx, i.cli.Client, cfg.ResourceName(), common.DefaultWaitTimeout, common.NoResourcesInUse)
 	if err != nil {
 		return nil, fmt.Errorf("error waiting for resource to become unused: %w", err)
 	}
@@ -176,8 +396,108 @@ func (i *ISCSI) Stop(ctx context.Context, iqn Iqn) (*ResourceConfig, error) {
 	return i.Get(ctx, iqn)
 }
 
+// Failover moves a running target to a different node. drbd-reactor is told
+// to prefer the other nodes with a diskful replica, which makes it hand over
+// the target from the node it is currently running on. Once the target is
+// running again, the original config is restored.
+//
+// An error is returned if the target is started on the same node again.
+func (i *ISCSI) Failover(ctx context.Context, iqn Iqn) (*ResourceConfig, error) {
+	ctx, unlock, err := i.cli.Lock(ctx, iqn.WWN())
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	cfg, path, err := reactor.FindConfig(ctx, i.cli.Client, fmt.Sprintf(IDFormat, iqn.WWN()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find the resource configuration: %w", err)
+	}
+
+	if cfg == nil {
+		return nil, fmt.Errorf("target \"%s\" %w", iqn, common.ErrNotFound)
+	}
+
+	resourceDefinition, resourceGroup, _, resources, err := cfg.DeployedResources(ctx, i.cli.Client)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch existing deployment: %w", err)
+	}
+
+	status := linstorcontrol.StatusFromResources(path, resourceDefinition, resourceGroup, resources)
+	if !status.Running() {
+		return nil, fmt.Errorf("target \"%s\" is %w", iqn, common.ErrNotRunning)
+	}
+
+	from := status.Primary
+	candidates := common.FailoverCandidates(resources, from)
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("target \"%s\" has no diskful replica on a node other than %s", iqn, from)
+	}
+
+	logger := log.WithFields(log.Fields{"target": iqn, "from": from})
+	logger.Infof("failing over target to one of %s", strings.Join(candidates, ", "))
+
+	err = reactor.MarkServiceChange(ctx, i.cli.Client, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	err = reactor.EnsureConfig(ctx, i.cli.Client, cfg.WithPreferredNodes(candidates))
+	if err != nil {
+		return nil, fmt.Errorf("failed to update config: %w", err)
+	}
+
+	defer func() {
+		// Restore the config even if the operation was cancelled.
+		ctx, cancel := context.WithTimeout(context.Background(), common.RollbackTimeout)
+		defer cancel()
+		err := reactor.EnsureConfig(ctx, i.cli.Client, cfg)
+		if err != nil {
+			logger.WithError(err).Warn("failed to restore config after failover")
+		}
+	}()
+
+	// Stopping the services on one node and starting them on another takes
+	// longer than just starting them, so both steps get the full timeout.
+	err = common.WaitUntilResourceCondition(ctx, i.cli.Client, cfg.ResourceName(), common.DefaultWaitTimeout, common.NotInUseOn(from))
+	if err != nil {
+		return nil, fmt.Errorf("error waiting for target to stop on %s: %w", from, err)
+	}
+
+	err = common.WaitUntilResourceCondition(ctx, i.cli.Client, cfg.ResourceName(), common.DefaultWaitTimeout, common.AnyResourcesInUse)
+	if err != nil {
+		return nil, fmt.Errorf("error waiting for target to start on another node: %w", err)
+	}
+
+	rsc, err := i.Get(ctx, iqn)
+	if err != nil {
+		return nil, err
+	}
+
+	if rsc.Status.Primary == from {
+		return nil, fmt.Errorf("target \"%s\" was started on %s again", iqn, from)
+	}
+
+	logger.WithField("to", rsc.Status.Primary).Info("target failed over")
+
+	return rsc, nil
+}
+
+// List returns all targets.
 func (i *ISCSI) List(ctx context.Context) ([]*ResourceConfig, error) {
-	cfgs, paths, err := reactor.ListConfigs(ctx, i.cli.Client)
+	return i.ListPage(ctx, 0, 0)
+}
+
+// ListPage returns the targets, ordered by their drbd-reactor config file,
+// skipping the first offset ones and returning at most limit. A limit of 0
+// returns all remaining targets. The deployed resources are only fetched for
+// the returned targets.
+func (i *ISCSI) ListPage(ctx context.Context, offset, limit int) ([]*ResourceConfig, error) {
+	cfgs, paths, err := reactor.ListConfigsPage(ctx, i.cli.Client, reactor.ListOptions{
+		IDPrefix: strings.TrimSuffix(IDFormat, "%s"),
+		Offset:   offset,
+		Limit:    limit,
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -199,13 +519,22 @@ func (i *ISCSI) List(ctx context.Context) ([]*ResourceConfig, error) {
 			log.WithError(err).Warn("failed to fetch deployed resources")
 		}
 
+		inconsistent := common.CheckVolumeCount(cfg, common.ExportedVolumeDefinitions(volumeDefinitions), volumeAgentTypes...)
+
 		parsed, err := FromPromoter(cfg, resourceDefinition, volumeDefinitions)
 		if err != nil {
+			if inconsistent != nil {
+				log.WithField("id", cfg.ID).Warnf("skipping target that is %s", inconsistent)
+				continue
+			}
 			log.WithError(err).Warn("skipping error while parsing promoter config")
 			continue
 		}
 
 		parsed.Status = linstorcontrol.StatusFromResources(path, resourceDefinition, resourceGroup, resources)
+		if inconsistent != nil {
+			parsed.Status.MarkInconsistent(inconsistent)
+		}
 
 		result = append(result, parsed)
 	}
@@ -213,21 +542,66 @@ func (i *ISCSI) List(ctx context.Context) ([]*ResourceConfig, error) {
 	return result, nil
 }
 
-func (i *ISCSI) Delete(ctx context.Context, iqn Iqn) error {
+// Watch polls the iSCSI targets every interval and emits an event whenever
+// one of them changes its state. The returned channel is closed when ctx is
+// cancelled.
+func (i *ISCSI) Watch(ctx context.Context, interval time.Duration) (<-chan common.Event, error) {
+	return common.Watch(ctx, "iscsi", interval, func(ctx context.Context) (map[string]common.ResourceStatus, error) {
+		targets, err := i.List(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		result := make(map[string]common.ResourceStatus, len(targets))
+		for _, target := range targets {
+			result[target.IQN.String()] = target.Status
+		}
+		return result, nil
+	})
+}
+
+// Delete removes a target: its drbd-reactor config and, unless
+// opts.KeepResource is set, its LINSTOR resource.
+func (i *ISCSI) Delete(ctx context.Context, iqn Iqn, opts common.DeleteOptions) error {
+	ctx, unlock, err := i.cli.Lock(ctx, iqn.WWN())
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	cfg, _, err := reactor.FindConfig(ctx, i.cli.Client, fmt.Sprintf(IDFormat, iqn.WWN()))
+	if err != nil {
+		return fmt.Errorf("failed to check for existing config: %w", err)
+	}
+
+	// the LINSTOR resource may have a name other than the WWN
+	rscName := iqn.WWN()
+	if cfg != nil && cfg.ResourceName() != "" {
+		rscName = cfg.ResourceName()
+	}
+
+	return i.delete(ctx, iqn, rscName, opts)
+}
+
+// delete removes the reactor config of the target and, unless
+// opts.KeepResource is set, the LINSTOR resource rscName backing it.
+func (i *ISCSI) delete(ctx context.Context, iqn Iqn, rscName string, opts common.DeleteOptions) error {
 	err := reactor.DeleteConfig(ctx, i.cli.Client, fmt.Sprintf(IDFormat, iqn.WWN()))
 	if err != nil {
 		return fmt.Errorf("failed to delete reactor config: %w", err)
 	}
 
-	waitCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
-	defer cancel()
-
-	err = common.WaitUntilResourceCondition(waitCtx, i.cli.Client, iqn.WWN(), common.NoResourcesInUse)
+	err = common.WaitUntilResourceCondition(ctx, i.cli.Client, rscName, common.DefaultWaitTimeout, common.NoResourcesInUse)
 	if err != nil {
 		return fmt.Errorf("error waiting for resource to become unused: %w", err)
 	}
 
-	err = i.cli.ResourceDefinitions.Delete(ctx, iqn.WWN())
+	if opts.KeepResource {
+		log.WithField("resource", rscName).Info("keeping LINSTOR resource")
+		return i.cli.MarkKept(ctx, rscName)
+	}
+
+	err = i.cli.ResourceDefinitions.Delete(ctx, rscName)
 	if err != nil && err != client.NotFoundError {
 		return fmt.Errorf("failed to delete resources: %w", err)
 	}
@@ -236,13 +610,19 @@ func (i *ISCSI) Delete(ctx context.Context, iqn Iqn) error {
 }
 
 func (i *ISCSI) AddVolume(ctx context.Context, iqn Iqn, volCfg *common.VolumeConfig) (*ResourceConfig, error) {
+	ctx, unlock, err := i.cli.Lock(ctx, iqn.WWN())
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
 	cfg, path, err := reactor.FindConfig(ctx, i.cli.Client, fmt.Sprintf(IDFormat, iqn.WWN()))
 	if err != nil {
 		return nil, fmt.Errorf("failed to check for existing config: %w", err)
 	}
 
 	if cfg == nil {
-		return nil, nil
+		return nil, fmt.Errorf("target \"%s\" %w", iqn, common.ErrNotFound)
 	}
 
 	resourceDefinition, resourceGroup, volumeDefinitions, resources, err := cfg.DeployedResources(ctx, i.cli.Client)
@@ -255,6 +635,13 @@ func (i *ISCSI) AddVolume(ctx context.Context, iqn Iqn, volCfg *common.VolumeCon
 		return nil, fmt.Errorf("unknown existing reactor config: %w", err)
 	}
 
+	err = common.ValidGrossSize(*volCfg, deployedCfg.GrossSize)
+	if err != nil {
+		return nil, err
+	}
+	// the volume is created with the size semantics of the target
+	volCfg.GrossSize = nil
+
 	exists := false
 	for i := range deployedCfg.Volumes {
 		if deployedCfg.Volumes[i].Number == volCfg.Number {
@@ -262,6 +649,10 @@ func (i *ISCSI) AddVolume(ctx context.Context, iqn Iqn, volCfg *common.VolumeCon
 				return nil, errors.New(fmt.Sprintf("existing volume has differing size %d != %d", deployedCfg.Volumes[i].SizeKiB, volCfg.SizeKiB))
 			}
 
+			if deployedCfg.Volumes[i].FileSystem != volCfg.FileSystem {
+				return nil, errors.New(fmt.Sprintf("existing volume has differing file system \"%s\" != \"%s\"", deployedCfg.Volumes[i].FileSystem, volCfg.FileSystem))
+			}
+
 			exists = true
 			break
 		}
@@ -269,7 +660,9 @@ func (i *ISCSI) AddVolume(ctx context.Context, iqn Iqn, volCfg *common.VolumeCon
 
 	if !exists {
 		status := linstorcontrol.StatusFromResources(path, resourceDefinition, resourceGroup, resources)
-		if status.Service == common.ServiceStateStarted {
+		// The iSCSITarget agent does not pick up new LUNs on a running
+		// target, so it has to be stopped first.
+		if status.Service.Active() {
 			return nil, errors.New("cannot add volume while service is running")
 		}
 
@@ -285,10 +678,15 @@ func (i *ISCSI) AddVolume(ctx context.Context, iqn Iqn, volCfg *common.VolumeCon
 		}
 
 		resourceDefinition, resourceGroup, resources, err = i.cli.EnsureResource(ctx, linstorcontrol.Resource{
-			Name:          deployedCfg.IQN.WWN(),
-			ResourceGroup: deployedCfg.ResourceGroup,
-			Volumes:       deployedCfg.Volumes,
-			GrossSize:     deployedCfg.GrossSize,
+			Name:           deployedCfg.linstorResourceName(),
+			ResourceGroup:  deployedCfg.ResourceGroup,
+			Volumes:        deployedCfg.Volumes,
+			FileSystem:     deployedCfg.FileSystem(),
+			GrossSize:      deployedCfg.GrossSize,
+			PlacementCount: deployedCfg.PlacementCount,
+			LayerList:      deployedCfg.LayerList,
+			StoragePool:    deployedCfg.StoragePool,
+			IOLimits:       deployedCfg.ioLimits(),
 		}, true)
 		if err != nil {
 			return nil, fmt.Errorf("failed to reconcile linstor resource: %w", err)
@@ -310,57 +708,431 @@ func (i *ISCSI) AddVolume(ctx context.Context, iqn Iqn, volCfg *common.VolumeCon
 	return deployedCfg, nil
 }
 
+// SetDescription changes the description of an existing target. An empty
+// description removes it.
+func (i *ISCSI) SetDescription(ctx context.Context, iqn Iqn, description string) (*ResourceConfig, error) {
+	err := common.ValidDescription(description)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, unlock, err := i.cli.Lock(ctx, iqn.WWN())
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	cfg, _, err := reactor.FindConfig(ctx, i.cli.Client, fmt.Sprintf(IDFormat, iqn.WWN()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to check for existing config: %w", err)
+	}
+
+	if cfg == nil {
+		return nil, fmt.Errorf("target \"%s\" %w", iqn, common.ErrNotFound)
+	}
+
+	err = i.cli.SetDescription(ctx, cfg.ResourceName(), description)
+	if err != nil {
+		return nil, err
+	}
+
+	return i.Get(ctx, iqn)
+}
+
+// SetCHAP changes the CHAP credentials of an existing target. Setting both
+// username and password to an empty string disables CHAP authentication.
+// The iSCSITarget agent only reads the credentials when it is started, so a
+// running target keeps accepting the old credentials until it is restarted.
+func (i *ISCSI) SetCHAP(ctx context.Context, iqn Iqn, username, password string) (*ResourceConfig, error) {
+	err := ValidCHAP(username, password)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, unlock, err := i.cli.Lock(ctx, iqn.WWN())
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	cfg, path, err := reactor.FindConfig(ctx, i.cli.Client, fmt.Sprintf(IDFormat, iqn.WWN()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to check for existing config: %w", err)
+	}
+
+	if cfg == nil {
+		return nil, fmt.Errorf("target \"%s\" %w", iqn, common.ErrNotFound)
+	}
+
+	resourceDefinition, resourceGroup, volumeDefinitions, resources, err := cfg.DeployedResources(ctx, i.cli.Client)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch existing deployment: %w", err)
+	}
+
+	deployedCfg, err := FromPromoter(cfg, resourceDefinition, volumeDefinitions)
+	if err != nil {
+		return nil, fmt.Errorf("unknown existing reactor config: %w", err)
+	}
+
+	deployedCfg.Username = username
+	deployedCfg.Password = password
+
+	cfg, err = deployedCfg.ToPromoter(resources)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert resource to promoter configuration: %w", err)
+	}
+
+	err = reactor.EnsureConfig(ctx, i.cli.Client, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update config: %w", err)
+	}
+
+	deployedCfg.Status = linstorcontrol.StatusFromResources(path, resourceDefinition, resourceGroup, resources)
+
+	return deployedCfg, nil
+}
+
+// Repair regenerates the reactor config of a target from its LINSTOR
+// resource and registers it again, undoing any manual changes. With dryRun,
+// the config is left untouched and only the differences are reported.
+func (i *ISCSI) Repair(ctx context.Context, iqn Iqn, dryRun bool) (*reactor.RepairResult, error) {
+	ctx, unlock, err := i.cli.Lock(ctx, iqn.WWN())
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	return i.repair(ctx, iqn, dryRun)
+}
+
+// Drift returns the differences between the registered reactor config of a
+// target and the one Repair would register, or "" if there are none. Unlike
+// a dry run of Repair, it does not take the lock of the target, so it can be
+// used for frequent checks.
+func (i *ISCSI) Drift(ctx context.Context, iqn Iqn) (string, error) {
+	result, err := i.repair(ctx, iqn, true)
+	if err != nil {
+		return "", err
+	}
+
+	return result.Diff, nil
+}
+
+func (i *ISCSI) repair(ctx context.Context, iqn Iqn, dryRun bool) (*reactor.RepairResult, error) {
+	cfg, _, err := reactor.FindConfig(ctx, i.cli.Client, fmt.Sprintf(IDFormat, iqn.WWN()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to check for existing config: %w", err)
+	}
+
+	if cfg == nil {
+		return nil, fmt.Errorf("target \"%s\" %w", iqn, common.ErrNotFound)
+	}
+
+	var password string
+	result, err := reactor.Repair(ctx, i.cli.Client, cfg, dryRun, func(definition *client.ResourceDefinition, volumeDefinitions []client.VolumeDefinition, resources []client.ResourceWithVolumes) (*reactor.PromoterConfig, error) {
+		rsc, err := FromPromoter(cfg, definition, volumeDefinitions)
+		if err != nil {
+			return nil, fmt.Errorf("unknown existing reactor config: %w", err)
+		}
+		password = rsc.Password
+
+		return rsc.ToPromoter(resources)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if password != "" {
+		result.Diff = strings.ReplaceAll(result.Diff, password, common.Redact(password))
+	}
+
+	return result, nil
+}
+
+// ShowConfig returns the drbd-reactor configuration of a target. If deployed
+// is set, it is the configuration registered in LINSTOR, otherwise it is
+// regenerated from the LINSTOR resource like Repair does. The CHAP password
+// is redacted.
+func (i *ISCSI) ShowConfig(ctx context.Context, iqn Iqn, deployed bool) (*reactor.ConfigText, error) {
+	cfg, _, err := reactor.FindConfig(ctx, i.cli.Client, fmt.Sprintf(IDFormat, iqn.WWN()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to check for existing config: %w", err)
+	}
+
+	if cfg == nil {
+		return nil, fmt.Errorf("target \"%s\" %w", iqn, common.ErrNotFound)
+	}
+
+	definition, _, volumeDefinitions, _, err := cfg.DeployedResources(ctx, i.cli.Client)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch deployed resources: %w", err)
+	}
+
+	rsc, err := FromPromoter(cfg, definition, volumeDefinitions)
+	if err != nil {
+		return nil, fmt.Errorf("unknown existing reactor config: %w", err)
+	}
+
+	result, err := reactor.ShowConfig(ctx, i.cli.Client, cfg, deployed, func(definition *client.ResourceDefinition, volumeDefinitions []client.VolumeDefinition, resources []client.ResourceWithVolumes) (*reactor.PromoterConfig, error) {
+		return rsc.ToPromoter(resources)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if rsc.Password != "" {
+		result.Config = strings.ReplaceAll(result.Config, rsc.Password, common.Redact(rsc.Password))
+	}
+
+	return result, nil
+}
+
+// DeleteVolume deletes a logical unit of a stopped target. Deleting a logical
+// unit that does not exist is not an error.
 func (i *ISCSI) DeleteVolume(ctx context.Context, iqn Iqn, lun int) (*ResourceConfig, error) {
+	cfg, _, err := i.DeleteVolumes(ctx, iqn, []int{lun})
+	return cfg, err
+}
+
+// DeleteVolumes deletes several logical units of a stopped target at once,
+// updating its reactor config only once. It returns the numbers of the given
+// logical units that did not exist.
+func (i *ISCSI) DeleteVolumes(ctx context.Context, iqn Iqn, luns []int) (*ResourceConfig, []int, error) {
+	ctx, unlock, err := i.cli.Lock(ctx, iqn.WWN())
+	if err != nil {
+		return nil, nil, err
+	}
+	defer unlock()
+
+	for _, lun := range luns {
+		if lun < 1 {
+			return nil, nil, common.ValidationError("the cluster private volume can not be deleted")
+		}
+	}
+
 	cfg, path, err := reactor.FindConfig(ctx, i.cli.Client, fmt.Sprintf(IDFormat, iqn.WWN()))
 	if err != nil {
-		return nil, fmt.Errorf("failed to delete reactor config: %w", err)
+		return nil, nil, fmt.Errorf("failed to delete reactor config: %w", err)
 	}
 
 	if cfg == nil {
-		return nil, nil
+		return nil, nil, fmt.Errorf("target \"%s\" %w", iqn, common.ErrNotFound)
 	}
 
 	resourceDefinition, resourceGroup, volumeDefinition, resources, err := cfg.DeployedResources(ctx, i.cli.Client)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch deployed resources: %w", err)
+		return nil, nil, fmt.Errorf("failed to fetch deployed resources: %w", err)
 	}
 
 	rscCfg, err := FromPromoter(cfg, resourceDefinition, volumeDefinition)
 	if err != nil {
-		return nil, fmt.Errorf("failed to convert volume definition to resource: %w", err)
+		return nil, nil, fmt.Errorf("failed to convert volume definition to resource: %w", err)
 	}
 
 	status := linstorcontrol.StatusFromResources(path, resourceDefinition, resourceGroup, resources)
-	if status.Service == common.ServiceStateStarted {
-		return nil, errors.New("cannot delete volume while service is running")
+	if status.Service.Active() {
+		return nil, nil, errors.New("cannot delete volume while service is running")
 	}
 
-	for j := range rscCfg.Volumes {
-		if rscCfg.Volumes[j].Number == lun {
-			err = i.cli.ResourceDefinitions.DeleteVolumeDefinition(ctx, iqn.WWN(), lun)
-			if err != nil && err != client.NotFoundError {
-				return nil, fmt.Errorf("failed to delete volume definition")
-			}
+	var removed, missing []int
+	seen := make(map[int]bool, len(luns))
+	for _, lun := range luns {
+		if seen[lun] {
+			continue
+		}
+		seen[lun] = true
 
-			rscCfg.Volumes = append(rscCfg.Volumes[:j], rscCfg.Volumes[j+1:]...)
-			// Manually delete the resources from the current resource config
-			for k := range resources {
-				resources[k].Volumes = append(resources[k].Volumes[:j], resources[k].Volumes[j+1:]...)
-			}
+		if rscCfg.removeLogicalUnit(resources, lun) {
+			removed = append(removed, lun)
+		} else {
+			missing = append(missing, lun)
+		}
+	}
 
-			cfg, err = rscCfg.ToPromoter(resources)
-			if err != nil {
-				return nil, fmt.Errorf("failed to convert resource to promoter configuration: %w", err)
-			}
+	if len(removed) == 0 {
+		rscCfg, err = i.Get(ctx, iqn)
+		return rscCfg, missing, err
+	}
 
-			err = reactor.EnsureConfig(ctx, i.cli.Client, cfg)
-			if err != nil {
-				return nil, fmt.Errorf("failed to update config")
-			}
+	// Update the reactor config first, so that it never references a volume
+	// that no longer exists.
+	cfg, err = rscCfg.ToPromoter(resources)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to convert resource to promoter configuration: %w", err)
+	}
+
+	err = reactor.EnsureConfig(ctx, i.cli.Client, cfg)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to update config: %w", err)
+	}
+
+	for _, lun := range removed {
+		err = i.cli.ResourceDefinitions.DeleteVolumeDefinition(ctx, cfg.ResourceName(), lun)
+		if err != nil && err != client.NotFoundError {
+			return nil, nil, fmt.Errorf("failed to delete volume definition %d: %w", lun, err)
+		}
+	}
+
+	rscCfg, err = i.Get(ctx, iqn)
+	return rscCfg, missing, err
+}
+
+// SetVolumeExported maps a logical unit into the target, or removes it from
+// the target while keeping its data. Like adding a volume, this requires the
+// target to be stopped, as the iSCSITarget agent does not pick up changed LUNs
+// on a running target.
+func (i *ISCSI) SetVolumeExported(ctx context.Context, iqn Iqn, lun int, exported bool) (*ResourceConfig, error) {
+	ctx, unlock, err := i.cli.Lock(ctx, iqn.WWN())
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	if lun < 1 {
+		return nil, common.ValidationError("the cluster private volume can not be unexported")
+	}
+
+	cfg, path, err := reactor.FindConfig(ctx, i.cli.Client, fmt.Sprintf(IDFormat, iqn.WWN()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to check for existing config: %w", err)
+	}
 
+	if cfg == nil {
+		return nil, fmt.Errorf("target \"%s\" %w", iqn, common.ErrNotFound)
+	}
+
+	resourceDefinition, resourceGroup, volumeDefinitions, resources, err := cfg.DeployedResources(ctx, i.cli.Client)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch existing deployment: %w", err)
+	}
+
+	deployedCfg, err := FromPromoter(cfg, resourceDefinition, volumeDefinitions)
+	if err != nil {
+		return nil, fmt.Errorf("unknown existing reactor config: %w", err)
+	}
+
+	var vol *common.VolumeConfig
+	for j := range deployedCfg.Volumes {
+		if deployedCfg.Volumes[j].Number == lun {
+			vol = &deployedCfg.Volumes[j]
 			break
 		}
 	}
 
-	return i.Get(ctx, iqn)
+	if vol == nil {
+		return nil, fmt.Errorf("logical unit %d of target \"%s\" %w", lun, iqn, common.ErrNotFound)
+	}
+
+	status := linstorcontrol.StatusFromResources(path, resourceDefinition, resourceGroup, resources)
+	deployedCfg.Status = status
+
+	if vol.IsExported() == exported {
+		return deployedCfg, nil
+	}
+
+	if status.Service.Active() {
+		if exported {
+			return nil, errors.New("cannot export volume while service is running")
+		}
+		return nil, errors.New("cannot unexport volume while service is running")
+	}
+
+	err = i.cli.ResourceDefinitions.ModifyVolumeDefinition(ctx, cfg.ResourceName(), lun, client.VolumeDefinitionModify{
+		GenericPropsModify: common.ExportedPropsModify(exported),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to modify volume definition: %w", err)
+	}
+
+	vol.Exported = nil
+	if !exported {
+		vol.Exported = &exported
+	}
+
+	cfg, err = deployedCfg.ToPromoter(resources)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert resource to promoter configuration: %w", err)
+	}
+
+	err = reactor.EnsureConfig(ctx, i.cli.Client, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update config: %w", err)
+	}
+
+	return deployedCfg, nil
+}
+
+// RecreatePrivateVolume replaces the cluster private volume of a stopped
+// target with a new, empty one, e.g. if its file system is corrupted. The
+// volume definition is deleted and created again, so that LINSTOR creates a
+// new file system on it. The data volumes are not touched. Everything stored
+// on the cluster private volume, such as the TCP connections portblock
+// restores after a failover, is lost.
+func (i *ISCSI) RecreatePrivateVolume(ctx context.Context, iqn Iqn) (*ResourceConfig, error) {
+	ctx, unlock, err := i.cli.Lock(ctx, iqn.WWN())
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	cfg, path, err := reactor.FindConfig(ctx, i.cli.Client, fmt.Sprintf(IDFormat, iqn.WWN()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to check for existing config: %w", err)
+	}
+
+	if cfg == nil {
+		return nil, fmt.Errorf("target \"%s\" %w", iqn, common.ErrNotFound)
+	}
+
+	resourceDefinition, resourceGroup, volumeDefinitions, resources, err := cfg.DeployedResources(ctx, i.cli.Client)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch existing deployment: %w", err)
+	}
+
+	deployedCfg, err := FromPromoter(cfg, resourceDefinition, volumeDefinitions)
+	if err != nil {
+		return nil, fmt.Errorf("unknown existing reactor config: %w", err)
+	}
+
+	status := linstorcontrol.StatusFromResources(path, resourceDefinition, resourceGroup, resources)
+	if status.Service.Active() || common.AnyResourcesInUse(resources) {
+		return nil, errors.New("cannot recreate the cluster private volume while service is running")
+	}
+
+	rscName := deployedCfg.linstorResourceName()
+	err = i.cli.ResourceDefinitions.DeleteVolumeDefinition(ctx, rscName, 0)
+	if err != nil && err != client.NotFoundError {
+		return nil, fmt.Errorf("failed to delete cluster private volume: %w", err)
+	}
+
+	deployedCfg.Volumes[0] = common.ClusterPrivateVolume()
+	resourceDefinition, resourceGroup, resources, err = i.cli.EnsureResource(ctx, linstorcontrol.Resource{
+		Name:           rscName,
+		ResourceGroup:  deployedCfg.ResourceGroup,
+		Volumes:        deployedCfg.Volumes,
+		FileSystem:     deployedCfg.FileSystem(),
+		GrossSize:      deployedCfg.GrossSize,
+		PlacementCount: deployedCfg.PlacementCount,
+		LayerList:      deployedCfg.LayerList,
+		StoragePool:    deployedCfg.StoragePool,
+		IOLimits:       deployedCfg.ioLimits(),
+	}, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to recreate cluster private volume: %w", err)
+	}
+
+	// the new volume may have a different device path
+	cfg, err = deployedCfg.ToPromoter(resources)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert resource to promoter configuration: %w", err)
+	}
+
+	err = reactor.EnsureConfig(ctx, i.cli.Client, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update config: %w", err)
+	}
+
+	deployedCfg.Status = linstorcontrol.StatusFromResources(path, resourceDefinition, resourceGroup, resources)
+
+	return deployedCfg, nil
 }
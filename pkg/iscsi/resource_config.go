@@ -2,6 +2,7 @@ package iscsi
 
 import (
 	"crypto/md5"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/icza/gog"
@@ -12,18 +13,27 @@ import (
 	"strconv"
 	"strings"
 
+	apiconsts "github.com/LINBIT/golinstor"
 	"github.com/LINBIT/golinstor/client"
 
 	"github.com/LINBIT/linstor-gateway/pkg/common"
+	"github.com/LINBIT/linstor-gateway/pkg/linstorcontrol"
 	"github.com/LINBIT/linstor-gateway/pkg/reactor"
 )
 
+// volumeAgentTypes are the types of the resource agents that each configure
+// one volume of a target.
+var volumeAgentTypes = []string{"ocf:heartbeat:Filesystem", "ocf:heartbeat:iSCSILogicalUnit"}
+
 const (
 	DefaultISCSIPort = 3260
 )
 
 type ResourceConfig struct {
-	IQN               Iqn                   `json:"iqn"`
+	IQN Iqn `json:"iqn"`
+	// ResourceName is the name of the LINSTOR resource backing this target.
+	// By default, it is derived from the WWN of the IQN.
+	ResourceName      string                `json:"resource_name,omitempty"`
 	AllowedInitiators []Iqn                 `json:"allowed_initiators,omitempty"`
 	ResourceGroup     string                `json:"resource_group"`
 	Volumes           []common.VolumeConfig `json:"volumes"`
@@ -32,15 +42,131 @@ type ResourceConfig struct {
 	ServiceIPs        []common.IpCidr       `json:"service_ips"`
 	Status            common.ResourceStatus `json:"status"`
 	GrossSize         bool                  `json:"gross_size"`
+	// NoServiceIP must be set to create a target without service IPs. The
+	// target then listens on all addresses of the node it runs on, and is
+	// only reachable on that node's own addresses, e.g. by a consumer that
+	// runs on the same node.
+	NoServiceIP bool `json:"no_service_ip,omitempty"`
+	// PlacementCount is the number of diskful replicas of the resource, if
+	// it differs from the placement count of the resource group. Diskless
+	// resources, e.g. a tie-breaker, are not counted.
+	PlacementCount int `json:"placement_count,omitempty"`
+	// LayerList overrides the LINSTOR layers of the resource group, e.g.
+	// [DRBD STORAGE]. Without DRBD, the target is not highly available.
+	LayerList []string `json:"layer_list,omitempty"`
+	// StoragePool overrides the storage pools of the resource group, so
+	// that all diskful replicas are placed in this pool. It narrows the
+	// placement: if the pool is not present on enough nodes, the placement
+	// count of the resource group can not be satisfied.
+	StoragePool string `json:"storage_pool,omitempty"`
+	// DrbdPort is the TCP port DRBD uses for the resource, and DrbdMinor
+	// the DRBD minor number of the cluster private volume. The other
+	// volumes use DrbdMinor plus their volume number. If 0, LINSTOR
+	// assigns them. They can only be set when the resource is created.
+	DrbdPort  int `json:"drbd_port,omitempty"`
+	DrbdMinor int `json:"drbd_minor,omitempty"`
+	// Labels organize targets, e.g. by team. They can be used to select
+	// targets when listing them. They can only be set when the resource
+	// is created.
+	Labels map[string]string `json:"labels,omitempty"`
+	// Description is a free text describing the target, e.g. its purpose or
+	// owner. It is stored with the LINSTOR resource.
+	Description string `json:"description,omitempty"`
+	// DrbdOptions override DRBD options of the resource, e.g. the
+	// replication protocol or what happens when the target loses quorum.
+	// Options that are not set use the defaults. They can only be set when
+	// the resource is created.
+	DrbdOptions map[string]string `json:"drbd_options,omitempty"`
+	// ReadLimit and WriteLimit limit the throughput of the target, either
+	// in bytes or in IO operations per second. If nil, the throughput is
+	// not limited.
+	ReadLimit  *common.IOLimit `json:"read_limit,omitempty"`
+	WriteLimit *common.IOLimit `json:"write_limit,omitempty"`
+	// CacheMode selects whether the logical units report a volatile write
+	// cache to initiators. If empty, the LIO default is used.
+	CacheMode string `json:"cache_mode,omitempty"`
+}
+
+// ResourceConfigWithSecrets is a ResourceConfig that includes the CHAP
+// password when marshalled. Use it only where the password is really needed,
+// e.g. when creating a target.
+type ResourceConfigWithSecrets ResourceConfig
+
+// MarshalJSON implements json.Marshaler. The CHAP password is redacted.
+func (r ResourceConfig) MarshalJSON() ([]byte, error) {
+	redacted := ResourceConfigWithSecrets(r)
+	redacted.Password = common.Redact(r.Password)
+	return json.Marshal(redacted)
+}
+
+// String implements fmt.Stringer. The CHAP password is redacted.
+func (r ResourceConfig) String() string {
+	redacted := ResourceConfigWithSecrets(r)
+	redacted.Password = common.Redact(r.Password)
+	return fmt.Sprintf("%+v", redacted)
 }
 
 const (
-	agentTypePortblock   = "ocf:heartbeat:portblock"
-	agentTypeIPaddr2     = "ocf:heartbeat:IPaddr2"
-	agentTypeISCSITarget = "ocf:heartbeat:iSCSITarget"
+	agentTypePortblock        = "ocf:heartbeat:portblock"
+	agentTypeIPaddr2          = "ocf:heartbeat:IPaddr2"
+	agentTypeISCSITarget      = "ocf:heartbeat:iSCSITarget"
+	agentTypeISCSILogicalUnit = "ocf:heartbeat:iSCSILogicalUnit"
 )
 
-const minAgentEntries = 4 // portblock, service_ip, target, portunblock
+// writeCacheAttribute is the LIO device attribute that enables the emulation
+// of a volatile write cache. The iSCSILogicalUnit agent sets it from its
+// "additional_parameters".
+const writeCacheAttribute = "emulate_write_cache"
+
+const (
+	// CacheModeWriteThrough makes the logical units report no write cache,
+	// so initiators consider a write complete once it is on stable storage.
+	CacheModeWriteThrough = "write-through"
+	// CacheModeWriteBack makes the logical units report a volatile write
+	// cache, so initiators flush it when they need data to be persistent.
+	CacheModeWriteBack = "write-back"
+)
+
+// SupportedCacheModes lists the values allowed for the cache mode of a
+// target.
+var SupportedCacheModes = []string{CacheModeWriteThrough, CacheModeWriteBack}
+
+// writeCacheParameter returns the LIO device attribute that selects the
+// given cache mode, or an empty string for the default.
+func writeCacheParameter(mode string) string {
+	switch mode {
+	case CacheModeWriteThrough:
+		return writeCacheAttribute + "=0"
+	case CacheModeWriteBack:
+		return writeCacheAttribute + "=1"
+	default:
+		return ""
+	}
+}
+
+// parseCacheMode returns the cache mode selected by the additional
+// parameters of a logical unit.
+func parseCacheMode(params string) (string, error) {
+	for _, param := range strings.Fields(params) {
+		name, value, _ := strings.Cut(param, "=")
+		if name != writeCacheAttribute {
+			continue
+		}
+
+		switch value {
+		case "0":
+			return CacheModeWriteThrough, nil
+		case "1":
+			return CacheModeWriteBack, nil
+		default:
+			return "", fmt.Errorf("malformed %s value %q", writeCacheAttribute, value)
+		}
+	}
+
+	return "", nil
+}
+
+const minAgentEntries = 1 // target, the service IPs are optional
 
 func parsePromoterConfig(cfg *reactor.PromoterConfig) (*ResourceConfig, error) {
 	r := &ResourceConfig{}
@@ -65,6 +191,7 @@ func parsePromoterConfig(cfg *reactor.PromoterConfig) (*ResourceConfig, error) {
 	}
 
 	var numPortblocks, numPortunblocks int
+	hasTarget := false
 	for _, entry := range rscCfg.Start {
 		switch agent := entry.(type) {
 		case *reactor.ResourceAgent:
@@ -89,6 +216,7 @@ func parsePromoterConfig(cfg *reactor.PromoterConfig) (*ResourceConfig, error) {
 
 				r.ServiceIPs = append(r.ServiceIPs, common.ServiceIPFromParts(ip, prefixLength))
 			case agentTypeISCSITarget:
+				hasTarget = true
 				r.IQN, err = NewIqn(agent.Attributes["iqn"])
 				if err != nil {
 					return nil, fmt.Errorf("got malformed iqn: %w", err)
@@ -107,12 +235,24 @@ func parsePromoterConfig(cfg *reactor.PromoterConfig) (*ResourceConfig, error) {
 						r.AllowedInitiators = append(r.AllowedInitiators, iqn)
 					}
 				}
+			case agentTypeISCSILogicalUnit:
+				r.CacheMode, err = parseCacheMode(agent.Attributes["additional_parameters"])
+				if err != nil {
+					return nil, err
+				}
+			default:
+				// e.g. added by hand, or by a newer version
+				log.WithField("agent", agent.Name).Debugf("ignoring unknown resource agent %s", agent.Type)
 			}
 		case *reactor.SystemdService:
 			// ignore systemd services for now
 		}
 	}
 
+	if !hasTarget {
+		return nil, fmt.Errorf("malformed configuration: missing %s agent", agentTypeISCSITarget)
+	}
+
 	if numPortblocks != numPortunblocks {
 		return nil, fmt.Errorf("malformed configuration: got a different number of portblock and portunblock agents")
 	}
@@ -121,6 +261,8 @@ func parsePromoterConfig(cfg *reactor.PromoterConfig) (*ResourceConfig, error) {
 		return nil, fmt.Errorf("malformed configuration: got a different number of portblock agents than IPaddr2 agents")
 	}
 
+	r.NoServiceIP = len(r.ServiceIPs) == 0
+
 	return r, nil
 }
 
@@ -130,26 +272,32 @@ func FromPromoter(cfg *reactor.PromoterConfig, definition *client.ResourceDefini
 		return nil, fmt.Errorf("failed to parse promoter config: %w", err)
 	}
 	r.ResourceGroup = definition.ResourceGroupName
-
-	anyGrossSize := false
+	r.ResourceName = cfg.ResourceName()
 
 	for _, vd := range volumeDefinitions {
-		for _, flag := range vd.Flags {
-			if flag == "GROSS_SIZE" {
-				anyGrossSize = true
-				break
-			}
-		}
 		if vd.VolumeNumber == nil {
 			vd.VolumeNumber = gog.Ptr(int32(0))
 		}
 		r.Volumes = append(r.Volumes, common.VolumeConfig{
-			Number:  int(*vd.VolumeNumber),
-			SizeKiB: vd.SizeKib,
+			Number:     int(*vd.VolumeNumber),
+			SizeKiB:    vd.SizeKib,
+			FileSystem: vd.Props[apiconsts.NamespcFilesystem+"/Type"],
+			Exported:   common.Exported(vd),
 		})
 	}
 
-	r.GrossSize = anyGrossSize
+	r.GrossSize = common.AnyGrossSize(volumeDefinitions)
+	r.PlacementCount = linstorcontrol.PlacementCount(definition)
+	r.LayerList = linstorcontrol.LayerList(definition)
+	r.StoragePool = linstorcontrol.StoragePool(definition)
+	r.DrbdPort = linstorcontrol.DrbdPort(definition)
+	r.DrbdMinor = linstorcontrol.DrbdMinor(volumeDefinitions)
+	r.Labels = linstorcontrol.Labels(definition)
+	r.Description = linstorcontrol.Description(definition)
+	r.DrbdOptions = linstorcontrol.DrbdOptions(definition)
+
+	limits := linstorcontrol.IOLimitsFromDefinition(definition)
+	r.ReadLimit, r.WriteLimit = limits.Read, limits.Write
 
 	return r, nil
 }
@@ -180,19 +328,181 @@ func (r *ResourceConfig) VolumeConfig(number int) *common.Volume {
 	return result
 }
 
+// ioLimits returns the throughput limits of the LINSTOR resource.
+func (r *ResourceConfig) ioLimits() *linstorcontrol.IOLimits {
+	return &linstorcontrol.IOLimits{Read: r.ReadLimit, Write: r.WriteLimit}
+}
+
 func (r *ResourceConfig) FillDefaults() {
 	if r.ResourceGroup == "" {
 		r.ResourceGroup = "DfltRscGrp"
 	}
 }
 
+// createOnlySettings returns the settings of the target that can only be set
+// when its LINSTOR resource is created.
+func (r *ResourceConfig) createOnlySettings() common.CreateOnlySettings {
+	return common.CreateOnlySettings{
+		PlacementCount: r.PlacementCount,
+		LayerList:      r.LayerList,
+		StoragePool:    r.StoragePool,
+		DrbdPort:       r.DrbdPort,
+		DrbdMinor:      r.DrbdMinor,
+		Labels:         r.Labels,
+		DrbdOptions:    r.DrbdOptions,
+	}
+}
+
+// linstorResourceName returns the name of the LINSTOR resource backing this
+// target.
+func (r *ResourceConfig) linstorResourceName() string {
+	if r.ResourceName != "" {
+		return r.ResourceName
+	}
+	return r.IQN.WWN()
+}
+
+// CloneAs returns the configuration for a new target with the given IQN and
+// service IPs that is otherwise like r. The cluster private volume and the
+// status are not copied, so the result can be passed to Create.
+//
+// The service IPs must not be used by r, since two targets can not share a
+// service IP.
+func (r *ResourceConfig) CloneAs(iqn Iqn, serviceIPs []common.IpCidr) (*ResourceConfig, error) {
+	if iqn.String() == r.IQN.String() {
+		return nil, common.ValidationError(fmt.Sprintf("can not clone target '%s' to itself", r.IQN))
+	}
+
+	if len(serviceIPs) == 0 {
+		return nil, common.ValidationError("missing service ips")
+	}
+
+	for _, ip := range serviceIPs {
+		for _, used := range r.ServiceIPs {
+			if ip.IP().Equal(used.IP()) {
+				return nil, common.ValidationError(fmt.Sprintf("service ip %s is already used by target '%s'", ip.IP(), r.IQN))
+			}
+		}
+	}
+
+	var volumes []common.VolumeConfig
+	for _, vol := range r.Volumes {
+		if vol.Number == 0 {
+			continue
+		}
+		volumes = append(volumes, vol)
+	}
+
+	var labels map[string]string
+	if r.Labels != nil {
+		labels = make(map[string]string, len(r.Labels))
+		for k, v := range r.Labels {
+			labels[k] = v
+		}
+	}
+
+	var drbdOptions map[string]string
+	if r.DrbdOptions != nil {
+		drbdOptions = make(map[string]string, len(r.DrbdOptions))
+		for k, v := range r.DrbdOptions {
+			drbdOptions[k] = v
+		}
+	}
+
+	return &ResourceConfig{
+		IQN:               iqn,
+		AllowedInitiators: append([]Iqn(nil), r.AllowedInitiators...),
+		ResourceGroup:     r.ResourceGroup,
+		Volumes:           volumes,
+		Username:          r.Username,
+		Password:          r.Password,
+		ServiceIPs:        serviceIPs,
+		GrossSize:         r.GrossSize,
+		PlacementCount:    r.PlacementCount,
+		LayerList:         append([]string(nil), r.LayerList...),
+		StoragePool:       r.StoragePool,
+		Labels:            labels,
+		Description:       r.Description,
+		DrbdOptions:       drbdOptions,
+		ReadLimit:         r.ReadLimit,
+		WriteLimit:        r.WriteLimit,
+		CacheMode:         r.CacheMode,
+	}, nil
+}
+
 func (r *ResourceConfig) Valid() error {
 	if len(r.IQN.WWN()) < 2 {
 		return common.ValidationError("iscsi wwn string to short (min. 2)")
 	}
 
-	if len(r.ServiceIPs) == 0 {
-		return common.ValidationError("missing service ips")
+	if r.ResourceName != "" {
+		err := common.ValidLinstorName(r.ResourceName)
+		if err != nil {
+			return err
+		}
+	}
+
+	if r.NoServiceIP && len(r.ServiceIPs) > 0 {
+		return common.ValidationError("service ips can not be combined with no_service_ip")
+	}
+
+	if !r.NoServiceIP && len(r.ServiceIPs) == 0 {
+		return common.ValidationError("missing service ips, set no_service_ip to create a target without one")
+	}
+
+	// every service ip becomes a portal of the target, which LIO can only
+	// create once per address and port
+	serviceIPs := make(map[string]struct{})
+	for i := range r.ServiceIPs {
+		if r.ServiceIPs[i].IP() == nil {
+			return common.ValidationError("missing service ip")
+		}
+
+		ip := r.ServiceIPs[i].IP().String()
+		if _, ok := serviceIPs[ip]; ok {
+			return common.ValidationError(fmt.Sprintf("duplicate service ip %s", ip))
+		}
+		serviceIPs[ip] = struct{}{}
+	}
+
+	err := common.ValidPlacementCount(r.PlacementCount)
+	if err != nil {
+		return err
+	}
+
+	err = common.ValidLayerList(r.LayerList)
+	if err != nil {
+		return err
+	}
+
+	err = common.ValidDrbdNumbers(r.DrbdPort, r.DrbdMinor, len(r.Volumes))
+	if err != nil {
+		return err
+	}
+
+	err = common.ValidLabels(r.Labels)
+	if err != nil {
+		return err
+	}
+
+	err = common.ValidDescription(r.Description)
+	if err != nil {
+		return err
+	}
+
+	err = common.ValidDrbdOptions(r.DrbdOptions)
+	if err != nil {
+		return err
+	}
+
+	err = common.ValidIOLimits(r.ReadLimit, r.WriteLimit)
+	if err != nil {
+		return err
+	}
+
+	err = validCacheMode(r.CacheMode)
+	if err != nil {
+		return err
 	}
 
 	sort.Slice(r.Volumes, func(i, j int) bool {
@@ -211,18 +521,111 @@ func (r *ResourceConfig) Valid() error {
 		}
 
 		if i > 0 && r.Volumes[i-1].Number == r.Volumes[i].Number {
-			return common.ValidationError("volume numbers must be unique")
+			return common.ValidationError(fmt.Sprintf("volume numbers must be unique, %d is used more than once", r.Volumes[i].Number))
+		}
+
+		if r.Volumes[i].Number == 0 && !r.Volumes[i].IsExported() {
+			return common.ValidationError("the cluster private volume can not be unexported")
+		}
+
+		if i != 0 {
+			// the "cluster private volume" always has a file system
+			err := validFileSystem(&r.Volumes[i])
+			if err != nil {
+				return err
+			}
 		}
 	}
 
 	return nil
 }
 
+// CHAPCredentials is the request body for changing the CHAP credentials of a
+// target.
+type CHAPCredentials struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// ValidCHAP checks that either both the CHAP username and password are set,
+// or neither of them is.
+func ValidCHAP(username, password string) error {
+	if username == "" && password != "" {
+		return common.ValidationError("a CHAP password requires a username")
+	}
+
+	if username != "" && password == "" {
+		return common.ValidationError("a CHAP username requires a password")
+	}
+
+	return nil
+}
+
+func validCacheMode(mode string) error {
+	if mode == "" {
+		return nil
+	}
+
+	for _, m := range SupportedCacheModes {
+		if mode == m {
+			return nil
+		}
+	}
+
+	return common.ValidationError(fmt.Sprintf("unsupported cache mode %q (supported: %s)", mode, strings.Join(SupportedCacheModes, ", ")))
+}
+
+// SupportedFileSystems lists the file systems that may be created on a
+// logical unit. By default, no file system is created and the logical unit
+// is exported as a raw block device.
+var SupportedFileSystems = []string{"ext4", "xfs"}
+
+func validFileSystem(vol *common.VolumeConfig) error {
+	if vol.FileSystem == "" {
+		if vol.FileSystemRootOwner != (common.UidGid{}) {
+			return common.ValidationError(fmt.Sprintf("volume %d: a file system root owner requires a file system", vol.Number))
+		}
+		return nil
+	}
+
+	for _, fs := range SupportedFileSystems {
+		if vol.FileSystem == fs {
+			if fs != "ext4" && vol.FileSystemRootOwner != (common.UidGid{}) {
+				return common.ValidationError(fmt.Sprintf("volume %d: setting the file system root owner is only supported for ext4", vol.Number))
+			}
+			return nil
+		}
+	}
+
+	return common.ValidationError(fmt.Sprintf("volume %d: unsupported file system %q (supported: %s)", vol.Number, vol.FileSystem, strings.Join(SupportedFileSystems, ", ")))
+}
+
+// FileSystem returns the file system that is created on the logical units
+// of this target, or an empty string if they are raw block devices.
+func (r *ResourceConfig) FileSystem() string {
+	for i := range r.Volumes {
+		if r.Volumes[i].Number != 0 && r.Volumes[i].FileSystem != "" {
+			return r.Volumes[i].FileSystem
+		}
+	}
+	return ""
+}
+
+// Matches reports whether the deployed target o is configured as requested
+// by r. A DRBD port or minor that is not set in r matches any.
 func (r *ResourceConfig) Matches(o *ResourceConfig) bool {
 	if r.IQN != o.IQN {
 		return false
 	}
 
+	if r.linstorResourceName() != o.linstorResourceName() {
+		return false
+	}
+
+	if len(r.ServiceIPs) != len(o.ServiceIPs) {
+		return false
+	}
+
 	for i := range r.ServiceIPs {
 		if r.ServiceIPs[i].String() != o.ServiceIPs[i].String() {
 			return false
@@ -233,6 +636,22 @@ func (r *ResourceConfig) Matches(o *ResourceConfig) bool {
 		return false
 	}
 
+	if common.CheckCreateOnly(o.createOnlySettings(), r.createOnlySettings()) != nil {
+		return false
+	}
+
+	if r.Description != o.Description {
+		return false
+	}
+
+	if !common.EqualIOLimits(r.ReadLimit, o.ReadLimit) || !common.EqualIOLimits(r.WriteLimit, o.WriteLimit) {
+		return false
+	}
+
+	if r.CacheMode != o.CacheMode {
+		return false
+	}
+
 	if len(r.Volumes) != len(o.Volumes) {
 		return false
 	}
@@ -245,6 +664,14 @@ func (r *ResourceConfig) Matches(o *ResourceConfig) bool {
 		if r.Volumes[i].SizeKiB != o.Volumes[i].SizeKiB {
 			return false
 		}
+
+		if r.Volumes[i].FileSystem != o.Volumes[i].FileSystem {
+			return false
+		}
+
+		if r.Volumes[i].IsExported() != o.Volumes[i].IsExported() {
+			return false
+		}
 	}
 
 	if r.Username != o.Username {
@@ -258,7 +685,34 @@ func (r *ResourceConfig) Matches(o *ResourceConfig) bool {
 	return true
 }
 
+// removeLogicalUnit removes the logical unit with the given number from the
+// config and from the deployed resources, so that a promoter config generated
+// from them has no reference to it left. It reports whether the logical unit
+// existed.
+func (r *ResourceConfig) removeLogicalUnit(resources []client.ResourceWithVolumes, lun int) bool {
+	for j := range r.Volumes {
+		if r.Volumes[j].Number == lun {
+			r.Volumes = append(r.Volumes[:j], r.Volumes[j+1:]...)
+			common.RemoveVolume(resources, lun)
+			return true
+		}
+	}
+
+	return false
+}
+
+// anyPortal makes LIO listen on all addresses of the node a target without
+// service IPs runs on.
+var anyPortal = fmt.Sprintf("0.0.0.0:%d", DefaultISCSIPort)
+
+// portals returns the portals of the target, one per service IP. The
+// iSCSITarget resource agent adds all of them to the first target portal
+// group, so every portal accepts the same initiators.
 func (r *ResourceConfig) portals() string {
+	if len(r.ServiceIPs) == 0 {
+		return anyPortal
+	}
+
 	var portals []string
 	for _, ip := range r.ServiceIPs {
 		portals = append(portals, fmt.Sprintf("%s:%d", ip.IP(), DefaultISCSIPort))
@@ -286,7 +740,7 @@ func (r *ResourceConfig) ToPromoter(deployment []client.ResourceWithVolumes) (*r
 	// volume 0 is reserved as the "cluster private" volume
 	clusterPrivateVol := r.Volumes[0]
 	deployedClusterPrivateVol := deployedRes.Volumes[0]
-	agents = append(agents, common.ClusterPrivateVolumeAgent(clusterPrivateVol, deployedClusterPrivateVol, r.IQN.WWN()))
+	agents = append(agents, common.ClusterPrivateVolumeAgent(clusterPrivateVol, deployedClusterPrivateVol, r.linstorResourceName()))
 
 	for i, ip := range r.ServiceIPs {
 		agents = append(agents, &reactor.ResourceAgent{
@@ -326,10 +780,19 @@ func (r *ResourceConfig) ToPromoter(deployment []client.ResourceWithVolumes) (*r
 
 	for i := 1; i < len(deployedRes.Volumes); i++ {
 		vol := deployedRes.Volumes[i]
+		if i >= len(r.Volumes) {
+			return nil, fmt.Errorf("inconsistent volumes, volume %d is deployed, but not configured", vol.VolumeNumber)
+		}
+
 		if int(vol.VolumeNumber) != r.Volumes[i].Number {
 			return nil, fmt.Errorf("inconsistent volumes, expected volume number %d, got %d", vol.VolumeNumber, r.Volumes[i].Number)
 		}
 
+		if !r.Volumes[i].IsExported() {
+			// the volume is created, but not mapped into the target yet
+			continue
+		}
+
 		devPath := vol.DevicePath
 		for k, v := range vol.Props {
 			if strings.HasPrefix(k, "Satellite/Device/Symlinks/") {
@@ -349,8 +812,8 @@ func (r *ResourceConfig) ToPromoter(deployment []client.ResourceWithVolumes) (*r
 		serial := fmt.Sprintf("%.4x", md5.Sum([]byte(r.IQN.String())))
 		log.WithField("iqn", r.IQN.String()).Tracef("Setting scsi serial number to %s", serial)
 
-		agents = append(agents, &reactor.ResourceAgent{
-			Type: "ocf:heartbeat:iSCSILogicalUnit",
+		lu := &reactor.ResourceAgent{
+			Type: agentTypeISCSILogicalUnit,
 			Name: fmt.Sprintf("lu%d", vol.VolumeNumber),
 			Attributes: map[string]string{
 				"target_iqn": r.IQN.String(),
@@ -359,7 +822,12 @@ func (r *ResourceConfig) ToPromoter(deployment []client.ResourceWithVolumes) (*r
 				"product_id": "LINSTOR iSCSI",
 				"scsi_sn":    serial,
 			},
-		})
+		}
+		if param := writeCacheParameter(r.CacheMode); param != "" {
+			lu.Attributes["additional_parameters"] = param
+		}
+
+		agents = append(agents, lu)
 	}
 
 	for i, ip := range r.ServiceIPs {
@@ -379,7 +847,7 @@ func (r *ResourceConfig) ToPromoter(deployment []client.ResourceWithVolumes) (*r
 	return &reactor.PromoterConfig{
 		ID: r.ID(),
 		Resources: map[string]reactor.PromoterResourceConfig{
-			r.IQN.WWN(): {
+			r.linstorResourceName(): {
 				Runner:              "systemd",
 				Start:               agents,
 				StopServicesOnExit:  true,
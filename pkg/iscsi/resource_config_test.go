@@ -1,8 +1,12 @@
 package iscsi
 
 import (
+	"encoding/json"
+	"fmt"
+	"github.com/LINBIT/golinstor/client"
 	"github.com/LINBIT/linstor-gateway/pkg/common"
 	"github.com/LINBIT/linstor-gateway/pkg/reactor"
+	"github.com/icza/gog"
 	"github.com/stretchr/testify/assert"
 	"testing"
 )
@@ -45,6 +49,46 @@ func TestParsePromoterConfig(t *testing.T) {
 				ServiceIPs: []common.IpCidr{ipnet("1.1.1.1/16")},
 			},
 		},
+		{
+			name: "write-back cache",
+			cfg: &reactor.PromoterConfig{
+				ID: "iscsi-target1",
+				Resources: map[string]reactor.PromoterResourceConfig{
+					"target1": {
+						Start: []reactor.StartEntry{
+							&reactor.ResourceAgent{Type: "ocf:heartbeat:portblock", Name: "pblock0", Attributes: map[string]string{"action": "block", "ip": "1.1.1.1", "portno": "3260", "protocol": "tcp"}},
+							&reactor.ResourceAgent{Type: "ocf:heartbeat:IPaddr2", Name: "service_ip0", Attributes: map[string]string{"cidr_netmask": "16", "ip": "1.1.1.1"}},
+							&reactor.ResourceAgent{Type: "ocf:heartbeat:iSCSITarget", Name: "target", Attributes: map[string]string{"allowed_initiators": "", "iqn": "iqn.2021-08.com.linbit:target1", "portals": "1.1.1.1:3260"}},
+							&reactor.ResourceAgent{Type: "ocf:heartbeat:iSCSILogicalUnit", Name: "lu1", Attributes: map[string]string{"lun": "1", "path": "/dev/drbd/by-res/target1/1", "product_id": "LINSTOR iSCSI", "target_iqn": "iqn.2021-08.com.linbit:target1", "additional_parameters": "emulate_write_cache=1"}},
+							&reactor.ResourceAgent{Type: "ocf:heartbeat:portblock", Name: "punblock0", Attributes: map[string]string{"action": "unblock", "ip": "1.1.1.1", "portno": "3260", "protocol": "tcp"}},
+						},
+					},
+				},
+			},
+			want: &ResourceConfig{
+				IQN:        Iqn{"iqn.2021-08.com.linbit", "target1"},
+				ServiceIPs: []common.IpCidr{ipnet("1.1.1.1/16")},
+				CacheMode:  CacheModeWriteBack,
+			},
+		},
+		{
+			name: "malformed write cache parameter",
+			cfg: &reactor.PromoterConfig{
+				ID: "iscsi-target1",
+				Resources: map[string]reactor.PromoterResourceConfig{
+					"target1": {
+						Start: []reactor.StartEntry{
+							&reactor.ResourceAgent{Type: "ocf:heartbeat:portblock", Name: "pblock0", Attributes: map[string]string{"action": "block", "ip": "1.1.1.1", "portno": "3260", "protocol": "tcp"}},
+							&reactor.ResourceAgent{Type: "ocf:heartbeat:IPaddr2", Name: "service_ip0", Attributes: map[string]string{"cidr_netmask": "16", "ip": "1.1.1.1"}},
+							&reactor.ResourceAgent{Type: "ocf:heartbeat:iSCSITarget", Name: "target", Attributes: map[string]string{"allowed_initiators": "", "iqn": "iqn.2021-08.com.linbit:target1", "portals": "1.1.1.1:3260"}},
+							&reactor.ResourceAgent{Type: "ocf:heartbeat:iSCSILogicalUnit", Name: "lu1", Attributes: map[string]string{"lun": "1", "path": "/dev/drbd/by-res/target1/1", "product_id": "LINSTOR iSCSI", "target_iqn": "iqn.2021-08.com.linbit:target1", "additional_parameters": "emulate_write_cache=yes"}},
+							&reactor.ResourceAgent{Type: "ocf:heartbeat:portblock", Name: "punblock0", Attributes: map[string]string{"action": "unblock", "ip": "1.1.1.1", "portno": "3260", "protocol": "tcp"}},
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
 		{
 			name: "invalid id",
 			cfg: &reactor.PromoterConfig{
@@ -105,6 +149,46 @@ func TestParsePromoterConfig(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "unknown agent",
+			cfg: &reactor.PromoterConfig{
+				ID: "iscsi-target1",
+				Resources: map[string]reactor.PromoterResourceConfig{
+					"target1": {
+						Start: []reactor.StartEntry{
+							&reactor.ResourceAgent{Type: "ocf:heartbeat:portblock", Name: "pblock0", Attributes: map[string]string{"action": "block", "ip": "1.1.1.1", "portno": "3260", "protocol": "tcp"}},
+							&reactor.ResourceAgent{Type: "ocf:heartbeat:IPaddr2", Name: "service_ip0", Attributes: map[string]string{"cidr_netmask": "16", "ip": "1.1.1.1"}},
+							&reactor.ResourceAgent{Type: "ocf:custom:thing", Name: "thing", Attributes: map[string]string{"foo": "bar"}},
+							&reactor.ResourceAgent{Type: "ocf:heartbeat:iSCSITarget", Name: "target", Attributes: map[string]string{"allowed_initiators": "", "iqn": "iqn.2021-08.com.linbit:target1", "portals": "1.1.1.1:3260"}},
+							&reactor.ResourceAgent{Type: "ocf:heartbeat:iSCSILogicalUnit", Name: "lu1", Attributes: map[string]string{"lun": "1", "path": "/dev/drbd/by-res/target1/1", "product_id": "LINSTOR iSCSI", "target_iqn": "iqn.2021-08.com.linbit:target1"}},
+							&reactor.ResourceAgent{Type: "ocf:heartbeat:portblock", Name: "punblock0", Attributes: map[string]string{"action": "unblock", "ip": "1.1.1.1", "portno": "3260", "protocol": "tcp"}},
+						},
+					},
+				},
+			},
+			want: &ResourceConfig{
+				IQN:        Iqn{"iqn.2021-08.com.linbit", "target1"},
+				ServiceIPs: []common.IpCidr{ipnet("1.1.1.1/16")},
+			},
+		},
+		{
+			name: "missing target",
+			cfg: &reactor.PromoterConfig{
+				ID: "iscsi-target1",
+				Resources: map[string]reactor.PromoterResourceConfig{
+					"target1": {
+						Start: []reactor.StartEntry{
+							&reactor.ResourceAgent{Type: "ocf:heartbeat:portblock", Name: "pblock0", Attributes: map[string]string{"action": "block", "ip": "1.1.1.1", "portno": "3260", "protocol": "tcp"}},
+							&reactor.ResourceAgent{Type: "ocf:heartbeat:IPaddr2", Name: "service_ip0", Attributes: map[string]string{"cidr_netmask": "16", "ip": "1.1.1.1"}},
+							&reactor.ResourceAgent{Type: "ocf:custom:thing", Name: "thing", Attributes: map[string]string{"foo": "bar"}},
+							&reactor.ResourceAgent{Type: "ocf:heartbeat:iSCSILogicalUnit", Name: "lu1", Attributes: map[string]string{"lun": "1", "path": "/dev/drbd/by-res/target1/1", "product_id": "LINSTOR iSCSI", "target_iqn": "iqn.2021-08.com.linbit:target1"}},
+							&reactor.ResourceAgent{Type: "ocf:heartbeat:portblock", Name: "punblock0", Attributes: map[string]string{"action": "unblock", "ip": "1.1.1.1", "portno": "3260", "protocol": "tcp"}},
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
 		{
 			name: "multiple ips",
 			cfg: &reactor.PromoterConfig{
@@ -147,3 +231,610 @@ func TestParsePromoterConfig(t *testing.T) {
 		})
 	}
 }
+
+func TestValid(t *testing.T) {
+	t.Parallel()
+	testcases := []struct {
+		name           string
+		volumes        []common.VolumeConfig
+		placementCount int
+		cacheMode      string
+		labels         map[string]string
+		resourceName   string
+		serviceIPs     []common.IpCidr
+		noServiceIP    bool
+		withoutIPs     bool
+		expectError    bool
+	}{{
+		name:    "raw block",
+		volumes: []common.VolumeConfig{{Number: 1, SizeKiB: 1024}},
+	}, {
+		name:    "ext4",
+		volumes: []common.VolumeConfig{{Number: 1, SizeKiB: 1024, FileSystem: "ext4", FileSystemRootOwner: common.UidGid{Uid: 1000, Gid: 1000}}},
+	}, {
+		name:    "xfs",
+		volumes: []common.VolumeConfig{{Number: 1, SizeKiB: 1024, FileSystem: "xfs"}},
+	}, {
+		name:        "unsupported file system",
+		volumes:     []common.VolumeConfig{{Number: 1, SizeKiB: 1024, FileSystem: "vfat"}},
+		expectError: true,
+	}, {
+		name:        "root owner without file system",
+		volumes:     []common.VolumeConfig{{Number: 1, SizeKiB: 1024, FileSystemRootOwner: common.UidGid{Uid: 1000, Gid: 1000}}},
+		expectError: true,
+	}, {
+		name:        "root owner with xfs",
+		volumes:     []common.VolumeConfig{{Number: 1, SizeKiB: 1024, FileSystem: "xfs", FileSystemRootOwner: common.UidGid{Uid: 1000, Gid: 1000}}},
+		expectError: true,
+	}, {
+		name:           "placement count",
+		volumes:        []common.VolumeConfig{{Number: 1, SizeKiB: 1024}},
+		placementCount: 2,
+	}, {
+		name:           "negative placement count",
+		volumes:        []common.VolumeConfig{{Number: 1, SizeKiB: 1024}},
+		placementCount: -1,
+		expectError:    true,
+	}, {
+		name:      "write-through cache",
+		volumes:   []common.VolumeConfig{{Number: 1, SizeKiB: 1024}},
+		cacheMode: CacheModeWriteThrough,
+	}, {
+		name:        "unsupported cache mode",
+		volumes:     []common.VolumeConfig{{Number: 1, SizeKiB: 1024}},
+		cacheMode:   "none",
+		expectError: true,
+	}, {
+		name:    "labels",
+		volumes: []common.VolumeConfig{{Number: 1, SizeKiB: 1024}},
+		labels:  map[string]string{"team": "foo"},
+	}, {
+		name:        "invalid label",
+		volumes:     []common.VolumeConfig{{Number: 1, SizeKiB: 1024}},
+		labels:      map[string]string{"team": "foo bar"},
+		expectError: true,
+	}, {
+		name:         "resource name",
+		volumes:      []common.VolumeConfig{{Number: 1, SizeKiB: 1024}},
+		resourceName: "custom_name",
+	}, {
+		name:         "invalid resource name",
+		volumes:      []common.VolumeConfig{{Number: 1, SizeKiB: 1024}},
+		resourceName: "custom.name",
+		expectError:  true,
+	}, {
+		name:    "unexported",
+		volumes: []common.VolumeConfig{{Number: 1, SizeKiB: 1024, Exported: new(bool)}},
+	}, {
+		name:        "no service ip",
+		volumes:     []common.VolumeConfig{{Number: 1, SizeKiB: 1024}},
+		noServiceIP: true,
+		withoutIPs:  true,
+	}, {
+		name:        "missing service ips",
+		volumes:     []common.VolumeConfig{{Number: 1, SizeKiB: 1024}},
+		withoutIPs:  true,
+		expectError: true,
+	}, {
+		name:        "service ips with no service ip",
+		volumes:     []common.VolumeConfig{{Number: 1, SizeKiB: 1024}},
+		noServiceIP: true,
+		expectError: true,
+	}, {
+		name:        "duplicate volume number",
+		volumes:     []common.VolumeConfig{{Number: 1, SizeKiB: 1024}, {Number: 2, SizeKiB: 1024}, {Number: 1, SizeKiB: 2048}},
+		expectError: true,
+	}, {
+		name:        "user supplied cluster private volume",
+		volumes:     []common.VolumeConfig{{Number: 0, SizeKiB: 1024}, {Number: 1, SizeKiB: 1024}},
+		expectError: true,
+	}, {
+		name:       "multiple service ips",
+		volumes:    []common.VolumeConfig{{Number: 1, SizeKiB: 1024}},
+		serviceIPs: []common.IpCidr{ipnet("192.168.127.1/24"), ipnet("10.0.0.1/16")},
+	}, {
+		name:        "duplicate service ip",
+		volumes:     []common.VolumeConfig{{Number: 1, SizeKiB: 1024}},
+		serviceIPs:  []common.IpCidr{ipnet("192.168.127.1/24"), ipnet("192.168.127.1/16")},
+		expectError: true,
+	}}
+
+	for i := range testcases {
+		tcase := &testcases[i]
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := ResourceConfig{
+				IQN:            Iqn{"iqn.2019-08.com.linbit", "example"},
+				ServiceIPs:     []common.IpCidr{ipnet("192.168.127.1/24")},
+				Volumes:        append([]common.VolumeConfig{common.ClusterPrivateVolume()}, tcase.volumes...),
+				PlacementCount: tcase.placementCount,
+				CacheMode:      tcase.cacheMode,
+				Labels:         tcase.labels,
+				ResourceName:   tcase.resourceName,
+				NoServiceIP:    tcase.noServiceIP,
+			}
+			if tcase.serviceIPs != nil {
+				cfg.ServiceIPs = tcase.serviceIPs
+			}
+			if tcase.withoutIPs {
+				cfg.ServiceIPs = nil
+			}
+			cfg.FillDefaults()
+			err := cfg.Valid()
+			if tcase.expectError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestResourceConfig_RedactsPassword(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name     string
+		password string
+		expected string
+	}{{
+		name:     "password set",
+		password: "secret",
+		expected: `"password":"****"`,
+	}, {
+		name:     "no password",
+		password: "",
+		expected: `"username":"user","service_ips"`,
+	}}
+
+	for i := range cases {
+		tcase := &cases[i]
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+			cfg := &ResourceConfig{
+				IQN:      Iqn{"iqn.2021-08.com.linbit", "target1"},
+				Username: "user",
+				Password: tcase.password,
+			}
+
+			text, err := json.Marshal(cfg)
+			assert.NoError(t, err)
+			assert.Contains(t, string(text), tcase.expected)
+			assert.Contains(t, fmt.Sprintf("%v", cfg), "Password:"+common.Redact(tcase.password)+" ")
+
+			text, err = json.Marshal((*ResourceConfigWithSecrets)(cfg))
+			assert.NoError(t, err)
+			if tcase.password != "" {
+				assert.Contains(t, string(text), `"password":"secret"`)
+			}
+		})
+	}
+}
+
+func TestRemoveLogicalUnit(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name     string
+		lun      int
+		removed  bool
+		expected []string
+	}{{
+		name:     "middle",
+		lun:      2,
+		removed:  true,
+		expected: []string{"lu1", "lu3"},
+	}, {
+		name:     "last",
+		lun:      3,
+		removed:  true,
+		expected: []string{"lu1", "lu2"},
+	}, {
+		name:     "unknown",
+		lun:      4,
+		expected: []string{"lu1", "lu2", "lu3"},
+	}}
+
+	for i := range cases {
+		tcase := &cases[i]
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+			cfg := &ResourceConfig{
+				IQN:        Iqn{"iqn.2021-08.com.linbit", "target1"},
+				ServiceIPs: []common.IpCidr{ipnet("1.1.1.1/16")},
+				Volumes: []common.VolumeConfig{
+					common.ClusterPrivateVolume(),
+					{Number: 1, SizeKiB: 1024},
+					{Number: 2, SizeKiB: 1024},
+					{Number: 3, SizeKiB: 1024},
+				},
+			}
+			resources := []client.ResourceWithVolumes{{
+				Resource: client.Resource{Name: "target1", NodeName: "node1"},
+				Volumes: []client.Volume{
+					{VolumeNumber: 0, DevicePath: "/dev/drbd1000"},
+					{VolumeNumber: 1, DevicePath: "/dev/drbd1001"},
+					{VolumeNumber: 2, DevicePath: "/dev/drbd1002"},
+					{VolumeNumber: 3, DevicePath: "/dev/drbd1003"},
+				},
+			}}
+
+			assert.Equal(t, tcase.removed, cfg.removeLogicalUnit(resources, tcase.lun))
+
+			promoter, err := cfg.ToPromoter(resources)
+			assert.NoError(t, err)
+
+			var lus []string
+			for _, entry := range promoter.Resources["target1"].Start {
+				agent, ok := entry.(*reactor.ResourceAgent)
+				if !ok || agent.Type != "ocf:heartbeat:iSCSILogicalUnit" {
+					continue
+				}
+				lus = append(lus, agent.Name)
+
+				if tcase.removed {
+					assert.NotEqual(t, fmt.Sprint(tcase.lun), agent.Attributes["lun"])
+					assert.NotEqual(t, fmt.Sprintf("/dev/drbd100%d", tcase.lun), agent.Attributes["path"])
+				}
+			}
+			assert.Equal(t, tcase.expected, lus)
+
+			text, err := json.Marshal(promoter)
+			assert.NoError(t, err)
+			if tcase.removed {
+				assert.NotContains(t, string(text), fmt.Sprintf("/dev/drbd100%d", tcase.lun))
+			}
+		})
+	}
+}
+
+func TestToPromoter_CacheMode(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name      string
+		cacheMode string
+		expected  string
+	}{{
+		name: "default",
+	}, {
+		name:      "write-through",
+		cacheMode: CacheModeWriteThrough,
+		expected:  "emulate_write_cache=0",
+	}, {
+		name:      "write-back",
+		cacheMode: CacheModeWriteBack,
+		expected:  "emulate_write_cache=1",
+	}}
+
+	for i := range cases {
+		tcase := &cases[i]
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+			cfg := &ResourceConfig{
+				IQN:        Iqn{"iqn.2021-08.com.linbit", "target1"},
+				ServiceIPs: []common.IpCidr{ipnet("1.1.1.1/16")},
+				Volumes:    []common.VolumeConfig{common.ClusterPrivateVolume(), {Number: 1, SizeKiB: 1024}},
+				CacheMode:  tcase.cacheMode,
+			}
+			resources := []client.ResourceWithVolumes{{
+				Resource: client.Resource{Name: "target1", NodeName: "node1"},
+				Volumes: []client.Volume{
+					{VolumeNumber: 0, DevicePath: "/dev/drbd1000"},
+					{VolumeNumber: 1, DevicePath: "/dev/drbd1001"},
+				},
+			}}
+
+			promoter, err := cfg.ToPromoter(resources)
+			assert.NoError(t, err)
+
+			parsed, err := parsePromoterConfig(promoter)
+			assert.NoError(t, err)
+			assert.Equal(t, tcase.cacheMode, parsed.CacheMode)
+
+			for _, entry := range promoter.Resources["target1"].Start {
+				agent, ok := entry.(*reactor.ResourceAgent)
+				if ok && agent.Type == "ocf:heartbeat:iSCSILogicalUnit" {
+					assert.Equal(t, tcase.expected, agent.Attributes["additional_parameters"])
+				}
+			}
+		})
+	}
+}
+
+func TestToPromoter_Unexported(t *testing.T) {
+	t.Parallel()
+	cfg := &ResourceConfig{
+		IQN:        Iqn{"iqn.2021-08.com.linbit", "target1"},
+		ServiceIPs: []common.IpCidr{ipnet("1.1.1.1/16")},
+		Volumes: []common.VolumeConfig{
+			common.ClusterPrivateVolume(),
+			{Number: 1, SizeKiB: 1024},
+			{Number: 2, SizeKiB: 1024, Exported: new(bool)},
+		},
+	}
+	resources := []client.ResourceWithVolumes{{
+		Resource: client.Resource{Name: "target1", NodeName: "node1"},
+		Volumes: []client.Volume{
+			{VolumeNumber: 0, DevicePath: "/dev/drbd1000"},
+			{VolumeNumber: 1, DevicePath: "/dev/drbd1001"},
+			{VolumeNumber: 2, DevicePath: "/dev/drbd1002"},
+		},
+	}}
+
+	promoter, err := cfg.ToPromoter(resources)
+	assert.NoError(t, err)
+
+	var luns []string
+	for _, entry := range promoter.Resources["target1"].Start {
+		agent, ok := entry.(*reactor.ResourceAgent)
+		if ok && agent.Type == "ocf:heartbeat:iSCSILogicalUnit" {
+			luns = append(luns, agent.Attributes["lun"])
+		}
+	}
+	assert.Equal(t, []string{"1"}, luns)
+
+	volumeDefinitions := []client.VolumeDefinition{
+		{VolumeNumber: gog.Ptr(int32(1)), SizeKib: 1024, Props: common.ExportedProps(true)},
+		{VolumeNumber: gog.Ptr(int32(2)), SizeKib: 1024, Props: common.ExportedProps(false)},
+	}
+	parsed, err := FromPromoter(promoter, &client.ResourceDefinition{Name: "target1"}, volumeDefinitions)
+	assert.NoError(t, err)
+	assert.Len(t, parsed.Volumes, 2)
+	assert.True(t, parsed.Volumes[0].IsExported())
+	assert.False(t, parsed.Volumes[1].IsExported())
+}
+
+func TestToPromoter_FewerVolumes(t *testing.T) {
+	t.Parallel()
+	cfg := &ResourceConfig{
+		IQN:        Iqn{"iqn.2021-08.com.linbit", "target1"},
+		ServiceIPs: []common.IpCidr{ipnet("1.1.1.1/16")},
+		Volumes:    []common.VolumeConfig{common.ClusterPrivateVolume(), {Number: 1, SizeKiB: 1024}},
+	}
+	resources := []client.ResourceWithVolumes{{
+		Resource: client.Resource{Name: "target1", NodeName: "node1"},
+		Volumes: []client.Volume{
+			{VolumeNumber: 0, DevicePath: "/dev/drbd1000"},
+			{VolumeNumber: 1, DevicePath: "/dev/drbd1001"},
+			{VolumeNumber: 2, DevicePath: "/dev/drbd1002"},
+		},
+	}}
+
+	_, err := cfg.ToPromoter(resources)
+	assert.ErrorContains(t, err, "volume 2 is deployed, but not configured")
+}
+
+func TestToPromoter_NoServiceIP(t *testing.T) {
+	t.Parallel()
+	cfg := &ResourceConfig{
+		IQN:         Iqn{"iqn.2021-08.com.linbit", "target1"},
+		NoServiceIP: true,
+		Volumes: []common.VolumeConfig{
+			common.ClusterPrivateVolume(),
+			{Number: 1, SizeKiB: 1024},
+		},
+	}
+	resources := []client.ResourceWithVolumes{{
+		Resource: client.Resource{Name: "target1", NodeName: "node1"},
+		Volumes: []client.Volume{
+			{VolumeNumber: 0, DevicePath: "/dev/drbd1000"},
+			{VolumeNumber: 1, DevicePath: "/dev/drbd1001"},
+		},
+	}}
+
+	promoter, err := cfg.ToPromoter(resources)
+	assert.NoError(t, err)
+
+	var types []string
+	var portals string
+	for _, entry := range promoter.Resources["target1"].Start {
+		agent, ok := entry.(*reactor.ResourceAgent)
+		if !ok {
+			continue
+		}
+		types = append(types, agent.Type)
+		if agent.Type == "ocf:heartbeat:iSCSITarget" {
+			portals = agent.Attributes["portals"]
+		}
+	}
+	assert.Equal(t, []string{"ocf:heartbeat:Filesystem", "ocf:heartbeat:iSCSITarget", "ocf:heartbeat:iSCSILogicalUnit"}, types)
+	assert.Equal(t, "0.0.0.0:3260", portals)
+
+	volumeDefinitions := []client.VolumeDefinition{
+		{VolumeNumber: gog.Ptr(int32(1)), SizeKib: 1024},
+	}
+	parsed, err := FromPromoter(promoter, &client.ResourceDefinition{Name: "target1"}, volumeDefinitions)
+	assert.NoError(t, err)
+	assert.True(t, parsed.NoServiceIP)
+	assert.Empty(t, parsed.ServiceIPs)
+}
+
+func TestToPromoter_MultiplePortals(t *testing.T) {
+	t.Parallel()
+	cfg := &ResourceConfig{
+		IQN:        Iqn{"iqn.2021-08.com.linbit", "target1"},
+		ServiceIPs: []common.IpCidr{ipnet("192.168.127.1/24"), ipnet("10.0.0.1/16")},
+		Volumes: []common.VolumeConfig{
+			common.ClusterPrivateVolume(),
+			{Number: 1, SizeKiB: 1024},
+		},
+	}
+	resources := []client.ResourceWithVolumes{{
+		Resource: client.Resource{Name: "target1", NodeName: "node1"},
+		Volumes: []client.Volume{
+			{VolumeNumber: 0, DevicePath: "/dev/drbd1000"},
+			{VolumeNumber: 1, DevicePath: "/dev/drbd1001"},
+		},
+	}}
+
+	promoter, err := cfg.ToPromoter(resources)
+	assert.NoError(t, err)
+
+	var targets int
+	var portals string
+	for _, entry := range promoter.Resources["target1"].Start {
+		agent, ok := entry.(*reactor.ResourceAgent)
+		if ok && agent.Type == "ocf:heartbeat:iSCSITarget" {
+			targets++
+			portals = agent.Attributes["portals"]
+		}
+	}
+	assert.Equal(t, 1, targets)
+	assert.Equal(t, "192.168.127.1:3260 10.0.0.1:3260", portals)
+
+	volumeDefinitions := []client.VolumeDefinition{
+		{VolumeNumber: gog.Ptr(int32(1)), SizeKib: 1024},
+	}
+	parsed, err := FromPromoter(promoter, &client.ResourceDefinition{Name: "target1"}, volumeDefinitions)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"192.168.127.1/24", "10.0.0.1/16"}, []string{parsed.ServiceIPs[0].String(), parsed.ServiceIPs[1].String()})
+}
+
+func TestCloneAs(t *testing.T) {
+	t.Parallel()
+	src := &ResourceConfig{
+		IQN:               Iqn{"iqn.2021-08.com.linbit", "target1"},
+		AllowedInitiators: []Iqn{{"iqn.2021-08.com.linbit", "initiator1"}},
+		ResourceGroup:     "rg",
+		Volumes: []common.VolumeConfig{
+			common.ClusterPrivateVolume(),
+			{Number: 1, SizeKiB: 1024},
+			{Number: 2, SizeKiB: 2048, FileSystem: "ext4"},
+		},
+		Username:       "user",
+		Password:       "secret",
+		ServiceIPs:     []common.IpCidr{ipnet("192.168.1.10/24")},
+		Status:         common.ResourceStatus{Primary: "node1"},
+		PlacementCount: 2,
+		DrbdPort:       7010,
+		CacheMode:      "writeback",
+		Labels:         map[string]string{"team": "foo"},
+		DrbdOptions:    map[string]string{"protocol": "A"},
+	}
+	dst := Iqn{"iqn.2021-08.com.linbit", "target2"}
+
+	cases := []struct {
+		name       string
+		iqn        Iqn
+		serviceIPs []common.IpCidr
+		wantErr    bool
+	}{{
+		name:       "new service ip",
+		iqn:        dst,
+		serviceIPs: []common.IpCidr{ipnet("192.168.1.11/24")},
+	}, {
+		name:    "missing service ips",
+		iqn:     dst,
+		wantErr: true,
+	}, {
+		name:       "service ip of source",
+		iqn:        dst,
+		serviceIPs: []common.IpCidr{ipnet("192.168.1.10/16")},
+		wantErr:    true,
+	}, {
+		name:       "same iqn",
+		iqn:        src.IQN,
+		serviceIPs: []common.IpCidr{ipnet("192.168.1.11/24")},
+		wantErr:    true,
+	}}
+
+	for i := range cases {
+		tcase := &cases[i]
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+			actual, err := src.CloneAs(tcase.iqn, tcase.serviceIPs)
+			if tcase.wantErr {
+				assert.Error(t, err)
+				assert.IsType(t, common.ValidationError(""), err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, &ResourceConfig{
+				IQN:               dst,
+				AllowedInitiators: src.AllowedInitiators,
+				ResourceGroup:     "rg",
+				Volumes:           src.Volumes[1:],
+				Username:          "user",
+				Password:          "secret",
+				ServiceIPs:        tcase.serviceIPs,
+				PlacementCount:    2,
+				CacheMode:         "writeback",
+				Labels:            map[string]string{"team": "foo"},
+				DrbdOptions:       map[string]string{"protocol": "A"},
+			}, actual)
+		})
+	}
+}
+
+func TestMatches(t *testing.T) {
+	t.Parallel()
+	deployed := func() *ResourceConfig {
+		return &ResourceConfig{
+			IQN:            Iqn{"iqn.2021-08.com.linbit", "target1"},
+			ResourceName:   "target1",
+			ResourceGroup:  "rg",
+			Volumes:        []common.VolumeConfig{common.ClusterPrivateVolume(), {Number: 1, SizeKiB: 1024}},
+			ServiceIPs:     []common.IpCidr{ipnet("192.168.1.10/24")},
+			PlacementCount: 2,
+			LayerList:      []string{"DRBD", "STORAGE"},
+			StoragePool:    "thin",
+			DrbdPort:       7010,
+			DrbdMinor:      1010,
+			Labels:         map[string]string{"team": "foo"},
+			Description:    "database",
+			DrbdOptions:    map[string]string{"protocol": "A", "quorum": "majority", "on-no-quorum": "io-error"},
+		}
+	}
+
+	cases := []struct {
+		name    string
+		change  func(r *ResourceConfig)
+		matches bool
+	}{{
+		name:    "unchanged",
+		change:  func(r *ResourceConfig) {},
+		matches: true,
+	}, {
+		name:    "port and minor assigned by LINSTOR",
+		change:  func(r *ResourceConfig) { r.DrbdPort, r.DrbdMinor = 0, 0 },
+		matches: true,
+	}, {
+		name:    "default DRBD options",
+		change:  func(r *ResourceConfig) { r.DrbdOptions = map[string]string{"protocol": "A"} },
+		matches: true,
+	}, {
+		name:   "placement count",
+		change: func(r *ResourceConfig) { r.PlacementCount = 3 },
+	}, {
+		name:   "layer list",
+		change: func(r *ResourceConfig) { r.LayerList = nil },
+	}, {
+		name:   "storage pool",
+		change: func(r *ResourceConfig) { r.StoragePool = "thick" },
+	}, {
+		name:   "DRBD port",
+		change: func(r *ResourceConfig) { r.DrbdPort = 7011 },
+	}, {
+		name:   "DRBD minor",
+		change: func(r *ResourceConfig) { r.DrbdMinor = 1011 },
+	}, {
+		name:   "labels",
+		change: func(r *ResourceConfig) { r.Labels = map[string]string{"team": "bar"} },
+	}, {
+		name:   "DRBD options",
+		change: func(r *ResourceConfig) { r.DrbdOptions = map[string]string{"protocol": "C"} },
+	}, {
+		name:   "description",
+		change: func(r *ResourceConfig) { r.Description = "" },
+	}}
+
+	for i := range cases {
+		tcase := &cases[i]
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+			requested := deployed()
+			tcase.change(requested)
+			assert.Equal(t, tcase.matches, requested.Matches(deployed()))
+		})
+	}
+}
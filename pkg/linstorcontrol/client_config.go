@@ -0,0 +1,93 @@
+package linstorcontrol
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ClientConfigPaths are the configuration files of the linstor command line
+// client, in the order it reads them. Settings in later files take
+// precedence. A leading "~/" is replaced by the home directory of the
+// current user.
+var ClientConfigPaths = []string{
+	"/etc/linstor/linstor-client.conf",
+	"~/.config/linstor/linstor-client.conf",
+}
+
+// ControllersFromClientConfig returns the LINSTOR controllers configured for
+// the linstor command line client in the given files, i.e. the "controllers"
+// setting of the "[global]" section. Files that do not exist are skipped. It
+// returns nil if none of the files configures any controllers.
+func ControllersFromClientConfig(paths []string) ([]string, error) {
+	var controllers []string
+	for _, path := range paths {
+		if strings.HasPrefix(path, "~/") {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				continue
+			}
+			path = filepath.Join(home, path[2:])
+		}
+
+		value, found, err := readClientConfigControllers(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read LINSTOR client config %s: %w", path, err)
+		}
+		if !found {
+			continue
+		}
+
+		controllers = nil
+		for _, c := range strings.Split(value, ",") {
+			c = strings.TrimSpace(c)
+			if c != "" {
+				controllers = append(controllers, c)
+			}
+		}
+	}
+
+	return controllers, nil
+}
+
+// readClientConfigControllers reads the "controllers" setting of the
+// "[global]" section from the INI file at path.
+func readClientConfigControllers(path string) (string, bool, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	defer f.Close()
+
+	section := ""
+	value, found := "", false
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+
+		key, v, ok := strings.Cut(line, "=")
+		if !ok {
+			key, v, ok = strings.Cut(line, ":")
+		}
+		if ok && section == "global" && strings.TrimSpace(key) == "controllers" {
+			value, found = strings.TrimSpace(v), true
+		}
+	}
+
+	return value, found, scanner.Err()
+}
@@ -0,0 +1,57 @@
+package linstorcontrol
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestControllersFromClientConfig(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		err := os.WriteFile(path, []byte(content), 0o644)
+		assert.NoError(t, err)
+		return path
+	}
+
+	system := write("system.conf", "# system wide\n[global]\ncontrollers = alpha, linstor://bravo:3370\n\n[other]\ncontrollers=ignored\n")
+	user := write("user.conf", "[global]\ncontrollers: charlie\n")
+	noControllers := write("none.conf", "[global]\nmachine-readable = true\n")
+	missing := filepath.Join(dir, "missing.conf")
+
+	cases := []struct {
+		name     string
+		paths    []string
+		expected []string
+	}{{
+		name:     "system config",
+		paths:    []string{system, missing},
+		expected: []string{"alpha", "linstor://bravo:3370"},
+	}, {
+		name:     "user config takes precedence",
+		paths:    []string{system, user},
+		expected: []string{"charlie"},
+	}, {
+		name:     "no controllers",
+		paths:    []string{noControllers, missing},
+		expected: nil,
+	}, {
+		name:     "later file without controllers",
+		paths:    []string{system, noControllers},
+		expected: []string{"alpha", "linstor://bravo:3370"},
+	}}
+
+	for i := range cases {
+		tcase := &cases[i]
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+			actual, err := ControllersFromClientConfig(tcase.paths)
+			assert.NoError(t, err)
+			assert.Equal(t, tcase.expected, actual)
+		})
+	}
+}
@@ -7,18 +7,35 @@ import (
 	"errors"
 	"fmt"
 	"github.com/icza/gog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
 	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	apiconsts "github.com/LINBIT/golinstor"
 	"github.com/LINBIT/golinstor/client"
+	"github.com/LINBIT/golinstor/devicelayerkind"
+	"github.com/google/uuid"
 	log "github.com/sirupsen/logrus"
 
 	"github.com/LINBIT/linstor-gateway/pkg/common"
+	"github.com/LINBIT/linstor-gateway/pkg/observe"
+	"github.com/LINBIT/linstor-gateway/pkg/reactor"
 )
 
 // Linstor is a struct containing the configuration that is needed to create or delete a LINSTOR resource.
 type Linstor struct {
 	*client.Client
+	// Retry configures how transient errors are retried in EnsureResource.
+	Retry RetryConfig
+	// httpClient is the HTTP client used by Client, if it is owned by
+	// this struct and therefore must be cleaned up by Close.
+	httpClient *http.Client
 }
 
 type Resource struct {
@@ -27,6 +44,320 @@ type Resource struct {
 	ResourceGroup string                `json:"resource_group_name,omitempty"`
 	FileSystem    string                `json:"file_system,omitempty"`
 	GrossSize     bool                  `json:"gross_size"`
+	// PlacementCount overrides the number of diskful replicas configured in
+	// the resource group. If 0, the resource group's value is used.
+	PlacementCount int `json:"placement_count,omitempty"`
+	// IOLimits are the throughput limits of the resource. If nil, the
+	// limits of an existing resource definition are left unchanged.
+	IOLimits *IOLimits `json:"io_limits,omitempty"`
+	// LayerList overrides the LINSTOR layers configured in the resource
+	// group, e.g. DRBD,STORAGE. If empty, the resource group's layers are
+	// used.
+	LayerList []string `json:"layer_list,omitempty"`
+	// StoragePool overrides the storage pools configured in the resource
+	// group, so that all diskful replicas are placed in this pool. If empty,
+	// the resource group's storage pools are used.
+	StoragePool string `json:"storage_pool,omitempty"`
+	// DrbdPort is the TCP port DRBD uses for the resource. If 0, LINSTOR
+	// assigns a free port.
+	DrbdPort int `json:"drbd_port,omitempty"`
+	// DrbdMinor is the DRBD minor number of volume 0. Every other volume
+	// uses this number plus its volume number. If 0, LINSTOR assigns free
+	// minor numbers.
+	DrbdMinor int `json:"drbd_minor,omitempty"`
+	// Labels are set as properties of the resource definition when it is
+	// created, so that resources can be selected by them later.
+	Labels map[string]string `json:"labels,omitempty"`
+	// DrbdOptions override the DRBD options of the resource definition
+	// when it is created, e.g. the replication protocol.
+	DrbdOptions map[string]string `json:"drbd_options,omitempty"`
+	// Description is a free text describing the resource, set as property
+	// of the resource definition when it is created.
+	Description string `json:"description,omitempty"`
+}
+
+// layerKinds converts a layer list to the type used by the LINSTOR API.
+func layerKinds(layers []string) []devicelayerkind.DeviceLayerKind {
+	if len(layers) == 0 {
+		return nil
+	}
+
+	kinds := make([]devicelayerkind.DeviceLayerKind, len(layers))
+	for i, layer := range layers {
+		kinds[i] = devicelayerkind.DeviceLayerKind(layer)
+	}
+
+	return kinds
+}
+
+// IOLimits are the read and write throughput limits of a resource. A nil
+// limit means that the throughput is not limited.
+type IOLimits struct {
+	Read  *common.IOLimit `json:"read,omitempty"`
+	Write *common.IOLimit `json:"write,omitempty"`
+}
+
+// These LINSTOR properties limit the throughput of a resource, either in
+// bytes or in IO operations per second.
+const (
+	readLimitProp      = apiconsts.NamespcSysFs + "/" + apiconsts.KeySysFsBlkioThrottleRead
+	readIOPSLimitProp  = apiconsts.NamespcSysFs + "/" + apiconsts.KeySysFsBlkioThrottleReadIops
+	writeLimitProp     = apiconsts.NamespcSysFs + "/" + apiconsts.KeySysFsBlkioThrottleWrite
+	writeIOPSLimitProp = apiconsts.NamespcSysFs + "/" + apiconsts.KeySysFsBlkioThrottleWriteIops
+)
+
+// props returns the properties to set and to delete on a resource definition
+// so that its throughput is limited as configured.
+func (l *IOLimits) props() (map[string]string, []string) {
+	override := map[string]string{}
+	var remove []string
+
+	set := func(limit *common.IOLimit, bytesProp, iopsProp string) {
+		if limit == nil {
+			remove = append(remove, bytesProp, iopsProp)
+			return
+		}
+
+		prop, other := bytesProp, iopsProp
+		if limit.IOPS {
+			prop, other = iopsProp, bytesProp
+		}
+		override[prop] = strconv.FormatInt(limit.Value, 10)
+		remove = append(remove, other)
+	}
+
+	set(l.Read, readLimitProp, readIOPSLimitProp)
+	set(l.Write, writeLimitProp, writeIOPSLimitProp)
+
+	return override, remove
+}
+
+// IOLimitsFromDefinition returns the throughput limits set on the given
+// resource definition. If both a limit in bytes and in IO operations per
+// second are set, the one in bytes is returned.
+func IOLimitsFromDefinition(definition *client.ResourceDefinition) IOLimits {
+	if definition == nil {
+		return IOLimits{}
+	}
+
+	limit := func(bytesProp, iopsProp string) *common.IOLimit {
+		value, err := strconv.ParseInt(definition.Props[bytesProp], 10, 64)
+		if err == nil && value > 0 {
+			return &common.IOLimit{Value: value}
+		}
+
+		value, err = strconv.ParseInt(definition.Props[iopsProp], 10, 64)
+		if err == nil && value > 0 {
+			return &common.IOLimit{Value: value, IOPS: true}
+		}
+
+		return nil
+	}
+
+	return IOLimits{Read: limit(readLimitProp, readIOPSLimitProp), Write: limit(writeLimitProp, writeIOPSLimitProp)}
+}
+
+// placementCountProp stores the placement count of a resource that overrides
+// the one of its resource group, so that it is kept when volumes are added
+// later and the expected number of replicas can be reported.
+const placementCountProp = apiconsts.NamespcAuxiliary + "/linstor-gateway/placement-count"
+
+// PlacementCount returns the number of diskful replicas requested for the
+// resource definition when it was created, or 0 if the placement count of the
+// resource group applies.
+func PlacementCount(definition *client.ResourceDefinition) int {
+	if definition == nil {
+		return 0
+	}
+
+	count, err := strconv.Atoi(definition.Props[placementCountProp])
+	if err != nil || count < 1 {
+		return 0
+	}
+
+	return count
+}
+
+// layerListProp stores the layer list of a resource that overrides the one of
+// its resource group, so that it can be reported later.
+const layerListProp = apiconsts.NamespcAuxiliary + "/linstor-gateway/layer-list"
+
+// LayerList returns the LINSTOR layers requested for the resource definition
+// when it was created, or nil if the layers of the resource group apply.
+func LayerList(definition *client.ResourceDefinition) []string {
+	if definition == nil || definition.Props[layerListProp] == "" {
+		return nil
+	}
+
+	return strings.Split(definition.Props[layerListProp], ",")
+}
+
+// storagePoolProp stores the storage pool of a resource that overrides the
+// ones of its resource group, so that it is kept when volumes are added later
+// and can be reported.
+const storagePoolProp = apiconsts.NamespcAuxiliary + "/linstor-gateway/storage-pool"
+
+// StoragePool returns the storage pool requested for the resource definition
+// when it was created, or "" if the storage pools of the resource group apply.
+func StoragePool(definition *client.ResourceDefinition) string {
+	if definition == nil {
+		return ""
+	}
+
+	return definition.Props[storagePoolProp]
+}
+
+// labelPropPrefix is the prefix of the properties that store the labels of a
+// resource. The key of the label is appended.
+const labelPropPrefix = apiconsts.NamespcAuxiliary + "/linstor-gateway/label/"
+
+// Labels returns the labels set on the resource definition when it was
+// created, or nil if there are none.
+func Labels(definition *client.ResourceDefinition) map[string]string {
+	if definition == nil {
+		return nil
+	}
+
+	var labels map[string]string
+	for k, v := range definition.Props {
+		if !strings.HasPrefix(k, labelPropPrefix) {
+			continue
+		}
+
+		if labels == nil {
+			labels = make(map[string]string)
+		}
+		labels[strings.TrimPrefix(k, labelPropPrefix)] = v
+	}
+
+	return labels
+}
+
+// createTokenProp stores a random token unique to the call that created a
+// resource definition. It tells whether a resource definition that exists
+// after a lost response was created by us.
+const createTokenProp = apiconsts.NamespcAuxiliary + "/linstor-gateway/create-token"
+
+// descriptionProp stores the free text description of a resource.
+const descriptionProp = apiconsts.NamespcAuxiliary + "/linstor-gateway/description"
+
+// Description returns the description of the resource, or "" if it has none.
+func Description(definition *client.ResourceDefinition) string {
+	if definition == nil {
+		return ""
+	}
+
+	return definition.Props[descriptionProp]
+}
+
+// SetDescription changes the description of an existing resource. An empty
+// description removes it.
+func (l *Linstor) SetDescription(ctx context.Context, name, description string) error {
+	modify := client.GenericPropsModify{OverrideProps: map[string]string{descriptionProp: description}}
+	if description == "" {
+		modify = client.GenericPropsModify{DeleteProps: []string{descriptionProp}}
+	}
+
+	err := l.retry(ctx, func() error {
+		return l.ResourceDefinitions.Modify(ctx, name, modify)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to set description of resource '%s': %w", name, err)
+	}
+
+	return nil
+}
+
+// keptProp is set on the resource definitions of resources that are kept
+// when their target or export is deleted, so that they are not mistaken for
+// leftovers of failed operations.
+const keptProp = apiconsts.NamespcAuxiliary + "/linstor-gateway/kept"
+
+// Kept reports whether the resource was kept when its target or export was
+// deleted.
+func Kept(definition *client.ResourceDefinition) bool {
+	return definition != nil && definition.Props[keptProp] == "True"
+}
+
+// MarkKept records that the resource name was kept when its target or export
+// was deleted, to hand it over to manual management.
+func (l *Linstor) MarkKept(ctx context.Context, name string) error {
+	err := l.ResourceDefinitions.Modify(ctx, name, client.GenericPropsModify{
+		OverrideProps: map[string]string{keptProp: "True"},
+	})
+	if err != nil && err != client.NotFoundError {
+		return fmt.Errorf("failed to mark resource '%s' as kept: %w", name, err)
+	}
+
+	return nil
+}
+
+// drbdOptionProps are the properties that store the DRBD options which can
+// be set with Resource.DrbdOptions.
+var drbdOptionProps = map[string]string{
+	common.DrbdOptionProtocol:   apiconsts.NamespcDrbdNetOptions + "/protocol",
+	common.DrbdOptionQuorum:     apiconsts.NamespcDrbdResourceOptions + "/quorum",
+	common.DrbdOptionOnNoQuorum: apiconsts.NamespcDrbdResourceOptions + "/on-no-quorum",
+}
+
+// DrbdOptions returns the DRBD options set on the resource definition, or nil
+// if there are none. This includes the quorum options that are always set
+// when a resource is created. Options that are not set are inherited from the
+// resource group or use the DRBD default.
+func DrbdOptions(definition *client.ResourceDefinition) map[string]string {
+	if definition == nil {
+		return nil
+	}
+
+	var options map[string]string
+	for name, prop := range drbdOptionProps {
+		value, ok := definition.Props[prop]
+		if !ok {
+			continue
+		}
+
+		if options == nil {
+			options = make(map[string]string)
+		}
+		options[name] = value
+	}
+
+	return options
+}
+
+// DrbdPort returns the TCP port DRBD uses for the resource definition, or 0
+// if it is not known.
+func DrbdPort(definition *client.ResourceDefinition) int {
+	if definition == nil {
+		return 0
+	}
+
+	for _, layer := range definition.LayerData {
+		if drbd, ok := layer.Data.(*client.DrbdResourceDefinitionLayer); ok {
+			return int(drbd.Port)
+		}
+	}
+
+	return 0
+}
+
+// DrbdMinor returns the DRBD minor number of the cluster private volume, or 0
+// if it is not known. The other volumes use this minor plus their volume
+// number if it was set when the resource was created.
+func DrbdMinor(volumeDefinitions []client.VolumeDefinition) int {
+	for _, vd := range volumeDefinitions {
+		if vd.VolumeNumber == nil || *vd.VolumeNumber != 0 {
+			continue
+		}
+
+		for _, layer := range vd.LayerData {
+			if drbd, ok := layer.Data.(*client.DrbdVolumeDefinition); ok {
+				return int(drbd.MinorNumber)
+			}
+		}
+	}
+
+	return 0
 }
 
 // CreateResult is a struct than is used as the result of a successful create action.
@@ -43,17 +374,40 @@ func StatusFromResources(serviceCfgPath string, definition *client.ResourceDefin
 	service := common.ServiceStateStopped
 	primary := ""
 	nodes := make([]string, 0, len(resources))
+	disconnected := false
+	drbdPort := 0
+	drbdMinors := make(map[int]int)
 
-	volumeByNumber := make(map[int][]*client.Volume)
+	volumeByNumber := make(map[int][]nodeVolume)
 	for _, nodeRsc := range resources {
 		nodes = append(nodes, nodeRsc.NodeName)
 
+		if port := nodeRsc.LayerObject.Drbd.DrbdResourceDefinition.Port; port > 0 {
+			drbdPort = int(port)
+		}
+
+		for _, drbdVol := range nodeRsc.LayerObject.Drbd.DrbdVolumes {
+			def := drbdVol.DrbdVolumeDefinition
+			if def.MinorNumber > 0 {
+				drbdMinors[int(def.VolumeNumber)] = int(def.MinorNumber)
+			}
+		}
+
 		if nodeRsc.State.InUse != nil && *nodeRsc.State.InUse {
 			primary = nodeRsc.NodeName
 		}
 
+		for _, conn := range nodeRsc.LayerObject.Drbd.Connections {
+			if !conn.Connected {
+				disconnected = true
+			}
+		}
+
 		for _, vol := range nodeRsc.Volumes {
-			volumeByNumber[int(vol.VolumeNumber)] = append(volumeByNumber[int(vol.VolumeNumber)], &vol)
+			volumeByNumber[int(vol.VolumeNumber)] = append(volumeByNumber[int(vol.VolumeNumber)], nodeVolume{
+				node: nodeRsc.NodeName,
+				vol:  vol,
+			})
 		}
 	}
 
@@ -61,21 +415,57 @@ func StatusFromResources(serviceCfgPath string, definition *client.ResourceDefin
 		service = common.ServiceStateStarted
 	}
 
+	if (service == common.ServiceStateStarted) != (primary != "") {
+		service = serviceTransition(service, reactor.ServiceChanged(definition), time.Now())
+	}
+
+	placement := placementFromGroup(group)
+	wantPlaceCount := 0
+	if placement != nil {
+		wantPlaceCount = placement.PlaceCount
+	}
+	if count := PlacementCount(definition); count > 0 {
+		wantPlaceCount = count
+		if placement != nil {
+			placement.PlaceCount = count
+		}
+	}
+	if pool := StoragePool(definition); pool != "" && placement != nil {
+		placement.StoragePools = []string{pool}
+	}
+
 	volumes := make([]common.VolumeState, 0, len(volumeByNumber))
 	for nr, deployedVols := range volumeByNumber {
 		upToDate := 0
 		diskful := 0
-		for _, vol := range deployedVols {
-			if vol.State.DiskState == "UpToDate" {
+		var allocated uint64
+		diskStates := make(map[string]string, len(deployedVols))
+		nodeStates := make([]common.NodeVolumeState, 0, len(deployedVols))
+		for _, nv := range deployedVols {
+			diskStates[nv.node] = nv.vol.State.DiskState
+			nodeState := common.NodeVolumeState{
+				Node:        nv.node,
+				DiskState:   nv.vol.State.DiskState,
+				Primary:     nv.node == primary,
+				StoragePool: nv.vol.StoragePoolName,
+			}
+			if nv.vol.AllocatedSizeKib > 0 {
+				nodeState.AllocatedKiB = uint64(nv.vol.AllocatedSizeKib)
+			}
+			nodeStates = append(nodeStates, nodeState)
+			if nv.vol.AllocatedSizeKib > 0 && uint64(nv.vol.AllocatedSizeKib) > allocated {
+				allocated = uint64(nv.vol.AllocatedSizeKib)
+			}
+			if nv.vol.State.DiskState == "UpToDate" {
 				diskful++
 			}
-			if vol.State.DiskState == "UpToDate" || vol.State.DiskState == "Diskless" {
+			if nv.vol.State.DiskState == "UpToDate" || nv.vol.State.DiskState == "Diskless" {
 				upToDate++
 			}
 		}
 
 		aggregateState := common.ResourceStateBad
-		if upToDate == len(deployedVols) && diskful >= int(group.SelectFilter.PlaceCount) {
+		if upToDate == len(deployedVols) && diskful >= wantPlaceCount {
 			aggregateState = common.ResourceStateOK
 		} else if upToDate > 0 {
 			aggregateState = common.ResourceStateDegraded
@@ -83,13 +473,22 @@ func StatusFromResources(serviceCfgPath string, definition *client.ResourceDefin
 
 		log.WithFields(log.Fields{
 			"resource":       definition.Name,
-			"wantPlaceCount": group.SelectFilter.PlaceCount,
+			"wantPlaceCount": wantPlaceCount,
 			"haveDiskful":    diskful,
 		}).Tracef("deciding aggregateState %s", aggregateState)
 
+		sort.Slice(nodeStates, func(i, j int) bool {
+			return nodeStates[i].Node < nodeStates[j].Node
+		})
+
 		volumes = append(volumes, common.VolumeState{
-			Number: nr,
-			State:  aggregateState,
+			Number:       nr,
+			State:        aggregateState,
+			Sync:         syncState(deployedVols, disconnected),
+			DiskStates:   diskStates,
+			Nodes:        nodeStates,
+			DrbdMinor:    drbdMinors[nr],
+			AllocatedKiB: allocated,
 		})
 
 		if resourceState < aggregateState {
@@ -102,21 +501,453 @@ func StatusFromResources(serviceCfgPath string, definition *client.ResourceDefin
 	})
 
 	return common.ResourceStatus{
-		State:   resourceState,
-		Service: service,
-		Primary: primary,
-		Nodes:   nodes,
-		Volumes: volumes,
+		State:      resourceState,
+		Service:    service,
+		Primary:    primary,
+		Nodes:      nodes,
+		Volumes:    volumes,
+		Placement:  placement,
+		Replicas:   common.DiskfulReplicas(resources),
+		ConfigPath: serviceCfgPath,
+		DrbdPort:   drbdPort,
+	}
+}
+
+// TransitionWindow is how long after the services of a resource were
+// started, stopped or failed over they may take to get there. It matches the
+// longest time Start waits for the services.
+var TransitionWindow = DefaultStartWait.MaxTimeout
+
+// serviceTransition returns the state of a service whose state disagrees
+// with the DRBD role of its resource. drbd-reactor promotes the resource some
+// time after the service is started, and demotes it some time after it is
+// stopped. In between, e.g. during a failover, the service is transitioning.
+// If the service was changed more than TransitionWindow before now, or it is
+// unknown when, a started service failed to start, and a stopped one is
+// reported as stopped, although its resource is still in use.
+func serviceTransition(service common.ServiceState, changed, now time.Time) common.ServiceState {
+	if !changed.IsZero() && now.Sub(changed) < TransitionWindow {
+		return common.ServiceStateTransitioning
+	}
+
+	if service == common.ServiceStateStarted {
+		return common.ServiceStateFailed
+	}
+
+	return service
+}
+
+// placementFromGroup returns the placement policy configured in the given
+// resource group.
+func placementFromGroup(group *client.ResourceGroup) *common.Placement {
+	if group == nil {
+		return nil
+	}
+
+	filter := group.SelectFilter
+	var pools []string
+	if filter.StoragePool != "" {
+		pools = append(pools, filter.StoragePool)
+	}
+	for _, pool := range filter.StoragePoolList {
+		if pool != filter.StoragePool {
+			pools = append(pools, pool)
+		}
 	}
+
+	return &common.Placement{
+		ResourceGroup:        group.Name,
+		PlaceCount:           int(filter.PlaceCount),
+		StoragePools:         pools,
+		DisklessStoragePools: filter.StoragePoolDisklessList,
+		ReplicasOnSame:       filter.ReplicasOnSame,
+		ReplicasOnDifferent:  filter.ReplicasOnDifferent,
+		DisklessOnRemaining:  filter.DisklessOnRemaining,
+	}
+}
+
+type nodeVolume struct {
+	node string
+	vol  client.Volume
 }
 
+var syncProgressRegex = regexp.MustCompile(`^SyncTarget\((\d+(\.\d+)?)%\)$`)
+
+// syncState summarizes the DRBD replication state of a volume.
+//
+// A disconnected peer takes precedence over everything else, as it is the
+// most likely cause for any other problem. The golinstor client does not
+// expose the replication state of a volume, so a resync is detected from the
+// disk states instead: an "Inconsistent" disk on a connected resource is a
+// sync target. The sync progress is only available if LINSTOR reports it as
+// part of the disk state.
+func syncState(vols []nodeVolume, disconnected bool) string {
+	if disconnected {
+		return common.SyncStateDisconnected
+	}
+
+	state := common.SyncStateUpToDate
+	progress := -1.0
+	for _, nv := range vols {
+		diskState := nv.vol.State.DiskState
+		if m := syncProgressRegex.FindStringSubmatch(diskState); m != nil {
+			state = common.SyncStateSyncTarget
+			p, err := strconv.ParseFloat(m[1], 64)
+			// report the progress of the slowest sync target
+			if err == nil && (progress < 0 || p < progress) {
+				progress = p
+			}
+			continue
+		}
+
+		switch diskState {
+		case "UpToDate", "Diskless":
+		case "Inconsistent", common.SyncStateSyncTarget:
+			state = common.SyncStateSyncTarget
+		default:
+			if state == common.SyncStateUpToDate {
+				state = diskState
+			}
+		}
+	}
+
+	if state == common.SyncStateSyncTarget && progress >= 0 {
+		return fmt.Sprintf("%s(%.2f%%)", common.SyncStateSyncTarget, progress)
+	}
+
+	return state
+}
+
+// WaitSynced waits until every volume of the resource name is UpToDate on
+// all of its replicas and the requested number of diskful replicas is
+// deployed, i.e. until StatusFromResources reports all volumes as OK. It
+// returns the resources once they are in sync. A timeout of 0 uses
+// common.DefaultSyncTimeout.
+func (l *Linstor) WaitSynced(ctx context.Context, name string, definition *client.ResourceDefinition, group *client.ResourceGroup, timeout time.Duration) ([]client.ResourceWithVolumes, error) {
+	if timeout == 0 {
+		timeout = common.DefaultSyncTimeout
+	}
+
+	var synced []client.ResourceWithVolumes
+	err := common.WaitUntilResourceCondition(ctx, l.Client, name, common.WaitTimeout{Fixed: timeout}, func(resources []client.ResourceWithVolumes) bool {
+		status := StatusFromResources("", definition, group, resources)
+		if len(status.Volumes) == 0 {
+			return false
+		}
+
+		for _, vol := range status.Volumes {
+			if vol.State != common.ResourceStateOK {
+				return false
+			}
+		}
+
+		synced = resources
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to wait for the replicas to be in sync: %w", err)
+	}
+
+	return synced, nil
+}
+
+// DefaultProbeTimeout is how long Default waits for a LINSTOR controller to
+// respond before giving up. A value of zero disables the probe.
+var DefaultProbeTimeout = 5 * time.Second
+
+// Default creates a LINSTOR client that connects to one of the given
+// controllers. Unless DefaultProbeTimeout is zero, it fails fast if none of
+// them responds. The client should be reused for all requests, and closed
+// with Close once it is no longer needed.
 func Default(controllers []string) (*Linstor, error) {
-	cli, err := client.NewClient(client.Log(log.StandardLogger()), client.Controllers(controllers))
+	controllers = sslControllers(controllers)
+
+	err := checkHTTPSControllers(controllers, DefaultTLS)
 	if err != nil {
 		return nil, err
 	}
 
-	return &Linstor{Client: cli}, nil
+	// If TLS is configured via the environment, golinstor has to build the
+	// HTTP client itself. Otherwise, use a dedicated one instead of the
+	// shared http.DefaultClient, so that its connections can be closed.
+	// DefaultTLS takes precedence over the environment.
+	var httpClient *http.Client
+	switch {
+	case DefaultTLS.enabled():
+		tlsConfig, err := DefaultTLS.load()
+		if err != nil {
+			return nil, err
+		}
+
+		if len(controllers) == 0 && os.Getenv(client.ControllerUrlEnv) != "" {
+			controllers = strings.Split(os.Getenv(client.ControllerUrlEnv), ",")
+		}
+		controllers = httpsControllers(controllers)
+
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = tlsConfig
+		httpClient = &http.Client{Transport: transport}
+	case !tlsFromEnv():
+		httpClient = &http.Client{Transport: http.DefaultTransport.(*http.Transport).Clone()}
+	}
+
+	opts := []client.Option{client.Log(log.StandardLogger())}
+	if base := ipv6BaseURL(controllers); base != nil {
+		opts = append(opts, client.BaseURL(base))
+	} else {
+		opts = append(opts, client.Controllers(controllers))
+	}
+	if httpClient != nil {
+		opts = append(opts, client.HTTPClient(httpClient))
+	}
+
+	cli, err := client.NewClient(opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &Linstor{Client: cli, Retry: DefaultRetryConfig, httpClient: httpClient}
+
+	if DefaultProbeTimeout > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), DefaultProbeTimeout)
+		defer cancel()
+
+		_, err = l.Controller.GetVersion(ctx)
+		if err != nil {
+			l.Close()
+			return nil, fmt.Errorf("cannot reach LINSTOR controller at %s: %w", describeControllers(controllers), err)
+		}
+	}
+
+	return l, nil
+}
+
+// describeControllers formats the list of controllers for error messages.
+func describeControllers(controllers []string) string {
+	if len(controllers) == 0 {
+		return "localhost:3370"
+	}
+	return strings.Join(controllers, ", ")
+}
+
+// tlsFromEnv reports whether any of the environment variables that make
+// golinstor set up client certificates or custom CAs is present.
+func tlsFromEnv() bool {
+	for _, env := range []string{client.UserCertEnv, client.UserKeyEnv, client.RootCAEnv} {
+		if _, ok := os.LookupEnv(env); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Close releases the idle connections to the LINSTOR controller. The client
+// can still be used afterwards, but has to connect again.
+func (l *Linstor) Close() {
+	if l.httpClient != nil {
+		l.httpClient.CloseIdleConnections()
+	}
+}
+
+// ValidateControllers checks that every entry in controllers is a valid
+// LINSTOR controller address of the form "[scheme://]host[:port]", where
+// scheme is one of "http", "https", "linstor" or "linstor+ssl". IPv6
+// addresses must be put in brackets if a port is given, and are only
+// supported with a single controller.
+func ValidateControllers(controllers []string) error {
+	for _, c := range controllers {
+		_, host, _, err := parseController(c)
+		if err != nil {
+			return fmt.Errorf("invalid LINSTOR controller %q: %w", c, err)
+		}
+
+		if strings.Contains(host, ":") && len(controllers) > 1 {
+			return fmt.Errorf("invalid LINSTOR controller %q: IPv6 addresses are only supported with a single controller", c)
+		}
+	}
+
+	return nil
+}
+
+// parseController splits a LINSTOR controller address of the form
+// "[scheme://]host[:port]" into its parts. The scheme and port are empty if
+// they are not given. An IPv6 host is returned without brackets.
+func parseController(c string) (string, string, string, error) {
+	scheme, endpoint := "", c
+	if before, after, found := strings.Cut(c, "://"); found {
+		scheme, endpoint = before, after
+		switch scheme {
+		case "http", "https", "linstor", "linstor+ssl":
+		default:
+			return "", "", "", fmt.Errorf("unsupported scheme %q", scheme)
+		}
+
+		if strings.Contains(endpoint, "://") {
+			return "", "", "", errors.New("multiple scheme separators")
+		}
+	}
+
+	host, port, err := net.SplitHostPort(endpoint)
+	if err == nil {
+		if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+			return "", "", "", fmt.Errorf("invalid port %q", port)
+		}
+	} else {
+		// no port, possibly a bare or bracketed IPv6 address
+		host, port = endpoint, ""
+		if strings.HasPrefix(host, "[") && strings.HasSuffix(host, "]") {
+			host = host[1 : len(host)-1]
+		}
+	}
+
+	if host == "" || strings.ContainsAny(host, "/?#[]@ ") || (strings.Contains(host, ":") && net.ParseIP(host) == nil) {
+		return "", "", "", fmt.Errorf("invalid host %q", host)
+	}
+
+	return scheme, host, port, nil
+}
+
+// ipv6BaseURL returns the URL of the controller if controllers is a single
+// IPv6 address, or nil otherwise. golinstor can not parse IPv6 addresses, so
+// they are passed as base URL instead.
+func ipv6BaseURL(controllers []string) *url.URL {
+	if len(controllers) != 1 {
+		return nil
+	}
+
+	scheme, host, port, err := parseController(controllers[0])
+	if err != nil || !strings.Contains(host, ":") {
+		return nil
+	}
+
+	if scheme == "" || scheme == "linstor" {
+		scheme = "http"
+		if tlsFromEnv() {
+			scheme = "https"
+		}
+	}
+
+	if port == "" {
+		port = "3370"
+		if scheme == "https" {
+			port = "3371"
+		}
+	}
+
+	return &url.URL{Scheme: scheme, Host: net.JoinHostPort(host, port)}
+}
+
+// CheckConnection verifies that at least one of the configured LINSTOR
+// controllers is reachable.
+func (l *Linstor) CheckConnection(ctx context.Context) error {
+	_, err := l.Controller.GetVersion(ctx)
+	if err != nil {
+		return fmt.Errorf("cannot reach any LINSTOR controller: %w", err)
+	}
+
+	return nil
+}
+
+// CheckResourceGroup verifies that the resource group with the given name
+// exists. If it does not, the returned error lists the available groups.
+func (l *Linstor) CheckResourceGroup(ctx context.Context, name string) error {
+	err := l.retry(ctx, func() error {
+		_, err := l.ResourceGroups.Get(ctx, name)
+		return err
+	})
+	if err == nil {
+		return nil
+	}
+	if err != client.NotFoundError {
+		return fmt.Errorf("failed to get resource group '%s': %w", name, err)
+	}
+
+	var groups []client.ResourceGroup
+	err = l.retry(ctx, func() error {
+		groups, err = l.ResourceGroups.GetAll(ctx)
+		return err
+	})
+	if err != nil || len(groups) == 0 {
+		return fmt.Errorf("resource group '%s' does not exist", name)
+	}
+
+	names := make([]string, len(groups))
+	for i := range groups {
+		names[i] = groups[i].Name
+	}
+	sort.Strings(names)
+
+	return fmt.Errorf("resource group '%s' does not exist (available: %s)", name, strings.Join(names, ", "))
+}
+
+// CheckCapacity verifies that the storage pools of the resource group have
+// enough free space for the given volumes. placementCount and layerList
+// override the ones of the resource group if they are set.
+//
+// LINSTOR reports the free space of thinly provisioned pools without taking
+// overprovisioning into account, so the check may reject resources that would
+// fit into such pools.
+func (l *Linstor) CheckCapacity(ctx context.Context, resourceGroup string, placementCount int, layerList []string, storagePool string, volumes []common.VolumeConfig) error {
+	var group client.ResourceGroup
+	err := l.retry(ctx, func() error {
+		var err error
+		group, err = l.ResourceGroups.Get(ctx, resourceGroup)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get resource group '%s': %w", resourceGroup, err)
+	}
+
+	filter := group.SelectFilter
+	if placementCount > 0 {
+		filter.PlaceCount = int32(placementCount)
+	}
+	if len(layerList) > 0 {
+		filter.LayerStack = layerList
+	}
+	if storagePool != "" {
+		filter.StoragePool = storagePool
+		filter.StoragePoolList = []string{storagePool}
+	}
+
+	var sizes client.MaxVolumeSizes
+	err = l.retry(ctx, func() error {
+		var err error
+		sizes, err = l.Resources.QueryMaxVolumeSize(ctx, filter)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to query free space of resource group '%s': %w", resourceGroup, err)
+	}
+
+	return checkCapacity(volumes, sizes.Candidates)
+}
+
+// checkCapacity verifies that the largest of the candidate storage pools can
+// hold all volumes, since all volumes of a resource are placed in the same
+// storage pool.
+func checkCapacity(volumes []common.VolumeConfig, candidates []client.Candidate) error {
+	var requested uint64
+	for _, vol := range volumes {
+		requested += vol.SizeKiB
+	}
+
+	var free uint64
+	for _, candidate := range candidates {
+		if candidate.MaxVolumeSizeKib > 0 && uint64(candidate.MaxVolumeSizeKib) > free {
+			free = uint64(candidate.MaxVolumeSizeKib)
+		}
+	}
+
+	if free == 0 {
+		return fmt.Errorf("requested %s but no pool has free space", common.FormatSize(requested))
+	}
+
+	if requested > free {
+		return fmt.Errorf("requested %s but pool has %s free", common.FormatSize(requested), common.FormatSize(free))
+	}
+
+	return nil
 }
 
 // EnsureResource creates or updates the given resource.
@@ -126,22 +957,68 @@ func Default(controllers []string) (*Linstor, error) {
 //   definition on the respective nodes
 // - An error if one occurred, or nil
 func (l *Linstor) EnsureResource(ctx context.Context, res Resource, mayExist bool) (*client.ResourceDefinition, *client.ResourceGroup, []client.ResourceWithVolumes, error) {
+	ctx, end := observe.Step(ctx, "linstorcontrol.EnsureResource")
+	rd, rg, resources, err := l.ensureResource(ctx, res, mayExist)
+	end(err)
+	return rd, rg, resources, err
+}
+
+// cleanupTimeout limits how long cleaning up after a failed operation may
+// take. Cleanup also runs if the operation was cancelled.
+var cleanupTimeout = 30 * time.Second
+
+// deleteVolumeDefinitions deletes the given volume definitions of a resource
+// definition, in reverse order. Errors are only logged, since it is used to
+// clean up after another error.
+func (l *Linstor) deleteVolumeDefinitions(resource string, volumes []int) {
+	ctx, cancel := context.WithTimeout(context.Background(), cleanupTimeout)
+	defer cancel()
+
+	for i := len(volumes) - 1; i >= 0; i-- {
+		logger := log.WithFields(log.Fields{"resource": resource, "volNr": volumes[i]})
+		logger.Trace("delete partially created volume definition")
+
+		err := l.retry(ctx, func() error {
+			return l.ResourceDefinitions.DeleteVolumeDefinition(ctx, resource, volumes[i])
+		})
+		if err != nil && err != client.NotFoundError {
+			logger.WithError(err).Warn("failed to delete partially created volume definition")
+		}
+	}
+}
+
+func (l *Linstor) ensureResource(ctx context.Context, res Resource, mayExist bool) (*client.ResourceDefinition, *client.ResourceGroup, []client.ResourceWithVolumes, error) {
 	logger := log.WithField("resource", res.Name)
 
 	logger.Trace("ensure resource group exists")
 
-	err := l.ResourceGroups.Create(ctx, client.ResourceGroup{
-		Name: res.ResourceGroup,
+	err := l.retry(ctx, func() error {
+		return l.ResourceGroups.Create(ctx, client.ResourceGroup{
+			Name: res.ResourceGroup,
+		})
 	})
 	if err != nil && !isErrAlreadyExists(err) {
 		return nil, nil, nil, fmt.Errorf("failed to create resource group: %w", err)
 	}
 
-	rgroup, err := l.ResourceGroups.Get(ctx, res.ResourceGroup)
+	var rgroup client.ResourceGroup
+	err = l.retry(ctx, func() error {
+		rgroup, err = l.ResourceGroups.Get(ctx, res.ResourceGroup)
+		return err
+	})
 	if err != nil {
 		return nil, nil, nil, fmt.Errorf("failed to get resource group: %w", err)
 	}
 
+	if res.StoragePool != "" {
+		logger.Trace("ensure storage pool exists")
+
+		err = l.checkStoragePool(ctx, res.StoragePool)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+	}
+
 	logger.Trace("ensure resource definition exists")
 
 	props := map[string]string{}
@@ -157,60 +1034,185 @@ func (l *Linstor) EnsureResource(ctx context.Context, res Resource, mayExist boo
 		props[apiconsts.NamespcDrbdResourceOptions+"/auto-promote"] = "no"
 	}
 
-	props[apiconsts.NamespcDrbdResourceOptions+"/quorum"] = "majority"
-	props[apiconsts.NamespcDrbdResourceOptions+"/on-no-quorum"] = "io-error"
+	for name, value := range common.DefaultDrbdOptions {
+		props[drbdOptionProps[name]] = value
+	}
+
+	for name, value := range res.DrbdOptions {
+		props[drbdOptionProps[name]] = value
+	}
+
+	if res.PlacementCount > 0 {
+		props[placementCountProp] = strconv.Itoa(res.PlacementCount)
+	}
+
+	if res.StoragePool != "" {
+		props[storagePoolProp] = res.StoragePool
+	}
+
+	if len(res.LayerList) > 0 {
+		props[layerListProp] = strings.Join(res.LayerList, ",")
+
+		if common.WithoutDRBD(res.LayerList) {
+			logger.WithField("layers", res.LayerList).Warn("layer list does not contain DRBD, the resource will not be highly available")
+		}
+	}
+
+	for k, v := range res.Labels {
+		props[labelPropPrefix+k] = v
+	}
+
+	if res.Description != "" {
+		props[descriptionProp] = res.Description
+	}
+
+	var limitProps map[string]string
+	var removeLimitProps []string
+	if res.IOLimits != nil {
+		limitProps, removeLimitProps = res.IOLimits.props()
+		for k, v := range limitProps {
+			props[k] = v
+		}
+	}
+
+	token := uuid.NewString()
+	props[createTokenProp] = token
 
-	err = l.ResourceDefinitions.Create(ctx, client.ResourceDefinitionCreate{
-		ResourceDefinition: client.ResourceDefinition{
-			Name:              res.Name,
-			ResourceGroupName: res.ResourceGroup,
-			Props:             props,
-		},
+	attempt := 0
+	err = l.retry(ctx, func() error {
+		attempt++
+		err := l.ResourceDefinitions.Create(ctx, client.ResourceDefinitionCreate{
+			DrbdPort: int32(res.DrbdPort),
+			ResourceDefinition: client.ResourceDefinition{
+				Name:              res.Name,
+				ResourceGroupName: res.ResourceGroup,
+				Props:             props,
+			},
+		})
+		// Creating is not idempotent: if the response to an earlier attempt
+		// was lost, LINSTOR may already have created the resource definition.
+		if attempt > 1 && isErrAlreadyExists(err) && l.createdResourceDefinition(ctx, res.Name, token) {
+			return nil
+		}
+		return err
 	})
 	if err != nil {
 		if (!mayExist && isErrAlreadyExists(err)) || !isErrAlreadyExists(err) {
 			return nil, nil, nil, fmt.Errorf("failed to create resource definition: %w", err)
 		}
+
+		if res.IOLimits != nil {
+			logger.Trace("update IO limits of existing resource definition")
+
+			err = l.retry(ctx, func() error {
+				return l.ResourceDefinitions.Modify(ctx, res.Name, client.GenericPropsModify{
+					OverrideProps: limitProps,
+					DeleteProps:   removeLimitProps,
+				})
+			})
+			if err != nil {
+				return nil, nil, nil, fmt.Errorf("failed to update IO limits of resource definition '%s': %w", res.Name, err)
+			}
+		}
+	}
+
+	// Volume definitions that existed before are not deleted if a later
+	// one fails, as they may hold data.
+	var volumeDefinitions []client.VolumeDefinition
+	err = l.retry(ctx, func() error {
+		volumeDefinitions, err = l.ResourceDefinitions.GetVolumeDefinitions(ctx, res.Name)
+		return err
+	})
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to get volume definitions of resource definition '%s': %w", res.Name, err)
+	}
+
+	existingVolumes := map[int]bool{}
+	for _, vd := range volumeDefinitions {
+		if vd.VolumeNumber != nil {
+			existingVolumes[int(*vd.VolumeNumber)] = true
+		}
 	}
 
+	// LINSTOR can not create several volume definitions at once, so the
+	// ones created here are deleted again if a later one fails.
+	var createdVolumes []int
 	for _, vol := range res.Volumes {
 		logger.WithField("volNr", vol.Number).Trace("ensure volume definition exists")
 
-		volProps := map[string]string{}
+		volProps := common.ExportedProps(vol.IsExported())
 		if vol.FileSystem != "" {
 			volProps[apiconsts.NamespcFilesystem+"/Type"] = vol.FileSystem
-			volProps[apiconsts.NamespcFilesystem+"/MkfsParams"] = "-E root_owner=" + vol.FileSystemRootOwner.String()
+			// root_owner is an ext4 specific option
+			if vol.FileSystem == "ext4" {
+				volProps[apiconsts.NamespcFilesystem+"/MkfsParams"] = "-E root_owner=" + vol.FileSystemRootOwner.String()
+			}
 		}
 		var volFlags []string
 		if res.GrossSize {
 			volFlags = append(volFlags, "GROSS_SIZE")
 		}
-		err := l.ResourceDefinitions.CreateVolumeDefinition(ctx, res.Name, client.VolumeDefinitionCreate{
-			VolumeDefinition: client.VolumeDefinition{
-				VolumeNumber: gog.Ptr(int32(vol.Number)),
-				SizeKib:      vol.SizeKiB,
-				Props:        volProps,
-				Flags:        volFlags,
-			},
+		var minor int32
+		if res.DrbdMinor > 0 {
+			minor = int32(res.DrbdMinor + vol.Number)
+		}
+		err := l.retry(ctx, func() error {
+			return l.ResourceDefinitions.CreateVolumeDefinition(ctx, res.Name, client.VolumeDefinitionCreate{
+				DrbdMinorNumber: minor,
+				VolumeDefinition: client.VolumeDefinition{
+					VolumeNumber: gog.Ptr(int32(vol.Number)),
+					SizeKib:      vol.SizeKiB,
+					Props:        volProps,
+					Flags:        volFlags,
+				},
+			})
 		})
 		if err != nil && !isErrAlreadyExists(err) {
+			l.deleteVolumeDefinitions(res.Name, createdVolumes)
 			return nil, nil, nil, fmt.Errorf("failed to ensure volume definition: %w", err)
 		}
+		// This includes a volume definition created by an attempt whose
+		// response got lost.
+		if !existingVolumes[vol.Number] {
+			createdVolumes = append(createdVolumes, vol.Number)
+		}
 	}
 
 	logger.Trace("ensure resource is placed")
 
-	err = l.Resources.Autoplace(ctx, res.Name, client.AutoPlaceRequest{})
+	// LINSTOR merges the filter with the one of the resource group, so only
+	// the placement count, storage pool and layer list are overridden.
+	filter := client.AutoSelectFilter{PlaceCount: int32(res.PlacementCount)}
+	if res.StoragePool != "" {
+		filter.StoragePool = res.StoragePool
+		filter.StoragePoolList = []string{res.StoragePool}
+	}
+
+	err = l.retry(ctx, func() error {
+		return l.Resources.Autoplace(ctx, res.Name, client.AutoPlaceRequest{
+			SelectFilter: filter,
+			LayerList:    layerKinds(res.LayerList),
+		})
+	})
+	if isErrNoStoragePool(err) {
+		placeCount := res.PlacementCount
+		if placeCount == 0 {
+			placeCount = int(rgroup.SelectFilter.PlaceCount)
+		}
+		return nil, nil, nil, noStoragePoolError(res.ResourceGroup, res.StoragePool, placeCount, err)
+	}
 	if err != nil {
 		return nil, nil, nil, fmt.Errorf("failed to autoplace resources: %w", err)
 	}
 
 	// XXX: remove this when LINSTOR supports this (see comment above).
 	if res.FileSystem != "" {
-		err = l.ResourceDefinitions.Modify(ctx, res.Name, client.GenericPropsModify{
-			OverrideProps: map[string]string{
-				apiconsts.NamespcDrbdResourceOptions + "/auto-promote": "no",
-			},
+		err = l.retry(ctx, func() error {
+			return l.ResourceDefinitions.Modify(ctx, res.Name, client.GenericPropsModify{
+				OverrideProps: map[string]string{
+					apiconsts.NamespcDrbdResourceOptions + "/auto-promote": "no",
+				},
+			})
 		})
 		if err != nil {
 			return nil, nil, nil, fmt.Errorf("failed to update properties of resource definition '%s': %w", res.Name, err)
@@ -219,14 +1221,22 @@ func (l *Linstor) EnsureResource(ctx context.Context, res Resource, mayExist boo
 
 	logger.Trace("fetch existing resource definition")
 
-	rdef, err := l.ResourceDefinitions.Get(ctx, res.Name)
+	var rdef client.ResourceDefinition
+	err = l.retry(ctx, func() error {
+		rdef, err = l.ResourceDefinitions.Get(ctx, res.Name)
+		return err
+	})
 	if err != nil {
 		return nil, nil, nil, fmt.Errorf("failed to fetch existing resource definition: %w", err)
 	}
 
 	logger.Trace("fetch existing resources")
 
-	view, err := l.Resources.GetResourceView(ctx, &client.ListOpts{Resource: []string{res.Name}})
+	var view []client.ResourceWithVolumes
+	err = l.retry(ctx, func() error {
+		view, err = l.Resources.GetResourceView(ctx, &client.ListOpts{Resource: []string{res.Name}})
+		return err
+	})
 	if err != nil {
 		return nil, nil, nil, fmt.Errorf("failed to fetch resource view: %w", err)
 	}
@@ -246,7 +1256,9 @@ func (l *Linstor) EnsureResource(ctx context.Context, res Resource, mayExist boo
 			}
 		}
 		if !expected {
-			err := l.ResourceDefinitions.DeleteVolumeDefinition(ctx, res.Name, int(existingVol.VolumeNumber))
+			err := l.retry(ctx, func() error {
+				return l.ResourceDefinitions.DeleteVolumeDefinition(ctx, res.Name, int(existingVol.VolumeNumber))
+			})
 			if err != nil {
 				return nil, nil, nil, fmt.Errorf("failed to delete unexpected volume definition: %w", err)
 			}
@@ -256,6 +1268,86 @@ func (l *Linstor) EnsureResource(ctx context.Context, res Resource, mayExist boo
 	return &rdef, &rgroup, view, nil
 }
 
+// checkStoragePool returns an error if no node has a storage pool with the
+// given name.
+func (l *Linstor) checkStoragePool(ctx context.Context, name string) error {
+	var pools []client.StoragePool
+	err := l.retry(ctx, func() error {
+		var err error
+		pools, err = l.Nodes.GetStoragePoolView(ctx, &client.ListOpts{StoragePool: []string{name}})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list storage pools: %w", err)
+	}
+
+	for _, pool := range pools {
+		if pool.StoragePoolName == name {
+			return nil
+		}
+	}
+
+	return common.ValidationError(fmt.Sprintf("storage pool '%s' does not exist on any node", name))
+}
+
+// isErrNoStoragePool reports whether LINSTOR could not place a resource
+// because there are not enough usable storage pools.
+func isErrNoStoragePool(err error) bool {
+	apiErr, ok := err.(client.ApiCallError)
+	if !ok {
+		return false
+	}
+
+	possibleErrs := []uint64{
+		apiconsts.FailNotEnoughNodes,
+		apiconsts.FailNotFoundStorPool,
+		apiconsts.FailNotFoundStorPoolDfn,
+		apiconsts.FailNotFoundDfltStorPool,
+	}
+
+	for _, e := range possibleErrs {
+		if apiErr.Is(e) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// noStoragePoolError explains why a resource of the given resource group
+// could not be placed, and how to fix it. storagePool is the storage pool
+// that overrides the ones of the resource group, if any. cause is the error
+// returned by LINSTOR.
+func noStoragePoolError(resourceGroup, storagePool string, placeCount int, cause error) error {
+	replicas := "the requested replicas"
+	if placeCount > 0 {
+		replicas = fmt.Sprintf("%d replicas", placeCount)
+	}
+
+	where := fmt.Sprintf("in resource group '%s'", resourceGroup)
+	pools := "the storage pools of the resource group exist"
+	if storagePool != "" {
+		where = fmt.Sprintf("in storage pool '%s'", storagePool)
+		pools = fmt.Sprintf("the storage pool '%s' exists", storagePool)
+	}
+
+	return fmt.Errorf("%w: LINSTOR could not find storage pools for %s %s. "+
+		"Check with \"linstor storage-pool list\" that %s on enough online nodes, "+
+		"and that the placement count is not larger than the number of those nodes (LINSTOR error: %v)",
+		common.ErrNoStoragePool, replicas, where, pools, cause)
+}
+
+// createdResourceDefinition reports whether the resource definition name
+// exists and carries our create token, i.e. whether it was created by an
+// earlier attempt of ours.
+func (l *Linstor) createdResourceDefinition(ctx context.Context, name, token string) bool {
+	rdef, err := l.ResourceDefinitions.Get(ctx, name)
+	if err != nil {
+		return false
+	}
+	return rdef.Props[createTokenProp] == token
+}
+
 func isErrAlreadyExists(err error) bool {
 	if err == nil {
 		return false
@@ -0,0 +1,634 @@
+package linstorcontrol
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/LINBIT/golinstor/client"
+	"github.com/LINBIT/golinstor/devicelayerkind"
+	"github.com/icza/gog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/LINBIT/linstor-gateway/pkg/common"
+	"github.com/LINBIT/linstor-gateway/pkg/reactor"
+)
+
+func TestSyncState(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name         string
+		diskStates   []string
+		disconnected bool
+		expected     string
+	}{{
+		name:       "up to date",
+		diskStates: []string{"UpToDate", "UpToDate", "Diskless"},
+		expected:   common.SyncStateUpToDate,
+	}, {
+		name:         "disconnected",
+		diskStates:   []string{"UpToDate", "Inconsistent"},
+		disconnected: true,
+		expected:     common.SyncStateDisconnected,
+	}, {
+		name:       "resync without progress",
+		diskStates: []string{"UpToDate", "Inconsistent"},
+		expected:   common.SyncStateSyncTarget,
+	}, {
+		name:       "resync with progress",
+		diskStates: []string{"UpToDate", "SyncTarget(45.5%)", "SyncTarget(12.25%)"},
+		expected:   "SyncTarget(12.25%)",
+	}, {
+		name:       "outdated",
+		diskStates: []string{"UpToDate", "Outdated"},
+		expected:   "Outdated",
+	}}
+
+	for i := range cases {
+		tcase := &cases[i]
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+			var vols []nodeVolume
+			for _, state := range tcase.diskStates {
+				vols = append(vols, nodeVolume{vol: client.Volume{State: client.VolumeState{DiskState: state}}})
+			}
+
+			assert.Equal(t, tcase.expected, syncState(vols, tcase.disconnected))
+		})
+	}
+}
+
+func TestPlacementFromGroup(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name     string
+		group    *client.ResourceGroup
+		expected *common.Placement
+	}{{
+		name: "unknown group",
+	}, {
+		name:     "defaults",
+		group:    &client.ResourceGroup{Name: "DfltRscGrp"},
+		expected: &common.Placement{ResourceGroup: "DfltRscGrp"},
+	}, {
+		name: "storage pools",
+		group: &client.ResourceGroup{
+			Name: "rg",
+			SelectFilter: client.AutoSelectFilter{
+				PlaceCount:              2,
+				StoragePool:             "pool1",
+				StoragePoolList:         []string{"pool1", "pool2"},
+				StoragePoolDisklessList: []string{"diskless"},
+				ReplicasOnDifferent:     []string{"Aux/site"},
+				DisklessOnRemaining:     true,
+			},
+		},
+		expected: &common.Placement{
+			ResourceGroup:        "rg",
+			PlaceCount:           2,
+			StoragePools:         []string{"pool1", "pool2"},
+			DisklessStoragePools: []string{"diskless"},
+			ReplicasOnDifferent:  []string{"Aux/site"},
+			DisklessOnRemaining:  true,
+		},
+	}}
+
+	for i := range cases {
+		tcase := &cases[i]
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tcase.expected, placementFromGroup(tcase.group))
+		})
+	}
+}
+
+func TestStatusFromResources_PlacementCount(t *testing.T) {
+	t.Parallel()
+	group := &client.ResourceGroup{
+		Name:         "rg",
+		SelectFilter: client.AutoSelectFilter{PlaceCount: 3},
+	}
+	resources := []client.ResourceWithVolumes{
+		{Resource: client.Resource{NodeName: "node1", State: &client.ResourceState{}}, Volumes: []client.Volume{{VolumeNumber: 1, State: client.VolumeState{DiskState: "UpToDate"}}}},
+		{Resource: client.Resource{NodeName: "node2", State: &client.ResourceState{}}, Volumes: []client.Volume{{VolumeNumber: 1, State: client.VolumeState{DiskState: "UpToDate"}}}},
+	}
+
+	cases := []struct {
+		name               string
+		props              map[string]string
+		expectedState      common.ResourceState
+		expectedPlaceCount int
+	}{{
+		name:               "group placement count",
+		expectedState:      common.ResourceStateDegraded,
+		expectedPlaceCount: 3,
+	}, {
+		name:               "override",
+		props:              map[string]string{placementCountProp: "2"},
+		expectedState:      common.ResourceStateOK,
+		expectedPlaceCount: 2,
+	}, {
+		name:               "invalid override",
+		props:              map[string]string{placementCountProp: "two"},
+		expectedState:      common.ResourceStateDegraded,
+		expectedPlaceCount: 3,
+	}}
+
+	for i := range cases {
+		tcase := &cases[i]
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+			definition := &client.ResourceDefinition{Name: "rsc", Props: tcase.props}
+			status := StatusFromResources("/etc/drbd-reactor.d/rsc.toml", definition, group, resources)
+			assert.Equal(t, "/etc/drbd-reactor.d/rsc.toml", status.ConfigPath)
+			assert.Equal(t, tcase.expectedState, status.State)
+			assert.Equal(t, tcase.expectedPlaceCount, status.Placement.PlaceCount)
+			assert.Equal(t, 2, status.Replicas)
+		})
+	}
+}
+
+func TestStatusFromResources_DrbdNumbers(t *testing.T) {
+	t.Parallel()
+	drbdLayer := client.ResourceLayer{Drbd: client.DrbdResource{
+		DrbdResourceDefinition: client.DrbdResourceDefinitionLayer{Port: 7010},
+		DrbdVolumes: []client.DrbdVolume{
+			{DrbdVolumeDefinition: client.DrbdVolumeDefinition{VolumeNumber: 0, MinorNumber: 1010}},
+			{DrbdVolumeDefinition: client.DrbdVolumeDefinition{VolumeNumber: 1, MinorNumber: 1011}},
+		},
+	}}
+
+	cases := []struct {
+		name           string
+		layer          client.ResourceLayer
+		expectedPort   int
+		expectedMinors []int
+	}{{
+		name:           "drbd layer",
+		layer:          drbdLayer,
+		expectedPort:   7010,
+		expectedMinors: []int{1010, 1011},
+	}, {
+		name:           "no drbd layer",
+		expectedMinors: []int{0, 0},
+	}}
+
+	for i := range cases {
+		tcase := &cases[i]
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+			resources := []client.ResourceWithVolumes{{
+				Resource: client.Resource{NodeName: "node1", State: &client.ResourceState{}, LayerObject: tcase.layer},
+				Volumes:  []client.Volume{{VolumeNumber: 0}, {VolumeNumber: 1}},
+			}}
+			status := StatusFromResources("", &client.ResourceDefinition{Name: "rsc"}, &client.ResourceGroup{Name: "rg"}, resources)
+			assert.Equal(t, tcase.expectedPort, status.DrbdPort)
+			var minors []int
+			for _, vol := range status.Volumes {
+				minors = append(minors, vol.DrbdMinor)
+			}
+			assert.Equal(t, tcase.expectedMinors, minors)
+		})
+	}
+}
+
+func TestStatusFromResources_Allocated(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name     string
+		volumes  [][]client.Volume
+		expected []uint64
+	}{{
+		name: "largest replica",
+		volumes: [][]client.Volume{
+			{{VolumeNumber: 0, AllocatedSizeKib: 4096}, {VolumeNumber: 1, AllocatedSizeKib: 1024}},
+			{{VolumeNumber: 0, AllocatedSizeKib: 4096}, {VolumeNumber: 1, AllocatedSizeKib: 2048}},
+		},
+		expected: []uint64{4096, 2048},
+	}, {
+		name: "diskless replica",
+		volumes: [][]client.Volume{
+			{{VolumeNumber: 0, AllocatedSizeKib: 4096}, {VolumeNumber: 1, AllocatedSizeKib: 1024}},
+			{{VolumeNumber: 0}, {VolumeNumber: 1}},
+		},
+		expected: []uint64{4096, 1024},
+	}, {
+		name: "not reported",
+		volumes: [][]client.Volume{
+			{{VolumeNumber: 0, AllocatedSizeKib: -1}, {VolumeNumber: 1}},
+		},
+		expected: []uint64{0, 0},
+	}}
+
+	for i := range cases {
+		tcase := &cases[i]
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+			var resources []client.ResourceWithVolumes
+			for j, vols := range tcase.volumes {
+				resources = append(resources, client.ResourceWithVolumes{
+					Resource: client.Resource{NodeName: fmt.Sprintf("node%d", j), State: &client.ResourceState{}},
+					Volumes:  vols,
+				})
+			}
+			status := StatusFromResources("", &client.ResourceDefinition{Name: "rsc"}, &client.ResourceGroup{Name: "rg"}, resources)
+			var allocated []uint64
+			for _, vol := range status.Volumes {
+				allocated = append(allocated, vol.AllocatedKiB)
+			}
+			assert.Equal(t, tcase.expected, allocated)
+		})
+	}
+}
+
+func TestStatusFromResources_Service(t *testing.T) {
+	t.Parallel()
+	const path = "/etc/drbd-reactor.d/rsc.toml"
+	recently := time.Now().Add(-10 * time.Second).UTC().Format(time.RFC3339)
+	longAgo := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+
+	cases := []struct {
+		name            string
+		props           map[string]string
+		inUse           bool
+		expectedService common.ServiceState
+		expectedPrimary string
+	}{{
+		name:            "started",
+		props:           map[string]string{"files" + path: "True"},
+		inUse:           true,
+		expectedService: common.ServiceStateStarted,
+		expectedPrimary: "node1",
+	}, {
+		name:            "stopped",
+		expectedService: common.ServiceStateStopped,
+	}, {
+		name:            "promoting",
+		props:           map[string]string{"files" + path: "True", reactor.ServiceChangedProp: recently},
+		expectedService: common.ServiceStateTransitioning,
+	}, {
+		name:            "demoting",
+		props:           map[string]string{reactor.ServiceChangedProp: recently},
+		inUse:           true,
+		expectedService: common.ServiceStateTransitioning,
+		expectedPrimary: "node1",
+	}, {
+		name:            "failed start",
+		props:           map[string]string{"files" + path: "True", reactor.ServiceChangedProp: longAgo},
+		expectedService: common.ServiceStateFailed,
+	}, {
+		name:            "failed start at an unknown time",
+		props:           map[string]string{"files" + path: "True"},
+		expectedService: common.ServiceStateFailed,
+	}, {
+		name:            "stopped, but still in use",
+		props:           map[string]string{reactor.ServiceChangedProp: longAgo},
+		inUse:           true,
+		expectedService: common.ServiceStateStopped,
+		expectedPrimary: "node1",
+	}}
+
+	for i := range cases {
+		tcase := &cases[i]
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+			inUse := tcase.inUse
+			resources := []client.ResourceWithVolumes{
+				{Resource: client.Resource{NodeName: "node1", State: &client.ResourceState{InUse: &inUse}}},
+				{Resource: client.Resource{NodeName: "node2", State: &client.ResourceState{}}},
+			}
+			status := StatusFromResources(path, &client.ResourceDefinition{Name: "rsc", Props: tcase.props}, nil, resources)
+			assert.Equal(t, tcase.expectedService, status.Service)
+			assert.Equal(t, tcase.expectedPrimary, status.Primary)
+		})
+	}
+}
+
+func TestStatusFromResources_Nodes(t *testing.T) {
+	t.Parallel()
+	inUse := true
+	resources := []client.ResourceWithVolumes{
+		{
+			Resource: client.Resource{NodeName: "node2", State: &client.ResourceState{}},
+			Volumes:  []client.Volume{{VolumeNumber: 1, StoragePoolName: "DfltDisklessStorPool", State: client.VolumeState{DiskState: "Diskless"}}},
+		},
+		{
+			Resource: client.Resource{NodeName: "node1", State: &client.ResourceState{InUse: &inUse}},
+			Volumes:  []client.Volume{{VolumeNumber: 1, StoragePoolName: "thin", AllocatedSizeKib: 1024, State: client.VolumeState{DiskState: "UpToDate"}}},
+		},
+		{
+			Resource: client.Resource{NodeName: "node3", State: &client.ResourceState{}},
+			Volumes:  []client.Volume{{VolumeNumber: 1, StoragePoolName: "thin", AllocatedSizeKib: -1, State: client.VolumeState{DiskState: "Outdated"}}},
+		},
+	}
+
+	status := StatusFromResources("", &client.ResourceDefinition{Name: "rsc"}, nil, resources)
+	assert.Len(t, status.Volumes, 1)
+	assert.Equal(t, []common.NodeVolumeState{
+		{Node: "node1", DiskState: "UpToDate", Primary: true, StoragePool: "thin", AllocatedKiB: 1024},
+		{Node: "node2", DiskState: "Diskless", StoragePool: "DfltDisklessStorPool"},
+		{Node: "node3", DiskState: "Outdated", StoragePool: "thin"},
+	}, status.Volumes[0].Nodes)
+}
+
+func TestLabels(t *testing.T) {
+	t.Parallel()
+	assert.Nil(t, Labels(nil))
+	assert.Nil(t, Labels(&client.ResourceDefinition{Props: map[string]string{placementCountProp: "2"}}))
+	assert.Equal(t, map[string]string{"team": "foo", "env": "prod"}, Labels(&client.ResourceDefinition{Props: map[string]string{
+		placementCountProp:         "2",
+		labelPropPrefix + "team":   "foo",
+		labelPropPrefix + "env":    "prod",
+		"Aux/linstor-gateway/team": "bar",
+	}}))
+}
+
+func TestDrbdOptions(t *testing.T) {
+	t.Parallel()
+	assert.Nil(t, DrbdOptions(nil))
+	assert.Nil(t, DrbdOptions(&client.ResourceDefinition{Props: map[string]string{placementCountProp: "2"}}))
+	assert.Equal(t, map[string]string{"protocol": "A", "on-no-quorum": "suspend-io"}, DrbdOptions(&client.ResourceDefinition{Props: map[string]string{
+		placementCountProp:                  "2",
+		"DrbdOptions/Net/protocol":          "A",
+		"DrbdOptions/Resource/on-no-quorum": "suspend-io",
+		"DrbdOptions/Resource/auto-promote": "no",
+	}}))
+}
+
+func TestDrbdPortAndMinor(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, 0, DrbdPort(nil))
+	assert.Equal(t, 0, DrbdPort(&client.ResourceDefinition{}))
+	assert.Equal(t, 7005, DrbdPort(&client.ResourceDefinition{LayerData: []client.ResourceDefinitionLayer{
+		{Type: devicelayerkind.Storage},
+		{Type: devicelayerkind.Drbd, Data: &client.DrbdResourceDefinitionLayer{Port: 7005}},
+	}}))
+
+	drbdMinor := func(minor int32) []client.VolumeDefinitionLayer {
+		return []client.VolumeDefinitionLayer{{Type: devicelayerkind.Drbd, Data: &client.DrbdVolumeDefinition{MinorNumber: minor}}}
+	}
+	assert.Equal(t, 0, DrbdMinor(nil))
+	assert.Equal(t, 1000, DrbdMinor([]client.VolumeDefinition{
+		{VolumeNumber: gog.Ptr(int32(1)), LayerData: drbdMinor(1001)},
+		{VolumeNumber: gog.Ptr(int32(0)), LayerData: drbdMinor(1000)},
+	}))
+}
+
+func TestValidateControllers(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name        string
+		controllers []string
+		expectErr   bool
+	}{{
+		name:        "host names",
+		controllers: []string{"alpha", "bravo:3370", "http://charlie", "https://delta:3371", "linstor://echo"},
+	}, {
+		name:        "IPv4",
+		controllers: []string{"192.168.0.1", "http://192.168.0.2:3370"},
+	}, {
+		name:        "IPv6",
+		controllers: []string{"[::1]"},
+	}, {
+		name:        "IPv6 with scheme",
+		controllers: []string{"http://[fd00::1]"},
+	}, {
+		name:        "IPv6 with port",
+		controllers: []string{"[fd00::1]:3370"},
+	}, {
+		name:        "bare IPv6",
+		controllers: []string{"fd00::1"},
+	}, {
+		name:        "linstor+ssl",
+		controllers: []string{"linstor+ssl://alpha", "linstor+ssl://bravo:3371"},
+	}, {
+		name:        "multiple IPv6",
+		controllers: []string{"[fd00::1]", "[fd00::2]"},
+		expectErr:   true,
+	}, {
+		name:        "unsupported scheme",
+		controllers: []string{"ftp://alpha"},
+		expectErr:   true,
+	}, {
+		name:        "multiple schemes",
+		controllers: []string{"http://https://alpha"},
+		expectErr:   true,
+	}, {
+		name:        "invalid port",
+		controllers: []string{"alpha:http"},
+		expectErr:   true,
+	}, {
+		name:        "empty port",
+		controllers: []string{"alpha:"},
+		expectErr:   true,
+	}, {
+		name:        "path",
+		controllers: []string{"http://alpha/v1"},
+		expectErr:   true,
+	}, {
+		name:        "empty host",
+		controllers: []string{"http://:3370"},
+		expectErr:   true,
+	}, {
+		name:        "unbalanced brackets",
+		controllers: []string{"[fd00::1"},
+		expectErr:   true,
+	}}
+
+	for i := range cases {
+		tcase := &cases[i]
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+			err := ValidateControllers(tcase.controllers)
+			if tcase.expectErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestIPv6BaseURL(t *testing.T) {
+	t.Parallel()
+	if tlsFromEnv() {
+		t.Skip("TLS is configured by the environment")
+	}
+
+	assert.Nil(t, ipv6BaseURL(nil))
+	assert.Nil(t, ipv6BaseURL([]string{"alpha"}))
+	assert.Nil(t, ipv6BaseURL([]string{"[fd00::1]", "[fd00::2]"}))
+	assert.Equal(t, "http://[::1]:3370", ipv6BaseURL([]string{"[::1]"}).String())
+	assert.Equal(t, "https://[fd00::1]:3371", ipv6BaseURL([]string{"https://[fd00::1]"}).String())
+	assert.Equal(t, "http://[fd00::1]:8080", ipv6BaseURL([]string{"linstor://[fd00::1]:8080"}).String())
+}
+
+// staticResourceGroups only knows about the given resource groups.
+type staticResourceGroups struct {
+	client.ResourceGroupProvider
+	groups []string
+}
+
+func (s staticResourceGroups) Get(ctx context.Context, resGrpName string, opts ...*client.ListOpts) (client.ResourceGroup, error) {
+	for _, g := range s.groups {
+		if g == resGrpName {
+			return client.ResourceGroup{Name: g}, nil
+		}
+	}
+	return client.ResourceGroup{}, client.NotFoundError
+}
+
+func (s staticResourceGroups) GetAll(ctx context.Context, opts ...*client.ListOpts) ([]client.ResourceGroup, error) {
+	var groups []client.ResourceGroup
+	for _, g := range s.groups {
+		groups = append(groups, client.ResourceGroup{Name: g})
+	}
+	return groups, nil
+}
+
+func TestCheckResourceGroup(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name          string
+		groups        []string
+		resourceGroup string
+		expectedErr   string
+	}{{
+		name:          "exists",
+		groups:        []string{"DfltRscGrp", "rg"},
+		resourceGroup: "rg",
+	}, {
+		name:          "missing",
+		groups:        []string{"rg2", "DfltRscGrp", "rg1"},
+		resourceGroup: "rg",
+		expectedErr:   "resource group 'rg' does not exist (available: DfltRscGrp, rg1, rg2)",
+	}, {
+		name:          "no groups at all",
+		resourceGroup: "rg",
+		expectedErr:   "resource group 'rg' does not exist",
+	}}
+
+	for i := range cases {
+		tcase := &cases[i]
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+			l := &Linstor{Client: &client.Client{
+				ResourceGroups: staticResourceGroups{groups: tcase.groups},
+			}}
+
+			err := l.CheckResourceGroup(context.Background(), tcase.resourceGroup)
+			if tcase.expectedErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tcase.expectedErr)
+			}
+		})
+	}
+}
+
+func TestDefaultProbe(t *testing.T) {
+	t.Parallel()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"version":"1.19.1"}`))
+	}))
+	defer srv.Close()
+
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	cli, err := Default([]string{srv.URL})
+	assert.NoError(t, err)
+	if cli != nil {
+		cli.Close()
+	}
+
+	_, err = Default([]string{closed.URL})
+	assert.ErrorContains(t, err, "cannot reach LINSTOR controller at "+closed.URL)
+}
+
+func TestIOLimits(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name           string
+		limits         IOLimits
+		expectOverride map[string]string
+		expectRemove   []string
+	}{{
+		name:           "unlimited",
+		expectOverride: map[string]string{},
+		expectRemove:   []string{readLimitProp, readIOPSLimitProp, writeLimitProp, writeIOPSLimitProp},
+	}, {
+		name:           "bytes",
+		limits:         IOLimits{Read: &common.IOLimit{Value: 1024}, Write: &common.IOLimit{Value: 2048}},
+		expectOverride: map[string]string{readLimitProp: "1024", writeLimitProp: "2048"},
+		expectRemove:   []string{readIOPSLimitProp, writeIOPSLimitProp},
+	}, {
+		name:           "iops",
+		limits:         IOLimits{Read: &common.IOLimit{Value: 100, IOPS: true}},
+		expectOverride: map[string]string{readIOPSLimitProp: "100"},
+		expectRemove:   []string{readLimitProp, writeLimitProp, writeIOPSLimitProp},
+	}}
+
+	for i := range cases {
+		tcase := &cases[i]
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+			override, remove := tcase.limits.props()
+			assert.Equal(t, tcase.expectOverride, override)
+			assert.Equal(t, tcase.expectRemove, remove)
+
+			// the limits can be read back from the properties they set
+			actual := IOLimitsFromDefinition(&client.ResourceDefinition{Props: override})
+			assert.Equal(t, tcase.limits, actual)
+		})
+	}
+}
+
+func TestCheckCapacity(t *testing.T) {
+	t.Parallel()
+	volumes := []common.VolumeConfig{common.ClusterPrivateVolume(), {Number: 1, SizeKiB: 1024 * 1024}}
+	cases := []struct {
+		name        string
+		volumes     []common.VolumeConfig
+		candidates  []client.Candidate
+		expectedErr string
+	}{{
+		name:    "enough space",
+		volumes: volumes,
+		candidates: []client.Candidate{
+			{StoragePool: "small", MaxVolumeSizeKib: 1024},
+			{StoragePool: "large", MaxVolumeSizeKib: 2 * 1024 * 1024},
+		},
+	}, {
+		name:        "not enough space",
+		volumes:     []common.VolumeConfig{{Number: 1, SizeKiB: 10 * 1024 * 1024 * 1024}},
+		candidates:  []client.Candidate{{StoragePool: "pool", MaxVolumeSizeKib: 2 * 1024 * 1024 * 1024}},
+		expectedErr: "requested 10T but pool has 2T free",
+	}, {
+		name:        "volumes are added up",
+		volumes:     []common.VolumeConfig{{Number: 1, SizeKiB: 1024 * 1024}, {Number: 2, SizeKiB: 1024 * 1024}},
+		candidates:  []client.Candidate{{StoragePool: "pool", MaxVolumeSizeKib: 1024 * 1024}},
+		expectedErr: "requested 2G but pool has 1G free",
+	}, {
+		name:        "no candidates",
+		volumes:     volumes,
+		expectedErr: "requested 1088M but no pool has free space",
+	}}
+
+	for i := range cases {
+		tcase := &cases[i]
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+			err := checkCapacity(tcase.volumes, tcase.candidates)
+			if tcase.expectedErr != "" {
+				assert.EqualError(t, err, tcase.expectedErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
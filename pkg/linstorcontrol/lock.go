@@ -0,0 +1,276 @@
+package linstorcontrol
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	apiconsts "github.com/LINBIT/golinstor"
+	"github.com/LINBIT/golinstor/client"
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/LINBIT/linstor-gateway/pkg/common"
+)
+
+// lockPropPrefix is the prefix of the controller properties that hold the
+// locks of resources. The name of the resource is appended.
+const lockPropPrefix = apiconsts.NamespcAuxiliary + "/linstor-gateway/lock/"
+
+var (
+	// lockTTL is how long a lock is held without being renewed. It only
+	// matters if the holder does not release it, e.g. because it crashed.
+	lockTTL = 5 * time.Minute
+	// lockRenewInterval is how often a held lock is renewed.
+	lockRenewInterval = lockTTL / 3
+	// lockSettleTime is how long to wait after taking a lock before
+	// checking that no other caller took it at the same time. LINSTOR
+	// can not atomically set a property only if it does not exist yet,
+	// so the last writer wins.
+	lockSettleTime = 200 * time.Millisecond
+	// unlockTimeout limits how long releasing or renewing a lock may take.
+	// Locks are released even if the operation was cancelled.
+	unlockTimeout = 10 * time.Second
+)
+
+// errLockLost is returned when renewing a lock that another caller took over.
+var errLockLost = errors.New("lock was taken over by another caller")
+
+// lockInfo is the value of a lock property.
+type lockInfo struct {
+	Token   string    `json:"token"`
+	Holder  string    `json:"holder"`
+	Expires time.Time `json:"expires"`
+}
+
+type heldLocksKey struct{}
+
+// Lock takes the advisory lock of the resource with the given name, so that
+// operations that change the same resource do not run concurrently. The lock
+// is stored as a property of the LINSTOR controller, so that it is shared by
+// all gateways using the same cluster.
+//
+// Operations on a target or export lock the name that its drbd-reactor config
+// ID is made of, e.g. the WWN of an iSCSI target. Creating one also locks its
+// LINSTOR resource, like cleanup does.
+//
+// If another caller holds the lock, an error wrapping
+// common.ErrOperationInProgress is returned. Otherwise, the returned context
+// must be used for the rest of the operation: operations started with it do
+// not try to take the lock again. The lock is renewed until the returned
+// function releases it. If it can not be renewed, the returned context is
+// cancelled.
+func (l *Linstor) Lock(ctx context.Context, name string) (context.Context, func(), error) {
+	held, _ := ctx.Value(heldLocksKey{}).(map[string]bool)
+	if held[name] {
+		return ctx, func() {}, nil
+	}
+
+	logger := log.WithField("resource", name)
+	prop := lockPropPrefix + name
+
+	existing, err := l.getLock(ctx, prop)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if existing != nil && time.Now().Before(existing.Expires) {
+		return nil, nil, lockedError(name, existing)
+	}
+
+	if existing != nil {
+		logger.WithField("holder", existing.Holder).Warn("taking over expired lock")
+	}
+
+	own := lockInfo{Token: uuid.NewString(), Holder: lockHolder(), Expires: time.Now().Add(lockTTL)}
+	value, err := json.Marshal(own)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	logger.Trace("take lock")
+
+	err = l.Controller.Modify(ctx, client.GenericPropsModify{OverrideProps: map[string]string{prop: string(value)}})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to lock resource '%s': %w", name, err)
+	}
+
+	select {
+	case <-ctx.Done():
+		l.unlock(prop, own.Token)
+		return nil, nil, ctx.Err()
+	case <-time.After(lockSettleTime):
+	}
+
+	current, err := l.getLock(ctx, prop)
+	if err != nil {
+		l.unlock(prop, own.Token)
+		return nil, nil, err
+	}
+
+	if current == nil || current.Token != own.Token {
+		// Another caller took the lock at the same time and won.
+		if current == nil {
+			current = &lockInfo{Holder: "unknown"}
+		}
+		return nil, nil, lockedError(name, current)
+	}
+
+	newHeld := make(map[string]bool, len(held)+1)
+	for k := range held {
+		newHeld[k] = true
+	}
+	newHeld[name] = true
+
+	lockCtx, cancel := context.WithCancel(context.WithValue(ctx, heldLocksKey{}, newHeld))
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		l.keepLock(prop, own, stop, cancel)
+	}()
+
+	var once sync.Once
+	return lockCtx, func() {
+		once.Do(func() {
+			close(stop)
+			<-stopped
+			cancel()
+			l.unlock(prop, own.Token)
+		})
+	}, nil
+}
+
+// keepLock renews the lock in the given property every lockRenewInterval
+// until stop is closed. If the lock was taken over, or it would expire before
+// the next attempt to renew it, cancel is called.
+func (l *Linstor) keepLock(prop string, own lockInfo, stop <-chan struct{}, cancel context.CancelFunc) {
+	logger := log.WithField("lock", prop)
+	ticker := time.NewTicker(lockRenewInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		expires, err := l.renewLock(prop, own)
+		if err == nil {
+			own.Expires = expires
+			continue
+		}
+
+		if errors.Is(err, errLockLost) || time.Until(own.Expires) < lockRenewInterval {
+			logger.WithError(err).Error("failed to renew lock, cancelling the operation")
+			cancel()
+			return
+		}
+
+		logger.WithError(err).Warn("failed to renew lock, trying again")
+	}
+}
+
+// renewLock extends the lock in the given property by lockTTL if it is still
+// held with the token of own. It returns the new expiry time.
+func (l *Linstor) renewLock(prop string, own lockInfo) (time.Time, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), unlockTimeout)
+	defer cancel()
+
+	logger := log.WithField("lock", prop)
+	logger.Trace("renew lock")
+
+	current, err := l.getLock(ctx, prop)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if current == nil || current.Token != own.Token {
+		return time.Time{}, errLockLost
+	}
+
+	own.Expires = time.Now().Add(lockTTL)
+	value, err := json.Marshal(own)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	err = l.Controller.Modify(ctx, client.GenericPropsModify{OverrideProps: map[string]string{prop: string(value)}})
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to renew lock: %w", err)
+	}
+
+	return own.Expires, nil
+}
+
+// getLock returns the lock stored in the given property, or nil if there is
+// none. A lock that can not be parsed counts as expired.
+func (l *Linstor) getLock(ctx context.Context, prop string) (*lockInfo, error) {
+	props, err := l.Controller.GetProps(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch locks: %w", err)
+	}
+
+	value, ok := props[prop]
+	if !ok {
+		return nil, nil
+	}
+
+	info := &lockInfo{}
+	err = json.Unmarshal([]byte(value), info)
+	if err != nil {
+		return &lockInfo{Holder: "unknown"}, nil
+	}
+
+	return info, nil
+}
+
+// unlock releases the lock in the given property if it is still held with
+// token.
+func (l *Linstor) unlock(prop, token string) {
+	ctx, cancel := context.WithTimeout(context.Background(), unlockTimeout)
+	defer cancel()
+
+	logger := log.WithField("lock", prop)
+	logger.Trace("release lock")
+
+	current, err := l.getLock(ctx, prop)
+	if err != nil {
+		logger.WithError(err).Warn("failed to release lock, it expires on its own")
+		return
+	}
+
+	if current == nil || current.Token != token {
+		logger.Warn("lock was taken over by another caller, not releasing it")
+		return
+	}
+
+	err = l.Controller.DeleteProp(ctx, prop)
+	if err != nil {
+		logger.WithError(err).Warn("failed to release lock, it expires on its own")
+	}
+}
+
+func lockedError(name string, holder *lockInfo) error {
+	msg := fmt.Sprintf("resource '%s' is locked by %s", name, holder.Holder)
+	if !holder.Expires.IsZero() {
+		msg += fmt.Sprintf(" until %s", holder.Expires.Format(time.RFC3339))
+	}
+
+	return fmt.Errorf("%w: %s", common.ErrOperationInProgress, msg)
+}
+
+// lockHolder describes this process in the locks it takes.
+func lockHolder() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+
+	return fmt.Sprintf("%s (pid %d)", host, os.Getpid())
+}
@@ -0,0 +1,182 @@
+package linstorcontrol
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/LINBIT/golinstor/client"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/LINBIT/linstor-gateway/pkg/common"
+)
+
+// propsController stores the properties of the controller. If steal is set,
+// another caller overwrites every lock right after it was taken.
+type propsController struct {
+	client.ControllerProvider
+	mu    sync.Mutex
+	props client.ControllerProps
+	steal bool
+}
+
+func (c *propsController) GetProps(ctx context.Context, opts ...*client.ListOpts) (client.ControllerProps, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	props := make(client.ControllerProps, len(c.props))
+	for k, v := range c.props {
+		props[k] = v
+	}
+	return props, nil
+}
+
+func (c *propsController) Modify(ctx context.Context, props client.GenericPropsModify) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k, v := range props.OverrideProps {
+		if c.steal {
+			v = `{"token":"other","holder":"other"}`
+		}
+		c.props[k] = v
+	}
+	return nil
+}
+
+func (c *propsController) DeleteProp(ctx context.Context, prop string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.props, prop)
+	return nil
+}
+
+func lockValue(t *testing.T, info lockInfo) string {
+	value, err := json.Marshal(info)
+	assert.NoError(t, err)
+	return string(value)
+}
+
+func TestLock(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name       string
+		existing   *lockInfo
+		steal      bool
+		expectErr  bool
+		expectKept bool
+	}{{
+		name: "unlocked",
+	}, {
+		name:       "locked",
+		existing:   &lockInfo{Token: "other", Holder: "other", Expires: time.Now().Add(time.Hour)},
+		expectErr:  true,
+		expectKept: true,
+	}, {
+		name:     "expired",
+		existing: &lockInfo{Token: "other", Holder: "other", Expires: time.Now().Add(-time.Hour)},
+	}, {
+		name:       "taken concurrently",
+		steal:      true,
+		expectErr:  true,
+		expectKept: true,
+	}}
+
+	for i := range cases {
+		tcase := &cases[i]
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			c := &propsController{props: client.ControllerProps{}, steal: tcase.steal}
+			if tcase.existing != nil {
+				c.props[lockPropPrefix+"rsc"] = lockValue(t, *tcase.existing)
+			}
+			l := &Linstor{Client: &client.Client{Controller: c}}
+
+			ctx, unlock, err := l.Lock(context.Background(), "rsc")
+			if tcase.expectErr {
+				assert.ErrorIs(t, err, common.ErrOperationInProgress)
+				assert.ErrorContains(t, err, "other")
+			} else {
+				assert.NoError(t, err)
+
+				// taking the same lock again with the returned context
+				// does not block
+				_, unlockAgain, err := l.Lock(ctx, "rsc")
+				assert.NoError(t, err)
+				unlockAgain()
+				assert.Contains(t, c.props, lockPropPrefix+"rsc")
+
+				// but with any other context, it does
+				_, _, err = l.Lock(context.Background(), "rsc")
+				assert.ErrorIs(t, err, common.ErrOperationInProgress)
+
+				// other resources are not locked
+				_, unlockOther, err := l.Lock(ctx, "other-rsc")
+				assert.NoError(t, err)
+				unlockOther()
+
+				unlock()
+			}
+
+			if tcase.expectKept {
+				assert.Contains(t, c.props, lockPropPrefix+"rsc")
+			} else {
+				assert.Empty(t, c.props)
+			}
+		})
+	}
+}
+
+func TestLockRenew(t *testing.T) {
+	// not parallel: changes the renew interval for all locks
+	defer func(interval time.Duration) { lockRenewInterval = interval }(lockRenewInterval)
+	lockRenewInterval = 10 * time.Millisecond
+
+	getLock := func(t *testing.T, c *propsController) lockInfo {
+		props, err := c.GetProps(context.Background())
+		assert.NoError(t, err)
+		var info lockInfo
+		assert.NoError(t, json.Unmarshal([]byte(props[lockPropPrefix+"rsc"]), &info))
+		return info
+	}
+
+	t.Run("renewed", func(t *testing.T) {
+		c := &propsController{props: client.ControllerProps{}}
+		l := &Linstor{Client: &client.Client{Controller: c}}
+
+		ctx, unlock, err := l.Lock(context.Background(), "rsc")
+		assert.NoError(t, err)
+		taken := getLock(t, c)
+
+		assert.Eventually(t, func() bool {
+			return getLock(t, c).Expires.After(taken.Expires)
+		}, time.Second, lockRenewInterval)
+		assert.NoError(t, ctx.Err())
+		assert.Equal(t, taken.Token, getLock(t, c).Token)
+
+		unlock()
+		assert.Empty(t, c.props)
+		assert.Error(t, ctx.Err())
+	})
+
+	t.Run("taken over", func(t *testing.T) {
+		c := &propsController{props: client.ControllerProps{}}
+		l := &Linstor{Client: &client.Client{Controller: c}}
+
+		ctx, unlock, err := l.Lock(context.Background(), "rsc")
+		assert.NoError(t, err)
+		defer unlock()
+
+		other := lockValue(t, lockInfo{Token: "other", Holder: "other", Expires: time.Now().Add(time.Hour)})
+		assert.NoError(t, c.Modify(context.Background(), client.GenericPropsModify{OverrideProps: map[string]string{lockPropPrefix + "rsc": other}}))
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(time.Second):
+			t.Fatal("context was not cancelled")
+		}
+		assert.Equal(t, "other", getLock(t, c).Token)
+	})
+}
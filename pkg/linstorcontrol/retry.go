@@ -0,0 +1,91 @@
+package linstorcontrol
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	apiconsts "github.com/LINBIT/golinstor"
+	"github.com/LINBIT/golinstor/client"
+	log "github.com/sirupsen/logrus"
+)
+
+// RetryConfig controls how often a LINSTOR call is retried when it fails
+// with a transient error, and how long to wait in between. The backoff is
+// doubled after every failed attempt.
+type RetryConfig struct {
+	Attempts int
+	Backoff  time.Duration
+}
+
+// DefaultRetryConfig is the retry configuration used by clients created
+// through Default.
+var DefaultRetryConfig = RetryConfig{
+	Attempts: 3,
+	Backoff:  time.Second,
+}
+
+// retry calls f until it succeeds, fails with an error that is not
+// transient, or the configured number of attempts is exhausted.
+func (l *Linstor) retry(ctx context.Context, f func() error) error {
+	attempts := l.Retry.Attempts
+	if attempts < 1 {
+		attempts = 1
+	}
+	backoff := l.Retry.Backoff
+
+	var err error
+	for i := 1; ; i++ {
+		err = f()
+		if err == nil || !isErrTransient(err) {
+			return err
+		}
+		if i >= attempts {
+			break
+		}
+
+		log.WithFields(log.Fields{
+			"attempt": i,
+			"backoff": backoff,
+		}).Debugf("transient LINSTOR error, retrying: %v", err)
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w (giving up after %d attempts: %v)", err, i, ctx.Err())
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+
+	return fmt.Errorf("%w (giving up after %d attempts)", err, attempts)
+}
+
+// isErrTransient reports whether err is likely to go away when the request
+// is repeated, e.g. because the controller was busy or briefly unreachable.
+func isErrTransient(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var apiErr client.ApiCallError
+	if errors.As(err, &apiErr) {
+		for _, rc := range apiErr {
+			// compare type and code exactly, ApiCallRc.Is would also
+			// match unrelated codes that happen to share bits
+			code := uint64(rc.RetCode) & (apiconsts.MaskBitsType | apiconsts.MaskBitsCode)
+			if code == apiconsts.FailRscBusy || code == apiconsts.FailNotConnected {
+				return true
+			}
+		}
+		return false
+	}
+
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
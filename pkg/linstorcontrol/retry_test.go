@@ -0,0 +1,467 @@
+package linstorcontrol
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	apiconsts "github.com/LINBIT/golinstor"
+	"github.com/LINBIT/golinstor/client"
+	"github.com/icza/gog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/LINBIT/linstor-gateway/pkg/common"
+)
+
+// flakyResourceGroups fails the first `failures` calls to Create with err.
+type flakyResourceGroups struct {
+	client.ResourceGroupProvider
+	failures int
+	err      error
+	calls    int
+}
+
+func (f *flakyResourceGroups) Create(ctx context.Context, resGrp client.ResourceGroup) error {
+	f.calls++
+	if f.calls <= f.failures {
+		return f.err
+	}
+	return nil
+}
+
+func (f *flakyResourceGroups) Get(ctx context.Context, resGrpName string, opts ...*client.ListOpts) (client.ResourceGroup, error) {
+	return client.ResourceGroup{Name: resGrpName}, nil
+}
+
+type fakeResourceDefinitions struct {
+	client.ResourceDefinitionProvider
+}
+
+func (fakeResourceDefinitions) Create(ctx context.Context, resDef client.ResourceDefinitionCreate) error {
+	return nil
+}
+
+func (fakeResourceDefinitions) CreateVolumeDefinition(ctx context.Context, resDefName string, volDef client.VolumeDefinitionCreate) error {
+	return nil
+}
+
+func (fakeResourceDefinitions) Get(ctx context.Context, resDefName string, opts ...*client.ListOpts) (client.ResourceDefinition, error) {
+	return client.ResourceDefinition{Name: resDefName}, nil
+}
+
+func (fakeResourceDefinitions) GetVolumeDefinitions(ctx context.Context, resDefName string, opts ...*client.ListOpts) ([]client.VolumeDefinition, error) {
+	return nil, nil
+}
+
+type fakeResources struct {
+	client.ResourceProvider
+}
+
+func (fakeResources) Autoplace(ctx context.Context, resName string, apr client.AutoPlaceRequest) error {
+	return nil
+}
+
+func (fakeResources) GetResourceView(ctx context.Context, opts ...*client.ListOpts) ([]client.ResourceWithVolumes, error) {
+	return []client.ResourceWithVolumes{{Resource: client.Resource{Name: opts[0].Resource[0]}}}, nil
+}
+
+func apiError(code uint64) error {
+	return client.ApiCallError{{RetCode: int64(code), Message: "mock error"}}
+}
+
+func TestEnsureResourceRetry(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name          string
+		failures      int
+		err           error
+		expectedCalls int
+		expectError   bool
+	}{{
+		name:          "fails twice then succeeds",
+		failures:      2,
+		err:           apiError(apiconsts.FailRscBusy),
+		expectedCalls: 3,
+	}, {
+		name:          "attempts exhausted",
+		failures:      5,
+		err:           apiError(apiconsts.FailNotConnected),
+		expectedCalls: 3,
+		expectError:   true,
+	}, {
+		name:          "non-transient error",
+		failures:      1,
+		err:           apiError(apiconsts.FailInvldRscName),
+		expectedCalls: 1,
+		expectError:   true,
+	}, {
+		name:          "non-api error",
+		failures:      1,
+		err:           errors.New("something broke"),
+		expectedCalls: 1,
+		expectError:   true,
+	}}
+
+	for i := range cases {
+		tcase := &cases[i]
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+			groups := &flakyResourceGroups{failures: tcase.failures, err: tcase.err}
+			l := &Linstor{
+				Client: &client.Client{
+					ResourceGroups:      groups,
+					ResourceDefinitions: fakeResourceDefinitions{},
+					Resources:           fakeResources{},
+				},
+				Retry: RetryConfig{Attempts: 3},
+			}
+
+			_, _, _, err := l.EnsureResource(context.Background(), Resource{Name: "test", ResourceGroup: "rg"}, false)
+			if tcase.expectError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tcase.expectedCalls, groups.calls)
+		})
+	}
+}
+
+// lossyResourceDefinitions fails the first Create with a network error. If
+// apply is set, the resource definition is created anyway. Definitions in
+// existing are present up front.
+type lossyResourceDefinitions struct {
+	fakeResourceDefinitions
+	apply    bool
+	existing map[string]client.ResourceDefinition
+	calls    int
+}
+
+func (l *lossyResourceDefinitions) Create(ctx context.Context, resDef client.ResourceDefinitionCreate) error {
+	l.calls++
+	if l.calls == 1 {
+		if l.apply {
+			l.existing[resDef.ResourceDefinition.Name] = resDef.ResourceDefinition
+		}
+		return &net.OpError{Op: "read", Net: "tcp", Err: errors.New("connection reset by peer")}
+	}
+	if _, ok := l.existing[resDef.ResourceDefinition.Name]; ok {
+		return apiError(apiconsts.FailExistsRscDfn)
+	}
+	l.existing[resDef.ResourceDefinition.Name] = resDef.ResourceDefinition
+	return nil
+}
+
+func (l *lossyResourceDefinitions) Get(ctx context.Context, resDefName string, opts ...*client.ListOpts) (client.ResourceDefinition, error) {
+	return l.existing[resDefName], nil
+}
+
+func TestEnsureResourceLostCreateResponse(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name        string
+		apply       bool
+		existing    map[string]client.ResourceDefinition
+		expectError bool
+	}{{
+		name:     "created by first attempt",
+		apply:    true,
+		existing: map[string]client.ResourceDefinition{},
+	}, {
+		name:     "not created by first attempt",
+		existing: map[string]client.ResourceDefinition{},
+	}, {
+		name: "created by someone else",
+		existing: map[string]client.ResourceDefinition{
+			"test": {Name: "test", ResourceGroupName: "other"},
+		},
+		expectError: true,
+	}, {
+		name: "kept from an earlier create",
+		existing: map[string]client.ResourceDefinition{
+			"test": {Name: "test", ResourceGroupName: "rg", Props: map[string]string{
+				apiconsts.NamespcDrbdResourceOptions + "/auto-promote": "no",
+				drbdOptionProps[common.DrbdOptionQuorum]:               "majority",
+				drbdOptionProps[common.DrbdOptionOnNoQuorum]:           "io-error",
+				createTokenProp: "earlier",
+			}},
+		},
+		expectError: true,
+	}}
+
+	for i := range cases {
+		tcase := &cases[i]
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+			definitions := &lossyResourceDefinitions{apply: tcase.apply, existing: tcase.existing}
+			l := &Linstor{
+				Client: &client.Client{
+					ResourceGroups:      &flakyResourceGroups{},
+					ResourceDefinitions: definitions,
+					Resources:           fakeResources{},
+				},
+				Retry: RetryConfig{Attempts: 3},
+			}
+
+			_, _, _, err := l.EnsureResource(context.Background(), Resource{Name: "test", ResourceGroup: "rg"}, false)
+			if tcase.expectError {
+				assert.Error(t, err)
+				assert.True(t, isErrAlreadyExists(errors.Unwrap(err)))
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, 2, definitions.calls)
+		})
+	}
+}
+
+// volumeDefinitions records the volume definitions that exist. Creating the
+// volume with number failOn fails. The first attempt to create the volume
+// with number lossyOn fails with a network error, even if the volume exists.
+type volumeDefinitions struct {
+	fakeResourceDefinitions
+	failOn  int
+	lossyOn int
+	lost    bool
+	volumes map[int]bool
+}
+
+func (v *volumeDefinitions) GetVolumeDefinitions(ctx context.Context, resDefName string, opts ...*client.ListOpts) ([]client.VolumeDefinition, error) {
+	var result []client.VolumeDefinition
+	for nr := range v.volumes {
+		result = append(result, client.VolumeDefinition{VolumeNumber: gog.Ptr(int32(nr))})
+	}
+	return result, nil
+}
+
+func (v *volumeDefinitions) CreateVolumeDefinition(ctx context.Context, resDefName string, volDef client.VolumeDefinitionCreate) error {
+	nr := int(*volDef.VolumeDefinition.VolumeNumber)
+	if nr == v.failOn {
+		return apiError(apiconsts.FailInvldVlmSize)
+	}
+	if nr == v.lossyOn && !v.lost {
+		v.lost = true
+		return &net.OpError{Op: "read", Net: "tcp", Err: errors.New("connection reset by peer")}
+	}
+	if v.volumes[nr] {
+		return apiError(apiconsts.FailExistsVlmDfn)
+	}
+	v.volumes[nr] = true
+	return nil
+}
+
+func (v *volumeDefinitions) DeleteVolumeDefinition(ctx context.Context, resDefName string, volNr int) error {
+	delete(v.volumes, volNr)
+	return nil
+}
+
+func TestEnsureResourceCleansUpVolumeDefinitions(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name     string
+		existing []int
+		failOn   int
+		lossyOn  int
+		expected map[int]bool
+	}{{
+		name:     "new resource",
+		failOn:   2,
+		lossyOn:  -1,
+		expected: map[int]bool{},
+	}, {
+		name:     "existing volumes are kept",
+		existing: []int{0, 1},
+		failOn:   3,
+		lossyOn:  -1,
+		expected: map[int]bool{0: true, 1: true},
+	}, {
+		name:     "existing volume with a lost response is kept",
+		existing: []int{0, 1},
+		failOn:   3,
+		lossyOn:  1,
+		expected: map[int]bool{0: true, 1: true},
+	}, {
+		name:     "new volume with a lost response is deleted",
+		existing: []int{0},
+		failOn:   3,
+		lossyOn:  1,
+		expected: map[int]bool{0: true},
+	}, {
+		name:     "success",
+		failOn:   -1,
+		lossyOn:  -1,
+		expected: map[int]bool{0: true, 1: true, 2: true, 3: true},
+	}}
+
+	for i := range cases {
+		tcase := &cases[i]
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+			definitions := &volumeDefinitions{failOn: tcase.failOn, lossyOn: tcase.lossyOn, volumes: map[int]bool{}}
+			for _, nr := range tcase.existing {
+				definitions.volumes[nr] = true
+			}
+			l := &Linstor{
+				Client: &client.Client{
+					ResourceGroups:      &flakyResourceGroups{},
+					ResourceDefinitions: definitions,
+					Resources:           fakeResources{},
+				},
+				Retry: RetryConfig{Attempts: 3},
+			}
+
+			res := Resource{Name: "test", ResourceGroup: "rg"}
+			for nr := 0; nr < 4; nr++ {
+				res.Volumes = append(res.Volumes, common.VolumeConfig{Number: nr, SizeKiB: 1024})
+			}
+
+			_, _, _, err := l.EnsureResource(context.Background(), res, true)
+			if tcase.failOn >= 0 {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tcase.expected, definitions.volumes)
+		})
+	}
+}
+
+// failingAutoplace fails to place resources with err.
+type failingAutoplace struct {
+	fakeResources
+	err error
+}
+
+func (f failingAutoplace) Autoplace(ctx context.Context, resName string, apr client.AutoPlaceRequest) error {
+	return f.err
+}
+
+func TestEnsureResourceNoStoragePool(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name             string
+		err              error
+		placementCount   int
+		storagePool      string
+		expectNoPool     bool
+		expectedReplicas string
+	}{{
+		name:             "not enough nodes",
+		err:              apiError(apiconsts.FailNotEnoughNodes),
+		placementCount:   2,
+		expectNoPool:     true,
+		expectedReplicas: "for 2 replicas",
+	}, {
+		name:             "no default storage pool",
+		err:              apiError(apiconsts.FailNotFoundDfltStorPool),
+		expectNoPool:     true,
+		expectedReplicas: "for the requested replicas",
+	}, {
+		name:             "storage pool override",
+		err:              apiError(apiconsts.FailNotEnoughNodes),
+		storagePool:      "thin",
+		expectNoPool:     true,
+		expectedReplicas: "in storage pool 'thin'",
+	}, {
+		name: "other error",
+		err:  apiError(apiconsts.FailInvldRscName),
+	}}
+
+	for i := range cases {
+		tcase := &cases[i]
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+			l := &Linstor{
+				Client: &client.Client{
+					Nodes:               fakeStoragePools{pools: []string{"thin"}},
+					ResourceGroups:      &flakyResourceGroups{},
+					ResourceDefinitions: fakeResourceDefinitions{},
+					Resources:           failingAutoplace{err: tcase.err},
+				},
+			}
+
+			_, _, _, err := l.EnsureResource(context.Background(), Resource{Name: "test", ResourceGroup: "rg", PlacementCount: tcase.placementCount, StoragePool: tcase.storagePool}, false)
+			assert.Error(t, err)
+			assert.Equal(t, tcase.expectNoPool, errors.Is(err, common.ErrNoStoragePool))
+			if tcase.expectNoPool {
+				assert.Contains(t, err.Error(), tcase.expectedReplicas)
+				assert.Contains(t, err.Error(), "linstor storage-pool list")
+			}
+		})
+	}
+}
+
+// fakeStoragePools lists the storage pools with the given names.
+type fakeStoragePools struct {
+	client.NodeProvider
+	pools []string
+}
+
+func (f fakeStoragePools) GetStoragePoolView(ctx context.Context, opts ...*client.ListOpts) ([]client.StoragePool, error) {
+	var result []client.StoragePool
+	for _, pool := range f.pools {
+		result = append(result, client.StoragePool{StoragePoolName: pool, NodeName: "node1"})
+	}
+	return result, nil
+}
+
+// recordingAutoplace records the last autoplace request.
+type recordingAutoplace struct {
+	fakeResources
+	request *client.AutoPlaceRequest
+}
+
+func (r recordingAutoplace) Autoplace(ctx context.Context, resName string, apr client.AutoPlaceRequest) error {
+	*r.request = apr
+	return nil
+}
+
+func TestEnsureResourceStoragePool(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name           string
+		storagePool    string
+		existingPools  []string
+		expectError    bool
+		expectedFilter client.AutoSelectFilter
+	}{{
+		name: "from resource group",
+	}, {
+		name:           "override",
+		storagePool:    "thin",
+		existingPools:  []string{"thin"},
+		expectedFilter: client.AutoSelectFilter{StoragePool: "thin", StoragePoolList: []string{"thin"}},
+	}, {
+		name:          "missing pool",
+		storagePool:   "thin",
+		existingPools: []string{"thick"},
+		expectError:   true,
+	}}
+
+	for i := range cases {
+		tcase := &cases[i]
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+			var request client.AutoPlaceRequest
+			l := &Linstor{
+				Client: &client.Client{
+					Nodes:               fakeStoragePools{pools: tcase.existingPools},
+					ResourceGroups:      &flakyResourceGroups{},
+					ResourceDefinitions: fakeResourceDefinitions{},
+					Resources:           recordingAutoplace{request: &request},
+				},
+			}
+
+			_, _, _, err := l.EnsureResource(context.Background(), Resource{Name: "test", ResourceGroup: "rg", StoragePool: tcase.storagePool}, false)
+			if tcase.expectError {
+				assert.Error(t, err)
+				assert.IsType(t, common.ValidationError(""), err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tcase.expectedFilter, request.SelectFilter)
+		})
+	}
+}
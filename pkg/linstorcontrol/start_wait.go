@@ -0,0 +1,126 @@
+package linstorcontrol
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/LINBIT/linstor-gateway/pkg/common"
+	"github.com/LINBIT/linstor-gateway/pkg/observe"
+)
+
+// StartWaitConfig controls how long WaitForStart waits for drbd-reactor to
+// start the services of a resource.
+type StartWaitConfig struct {
+	// Timeout is how long to wait for the services to start. Every failed
+	// start that drbd-reactor retries extends the wait by Timeout.
+	Timeout time.Duration
+	// MaxTimeout limits the total time to wait, including all extensions.
+	MaxTimeout time.Duration
+	// Interval is the time between two polls of the resource status.
+	Interval time.Duration
+}
+
+// DefaultStartWait is the configuration used by the Start operations of all
+// protocols.
+var DefaultStartWait = StartWaitConfig{
+	Timeout:    30 * time.Second,
+	MaxTimeout: 2 * time.Minute,
+	Interval:   3 * time.Second,
+}
+
+// WaitForStart polls the status of the resource name until its services are
+// started and it is in use on one of the nodes.
+//
+// drbd-reactor demotes the resource again if starting one of the services
+// fails, and then retries, possibly on another node. Whenever that is
+// observed, the wait is extended, up to cfg.MaxTimeout. Changes of the status
+// are logged while waiting. If the services do not start in time, the
+// returned error describes the last observed failure.
+func WaitForStart(ctx context.Context, cfg StartWaitConfig, name string, status func(ctx context.Context) (common.ResourceStatus, error)) (err error) {
+	ctx, end := observe.Step(ctx, "linstorcontrol.WaitForStart")
+	defer func() { end(err) }()
+
+	logger := log.WithField("resource", name)
+	begin := time.Now()
+	deadline := begin.Add(cfg.Timeout)
+	maxDeadline := begin.Add(cfg.MaxTimeout)
+
+	var last *common.ResourceStatus
+	attempts := 0
+	primary := ""
+	reason := "the resource was not promoted on any node"
+	for {
+		st, err := status(ctx)
+		if err != nil && !isErrTransient(err) {
+			return err
+		}
+
+		if err != nil {
+			logger.WithError(err).Debug("transient error while waiting for the services to start")
+		} else {
+			if st.Running() {
+				return nil
+			}
+
+			if last == nil || st.Service != last.Service || st.State != last.State || st.Primary != last.Primary {
+				logger.WithFields(log.Fields{
+					"service": st.Service,
+					"state":   st.State,
+					"primary": st.Primary,
+				}).Info("waiting for the services to start")
+			}
+			last = &st
+
+			switch {
+			case st.Primary != "":
+				primary = st.Primary
+			case primary != "":
+				// The resource was promoted, but demoted again before the
+				// services were started: drbd-reactor gave up on this
+				// node and will try again.
+				attempts++
+				reason = fmt.Sprintf("starting the services on %s failed", primary)
+				primary = ""
+
+				extended := time.Now().Add(cfg.Timeout)
+				if extended.After(maxDeadline) {
+					extended = maxDeadline
+				}
+				if extended.After(deadline) {
+					deadline = extended
+				}
+
+				logger.WithFields(log.Fields{
+					"attempts": attempts,
+					"deadline": deadline.Format(time.RFC3339),
+				}).Warnf("%s, waiting for drbd-reactor to retry", reason)
+			}
+		}
+
+		wait := cfg.Interval
+		if remaining := time.Until(deadline); remaining <= 0 {
+			return fmt.Errorf("timed out after %s waiting for the services of %s to start: %s", time.Since(begin).Round(time.Second), name, describeStartFailure(reason, last))
+		} else if remaining < wait {
+			wait = remaining
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w while waiting for the services of %s to start: %s", ctx.Err(), name, describeStartFailure(reason, last))
+		case <-time.After(wait):
+		}
+	}
+}
+
+// describeStartFailure adds the state of the resource to reason if it is not
+// healthy, as that is a likely cause for the failure.
+func describeStartFailure(reason string, last *common.ResourceStatus) string {
+	if last == nil || last.State == common.ResourceStateOK || last.State == common.Unknown {
+		return reason
+	}
+
+	return fmt.Sprintf("%s (resource state is %s)", reason, last.State)
+}
@@ -0,0 +1,98 @@
+package linstorcontrol
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/LINBIT/linstor-gateway/pkg/common"
+)
+
+func TestWaitForStart(t *testing.T) {
+	t.Parallel()
+	started := common.ResourceStatus{State: common.ResourceStateOK, Service: common.ServiceStateStarted, Primary: "node-a"}
+	promoting := common.ResourceStatus{State: common.ResourceStateOK, Service: common.ServiceStateTransitioning, Primary: "node-a"}
+	waiting := common.ResourceStatus{State: common.ResourceStateOK, Service: common.ServiceStateTransitioning}
+	degraded := common.ResourceStatus{State: common.ResourceStateDegraded, Service: common.ServiceStateTransitioning}
+	errBroken := errors.New("broken")
+
+	cases := []struct {
+		name string
+		// statuses are returned by the successive polls. The last one is
+		// repeated.
+		statuses  []common.ResourceStatus
+		statusErr error
+		expectErr string
+	}{{
+		name:     "started immediately",
+		statuses: []common.ResourceStatus{started},
+	}, {
+		name:     "started after a while",
+		statuses: []common.ResourceStatus{waiting, waiting, promoting, started},
+	}, {
+		name:     "started after a failed attempt",
+		statuses: []common.ResourceStatus{waiting, promoting, waiting, promoting, started},
+	}, {
+		name:      "never promoted",
+		statuses:  []common.ResourceStatus{waiting},
+		expectErr: "the resource was not promoted on any node",
+	}, {
+		name:      "promotion keeps failing",
+		statuses:  []common.ResourceStatus{promoting, waiting, promoting, degraded},
+		expectErr: "starting the services on node-a failed (resource state is Degraded)",
+	}, {
+		name:      "status error",
+		statuses:  []common.ResourceStatus{waiting},
+		statusErr: errBroken,
+		expectErr: "broken",
+	}}
+
+	for i := range cases {
+		tcase := &cases[i]
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+			var mu sync.Mutex
+			polls := 0
+			status := func(ctx context.Context) (common.ResourceStatus, error) {
+				mu.Lock()
+				defer mu.Unlock()
+				if tcase.statusErr != nil {
+					return common.ResourceStatus{}, tcase.statusErr
+				}
+				st := tcase.statuses[len(tcase.statuses)-1]
+				if polls < len(tcase.statuses) {
+					st = tcase.statuses[polls]
+				}
+				polls++
+				return st, nil
+			}
+
+			cfg := StartWaitConfig{Timeout: 50 * time.Millisecond, MaxTimeout: 200 * time.Millisecond, Interval: time.Millisecond}
+			err := WaitForStart(context.Background(), cfg, "test", status)
+			if tcase.expectErr != "" {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tcase.expectErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestWaitForStartCancelled(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	status := func(ctx context.Context) (common.ResourceStatus, error) {
+		return common.ResourceStatus{Service: common.ServiceStateTransitioning}, nil
+	}
+
+	cfg := StartWaitConfig{Timeout: time.Minute, MaxTimeout: time.Minute, Interval: time.Second}
+	err := WaitForStart(ctx, cfg, "test", status)
+	assert.ErrorIs(t, err, context.Canceled)
+}
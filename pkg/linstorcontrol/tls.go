@@ -0,0 +1,122 @@
+package linstorcontrol
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// TLSConfig configures HTTPS connections to the LINSTOR controller.
+type TLSConfig struct {
+	// CAFile is the path of the PEM encoded certificate authority that
+	// signed the certificate of the controller. If empty, the system
+	// certificate pool is used.
+	CAFile string
+	// CertFile and KeyFile are the paths of the PEM encoded client
+	// certificate and key used to authenticate against the controller.
+	// They have to be given together.
+	CertFile string
+	KeyFile  string
+	// InsecureSkipVerify disables the verification of the certificate of
+	// the controller. Only use it for testing.
+	InsecureSkipVerify bool
+}
+
+// DefaultTLS is the TLS configuration Default uses to connect to the LINSTOR
+// controller. If it is empty, TLS can still be configured by the environment
+// variables golinstor reads.
+var DefaultTLS TLSConfig
+
+// enabled reports whether any TLS option is set.
+func (t TLSConfig) enabled() bool {
+	return t.CAFile != "" || t.CertFile != "" || t.KeyFile != "" || t.InsecureSkipVerify
+}
+
+// load reads the certificates and returns the resulting TLS configuration.
+func (t TLSConfig) load() (*tls.Config, error) {
+	cfg := &tls.Config{InsecureSkipVerify: t.InsecureSkipVerify}
+
+	if t.CAFile != "" {
+		pem, err := os.ReadFile(t.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read LINSTOR CA certificate: %w", err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no valid certificate found in LINSTOR CA certificate %s", t.CAFile)
+		}
+		cfg.RootCAs = pool
+	}
+
+	if (t.CertFile == "") != (t.KeyFile == "") {
+		return nil, errors.New("the LINSTOR client certificate and key have to be given together")
+	}
+
+	if t.CertFile != "" {
+		keyPair, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load LINSTOR client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{keyPair}
+	}
+
+	return cfg, nil
+}
+
+// httpsControllers returns the controllers with their scheme set to https.
+// Controllers without scheme or with the "linstor" scheme would otherwise
+// be reached via plain HTTP, as golinstor only defaults to HTTPS if TLS is
+// configured by environment variables.
+func httpsControllers(controllers []string) []string {
+	if len(controllers) == 0 {
+		return []string{"https://localhost"}
+	}
+
+	result := make([]string, len(controllers))
+	for i, c := range controllers {
+		switch {
+		case strings.HasPrefix(c, "linstor://"):
+			result[i] = "https://" + strings.TrimPrefix(c, "linstor://")
+		case !strings.Contains(c, "://"):
+			result[i] = "https://" + c
+		default:
+			result[i] = c
+		}
+	}
+
+	return result
+}
+
+// sslControllers returns the controllers with the "linstor+ssl" scheme of the
+// linstor client replaced by https, which golinstor does not know.
+func sslControllers(controllers []string) []string {
+	result := make([]string, len(controllers))
+	for i, c := range controllers {
+		result[i] = c
+		if strings.HasPrefix(c, "linstor+ssl://") {
+			result[i] = "https://" + strings.TrimPrefix(c, "linstor+ssl://")
+		}
+	}
+
+	return result
+}
+
+// checkHTTPSControllers returns an error if any of the controllers uses
+// https, but TLS is configured neither by t nor by environment variables.
+func checkHTTPSControllers(controllers []string, t TLSConfig) error {
+	if t.enabled() || tlsFromEnv() {
+		return nil
+	}
+
+	for _, c := range controllers {
+		if strings.HasPrefix(c, "https://") {
+			return fmt.Errorf("LINSTOR controller %s uses https, but no certificates are configured: use --linstor-ca, --linstor-cert and --linstor-key", c)
+		}
+	}
+
+	return nil
+}
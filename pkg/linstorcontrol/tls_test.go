@@ -0,0 +1,125 @@
+package linstorcontrol
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTTPSControllers(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name        string
+		controllers []string
+		expected    []string
+	}{{
+		name:        "no controllers",
+		controllers: nil,
+		expected:    []string{"https://localhost"},
+	}, {
+		name:        "mixed schemes",
+		controllers: []string{"alpha", "linstor://bravo:3371", "https://charlie", "http://delta:3370"},
+		expected:    []string{"https://alpha", "https://bravo:3371", "https://charlie", "http://delta:3370"},
+	}}
+
+	for i := range cases {
+		tcase := &cases[i]
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tcase.expected, httpsControllers(tcase.controllers))
+		})
+	}
+}
+
+func TestSSLControllers(t *testing.T) {
+	t.Parallel()
+	assert.Empty(t, sslControllers(nil))
+	assert.Equal(t, []string{"https://alpha", "linstor://bravo", "https://[fd00::1]:3371"}, sslControllers([]string{"linstor+ssl://alpha", "linstor://bravo", "linstor+ssl://[fd00::1]:3371"}))
+}
+
+func TestCheckHTTPSControllers(t *testing.T) {
+	t.Parallel()
+	if tlsFromEnv() {
+		t.Skip("TLS is configured by the environment")
+	}
+
+	cases := []struct {
+		name        string
+		controllers []string
+		tls         TLSConfig
+		expectErr   bool
+	}{{
+		name:        "plain http",
+		controllers: []string{"alpha", "http://bravo"},
+	}, {
+		name:        "https without certificates",
+		controllers: []string{"alpha", "https://bravo"},
+		expectErr:   true,
+	}, {
+		name:        "https with CA",
+		controllers: []string{"https://bravo"},
+		tls:         TLSConfig{CAFile: "/etc/linstor/ca.pem"},
+	}}
+
+	for i := range cases {
+		tcase := &cases[i]
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+			err := checkHTTPSControllers(tcase.controllers, tcase.tls)
+			if tcase.expectErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestTLSConfigLoad(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	invalidCA := filepath.Join(dir, "ca.pem")
+	err := os.WriteFile(invalidCA, []byte("not a certificate\n"), 0o644)
+	assert.NoError(t, err)
+
+	cases := []struct {
+		name      string
+		tls       TLSConfig
+		expectErr bool
+	}{{
+		name: "insecure",
+		tls:  TLSConfig{InsecureSkipVerify: true},
+	}, {
+		name:      "missing CA file",
+		tls:       TLSConfig{CAFile: filepath.Join(dir, "missing.pem")},
+		expectErr: true,
+	}, {
+		name:      "invalid CA file",
+		tls:       TLSConfig{CAFile: invalidCA},
+		expectErr: true,
+	}, {
+		name:      "certificate without key",
+		tls:       TLSConfig{CertFile: filepath.Join(dir, "client.pem")},
+		expectErr: true,
+	}, {
+		name:      "key without certificate",
+		tls:       TLSConfig{KeyFile: filepath.Join(dir, "client.key")},
+		expectErr: true,
+	}}
+
+	for i := range cases {
+		tcase := &cases[i]
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+			cfg, err := tcase.tls.load()
+			if tcase.expectErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tcase.tls.InsecureSkipVerify, cfg.InsecureSkipVerify)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package nfs
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/LINBIT/linstor-gateway/pkg/common"
+)
+
+// DefaultExportOptions are the exportfs options used for the clients in
+// AllowedIPs.
+const DefaultExportOptions = "rw,all_squash,anonuid=0,anongid=0"
+
+// ExportClient grants the NFS clients in an IP range access to an export with
+// their own exportfs options, e.g. read-only access for one subnet.
+type ExportClient struct {
+	IPs common.IpCidr `json:"ips"`
+	// Options is a comma separated list of exportfs options, e.g.
+	// "ro,root_squash".
+	Options string `json:"options"`
+}
+
+// exportOptionRegexp matches a single exportfs option, e.g. "ro" or
+// "anonuid=0".
+var exportOptionRegexp = regexp.MustCompile(`^[a-z_]+(=[A-Za-z0-9_.:/@-]+)?$`)
+
+// contradictoryExportOptions maps exportfs options to the option that
+// reverses them.
+var contradictoryExportOptions = map[string]string{
+	"ro":            "rw",
+	"sync":          "async",
+	"secure":        "insecure",
+	"wdelay":        "no_wdelay",
+	"root_squash":   "no_root_squash",
+	"all_squash":    "no_all_squash",
+	"subtree_check": "no_subtree_check",
+}
+
+// validExportOptions checks the exportfs options of a client.
+func validExportOptions(options string) error {
+	if options == "" {
+		return common.ValidationError("missing export options")
+	}
+
+	seen := make(map[string]struct{})
+	for _, option := range strings.Split(options, ",") {
+		if !exportOptionRegexp.MatchString(option) {
+			return common.ValidationError(fmt.Sprintf("invalid export option %q", option))
+		}
+
+		name, _, _ := strings.Cut(option, "=")
+		if name == "fsid" {
+			return common.ValidationError("the fsid can not be set as an export option, it is set per volume")
+		}
+
+		if _, ok := seen[name]; ok {
+			return common.ValidationError(fmt.Sprintf("export option %s is set more than once", name))
+		}
+		seen[name] = struct{}{}
+	}
+
+	for option, opposite := range contradictoryExportOptions {
+		_, hasOption := seen[option]
+		_, hasOpposite := seen[opposite]
+		if hasOption && hasOpposite {
+			return common.ValidationError(fmt.Sprintf("export options %s and %s contradict each other", option, opposite))
+		}
+	}
+
+	return nil
+}
+
+// validClients checks that every client IP range is only given once, either
+// in allowedIPs or in clients, so that its export options are unambiguous.
+func validClients(allowedIPs []common.IpCidr, clients []ExportClient) error {
+	seen := make(map[string]struct{})
+	for i := range allowedIPs {
+		seen[allowedIPs[i].String()] = struct{}{}
+	}
+
+	for i := range clients {
+		if clients[i].IPs.IP() == nil || clients[i].IPs.Mask == nil {
+			return common.ValidationError("missing client ip or prefix length")
+		}
+
+		ips := clients[i].IPs.String()
+		if _, ok := seen[ips]; ok {
+			return common.ValidationError(fmt.Sprintf("client %s is listed more than once", ips))
+		}
+		seen[ips] = struct{}{}
+
+		err := validExportOptions(clients[i].Options)
+		if err != nil {
+			return common.ValidationError(fmt.Sprintf("client %s: %v", ips, err))
+		}
+	}
+
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/LINBIT/golinstor/client"
@@ -29,6 +30,12 @@ func New(controllers []string) (*NFS, error) {
 	return &NFS{cli}, nil
 }
 
+// NewWithClient creates a new NFS instance that uses an existing LINSTOR
+// client.
+func NewWithClient(cli *linstorcontrol.Linstor) *NFS {
+	return &NFS{cli}
+}
+
 func (n *NFS) Get(ctx context.Context, name string) (*ResourceConfig, error) {
 	cfg, path, err := reactor.FindConfig(ctx, n.cli.Client, fmt.Sprintf(IDFormat, name))
 	if err != nil {
@@ -36,7 +43,7 @@ func (n *NFS) Get(ctx context.Context, name string) (*ResourceConfig, error) {
 	}
 
 	if cfg == nil {
-		return nil, nil
+		return nil, fmt.Errorf("export \"%s\" %w", name, common.ErrNotFound)
 	}
 
 	resourceDefinition, resourceGroup, volumeDefinitions, resources, err := cfg.DeployedResources(ctx, n.cli.Client)
@@ -44,12 +51,20 @@ func (n *NFS) Get(ctx context.Context, name string) (*ResourceConfig, error) {
 		return nil, fmt.Errorf("failed to fetch existing deployment: %w", err)
 	}
 
+	inconsistent := common.CheckVolumeCount(cfg, volumeDefinitions, volumeAgentTypes...)
+
 	deployedCfg, err := FromPromoter(cfg, resourceDefinition, volumeDefinitions)
 	if err != nil {
+		if inconsistent != nil {
+			return nil, fmt.Errorf("export \"%s\" is %w", name, inconsistent)
+		}
 		return nil, fmt.Errorf("unknown existing reactor config: %w", err)
 	}
 
 	deployedCfg.Status = linstorcontrol.StatusFromResources(path, resourceDefinition, resourceGroup, resources)
+	if inconsistent != nil {
+		deployedCfg.Status.MarkInconsistent(inconsistent)
+	}
 
 	return deployedCfg, nil
 }
@@ -57,9 +72,30 @@ func (n *NFS) Get(ctx context.Context, name string) (*ResourceConfig, error) {
 // Create creates an NFS export according to the resource configuration
 // described in rsc. It automatically prepends a "cluster private volume" to the
 // list of volumes, so volume numbers must start at 1.
-func (n *NFS) Create(ctx context.Context, rsc *ResourceConfig) (*ResourceConfig, error) {
+//
+// If a later step fails after the LINSTOR resource was created, the resource
+// is deleted again, unless opts.KeepOnFailure is set.
+//
+// If the export already exists with a different config, an error is returned,
+// unless opts.Overwrite is set. In that case, the existing export is changed to
+// match rsc.
+//
+// Before a new resource is created, the storage pools of the resource group
+// are checked for enough free space, unless opts.SkipCapacityCheck is set. A
+// new export is started, unless opts.NoStart is set.
+//
+// Only one NFS server can run per node, so Create refuses to add a second
+// export, unless opts.SkipCollisionCheck is set.
+func (n *NFS) Create(ctx context.Context, rsc *ResourceConfig, opts common.CreateOptions) (*ResourceConfig, error) {
 	rsc.FillDefaults()
 
+	for _, vol := range rsc.Volumes {
+		err := common.ValidUserVolumeNumber(vol.Number)
+		if err != nil {
+			return nil, fmt.Errorf("validation failed: %w", err)
+		}
+	}
+
 	// prepend cluster private volume; it should always be the first volume and have number 0
 	rsc.Volumes = append([]VolumeConfig{{VolumeConfig: common.ClusterPrivateVolume()}}, rsc.Volumes...)
 
@@ -68,24 +104,42 @@ func (n *NFS) Create(ctx context.Context, rsc *ResourceConfig) (*ResourceConfig,
 		return nil, fmt.Errorf("validation failed: %w", err)
 	}
 
+	// waiting for the sync outlives the locks, which cancel their context
+	// when they are released
+	syncCtx := ctx
+
+	ctx, unlock, err := n.cli.Lock(ctx, rsc.Name)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	// Cleanup locks the LINSTOR resource, so that it does not delete it
+	// before the config is written.
+	ctx, unlockResource, err := n.cli.Lock(ctx, rsc.linstorResourceName())
+	if err != nil {
+		return nil, err
+	}
+	defer unlockResource()
+
 	configs, _, err := reactor.ListConfigs(ctx, n.cli.Client)
 	if err != nil {
 		return nil, fmt.Errorf("failed to check for existing NFS configs: %w", err)
 	}
 
-	for _, c := range configs {
-		if c.ID == rsc.ID() {
-			continue
-		}
-		for _, r := range c.Resources {
-			for _, s := range r.Start {
-				if agent, ok := s.(*reactor.ResourceAgent); ok {
-					if agent.Type == "ocf:heartbeat:nfsserver" {
-						return nil, fmt.Errorf("an NFS config with a different ID already exists: %s", c.ID)
-					}
-				}
-			}
+	if owner := resourceOwner(configs, rsc.ID(), rsc.ResourceName); owner != "" {
+		return nil, fmt.Errorf("the LINSTOR resource \"%s\" %w: it backs the export \"%s\", which was renamed from \"%s\"", rsc.ResourceName, common.ErrAlreadyExists, owner, rsc.ResourceName)
+	}
+
+	if other := otherNFSConfig(configs, rsc.ID()); other != "" {
+		if !opts.SkipCollisionCheck {
+			return nil, fmt.Errorf("an NFS config with a different ID %w: %s", common.ErrAlreadyExists, other)
 		}
+
+		log.WithFields(log.Fields{
+			"export": rsc.Name,
+			"other":  other,
+		}).Warn("skipping the check for other NFS exports: the exports must never run on the same node, as only one NFS server can run per node")
 	}
 
 	cfg, path, err := reactor.FindConfig(ctx, n.cli.Client, fmt.Sprintf(IDFormat, rsc.Name))
@@ -104,13 +158,17 @@ func (n *NFS) Create(ctx context.Context, rsc *ResourceConfig) (*ResourceConfig,
 			return nil, fmt.Errorf("unknown existing reactor config: %w", err)
 		}
 
+		status := linstorcontrol.StatusFromResources(path, resourceDefinition, resourceGroup, resources)
+
 		if !rsc.Matches(deployedCfg) {
-			log.Debugf("existing resource found that does not match config")
-			log.Debugf("diff: %s", cmp.Diff(deployedCfg, rsc))
-			return nil, errors.New("resource already exists with incompatible config")
+			if !opts.Overwrite {
+				return nil, common.IncompatibleConfigError(deployedCfg, rsc)
+			}
+
+			return n.overwrite(ctx, deployedCfg, rsc, status)
 		}
 
-		deployedCfg.Status = linstorcontrol.StatusFromResources(path, resourceDefinition, resourceGroup, resources)
+		deployedCfg.Status = status
 
 		return deployedCfg, nil
 	}
@@ -120,16 +178,53 @@ func (n *NFS) Create(ctx context.Context, rsc *ResourceConfig) (*ResourceConfig,
 		volumes[i] = rsc.Volumes[i].VolumeConfig
 	}
 
+	err = n.cli.CheckResourceGroup(ctx, rsc.ResourceGroup)
+	if err != nil {
+		return nil, err
+	}
+
+	if !opts.SkipCapacityCheck {
+		err = n.cli.CheckCapacity(ctx, rsc.ResourceGroup, rsc.PlacementCount, rsc.LayerList, rsc.StoragePool, volumes)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	resourceDefinition, resourceGroup, deployment, err := n.cli.EnsureResource(ctx, linstorcontrol.Resource{
-		Name:          rsc.Name,
-		ResourceGroup: rsc.ResourceGroup,
-		Volumes:       volumes,
-		GrossSize:     rsc.GrossSize,
+		Name:           rsc.ResourceName,
+		ResourceGroup:  rsc.ResourceGroup,
+		Volumes:        volumes,
+		GrossSize:      rsc.GrossSize,
+		PlacementCount: rsc.PlacementCount,
+		LayerList:      rsc.LayerList,
+		StoragePool:    rsc.StoragePool,
+		DrbdPort:       rsc.DrbdPort,
+		DrbdMinor:      rsc.DrbdMinor,
+		Labels:         rsc.Labels,
+		Description:    rsc.Description,
+		DrbdOptions:    rsc.DrbdOptions,
 	}, false)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create linstor resource: %w", err)
 	}
 
+	// Roll back everything created so far if one of the following steps
+	// fails, so that no orphaned LINSTOR resource is left behind.
+	success := false
+	defer func() {
+		if success || opts.KeepOnFailure {
+			return
+		}
+		// Roll back even if the operation was cancelled.
+		ctx, cancel := context.WithTimeout(context.Background(), common.RollbackTimeout)
+		defer cancel()
+		log.WithField("export", rsc.Name).Info("rolling back partially created export")
+		err := n.delete(ctx, rsc.Name, rsc.ResourceName, common.DeleteOptions{})
+		if err != nil {
+			log.WithError(err).Warnf("failed to roll back export \"%s\"", rsc.Name)
+		}
+	}()
+
 	cfg, err = rsc.ToPromoter(deployment)
 	if err != nil {
 		return nil, fmt.Errorf("failed to convert resource to promoter configuration: %w", err)
@@ -140,9 +235,25 @@ func (n *NFS) Create(ctx context.Context, rsc *ResourceConfig) (*ResourceConfig,
 		return nil, fmt.Errorf("failed to register reactor config file: %w", err)
 	}
 
-	_, err = n.Start(ctx, rsc.Name)
-	if err != nil {
-		return nil, fmt.Errorf("failed to start resources: %w", err)
+	if !opts.NoStart {
+		_, err = n.Start(ctx, rsc.Name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to start resources: %w", err)
+		}
+	}
+
+	success = true
+
+	if opts.WaitSync {
+		// The initial sync can take hours, don't block other operations
+		// on the new resource meanwhile.
+		unlockResource()
+		unlock()
+
+		deployment, err = n.cli.WaitSynced(syncCtx, rsc.linstorResourceName(), resourceDefinition, resourceGroup, opts.SyncTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("the export was created, but %w", err)
+		}
 	}
 
 	rsc.Status = linstorcontrol.StatusFromResources(path, resourceDefinition, resourceGroup, deployment)
@@ -150,14 +261,141 @@ func (n *NFS) Create(ctx context.Context, rsc *ResourceConfig) (*ResourceConfig,
 	return rsc, nil
 }
 
+// otherNFSConfig returns the ID of an NFS config other than id among configs,
+// or "" if there is none.
+func otherNFSConfig(configs []reactor.PromoterConfig, id string) string {
+	for _, c := range configs {
+		if c.ID == id {
+			continue
+		}
+		for _, r := range c.Resources {
+			for _, s := range r.Start {
+				if agent, ok := s.(*reactor.ResourceAgent); ok {
+					if agent.Type == "ocf:heartbeat:nfsserver" {
+						return c.ID
+					}
+				}
+			}
+		}
+	}
+
+	return ""
+}
+
+// resourceOwner returns the name of the export other than the one with the
+// given ID that is backed by the LINSTOR resource name, or "" if there is
+// none. Renamed exports keep the LINSTOR resource of their old name.
+func resourceOwner(configs []reactor.PromoterConfig, id, name string) string {
+	prefix := strings.TrimSuffix(IDFormat, "%s")
+	for i := range configs {
+		if configs[i].ID == id || !strings.HasPrefix(configs[i].ID, prefix) || configs[i].ResourceName() != name {
+			continue
+		}
+
+		return strings.TrimPrefix(configs[i].ID, prefix)
+	}
+
+	return ""
+}
+
+// overwrite changes the deployed export to match rsc. A running export is
+// stopped while its config is replaced, and started again afterwards.
+func (n *NFS) overwrite(ctx context.Context, deployedCfg, rsc *ResourceConfig, status common.ResourceStatus) (*ResourceConfig, error) {
+	// a renamed export keeps the LINSTOR resource it was created with
+	rsc.ResourceName = deployedCfg.ResourceName
+
+	oldVolumes := make([]common.VolumeConfig, len(deployedCfg.Volumes))
+	for i := range deployedCfg.Volumes {
+		oldVolumes[i] = deployedCfg.Volumes[i].VolumeConfig
+	}
+
+	volumes := make([]common.VolumeConfig, len(rsc.Volumes))
+	for i := range rsc.Volumes {
+		volumes[i] = rsc.Volumes[i].VolumeConfig
+	}
+
+	err := common.CheckOverwrite(deployedCfg.ResourceGroup, rsc.ResourceGroup, oldVolumes, volumes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to overwrite existing export: %w", err)
+	}
+
+	err = common.CheckCreateOnly(deployedCfg.createOnlySettings(), rsc.createOnlySettings())
+	if err != nil {
+		return nil, fmt.Errorf("failed to overwrite existing export: %w", err)
+	}
+
+	log.WithField("export", rsc.Name).Infof("overwriting existing export, diff: %s", cmp.Diff(deployedCfg, rsc, common.IgnoreSecrets))
+
+	started := status.Service.Active()
+	if started {
+		_, err = n.Stop(ctx, rsc.Name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to stop export: %w", err)
+		}
+	}
+
+	_, _, deployment, err := n.cli.EnsureResource(ctx, linstorcontrol.Resource{
+		Name:           rsc.ResourceName,
+		ResourceGroup:  rsc.ResourceGroup,
+		Volumes:        volumes,
+		GrossSize:      rsc.GrossSize,
+		PlacementCount: rsc.PlacementCount,
+		LayerList:      rsc.LayerList,
+		StoragePool:    rsc.StoragePool,
+	}, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reconcile linstor resource: %w", err)
+	}
+
+	if rsc.Description != deployedCfg.Description {
+		err = n.cli.SetDescription(ctx, rsc.ResourceName, rsc.Description)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	cfg, err := rsc.ToPromoter(deployment)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert resource to promoter configuration: %w", err)
+	}
+
+	err = reactor.EnsureConfig(ctx, n.cli.Client, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update config: %w", err)
+	}
+
+	if started {
+		return n.Start(ctx, rsc.Name)
+	}
+
+	return n.Get(ctx, rsc.Name)
+}
+
 func (n *NFS) Start(ctx context.Context, name string) (*ResourceConfig, error) {
-	cfg, _, err := reactor.FindConfig(ctx, n.cli.Client, fmt.Sprintf(IDFormat, name))
+	ctx, unlock, err := n.cli.Lock(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	cfg, path, err := reactor.FindConfig(ctx, n.cli.Client, fmt.Sprintf(IDFormat, name))
 	if err != nil {
 		return nil, fmt.Errorf("failed to find the resource configuration: %w", err)
 	}
 
 	if cfg == nil {
-		return nil, nil
+		return nil, fmt.Errorf("export \"%s\" %w", name, common.ErrNotFound)
+	}
+
+	resourceDefinition, resourceGroup, _, resources, err := cfg.DeployedResources(ctx, n.cli.Client)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch existing deployment: %w", err)
+	}
+
+	status := linstorcontrol.StatusFromResources(path, resourceDefinition, resourceGroup, resources)
+	if status.Running() {
+		log.WithField("export", name).Debug("export is already started")
+		return n.Get(ctx, name)
 	}
 
 	err = reactor.AttachConfig(ctx, n.cli.Client, cfg)
@@ -165,25 +403,45 @@ func (n *NFS) Start(ctx context.Context, name string) (*ResourceConfig, error) {
 		return nil, fmt.Errorf("failed to attach reactor configuration: %w", err)
 	}
 
-	waitCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
-	defer cancel()
-
-	err = common.WaitUntilResourceCondition(waitCtx, n.cli.Client, name, common.AnyResourcesInUse)
+	err = linstorcontrol.WaitForStart(ctx, linstorcontrol.DefaultStartWait, name, func(ctx context.Context) (common.ResourceStatus, error) {
+		resourceDefinition, resourceGroup, _, resources, err := cfg.DeployedResources(ctx, n.cli.Client)
+		if err != nil {
+			return common.ResourceStatus{}, err
+		}
+		return linstorcontrol.StatusFromResources(path, resourceDefinition, resourceGroup, resources), nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("error waiting for resource to become used: %w", err)
+		return nil, err
 	}
 
 	return n.Get(ctx, name)
 }
 
 func (n *NFS) Stop(ctx context.Context, name string) (*ResourceConfig, error) {
-	cfg, _, err := reactor.FindConfig(ctx, n.cli.Client, fmt.Sprintf(IDFormat, name))
+	ctx, unlock, err := n.cli.Lock(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	cfg, path, err := reactor.FindConfig(ctx, n.cli.Client, fmt.Sprintf(IDFormat, name))
 	if err != nil {
 		return nil, fmt.Errorf("failed to find the resource configuration: %w", err)
 	}
 
 	if cfg == nil {
-		return nil, nil
+		return nil, fmt.Errorf("export \"%s\" %w", name, common.ErrNotFound)
+	}
+
+	resourceDefinition, resourceGroup, _, resources, err := cfg.DeployedResources(ctx, n.cli.Client)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch existing deployment: %w", err)
+	}
+
+	status := linstorcontrol.StatusFromResources(path, resourceDefinition, resourceGroup, resources)
+	if status.Stopped() {
+		log.WithField("export", name).Debug("export is already stopped")
+		return n.Get(ctx, name)
 	}
 
 	err = reactor.DetachConfig(ctx, n.cli.Client, cfg)
@@ -191,10 +449,7 @@ func (n *NFS) Stop(ctx context.Context, name string) (*ResourceConfig, error) {
 		return nil, fmt.Errorf("failed to detach reactor configuration: %w", err)
 	}
 
-	waitCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
-	defer cancel()
-
-	err = common.WaitUntilResourceCondition(waitCtx, n.cli.Client, name, common.NoResourcesInUse)
+	err = common.WaitUntilResourceCondition(ctx, n.cli.Client, cfg.ResourceName(), common.DefaultWaitTimeout, common.NoResourcesInUse)
 	if err != nil {
 		return nil, fmt.Errorf("error waiting for resource to become unused: %w", err)
 	}
@@ -202,8 +457,21 @@ func (n *NFS) Stop(ctx context.Context, name string) (*ResourceConfig, error) {
 	return n.Get(ctx, name)
 }
 
+// List returns all exports.
 func (n *NFS) List(ctx context.Context) ([]*ResourceConfig, error) {
-	cfgs, paths, err := reactor.ListConfigs(ctx, n.cli.Client)
+	return n.ListPage(ctx, 0, 0)
+}
+
+// ListPage returns the exports, ordered by their drbd-reactor config file,
+// skipping the first offset ones and returning at most limit. A limit of 0
+// returns all remaining exports. The deployed resources are only fetched for
+// the returned exports.
+func (n *NFS) ListPage(ctx context.Context, offset, limit int) ([]*ResourceConfig, error) {
+	cfgs, paths, err := reactor.ListConfigsPage(ctx, n.cli.Client, reactor.ListOptions{
+		IDPrefix: strings.TrimSuffix(IDFormat, "%s"),
+		Offset:   offset,
+		Limit:    limit,
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -225,13 +493,22 @@ func (n *NFS) List(ctx context.Context) ([]*ResourceConfig, error) {
 			log.WithError(err).Warn("failed to fetch deployed resources")
 		}
 
+		inconsistent := common.CheckVolumeCount(cfg, volumeDefinitions, volumeAgentTypes...)
+
 		parsed, err := FromPromoter(cfg, resourceDefinition, volumeDefinitions)
 		if err != nil {
+			if inconsistent != nil {
+				log.WithField("id", cfg.ID).Warnf("skipping export that is %s", inconsistent)
+				continue
+			}
 			log.WithError(err).Warn("skipping error while parsing promoter config")
 			continue
 		}
 
 		parsed.Status = linstorcontrol.StatusFromResources(path, resourceDefinition, resourceGroup, resources)
+		if inconsistent != nil {
+			parsed.Status.MarkInconsistent(inconsistent)
+		}
 
 		result = append(result, parsed)
 	}
@@ -239,21 +516,66 @@ func (n *NFS) List(ctx context.Context) ([]*ResourceConfig, error) {
 	return result, nil
 }
 
-func (n *NFS) Delete(ctx context.Context, name string) error {
+// Watch polls the NFS exports every interval and emits an event whenever
+// one of them changes its state. The returned channel is closed when ctx is
+// cancelled.
+func (n *NFS) Watch(ctx context.Context, interval time.Duration) (<-chan common.Event, error) {
+	return common.Watch(ctx, "nfs", interval, func(ctx context.Context) (map[string]common.ResourceStatus, error) {
+		exports, err := n.List(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		result := make(map[string]common.ResourceStatus, len(exports))
+		for _, export := range exports {
+			result[export.Name] = export.Status
+		}
+		return result, nil
+	})
+}
+
+// Delete removes an export: its drbd-reactor config and, unless
+// opts.KeepResource is set, its LINSTOR resource.
+func (n *NFS) Delete(ctx context.Context, name string, opts common.DeleteOptions) error {
+	ctx, unlock, err := n.cli.Lock(ctx, name)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	cfg, _, err := reactor.FindConfig(ctx, n.cli.Client, fmt.Sprintf(IDFormat, name))
+	if err != nil {
+		return fmt.Errorf("failed to check for existing config: %w", err)
+	}
+
+	// a renamed export is still backed by the original resource
+	rscName := name
+	if cfg != nil && cfg.ResourceName() != "" {
+		rscName = cfg.ResourceName()
+	}
+
+	return n.delete(ctx, name, rscName, opts)
+}
+
+// delete removes the reactor config of the export with the given name and,
+// unless opts.KeepResource is set, the LINSTOR resource backing it.
+func (n *NFS) delete(ctx context.Context, name, rscName string, opts common.DeleteOptions) error {
 	err := reactor.DeleteConfig(ctx, n.cli.Client, fmt.Sprintf(IDFormat, name))
 	if err != nil {
 		return fmt.Errorf("failed to delete reactor config: %w", err)
 	}
 
-	waitCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
-	defer cancel()
-
-	err = common.WaitUntilResourceCondition(waitCtx, n.cli.Client, name, common.NoResourcesInUse)
+	err = common.WaitUntilResourceCondition(ctx, n.cli.Client, rscName, common.DefaultWaitTimeout, common.NoResourcesInUse)
 	if err != nil {
 		return fmt.Errorf("error waiting for resource to become unused: %w", err)
 	}
 
-	err = n.cli.ResourceDefinitions.Delete(ctx, name)
+	if opts.KeepResource {
+		log.WithField("resource", rscName).Info("keeping LINSTOR resource")
+		return n.cli.MarkKept(ctx, rscName)
+	}
+
+	err = n.cli.ResourceDefinitions.Delete(ctx, rscName)
 	if err != nil && err != client.NotFoundError {
 		return fmt.Errorf("failed to delete resources: %w", err)
 	}
@@ -261,14 +583,245 @@ func (n *NFS) Delete(ctx context.Context, name string) error {
 	return nil
 }
 
+// SetDescription changes the description of an existing export. An empty
+// description removes it.
+func (n *NFS) SetDescription(ctx context.Context, name string, description string) (*ResourceConfig, error) {
+	err := common.ValidDescription(description)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, unlock, err := n.cli.Lock(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	cfg, _, err := reactor.FindConfig(ctx, n.cli.Client, fmt.Sprintf(IDFormat, name))
+	if err != nil {
+		return nil, fmt.Errorf("failed to check for existing config: %w", err)
+	}
+
+	if cfg == nil {
+		return nil, fmt.Errorf("export \"%s\" %w", name, common.ErrNotFound)
+	}
+
+	err = n.cli.SetDescription(ctx, cfg.ResourceName(), description)
+	if err != nil {
+		return nil, err
+	}
+
+	return n.Get(ctx, name)
+}
+
+// Rename changes the name of an NFS export. The export needs to be stopped.
+//
+// LINSTOR does not support renaming a resource definition, so the renamed
+// export keeps using the original LINSTOR resource. Only the drbd-reactor
+// configuration is replaced by one with the new ID, which also changes the
+// export paths. While the renamed export exists, Create refuses to create a
+// new export with the old name.
+func (n *NFS) Rename(ctx context.Context, oldName, newName string) (*ResourceConfig, error) {
+	err := ValidName(newName)
+	if err != nil {
+		return nil, err
+	}
+
+	if oldName == newName {
+		return nil, errors.New("new name is the same as the old name")
+	}
+
+	ctx, unlockOld, err := n.cli.Lock(ctx, oldName)
+	if err != nil {
+		return nil, err
+	}
+	defer unlockOld()
+
+	ctx, unlockNew, err := n.cli.Lock(ctx, newName)
+	if err != nil {
+		return nil, err
+	}
+	defer unlockNew()
+
+	cfg, path, err := reactor.FindConfig(ctx, n.cli.Client, fmt.Sprintf(IDFormat, oldName))
+	if err != nil {
+		return nil, fmt.Errorf("failed to check for existing config: %w", err)
+	}
+
+	if cfg == nil {
+		return nil, fmt.Errorf("export \"%s\" %w", oldName, common.ErrNotFound)
+	}
+
+	existing, _, err := reactor.FindConfig(ctx, n.cli.Client, fmt.Sprintf(IDFormat, newName))
+	if err != nil {
+		return nil, fmt.Errorf("failed to check for existing config: %w", err)
+	}
+
+	if existing != nil {
+		return nil, fmt.Errorf("export \"%s\" %w", newName, common.ErrAlreadyExists)
+	}
+
+	resourceDefinition, resourceGroup, volumeDefinitions, resources, err := cfg.DeployedResources(ctx, n.cli.Client)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch existing deployment: %w", err)
+	}
+
+	rscCfg, err := FromPromoter(cfg, resourceDefinition, volumeDefinitions)
+	if err != nil {
+		return nil, fmt.Errorf("unknown existing reactor config: %w", err)
+	}
+
+	status := linstorcontrol.StatusFromResources(path, resourceDefinition, resourceGroup, resources)
+	if status.Service.Active() {
+		return nil, errors.New("cannot rename export while service is running")
+	}
+
+	rscCfg.Name = newName
+	newCfg, err := rscCfg.ToPromoter(resources)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert resource to promoter configuration: %w", err)
+	}
+
+	err = reactor.EnsureConfig(ctx, n.cli.Client, newCfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to register reactor config file: %w", err)
+	}
+
+	err = reactor.DeleteConfig(ctx, n.cli.Client, cfg.ID)
+	if err != nil {
+		cleanupErr := reactor.DeleteConfig(ctx, n.cli.Client, newCfg.ID)
+		if cleanupErr != nil {
+			log.WithError(cleanupErr).Warnf("failed to clean up new reactor config %s", newCfg.ID)
+		}
+		return nil, fmt.Errorf("failed to delete old reactor config: %w", err)
+	}
+
+	return n.Get(ctx, newName)
+}
+
+// AddVolume adds a volume to an existing export, exporting it at the export
+// path of volCfg. The export needs to be stopped. Volume 0 is reserved for the
+// cluster private volume, so volume numbers start at 1.
+func (n *NFS) AddVolume(ctx context.Context, name string, volCfg *VolumeConfig) (*ResourceConfig, error) {
+	ctx, unlock, err := n.cli.Lock(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	if volCfg.Number < 1 {
+		return nil, common.ValidationError(fmt.Sprintf("volume number must be at least 1, is %d", volCfg.Number))
+	}
+
+	cfg, path, err := reactor.FindConfig(ctx, n.cli.Client, fmt.Sprintf(IDFormat, name))
+	if err != nil {
+		return nil, fmt.Errorf("failed to check for existing config: %w", err)
+	}
+
+	if cfg == nil {
+		return nil, fmt.Errorf("export \"%s\" %w", name, common.ErrNotFound)
+	}
+
+	resourceDefinition, resourceGroup, volumeDefinitions, resources, err := cfg.DeployedResources(ctx, n.cli.Client)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch existing deployment: %w", err)
+	}
+
+	deployedCfg, err := FromPromoter(cfg, resourceDefinition, volumeDefinitions)
+	if err != nil {
+		return nil, fmt.Errorf("unknown existing reactor config: %w", err)
+	}
+
+	err = common.ValidGrossSize(volCfg.VolumeConfig, deployedCfg.GrossSize)
+	if err != nil {
+		return nil, err
+	}
+	// the volume is created with the size semantics of the export
+	volCfg.GrossSize = nil
+
+	volCfg.ExportPath = rootedPath(volCfg.ExportPath)
+
+	for i := range deployedCfg.Volumes {
+		if deployedCfg.Volumes[i].Number == volCfg.Number {
+			if deployedCfg.Volumes[i].SizeKiB != volCfg.SizeKiB {
+				return nil, errors.New(fmt.Sprintf("existing volume has differing size %d != %d", deployedCfg.Volumes[i].SizeKiB, volCfg.SizeKiB))
+			}
+
+			if deployedCfg.Volumes[i].ExportPath != volCfg.ExportPath {
+				return nil, errors.New(fmt.Sprintf("existing volume has differing export path \"%s\" != \"%s\"", deployedCfg.Volumes[i].ExportPath, volCfg.ExportPath))
+			}
+
+			deployedCfg.Status = linstorcontrol.StatusFromResources(path, resourceDefinition, resourceGroup, resources)
+
+			return deployedCfg, nil
+		}
+	}
+
+	status := linstorcontrol.StatusFromResources(path, resourceDefinition, resourceGroup, resources)
+	// The new file system has to be mounted before the nfsserver agent is
+	// started, which only happens when the export is started.
+	if status.Service.Active() {
+		return nil, errors.New("cannot add volume while service is running")
+	}
+
+	deployedCfg.Volumes = append(deployedCfg.Volumes, *volCfg)
+
+	err = deployedCfg.Valid()
+	if err != nil {
+		return nil, fmt.Errorf("validation failed: %w", err)
+	}
+
+	volumes := make([]common.VolumeConfig, len(deployedCfg.Volumes))
+	for i := range deployedCfg.Volumes {
+		volumes[i] = deployedCfg.Volumes[i].VolumeConfig
+	}
+
+	resourceDefinition, resourceGroup, resources, err = n.cli.EnsureResource(ctx, linstorcontrol.Resource{
+		Name:           deployedCfg.ResourceName,
+		ResourceGroup:  deployedCfg.ResourceGroup,
+		Volumes:        volumes,
+		GrossSize:      deployedCfg.GrossSize,
+		PlacementCount: deployedCfg.PlacementCount,
+		LayerList:      deployedCfg.LayerList,
+		StoragePool:    deployedCfg.StoragePool,
+	}, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reconcile linstor resource: %w", err)
+	}
+
+	cfg, err = deployedCfg.ToPromoter(resources)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert resource to promoter configuration: %w", err)
+	}
+
+	err = reactor.EnsureConfig(ctx, n.cli.Client, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update config: %w", err)
+	}
+
+	deployedCfg.Status = linstorcontrol.StatusFromResources(path, resourceDefinition, resourceGroup, resources)
+
+	return deployedCfg, nil
+}
+
 func (n *NFS) DeleteVolume(ctx context.Context, name string, lun int) (*ResourceConfig, error) {
+	if lun < 1 {
+		return nil, common.ValidationError("the cluster private volume can not be deleted")
+	}
+
+	ctx, unlock, err := n.cli.Lock(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
 	cfg, path, err := reactor.FindConfig(ctx, n.cli.Client, fmt.Sprintf(IDFormat, name))
 	if err != nil {
 		return nil, fmt.Errorf("failed to delete reactor config: %w", err)
 	}
 
 	if cfg == nil {
-		return nil, nil
+		return nil, fmt.Errorf("export \"%s\" %w", name, common.ErrNotFound)
 	}
 
 	resourceDefinition, resourceGroup, volumeDefinition, resources, err := cfg.DeployedResources(ctx, n.cli.Client)
@@ -282,22 +835,20 @@ func (n *NFS) DeleteVolume(ctx context.Context, name string, lun int) (*Resource
 	}
 
 	status := linstorcontrol.StatusFromResources(path, resourceDefinition, resourceGroup, resources)
-	if status.Service == common.ServiceStateStarted {
+	if status.Service.Active() {
 		return nil, errors.New("cannot delete volume while service is running")
 	}
 
 	for i := range rscCfg.Volumes {
 		if rscCfg.Volumes[i].Number == lun {
-			err = n.cli.ResourceDefinitions.DeleteVolumeDefinition(ctx, name, lun)
+			err = n.cli.ResourceDefinitions.DeleteVolumeDefinition(ctx, rscCfg.ResourceName, lun)
 			if err != nil && err != client.NotFoundError {
 				return nil, fmt.Errorf("failed to delete volume definition")
 			}
 
 			rscCfg.Volumes = append(rscCfg.Volumes[:i], rscCfg.Volumes[i+1:]...)
 			// Manually delete the resources from the current resource config
-			for j := range resources {
-				resources[j].Volumes = append(resources[j].Volumes[:i], resources[j].Volumes[i+1:]...)
-			}
+			common.RemoveVolume(resources, lun)
 
 			cfg, err = rscCfg.ToPromoter(resources)
 			if err != nil {
@@ -0,0 +1,62 @@
+package nfs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/LINBIT/linstor-gateway/pkg/reactor"
+)
+
+func TestOtherNFSConfig(t *testing.T) {
+	t.Parallel()
+
+	nfsConfig := func(id string) reactor.PromoterConfig {
+		return reactor.PromoterConfig{ID: id, Resources: map[string]reactor.PromoterResourceConfig{
+			"rsc": {Start: []reactor.StartEntry{&reactor.ResourceAgent{Type: "ocf:heartbeat:nfsserver"}}},
+		}}
+	}
+	iscsiConfig := reactor.PromoterConfig{ID: "iscsi-example", Resources: map[string]reactor.PromoterResourceConfig{
+		"rsc": {Start: []reactor.StartEntry{&reactor.ResourceAgent{Type: "ocf:heartbeat:iSCSITarget"}}},
+	}}
+
+	cases := []struct {
+		name     string
+		configs  []reactor.PromoterConfig
+		expected string
+	}{{
+		name: "none",
+	}, {
+		name:    "same export",
+		configs: []reactor.PromoterConfig{nfsConfig("nfs-example")},
+	}, {
+		name:    "other target type",
+		configs: []reactor.PromoterConfig{iscsiConfig},
+	}, {
+		name:     "other export",
+		configs:  []reactor.PromoterConfig{iscsiConfig, nfsConfig("nfs-other")},
+		expected: "nfs-other",
+	}}
+
+	for i := range cases {
+		tcase := &cases[i]
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tcase.expected, otherNFSConfig(tcase.configs, "nfs-example"))
+		})
+	}
+}
+
+func TestResourceOwner(t *testing.T) {
+	t.Parallel()
+
+	config := func(id, resource string) reactor.PromoterConfig {
+		return reactor.PromoterConfig{ID: id, Resources: map[string]reactor.PromoterResourceConfig{resource: {}}}
+	}
+
+	assert.Equal(t, "", resourceOwner(nil, "nfs-example", "example"))
+	assert.Equal(t, "", resourceOwner([]reactor.PromoterConfig{config("nfs-example", "example")}, "nfs-example", "example"))
+	assert.Equal(t, "", resourceOwner([]reactor.PromoterConfig{config("nfs-other", "other")}, "nfs-example", "example"))
+	assert.Equal(t, "", resourceOwner([]reactor.PromoterConfig{config("iscsi-example", "example")}, "nfs-example", "example"))
+	assert.Equal(t, "renamed", resourceOwner([]reactor.PromoterConfig{config("nfs-other", "other"), config("nfs-renamed", "example")}, "nfs-example", "example"))
+}
@@ -8,6 +8,7 @@ import (
 	log "github.com/sirupsen/logrus"
 	"net"
 	"path/filepath"
+	"regexp"
 	"sort"
 	"strconv"
 	"strings"
@@ -16,9 +17,14 @@ import (
 	"github.com/google/uuid"
 
 	"github.com/LINBIT/linstor-gateway/pkg/common"
+	"github.com/LINBIT/linstor-gateway/pkg/linstorcontrol"
 	"github.com/LINBIT/linstor-gateway/pkg/reactor"
 )
 
+// volumeAgentTypes are the types of the resource agents that each configure
+// one volume of an export.
+var volumeAgentTypes = []string{"ocf:heartbeat:Filesystem"}
+
 const (
 	ExportBasePath = "/srv/gateway-exports"
 	DefaultNFSPort = 2049
@@ -36,6 +42,20 @@ var (
 type VolumeConfig struct {
 	common.VolumeConfig
 	ExportPath string `json:"export_path"`
+	// Path is the directory inside the file system of the volume that is
+	// exported. It is created when the export is started. An empty path or
+	// "/" exports the whole file system.
+	Path string `json:"path,omitempty"`
+	// FSID identifies the exported file system to NFS clients. It must be
+	// the same on all nodes, otherwise clients get stale file handles
+	// after a failover. It is either a positive 32 bit number or a UUID.
+	// If empty, a UUID derived from the resource name and the volume
+	// number is used.
+	FSID string `json:"fsid,omitempty"`
+	// MountOptions are the comma separated options the file system of the
+	// volume is mounted with, e.g. "noatime,nodiratime". If empty, the
+	// defaults of the file system are used.
+	MountOptions string `json:"mount_options,omitempty"`
 }
 
 // rootedPath returns a cleaned up path, rooted at /.
@@ -43,26 +63,202 @@ func rootedPath(path string) string {
 	return filepath.Clean(filepath.Join("/", path))
 }
 
+// MountPath returns the full path at which the file system of the volume is
+// mounted.
+func MountPath(rsc *ResourceConfig, vol *VolumeConfig) string {
+	return filepath.Join(ExportBasePath, rsc.Name, vol.ExportPath)
+}
+
 // ExportPath returns the full path under which the resource is exported.
 func ExportPath(rsc *ResourceConfig, vol *VolumeConfig) string {
-	return filepath.Join(ExportBasePath, rsc.Name, vol.ExportPath)
+	return filepath.Join(MountPath(rsc, vol), vol.Path)
+}
+
+// ExportFSID returns the fsid under which the volume is exported: the
+// configured FSID, or the default derived from the resource name.
+func ExportFSID(rsc *ResourceConfig, vol *VolumeConfig) string {
+	if vol.FSID != "" {
+		return vol.FSID
+	}
+
+	return defaultFSID(rsc.linstorResourceName(), vol.Number)
+}
+
+// defaultFSID returns a UUID that only depends on the name of the LINSTOR
+// resource and the volume number, so that it is the same on all nodes and
+// does not change when the export is renamed.
+func defaultFSID(resource string, volume int) string {
+	return uuid.NewSHA1(UuidNFS, []byte(fmt.Sprintf("%s/%d", resource, volume))).String()
+}
+
+// regexMountOption matches a single mount option, with an optional value.
+var regexMountOption = regexp.MustCompile(`^[A-Za-z0-9_-]+(=[^,\s"'=]+)?$`)
+
+// validMountOptions checks that options is a comma separated list of mount
+// options.
+func validMountOptions(options string) error {
+	if options == "" {
+		return nil
+	}
+
+	for _, option := range strings.Split(options, ",") {
+		if !regexMountOption.MatchString(option) {
+			return common.ValidationError(fmt.Sprintf("invalid mount option %q in %q: expected comma separated options like noatime or commit=60", option, options))
+		}
+	}
+
+	return nil
+}
+
+// validFSID checks that fsid can be used as the fsid option of an export.
+// fsid 0 and "root" are rejected, since they mark the root of the NFSv4
+// pseudo file system.
+func validFSID(fsid string) error {
+	if fsid == "" {
+		return nil
+	}
+
+	if n, err := strconv.ParseUint(fsid, 10, 32); err == nil {
+		if n == 0 {
+			return common.ValidationError("fsid 0 is reserved for the NFSv4 root export")
+		}
+		return nil
+	}
+
+	if _, err := uuid.Parse(fsid); err != nil {
+		return common.ValidationError(fmt.Sprintf("invalid fsid %q: must be a positive 32 bit number or a UUID", fsid))
+	}
+
+	return nil
+}
+
+// mkdirServiceFormat is the systemd template unit that creates the exported
+// directory inside a mounted volume. The instance is the escaped path.
+const mkdirServiceFormat = "linstor-gateway-mkdir@%s.service"
+
+// mkdirService returns the name of the systemd unit that creates path.
+func mkdirService(path string) string {
+	return fmt.Sprintf(mkdirServiceFormat, systemdEscapePath(path))
+}
+
+// systemdEscapePath escapes path the same way "systemd-escape --path" does,
+// so that it can be used as the instance name of a template unit.
+func systemdEscapePath(path string) string {
+	path = strings.Trim(filepath.Clean(path), "/")
+	if path == "" {
+		return "-"
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		switch {
+		case c == '/':
+			b.WriteByte('-')
+		case c == '.' && i == 0:
+			fmt.Fprintf(&b, `\x%02x`, c)
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == ':', c == '_', c == '.':
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, `\x%02x`, c)
+		}
+	}
+	return b.String()
+}
+
+// subdirectory returns the canonical form of a directory inside a volume:
+// rooted at /, or empty for the root directory itself.
+func subdirectory(path string) string {
+	path = rootedPath(path)
+	if path == "/" {
+		return ""
+	}
+	return path
+}
+
+// exportSubdirectory checks that path does not leave the file system it is
+// relative to, and returns it in its canonical form.
+func exportSubdirectory(path string) (string, error) {
+	for _, elem := range strings.Split(filepath.ToSlash(path), "/") {
+		if elem == ".." {
+			return "", common.ValidationError(fmt.Sprintf("export path %q must not leave the volume", path))
+		}
+	}
+	return subdirectory(path), nil
 }
 
 type ResourceConfig struct {
-	Name          string                `json:"name"`
-	ServiceIP     common.IpCidr         `json:"service_ip,omitempty"`
-	AllowedIPs    []common.IpCidr       `json:"allowed_ips,omitempty"`
+	Name string `json:"name"`
+	// ResourceName is the name of the LINSTOR resource backing this export.
+	// It is the same as Name, unless the export was renamed: LINSTOR does
+	// not support renaming a resource definition, so a renamed export keeps
+	// using the original resource.
+	ResourceName string `json:"resource_name,omitempty"`
+	// ServiceIP is the primary service IP of the export. It is always the
+	// first entry of ServiceIPs.
+	ServiceIP common.IpCidr `json:"service_ip,omitempty"`
+	// ServiceIPs are all IPs the export is reachable on. They are started
+	// and stopped together with the export.
+	ServiceIPs []common.IpCidr `json:"service_ips,omitempty"`
+	// AllowedIPs are the clients that may access the export with the
+	// DefaultExportOptions.
+	AllowedIPs []common.IpCidr `json:"allowed_ips,omitempty"`
+	// Clients may access the export with their own export options. Every
+	// client IP range may only be given once, either here or in
+	// AllowedIPs.
+	Clients       []ExportClient        `json:"clients,omitempty"`
 	ResourceGroup string                `json:"resource_group"`
 	Volumes       []VolumeConfig        `json:"volumes"`
 	Status        common.ResourceStatus `json:"status"`
 	GrossSize     bool                  `json:"gross_size"`
+	// PlacementCount is the number of diskful replicas of the resource, if
+	// it differs from the placement count of the resource group. Diskless
+	// resources, e.g. a tie-breaker, are not counted.
+	PlacementCount int `json:"placement_count,omitempty"`
+	// LayerList overrides the LINSTOR layers of the resource group, e.g.
+	// [DRBD STORAGE]. Without DRBD, the target is not highly available.
+	LayerList []string `json:"layer_list,omitempty"`
+	// StoragePool overrides the storage pools of the resource group, so
+	// that all diskful replicas are placed in this pool. It narrows the
+	// placement: if the pool is not present on enough nodes, the placement
+	// count of the resource group can not be satisfied.
+	StoragePool string `json:"storage_pool,omitempty"`
+	// DrbdPort is the TCP port DRBD uses for the resource, and DrbdMinor
+	// the DRBD minor number of the cluster private volume. The other
+	// volumes use DrbdMinor plus their volume number. If 0, LINSTOR
+	// assigns them. They can only be set when the resource is created.
+	DrbdPort  int `json:"drbd_port,omitempty"`
+	DrbdMinor int `json:"drbd_minor,omitempty"`
+	// Labels organize exports, e.g. by team. They can be used to select
+	// exports when listing them. They can only be set when the resource
+	// is created.
+	Labels map[string]string `json:"labels,omitempty"`
+	// Description is a free text describing the export, e.g. its purpose or
+	// owner. It is stored with the LINSTOR resource.
+	Description string `json:"description,omitempty"`
+	// DrbdOptions override DRBD options of the resource, e.g. the
+	// replication protocol or what happens when the export loses quorum.
+	// Options that are not set use the defaults. They can only be set when
+	// the resource is created.
+	DrbdOptions map[string]string `json:"drbd_options,omitempty"`
 }
 
 const (
-	fsAgentName     = "fs_%d"
-	exportAgentName = "export_%d_%d"
+	fsAgentName           = "fs_%d"
+	exportAgentName       = "export_%d_%d"
+	exportClientAgentName = "export_%d_client_%d"
 )
 
+// serviceAgentName returns the name of the agent managing the i-th service
+// IP. The first one keeps the name used before multiple service IPs were
+// supported, so that existing configurations stay unchanged.
+func serviceAgentName(base string, i int) string {
+	if i == 0 {
+		return base
+	}
+	return fmt.Sprintf("%s_%d", base, i)
+}
+
 func FromPromoter(cfg *reactor.PromoterConfig, definition *client.ResourceDefinition, volumeDefinition []client.VolumeDefinition) (*ResourceConfig, error) {
 	r := &ResourceConfig{}
 	var res string
@@ -72,7 +268,17 @@ func FromPromoter(cfg *reactor.PromoterConfig, definition *client.ResourceDefini
 	}
 
 	r.Name = res
+	r.ResourceName = cfg.ResourceName()
 	r.ResourceGroup = definition.ResourceGroupName
+	r.GrossSize = common.AnyGrossSize(volumeDefinition)
+	r.PlacementCount = linstorcontrol.PlacementCount(definition)
+	r.LayerList = linstorcontrol.LayerList(definition)
+	r.StoragePool = linstorcontrol.StoragePool(definition)
+	r.DrbdPort = linstorcontrol.DrbdPort(definition)
+	r.DrbdMinor = linstorcontrol.DrbdMinor(volumeDefinition)
+	r.Labels = linstorcontrol.Labels(definition)
+	r.Description = linstorcontrol.Description(definition)
+	r.DrbdOptions = linstorcontrol.DrbdOptions(definition)
 
 	if len(cfg.Resources) != 1 {
 		return nil, errors.New(fmt.Sprintf("promoter config without exactly 1 resource (has %d)", len(cfg.Resources)))
@@ -88,6 +294,11 @@ func FromPromoter(cfg *reactor.PromoterConfig, definition *client.ResourceDefini
 	}
 
 	var numPortblocks, numPortunblocks int
+	// exportDirs maps volume numbers to the exported directory
+	exportDirs := make(map[int]string)
+	// exportFSIDs maps volume numbers to the fsid of their export
+	exportFSIDs := make(map[int]string)
+	hasServer := false
 	for _, entry := range rscCfg.Start {
 		switch agent := entry.(type) {
 		case *reactor.ResourceAgent:
@@ -112,19 +323,43 @@ func FromPromoter(cfg *reactor.PromoterConfig, definition *client.ResourceDefini
 					return nil, err
 				}
 
-				exists := false
-				for i := range r.AllowedIPs {
-					if r.AllowedIPs[i].String() == cidr.String() {
-						exists = true
-						break
+				// every volume is exported to the same clients, so
+				// only the first export of each client is kept
+				var volNr, ipNr int
+				if n, _ := fmt.Sscanf(agent.Name, exportClientAgentName, &volNr, &ipNr); n == 2 {
+					exists := false
+					for i := range r.Clients {
+						if r.Clients[i].IPs.String() == cidr.String() {
+							exists = true
+							break
+						}
+					}
+
+					if !exists {
+						r.Clients = append(r.Clients, ExportClient{IPs: cidr, Options: agent.Attributes["options"]})
+					}
+				} else {
+					n, err := fmt.Sscanf(agent.Name, exportAgentName, &volNr, &ipNr)
+					if n != 2 {
+						return nil, fmt.Errorf("agent %s doesn't have expected name: %w", agent.Name, err)
+					}
+
+					exists := false
+					for i := range r.AllowedIPs {
+						if r.AllowedIPs[i].String() == cidr.String() {
+							exists = true
+							break
+						}
 					}
-				}
 
-				if !exists {
-					r.AllowedIPs = append(r.AllowedIPs, cidr)
+					if !exists {
+						r.AllowedIPs = append(r.AllowedIPs, cidr)
+					}
 				}
+				exportDirs[volNr] = agent.Attributes["directory"]
+				exportFSIDs[volNr] = agent.Attributes["fsid"]
 			case "ocf:heartbeat:nfsserver":
-				break
+				hasServer = true
 			case "ocf:heartbeat:IPaddr2":
 				ip := net.ParseIP(agent.Attributes["ip"])
 				if ip == nil {
@@ -136,21 +371,50 @@ func FromPromoter(cfg *reactor.PromoterConfig, definition *client.ResourceDefini
 					return nil, fmt.Errorf("failed to parse service ip prefix")
 				}
 
-				r.ServiceIP = common.ServiceIPFromParts(ip, prefixLength)
+				r.ServiceIPs = append(r.ServiceIPs, common.ServiceIPFromParts(ip, prefixLength))
 			default:
-				return nil, errors.New(fmt.Sprintf("unexpected resource agent: %s", agent.Type))
+				// e.g. added by hand, or by a newer version
+				log.WithField("agent", agent.Name).Debugf("ignoring unknown resource agent %s", agent.Type)
 			}
 		case *reactor.SystemdService:
 			// ignore systemd services for now
 		}
 	}
 
+	if !hasServer {
+		return nil, errors.New("malformed configuration: missing ocf:heartbeat:nfsserver agent")
+	}
+
+	for i := range r.Volumes {
+		dir, ok := exportDirs[r.Volumes[i].Number]
+		if !ok {
+			continue
+		}
+
+		mountPath := MountPath(r, &r.Volumes[i])
+		if dir != mountPath && !strings.HasPrefix(dir, mountPath+"/") {
+			return nil, fmt.Errorf("exported directory %s not within mount point %s", dir, mountPath)
+		}
+		r.Volumes[i].Path = subdirectory(dir[len(mountPath):])
+
+		// Only keep fsids that differ from the default, e.g. those of
+		// exports created by older versions, so that they do not change
+		// when the config is written again.
+		if fsid := exportFSIDs[r.Volumes[i].Number]; fsid != defaultFSID(r.linstorResourceName(), r.Volumes[i].Number) {
+			r.Volumes[i].FSID = fsid
+		}
+	}
+
 	if numPortblocks != numPortunblocks {
 		return nil, fmt.Errorf("malformed configuration: got a different number of portblock and portunblock agents (%d vs %d)", numPortblocks, numPortunblocks)
 	}
 
-	if numPortblocks != 1 {
-		return nil, fmt.Errorf("malformed configuration: got a different number of portblock agents (%d) than IPaddr2 agents (1)", numPortblocks)
+	if numPortblocks != len(r.ServiceIPs) {
+		return nil, fmt.Errorf("malformed configuration: got a different number of portblock agents (%d) than IPaddr2 agents (%d)", numPortblocks, len(r.ServiceIPs))
+	}
+
+	if len(r.ServiceIPs) > 0 {
+		r.ServiceIP = r.ServiceIPs[0]
 	}
 
 	return r, nil
@@ -199,8 +463,9 @@ func parseVolume(agent *reactor.ResourceAgent, volumes []client.VolumeDefinition
 				"volume":   vol.VolumeNumber,
 				"resource": resName,
 			}).Warnf("invalid MkfsParams for volume: %q", val)
+		} else {
+			rootOwner = u
 		}
-		filesystem = val
 	}
 	if vol.VolumeNumber == nil {
 		vol.VolumeNumber = gog.Ptr(int32(0))
@@ -212,7 +477,8 @@ func parseVolume(agent *reactor.ResourceAgent, volumes []client.VolumeDefinition
 			FileSystem:          filesystem,
 			FileSystemRootOwner: rootOwner,
 		},
-		ExportPath: exportPath,
+		ExportPath:   exportPath,
+		MountOptions: agent.Attributes["options"],
 	}, nil
 }
 
@@ -280,6 +546,10 @@ func (r *ResourceConfig) VolumeConfig(number int) *common.Volume {
 }
 
 func (r *ResourceConfig) FillDefaults() {
+	if r.ResourceName == "" {
+		r.ResourceName = r.Name
+	}
+
 	if r.ResourceGroup == "" {
 		r.ResourceGroup = "DfltRscGrp"
 	}
@@ -292,16 +562,45 @@ func (r *ResourceConfig) FillDefaults() {
 		r.Volumes[i].ExportPath = rootedPath(r.Volumes[i].ExportPath)
 	}
 
-	if len(r.AllowedIPs) == 0 {
+	if len(r.AllowedIPs) == 0 && len(r.Clients) == 0 {
 		r.AllowedIPs = AllowAllCidr
 	}
+
+	if len(r.ServiceIPs) == 0 && r.ServiceIP.IP() != nil {
+		r.ServiceIPs = []common.IpCidr{r.ServiceIP}
+	}
+
+	if r.ServiceIP.IP() == nil && len(r.ServiceIPs) > 0 {
+		r.ServiceIP = r.ServiceIPs[0]
+	}
 }
 
-func (r *ResourceConfig) Valid() error {
-	if len(r.Name) < 2 {
+// validName matches the names LINSTOR accepts for resource definitions.
+var validName = regexp.MustCompile(`^[[:alpha:]_][[:alnum:]_-]*$`)
+
+// ValidName checks if name can be used as the name of an NFS export.
+func ValidName(name string) error {
+	if len(name) < 2 {
 		return common.ValidationError("nfs resource name to short (min. 2)")
 	}
 
+	if len(name) > 48 {
+		return common.ValidationError("nfs resource name to long (max. 48)")
+	}
+
+	if !validName.MatchString(name) {
+		return common.ValidationError(fmt.Sprintf("nfs resource name %q must start with a letter or underscore and may only contain letters, digits, underscores, and dashes", name))
+	}
+
+	return nil
+}
+
+func (r *ResourceConfig) Valid() error {
+	err := ValidName(r.Name)
+	if err != nil {
+		return err
+	}
+
 	if r.ServiceIP.IP() == nil {
 		return common.ValidationError("missing service ip")
 	}
@@ -310,15 +609,116 @@ func (r *ResourceConfig) Valid() error {
 		return common.ValidationError("missing service ip prefix length")
 	}
 
+	err = common.ValidPlacementCount(r.PlacementCount)
+	if err != nil {
+		return err
+	}
+
+	err = common.ValidLayerList(r.LayerList)
+	if err != nil {
+		return err
+	}
+
+	err = common.ValidDrbdNumbers(r.DrbdPort, r.DrbdMinor, len(r.Volumes))
+	if err != nil {
+		return err
+	}
+
+	volumes := make([]common.VolumeConfig, 0, len(r.Volumes))
+	for i := range r.Volumes {
+		volumes = append(volumes, r.Volumes[i].VolumeConfig)
+	}
+
+	err = common.ValidExported(volumes)
+	if err != nil {
+		return err
+	}
+
+	err = common.ValidLabels(r.Labels)
+	if err != nil {
+		return err
+	}
+
+	err = common.ValidDescription(r.Description)
+	if err != nil {
+		return err
+	}
+
+	err = common.ValidDrbdOptions(r.DrbdOptions)
+	if err != nil {
+		return err
+	}
+
+	err = validClients(r.AllowedIPs, r.Clients)
+	if err != nil {
+		return err
+	}
+
+	if len(r.ServiceIPs) > 0 && r.ServiceIPs[0].String() != r.ServiceIP.String() {
+		return common.ValidationError("the service ip must be the first of the service ips")
+	}
+
+	serviceIPs := make(map[string]struct{})
+	for i := range r.ServiceIPs {
+		if r.ServiceIPs[i].IP() == nil || r.ServiceIPs[i].Mask == nil {
+			return common.ValidationError("missing service ip or prefix length")
+		}
+
+		ip := r.ServiceIPs[i].IP().String()
+		if _, ok := serviceIPs[ip]; ok {
+			return common.ValidationError(fmt.Sprintf("duplicate service ip %s", ip))
+		}
+		serviceIPs[ip] = struct{}{}
+	}
+
 	sort.Slice(r.Volumes, func(i, j int) bool {
 		return r.Volumes[i].Number < r.Volumes[j].Number
 	})
 
 	paths := make(map[string]struct{})
+	fsids := make(map[string]struct{})
 
 	for i := range r.Volumes {
 		paths[r.Volumes[i].ExportPath] = struct{}{}
 
+		if r.Volumes[i].Number == 0 && r.Volumes[i].FSID != "" {
+			return common.ValidationError("the cluster private volume can not have an fsid")
+		}
+
+		err := validFSID(r.Volumes[i].FSID)
+		if err != nil {
+			return err
+		}
+
+		if r.Volumes[i].Number == 0 && r.Volumes[i].MountOptions != "" {
+			return common.ValidationError("the cluster private volume can not have mount options")
+		}
+
+		err = validMountOptions(r.Volumes[i].MountOptions)
+		if err != nil {
+			return err
+		}
+
+		if r.Volumes[i].Number != 0 {
+			fsid := ExportFSID(r, &r.Volumes[i])
+			if _, ok := fsids[fsid]; ok {
+				return common.ValidationError(fmt.Sprintf("duplicate fsid %s", fsid))
+			}
+			fsids[fsid] = struct{}{}
+		}
+
+		if r.Volumes[i].Number == 0 && r.Volumes[i].Path != "" {
+			return common.ValidationError("the cluster private volume can not be exported")
+		}
+
+		if r.Volumes[i].Number != 0 {
+			path, err := exportSubdirectory(r.Volumes[i].Path)
+			if err != nil {
+				return err
+			}
+			r.Volumes[i].Path = path
+		}
+
 		if r.Volumes[i].Number < 0 {
 			return common.ValidationError("volume numbers must start at 1")
 		}
@@ -328,7 +728,7 @@ func (r *ResourceConfig) Valid() error {
 		}
 
 		if i > 0 && r.Volumes[i-1].Number == r.Volumes[i].Number {
-			return common.ValidationError("volume numbers must be unique")
+			return common.ValidationError(fmt.Sprintf("volume numbers must be unique, %d is used more than once", r.Volumes[i].Number))
 		}
 	}
 
@@ -339,6 +739,8 @@ func (r *ResourceConfig) Valid() error {
 	return nil
 }
 
+// Matches reports whether the deployed export o is configured as requested
+// by r. A DRBD port or minor that is not set in r matches any.
 func (r *ResourceConfig) Matches(o *ResourceConfig) bool {
 	if r.Name != o.Name {
 		return false
@@ -348,10 +750,29 @@ func (r *ResourceConfig) Matches(o *ResourceConfig) bool {
 		return false
 	}
 
+	rIPs, oIPs := r.serviceIPs(), o.serviceIPs()
+	if len(rIPs) != len(oIPs) {
+		return false
+	}
+
+	for i := range rIPs {
+		if rIPs[i].String() != oIPs[i].String() {
+			return false
+		}
+	}
+
 	if r.ResourceGroup != o.ResourceGroup {
 		return false
 	}
 
+	if common.CheckCreateOnly(o.createOnlySettings(), r.createOnlySettings()) != nil {
+		return false
+	}
+
+	if r.Description != o.Description {
+		return false
+	}
+
 	if len(r.AllowedIPs) != len(o.AllowedIPs) {
 		return false
 	}
@@ -362,6 +783,16 @@ func (r *ResourceConfig) Matches(o *ResourceConfig) bool {
 		}
 	}
 
+	if len(r.Clients) != len(o.Clients) {
+		return false
+	}
+
+	for i := range r.Clients {
+		if r.Clients[i].IPs.String() != o.Clients[i].IPs.String() || r.Clients[i].Options != o.Clients[i].Options {
+			return false
+		}
+	}
+
 	if len(r.Volumes) != len(o.Volumes) {
 		return false
 	}
@@ -378,69 +809,122 @@ func (r *ResourceConfig) Matches(o *ResourceConfig) bool {
 		if r.Volumes[i].ExportPath != o.Volumes[i].ExportPath {
 			return false
 		}
+
+		if subdirectory(r.Volumes[i].Path) != subdirectory(o.Volumes[i].Path) {
+			return false
+		}
+
+		if r.Volumes[i].MountOptions != o.Volumes[i].MountOptions {
+			return false
+		}
 	}
 
 	return true
 }
 
+// serviceIPs returns all service IPs, including ServiceIP if ServiceIPs was
+// not filled in.
+func (r *ResourceConfig) serviceIPs() []common.IpCidr {
+	if len(r.ServiceIPs) == 0 && r.ServiceIP.IP() != nil {
+		return []common.IpCidr{r.ServiceIP}
+	}
+	return r.ServiceIPs
+}
+
 func (r *ResourceConfig) ID() string {
 	return fmt.Sprintf(IDFormat, r.Name)
 }
 
+// createOnlySettings returns the settings of the export that can only be set
+// when its LINSTOR resource is created.
+func (r *ResourceConfig) createOnlySettings() common.CreateOnlySettings {
+	return common.CreateOnlySettings{
+		PlacementCount: r.PlacementCount,
+		LayerList:      r.LayerList,
+		StoragePool:    r.StoragePool,
+		DrbdPort:       r.DrbdPort,
+		DrbdMinor:      r.DrbdMinor,
+		Labels:         r.Labels,
+		DrbdOptions:    r.DrbdOptions,
+	}
+}
+
+// linstorResourceName returns the name of the LINSTOR resource backing this
+// export.
+func (r *ResourceConfig) linstorResourceName() string {
+	if r.ResourceName != "" {
+		return r.ResourceName
+	}
+	return r.Name
+}
+
 func (r *ResourceConfig) ToPromoter(deployment []client.ResourceWithVolumes) (*reactor.PromoterConfig, error) {
 	if len(deployment) == 0 {
 		return nil, errors.New("resource config is missing deployment information")
 	}
 	deployedRes := deployment[0]
 	var agents []reactor.StartEntry
-	resUuid := uuid.NewSHA1(UuidNFS, []byte(deployedRes.Uuid))
-
 	log.Debugf("volumes: %+v", deployedRes.Volumes)
 
-	agents = append(agents, &reactor.ResourceAgent{
-		Type: "ocf:heartbeat:portblock",
-		Name: "portblock",
-		Attributes: map[string]string{
-			"ip":       r.ServiceIP.IP().String(),
-			"portno":   strconv.Itoa(DefaultNFSPort),
-			"action":   "block",
-			"protocol": "tcp",
-		},
-	})
+	serviceIPs := r.serviceIPs()
+	ipStrings := make([]string, 0, len(serviceIPs))
+	for i, ip := range serviceIPs {
+		ipStrings = append(ipStrings, ip.IP().String())
+		agents = append(agents, &reactor.ResourceAgent{
+			Type: "ocf:heartbeat:portblock",
+			Name: serviceAgentName("portblock", i),
+			Attributes: map[string]string{
+				"ip":       ip.IP().String(),
+				"portno":   strconv.Itoa(DefaultNFSPort),
+				"action":   "block",
+				"protocol": "tcp",
+			},
+		})
+	}
 
 	// volume 0 is reserved as the "cluster private" volume
 	clusterPrivateVol := r.Volumes[0]
 	deployedClusterPrivateVol := deployedRes.Volumes[0]
-	agents = append(agents, common.ClusterPrivateVolumeAgent(clusterPrivateVol.VolumeConfig, deployedClusterPrivateVol, r.Name))
+	agents = append(agents, common.ClusterPrivateVolumeAgent(clusterPrivateVol.VolumeConfig, deployedClusterPrivateVol, r.linstorResourceName()))
 
 	for i := 1; i < len(deployedRes.Volumes); i++ {
 		vol := deployedRes.Volumes[i]
+		if i >= len(r.Volumes) {
+			return nil, fmt.Errorf("inconsistent volumes, volume %d is deployed, but not configured", vol.VolumeNumber)
+		}
+
 		resVol := r.Volumes[i]
 		if int(vol.VolumeNumber) != resVol.Number {
 			return nil, fmt.Errorf("inconsistent volumes, expected volume number %d, got %d", vol.VolumeNumber, resVol.Number)
 		}
 
-		dirPath := ExportPath(r, &resVol)
-
-		agents = append(agents,
-			&reactor.ResourceAgent{
-				Type: "ocf:heartbeat:Filesystem",
-				Name: fmt.Sprintf(fsAgentName, vol.VolumeNumber),
-				Attributes: map[string]string{
-					"device":    common.DevicePath(vol),
-					"directory": dirPath,
-					"fstype":    resVol.FileSystem,
-					"run_fsck":  "no",
-				},
+		fsAgent := &reactor.ResourceAgent{
+			Type: "ocf:heartbeat:Filesystem",
+			Name: fmt.Sprintf(fsAgentName, vol.VolumeNumber),
+			Attributes: map[string]string{
+				"device":    common.DevicePath(vol),
+				"directory": MountPath(r, &resVol),
+				"fstype":    resVol.FileSystem,
+				"run_fsck":  "no",
 			},
-		)
+		}
+		if resVol.MountOptions != "" {
+			fsAgent.Attributes["options"] = resVol.MountOptions
+		}
+		agents = append(agents, fsAgent)
+
+		if subdirectory(resVol.Path) != "" {
+			// exportfs expects the directory to exist. "mkdir -p" is
+			// idempotent, so this is safe on every promotion.
+			agents = append(agents, &reactor.SystemdService{Name: mkdirService(ExportPath(r, &resVol))})
+		}
 	}
 
 	agents = append(agents, &reactor.ResourceAgent{
 		Type: "ocf:heartbeat:nfsserver",
 		Name: "nfsserver",
 		Attributes: map[string]string{
-			"nfs_ip":             r.ServiceIP.IP().String(),
+			"nfs_ip":             strings.Join(ipStrings, ","),
 			"nfs_shared_infodir": filepath.Join(common.ClusterPrivateVolumeMountPath, deployedRes.Name, "nfs"),
 			"nfs_server_scope":   r.ServiceIP.IP().String(),
 		},
@@ -448,12 +932,16 @@ func (r *ResourceConfig) ToPromoter(deployment []client.ResourceWithVolumes) (*r
 
 	for i := 1; i < len(deployedRes.Volumes); i++ {
 		vol := deployedRes.Volumes[i]
+		if i >= len(r.Volumes) {
+			return nil, fmt.Errorf("inconsistent volumes, volume %d is deployed, but not configured", vol.VolumeNumber)
+		}
+
 		resVol := r.Volumes[i]
 		if int(vol.VolumeNumber) != resVol.Number {
 			return nil, fmt.Errorf("inconsistent volumes, expected volume number %d, got %d", vol.VolumeNumber, resVol.Number)
 		}
 
-		fsid := uuid.NewSHA1(resUuid, []byte(vol.Uuid))
+		fsid := ExportFSID(r, &resVol)
 
 		dirPath := ExportPath(r, &resVol)
 
@@ -463,32 +951,49 @@ func (r *ResourceConfig) ToPromoter(deployment []client.ResourceWithVolumes) (*r
 				Name: fmt.Sprintf(exportAgentName, vol.VolumeNumber, j),
 				Attributes: map[string]string{
 					"directory":  dirPath,
-					"fsid":       fsid.String(),
+					"fsid":       fsid,
 					"clientspec": nfsFormatCidr(&r.AllowedIPs[j]),
-					"options":    "rw,all_squash,anonuid=0,anongid=0",
+					"options":    DefaultExportOptions,
+				},
+			})
+		}
+
+		for j := range r.Clients {
+			agents = append(agents, &reactor.ResourceAgent{
+				Type: "ocf:heartbeat:exportfs",
+				Name: fmt.Sprintf(exportClientAgentName, vol.VolumeNumber, j),
+				Attributes: map[string]string{
+					"directory":  dirPath,
+					"fsid":       fsid,
+					"clientspec": nfsFormatCidr(&r.Clients[j].IPs),
+					"options":    r.Clients[j].Options,
 				},
 			})
 		}
 	}
 
-	agents = append(agents, &reactor.ResourceAgent{Type: "ocf:heartbeat:IPaddr2", Name: "service_ip", Attributes: map[string]string{"ip": r.ServiceIP.IP().String(), "cidr_netmask": strconv.Itoa(r.ServiceIP.Prefix())}})
+	for i, ip := range serviceIPs {
+		agents = append(agents, &reactor.ResourceAgent{Type: "ocf:heartbeat:IPaddr2", Name: serviceAgentName("service_ip", i), Attributes: map[string]string{"ip": ip.IP().String(), "cidr_netmask": strconv.Itoa(ip.Prefix())}})
+	}
 
-	agents = append(agents, &reactor.ResourceAgent{
-		Type: "ocf:heartbeat:portblock",
-		Name: "portunblock",
-		Attributes: map[string]string{
-			"ip":         r.ServiceIP.IP().String(),
-			"portno":     strconv.Itoa(DefaultNFSPort),
-			"action":     "unblock",
-			"protocol":   "tcp",
-			"tickle_dir": filepath.Join(common.ClusterPrivateVolumeMountPath, deployedRes.Name),
-		},
-	})
+	for i, ip := range serviceIPs {
+		agents = append(agents, &reactor.ResourceAgent{
+			Type: "ocf:heartbeat:portblock",
+			Name: serviceAgentName("portunblock", i),
+			Attributes: map[string]string{
+				"ip":         ip.IP().String(),
+				"portno":     strconv.Itoa(DefaultNFSPort),
+				"action":     "unblock",
+				"protocol":   "tcp",
+				"tickle_dir": filepath.Join(common.ClusterPrivateVolumeMountPath, deployedRes.Name),
+			},
+		})
+	}
 
 	return &reactor.PromoterConfig{
 		ID: r.ID(),
 		Resources: map[string]reactor.PromoterResourceConfig{
-			r.Name: {
+			r.linstorResourceName(): {
 				Runner:              "systemd",
 				Start:               agents,
 				StopServicesOnExit:  true,
@@ -34,6 +34,122 @@ func TestResource_RoundTrip(t *testing.T) {
 			},
 		},
 		Status: common.ResourceStatus{},
+	}, {
+		Name:          "renamed",
+		ResourceName:  "test",
+		ServiceIP:     common.ServiceIPFromParts(net.IP{192, 168, 127, 1}, 24),
+		AllowedIPs:    AllowAllCidr,
+		ResourceGroup: "rg1",
+		Volumes: []VolumeConfig{
+			{VolumeConfig: common.ClusterPrivateVolume()},
+			{
+				VolumeConfig: common.VolumeConfig{
+					Number:     1,
+					SizeKiB:    1024,
+					FileSystem: "ext4",
+				},
+				ExportPath: "/",
+			},
+		},
+		Status: common.ResourceStatus{},
+	}, {
+		Name:          "subdirectory",
+		ServiceIP:     common.ServiceIPFromParts(net.IP{192, 168, 127, 1}, 24),
+		AllowedIPs:    AllowAllCidr,
+		ResourceGroup: "rg1",
+		Volumes: []VolumeConfig{
+			{VolumeConfig: common.ClusterPrivateVolume()},
+			{
+				VolumeConfig: common.VolumeConfig{
+					Number:     1,
+					SizeKiB:    1024,
+					FileSystem: "ext4",
+				},
+				ExportPath: "/",
+				Path:       "/projectA/data",
+			},
+		},
+		Status: common.ResourceStatus{},
+	}, {
+		Name:          "fsid",
+		ServiceIP:     common.ServiceIPFromParts(net.IP{192, 168, 127, 1}, 24),
+		AllowedIPs:    AllowAllCidr,
+		ResourceGroup: "rg1",
+		Volumes: []VolumeConfig{
+			{VolumeConfig: common.ClusterPrivateVolume()},
+			{
+				VolumeConfig: common.VolumeConfig{
+					Number:     1,
+					SizeKiB:    1024,
+					FileSystem: "ext4",
+				},
+				ExportPath: "/",
+				FSID:       "42",
+			},
+		},
+		Status: common.ResourceStatus{},
+	}, {
+		Name:          "mount_options",
+		ServiceIP:     common.ServiceIPFromParts(net.IP{192, 168, 127, 1}, 24),
+		AllowedIPs:    AllowAllCidr,
+		ResourceGroup: "rg1",
+		Volumes: []VolumeConfig{
+			{VolumeConfig: common.ClusterPrivateVolume()},
+			{
+				VolumeConfig: common.VolumeConfig{
+					Number:     1,
+					SizeKiB:    1024,
+					FileSystem: "ext4",
+				},
+				ExportPath:   "/",
+				MountOptions: "noatime,nodiratime",
+			},
+		},
+		Status: common.ResourceStatus{},
+	}, {
+		Name:      "multiple_service_ips",
+		ServiceIP: common.ServiceIPFromParts(net.IP{192, 168, 127, 1}, 24),
+		ServiceIPs: []common.IpCidr{
+			common.ServiceIPFromParts(net.IP{192, 168, 127, 1}, 24),
+			common.ServiceIPFromParts(net.IP{10, 0, 0, 1}, 16),
+		},
+		AllowedIPs:    AllowAllCidr,
+		ResourceGroup: "rg1",
+		Volumes: []VolumeConfig{
+			{VolumeConfig: common.ClusterPrivateVolume()},
+			{
+				VolumeConfig: common.VolumeConfig{
+					Number:     1,
+					SizeKiB:    1024,
+					FileSystem: "ext4",
+				},
+				ExportPath: "/",
+			},
+		},
+		Status: common.ResourceStatus{},
+	}, {
+		Name:      "clients",
+		ServiceIP: common.ServiceIPFromParts(net.IP{192, 168, 127, 1}, 24),
+		AllowedIPs: []common.IpCidr{
+			common.ServiceIPFromParts(net.IP{192, 168, 127, 0}, 24),
+		},
+		Clients: []ExportClient{
+			{IPs: common.ServiceIPFromParts(net.IP{10, 0, 0, 0}, 8), Options: "ro,root_squash"},
+			{IPs: common.ServiceIPFromParts(net.ParseIP("fd00::"), 64), Options: "rw,no_root_squash"},
+		},
+		ResourceGroup: "rg1",
+		Volumes: []VolumeConfig{
+			{VolumeConfig: common.ClusterPrivateVolume()},
+			{
+				VolumeConfig: common.VolumeConfig{
+					Number:     1,
+					SizeKiB:    1024,
+					FileSystem: "ext4",
+				},
+				ExportPath: "/",
+			},
+		},
+		Status: common.ResourceStatus{},
 	}}
 
 	propsFilesystemExt4 := map[string]string{apiconsts.NamespcFilesystem + "/Type": "ext4"}
@@ -63,11 +179,22 @@ func TestResource_RoundTrip(t *testing.T) {
 			)
 			assert.NoError(t, err)
 			assert.Equal(t, tcase.Name, decoded.Name)
+			assert.Equal(t, tcase.linstorResourceName(), decoded.ResourceName)
 			assert.Equal(t, tcase.ServiceIP.String(), decoded.ServiceIP.String())
+			assert.Len(t, decoded.ServiceIPs, len(tcase.serviceIPs()))
+			for i := 0; i < len(decoded.ServiceIPs); i++ {
+				assert.Equal(t, tcase.serviceIPs()[i].String(), decoded.ServiceIPs[i].String())
+			}
 			assert.Len(t, decoded.AllowedIPs, len(tcase.AllowedIPs))
 			for i := 0; i < len(decoded.AllowedIPs); i++ {
 				assert.Equal(t, tcase.AllowedIPs[i].String(), decoded.AllowedIPs[i].String())
 			}
+			assert.Len(t, decoded.Clients, len(tcase.Clients))
+			for i := 0; i < len(decoded.Clients); i++ {
+				assert.Equal(t, tcase.Clients[i].IPs.String(), decoded.Clients[i].IPs.String())
+				assert.Equal(t, tcase.Clients[i].Options, decoded.Clients[i].Options)
+			}
+			assert.True(t, tcase.Matches(decoded))
 			assert.Equal(t, tcase.ResourceGroup, decoded.ResourceGroup)
 			assert.Equal(t, tcase.Volumes, decoded.Volumes)
 			assert.Equal(t, tcase.Status, decoded.Status)
@@ -75,6 +202,98 @@ func TestResource_RoundTrip(t *testing.T) {
 	}
 }
 
+func TestFromPromoter_UnknownAgent(t *testing.T) {
+	t.Parallel()
+
+	cfg := ResourceConfig{
+		Name:          "test",
+		ServiceIP:     common.ServiceIPFromParts(net.IP{192, 168, 127, 1}, 24),
+		AllowedIPs:    []common.IpCidr{common.ServiceIPFromParts(net.IP{192, 168, 127, 0}, 24)},
+		ResourceGroup: "rg1",
+		Volumes: []VolumeConfig{
+			{VolumeConfig: common.ClusterPrivateVolume()},
+			{VolumeConfig: common.VolumeConfig{Number: 1, SizeKiB: 1024, FileSystem: "ext4"}, ExportPath: "/"},
+		},
+	}
+
+	unknown := &reactor.ResourceAgent{Type: "ocf:custom:thing", Name: "thing", Attributes: map[string]string{"foo": "bar"}}
+
+	cases := []struct {
+		name    string
+		modify  func(start []reactor.StartEntry) []reactor.StartEntry
+		wantErr bool
+	}{{
+		name: "extra agent",
+		modify: func(start []reactor.StartEntry) []reactor.StartEntry {
+			return append([]reactor.StartEntry{unknown}, start...)
+		},
+	}, {
+		name: "missing nfsserver",
+		modify: func(start []reactor.StartEntry) []reactor.StartEntry {
+			var result []reactor.StartEntry
+			for _, entry := range start {
+				if agent, ok := entry.(*reactor.ResourceAgent); ok && agent.Type == "ocf:heartbeat:nfsserver" {
+					entry = unknown
+				}
+				result = append(result, entry)
+			}
+			return result
+		},
+		wantErr: true,
+	}}
+
+	propsFilesystemExt4 := map[string]string{apiconsts.NamespcFilesystem + "/Type": "ext4"}
+	for i := range cases {
+		tcase := &cases[i]
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			encoded, err := cfg.ToPromoter([]client.ResourceWithVolumes{
+				{Volumes: []client.Volume{
+					{VolumeNumber: 0, DevicePath: "/dev/drbd1000", Props: propsFilesystemExt4},
+					{VolumeNumber: 1, DevicePath: "/dev/drbd1001", Props: propsFilesystemExt4},
+				}},
+			})
+			assert.NoError(t, err)
+
+			rsc := encoded.Resources[cfg.linstorResourceName()]
+			rsc.Start = tcase.modify(rsc.Start)
+			encoded.Resources[cfg.linstorResourceName()] = rsc
+
+			decoded, err := FromPromoter(
+				encoded,
+				&client.ResourceDefinition{ResourceGroupName: "rg1"},
+				[]client.VolumeDefinition{
+					{VolumeNumber: gog.Ptr(int32(0)), SizeKib: 64 * 1024, Props: propsFilesystemExt4},
+					{VolumeNumber: gog.Ptr(int32(1)), SizeKib: 1024, Props: propsFilesystemExt4},
+				},
+			)
+			if tcase.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.True(t, cfg.Matches(decoded))
+			assert.Equal(t, cfg.Volumes, decoded.Volumes)
+		})
+	}
+}
+
+func TestExportFSID(t *testing.T) {
+	t.Parallel()
+	vol := &VolumeConfig{VolumeConfig: common.VolumeConfig{Number: 1}}
+
+	original := ExportFSID(&ResourceConfig{Name: "test"}, vol)
+	renamed := ExportFSID(&ResourceConfig{Name: "renamed", ResourceName: "test"}, vol)
+	other := ExportFSID(&ResourceConfig{Name: "other"}, vol)
+
+	assert.NoError(t, validFSID(original))
+	assert.Equal(t, original, renamed)
+	assert.NotEqual(t, original, other)
+	assert.Equal(t, "42", ExportFSID(&ResourceConfig{Name: "test"}, &VolumeConfig{FSID: "42"}))
+}
+
 func TestFindFilesystemAgentVolume(t *testing.T) {
 	t.Parallel()
 	volumes := []client.VolumeDefinition{
@@ -149,6 +368,66 @@ func TestFindFilesystemAgentVolume(t *testing.T) {
 	}
 }
 
+func TestParseVolume(t *testing.T) {
+	t.Parallel()
+	agent := &reactor.ResourceAgent{
+		Type:       "ocf:heartbeat:Filesystem",
+		Name:       "fs_1",
+		Attributes: map[string]string{"directory": "/srv/gateway-exports/test/data"},
+	}
+
+	tests := []struct {
+		name  string
+		props map[string]string
+		want  *VolumeConfig
+	}{{
+		name:  "filesystem only",
+		props: map[string]string{apiconsts.NamespcFilesystem + "/Type": "ext4"},
+		want: &VolumeConfig{
+			VolumeConfig: common.VolumeConfig{Number: 1, SizeKiB: 1024, FileSystem: "ext4"},
+			ExportPath:   "/data",
+		},
+	}, {
+		name: "root owner",
+		props: map[string]string{
+			apiconsts.NamespcFilesystem + "/Type":       "ext4",
+			apiconsts.NamespcFilesystem + "/MkfsParams": "-E root_owner=65534:65534",
+		},
+		want: &VolumeConfig{
+			VolumeConfig: common.VolumeConfig{
+				Number:              1,
+				SizeKiB:             1024,
+				FileSystem:          "ext4",
+				FileSystemRootOwner: common.UidGid{Uid: 65534, Gid: 65534},
+			},
+			ExportPath: "/data",
+		},
+	}, {
+		name: "invalid mkfs params",
+		props: map[string]string{
+			apiconsts.NamespcFilesystem + "/Type":       "ext4",
+			apiconsts.NamespcFilesystem + "/MkfsParams": "-b 4096",
+		},
+		want: &VolumeConfig{
+			VolumeConfig: common.VolumeConfig{Number: 1, SizeKiB: 1024, FileSystem: "ext4"},
+			ExportPath:   "/data",
+		},
+	}}
+	for i := range tests {
+		tcase := &tests[i]
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+			volumes := []client.VolumeDefinition{
+				{VolumeNumber: gog.Ptr(int32(0)), SizeKib: 64 * 1024},
+				{VolumeNumber: gog.Ptr(int32(1)), SizeKib: 1024, Props: tcase.props},
+			}
+			got, err := parseVolume(agent, volumes, "test")
+			assert.NoError(t, err)
+			assert.Equal(t, tcase.want, got)
+		})
+	}
+}
+
 func TestValid(t *testing.T) {
 	t.Parallel()
 	testcases := []struct {
@@ -220,6 +499,192 @@ func TestValid(t *testing.T) {
 			},
 		},
 		expectError: true,
+	}, {
+		config: ResourceConfig{
+			Name:      "fsids",
+			ServiceIP: common.ServiceIPFromParts(net.IP{192, 168, 127, 1}, 24),
+			Volumes: []VolumeConfig{
+				{VolumeConfig: common.VolumeConfig{Number: 1, SizeKiB: 1024}, ExportPath: "/a", FSID: "42"},
+				{VolumeConfig: common.VolumeConfig{Number: 2, SizeKiB: 1024}, ExportPath: "/b", FSID: "7ba61f8d-39bc-4d9b-a3d6-4ac8a4bbce59"},
+			},
+		},
+		expectError: false,
+	}, {
+		config: ResourceConfig{
+			Name:      "fsid_0",
+			ServiceIP: common.ServiceIPFromParts(net.IP{192, 168, 127, 1}, 24),
+			Volumes: []VolumeConfig{
+				{VolumeConfig: common.VolumeConfig{Number: 1, SizeKiB: 1024}, FSID: "0"},
+			},
+		},
+		expectError: true,
+	}, {
+		config: ResourceConfig{
+			Name:      "invalid_fsid",
+			ServiceIP: common.ServiceIPFromParts(net.IP{192, 168, 127, 1}, 24),
+			Volumes: []VolumeConfig{
+				{VolumeConfig: common.VolumeConfig{Number: 1, SizeKiB: 1024}, FSID: "root"},
+			},
+		},
+		expectError: true,
+	}, {
+		config: ResourceConfig{
+			Name:      "duplicate_fsids",
+			ServiceIP: common.ServiceIPFromParts(net.IP{192, 168, 127, 1}, 24),
+			Volumes: []VolumeConfig{
+				{VolumeConfig: common.VolumeConfig{Number: 1, SizeKiB: 1024}, ExportPath: "/a", FSID: "42"},
+				{VolumeConfig: common.VolumeConfig{Number: 2, SizeKiB: 1024}, ExportPath: "/b", FSID: "42"},
+			},
+		},
+		expectError: true,
+	}, {
+		config: ResourceConfig{
+			Name:      "mount_options",
+			ServiceIP: common.ServiceIPFromParts(net.IP{192, 168, 127, 1}, 24),
+			Volumes: []VolumeConfig{
+				{VolumeConfig: common.VolumeConfig{Number: 1, SizeKiB: 1024}, MountOptions: "noatime,commit=60,data=ordered"},
+			},
+		},
+		expectError: false,
+	}, {
+		config: ResourceConfig{
+			Name:      "empty_mount_option",
+			ServiceIP: common.ServiceIPFromParts(net.IP{192, 168, 127, 1}, 24),
+			Volumes: []VolumeConfig{
+				{VolumeConfig: common.VolumeConfig{Number: 1, SizeKiB: 1024}, MountOptions: "noatime,,nodiratime"},
+			},
+		},
+		expectError: true,
+	}, {
+		config: ResourceConfig{
+			Name:      "malformed_mount_options",
+			ServiceIP: common.ServiceIPFromParts(net.IP{192, 168, 127, 1}, 24),
+			Volumes: []VolumeConfig{
+				{VolumeConfig: common.VolumeConfig{Number: 1, SizeKiB: 1024}, MountOptions: "noatime nodiratime"},
+			},
+		},
+		expectError: true,
+	}, {
+		config: ResourceConfig{
+			Name:      "mount_options_on_cluster_private_volume",
+			ServiceIP: common.ServiceIPFromParts(net.IP{192, 168, 127, 1}, 24),
+			Volumes: []VolumeConfig{
+				{VolumeConfig: common.ClusterPrivateVolume(), MountOptions: "noatime"},
+			},
+		},
+		expectError: true,
+	}, {
+		config: ResourceConfig{
+			Name:      "path_outside_volume",
+			ServiceIP: common.ServiceIPFromParts(net.IP{192, 168, 127, 1}, 24),
+			Volumes: []VolumeConfig{
+				{VolumeConfig: common.VolumeConfig{Number: 1, SizeKiB: 1024}, Path: "projectA/../../etc"},
+			},
+		},
+		expectError: true,
+	}, {
+		config: ResourceConfig{
+			Name:      "path_in_cluster_private_volume",
+			ServiceIP: common.ServiceIPFromParts(net.IP{192, 168, 127, 1}, 24),
+			Volumes: []VolumeConfig{
+				{VolumeConfig: common.ClusterPrivateVolume(), Path: "/data"},
+			},
+		},
+		expectError: true,
+	}, {
+		config: ResourceConfig{
+			Name:      "subdirectory",
+			ServiceIP: common.ServiceIPFromParts(net.IP{192, 168, 127, 1}, 24),
+			Volumes: []VolumeConfig{
+				{VolumeConfig: common.VolumeConfig{Number: 1, SizeKiB: 1024}, Path: "projectA/data"},
+			},
+		},
+		expectError: false,
+	}, {
+		config: ResourceConfig{
+			Name: "only_service_ips",
+			ServiceIPs: []common.IpCidr{
+				common.ServiceIPFromParts(net.IP{192, 168, 127, 1}, 24),
+				common.ServiceIPFromParts(net.IP{10, 0, 0, 1}, 16),
+			},
+		},
+		expectError: false,
+	}, {
+		config: ResourceConfig{
+			Name:      "service_ip_not_first",
+			ServiceIP: common.ServiceIPFromParts(net.IP{192, 168, 127, 1}, 24),
+			ServiceIPs: []common.IpCidr{
+				common.ServiceIPFromParts(net.IP{10, 0, 0, 1}, 16),
+				common.ServiceIPFromParts(net.IP{192, 168, 127, 1}, 24),
+			},
+		},
+		expectError: true,
+	}, {
+		config: ResourceConfig{
+			Name: "duplicate_service_ips",
+			ServiceIPs: []common.IpCidr{
+				common.ServiceIPFromParts(net.IP{192, 168, 127, 1}, 24),
+				common.ServiceIPFromParts(net.IP{192, 168, 127, 1}, 16),
+			},
+		},
+		expectError: true,
+	}, {
+		config: ResourceConfig{
+			Name:      "clients",
+			ServiceIP: common.ServiceIPFromParts(net.IP{192, 168, 127, 1}, 24),
+			Clients: []ExportClient{
+				{IPs: common.ServiceIPFromParts(net.IP{10, 0, 0, 0}, 8), Options: "ro,root_squash"},
+				{IPs: common.ServiceIPFromParts(net.IP{10, 1, 0, 0}, 16), Options: "rw,sec=krb5:krb5i,anonuid=0"},
+			},
+		},
+		expectError: false,
+	}, {
+		config: ResourceConfig{
+			Name:      "duplicate_client",
+			ServiceIP: common.ServiceIPFromParts(net.IP{192, 168, 127, 1}, 24),
+			Clients: []ExportClient{
+				{IPs: common.ServiceIPFromParts(net.IP{10, 0, 0, 0}, 8), Options: "ro"},
+				{IPs: common.ServiceIPFromParts(net.IP{10, 0, 0, 0}, 8), Options: "rw"},
+			},
+		},
+		expectError: true,
+	}, {
+		config: ResourceConfig{
+			Name:       "client_in_allowed_ips",
+			ServiceIP:  common.ServiceIPFromParts(net.IP{192, 168, 127, 1}, 24),
+			AllowedIPs: []common.IpCidr{common.ServiceIPFromParts(net.IP{10, 0, 0, 0}, 8)},
+			Clients: []ExportClient{
+				{IPs: common.ServiceIPFromParts(net.IP{10, 0, 0, 0}, 8), Options: "ro"},
+			},
+		},
+		expectError: true,
+	}, {
+		config: ResourceConfig{
+			Name:      "contradictory_options",
+			ServiceIP: common.ServiceIPFromParts(net.IP{192, 168, 127, 1}, 24),
+			Clients: []ExportClient{
+				{IPs: common.ServiceIPFromParts(net.IP{10, 0, 0, 0}, 8), Options: "ro,rw"},
+			},
+		},
+		expectError: true,
+	}, {
+		config: ResourceConfig{
+			Name:      "client_fsid",
+			ServiceIP: common.ServiceIPFromParts(net.IP{192, 168, 127, 1}, 24),
+			Clients: []ExportClient{
+				{IPs: common.ServiceIPFromParts(net.IP{10, 0, 0, 0}, 8), Options: "ro,fsid=1"},
+			},
+		},
+		expectError: true,
+	}, {
+		config: ResourceConfig{
+			Name:      "missing_client_options",
+			ServiceIP: common.ServiceIPFromParts(net.IP{192, 168, 127, 1}, 24),
+			Clients: []ExportClient{
+				{IPs: common.ServiceIPFromParts(net.IP{10, 0, 0, 0}, 8)},
+			},
+		},
+		expectError: true,
 	}, {
 		config: ResourceConfig{
 			Name:      "everything",
@@ -258,3 +723,28 @@ func TestValid(t *testing.T) {
 		})
 	}
 }
+
+func TestSystemdEscapePath(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		path     string
+		expected string
+	}{{
+		path:     "/",
+		expected: "-",
+	}, {
+		path:     "/srv/gateway-exports/test/projectA",
+		expected: `srv-gateway\x2dexports-test-projectA`,
+	}, {
+		path:     "/srv/.hidden/with space/",
+		expected: `srv-.hidden-with\x20space`,
+	}}
+
+	for i := range cases {
+		tcase := &cases[i]
+		t.Run(tcase.path, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tcase.expected, systemdEscapePath(tcase.path))
+		})
+	}
+}
@@ -0,0 +1,343 @@
+package nvmeof
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/LINBIT/golinstor/client"
+	"github.com/icza/gog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/LINBIT/linstor-gateway/pkg/common"
+	"github.com/LINBIT/linstor-gateway/pkg/linstorcontrol"
+)
+
+// mockLinstor records the calls made while creating a target. Registering the
+// reactor config fails, unless writeFiles is set.
+type mockLinstor struct {
+	writeFiles       bool
+	writtenFiles     []string
+	deletedFiles     []string
+	deletedResources []string
+}
+
+// mockControllerProps stores the properties of the controller, which hold
+// the locks of resources.
+type mockControllerProps struct {
+	client.ControllerProvider
+	mu    sync.Mutex
+	props client.ControllerProps
+}
+
+func (c *mockControllerProps) GetProps(ctx context.Context, opts ...*client.ListOpts) (client.ControllerProps, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	props := make(client.ControllerProps, len(c.props))
+	for k, v := range c.props {
+		props[k] = v
+	}
+	return props, nil
+}
+
+func (c *mockControllerProps) Modify(ctx context.Context, props client.GenericPropsModify) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.props == nil {
+		c.props = client.ControllerProps{}
+	}
+	for k, v := range props.OverrideProps {
+		c.props[k] = v
+	}
+	for _, k := range props.DeleteProps {
+		delete(c.props, k)
+	}
+	return nil
+}
+
+func (c *mockControllerProps) DeleteProp(ctx context.Context, prop string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.props, prop)
+	return nil
+}
+
+type mockController struct {
+	*mockControllerProps
+	m *mockLinstor
+}
+
+func (c mockController) GetExternalFiles(ctx context.Context, opts ...*client.ListOpts) ([]client.ExternalFile, error) {
+	return nil, nil
+}
+
+func (c mockController) ModifyExternalFile(ctx context.Context, name string, file client.ExternalFile) error {
+	if !c.m.writeFiles {
+		return errors.New("mock: cannot write external file")
+	}
+	c.m.writtenFiles = append(c.m.writtenFiles, name)
+	return nil
+}
+
+func (c mockController) DeleteExternalFile(ctx context.Context, name string) error {
+	c.m.deletedFiles = append(c.m.deletedFiles, name)
+	return nil
+}
+
+type mockResourceGroups struct {
+	client.ResourceGroupProvider
+}
+
+func (mockResourceGroups) Create(ctx context.Context, resGrp client.ResourceGroup) error {
+	return nil
+}
+
+func (mockResourceGroups) Get(ctx context.Context, resGrpName string, opts ...*client.ListOpts) (client.ResourceGroup, error) {
+	return client.ResourceGroup{Name: resGrpName}, nil
+}
+
+type mockResourceDefinitions struct {
+	client.ResourceDefinitionProvider
+	m *mockLinstor
+}
+
+func (mockResourceDefinitions) Create(ctx context.Context, resDef client.ResourceDefinitionCreate) error {
+	return nil
+}
+
+func (mockResourceDefinitions) CreateVolumeDefinition(ctx context.Context, resDefName string, volDef client.VolumeDefinitionCreate) error {
+	return nil
+}
+
+func (mockResourceDefinitions) Get(ctx context.Context, resDefName string, opts ...*client.ListOpts) (client.ResourceDefinition, error) {
+	return client.ResourceDefinition{Name: resDefName}, nil
+}
+
+func (mockResourceDefinitions) GetVolumeDefinitions(ctx context.Context, resDefName string, opts ...*client.ListOpts) ([]client.VolumeDefinition, error) {
+	return nil, nil
+}
+
+func (d mockResourceDefinitions) Delete(ctx context.Context, resDefName string) error {
+	d.m.deletedResources = append(d.m.deletedResources, resDefName)
+	return nil
+}
+
+type mockResources struct {
+	client.ResourceProvider
+	// diskState is the DRBD disk state of all volumes.
+	diskState string
+}
+
+func (mockResources) Autoplace(ctx context.Context, resName string, apr client.AutoPlaceRequest) error {
+	return nil
+}
+
+func (r mockResources) GetResourceView(ctx context.Context, opts ...*client.ListOpts) ([]client.ResourceWithVolumes, error) {
+	return []client.ResourceWithVolumes{{
+		Resource: client.Resource{
+			Name:     opts[0].Resource[0],
+			NodeName: "node1",
+			State:    &client.ResourceState{InUse: gog.Ptr(false)},
+		},
+		Volumes: []client.Volume{
+			{VolumeNumber: 0, State: client.VolumeState{DiskState: r.diskState}},
+			{VolumeNumber: 1, State: client.VolumeState{DiskState: r.diskState}},
+		},
+	}}, nil
+}
+
+func TestCreate_Rollback(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name              string
+		keepOnFailure     bool
+		resourceName      string
+		expectedFiles     []string
+		expectedResources []string
+	}{{
+		name:              "rollback",
+		expectedFiles:     []string{"/etc/drbd-reactor.d/linstor-gateway-nvmeof-example.toml"},
+		expectedResources: []string{"example"},
+	}, {
+		name:              "rollback with resource name",
+		resourceName:      "custom",
+		expectedFiles:     []string{"/etc/drbd-reactor.d/linstor-gateway-nvmeof-example.toml"},
+		expectedResources: []string{"custom"},
+	}, {
+		name:          "keep on failure",
+		keepOnFailure: true,
+	}}
+
+	for i := range cases {
+		tcase := &cases[i]
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+			m := &mockLinstor{}
+			n := &NVMeoF{cli: &linstorcontrol.Linstor{Client: &client.Client{
+				Controller:          mockController{mockControllerProps: &mockControllerProps{}, m: m},
+				ResourceGroups:      mockResourceGroups{},
+				ResourceDefinitions: mockResourceDefinitions{m: m},
+				Resources:           mockResources{},
+			}}}
+
+			_, err := n.Create(context.Background(), &ResourceConfig{
+				NQN:           Nqn{"nqn.2021-08.com.example.test", "example"},
+				ResourceName:  tcase.resourceName,
+				ServiceIP:     common.ServiceIPFromParts(net.IP{192, 168, 127, 1}, 24),
+				ResourceGroup: "rg",
+				Volumes:       []common.VolumeConfig{{Number: 1, SizeKiB: 1024}},
+			}, common.CreateOptions{KeepOnFailure: tcase.keepOnFailure, SkipCapacityCheck: true})
+			assert.ErrorContains(t, err, "failed to register reactor config file")
+			assert.Equal(t, tcase.expectedFiles, m.deletedFiles)
+			assert.Equal(t, tcase.expectedResources, m.deletedResources)
+		})
+	}
+}
+
+func TestCreate_NoStart(t *testing.T) {
+	t.Parallel()
+	// Starting the target would attach the reactor config, which the mocks
+	// do not implement.
+	m := &mockLinstor{writeFiles: true}
+	n := &NVMeoF{cli: &linstorcontrol.Linstor{Client: &client.Client{
+		Controller:          mockController{mockControllerProps: &mockControllerProps{}, m: m},
+		ResourceGroups:      mockResourceGroups{},
+		ResourceDefinitions: mockResourceDefinitions{m: m},
+		Resources:           mockResources{},
+	}}}
+
+	rsc, err := n.Create(context.Background(), &ResourceConfig{
+		NQN:           Nqn{"nqn.2021-08.com.example.test", "example"},
+		ServiceIP:     common.ServiceIPFromParts(net.IP{192, 168, 127, 1}, 24),
+		ResourceGroup: "rg",
+		Volumes:       []common.VolumeConfig{{Number: 1, SizeKiB: 1024}},
+	}, common.CreateOptions{SkipCapacityCheck: true, NoStart: true})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/etc/drbd-reactor.d/linstor-gateway-nvmeof-example.toml"}, m.writtenFiles)
+	assert.Empty(t, m.deletedResources)
+	assert.Equal(t, common.ServiceStateStopped, rsc.Status.Service)
+}
+
+func TestCreate_WaitSync(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name      string
+		diskState string
+		expectErr string
+	}{{
+		name:      "synced",
+		diskState: "UpToDate",
+	}, {
+		name:      "still syncing",
+		diskState: "Inconsistent",
+		expectErr: "the target was created, but failed to wait for the replicas to be in sync",
+	}}
+
+	for i := range cases {
+		tcase := &cases[i]
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+			m := &mockLinstor{writeFiles: true}
+			n := &NVMeoF{cli: &linstorcontrol.Linstor{Client: &client.Client{
+				Controller:          mockController{mockControllerProps: &mockControllerProps{}, m: m},
+				ResourceGroups:      mockResourceGroups{},
+				ResourceDefinitions: mockResourceDefinitions{m: m},
+				Resources:           mockResources{diskState: tcase.diskState},
+			}}}
+
+			rsc, err := n.Create(context.Background(), &ResourceConfig{
+				NQN:           Nqn{"nqn.2021-08.com.example.test", "example"},
+				ServiceIP:     common.ServiceIPFromParts(net.IP{192, 168, 127, 1}, 24),
+				ResourceGroup: "rg",
+				Volumes:       []common.VolumeConfig{{Number: 1, SizeKiB: 1024}},
+			}, common.CreateOptions{SkipCapacityCheck: true, NoStart: true, WaitSync: true, SyncTimeout: 10 * time.Millisecond})
+			if tcase.expectErr != "" {
+				assert.ErrorContains(t, err, tcase.expectErr)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, common.ResourceStateOK, rsc.Status.State)
+			}
+			// a target that is still syncing is kept
+			assert.Empty(t, m.deletedResources)
+		})
+	}
+}
+
+func TestCreate_InvalidVolumeNumbers(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name    string
+		volumes []common.VolumeConfig
+	}{{
+		name:    "cluster private volume",
+		volumes: []common.VolumeConfig{{Number: 0, SizeKiB: 1024}, {Number: 1, SizeKiB: 1024}},
+	}, {
+		name:    "negative",
+		volumes: []common.VolumeConfig{{Number: -1, SizeKiB: 1024}},
+	}, {
+		name:    "duplicate",
+		volumes: []common.VolumeConfig{{Number: 1, SizeKiB: 1024}, {Number: 2, SizeKiB: 1024}, {Number: 1, SizeKiB: 2048}},
+	}}
+
+	for i := range cases {
+		tcase := &cases[i]
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+			// validation fails before LINSTOR is contacted
+			m := &mockLinstor{}
+			n := &NVMeoF{cli: &linstorcontrol.Linstor{Client: &client.Client{
+				Controller:          mockController{mockControllerProps: &mockControllerProps{}, m: m},
+				ResourceGroups:      mockResourceGroups{},
+				ResourceDefinitions: mockResourceDefinitions{m: m},
+				Resources:           mockResources{},
+			}}}
+
+			_, err := n.Create(context.Background(), &ResourceConfig{
+				NQN:           Nqn{"nqn.2021-08.com.example.test", "example"},
+				ServiceIP:     common.ServiceIPFromParts(net.IP{192, 168, 127, 1}, 24),
+				ResourceGroup: "rg",
+				Volumes:       tcase.volumes,
+			}, common.CreateOptions{SkipCapacityCheck: true, NoStart: true})
+			var validationErr common.ValidationError
+			assert.ErrorAs(t, err, &validationErr)
+			assert.Empty(t, m.writtenFiles)
+		})
+	}
+}
+
+func TestOverwrite_RemovedVolume(t *testing.T) {
+	t.Parallel()
+	// the overwrite is rejected before the running target is stopped, which
+	// would lock it
+	m := &mockLinstor{}
+	props := &mockControllerProps{}
+	n := &NVMeoF{cli: &linstorcontrol.Linstor{Client: &client.Client{
+		Controller:          mockController{mockControllerProps: props, m: m},
+		ResourceGroups:      mockResourceGroups{},
+		ResourceDefinitions: mockResourceDefinitions{m: m},
+		Resources:           mockResources{},
+	}}}
+
+	deployed := &ResourceConfig{
+		NQN:           Nqn{"nqn.2021-08.com.example.test", "example"},
+		ServiceIP:     common.ServiceIPFromParts(net.IP{192, 168, 127, 1}, 24),
+		ResourceGroup: "rg",
+		Volumes:       []common.VolumeConfig{common.ClusterPrivateVolume(), {Number: 1, SizeKiB: 1024}, {Number: 2, SizeKiB: 1024}},
+	}
+	requested := &ResourceConfig{
+		NQN:           deployed.NQN,
+		ServiceIP:     deployed.ServiceIP,
+		ResourceGroup: "rg",
+		Volumes:       []common.VolumeConfig{common.ClusterPrivateVolume(), {Number: 1, SizeKiB: 1024}},
+	}
+
+	_, err := n.overwrite(context.Background(), deployed, requested, common.ResourceStatus{Service: common.ServiceStateStarted})
+	var validationErr common.ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+	assert.ErrorContains(t, err, "cannot remove existing volume 2, use delete-volume to remove it")
+	assert.Empty(t, props.props)
+	assert.Empty(t, m.writtenFiles)
+}
@@ -3,7 +3,25 @@ package nvmeof
 import (
 	"encoding/json"
 	"fmt"
+	"regexp"
 	"strings"
+
+	"github.com/LINBIT/linstor-gateway/pkg/common"
+)
+
+const (
+	// maxNqnLength is the maximum length of an NQN as defined by the NVMe
+	// specification.
+	maxNqnLength = 223
+	// maxSubsystemLength is the maximum length of a LINSTOR resource name.
+	maxSubsystemLength = 48
+	exampleNqn         = "nqn.2021-08.com.linbit:nvme:example"
+)
+
+var (
+	regexNqnVendor  = regexp.MustCompile(`^nqn\.\d{4}-[0-1][0-9]\.[[:alnum:]-]+(\.[[:alnum:]-]+)+$`)
+	regexVendorName = regexp.MustCompile(`^[[:alnum:]][[:alnum:].-]*$`)
+	regexSubsystem  = regexp.MustCompile(`^[[:alpha:]_][[:alnum:]_-]+$`)
 )
 
 // Nqn represents a conventional nvme qualified name
@@ -31,7 +49,19 @@ func (n *Nqn) UnmarshalText(text []byte) error {
 	s := string(text)
 	parts := strings.Split(s, ":")
 	if len(parts) != 3 {
-		return malformedNqn(s)
+		return malformedNqn{nqn: s, reason: fmt.Sprintf("expected 3 parts separated by \":\", got %d", len(parts))}
+	}
+
+	if parts[1] != "nvme" {
+		return malformedNqn{nqn: s, reason: fmt.Sprintf("the middle part must be \"nvme\", not \"%s\"", parts[1])}
+	}
+
+	if parts[0] == "" {
+		return malformedNqn{nqn: s, reason: "the vendor part is empty"}
+	}
+
+	if parts[2] == "" {
+		return malformedNqn{nqn: s, reason: "the subsystem part is empty"}
 	}
 
 	n[0] = parts[0]
@@ -39,6 +69,33 @@ func (n *Nqn) UnmarshalText(text []byte) error {
 	return nil
 }
 
+// Validate checks that the NQN is suitable for creating a new target. This is
+// stricter than parsing, so that existing targets keep working.
+//
+// The vendor part is either a plain vendor name ("linbit") or follows the
+// "nqn.YYYY-MM.reverse.domain" format from the NVMe specification. The
+// subsystem part is used as the name of the LINSTOR resource, so it has to be
+// a valid resource name.
+func (n Nqn) Validate() error {
+	if len(n.String()) > maxNqnLength {
+		return malformedNqn{nqn: n.String(), reason: fmt.Sprintf("it is longer than %d characters", maxNqnLength)}
+	}
+
+	if strings.HasPrefix(n.Vendor(), "nqn.") {
+		if !regexNqnVendor.MatchString(n.Vendor()) {
+			return malformedNqn{nqn: n.String(), reason: fmt.Sprintf("the vendor part \"%s\" starts with \"nqn.\", but is not of the form nqn.YYYY-MM.reverse.domain", n.Vendor())}
+		}
+	} else if !regexVendorName.MatchString(n.Vendor()) {
+		return malformedNqn{nqn: n.String(), reason: fmt.Sprintf("the vendor part \"%s\" may only contain letters, digits, \".\" and \"-\"", n.Vendor())}
+	}
+
+	if !regexSubsystem.MatchString(n.Subsystem()) || len(n.Subsystem()) > maxSubsystemLength {
+		return malformedNqn{nqn: n.String(), reason: fmt.Sprintf("the subsystem part \"%s\" is used as LINSTOR resource name, so it must be 2 to %d characters long, start with a letter or \"_\", and contain only letters, digits, \"_\" and \"-\"", n.Subsystem(), maxSubsystemLength)}
+	}
+
+	return nil
+}
+
 func (n *Nqn) UnmarshalJSON(text []byte) error {
 	var s string
 	err := json.Unmarshal(text, &s)
@@ -60,8 +117,16 @@ func (n Nqn) String() string {
 	return fmt.Sprintf("%s:nvme:%s", n[0], n[1])
 }
 
-type malformedNqn string
+type malformedNqn struct {
+	nqn    string
+	reason string
+}
 
 func (m malformedNqn) Error() string {
-	return fmt.Sprintf("NQN '%s' malformed, expected <vendor>:nvme:<subsystem>", string(m))
+	return fmt.Sprintf("NQN '%s' malformed: %s. expected <vendor>:nvme:<subsystem>, for example %s", m.nqn, m.reason, exampleNqn)
+}
+
+// Unwrap allows callers to detect a malformed NQN as common.ValidationError.
+func (m malformedNqn) Unwrap() error {
+	return common.ValidationError(m.reason)
 }
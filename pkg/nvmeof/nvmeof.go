@@ -5,9 +5,11 @@ import (
 	"errors"
 	"fmt"
 	"sort"
+	"strings"
 	"time"
 
 	"github.com/LINBIT/golinstor/client"
+	"github.com/google/go-cmp/cmp"
 	"github.com/google/uuid"
 	log "github.com/sirupsen/logrus"
 
@@ -30,6 +32,12 @@ func New(controllers []string) (*NVMeoF, error) {
 	return &NVMeoF{cli}, nil
 }
 
+// NewWithClient creates a new NVMeoF instance that uses an existing LINSTOR
+// client.
+func NewWithClient(cli *linstorcontrol.Linstor) *NVMeoF {
+	return &NVMeoF{cli}
+}
+
 func (n *NVMeoF) Get(ctx context.Context, nqn Nqn) (*ResourceConfig, error) {
 	cfg, path, err := reactor.FindConfig(ctx, n.cli.Client, fmt.Sprintf(IDFormat, nqn.Subsystem()))
 	if err != nil {
@@ -37,7 +45,7 @@ func (n *NVMeoF) Get(ctx context.Context, nqn Nqn) (*ResourceConfig, error) {
 	}
 
 	if cfg == nil {
-		return nil, nil
+		return nil, fmt.Errorf("target \"%s\" %w", nqn, common.ErrNotFound)
 	}
 
 	resourceDefinition, resourceGroup, volumeDefinitions, resources, err := cfg.DeployedResources(ctx, n.cli.Client)
@@ -45,12 +53,20 @@ func (n *NVMeoF) Get(ctx context.Context, nqn Nqn) (*ResourceConfig, error) {
 		return nil, fmt.Errorf("failed to fetch existing deployment: %w", err)
 	}
 
+	inconsistent := common.CheckVolumeCount(cfg, volumeDefinitions, volumeAgentTypes...)
+
 	deployedCfg, err := FromPromoter(cfg, resourceDefinition, volumeDefinitions)
 	if err != nil {
+		if inconsistent != nil {
+			return nil, fmt.Errorf("target \"%s\" is %w", nqn, inconsistent)
+		}
 		return nil, fmt.Errorf("unknown existing reactor config: %w", err)
 	}
 
 	deployedCfg.Status = linstorcontrol.StatusFromResources(path, resourceDefinition, resourceGroup, resources)
+	if inconsistent != nil {
+		deployedCfg.Status.MarkInconsistent(inconsistent)
+	}
 
 	return deployedCfg, nil
 }
@@ -58,9 +74,27 @@ func (n *NVMeoF) Get(ctx context.Context, nqn Nqn) (*ResourceConfig, error) {
 // Create creates an NVMe-oF target according to the resource configuration
 // described in rsc. It automatically prepends a "cluster private volume" to the
 // list of volumes, so volume numbers must start at 1.
-func (n *NVMeoF) Create(ctx context.Context, rsc *ResourceConfig) (*ResourceConfig, error) {
+//
+// If a later step fails after the LINSTOR resource was created, the resource
+// is deleted again, unless opts.KeepOnFailure is set.
+//
+// If the target already exists with a different config, an error is returned,
+// unless opts.Overwrite is set. In that case, the existing target is changed to
+// match rsc.
+//
+// Before a new resource is created, the storage pools of the resource group
+// are checked for enough free space, unless opts.SkipCapacityCheck is set. A
+// new target is started, unless opts.NoStart is set.
+func (n *NVMeoF) Create(ctx context.Context, rsc *ResourceConfig, opts common.CreateOptions) (*ResourceConfig, error) {
 	rsc.FillDefaults()
 
+	for _, vol := range rsc.Volumes {
+		err := common.ValidUserVolumeNumber(vol.Number)
+		if err != nil {
+			return nil, fmt.Errorf("validation failed: %w", err)
+		}
+	}
+
 	// prepend cluster private volume; it should always be the first volume and have number 0
 	rsc.Volumes = append([]common.VolumeConfig{common.ClusterPrivateVolume()}, rsc.Volumes...)
 
@@ -69,6 +103,24 @@ func (n *NVMeoF) Create(ctx context.Context, rsc *ResourceConfig) (*ResourceConf
 		return nil, fmt.Errorf("validation failed: %w", err)
 	}
 
+	// waiting for the sync outlives the locks, which cancel their context
+	// when they are released
+	syncCtx := ctx
+
+	ctx, unlock, err := n.cli.Lock(ctx, rsc.NQN.Subsystem())
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	// Cleanup locks the LINSTOR resource, so that it does not delete it
+	// before the config is written.
+	ctx, unlockResource, err := n.cli.Lock(ctx, rsc.linstorResourceName())
+	if err != nil {
+		return nil, err
+	}
+	defer unlockResource()
+
 	cfg, path, err := reactor.FindConfig(ctx, n.cli.Client, fmt.Sprintf(IDFormat, rsc.NQN.Subsystem()))
 	if err != nil {
 		return nil, fmt.Errorf("failed to check for existing config: %w", err)
@@ -85,25 +137,76 @@ func (n *NVMeoF) Create(ctx context.Context, rsc *ResourceConfig) (*ResourceConf
 			return nil, fmt.Errorf("unknown existing reactor config: %w", err)
 		}
 
+		status := linstorcontrol.StatusFromResources(path, resourceDefinition, resourceGroup, resources)
+
+		// keep the LINSTOR resource name unless a different one is requested
+		if rsc.ResourceName == "" {
+			rsc.ResourceName = deployedCfg.ResourceName
+		}
+
 		if !rsc.Matches(deployedCfg) {
-			return nil, errors.New("resource already exists with incompatible config")
+			if !opts.Overwrite {
+				return nil, common.IncompatibleConfigError(deployedCfg, rsc)
+			}
+
+			return n.overwrite(ctx, deployedCfg, rsc, status)
 		}
 
-		deployedCfg.Status = linstorcontrol.StatusFromResources(path, resourceDefinition, resourceGroup, resources)
+		deployedCfg.Status = status
 
 		return deployedCfg, nil
 	}
 
+	rsc.ResourceName = rsc.linstorResourceName()
+
+	err = n.cli.CheckResourceGroup(ctx, rsc.ResourceGroup)
+	if err != nil {
+		return nil, err
+	}
+
+	if !opts.SkipCapacityCheck {
+		err = n.cli.CheckCapacity(ctx, rsc.ResourceGroup, rsc.PlacementCount, rsc.LayerList, rsc.StoragePool, rsc.Volumes)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	resourceDefinition, resourceGroup, deployment, err := n.cli.EnsureResource(ctx, linstorcontrol.Resource{
-		Name:          rsc.NQN.Subsystem(),
-		ResourceGroup: rsc.ResourceGroup,
-		Volumes:       rsc.Volumes,
-		GrossSize:     rsc.GrossSize,
+		Name:           rsc.linstorResourceName(),
+		ResourceGroup:  rsc.ResourceGroup,
+		Volumes:        rsc.Volumes,
+		GrossSize:      rsc.GrossSize,
+		PlacementCount: rsc.PlacementCount,
+		LayerList:      rsc.LayerList,
+		StoragePool:    rsc.StoragePool,
+		DrbdPort:       rsc.DrbdPort,
+		DrbdMinor:      rsc.DrbdMinor,
+		Labels:         rsc.Labels,
+		Description:    rsc.Description,
+		DrbdOptions:    rsc.DrbdOptions,
+		IOLimits:       rsc.ioLimits(),
 	}, false)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create linstor resource: %w", err)
 	}
 
+	// Roll back everything created so far if one of the following steps
+	// fails, so that no orphaned LINSTOR resource is left behind.
+	success := false
+	defer func() {
+		if success || opts.KeepOnFailure {
+			return
+		}
+		// Roll back even if the operation was cancelled.
+		ctx, cancel := context.WithTimeout(context.Background(), common.RollbackTimeout)
+		defer cancel()
+		log.WithField("target", rsc.NQN).Info("rolling back partially created target")
+		err := n.delete(ctx, rsc.NQN, rsc.linstorResourceName(), common.DeleteOptions{})
+		if err != nil {
+			log.WithError(err).Warnf("failed to roll back target \"%s\"", rsc.NQN)
+		}
+	}()
+
 	cfg, err = rsc.ToPromoter(deployment)
 	if err != nil {
 		return nil, fmt.Errorf("failed to convert resource to promoter configuration: %w", err)
@@ -114,9 +217,25 @@ func (n *NVMeoF) Create(ctx context.Context, rsc *ResourceConfig) (*ResourceConf
 		return nil, fmt.Errorf("failed to register reactor config file: %w", err)
 	}
 
-	_, err = n.Start(ctx, rsc.NQN)
-	if err != nil {
-		return nil, fmt.Errorf("failed to start resources: %w", err)
+	if !opts.NoStart {
+		_, err = n.Start(ctx, rsc.NQN)
+		if err != nil {
+			return nil, fmt.Errorf("failed to start resources: %w", err)
+		}
+	}
+
+	success = true
+
+	if opts.WaitSync {
+		// The initial sync can take hours, don't block other operations
+		// on the new resource meanwhile.
+		unlockResource()
+		unlock()
+
+		deployment, err = n.cli.WaitSynced(syncCtx, rsc.linstorResourceName(), resourceDefinition, resourceGroup, opts.SyncTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("the target was created, but %w", err)
+		}
 	}
 
 	rsc.Status = linstorcontrol.StatusFromResources(path, resourceDefinition, resourceGroup, deployment)
@@ -124,14 +243,96 @@ func (n *NVMeoF) Create(ctx context.Context, rsc *ResourceConfig) (*ResourceConf
 	return rsc, nil
 }
 
+// overwrite changes the deployed target to match rsc. A running target is
+// stopped while its config is replaced, and started again afterwards.
+func (n *NVMeoF) overwrite(ctx context.Context, deployedCfg, rsc *ResourceConfig, status common.ResourceStatus) (*ResourceConfig, error) {
+	if deployedCfg.linstorResourceName() != rsc.linstorResourceName() {
+		return nil, fmt.Errorf("failed to overwrite existing target: %w", common.ValidationError(fmt.Sprintf("cannot change the LINSTOR resource name from %s to %s", deployedCfg.linstorResourceName(), rsc.linstorResourceName())))
+	}
+
+	err := common.CheckOverwrite(deployedCfg.ResourceGroup, rsc.ResourceGroup, deployedCfg.Volumes, rsc.Volumes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to overwrite existing target: %w", err)
+	}
+
+	err = common.CheckCreateOnly(deployedCfg.createOnlySettings(), rsc.createOnlySettings())
+	if err != nil {
+		return nil, fmt.Errorf("failed to overwrite existing target: %w", err)
+	}
+
+	log.WithField("target", rsc.NQN).Infof("overwriting existing target, diff: %s", cmp.Diff(deployedCfg, rsc, common.IgnoreSecrets))
+
+	started := status.Service.Active()
+	if started {
+		_, err = n.Stop(ctx, rsc.NQN)
+		if err != nil {
+			return nil, fmt.Errorf("failed to stop target: %w", err)
+		}
+	}
+
+	_, _, deployment, err := n.cli.EnsureResource(ctx, linstorcontrol.Resource{
+		Name:           rsc.linstorResourceName(),
+		ResourceGroup:  rsc.ResourceGroup,
+		Volumes:        rsc.Volumes,
+		GrossSize:      rsc.GrossSize,
+		PlacementCount: rsc.PlacementCount,
+		LayerList:      rsc.LayerList,
+		StoragePool:    rsc.StoragePool,
+		IOLimits:       rsc.ioLimits(),
+	}, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reconcile linstor resource: %w", err)
+	}
+
+	if rsc.Description != deployedCfg.Description {
+		err = n.cli.SetDescription(ctx, rsc.linstorResourceName(), rsc.Description)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	cfg, err := rsc.ToPromoter(deployment)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert resource to promoter configuration: %w", err)
+	}
+
+	err = reactor.EnsureConfig(ctx, n.cli.Client, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update config: %w", err)
+	}
+
+	if started {
+		return n.Start(ctx, rsc.NQN)
+	}
+
+	return n.Get(ctx, rsc.NQN)
+}
+
 func (n *NVMeoF) Start(ctx context.Context, nqn Nqn) (*ResourceConfig, error) {
-	cfg, _, err := reactor.FindConfig(ctx, n.cli.Client, fmt.Sprintf(IDFormat, nqn.Subsystem()))
+	ctx, unlock, err := n.cli.Lock(ctx, nqn.Subsystem())
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	cfg, path, err := reactor.FindConfig(ctx, n.cli.Client, fmt.Sprintf(IDFormat, nqn.Subsystem()))
 	if err != nil {
 		return nil, fmt.Errorf("failed to find the resource configuration: %w", err)
 	}
 
 	if cfg == nil {
-		return nil, nil
+		return nil, fmt.Errorf("target \"%s\" %w", nqn, common.ErrNotFound)
+	}
+
+	resourceDefinition, resourceGroup, _, resources, err := cfg.DeployedResources(ctx, n.cli.Client)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch existing deployment: %w", err)
+	}
+
+	status := linstorcontrol.StatusFromResources(path, resourceDefinition, resourceGroup, resources)
+	if status.Running() {
+		log.WithField("target", nqn).Debug("target is already started")
+		return n.Get(ctx, nqn)
 	}
 
 	err = reactor.AttachConfig(ctx, n.cli.Client, cfg)
@@ -139,25 +340,45 @@ func (n *NVMeoF) Start(ctx context.Context, nqn Nqn) (*ResourceConfig, error) {
 		return nil, fmt.Errorf("failed to detach reactor configuration: %w", err)
 	}
 
-	waitCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
-	defer cancel()
-
-	err = common.WaitUntilResourceCondition(waitCtx, n.cli.Client, nqn.Subsystem(), common.AnyResourcesInUse)
+	err = linstorcontrol.WaitForStart(ctx, linstorcontrol.DefaultStartWait, nqn.String(), func(ctx context.Context) (common.ResourceStatus, error) {
+		resourceDefinition, resourceGroup, _, resources, err := cfg.DeployedResources(ctx, n.cli.Client)
+		if err != nil {
+			return common.ResourceStatus{}, err
+		}
+		return linstorcontrol.StatusFromResources(path, resourceDefinition, resourceGroup, resources), nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("error waiting for resource to become used: %w", err)
+		return nil, err
 	}
 
 	return n.Get(ctx, nqn)
 }
 
 func (n *NVMeoF) Stop(ctx context.Context, nqn Nqn) (*ResourceConfig, error) {
-	cfg, _, err := reactor.FindConfig(ctx, n.cli.Client, fmt.Sprintf(IDFormat, nqn.Subsystem()))
+	ctx, unlock, err := n.cli.Lock(ctx, nqn.Subsystem())
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	cfg, path, err := reactor.FindConfig(ctx, n.cli.Client, fmt.Sprintf(IDFormat, nqn.Subsystem()))
 	if err != nil {
 		return nil, fmt.Errorf("failed to find the resource configuration: %w", err)
 	}
 
 	if cfg == nil {
-		return nil, nil
+		return nil, fmt.Errorf("target \"%s\" %w", nqn, common.ErrNotFound)
+	}
+
+	resourceDefinition, resourceGroup, _, resources, err := cfg.DeployedResources(ctx, n.cli.Client)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch existing deployment: %w", err)
+	}
+
+	status := linstorcontrol.StatusFromResources(path, resourceDefinition, resourceGroup, resources)
+	if status.Stopped() {
+		log.WithField("target", nqn).Debug("target is already stopped")
+		return n.Get(ctx, nqn)
 	}
 
 	err = reactor.DetachConfig(ctx, n.cli.Client, cfg)
@@ -165,10 +386,7 @@ func (n *NVMeoF) Stop(ctx context.Context, nqn Nqn) (*ResourceConfig, error) {
 		return nil, fmt.Errorf("failed to detach reactor configuration: %w", err)
 	}
 
-	waitCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
-	defer cancel()
-
-	err = common.WaitUntilResourceCondition(waitCtx, n.cli.Client, nqn.Subsystem(), common.NoResourcesInUse)
+	err = common.WaitUntilResourceCondition(ctx, n.cli.Client, cfg.ResourceName(), common.DefaultWaitTimeout, common.NoResourcesInUse)
 	if err != nil {
 		return nil, fmt.Errorf("error waiting for resource to become unused: %w", err)
 	}
@@ -176,8 +394,21 @@ func (n *NVMeoF) Stop(ctx context.Context, nqn Nqn) (*ResourceConfig, error) {
 	return n.Get(ctx, nqn)
 }
 
+// List returns all targets.
 func (n *NVMeoF) List(ctx context.Context) ([]*ResourceConfig, error) {
-	cfgs, paths, err := reactor.ListConfigs(ctx, n.cli.Client)
+	return n.ListPage(ctx, 0, 0)
+}
+
+// ListPage returns the targets, ordered by their drbd-reactor config file,
+// skipping the first offset ones and returning at most limit. A limit of 0
+// returns all remaining targets. The deployed resources are only fetched for
+// the returned targets.
+func (n *NVMeoF) ListPage(ctx context.Context, offset, limit int) ([]*ResourceConfig, error) {
+	cfgs, paths, err := reactor.ListConfigsPage(ctx, n.cli.Client, reactor.ListOptions{
+		IDPrefix: strings.TrimSuffix(IDFormat, "%s"),
+		Offset:   offset,
+		Limit:    limit,
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -199,13 +430,22 @@ func (n *NVMeoF) List(ctx context.Context) ([]*ResourceConfig, error) {
 			log.WithError(err).Warn("failed to fetch deployed resources")
 		}
 
+		inconsistent := common.CheckVolumeCount(cfg, volumeDefinitions, volumeAgentTypes...)
+
 		parsed, err := FromPromoter(cfg, resourceDefinition, volumeDefinitions)
 		if err != nil {
+			if inconsistent != nil {
+				log.WithField("id", cfg.ID).Warnf("skipping target that is %s", inconsistent)
+				continue
+			}
 			log.WithError(err).Warn("skipping error while parsing promoter config")
 			continue
 		}
 
 		parsed.Status = linstorcontrol.StatusFromResources(path, resourceDefinition, resourceGroup, resources)
+		if inconsistent != nil {
+			parsed.Status.MarkInconsistent(inconsistent)
+		}
 
 		result = append(result, parsed)
 	}
@@ -213,21 +453,66 @@ func (n *NVMeoF) List(ctx context.Context) ([]*ResourceConfig, error) {
 	return result, nil
 }
 
-func (n *NVMeoF) Delete(ctx context.Context, nqn Nqn) error {
+// Watch polls the NVMe-oF targets every interval and emits an event whenever
+// one of them changes its state. The returned channel is closed when ctx is
+// cancelled.
+func (n *NVMeoF) Watch(ctx context.Context, interval time.Duration) (<-chan common.Event, error) {
+	return common.Watch(ctx, "nvme-of", interval, func(ctx context.Context) (map[string]common.ResourceStatus, error) {
+		targets, err := n.List(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		result := make(map[string]common.ResourceStatus, len(targets))
+		for _, target := range targets {
+			result[target.NQN.String()] = target.Status
+		}
+		return result, nil
+	})
+}
+
+// Delete removes a target: its drbd-reactor config and, unless
+// opts.KeepResource is set, its LINSTOR resource.
+func (n *NVMeoF) Delete(ctx context.Context, nqn Nqn, opts common.DeleteOptions) error {
+	ctx, unlock, err := n.cli.Lock(ctx, nqn.Subsystem())
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	cfg, _, err := reactor.FindConfig(ctx, n.cli.Client, fmt.Sprintf(IDFormat, nqn.Subsystem()))
+	if err != nil {
+		return fmt.Errorf("failed to check for existing config: %w", err)
+	}
+
+	// the LINSTOR resource may have a name other than the subsystem
+	rscName := nqn.Subsystem()
+	if cfg != nil && cfg.ResourceName() != "" {
+		rscName = cfg.ResourceName()
+	}
+
+	return n.delete(ctx, nqn, rscName, opts)
+}
+
+// delete removes the reactor config of the target and, unless
+// opts.KeepResource is set, the LINSTOR resource rscName backing it.
+func (n *NVMeoF) delete(ctx context.Context, nqn Nqn, rscName string, opts common.DeleteOptions) error {
 	err := reactor.DeleteConfig(ctx, n.cli.Client, fmt.Sprintf(IDFormat, nqn.Subsystem()))
 	if err != nil {
 		return fmt.Errorf("failed to delete reactor config: %w", err)
 	}
 
-	waitCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
-	defer cancel()
-
-	err = common.WaitUntilResourceCondition(waitCtx, n.cli.Client, nqn.Subsystem(), common.NoResourcesInUse)
+	err = common.WaitUntilResourceCondition(ctx, n.cli.Client, rscName, common.DefaultWaitTimeout, common.NoResourcesInUse)
 	if err != nil {
 		return fmt.Errorf("error waiting for resource to become unused: %w", err)
 	}
 
-	err = n.cli.ResourceDefinitions.Delete(ctx, nqn.Subsystem())
+	if opts.KeepResource {
+		log.WithField("resource", rscName).Info("keeping LINSTOR resource")
+		return n.cli.MarkKept(ctx, rscName)
+	}
+
+	err = n.cli.ResourceDefinitions.Delete(ctx, rscName)
 	if err != nil && err != client.NotFoundError {
 		return fmt.Errorf("failed to delete resources: %w", err)
 	}
@@ -235,14 +520,51 @@ func (n *NVMeoF) Delete(ctx context.Context, nqn Nqn) error {
 	return nil
 }
 
+// SetDescription changes the description of an existing target. An empty
+// description removes it.
+func (n *NVMeoF) SetDescription(ctx context.Context, nqn Nqn, description string) (*ResourceConfig, error) {
+	err := common.ValidDescription(description)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, unlock, err := n.cli.Lock(ctx, nqn.Subsystem())
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	cfg, _, err := reactor.FindConfig(ctx, n.cli.Client, fmt.Sprintf(IDFormat, nqn.Subsystem()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to check for existing config: %w", err)
+	}
+
+	if cfg == nil {
+		return nil, fmt.Errorf("target \"%s\" %w", nqn, common.ErrNotFound)
+	}
+
+	err = n.cli.SetDescription(ctx, cfg.ResourceName(), description)
+	if err != nil {
+		return nil, err
+	}
+
+	return n.Get(ctx, nqn)
+}
+
 func (n *NVMeoF) AddVolume(ctx context.Context, nqn Nqn, volCfg *common.VolumeConfig) (*ResourceConfig, error) {
+	ctx, unlock, err := n.cli.Lock(ctx, nqn.Subsystem())
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
 	cfg, path, err := reactor.FindConfig(ctx, n.cli.Client, fmt.Sprintf(IDFormat, nqn.Subsystem()))
 	if err != nil {
 		return nil, fmt.Errorf("failed to check for existing config: %w", err)
 	}
 
 	if cfg == nil {
-		return nil, nil
+		return nil, fmt.Errorf("target \"%s\" %w", nqn, common.ErrNotFound)
 	}
 
 	resourceDefinition, resourceGroup, volumeDefinitions, resources, err := cfg.DeployedResources(ctx, n.cli.Client)
@@ -255,6 +577,13 @@ func (n *NVMeoF) AddVolume(ctx context.Context, nqn Nqn, volCfg *common.VolumeCo
 		return nil, fmt.Errorf("unknown existing reactor config: %w", err)
 	}
 
+	err = common.ValidGrossSize(*volCfg, deployedCfg.GrossSize)
+	if err != nil {
+		return nil, err
+	}
+	// the volume is created with the size semantics of the target
+	volCfg.GrossSize = nil
+
 	exists := false
 	for i := range deployedCfg.Volumes {
 		if deployedCfg.Volumes[i].Number == volCfg.Number {
@@ -268,10 +597,12 @@ func (n *NVMeoF) AddVolume(ctx context.Context, nqn Nqn, volCfg *common.VolumeCo
 	}
 
 	if !exists {
+		// Unlike iSCSI and NFS, NVMe-oF supports adding namespaces to a
+		// running subsystem, so there is no need to stop the target. The
+		// new namespace is brought up once drbd-reactor picks up the
+		// updated promoter config.
 		status := linstorcontrol.StatusFromResources(path, resourceDefinition, resourceGroup, resources)
-		if status.Service == common.ServiceStateStarted {
-			return nil, errors.New("cannot add volume while service is running")
-		}
+		live := status.Service.Active()
 
 		deployedCfg.Volumes = append(deployedCfg.Volumes, *volCfg)
 
@@ -285,13 +616,32 @@ func (n *NVMeoF) AddVolume(ctx context.Context, nqn Nqn, volCfg *common.VolumeCo
 		}
 
 		resourceDefinition, resourceGroup, resources, err = n.cli.EnsureResource(ctx, linstorcontrol.Resource{
-			Name:          deployedCfg.NQN.Subsystem(),
-			ResourceGroup: deployedCfg.ResourceGroup,
-			Volumes:       deployedCfg.Volumes,
+			Name:           deployedCfg.linstorResourceName(),
+			ResourceGroup:  deployedCfg.ResourceGroup,
+			Volumes:        deployedCfg.Volumes,
+			GrossSize:      deployedCfg.GrossSize,
+			PlacementCount: deployedCfg.PlacementCount,
+			LayerList:      deployedCfg.LayerList,
+			StoragePool:    deployedCfg.StoragePool,
+			IOLimits:       deployedCfg.ioLimits(),
 		}, true)
 		if err != nil {
 			return nil, fmt.Errorf("failed to reconcile linstor resource: %w", err)
 		}
+
+		if live {
+			log.WithFields(log.Fields{
+				"target": nqn,
+				"nsid":   volCfg.Number,
+			}).Info("adding namespace to running target")
+
+			// The namespace can only be activated once its data is
+			// accessible from the primary.
+			err = common.WaitUntilResourceCondition(ctx, n.cli.Client, deployedCfg.linstorResourceName(), common.DefaultWaitTimeout, common.VolumeUpToDate(volCfg.Number))
+			if err != nil {
+				return nil, fmt.Errorf("error waiting for new volume to become up to date: %w", err)
+			}
+		}
 	}
 
 	cfg, err = deployedCfg.ToPromoter(resources)
@@ -310,13 +660,19 @@ func (n *NVMeoF) AddVolume(ctx context.Context, nqn Nqn, volCfg *common.VolumeCo
 }
 
 func (n *NVMeoF) DeleteVolume(ctx context.Context, nqn Nqn, nsid int) (*ResourceConfig, error) {
+	ctx, unlock, err := n.cli.Lock(ctx, nqn.Subsystem())
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
 	cfg, path, err := reactor.FindConfig(ctx, n.cli.Client, fmt.Sprintf(IDFormat, nqn.Subsystem()))
 	if err != nil {
 		return nil, fmt.Errorf("failed to delete reactor config: %w", err)
 	}
 
 	if cfg == nil {
-		return nil, nil
+		return nil, fmt.Errorf("target \"%s\" %w", nqn, common.ErrNotFound)
 	}
 
 	resourceDefinition, resourceGroup, volumeDefinition, resources, err := cfg.DeployedResources(ctx, n.cli.Client)
@@ -330,22 +686,20 @@ func (n *NVMeoF) DeleteVolume(ctx context.Context, nqn Nqn, nsid int) (*Resource
 	}
 
 	status := linstorcontrol.StatusFromResources(path, resourceDefinition, resourceGroup, resources)
-	if status.Service == common.ServiceStateStarted {
+	if status.Service.Active() {
 		return nil, errors.New("cannot delete volume while service is running")
 	}
 
 	for i := range rscCfg.Volumes {
 		if rscCfg.Volumes[i].Number == nsid {
-			err = n.cli.ResourceDefinitions.DeleteVolumeDefinition(ctx, nqn.Subsystem(), nsid)
+			err = n.cli.ResourceDefinitions.DeleteVolumeDefinition(ctx, cfg.ResourceName(), nsid)
 			if err != nil && err != client.NotFoundError {
 				return nil, fmt.Errorf("failed to delete volume definition")
 			}
 
 			rscCfg.Volumes = append(rscCfg.Volumes[:i], rscCfg.Volumes[i+1:]...)
 			// Manually delete the resources from the current resource config
-			for j := range resources {
-				resources[j].Volumes = append(resources[j].Volumes[:i], resources[j].Volumes[i+1:]...)
-			}
+			common.RemoveVolume(resources, nsid)
 
 			cfg, err = rscCfg.ToPromoter(resources)
 			if err != nil {
@@ -363,3 +717,86 @@ func (n *NVMeoF) DeleteVolume(ctx context.Context, nqn Nqn, nsid int) (*Resource
 
 	return n.Get(ctx, nqn)
 }
+
+// AddAllowedHost adds a host NQN to the list of hosts that may connect to the
+// target. Adding a host that is already allowed is not an error.
+// The nvmet-subsystem agent only applies the list when it is started, so a
+// running target keeps its current list until it is restarted.
+func (n *NVMeoF) AddAllowedHost(ctx context.Context, nqn Nqn, host string) (*ResourceConfig, error) {
+	err := ValidHostNqn(host)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, unlock, err := n.cli.Lock(ctx, nqn.Subsystem())
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	return n.modifyAllowedHosts(ctx, nqn, func(hosts []string) []string {
+		for _, h := range hosts {
+			if h == host {
+				return hosts
+			}
+		}
+		return append(hosts, host)
+	})
+}
+
+// RemoveAllowedHost removes a host NQN from the list of hosts that may connect
+// to the target. Removing the last host allows any host to connect.
+func (n *NVMeoF) RemoveAllowedHost(ctx context.Context, nqn Nqn, host string) (*ResourceConfig, error) {
+	ctx, unlock, err := n.cli.Lock(ctx, nqn.Subsystem())
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	return n.modifyAllowedHosts(ctx, nqn, func(hosts []string) []string {
+		result := make([]string, 0, len(hosts))
+		for _, h := range hosts {
+			if h != host {
+				result = append(result, h)
+			}
+		}
+		return result
+	})
+}
+
+func (n *NVMeoF) modifyAllowedHosts(ctx context.Context, nqn Nqn, modify func(hosts []string) []string) (*ResourceConfig, error) {
+	cfg, path, err := reactor.FindConfig(ctx, n.cli.Client, fmt.Sprintf(IDFormat, nqn.Subsystem()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to check for existing config: %w", err)
+	}
+
+	if cfg == nil {
+		return nil, fmt.Errorf("target \"%s\" %w", nqn, common.ErrNotFound)
+	}
+
+	resourceDefinition, resourceGroup, volumeDefinitions, resources, err := cfg.DeployedResources(ctx, n.cli.Client)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch existing deployment: %w", err)
+	}
+
+	deployedCfg, err := FromPromoter(cfg, resourceDefinition, volumeDefinitions)
+	if err != nil {
+		return nil, fmt.Errorf("unknown existing reactor config: %w", err)
+	}
+
+	deployedCfg.AllowedHosts = modify(deployedCfg.AllowedHosts)
+
+	cfg, err = deployedCfg.ToPromoter(resources)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert resource to promoter configuration: %w", err)
+	}
+
+	err = reactor.EnsureConfig(ctx, n.cli.Client, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update config: %w", err)
+	}
+
+	deployedCfg.Status = linstorcontrol.StatusFromResources(path, resourceDefinition, resourceGroup, resources)
+
+	return deployedCfg, nil
+}
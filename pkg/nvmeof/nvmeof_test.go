@@ -3,6 +3,7 @@ package nvmeof_test
 import (
 	"github.com/icza/gog"
 	"net"
+	"strings"
 	"testing"
 
 	"github.com/LINBIT/golinstor/client"
@@ -10,6 +11,7 @@ import (
 
 	"github.com/LINBIT/linstor-gateway/pkg/common"
 	"github.com/LINBIT/linstor-gateway/pkg/nvmeof"
+	"github.com/LINBIT/linstor-gateway/pkg/reactor"
 )
 
 func TestResource_RoundTrip(t *testing.T) {
@@ -24,6 +26,37 @@ func TestResource_RoundTrip(t *testing.T) {
 			ResourceGroup: "rg1",
 			ServiceIP:     common.ServiceIPFromParts(net.IP{192, 168, 127, 1}, 24),
 		},
+		{
+			NQN: nvmeof.Nqn{"nqn.com.example.test", "restricted"},
+			AllowedHosts: []string{
+				"nqn.2014-08.org.nvmexpress:uuid:0c468c4d-a385-47e0-8299-6e95051277db",
+				"nqn.2014-08.org.nvmexpress:uuid:7d0ad6b3-24c0-4ab6-a3b0-5c7dd4b9f6a2",
+			},
+			Volumes: []common.VolumeConfig{
+				{Number: 2, SizeKiB: 1024},
+			},
+			ResourceGroup: "rg1",
+			ServiceIP:     common.ServiceIPFromParts(net.IP{192, 168, 127, 1}, 24),
+		},
+		{
+			NQN:          nvmeof.Nqn{"nqn.com.example.test", "identified"},
+			Model:        "Example Storage",
+			SerialNumber: "SN-0001",
+			Volumes: []common.VolumeConfig{
+				{Number: 2, SizeKiB: 1024},
+			},
+			ResourceGroup: "rg1",
+			ServiceIP:     common.ServiceIPFromParts(net.IP{192, 168, 127, 1}, 24),
+		},
+		{
+			NQN:          nvmeof.Nqn{"nqn.com.example.test", "renamed"},
+			ResourceName: "custom_name",
+			Volumes: []common.VolumeConfig{
+				{Number: 2, SizeKiB: 1024},
+			},
+			ResourceGroup: "rg1",
+			ServiceIP:     common.ServiceIPFromParts(net.IP{192, 168, 127, 1}, 24),
+		},
 	}
 
 	for i := range testcases {
@@ -48,6 +81,255 @@ func TestResource_RoundTrip(t *testing.T) {
 			assert.Equal(t, tcase.ServiceIP.String(), decoded.ServiceIP.String())
 			assert.Equal(t, tcase.Volumes, decoded.Volumes)
 			assert.Equal(t, tcase.ResourceGroup, decoded.ResourceGroup)
+			assert.Equal(t, tcase.AllowedHosts, decoded.AllowedHosts)
+			assert.Equal(t, tcase.Model, decoded.Model)
+			assert.Equal(t, tcase.SerialNumber, decoded.SerialNumber)
+			assert.Equal(t, tcase.Serial(), decoded.Serial())
+			expectedName := tcase.ResourceName
+			if expectedName == "" {
+				expectedName = tcase.NQN.Subsystem()
+			}
+			assert.Equal(t, expectedName, decoded.ResourceName)
+		})
+	}
+}
+
+func TestFromPromoter_UnknownAgent(t *testing.T) {
+	t.Parallel()
+
+	cfg := nvmeof.ResourceConfig{
+		NQN: nvmeof.Nqn{"nqn.com.example.test", "example-resource"},
+		Volumes: []common.VolumeConfig{
+			{Number: 2, SizeKiB: 1024},
+		},
+		ResourceGroup: "rg1",
+		ServiceIP:     common.ServiceIPFromParts(net.IP{192, 168, 127, 1}, 24),
+	}
+
+	unknown := &reactor.ResourceAgent{Type: "ocf:custom:thing", Name: "thing", Attributes: map[string]string{"foo": "bar"}}
+
+	cases := []struct {
+		name    string
+		modify  func(start []reactor.StartEntry) []reactor.StartEntry
+		wantErr bool
+	}{{
+		name: "extra agent",
+		modify: func(start []reactor.StartEntry) []reactor.StartEntry {
+			return append([]reactor.StartEntry{unknown}, start...)
+		},
+	}, {
+		name: "missing subsystem",
+		modify: func(start []reactor.StartEntry) []reactor.StartEntry {
+			var result []reactor.StartEntry
+			for _, entry := range start {
+				if agent, ok := entry.(*reactor.ResourceAgent); ok && agent.Type == "ocf:heartbeat:nvmet-subsystem" {
+					entry = unknown
+				}
+				result = append(result, entry)
+			}
+			return result
+		},
+		wantErr: true,
+	}}
+
+	for i := range cases {
+		tcase := &cases[i]
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			encoded, err := cfg.ToPromoter([]client.ResourceWithVolumes{
+				{Volumes: []client.Volume{{VolumeNumber: 2, DevicePath: "/dev/drbd1002"}}},
+			})
+			assert.NoError(t, err)
+
+			rsc := encoded.Resources[cfg.NQN.Subsystem()]
+			rsc.Start = tcase.modify(rsc.Start)
+			encoded.Resources[cfg.NQN.Subsystem()] = rsc
+
+			decoded, err := nvmeof.FromPromoter(
+				encoded,
+				&client.ResourceDefinition{ResourceGroupName: "rg1"},
+				[]client.VolumeDefinition{
+					{VolumeNumber: gog.Ptr(int32(2)), SizeKib: 1024},
+				},
+			)
+			if tcase.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, cfg.NQN, decoded.NQN)
+			assert.Equal(t, cfg.ServiceIP.String(), decoded.ServiceIP.String())
+			assert.Equal(t, cfg.Volumes, decoded.Volumes)
+		})
+	}
+}
+
+func TestResourceConfig_ValidModelAndSerial(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name    string
+		model   string
+		serial  string
+		wantErr bool
+	}{{
+		name: "defaults",
+	}, {
+		name:   "explicit",
+		model:  "Example Storage",
+		serial: "SN-0001",
+	}, {
+		name:   "maximum length",
+		model:  strings.Repeat("m", 40),
+		serial: strings.Repeat("s", 20),
+	}, {
+		name:    "model too long",
+		model:   strings.Repeat("m", 41),
+		wantErr: true,
+	}, {
+		name:    "serial too long",
+		serial:  strings.Repeat("s", 21),
+		wantErr: true,
+	}, {
+		name:    "non-ascii model",
+		model:   "Spéicher",
+		wantErr: true,
+	}, {
+		name:    "control character in serial",
+		serial:  "SN\n0001",
+		wantErr: true,
+	}, {
+		name:    "trailing space",
+		serial:  "SN-0001 ",
+		wantErr: true,
+	}}
+
+	for i := range cases {
+		tcase := &cases[i]
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+			cfg := &nvmeof.ResourceConfig{
+				NQN:           nvmeof.Nqn{"nqn.2021-08.com.example", "example"},
+				ServiceIP:     common.ServiceIPFromParts(net.IP{192, 168, 127, 1}, 24),
+				ResourceGroup: "rg1",
+				Volumes:       []common.VolumeConfig{common.ClusterPrivateVolume(), {Number: 1, SizeKiB: 1024}},
+				Model:         tcase.model,
+				SerialNumber:  tcase.serial,
+			}
+			err := cfg.Valid()
+			if tcase.wantErr {
+				assert.Error(t, err)
+				assert.IsType(t, common.ValidationError(""), err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestValidHostNqn(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		host    string
+		wantErr bool
+	}{{
+		host: "nqn.2014-08.org.nvmexpress:uuid:0c468c4d-a385-47e0-8299-6e95051277db",
+	}, {
+		host: "nqn.2021-08.com.example:host1",
+	}, {
+		host:    "host1",
+		wantErr: true,
+	}, {
+		host:    "nqn.2021-08.com.example:host1 nqn.2021-08.com.example:host2",
+		wantErr: true,
+	}, {
+		host:    "nqn." + strings.Repeat("a", 220),
+		wantErr: true,
+	}}
+
+	for i := range cases {
+		tcase := &cases[i]
+		t.Run(tcase.host, func(t *testing.T) {
+			t.Parallel()
+			err := nvmeof.ValidHostNqn(tcase.host)
+			if tcase.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestNqn(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		descr         string
+		input         string
+		parseError    string
+		validateError string
+		subsystem     string
+	}{{
+		descr:     "specification format",
+		input:     "nqn.2021-08.com.linbit:nvme:example",
+		subsystem: "example",
+	}, {
+		descr:     "plain vendor name",
+		input:     "linbit:nvme:example",
+		subsystem: "example",
+	}, {
+		descr:      "missing part",
+		input:      "linbit:example",
+		parseError: "expected 3 parts",
+	}, {
+		descr:      "wrong middle part",
+		input:      "linbit:nvmf:example",
+		parseError: `middle part must be "nvme"`,
+	}, {
+		descr:      "empty subsystem",
+		input:      "linbit:nvme:",
+		parseError: "subsystem part is empty",
+	}, {
+		descr:         "nqn prefix without date",
+		input:         "nqn.com.linbit:nvme:example",
+		validateError: "not of the form nqn.YYYY-MM.reverse.domain",
+		subsystem:     "example",
+	}, {
+		descr:         "invalid vendor name",
+		input:         "lin bit:nvme:example",
+		validateError: `the vendor part "lin bit"`,
+		subsystem:     "example",
+	}, {
+		descr:         "subsystem is not a resource name",
+		input:         "linbit:nvme:1example",
+		validateError: "LINSTOR resource name",
+		subsystem:     "1example",
+	}}
+
+	for i := range cases {
+		tcase := &cases[i]
+		t.Run(tcase.descr, func(t *testing.T) {
+			t.Parallel()
+
+			nqn, err := nvmeof.NewNqn(tcase.input)
+			if tcase.parseError != "" {
+				assert.ErrorContains(t, err, tcase.parseError)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tcase.input, nqn.String())
+			assert.Equal(t, tcase.subsystem, nqn.Subsystem())
+
+			err = nqn.Validate()
+			if tcase.validateError != "" {
+				assert.ErrorContains(t, err, tcase.validateError)
+			} else {
+				assert.NoError(t, err)
+			}
 		})
 	}
 }
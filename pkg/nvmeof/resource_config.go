@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"net"
 	"path/filepath"
+	"regexp"
 	"sort"
 	"strconv"
 	"strings"
@@ -16,19 +17,81 @@ import (
 	"github.com/icza/gog"
 
 	"github.com/LINBIT/linstor-gateway/pkg/common"
+	"github.com/LINBIT/linstor-gateway/pkg/linstorcontrol"
 	"github.com/LINBIT/linstor-gateway/pkg/reactor"
 )
 
+// volumeAgentTypes are the types of the resource agents that each configure
+// one volume of a target.
+var volumeAgentTypes = []string{"ocf:heartbeat:Filesystem", "ocf:heartbeat:nvmet-namespace"}
+
 const IDFormat = "nvmeof-%s"
 const DefaultPort = 4420
 
+// The maximum lengths of the model and serial number of a subsystem, as
+// defined by the NVMe specification.
+const (
+	maxModelLength  = 40
+	maxSerialLength = 20
+)
+
 type ResourceConfig struct {
-	NQN           Nqn                   `json:"nqn"`
-	ServiceIP     common.IpCidr         `json:"service_ip"`
+	NQN Nqn `json:"nqn"`
+	// ResourceName is the name of the LINSTOR resource backing this target.
+	// By default, it is derived from the subsystem part of the NQN.
+	ResourceName string        `json:"resource_name,omitempty"`
+	ServiceIP    common.IpCidr `json:"service_ip"`
+	// AllowedHosts are the NQNs of the hosts that may connect to the
+	// subsystem. If empty, any host may connect.
+	//
+	// Host NQNs do not follow the <vendor>:nvme:<subsystem> scheme of
+	// subsystem NQNs (e.g. "nqn.2014-08.org.nvmexpress:uuid:..."), so they
+	// are kept as plain strings.
+	AllowedHosts []string `json:"allowed_hosts,omitempty"`
+	// Model and SerialNumber are reported to hosts in the identify
+	// controller data of the subsystem. If empty, the model is the kernel's
+	// default, and the serial number is derived from the NQN.
+	Model         string                `json:"model,omitempty"`
+	SerialNumber  string                `json:"serial_number,omitempty"`
 	ResourceGroup string                `json:"resource_group"`
 	Volumes       []common.VolumeConfig `json:"volumes"`
 	Status        common.ResourceStatus `json:"status"`
 	GrossSize     bool                  `json:"gross_size"`
+	// PlacementCount is the number of diskful replicas of the resource, if
+	// it differs from the placement count of the resource group. Diskless
+	// resources, e.g. a tie-breaker, are not counted.
+	PlacementCount int `json:"placement_count,omitempty"`
+	// LayerList overrides the LINSTOR layers of the resource group, e.g.
+	// [DRBD STORAGE]. Without DRBD, the target is not highly available.
+	LayerList []string `json:"layer_list,omitempty"`
+	// StoragePool overrides the storage pools of the resource group, so
+	// that all diskful replicas are placed in this pool. It narrows the
+	// placement: if the pool is not present on enough nodes, the placement
+	// count of the resource group can not be satisfied.
+	StoragePool string `json:"storage_pool,omitempty"`
+	// DrbdPort is the TCP port DRBD uses for the resource, and DrbdMinor
+	// the DRBD minor number of the cluster private volume. The other
+	// volumes use DrbdMinor plus their volume number. If 0, LINSTOR
+	// assigns them. They can only be set when the resource is created.
+	DrbdPort  int `json:"drbd_port,omitempty"`
+	DrbdMinor int `json:"drbd_minor,omitempty"`
+	// Labels organize targets, e.g. by team. They can be used to select
+	// targets when listing them. They can only be set when the resource
+	// is created.
+	Labels map[string]string `json:"labels,omitempty"`
+	// Description is a free text describing the target, e.g. its purpose or
+	// owner. It is stored with the LINSTOR resource.
+	Description string `json:"description,omitempty"`
+	// DrbdOptions override DRBD options of the resource, e.g. the
+	// replication protocol or what happens when the target loses quorum.
+	// Options that are not set use the defaults. They can only be set when
+	// the resource is created.
+	DrbdOptions map[string]string `json:"drbd_options,omitempty"`
+	// ReadLimit and WriteLimit limit the throughput of the target, either
+	// in bytes or in IO operations per second. If nil, the throughput is
+	// not limited.
+	ReadLimit  *common.IOLimit `json:"read_limit,omitempty"`
+	WriteLimit *common.IOLimit `json:"write_limit,omitempty"`
 }
 
 func (r *ResourceConfig) VolumeConfig(number int) *common.Volume {
@@ -61,13 +124,46 @@ func (r *ResourceConfig) ID() string {
 	return fmt.Sprintf(IDFormat, r.NQN.Subsystem())
 }
 
-func parseIP(startEntries []reactor.StartEntry, index int) (common.IpCidr, error) {
-	ipAgent, ok := startEntries[index].(*reactor.ResourceAgent)
-	if !ok {
-		return common.IpCidr{}, fmt.Errorf("expected a resource agent at index %d, got a systemd service", index)
+// createOnlySettings returns the settings of the target that can only be set
+// when its LINSTOR resource is created.
+func (r *ResourceConfig) createOnlySettings() common.CreateOnlySettings {
+	return common.CreateOnlySettings{
+		PlacementCount: r.PlacementCount,
+		LayerList:      r.LayerList,
+		StoragePool:    r.StoragePool,
+		DrbdPort:       r.DrbdPort,
+		DrbdMinor:      r.DrbdMinor,
+		Labels:         r.Labels,
+		DrbdOptions:    r.DrbdOptions,
 	}
-	if ipAgent.Type != "ocf:heartbeat:IPaddr2" {
-		return common.IpCidr{}, fmt.Errorf("expected 'ocf:heartbeat:IPaddr2' agent, got '%s' instead", ipAgent.Type)
+}
+
+// linstorResourceName returns the name of the LINSTOR resource backing this
+// target.
+func (r *ResourceConfig) linstorResourceName() string {
+	if r.ResourceName != "" {
+		return r.ResourceName
+	}
+	return r.NQN.Subsystem()
+}
+
+// findAgent returns the first resource agent of the given type. Agents of
+// other types, including ones unknown to LINSTOR Gateway, are skipped.
+func findAgent(startEntries []reactor.StartEntry, agentType string) (*reactor.ResourceAgent, error) {
+	for _, entry := range startEntries {
+		agent, ok := entry.(*reactor.ResourceAgent)
+		if ok && agent.Type == agentType {
+			return agent, nil
+		}
+	}
+
+	return nil, fmt.Errorf("missing '%s' agent", agentType)
+}
+
+func parseIP(startEntries []reactor.StartEntry) (common.IpCidr, error) {
+	ipAgent, err := findAgent(startEntries, "ocf:heartbeat:IPaddr2")
+	if err != nil {
+		return common.IpCidr{}, err
 	}
 
 	ip := net.ParseIP(ipAgent.Attributes["ip"])
@@ -83,16 +179,86 @@ func parseIP(startEntries []reactor.StartEntry, index int) (common.IpCidr, error
 	return common.ServiceIPFromParts(ip, prefixLength), nil
 }
 
-func parseNQN(startEntries []reactor.StartEntry, index int) (Nqn, error) {
-	subsysAgent, ok := startEntries[index].(*reactor.ResourceAgent)
-	if !ok {
-		return Nqn{}, fmt.Errorf("expected a resource agent at index %d, got a systemd service", index)
+// subsystem are the settings of an nvmet-subsystem agent.
+type subsystem struct {
+	nqn          Nqn
+	allowedHosts []string
+	model        string
+	serial       string
+}
+
+// parseSubsystem returns the settings of the nvmet-subsystem agent.
+func parseSubsystem(startEntries []reactor.StartEntry) (*subsystem, error) {
+	subsysAgent, err := findAgent(startEntries, "ocf:heartbeat:nvmet-subsystem")
+	if err != nil {
+		return nil, err
+	}
+
+	nqn, err := NewNqn(subsysAgent.Attributes["nqn"])
+	if err != nil {
+		return nil, err
 	}
-	if subsysAgent.Type != "ocf:heartbeat:nvmet-subsystem" {
-		return Nqn{}, errors.New(fmt.Sprintf("expected 'ocf:heartbeat:nvmet-subsystem' agent, got '%s' instead", subsysAgent.Type))
+
+	var hosts []string
+	if allowed := subsysAgent.Attributes["allowed_initiators"]; allowed != "" {
+		hosts = strings.Fields(allowed)
 	}
 
-	return NewNqn(subsysAgent.Attributes["nqn"])
+	return &subsystem{
+		nqn:          nqn,
+		allowedHosts: hosts,
+		model:        subsysAgent.Attributes["model"],
+		serial:       subsysAgent.Attributes["serial"],
+	}, nil
+}
+
+// defaultSerial derives the serial number of a subsystem from its NQN.
+func defaultSerial(nqn Nqn) string {
+	digest := sha256.Sum256([]byte(nqn.String()))
+	return hex.EncodeToString(digest[:8])
+}
+
+// Serial returns the serial number the subsystem reports to hosts.
+func (r *ResourceConfig) Serial() string {
+	if r.SerialNumber != "" {
+		return r.SerialNumber
+	}
+
+	return defaultSerial(r.NQN)
+}
+
+// validIdentifyString checks that s can be used as the model or serial number
+// of a subsystem: the NVMe specification only allows printable ASCII
+// characters.
+func validIdentifyString(name, s string, maxLength int) error {
+	if len(s) > maxLength {
+		return common.ValidationError(fmt.Sprintf("%s %q is too long: %d characters, at most %d are allowed", name, s, len(s), maxLength))
+	}
+
+	for _, c := range s {
+		if c < 0x20 || c > 0x7e {
+			return common.ValidationError(fmt.Sprintf("%s %q contains invalid characters: only printable ASCII characters are allowed", name, s))
+		}
+	}
+
+	if strings.TrimSpace(s) != s {
+		return common.ValidationError(fmt.Sprintf("%s %q must not start or end with a space", name, s))
+	}
+
+	return nil
+}
+
+// regexHostNqn matches the NQNs hosts identify themselves with. They are
+// passed to the resource agent as a space separated list.
+var regexHostNqn = regexp.MustCompile(`^nqn\.\S+$`)
+
+// ValidHostNqn checks that host can be used in the list of allowed hosts.
+func ValidHostNqn(host string) error {
+	if !regexHostNqn.MatchString(host) || len(host) > maxNqnLength {
+		return common.ValidationError(fmt.Sprintf("invalid host NQN %q: expected \"nqn.\" followed by at most %d characters without spaces", host, maxNqnLength-len("nqn.")))
+	}
+
+	return nil
 }
 
 func FromPromoter(cfg *reactor.PromoterConfig, definition *client.ResourceDefinition, volumeDefinition []client.VolumeDefinition) (*ResourceConfig, error) {
@@ -104,6 +270,19 @@ func FromPromoter(cfg *reactor.PromoterConfig, definition *client.ResourceDefini
 	}
 
 	r.ResourceGroup = definition.ResourceGroupName
+	r.ResourceName = cfg.ResourceName()
+	r.GrossSize = common.AnyGrossSize(volumeDefinition)
+	r.PlacementCount = linstorcontrol.PlacementCount(definition)
+	r.LayerList = linstorcontrol.LayerList(definition)
+	r.StoragePool = linstorcontrol.StoragePool(definition)
+	r.DrbdPort = linstorcontrol.DrbdPort(definition)
+	r.DrbdMinor = linstorcontrol.DrbdMinor(volumeDefinition)
+	r.Labels = linstorcontrol.Labels(definition)
+	r.Description = linstorcontrol.Description(definition)
+	r.DrbdOptions = linstorcontrol.DrbdOptions(definition)
+
+	limits := linstorcontrol.IOLimitsFromDefinition(definition)
+	r.ReadLimit, r.WriteLimit = limits.Read, limits.Write
 
 	if len(cfg.Resources) != 1 {
 		return nil, errors.New(fmt.Sprintf("promoter config without exactly 1 resource (has %d)", len(cfg.Resources)))
@@ -114,19 +293,20 @@ func FromPromoter(cfg *reactor.PromoterConfig, definition *client.ResourceDefini
 		rscCfg = v
 	}
 
-	if len(rscCfg.Start) < 5 {
-		return nil, errors.New(fmt.Sprintf("config has too few agent entries, expected at least 3, got %d", len(rscCfg.Start)))
-	}
-
-	r.ServiceIP, err = parseIP(rscCfg.Start, 2)
+	r.ServiceIP, err = parseIP(rscCfg.Start)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse service IP: %w", err)
 	}
 
-	r.NQN, err = parseNQN(rscCfg.Start, 3)
+	subsys, err := parseSubsystem(rscCfg.Start)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse NQN: %w", err)
 	}
+
+	r.NQN, r.AllowedHosts, r.Model = subsys.nqn, subsys.allowedHosts, subsys.model
+	if subsys.serial != defaultSerial(r.NQN) {
+		r.SerialNumber = subsys.serial
+	}
 	for _, vd := range volumeDefinition {
 		if vd.VolumeNumber == nil {
 			vd.VolumeNumber = gog.Ptr(int32(0))
@@ -146,9 +326,6 @@ func (r *ResourceConfig) ToPromoter(deployment []client.ResourceWithVolumes) (*r
 	}
 	deployedRes := deployment[0]
 
-	digest := sha256.Sum256([]byte(r.NQN.String()))
-	serial := hex.EncodeToString(digest[:8])
-
 	uuidNS := uuid.NewSHA1(UUIDNVMeoF, []byte(deployedRes.Uuid))
 
 	// volume 0 is reserved as the "cluster private" volume
@@ -166,7 +343,7 @@ func (r *ResourceConfig) ToPromoter(deployment []client.ResourceWithVolumes) (*r
 				"protocol": "tcp",
 			},
 		},
-		common.ClusterPrivateVolumeAgent(clusterPrivateVol, deployedClusterPrivateVol, r.NQN.Subsystem()),
+		common.ClusterPrivateVolumeAgent(clusterPrivateVol, deployedClusterPrivateVol, r.linstorResourceName()),
 		&reactor.ResourceAgent{
 			Type: "ocf:heartbeat:IPaddr2",
 			Name: "service_ip",
@@ -180,13 +357,27 @@ func (r *ResourceConfig) ToPromoter(deployment []client.ResourceWithVolumes) (*r
 			Name: "subsys",
 			Attributes: map[string]string{
 				"nqn":    r.NQN.String(),
-				"serial": serial,
+				"serial": r.Serial(),
 			},
 		},
 	}
 
+	if len(r.AllowedHosts) > 0 {
+		// without this attribute, any host may connect
+		agents[3].(*reactor.ResourceAgent).Attributes["allowed_initiators"] = strings.Join(r.AllowedHosts, " ")
+	}
+
+	if r.Model != "" {
+		// without this attribute, the kernel's default model is used
+		agents[3].(*reactor.ResourceAgent).Attributes["model"] = r.Model
+	}
+
 	for i := 1; i < len(deployedRes.Volumes); i++ {
 		vol := deployedRes.Volumes[i]
+		if i >= len(r.Volumes) {
+			return nil, fmt.Errorf("inconsistent volumes, volume %d is deployed, but not configured", vol.VolumeNumber)
+		}
+
 		if int(vol.VolumeNumber) != r.Volumes[i].Number {
 			return nil, fmt.Errorf("inconsistent volumes, expected volume number %d, got %d", vol.VolumeNumber, r.Volumes[i].Number)
 		}
@@ -236,7 +427,7 @@ func (r *ResourceConfig) ToPromoter(deployment []client.ResourceWithVolumes) (*r
 	return &reactor.PromoterConfig{
 		ID: r.ID(),
 		Resources: map[string]reactor.PromoterResourceConfig{
-			r.NQN.Subsystem(): {
+			r.linstorResourceName(): {
 				Runner:              "systemd",
 				Start:               agents,
 				StopServicesOnExit:  true,
@@ -247,11 +438,17 @@ func (r *ResourceConfig) ToPromoter(deployment []client.ResourceWithVolumes) (*r
 	}, nil
 }
 
+// Matches reports whether the deployed target o is configured as requested
+// by r. A DRBD port or minor that is not set in r matches any.
 func (r *ResourceConfig) Matches(o *ResourceConfig) bool {
 	if r.NQN != o.NQN {
 		return false
 	}
 
+	if r.linstorResourceName() != o.linstorResourceName() {
+		return false
+	}
+
 	if r.ServiceIP.String() != o.ServiceIP.String() {
 		return false
 	}
@@ -260,6 +457,32 @@ func (r *ResourceConfig) Matches(o *ResourceConfig) bool {
 		return false
 	}
 
+	if common.CheckCreateOnly(o.createOnlySettings(), r.createOnlySettings()) != nil {
+		return false
+	}
+
+	if r.Description != o.Description {
+		return false
+	}
+
+	if r.Model != o.Model || r.Serial() != o.Serial() {
+		return false
+	}
+
+	if len(r.AllowedHosts) != len(o.AllowedHosts) {
+		return false
+	}
+
+	for i := range r.AllowedHosts {
+		if r.AllowedHosts[i] != o.AllowedHosts[i] {
+			return false
+		}
+	}
+
+	if !common.EqualIOLimits(r.ReadLimit, o.ReadLimit) || !common.EqualIOLimits(r.WriteLimit, o.WriteLimit) {
+		return false
+	}
+
 	if len(r.Volumes) != len(o.Volumes) {
 		return false
 	}
@@ -277,6 +500,11 @@ func (r *ResourceConfig) Matches(o *ResourceConfig) bool {
 	return true
 }
 
+// ioLimits returns the throughput limits of the LINSTOR resource.
+func (r *ResourceConfig) ioLimits() *linstorcontrol.IOLimits {
+	return &linstorcontrol.IOLimits{Read: r.ReadLimit, Write: r.WriteLimit}
+}
+
 func (r *ResourceConfig) FillDefaults() {
 	if r.ResourceGroup == "" {
 		r.ResourceGroup = "DfltRscGrp"
@@ -284,8 +512,16 @@ func (r *ResourceConfig) FillDefaults() {
 }
 
 func (r *ResourceConfig) Valid() error {
-	if len(r.NQN.Subsystem()) < 2 {
-		return common.ValidationError("nvme subsystem string to short (min. 2)")
+	err := r.NQN.Validate()
+	if err != nil {
+		return common.ValidationError(err.Error())
+	}
+
+	if r.ResourceName != "" {
+		err := common.ValidLinstorName(r.ResourceName)
+		if err != nil {
+			return err
+		}
 	}
 
 	if r.ServiceIP.IP() == nil {
@@ -296,6 +532,69 @@ func (r *ResourceConfig) Valid() error {
 		return common.ValidationError("missing service ip prefix length")
 	}
 
+	err = common.ValidPlacementCount(r.PlacementCount)
+	if err != nil {
+		return err
+	}
+
+	err = common.ValidLayerList(r.LayerList)
+	if err != nil {
+		return err
+	}
+
+	err = common.ValidDrbdNumbers(r.DrbdPort, r.DrbdMinor, len(r.Volumes))
+	if err != nil {
+		return err
+	}
+
+	err = common.ValidExported(r.Volumes)
+	if err != nil {
+		return err
+	}
+
+	err = common.ValidLabels(r.Labels)
+	if err != nil {
+		return err
+	}
+
+	err = common.ValidDescription(r.Description)
+	if err != nil {
+		return err
+	}
+
+	err = common.ValidDrbdOptions(r.DrbdOptions)
+	if err != nil {
+		return err
+	}
+
+	err = common.ValidIOLimits(r.ReadLimit, r.WriteLimit)
+	if err != nil {
+		return err
+	}
+
+	err = validIdentifyString("model", r.Model, maxModelLength)
+	if err != nil {
+		return err
+	}
+
+	err = validIdentifyString("serial number", r.SerialNumber, maxSerialLength)
+	if err != nil {
+		return err
+	}
+
+	hosts := make(map[string]struct{}, len(r.AllowedHosts))
+	for _, host := range r.AllowedHosts {
+		err := ValidHostNqn(host)
+		if err != nil {
+			return err
+		}
+
+		if _, ok := hosts[host]; ok {
+			return common.ValidationError(fmt.Sprintf("duplicate allowed host %s", host))
+		}
+		hosts[host] = struct{}{}
+	}
+
 	sort.Slice(r.Volumes, func(i, j int) bool {
 		return r.Volumes[i].Number < r.Volumes[j].Number
 	})
@@ -311,7 +610,7 @@ func (r *ResourceConfig) Valid() error {
 		}
 
 		if i > 0 && r.Volumes[i-1].Number == r.Volumes[i].Number {
-			return common.ValidationError("volume numbers must be unique")
+			return common.ValidationError(fmt.Sprintf("volume numbers must be unique, %d is used more than once", r.Volumes[i].Number))
 		}
 	}
 
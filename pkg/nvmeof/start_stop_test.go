@@ -0,0 +1,156 @@
+package nvmeof
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/LINBIT/golinstor/client"
+	"github.com/icza/gog"
+	"github.com/pelletier/go-toml"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/LINBIT/linstor-gateway/pkg/common"
+	"github.com/LINBIT/linstor-gateway/pkg/linstorcontrol"
+	"github.com/LINBIT/linstor-gateway/pkg/reactor"
+)
+
+// serviceLinstor simulates a target whose service is started by attaching
+// its reactor config, and stopped by detaching it.
+type serviceLinstor struct {
+	file     client.ExternalFile
+	started  bool
+	attached int
+	detached int
+}
+
+type serviceController struct {
+	*mockControllerProps
+	m *serviceLinstor
+}
+
+func (c serviceController) GetExternalFiles(ctx context.Context, opts ...*client.ListOpts) ([]client.ExternalFile, error) {
+	return []client.ExternalFile{c.m.file}, nil
+}
+
+type serviceResourceDefinitions struct {
+	client.ResourceDefinitionProvider
+	m *serviceLinstor
+}
+
+func (d serviceResourceDefinitions) Get(ctx context.Context, resDefName string, opts ...*client.ListOpts) (client.ResourceDefinition, error) {
+	props := map[string]string{}
+	if d.m.started {
+		props["files"+d.m.file.Path] = "True"
+	}
+	return client.ResourceDefinition{Name: resDefName, ResourceGroupName: "rg", Props: props}, nil
+}
+
+func (d serviceResourceDefinitions) GetVolumeDefinitions(ctx context.Context, resDefName string, opts ...*client.ListOpts) ([]client.VolumeDefinition, error) {
+	return []client.VolumeDefinition{{VolumeNumber: gog.Ptr(int32(0))}, {VolumeNumber: gog.Ptr(int32(1))}}, nil
+}
+
+func (d serviceResourceDefinitions) Modify(ctx context.Context, resDefName string, props client.GenericPropsModify) error {
+	return nil
+}
+
+func (d serviceResourceDefinitions) AttachExternalFile(ctx context.Context, resDefName string, filePath string) error {
+	d.m.attached++
+	d.m.started = true
+	return nil
+}
+
+func (d serviceResourceDefinitions) DetachExternalFile(ctx context.Context, resDefName string, filePath string) error {
+	d.m.detached++
+	d.m.started = false
+	return nil
+}
+
+type serviceResources struct {
+	client.ResourceProvider
+	m *serviceLinstor
+}
+
+func (r serviceResources) GetResourceView(ctx context.Context, opts ...*client.ListOpts) ([]client.ResourceWithVolumes, error) {
+	return []client.ResourceWithVolumes{{
+		Resource: client.Resource{
+			Name:     "example",
+			NodeName: "node1",
+			State:    &client.ResourceState{InUse: gog.Ptr(r.m.started)},
+		},
+		Volumes: []client.Volume{{VolumeNumber: 0}, {VolumeNumber: 1}},
+	}}, nil
+}
+
+func TestStartStop(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name           string
+		start          bool
+		started        bool
+		expectAttached int
+		expectDetached int
+		expectService  common.ServiceState
+	}{{
+		name:           "start stopped target",
+		start:          true,
+		expectAttached: 1,
+		expectService:  common.ServiceStateStarted,
+	}, {
+		name:          "start already started target",
+		start:         true,
+		started:       true,
+		expectService: common.ServiceStateStarted,
+	}, {
+		name:           "stop started target",
+		started:        true,
+		expectDetached: 1,
+		expectService:  common.ServiceStateStopped,
+	}, {
+		name:          "stop already stopped target",
+		expectService: common.ServiceStateStopped,
+	}}
+
+	for i := range cases {
+		tcase := &cases[i]
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			nqn := Nqn{"nqn.2021-08.com.example.test", "example"}
+			rsc := &ResourceConfig{
+				NQN:       nqn,
+				ServiceIP: common.ServiceIPFromParts(net.IP{192, 168, 127, 1}, 24),
+				Volumes:   []common.VolumeConfig{common.ClusterPrivateVolume(), {Number: 1, SizeKiB: 1024}},
+			}
+			promoter, err := rsc.ToPromoter([]client.ResourceWithVolumes{{
+				Resource: client.Resource{Name: "example"},
+				Volumes:  []client.Volume{{VolumeNumber: 0}, {VolumeNumber: 1}},
+			}})
+			assert.NoError(t, err)
+			content, err := toml.Marshal(reactor.Config{Promoter: []reactor.PromoterConfig{*promoter}})
+			assert.NoError(t, err)
+
+			path := reactor.ConfigPath(promoter.ID)
+			m := &serviceLinstor{file: client.ExternalFile{Path: path, Content: content}, started: tcase.started}
+			props := &mockControllerProps{}
+			n := &NVMeoF{cli: &linstorcontrol.Linstor{Client: &client.Client{
+				Controller:          serviceController{mockControllerProps: props, m: m},
+				ResourceGroups:      mockResourceGroups{},
+				ResourceDefinitions: serviceResourceDefinitions{m: m},
+				Resources:           serviceResources{m: m},
+			}}}
+
+			var cfg *ResourceConfig
+			if tcase.start {
+				cfg, err = n.Start(context.Background(), nqn)
+			} else {
+				cfg, err = n.Stop(context.Background(), nqn)
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tcase.expectService, cfg.Status.Service)
+			assert.Equal(t, tcase.expectAttached, m.attached)
+			assert.Equal(t, tcase.expectDetached, m.detached)
+			assert.Empty(t, props.props, "lock not released")
+		})
+	}
+}
@@ -0,0 +1,70 @@
+// Package observe records the major steps of operations, such as LINSTOR
+// calls, drbd-reactor config changes and waiting for resources, so that
+// their timing can be inspected.
+//
+// The Observer is carried in the context of an operation. Without one, all
+// steps are ignored.
+package observe
+
+import (
+	"context"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Observer is notified about the steps of an operation. It can be used to
+// record timings, e.g. as tracing spans.
+type Observer interface {
+	// StartStep is called when a step starts. The returned context is
+	// passed to the nested steps, and end is called with the result of
+	// the step when it is done.
+	StartStep(ctx context.Context, name string) (context.Context, func(err error))
+}
+
+type observerKey struct{}
+
+// WithObserver returns a copy of ctx that carries o. A nil Observer ignores
+// all steps.
+func WithObserver(ctx context.Context, o Observer) context.Context {
+	return context.WithValue(ctx, observerKey{}, o)
+}
+
+// FromContext returns the Observer carried in ctx, or a Nop if there is none.
+func FromContext(ctx context.Context) Observer {
+	o, _ := ctx.Value(observerKey{}).(Observer)
+	if o == nil {
+		return Nop{}
+	}
+
+	return o
+}
+
+// Step starts a step with the Observer carried in ctx. The step ends when
+// the returned function is called.
+func Step(ctx context.Context, name string) (context.Context, func(err error)) {
+	return FromContext(ctx).StartStep(ctx, name)
+}
+
+// Nop is an Observer that ignores all steps.
+type Nop struct{}
+
+// StartStep implements Observer.
+func (Nop) StartStep(ctx context.Context, name string) (context.Context, func(err error)) {
+	return ctx, func(error) {}
+}
+
+// Log is an Observer that logs the duration of every step at debug level.
+type Log struct{}
+
+// StartStep implements Observer.
+func (Log) StartStep(ctx context.Context, name string) (context.Context, func(err error)) {
+	start := time.Now()
+	return ctx, func(err error) {
+		logger := log.WithFields(log.Fields{"step": name, "duration": time.Since(start)})
+		if err != nil {
+			logger = logger.WithError(err)
+		}
+		logger.Debug("step done")
+	}
+}
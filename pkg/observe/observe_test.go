@@ -0,0 +1,76 @@
+package observe
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type parentKey struct{}
+
+// recorder records the steps it observes as "parent/name", and whether they
+// failed.
+type recorder struct {
+	steps []string
+}
+
+func (r *recorder) StartStep(ctx context.Context, name string) (context.Context, func(err error)) {
+	if parent, ok := ctx.Value(parentKey{}).(string); ok {
+		name = parent + "/" + name
+	}
+
+	return context.WithValue(ctx, parentKey{}, name), func(err error) {
+		if err != nil {
+			name += " failed"
+		}
+		r.steps = append(r.steps, name)
+	}
+}
+
+func TestStep(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name         string
+		withObserver bool
+		observer     Observer
+		expected     []string
+	}{{
+		name: "no observer",
+	}, {
+		name:         "nil observer",
+		withObserver: true,
+	}, {
+		name:         "recorder",
+		withObserver: true,
+		observer:     &recorder{},
+		expected:     []string{"outer/inner", "outer/failing failed", "outer"},
+	}}
+
+	for i := range cases {
+		tcase := &cases[i]
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			if tcase.withObserver {
+				ctx = WithObserver(ctx, tcase.observer)
+			}
+
+			outerCtx, endOuter := Step(ctx, "outer")
+			_, endInner := Step(outerCtx, "inner")
+			endInner(nil)
+			_, endFailing := Step(outerCtx, "failing")
+			endFailing(errors.New("failed"))
+			endOuter(nil)
+
+			if r, ok := tcase.observer.(*recorder); ok {
+				assert.Equal(t, tcase.expected, r.steps)
+			} else {
+				assert.Equal(t, Nop{}, FromContext(ctx))
+			}
+		})
+	}
+}
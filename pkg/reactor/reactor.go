@@ -5,17 +5,57 @@ import (
 	"encoding"
 	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 
+	apiconsts "github.com/LINBIT/golinstor"
 	"github.com/LINBIT/golinstor/client"
 	"github.com/pelletier/go-toml"
+
+	"github.com/LINBIT/linstor-gateway/pkg/observe"
 )
 
 const (
-	promoterDir       = "/etc/drbd-reactor.d"
-	gatewayConfigPath = promoterDir + "/linstor-gateway-%s.toml"
+	// DefaultConfigDir is the directory drbd-reactor reads its configuration
+	// snippets from by default.
+	DefaultConfigDir  = "/etc/drbd-reactor.d"
+	gatewayConfigFile = "linstor-gateway-%s.toml"
 )
 
+// ConfigDir is the directory in which the promoter configs are placed on the
+// nodes. It can be changed for deployments where drbd-reactor reads its
+// configuration from a different location, e.g. a bind-mounted directory.
+var ConfigDir = DefaultConfigDir
+
+// CheckConfigDir checks that dir can be used as the promoter config
+// directory: it must be an absolute path to an existing, writable directory.
+func CheckConfigDir(dir string) error {
+	if !filepath.IsAbs(dir) {
+		return fmt.Errorf("reactor config directory \"%s\" is not an absolute path", dir)
+	}
+
+	info, err := os.Stat(dir)
+	if err != nil {
+		return fmt.Errorf("reactor config directory \"%s\" is not accessible: %w", dir, err)
+	}
+
+	if !info.IsDir() {
+		return fmt.Errorf("reactor config directory \"%s\" is not a directory", dir)
+	}
+
+	f, err := os.CreateTemp(dir, ".linstor-gateway-check-*")
+	if err != nil {
+		return fmt.Errorf("reactor config directory \"%s\" is not writable: %w", dir, err)
+	}
+	f.Close()
+	os.Remove(f.Name())
+
+	return nil
+}
+
 // Config is the root configuration for drbd-reactor.
 //
 // Currently, only supports Promoter plugins.
@@ -29,8 +69,53 @@ type PromoterConfig struct {
 	Resources map[string]PromoterResourceConfig `toml:"resources,omitempty"`
 }
 
+// ResourceName returns the name of the LINSTOR resource managed by this
+// promoter config. It returns an empty string if the config does not contain
+// exactly one resource.
+func (p *PromoterConfig) ResourceName() string {
+	if len(p.Resources) != 1 {
+		return ""
+	}
+
+	for k := range p.Resources {
+		return k
+	}
+
+	return ""
+}
+
+// CountAgents returns the number of resource agents of one of the given
+// types that are started by this promoter config.
+func (p *PromoterConfig) CountAgents(types ...string) int {
+	count := 0
+	for _, r := range p.Resources {
+		for _, entry := range r.Start {
+			agent, ok := entry.(*ResourceAgent)
+			if !ok {
+				continue
+			}
+
+			for _, t := range types {
+				if agent.Type == t {
+					count++
+					break
+				}
+			}
+		}
+	}
+
+	return count
+}
+
 // DeployedResources fetches the current state of the resources referenced in the promoter config.
 func (p *PromoterConfig) DeployedResources(ctx context.Context, cli *client.Client) (*client.ResourceDefinition, *client.ResourceGroup, []client.VolumeDefinition, []client.ResourceWithVolumes, error) {
+	ctx, end := observe.Step(ctx, "reactor.DeployedResources")
+	rd, rg, vds, resources, err := p.deployedResources(ctx, cli)
+	end(err)
+	return rd, rg, vds, resources, err
+}
+
+func (p *PromoterConfig) deployedResources(ctx context.Context, cli *client.Client) (*client.ResourceDefinition, *client.ResourceGroup, []client.VolumeDefinition, []client.ResourceWithVolumes, error) {
 	var rscNames []string
 	for k := range p.Resources {
 		rscNames = append(rscNames, k)
@@ -75,6 +160,10 @@ type PromoterResourceConfig struct {
 	OnDrbdDemoteFailure string       `toml:"on-drbd-demote-failure,omitempty"`
 	StopServicesOnExit  bool         `toml:"stop-services-on-exit,omitempty"`
 	TargetAs            string       `toml:"target-as,omitempty"`
+	// PreferredNodes are the nodes the resource is promoted on, in order
+	// of preference. If the resource is active on a node that is less
+	// preferred than another available node, it is moved there.
+	PreferredNodes []string `toml:"preferred-nodes,omitempty"`
 }
 
 func (c *PromoterResourceConfig) UnmarshalTOML(data interface{}) error {
@@ -130,21 +219,46 @@ func (c *PromoterResourceConfig) UnmarshalTOML(data interface{}) error {
 			return fmt.Errorf("could not convert value %v to string (is type %T)", val, val)
 		}
 	}
+	if val, ok := d["preferred-nodes"]; ok {
+		nodes, nodesOk := val.([]interface{})
+		if !nodesOk {
+			return fmt.Errorf("could not convert value %v to slice (is type %T)", val, val)
+		}
+		for _, entry := range nodes {
+			node, ok := entry.(string)
+			if !ok {
+				return fmt.Errorf("could not convert value %v to string (is type %T)", entry, entry)
+			}
+			c.PreferredNodes = append(c.PreferredNodes, node)
+		}
+	}
 	return nil
 }
 
+// WithPreferredNodes returns a copy of the config in which all resources
+// are preferably promoted on the given nodes.
+func (p *PromoterConfig) WithPreferredNodes(nodes []string) *PromoterConfig {
+	result := &PromoterConfig{ID: p.ID, Resources: make(map[string]PromoterResourceConfig, len(p.Resources))}
+	for name, rsc := range p.Resources {
+		rsc.PreferredNodes = nodes
+		result.Resources[name] = rsc
+	}
+
+	return result
+}
+
 // EnsureConfig ensures the given config is registered in LINSTOR and up-to-date.
-func EnsureConfig(ctx context.Context, cli *client.Client, cfg *PromoterConfig) error {
-	buffer := strings.Builder{}
-	encoder := toml.NewEncoder(&buffer).ArraysWithOneElementPerLine(true)
+func EnsureConfig(ctx context.Context, cli *client.Client, cfg *PromoterConfig) (err error) {
+	ctx, end := observe.Step(ctx, "reactor.EnsureConfig")
+	defer func() { end(err) }()
 
-	err := encoder.Encode(&Config{Promoter: []PromoterConfig{*cfg}})
+	content, err := encodeConfig(cfg)
 	if err != nil {
-		return fmt.Errorf("error encoding promoter config: %w", err)
+		return err
 	}
 
 	path := ConfigPath(cfg.ID)
-	err = cli.Controller.ModifyExternalFile(ctx, path, client.ExternalFile{Path: path, Content: []byte(buffer.String())})
+	err = cli.Controller.ModifyExternalFile(ctx, path, client.ExternalFile{Path: path, Content: []byte(content)})
 	if err != nil {
 		return fmt.Errorf("error setting promoter config in linstor: %w", err)
 	}
@@ -152,8 +266,64 @@ func EnsureConfig(ctx context.Context, cli *client.Client, cfg *PromoterConfig)
 	return nil
 }
 
+// encodeConfig returns the content of the config file registered for cfg.
+func encodeConfig(cfg *PromoterConfig) (string, error) {
+	buffer := strings.Builder{}
+	encoder := toml.NewEncoder(&buffer).ArraysWithOneElementPerLine(true)
+
+	err := encoder.Encode(&Config{Promoter: []PromoterConfig{*cfg}})
+	if err != nil {
+		return "", fmt.Errorf("error encoding promoter config: %w", err)
+	}
+
+	return buffer.String(), nil
+}
+
+// ServiceChangedProp stores when the services of a resource were last
+// started, stopped or failed over, in RFC 3339 format.
+const ServiceChangedProp = apiconsts.NamespcAuxiliary + "/linstor-gateway/service-changed"
+
+// MarkServiceChange records in ServiceChangedProp of all resources referenced
+// by cfg that their services are being started, stopped or failed over now.
+func MarkServiceChange(ctx context.Context, cli *client.Client, cfg *PromoterConfig) error {
+	now := time.Now().UTC().Format(time.RFC3339)
+	for rd := range cfg.Resources {
+		err := cli.ResourceDefinitions.Modify(ctx, rd, client.GenericPropsModify{
+			OverrideProps: map[string]string{ServiceChangedProp: now},
+		})
+		if err != nil {
+			return fmt.Errorf("error recording service change of resource: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// ServiceChanged returns when the services of the resource were last
+// started, stopped or failed over, or the zero time if that is unknown.
+func ServiceChanged(definition *client.ResourceDefinition) time.Time {
+	if definition == nil {
+		return time.Time{}
+	}
+
+	changed, err := time.Parse(time.RFC3339, definition.Props[ServiceChangedProp])
+	if err != nil {
+		return time.Time{}
+	}
+
+	return changed
+}
+
 // AttachConfig ensures the promoter config is attached to all referenced resources.
-func AttachConfig(ctx context.Context, cli *client.Client, cfg *PromoterConfig) error {
+func AttachConfig(ctx context.Context, cli *client.Client, cfg *PromoterConfig) (err error) {
+	ctx, end := observe.Step(ctx, "reactor.AttachConfig")
+	defer func() { end(err) }()
+
+	err = MarkServiceChange(ctx, cli, cfg)
+	if err != nil {
+		return err
+	}
+
 	path := ConfigPath(cfg.ID)
 
 	for rd := range cfg.Resources {
@@ -167,7 +337,15 @@ func AttachConfig(ctx context.Context, cli *client.Client, cfg *PromoterConfig)
 }
 
 // DetachConfig detaches the promoter config from all resources.
-func DetachConfig(ctx context.Context, cli *client.Client, cfg *PromoterConfig) error {
+func DetachConfig(ctx context.Context, cli *client.Client, cfg *PromoterConfig) (err error) {
+	ctx, end := observe.Step(ctx, "reactor.DetachConfig")
+	defer func() { end(err) }()
+
+	err = MarkServiceChange(ctx, cli, cfg)
+	if err != nil {
+		return err
+	}
+
 	path := ConfigPath(cfg.ID)
 
 	for rd := range cfg.Resources {
@@ -180,20 +358,57 @@ func DetachConfig(ctx context.Context, cli *client.Client, cfg *PromoterConfig)
 	return nil
 }
 
+// ListOptions selects which promoter configurations ListConfigsPage returns.
+type ListOptions struct {
+	// IDPrefix selects only the configurations whose ID starts with it,
+	// e.g. "iscsi-". If empty, all configurations are selected.
+	IDPrefix string
+	// Offset is the number of selected configurations to skip.
+	Offset int
+	// Limit is the maximum number of configurations to return. If 0, all
+	// selected configurations are returned.
+	Limit int
+}
+
 // filterConfigs takes a list of external files in the LINSTOR cluster and
-// extracts all drbd-reactor promoter configuration files that were created by
-// LINSTOR Gateway.
-func filterConfigs(files []client.ExternalFile) ([]PromoterConfig, []string, error) {
-	result := make([]PromoterConfig, 0, len(files))
-	paths := make([]string, 0, len(files))
+// extracts all drbd-reactor promoter configuration files in dir that were
+// created by LINSTOR Gateway and are selected by opts. The files are ordered
+// by their path, so that pages are stable. Only the files on the requested
+// page are decoded.
+func filterConfigs(files []client.ExternalFile, dir string, opts ListOptions) ([]PromoterConfig, []string, error) {
+	selected := make([]*client.ExternalFile, 0, len(files))
+	for i := range files {
+		if filepath.Dir(files[i].Path) != filepath.Clean(dir) {
+			continue
+		}
 
-	for _, file := range files {
 		var name string
-		n, _ := fmt.Sscanf(file.Path, gatewayConfigPath, &name)
-		if n == 0 {
+		n, _ := fmt.Sscanf(filepath.Base(files[i].Path), gatewayConfigFile, &name)
+		if n == 0 || !strings.HasPrefix(name, opts.IDPrefix) {
 			continue
 		}
 
+		selected = append(selected, &files[i])
+	}
+
+	sort.SliceStable(selected, func(i, j int) bool {
+		return selected[i].Path < selected[j].Path
+	})
+
+	if opts.Offset >= len(selected) {
+		selected = nil
+	} else if opts.Offset > 0 {
+		selected = selected[opts.Offset:]
+	}
+
+	if opts.Limit > 0 && opts.Limit < len(selected) {
+		selected = selected[:opts.Limit]
+	}
+
+	result := make([]PromoterConfig, 0, len(selected))
+	paths := make([]string, 0, len(selected))
+
+	for _, file := range selected {
 		cfg := Config{}
 		err := toml.Unmarshal(file.Content, &cfg)
 		if err != nil {
@@ -209,11 +424,20 @@ func filterConfigs(files []client.ExternalFile) ([]PromoterConfig, []string, err
 
 // ListConfigs fetches all promoter configurations registered with LINSTOR.
 func ListConfigs(ctx context.Context, cli *client.Client) ([]PromoterConfig, []string, error) {
+	return ListConfigsPage(ctx, cli, ListOptions{})
+}
+
+// ListConfigsPage fetches the promoter configurations registered with LINSTOR
+// that are selected by opts. LINSTOR can not filter external files, so all of
+// them are fetched, but only the selected ones are decoded.
+func ListConfigsPage(ctx context.Context, cli *client.Client, opts ListOptions) ([]PromoterConfig, []string, error) {
+	ctx, end := observe.Step(ctx, "reactor.ListConfigs")
 	files, err := cli.Controller.GetExternalFiles(ctx, &client.ListOpts{Content: true})
+	end(err)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to fetch file list: %w", err)
 	}
-	return filterConfigs(files)
+	return filterConfigs(files, ConfigDir, opts)
 }
 
 // FindConfig fetches the promoter config with the given id.
@@ -238,10 +462,13 @@ func FindConfig(ctx context.Context, cli *client.Client, id string) (*PromoterCo
 // DeleteConfig removes the promoter of the given id from LINSTOR.
 //
 // In case the config did not exist, no error is returned.
-func DeleteConfig(ctx context.Context, cli *client.Client, id string) error {
+func DeleteConfig(ctx context.Context, cli *client.Client, id string) (err error) {
+	ctx, end := observe.Step(ctx, "reactor.DeleteConfig")
+	defer func() { end(err) }()
+
 	path := ConfigPath(id)
 
-	err := cli.Controller.DeleteExternalFile(ctx, path)
+	err = cli.Controller.DeleteExternalFile(ctx, path)
 	if err != nil && err != client.NotFoundError {
 		return fmt.Errorf("error removing config file: %w", err)
 	}
@@ -251,5 +478,5 @@ func DeleteConfig(ctx context.Context, cli *client.Client, id string) error {
 
 // ConfigPath is the file system path of the promoter config with the given id once it is deployed.
 func ConfigPath(id string) string {
-	return fmt.Sprintf(gatewayConfigPath, id)
+	return filepath.Join(ConfigDir, fmt.Sprintf(gatewayConfigFile, id))
 }
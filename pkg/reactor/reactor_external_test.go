@@ -78,6 +78,43 @@ id = "unexpected-types"
     target-as = 3.14
 `,
 		wantErr: true,
+	}, {
+		name: "unexpected preferred-nodes type",
+		cfg: `[[promoter]]
+id = "unexpected-types"
+
+[promoter.resources]
+  [promoter.resources.rsc1]
+    preferred-nodes = "node1"
+`,
+		wantErr: true,
+	}, {
+		name: "unexpected preferred-nodes entry type",
+		cfg: `[[promoter]]
+id = "unexpected-types"
+
+[promoter.resources]
+  [promoter.resources.rsc1]
+    preferred-nodes = [ 1 ]
+`,
+		wantErr: true,
+	}, {
+		name: "with preferred nodes",
+		cfg: `[[promoter]]
+id = "preferred-nodes"
+
+[promoter.resources]
+  [promoter.resources.rsc1]
+    preferred-nodes = [ "node2", "node1" ]
+`,
+		expected: reactor.Config{
+			Promoter: []reactor.PromoterConfig{{
+				ID: "preferred-nodes",
+				Resources: map[string]reactor.PromoterResourceConfig{
+					"rsc1": {PreferredNodes: []string{"node2", "node1"}},
+				},
+			}},
+		},
 	}, {
 		name: "unexpected start entry type",
 		cfg: `[[promoter]]
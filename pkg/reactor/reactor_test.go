@@ -3,6 +3,7 @@ package reactor
 import (
 	"github.com/LINBIT/golinstor/client"
 	"github.com/stretchr/testify/assert"
+	"os"
 	"path/filepath"
 	"testing"
 )
@@ -13,49 +14,73 @@ func TestFilterConfigs(t *testing.T) {
 	testcases := []struct {
 		name            string
 		files           []client.ExternalFile
+		dir             string
 		expectedConfigs []PromoterConfig
 		expectedPaths   []string
 		wantErr         bool
 	}{{
 		name:            "empty files",
 		files:           []client.ExternalFile{},
+		dir:             DefaultConfigDir,
 		expectedConfigs: []PromoterConfig{},
 		expectedPaths:   []string{},
 	}, {
 		name: "one file",
+		dir:  DefaultConfigDir,
 		files: []client.ExternalFile{
 			{
-				Path: filepath.Join(promoterDir, "linstor-gateway-iscsi-target1.toml"),
+				Path: filepath.Join(DefaultConfigDir, "linstor-gateway-iscsi-target1.toml"),
 				Content: []byte(`[[promoter]]
 id = "iscsi-target1"
 `),
 			},
 		},
 		expectedConfigs: []PromoterConfig{{ID: "iscsi-target1", Resources: nil}},
-		expectedPaths:   []string{filepath.Join(promoterDir, "linstor-gateway-iscsi-target1.toml")},
+		expectedPaths:   []string{filepath.Join(DefaultConfigDir, "linstor-gateway-iscsi-target1.toml")},
 	}, {
 		name: "one file with invalid contents",
+		dir:  DefaultConfigDir,
 		files: []client.ExternalFile{
 			{
-				Path:    filepath.Join(promoterDir, "linstor-gateway-iscsi-target1.toml"),
+				Path:    filepath.Join(DefaultConfigDir, "linstor-gateway-iscsi-target1.toml"),
 				Content: []byte(`don't know what this is, but it's not toml!`),
 			},
 		},
 		wantErr: true,
 	}, {
 		name: "one relevant file",
+		dir:  DefaultConfigDir,
 		files: []client.ExternalFile{
 			{
-				Path: filepath.Join(promoterDir, "linstor-gateway-iscsi-target1.toml"),
+				Path: filepath.Join(DefaultConfigDir, "linstor-gateway-iscsi-target1.toml"),
 				Content: []byte(`[[promoter]]
 id = "iscsi-target1"
 `),
 			},
 			{Path: "/some/other/file"},
-			{Path: filepath.Join(promoterDir, "oops-not-the-right-pattern.toml")},
+			{Path: filepath.Join(DefaultConfigDir, "oops-not-the-right-pattern.toml")},
 		},
 		expectedConfigs: []PromoterConfig{{ID: "iscsi-target1", Resources: nil}},
-		expectedPaths:   []string{filepath.Join(promoterDir, "linstor-gateway-iscsi-target1.toml")},
+		expectedPaths:   []string{filepath.Join(DefaultConfigDir, "linstor-gateway-iscsi-target1.toml")},
+	}, {
+		name: "custom directory",
+		dir:  "/srv/reactor/",
+		files: []client.ExternalFile{
+			{
+				Path: "/srv/reactor/linstor-gateway-iscsi-target1.toml",
+				Content: []byte(`[[promoter]]
+id = "iscsi-target1"
+`),
+			},
+			{
+				Path: filepath.Join(DefaultConfigDir, "linstor-gateway-iscsi-target2.toml"),
+				Content: []byte(`[[promoter]]
+id = "iscsi-target2"
+`),
+			},
+		},
+		expectedConfigs: []PromoterConfig{{ID: "iscsi-target1", Resources: nil}},
+		expectedPaths:   []string{"/srv/reactor/linstor-gateway-iscsi-target1.toml"},
 	}}
 
 	for i := range testcases {
@@ -63,7 +88,7 @@ id = "iscsi-target1"
 		t.Run(tcase.name, func(t *testing.T) {
 			t.Parallel()
 
-			configs, paths, err := filterConfigs(tcase.files)
+			configs, paths, err := filterConfigs(tcase.files, tcase.dir, ListOptions{})
 			if tcase.wantErr {
 				assert.Error(t, err)
 			} else {
@@ -74,3 +99,101 @@ id = "iscsi-target1"
 		})
 	}
 }
+
+func TestFilterConfigs_Page(t *testing.T) {
+	t.Parallel()
+
+	var files []client.ExternalFile
+	for _, id := range []string{"nfs-export1", "iscsi-target3", "iscsi-target1", "iscsi-target2"} {
+		files = append(files, client.ExternalFile{
+			Path:    filepath.Join(DefaultConfigDir, "linstor-gateway-"+id+".toml"),
+			Content: []byte("[[promoter]]\nid = \"" + id + "\"\n"),
+		})
+	}
+
+	testcases := []struct {
+		name        string
+		opts        ListOptions
+		expectedIDs []string
+	}{{
+		name:        "all",
+		expectedIDs: []string{"iscsi-target1", "iscsi-target2", "iscsi-target3", "nfs-export1"},
+	}, {
+		name:        "prefix",
+		opts:        ListOptions{IDPrefix: "iscsi-"},
+		expectedIDs: []string{"iscsi-target1", "iscsi-target2", "iscsi-target3"},
+	}, {
+		name:        "limit",
+		opts:        ListOptions{IDPrefix: "iscsi-", Limit: 2},
+		expectedIDs: []string{"iscsi-target1", "iscsi-target2"},
+	}, {
+		name:        "offset and limit",
+		opts:        ListOptions{IDPrefix: "iscsi-", Offset: 2, Limit: 2},
+		expectedIDs: []string{"iscsi-target3"},
+	}, {
+		name:        "offset past the end",
+		opts:        ListOptions{IDPrefix: "iscsi-", Offset: 3},
+		expectedIDs: []string{},
+	}}
+
+	for i := range testcases {
+		tcase := &testcases[i]
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			configs, paths, err := filterConfigs(files, DefaultConfigDir, tcase.opts)
+			assert.NoError(t, err)
+			assert.Len(t, paths, len(tcase.expectedIDs))
+
+			ids := []string{}
+			for _, cfg := range configs {
+				ids = append(ids, cfg.ID)
+			}
+			assert.Equal(t, tcase.expectedIDs, ids)
+		})
+	}
+}
+
+func TestCheckConfigDir(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file")
+	err := os.WriteFile(file, nil, 0o644)
+	assert.NoError(t, err)
+
+	testcases := []struct {
+		name    string
+		dir     string
+		wantErr bool
+	}{{
+		name: "writable directory",
+		dir:  dir,
+	}, {
+		name:    "relative path",
+		dir:     "drbd-reactor.d",
+		wantErr: true,
+	}, {
+		name:    "missing directory",
+		dir:     filepath.Join(dir, "missing"),
+		wantErr: true,
+	}, {
+		name:    "not a directory",
+		dir:     file,
+		wantErr: true,
+	}}
+
+	for i := range testcases {
+		tcase := &testcases[i]
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := CheckConfigDir(tcase.dir)
+			if tcase.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
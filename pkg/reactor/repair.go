@@ -0,0 +1,114 @@
+package reactor
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/LINBIT/golinstor/client"
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// RepairResult describes the outcome of repairing a promoter config.
+type RepairResult struct {
+	// Diff is a unified diff from the registered to the regenerated config.
+	// It is empty if the config did not drift.
+	Diff string `json:"diff"`
+	// Applied is true if the regenerated config was registered in LINSTOR.
+	Applied bool `json:"applied"`
+}
+
+// RegenerateFunc builds the promoter config from the current state of its
+// LINSTOR resource.
+type RegenerateFunc func(definition *client.ResourceDefinition, volumeDefinitions []client.VolumeDefinition, resources []client.ResourceWithVolumes) (*PromoterConfig, error)
+
+// Repair regenerates the promoter config cfg and registers the result in
+// LINSTOR, resetting any drift from what LINSTOR Gateway would generate. The
+// config is registered again even if it did not change, so that LINSTOR
+// redeploys it to all nodes. If dryRun is set, only the diff is computed.
+func Repair(ctx context.Context, cli *client.Client, cfg *PromoterConfig, dryRun bool, regenerate RegenerateFunc) (*RepairResult, error) {
+	definition, _, volumeDefinitions, resources, err := cfg.DeployedResources(ctx, cli)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch deployed resources: %w", err)
+	}
+
+	regenerated, err := regenerate(definition, volumeDefinitions, resources)
+	if err != nil {
+		return nil, fmt.Errorf("failed to regenerate config: %w", err)
+	}
+
+	diff, err := DiffConfigs(cfg, regenerated)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &RepairResult{Diff: diff}
+	if dryRun {
+		return result, nil
+	}
+
+	err = EnsureConfig(ctx, cli, regenerated)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update config: %w", err)
+	}
+	result.Applied = true
+
+	return result, nil
+}
+
+// ConfigText is the TOML representation of a promoter config, as it is read
+// by drbd-reactor.
+type ConfigText struct {
+	// Path is the path of the config file on the nodes.
+	Path string `json:"path"`
+	// Config is the content of the config file.
+	Config string `json:"config"`
+	// Deployed is true if Config is the config registered in LINSTOR, and
+	// false if it was regenerated from the LINSTOR resource.
+	Deployed bool `json:"deployed"`
+}
+
+// ShowConfig returns the promoter config cfg as it is registered in LINSTOR
+// if deployed is set. Otherwise, the config is regenerated like Repair does,
+// showing what LINSTOR Gateway would deploy. Nothing is changed.
+func ShowConfig(ctx context.Context, cli *client.Client, cfg *PromoterConfig, deployed bool, regenerate RegenerateFunc) (*ConfigText, error) {
+	if !deployed {
+		definition, _, volumeDefinitions, resources, err := cfg.DeployedResources(ctx, cli)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch deployed resources: %w", err)
+		}
+
+		cfg, err = regenerate(definition, volumeDefinitions, resources)
+		if err != nil {
+			return nil, fmt.Errorf("failed to regenerate config: %w", err)
+		}
+	}
+
+	text, err := encodeConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ConfigText{Path: ConfigPath(cfg.ID), Config: text, Deployed: deployed}, nil
+}
+
+// DiffConfigs returns a unified diff between the TOML representations of two
+// promoter configs. It is empty if both are the same.
+func DiffConfigs(old, new *PromoterConfig) (string, error) {
+	oldText, err := encodeConfig(old)
+	if err != nil {
+		return "", err
+	}
+
+	newText, err := encodeConfig(new)
+	if err != nil {
+		return "", err
+	}
+
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(oldText),
+		B:        difflib.SplitLines(newText),
+		FromFile: ConfigPath(old.ID) + " (registered)",
+		ToFile:   ConfigPath(new.ID) + " (regenerated)",
+		Context:  3,
+	})
+}
@@ -0,0 +1,93 @@
+package reactor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffConfigs(t *testing.T) {
+	t.Parallel()
+
+	base := PromoterConfig{
+		ID: "iscsi-target1",
+		Resources: map[string]PromoterResourceConfig{
+			"target1": {
+				Start: []StartEntry{
+					&ResourceAgent{Type: "ocf:heartbeat:IPaddr2", Name: "service_ip", Attributes: map[string]string{"ip": "192.168.127.1", "cidr_netmask": "24"}},
+				},
+				Runner: "systemd",
+			},
+		},
+	}
+	drifted := PromoterConfig{
+		ID: "iscsi-target1",
+		Resources: map[string]PromoterResourceConfig{
+			"target1": {
+				Start: []StartEntry{
+					&ResourceAgent{Type: "ocf:heartbeat:IPaddr2", Name: "service_ip", Attributes: map[string]string{"ip": "192.168.127.2", "cidr_netmask": "24"}},
+				},
+				Runner: "systemd",
+			},
+		},
+	}
+
+	cases := []struct {
+		name     string
+		old      *PromoterConfig
+		new      *PromoterConfig
+		expected string
+	}{{
+		name: "unchanged",
+		old:  &base,
+		new:  &base,
+	}, {
+		name: "changed",
+		old:  &drifted,
+		new:  &base,
+		expected: `--- /etc/drbd-reactor.d/linstor-gateway-iscsi-target1.toml (registered)
++++ /etc/drbd-reactor.d/linstor-gateway-iscsi-target1.toml (regenerated)
+@@ -6,5 +6,5 @@
+ 
+     [promoter.resources.target1]
+       runner = "systemd"
+-      start = ["ocf:heartbeat:IPaddr2 service_ip cidr_netmask=24 ip=192.168.127.2"]
++      start = ["ocf:heartbeat:IPaddr2 service_ip cidr_netmask=24 ip=192.168.127.1"]
+ 
+`,
+	}}
+
+	for i := range cases {
+		tcase := &cases[i]
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+			actual, err := DiffConfigs(tcase.old, tcase.new)
+			assert.NoError(t, err)
+			assert.Equal(t, tcase.expected, actual)
+		})
+	}
+}
+
+func TestShowConfigDeployed(t *testing.T) {
+	t.Parallel()
+
+	cfg := PromoterConfig{
+		ID: "iscsi-target1",
+		Resources: map[string]PromoterResourceConfig{
+			"target1": {
+				Start: []StartEntry{
+					&ResourceAgent{Type: "ocf:heartbeat:IPaddr2", Name: "service_ip", Attributes: map[string]string{"ip": "192.168.127.1", "cidr_netmask": "24"}},
+				},
+				Runner: "systemd",
+			},
+		},
+	}
+
+	// The deployed config is shown as is, without fetching the resource.
+	actual, err := ShowConfig(context.Background(), nil, &cfg, true, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "/etc/drbd-reactor.d/linstor-gateway-iscsi-target1.toml", actual.Path)
+	assert.True(t, actual.Deployed)
+	assert.Contains(t, actual.Config, `start = ["ocf:heartbeat:IPaddr2 service_ip cidr_netmask=24 ip=192.168.127.1"]`)
+}
@@ -0,0 +1,121 @@
+package reconcile
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+const (
+	resultSucceeded = "succeeded"
+	resultFailed    = "failed"
+	// resultReported is recorded if the action is disabled.
+	resultReported = "reported"
+	// resultBackoff is recorded if the action is not retried yet after a
+	// failure.
+	resultBackoff = "backoff"
+	// resultPostponed is recorded if too many actions were taken in a
+	// single check.
+	resultPostponed = "postponed"
+)
+
+type remediation struct {
+	action, result string
+}
+
+// Metrics records the results of the checks of a Reconciler. It serves them
+// in the Prometheus text format.
+type Metrics struct {
+	mu           sync.Mutex
+	checks       int
+	checkErrors  int
+	targets      int
+	degraded     map[string]int
+	remediations map[remediation]int
+}
+
+// NewMetrics returns empty Metrics.
+func NewMetrics() *Metrics {
+	return &Metrics{
+		degraded:     make(map[string]int),
+		remediations: make(map[remediation]int),
+	}
+}
+
+func (m *Metrics) checked(err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.checks++
+	if err != nil {
+		m.checkErrors++
+	}
+}
+
+func (m *Metrics) setTargets(total int, degraded map[string]int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.targets = total
+	for kind := range m.degraded {
+		m.degraded[kind] = 0
+	}
+	for kind, n := range degraded {
+		m.degraded[kind] = n
+	}
+}
+
+func (m *Metrics) remediated(action, result string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.remediations[remediation{action: action, result: result}]++
+}
+
+// ServeHTTP implements http.Handler.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	fmt.Fprintln(w, "# HELP linstor_gateway_reconcile_checks_total Number of checks of all targets.")
+	fmt.Fprintln(w, "# TYPE linstor_gateway_reconcile_checks_total counter")
+	fmt.Fprintf(w, "linstor_gateway_reconcile_checks_total %d\n", m.checks)
+
+	fmt.Fprintln(w, "# HELP linstor_gateway_reconcile_check_errors_total Number of checks that failed to list the targets.")
+	fmt.Fprintln(w, "# TYPE linstor_gateway_reconcile_check_errors_total counter")
+	fmt.Fprintf(w, "linstor_gateway_reconcile_check_errors_total %d\n", m.checkErrors)
+
+	fmt.Fprintln(w, "# HELP linstor_gateway_targets Number of targets and exports at the last check.")
+	fmt.Fprintln(w, "# TYPE linstor_gateway_targets gauge")
+	fmt.Fprintf(w, "linstor_gateway_targets %d\n", m.targets)
+
+	fmt.Fprintln(w, "# HELP linstor_gateway_degraded_targets Number of degraded targets and exports at the last check.")
+	fmt.Fprintln(w, "# TYPE linstor_gateway_degraded_targets gauge")
+	kinds := make([]string, 0, len(m.degraded))
+	for kind := range m.degraded {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	for _, kind := range kinds {
+		fmt.Fprintf(w, "linstor_gateway_degraded_targets{kind=%q} %d\n", kind, m.degraded[kind])
+	}
+
+	fmt.Fprintln(w, "# HELP linstor_gateway_remediations_total Number of problems found, by remediation action and result.")
+	fmt.Fprintln(w, "# TYPE linstor_gateway_remediations_total counter")
+	keys := make([]remediation, 0, len(m.remediations))
+	for key := range m.remediations {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].action != keys[j].action {
+			return keys[i].action < keys[j].action
+		}
+		return keys[i].result < keys[j].result
+	})
+	for _, key := range keys {
+		fmt.Fprintf(w, "linstor_gateway_remediations_total{action=%q,result=%q} %d\n", key.action, key.result, m.remediations[key])
+	}
+}
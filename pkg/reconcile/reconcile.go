@@ -0,0 +1,288 @@
+// Package reconcile periodically checks all targets and exports and
+// remediates common problems, such as drifted drbd-reactor configs or
+// services that failed to start.
+//
+// Every remediation action can be disabled on its own. With all actions
+// disabled, problems are only reported.
+package reconcile
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/LINBIT/linstor-gateway/pkg/common"
+)
+
+const (
+	// ActionRepair re-registers a drbd-reactor config that drifted from
+	// what LINSTOR Gateway would generate.
+	ActionRepair = "repair"
+	// ActionRestart stops and starts a target whose services failed to
+	// start.
+	ActionRestart = "restart"
+)
+
+// Actions selects the remediation actions that are taken. Problems that
+// would be remediated by a disabled action are only reported.
+type Actions struct {
+	Repair  bool
+	Restart bool
+}
+
+// enabled reports whether action is enabled.
+func (a Actions) enabled(action string) bool {
+	switch action {
+	case ActionRepair:
+		return a.Repair
+	case ActionRestart:
+		return a.Restart
+	}
+
+	return false
+}
+
+// Config controls how often the targets are checked and how eagerly
+// problems are remediated.
+type Config struct {
+	// Interval is the time between two checks of all targets.
+	Interval time.Duration
+	Actions  Actions
+	// MaxActions limits the remediation actions taken in a single check.
+	// Further problems are remediated in the next checks.
+	MaxActions int
+	// Backoff is how long to wait before retrying an action on a target
+	// after it failed. It is doubled after every failure, up to
+	// MaxBackoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+// DefaultConfig is a conservative configuration that only reports problems.
+var DefaultConfig = Config{
+	Interval:   time.Minute,
+	MaxActions: 1,
+	Backoff:    5 * time.Minute,
+	MaxBackoff: time.Hour,
+}
+
+// Target is a target or export that is checked by the Reconciler.
+type Target struct {
+	// Kind is the type of the target, e.g. "iscsi".
+	Kind string
+	// Name identifies the target, e.g. its IQN.
+	Name   string
+	Status common.ResourceStatus
+	// Drift returns a diff from the registered to the generated
+	// drbd-reactor config, or "" if it did not drift. It is nil if drift
+	// can not be detected for this kind of target.
+	Drift func(ctx context.Context) (string, error)
+	// Repair registers the generated drbd-reactor config. It is nil if the
+	// config of this kind of target can not be repaired.
+	Repair func(ctx context.Context) error
+	// Restart stops and starts the services of the target.
+	Restart func(ctx context.Context) error
+}
+
+func (t *Target) key() string {
+	return t.Kind + "/" + t.Name
+}
+
+// ListFunc returns all targets that are checked.
+type ListFunc func(ctx context.Context) ([]Target, error)
+
+// targetState is what the Reconciler remembers about a target between
+// checks.
+type targetState struct {
+	// notStarted is set if the services of the target had failed to start
+	// at the last check.
+	notStarted bool
+	// failures is the number of consecutive failed actions.
+	failures int
+	// retryAt is the earliest time another action is taken.
+	retryAt time.Time
+}
+
+// Reconciler checks all targets and remediates their problems.
+type Reconciler struct {
+	cfg     Config
+	list    ListFunc
+	metrics *Metrics
+	states  map[string]*targetState
+	now     func() time.Time
+}
+
+// New returns a Reconciler that checks the targets returned by list. Its
+// results are recorded in metrics.
+func New(cfg Config, list ListFunc, metrics *Metrics) *Reconciler {
+	return &Reconciler{
+		cfg:     cfg,
+		list:    list,
+		metrics: metrics,
+		states:  make(map[string]*targetState),
+		now:     time.Now,
+	}
+}
+
+// Run checks all targets every cfg.Interval until ctx is cancelled. Errors
+// of single checks are logged, and do not stop the Reconciler.
+func (r *Reconciler) Run(ctx context.Context) error {
+	ticker := time.NewTicker(r.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		err := r.Check(ctx)
+		if err != nil {
+			log.WithError(err).Warn("failed to check targets")
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check checks all targets once, and remediates the problems it finds.
+func (r *Reconciler) Check(ctx context.Context) error {
+	targets, err := r.list(ctx)
+	r.metrics.checked(err)
+	if err != nil {
+		return fmt.Errorf("failed to list targets: %w", err)
+	}
+
+	seen := make(map[string]bool, len(targets))
+	degraded := make(map[string]int)
+	actions := 0
+	for i := range targets {
+		t := &targets[i]
+		seen[t.key()] = true
+		if t.Status.Degraded() {
+			degraded[t.Kind]++
+		}
+
+		action, reason := r.diagnose(ctx, t)
+		if action == "" {
+			continue
+		}
+
+		logger := log.WithFields(log.Fields{
+			"target": t.Name,
+			"kind":   t.Kind,
+			"action": action,
+		})
+
+		if !r.cfg.Actions.enabled(action) {
+			logger.Warnf("%s, not remediating (report only)", reason)
+			r.metrics.remediated(action, resultReported)
+			continue
+		}
+
+		state := r.state(t)
+		if r.now().Before(state.retryAt) {
+			logger.Debugf("%s, waiting until %s to retry", reason, state.retryAt.Format(time.RFC3339))
+			r.metrics.remediated(action, resultBackoff)
+			continue
+		}
+
+		if r.cfg.MaxActions > 0 && actions >= r.cfg.MaxActions {
+			logger.Infof("%s, postponed to the next check", reason)
+			r.metrics.remediated(action, resultPostponed)
+			continue
+		}
+
+		actions++
+		logger.Warnf("%s, remediating", reason)
+		err := r.remediate(ctx, t, action)
+		if err != nil {
+			state.failures++
+			state.retryAt = r.now().Add(r.backoff(state.failures))
+			logger.WithError(err).Errorf("remediation failed, retrying after %s", state.retryAt.Format(time.RFC3339))
+			r.metrics.remediated(action, resultFailed)
+			continue
+		}
+
+		state.failures = 0
+		state.retryAt = time.Time{}
+		state.notStarted = false
+		logger.Info("remediation succeeded")
+		r.metrics.remediated(action, resultSucceeded)
+	}
+
+	for key := range r.states {
+		if !seen[key] {
+			delete(r.states, key)
+		}
+	}
+
+	r.metrics.setTargets(len(targets), degraded)
+
+	return nil
+}
+
+// diagnose returns the action that remediates the problem of t, and a
+// description of the problem. The action is "" if there is nothing to do.
+//
+// A target whose services failed to start is only restarted if that was
+// already the case in the previous check, so that failovers drbd-reactor
+// started on its own, e.g. after a node crashed, are not disturbed.
+func (r *Reconciler) diagnose(ctx context.Context, t *Target) (string, string) {
+	if t.Drift != nil && t.Repair != nil {
+		diff, err := t.Drift(ctx)
+		if err != nil {
+			log.WithError(err).WithField("target", t.Name).Warn("failed to check the drbd-reactor config for drift")
+		} else if diff != "" {
+			log.WithField("target", t.Name).Debugf("drbd-reactor config drifted:\n%s", diff)
+			return ActionRepair, "drbd-reactor config drifted"
+		}
+	}
+
+	notStarted := t.Status.Service == common.ServiceStateFailed
+	state := r.state(t)
+	wasNotStarted := state.notStarted
+	state.notStarted = notStarted
+	if notStarted && wasNotStarted && t.Restart != nil {
+		return ActionRestart, "services failed to start"
+	}
+
+	return "", ""
+}
+
+func (r *Reconciler) remediate(ctx context.Context, t *Target, action string) error {
+	switch action {
+	case ActionRepair:
+		return t.Repair(ctx)
+	case ActionRestart:
+		return t.Restart(ctx)
+	}
+
+	return fmt.Errorf("unknown action %s", action)
+}
+
+func (r *Reconciler) state(t *Target) *targetState {
+	state, ok := r.states[t.key()]
+	if !ok {
+		state = &targetState{}
+		r.states[t.key()] = state
+	}
+
+	return state
+}
+
+// backoff returns the wait before the next action after the given number of
+// consecutive failures.
+func (r *Reconciler) backoff(failures int) time.Duration {
+	wait := r.cfg.Backoff
+	for i := 1; i < failures && wait < r.cfg.MaxBackoff; i++ {
+		wait *= 2
+	}
+
+	if wait > r.cfg.MaxBackoff {
+		wait = r.cfg.MaxBackoff
+	}
+
+	return wait
+}
@@ -0,0 +1,190 @@
+package reconcile
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/LINBIT/linstor-gateway/pkg/common"
+)
+
+// fakeTarget records the actions taken on a target.
+type fakeTarget struct {
+	drift    string
+	status   common.ResourceStatus
+	fail     bool
+	repairs  int
+	restarts int
+}
+
+func (f *fakeTarget) target(name string) Target {
+	result := func() error {
+		if f.fail {
+			return errors.New("broken")
+		}
+		return nil
+	}
+
+	return Target{
+		Kind:   "iscsi",
+		Name:   name,
+		Status: f.status,
+		Drift: func(ctx context.Context) (string, error) {
+			return f.drift, nil
+		},
+		Repair: func(ctx context.Context) error {
+			f.repairs++
+			return result()
+		},
+		Restart: func(ctx context.Context) error {
+			f.restarts++
+			return result()
+		},
+	}
+}
+
+func TestCheck(t *testing.T) {
+	t.Parallel()
+	started := common.ResourceStatus{State: common.ResourceStateOK, Service: common.ServiceStateStarted, Primary: "node-a"}
+	notStarted := common.ResourceStatus{State: common.ResourceStateOK, Service: common.ServiceStateFailed}
+	transitioning := common.ResourceStatus{State: common.ResourceStateOK, Service: common.ServiceStateTransitioning}
+	allActions := Actions{Repair: true, Restart: true}
+
+	cases := []struct {
+		name    string
+		actions Actions
+		targets []*fakeTarget
+		// checks is the number of checks, one minute apart.
+		checks           int
+		maxActions       int
+		expectedRepairs  []int
+		expectedRestarts []int
+	}{{
+		name:             "healthy",
+		actions:          allActions,
+		targets:          []*fakeTarget{{status: started}},
+		checks:           2,
+		expectedRepairs:  []int{0},
+		expectedRestarts: []int{0},
+	}, {
+		name:             "drift",
+		actions:          allActions,
+		targets:          []*fakeTarget{{status: started, drift: "-old\n+new"}},
+		checks:           1,
+		expectedRepairs:  []int{1},
+		expectedRestarts: []int{0},
+	}, {
+		name:             "report only",
+		targets:          []*fakeTarget{{status: notStarted, drift: "-old\n+new"}},
+		checks:           3,
+		expectedRepairs:  []int{0},
+		expectedRestarts: []int{0},
+	}, {
+		name:             "not started at a single check",
+		actions:          allActions,
+		targets:          []*fakeTarget{{status: notStarted}},
+		checks:           1,
+		expectedRepairs:  []int{0},
+		expectedRestarts: []int{0},
+	}, {
+		name:             "not started at two checks",
+		actions:          allActions,
+		targets:          []*fakeTarget{{status: notStarted}},
+		checks:           2,
+		expectedRepairs:  []int{0},
+		expectedRestarts: []int{1},
+	}, {
+		name:             "transitioning",
+		actions:          allActions,
+		targets:          []*fakeTarget{{status: transitioning}},
+		checks:           3,
+		expectedRepairs:  []int{0},
+		expectedRestarts: []int{0},
+	}, {
+		name:             "restart disabled",
+		actions:          Actions{Repair: true},
+		targets:          []*fakeTarget{{status: notStarted}},
+		checks:           3,
+		expectedRepairs:  []int{0},
+		expectedRestarts: []int{0},
+	}, {
+		name:             "backoff after failure",
+		actions:          allActions,
+		targets:          []*fakeTarget{{status: started, drift: "-old\n+new", fail: true}},
+		checks:           4,
+		expectedRepairs:  []int{1},
+		expectedRestarts: []int{0},
+	}, {
+		name:             "max actions",
+		actions:          allActions,
+		targets:          []*fakeTarget{{status: started, drift: "-old\n+new"}, {status: started, drift: "-old\n+new"}},
+		checks:           1,
+		maxActions:       1,
+		expectedRepairs:  []int{1, 0},
+		expectedRestarts: []int{0, 0},
+	}}
+
+	for i := range cases {
+		tcase := &cases[i]
+		t.Run(tcase.name, func(t *testing.T) {
+			t.Parallel()
+			list := func(ctx context.Context) ([]Target, error) {
+				var targets []Target
+				for j, f := range tcase.targets {
+					targets = append(targets, f.target(string(rune('a'+j))))
+				}
+				return targets, nil
+			}
+
+			cfg := Config{Interval: time.Minute, Actions: tcase.actions, MaxActions: tcase.maxActions, Backoff: 5 * time.Minute, MaxBackoff: time.Hour}
+			r := New(cfg, list, NewMetrics())
+			now := time.Date(2022, 8, 1, 0, 0, 0, 0, time.UTC)
+			r.now = func() time.Time { return now }
+
+			for j := 0; j < tcase.checks; j++ {
+				err := r.Check(context.Background())
+				assert.NoError(t, err)
+				now = now.Add(time.Minute)
+			}
+
+			for j, f := range tcase.targets {
+				assert.Equal(t, tcase.expectedRepairs[j], f.repairs, "repairs of target %d", j)
+				assert.Equal(t, tcase.expectedRestarts[j], f.restarts, "restarts of target %d", j)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	t.Parallel()
+	r := New(Config{Backoff: time.Minute, MaxBackoff: 5 * time.Minute}, nil, NewMetrics())
+
+	assert.Equal(t, time.Minute, r.backoff(1))
+	assert.Equal(t, 2*time.Minute, r.backoff(2))
+	assert.Equal(t, 4*time.Minute, r.backoff(3))
+	assert.Equal(t, 5*time.Minute, r.backoff(4))
+	assert.Equal(t, 5*time.Minute, r.backoff(100))
+}
+
+func TestMetrics(t *testing.T) {
+	t.Parallel()
+	m := NewMetrics()
+	m.checked(nil)
+	m.setTargets(3, map[string]int{"iscsi": 1})
+	m.remediated(ActionRepair, resultSucceeded)
+	m.remediated(ActionRestart, resultReported)
+
+	recorder := httptest.NewRecorder()
+	m.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+
+	body := recorder.Body.String()
+	assert.Contains(t, body, "linstor_gateway_reconcile_checks_total 1\n")
+	assert.Contains(t, body, "linstor_gateway_targets 3\n")
+	assert.Contains(t, body, `linstor_gateway_degraded_targets{kind="iscsi"} 1`)
+	assert.Contains(t, body, `linstor_gateway_remediations_total{action="repair",result="succeeded"} 1`)
+	assert.Contains(t, body, `linstor_gateway_remediations_total{action="restart",result="reported"} 1`)
+}
@@ -0,0 +1,96 @@
+package reconcile
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/LINBIT/linstor-gateway/pkg/iscsi"
+	"github.com/LINBIT/linstor-gateway/pkg/linstorcontrol"
+	"github.com/LINBIT/linstor-gateway/pkg/nfs"
+	"github.com/LINBIT/linstor-gateway/pkg/nvmeof"
+)
+
+// AllTargets returns a ListFunc for all iSCSI targets, NFS exports and
+// NVMe-oF targets. Drifted configs can only be detected and repaired for
+// iSCSI targets.
+func AllTargets(cli *linstorcontrol.Linstor) ListFunc {
+	iscsiTargets := iscsi.NewWithClient(cli)
+	nfsExports := nfs.NewWithClient(cli)
+	nvmeTargets := nvmeof.NewWithClient(cli)
+
+	return func(ctx context.Context) ([]Target, error) {
+		var targets []Target
+
+		iscsiCfgs, err := iscsiTargets.List(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list iSCSI targets: %w", err)
+		}
+		for _, cfg := range iscsiCfgs {
+			iqn := cfg.IQN
+			targets = append(targets, Target{
+				Kind:   "iscsi",
+				Name:   iqn.String(),
+				Status: cfg.Status,
+				Drift: func(ctx context.Context) (string, error) {
+					return iscsiTargets.Drift(ctx, iqn)
+				},
+				Repair: func(ctx context.Context) error {
+					_, err := iscsiTargets.Repair(ctx, iqn, false)
+					return err
+				},
+				Restart: func(ctx context.Context) error {
+					_, err := iscsiTargets.Stop(ctx, iqn)
+					if err != nil {
+						return err
+					}
+					_, err = iscsiTargets.Start(ctx, iqn)
+					return err
+				},
+			})
+		}
+
+		nfsCfgs, err := nfsExports.List(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list NFS exports: %w", err)
+		}
+		for _, cfg := range nfsCfgs {
+			name := cfg.Name
+			targets = append(targets, Target{
+				Kind:   "nfs",
+				Name:   name,
+				Status: cfg.Status,
+				Restart: func(ctx context.Context) error {
+					_, err := nfsExports.Stop(ctx, name)
+					if err != nil {
+						return err
+					}
+					_, err = nfsExports.Start(ctx, name)
+					return err
+				},
+			})
+		}
+
+		nvmeCfgs, err := nvmeTargets.List(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list NVMe-oF targets: %w", err)
+		}
+		for _, cfg := range nvmeCfgs {
+			nqn := cfg.NQN
+			targets = append(targets, Target{
+				Kind:   "nvme",
+				Name:   nqn.String(),
+				Status: cfg.Status,
+				Restart: func(ctx context.Context) error {
+					_, err := nvmeTargets.Stop(ctx, nqn)
+					if err != nil {
+						return err
+					}
+					_, err = nvmeTargets.Start(ctx, nqn)
+					return err
+				},
+			})
+		}
+
+		return targets, nil
+	}
+}
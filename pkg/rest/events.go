@@ -0,0 +1,170 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/LINBIT/linstor-gateway/pkg/common"
+)
+
+const (
+	// eventLogSize is the number of events that are kept, so that
+	// subscribers can catch up after reconnecting.
+	eventLogSize = 1000
+)
+
+// WatchInterval is the interval in which the state of all targets and exports
+// is polled for the events endpoint.
+var WatchInterval = 10 * time.Second
+
+// Events streams state changes of all targets and exports as Server-Sent
+// Events. Every event carries its sequence number as SSE id. A subscriber
+// that sets the "since" query parameter or the Last-Event-ID header to the
+// last sequence number it received first gets all events it missed.
+func (s *server) Events() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			MustError(http.StatusInternalServerError, w, "streaming is not supported")
+			return
+		}
+
+		err := s.watchEvents()
+		if err != nil {
+			MustError(http.StatusInternalServerError, w, "%v", err)
+			return
+		}
+
+		seq := s.events.Seq()
+		since := r.URL.Query().Get("since")
+		if since == "" {
+			since = r.Header.Get("Last-Event-ID")
+		}
+		if since != "" {
+			seq, err = strconv.ParseUint(since, 10, 64)
+			if err != nil {
+				MustError(http.StatusBadRequest, w, "invalid sequence number: %v", err)
+				return
+			}
+		}
+
+		events, appended, err := s.events.Since(seq)
+		if err != nil {
+			MustError(http.StatusGone, w, "%v", err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		for {
+			for _, event := range events {
+				b, err := json.Marshal(event)
+				if err != nil {
+					log.WithError(err).Warn("failed to encode event")
+					continue
+				}
+
+				_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.Seq, event.Type, b)
+				if err != nil {
+					log.WithError(err).Debug("failed to write event, closing stream")
+					return
+				}
+				seq = event.Seq
+			}
+			flusher.Flush()
+
+			select {
+			case <-appended:
+			case <-r.Context().Done():
+				return
+			}
+
+			events, appended, err = s.events.Since(seq)
+			if err != nil {
+				// The subscriber fell behind further than the log
+				// reaches. It notices the gap when reconnecting.
+				log.WithError(err).Debug("subscriber too slow, closing stream")
+				return
+			}
+		}
+	}
+}
+
+// watchEvents starts recording the state changes of all targets and exports
+// in the event log, unless that already happened. Recording starts with the
+// first subscriber and continues until the server shuts down, so that
+// subscribers can reconnect without missing events.
+func (s *server) watchEvents() error {
+	s.watchMu.Lock()
+	defer s.watchMu.Unlock()
+
+	if s.watching {
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(s.ctx)
+
+	watchers := []func(context.Context, time.Duration) (<-chan common.Event, error){
+		s.iscsi.Watch,
+		s.nfs.Watch,
+		s.nvmeof.Watch,
+	}
+	channels := make([]<-chan common.Event, 0, len(watchers))
+	for _, watch := range watchers {
+		events, err := watch(ctx, WatchInterval)
+		if err != nil {
+			cancel()
+			return fmt.Errorf("failed to watch targets: %w", err)
+		}
+		channels = append(channels, events)
+	}
+
+	go func() {
+		defer cancel()
+		for event := range mergeEvents(ctx, channels...) {
+			s.events.Append(event)
+		}
+	}()
+
+	s.watching = true
+
+	return nil
+}
+
+// mergeEvents forwards the events of all given channels to the returned
+// channel, which is closed once all of them are closed or ctx is cancelled.
+func mergeEvents(ctx context.Context, channels ...<-chan common.Event) <-chan common.Event {
+	merged := make(chan common.Event)
+
+	var wg sync.WaitGroup
+	wg.Add(len(channels))
+	for _, ch := range channels {
+		go func(ch <-chan common.Event) {
+			defer wg.Done()
+			for event := range ch {
+				select {
+				case merged <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}(ch)
+	}
+
+	go func() {
+		wg.Wait()
+		close(merged)
+	}()
+
+	return merged
+}
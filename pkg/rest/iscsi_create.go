@@ -21,9 +21,15 @@ func (s *server) ISCSICreate() http.HandlerFunc {
 			return
 		}
 
-		result, err := s.iscsi.Create(request.Context(), &rsc)
+		opts, err := createOptions(request)
 		if err != nil {
-			_, _ = Errorf(http.StatusBadRequest, writer, "failed to create iscsi resource: %v", err)
+			_, _ = Errorf(http.StatusBadRequest, writer, "%v", err)
+			return
+		}
+
+		result, err := s.iscsi.Create(request.Context(), &rsc, opts)
+		if err != nil {
+			_, _ = Errorf(errorStatus(err, http.StatusBadRequest), writer, "failed to create iscsi resource: %v", err)
 			return
 		}
 
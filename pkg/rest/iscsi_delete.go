@@ -2,12 +2,14 @@ package rest
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/gorilla/mux"
 	log "github.com/sirupsen/logrus"
 
+	"github.com/LINBIT/linstor-gateway/pkg/common"
 	"github.com/LINBIT/linstor-gateway/pkg/iscsi"
 )
 
@@ -23,7 +25,7 @@ func (s *server) ISCSIDelete(all bool) http.HandlerFunc {
 		}
 
 		if all {
-			err = s.iscsi.Delete(ctx, iqn)
+			err = s.iscsi.Delete(ctx, iqn, deleteOptions(request))
 			if err != nil {
 				MustError(http.StatusInternalServerError, writer, "delete failed: %v", err)
 				return
@@ -35,14 +37,13 @@ func (s *server) ISCSIDelete(all bool) http.HandlerFunc {
 				return
 			}
 
-			oldCfg, err := s.iscsi.DeleteVolume(ctx, iqn, lun)
-			if err != nil {
-				MustError(http.StatusInternalServerError, writer, "error deleting volume: %v", err)
+			_, err = s.iscsi.DeleteVolume(ctx, iqn, lun)
+			if errors.Is(err, common.ErrNotFound) {
+				MustError(http.StatusNotFound, writer, "no resource found for iqn %s", iqn)
 				return
 			}
-
-			if oldCfg == nil {
-				MustError(http.StatusNotFound, writer, "no resource found for iqn %s", iqn)
+			if err != nil {
+				MustError(http.StatusInternalServerError, writer, "error deleting volume: %v", err)
 				return
 			}
 		}
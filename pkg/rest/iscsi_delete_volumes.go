@@ -0,0 +1,70 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/LINBIT/linstor-gateway/pkg/common"
+	"github.com/LINBIT/linstor-gateway/pkg/iscsi"
+)
+
+// ISCSIDeleteVolumes deletes several logical units of an iSCSI target via the
+// REST-API
+func (s *server) ISCSIDeleteVolumes() http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		iqn, err := iscsi.NewIqn(mux.Vars(request)["iqn"])
+		if err != nil {
+			MustError(http.StatusBadRequest, writer, "malformed iqn: %v", err)
+			return
+		}
+
+		var req struct {
+			LUNs []int `json:"luns"`
+		}
+		err = json.NewDecoder(request.Body).Decode(&req)
+		if err != nil {
+			MustError(http.StatusBadRequest, writer, "failed to parse request body: %v", err)
+			return
+		}
+
+		if len(req.LUNs) == 0 {
+			MustError(http.StatusBadRequest, writer, "no logical units given")
+			return
+		}
+
+		_, missing, err := s.iscsi.DeleteVolumes(request.Context(), iqn, req.LUNs)
+		if errors.Is(err, common.ErrNotFound) {
+			MustError(http.StatusNotFound, writer, "no resource found for iqn %s", iqn)
+			return
+		}
+		if err != nil {
+			MustError(errorStatus(err, http.StatusInternalServerError), writer, "error deleting volumes: %v", err)
+			return
+		}
+
+		skip := make(map[int]bool, len(req.LUNs))
+		for _, lun := range missing {
+			skip[lun] = true
+		}
+		deleted := make([]int, 0, len(req.LUNs))
+		for _, lun := range req.LUNs {
+			if !skip[lun] {
+				deleted = append(deleted, lun)
+				skip[lun] = true
+			}
+		}
+
+		writer.WriteHeader(http.StatusOK)
+		err = json.NewEncoder(writer).Encode(struct {
+			Deleted []int `json:"deleted"`
+			Missing []int `json:"missing"`
+		}{Deleted: deleted, Missing: missing})
+		if err != nil {
+			log.WithError(err).Warn("failed to write response")
+		}
+	}
+}
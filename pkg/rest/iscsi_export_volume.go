@@ -0,0 +1,46 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/LINBIT/linstor-gateway/pkg/common"
+	"github.com/LINBIT/linstor-gateway/pkg/iscsi"
+)
+
+func (s *server) ISCSISetExported(exported bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		iqn, err := iscsi.NewIqn(mux.Vars(r)["iqn"])
+		if err != nil {
+			MustError(http.StatusBadRequest, w, "malformed iqn: %v", err)
+			return
+		}
+
+		lun, err := strconv.Atoi(mux.Vars(r)["lun"])
+		if err != nil {
+			MustError(http.StatusBadRequest, w, "malformed LUN: %v", err)
+			return
+		}
+
+		cfg, err := s.iscsi.SetVolumeExported(r.Context(), iqn, lun, exported)
+		if errors.Is(err, common.ErrNotFound) {
+			MustError(http.StatusNotFound, w, "no volume found for iqn %s, lun %d", iqn, lun)
+			return
+		}
+		if err != nil {
+			MustError(errorStatus(err, http.StatusInternalServerError), w, "failed to change exported state of volume: %v", err)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		err = json.NewEncoder(w).Encode(cfg.VolumeConfig(lun))
+		if err != nil {
+			log.WithError(err).Warn("failed to write response")
+		}
+	}
+}
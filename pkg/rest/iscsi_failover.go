@@ -0,0 +1,39 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/LINBIT/linstor-gateway/pkg/common"
+	"github.com/LINBIT/linstor-gateway/pkg/iscsi"
+)
+
+func (s *server) ISCSIFailover() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		iqn, err := iscsi.NewIqn(mux.Vars(r)["iqn"])
+		if err != nil {
+			MustError(http.StatusBadRequest, w, "invalid iqn: %v", err)
+			return
+		}
+
+		cfg, err := s.iscsi.Failover(r.Context(), iqn)
+		if errors.Is(err, common.ErrNotFound) {
+			MustError(http.StatusNotFound, w, "no resource with iqn %s found", iqn)
+			return
+		}
+		if err != nil {
+			MustError(errorStatus(err, http.StatusInternalServerError), w, "failed to fail over target: %v", err)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		err = json.NewEncoder(w).Encode(cfg)
+		if err != nil {
+			log.WithError(err).Warn("failed to write response")
+		}
+	}
+}
@@ -2,12 +2,14 @@ package rest
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/gorilla/mux"
 	log "github.com/sirupsen/logrus"
 
+	"github.com/LINBIT/linstor-gateway/pkg/common"
 	"github.com/LINBIT/linstor-gateway/pkg/iscsi"
 )
 
@@ -20,13 +22,12 @@ func (s *server) ISCSIGet(all bool) http.HandlerFunc {
 		}
 
 		cfg, err := s.iscsi.Get(r.Context(), iqn)
-		if err != nil {
-			MustError(http.StatusInternalServerError, w, "failed to fetch resource status: %v", err)
+		if errors.Is(err, common.ErrNotFound) {
+			MustError(http.StatusNotFound, w, "no resource found for iqn %s", iqn)
 			return
 		}
-
-		if cfg == nil {
-			MustError(http.StatusNotFound, w, "no resource found for iqn %s", iqn)
+		if err != nil {
+			MustError(http.StatusInternalServerError, w, "failed to fetch resource status: %v", err)
 			return
 		}
 
@@ -34,7 +35,11 @@ func (s *server) ISCSIGet(all bool) http.HandlerFunc {
 			w.WriteHeader(http.StatusOK)
 			enc := json.NewEncoder(w)
 
-			err = enc.Encode(cfg)
+			if showSecrets(r) {
+				err = enc.Encode((*iscsi.ResourceConfigWithSecrets)(cfg))
+			} else {
+				err = enc.Encode(cfg)
+			}
 			if err != nil {
 				log.WithError(err).Warn("failed to write response")
 			}
@@ -9,7 +9,13 @@ import (
 
 func (s *server) ISCSIList() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		targets, err := s.iscsi.List(r.Context())
+		offset, limit, err := queryPage(r)
+		if err != nil {
+			MustError(http.StatusBadRequest, w, "%v", err)
+			return
+		}
+
+		targets, err := s.iscsi.ListPage(r.Context(), offset, limit)
 		if err != nil {
 			MustError(http.StatusInternalServerError, w, "Could not list targets: %v", err)
 			return
@@ -0,0 +1,49 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/LINBIT/linstor-gateway/pkg/common"
+	"github.com/LINBIT/linstor-gateway/pkg/iscsi"
+)
+
+// ISCSISetDescription changes the description of an iSCSI target via the REST-API
+func (s *server) ISCSISetDescription() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		iqn, err := iscsi.NewIqn(mux.Vars(r)["iqn"])
+		if err != nil {
+			MustError(http.StatusBadRequest, w, "invalid iqn: %v", err)
+			return
+		}
+
+		var req struct {
+			Description string `json:"description"`
+		}
+		err = json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			MustError(http.StatusBadRequest, w, "failed to parse request body: %v", err)
+			return
+		}
+
+		cfg, err := s.iscsi.SetDescription(r.Context(), iqn, req.Description)
+		if errors.Is(err, common.ErrNotFound) {
+			MustError(http.StatusNotFound, w, "no resource with iqn %s found", iqn)
+			return
+		}
+		if err != nil {
+			MustError(errorStatus(err, http.StatusInternalServerError), w, "failed to set description: %v", err)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		err = json.NewEncoder(w).Encode(cfg)
+		if err != nil {
+			log.WithError(err).Warn("failed to write response")
+		}
+	}
+}
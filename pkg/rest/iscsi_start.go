@@ -2,11 +2,13 @@ package rest
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 
 	"github.com/gorilla/mux"
 	log "github.com/sirupsen/logrus"
 
+	"github.com/LINBIT/linstor-gateway/pkg/common"
 	"github.com/LINBIT/linstor-gateway/pkg/iscsi"
 )
 
@@ -19,13 +21,12 @@ func (s *server) ISCSIStart() http.HandlerFunc {
 		}
 
 		cfg, err := s.iscsi.Start(r.Context(), iqn)
-		if err != nil {
-			MustError(http.StatusInternalServerError, w, "failed to start target: %v", err)
+		if errors.Is(err, common.ErrNotFound) {
+			MustError(http.StatusNotFound, w, "no resource with iqn %s found", iqn)
 			return
 		}
-
-		if cfg == nil {
-			MustError(http.StatusNotFound, w, "no resource with iqn %s found", iqn)
+		if err != nil {
+			MustError(http.StatusInternalServerError, w, "failed to start target: %v", err)
 			return
 		}
 
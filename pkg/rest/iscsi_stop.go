@@ -2,11 +2,13 @@ package rest
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 
 	"github.com/gorilla/mux"
 	log "github.com/sirupsen/logrus"
 
+	"github.com/LINBIT/linstor-gateway/pkg/common"
 	"github.com/LINBIT/linstor-gateway/pkg/iscsi"
 )
 
@@ -21,13 +23,12 @@ func (s *server) ISCSIStop() http.HandlerFunc {
 		}
 
 		cfg, err := s.iscsi.Stop(ctx, iqn)
-		if err != nil {
-			MustError(http.StatusInternalServerError, w, "failed to stop resource: %v", err)
+		if errors.Is(err, common.ErrNotFound) {
+			MustError(http.StatusNotFound, w, "no resource found for iqn %s", iqn)
 			return
 		}
-
-		if cfg == nil {
-			MustError(http.StatusNotFound, w, "no resource found for iqn %s", iqn)
+		if err != nil {
+			MustError(http.StatusInternalServerError, w, "failed to stop resource: %v", err)
 			return
 		}
 
@@ -0,0 +1,65 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/LINBIT/linstor-gateway/pkg/common"
+	"github.com/LINBIT/linstor-gateway/pkg/nfs"
+)
+
+func (s *server) NFSAddVolume() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		resource := mux.Vars(r)["resource"]
+
+		id, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			MustError(http.StatusBadRequest, w, "invalid volume number %q: %v", mux.Vars(r)["id"], err)
+			return
+		}
+
+		var vCfg nfs.VolumeConfig
+		decoder := json.NewDecoder(r.Body)
+		err = decoder.Decode(&vCfg)
+		if err != nil {
+			MustError(http.StatusBadRequest, w, "failed to parse request body: %v", err)
+			return
+		}
+
+		if vCfg.Number != 0 && vCfg.Number != id {
+			MustError(http.StatusBadRequest, w, "expected volume number to be %d, but request body has %d", id, vCfg.Number)
+			return
+		}
+
+		// Fill in default
+		vCfg.Number = id
+
+		if id < 1 {
+			MustError(http.StatusBadRequest, w, "volume number must be positive, is %d", id)
+			return
+		}
+
+		cfg, err := s.nfs.AddVolume(r.Context(), resource, &vCfg)
+		if errors.Is(err, common.ErrNotFound) {
+			MustError(http.StatusNotFound, w, "no resource found")
+			return
+		}
+		if err != nil {
+			MustError(errorStatus(err, http.StatusInternalServerError), w, "failed to add volume to resource: %v", err)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		enc := json.NewEncoder(w)
+
+		err = enc.Encode(cfg.VolumeConfig(id))
+		if err != nil {
+			log.WithError(err).Warn("failed to write response")
+		}
+	}
+}
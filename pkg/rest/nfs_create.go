@@ -21,9 +21,15 @@ func (s *server) NFSCreate() http.HandlerFunc {
 			return
 		}
 
-		result, err := s.nfs.Create(request.Context(), &rsc)
+		opts, err := createOptions(request)
 		if err != nil {
-			_, _ = Errorf(http.StatusBadRequest, writer, "failed to create nfs resource: %v", err)
+			_, _ = Errorf(http.StatusBadRequest, writer, "%v", err)
+			return
+		}
+
+		result, err := s.nfs.Create(request.Context(), &rsc, opts)
+		if err != nil {
+			_, _ = Errorf(errorStatus(err, http.StatusBadRequest), writer, "failed to create nfs resource: %v", err)
 			return
 		}
 
@@ -2,11 +2,14 @@ package rest
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/gorilla/mux"
 	log "github.com/sirupsen/logrus"
+
+	"github.com/LINBIT/linstor-gateway/pkg/common"
 )
 
 // NFSDelete deletes a highly-available NFS export via the REST-API
@@ -17,7 +20,7 @@ func (s *server) NFSDelete(all bool) http.HandlerFunc {
 		resource := mux.Vars(request)["resource"]
 
 		if all {
-			err := s.nfs.Delete(ctx, resource)
+			err := s.nfs.Delete(ctx, resource, deleteOptions(request))
 			if err != nil {
 				MustError(http.StatusInternalServerError, writer, "delete failed: %v", err)
 				return
@@ -29,14 +32,13 @@ func (s *server) NFSDelete(all bool) http.HandlerFunc {
 				return
 			}
 
-			oldCfg, err := s.nfs.DeleteVolume(ctx, resource, id)
-			if err != nil {
-				MustError(http.StatusInternalServerError, writer, "error deleting volume: %v", err)
+			_, err = s.nfs.DeleteVolume(ctx, resource, id)
+			if errors.Is(err, common.ErrNotFound) {
+				MustError(http.StatusNotFound, writer, "no resource found")
 				return
 			}
-
-			if oldCfg == nil {
-				MustError(http.StatusNotFound, writer, "no resource found")
+			if err != nil {
+				MustError(errorStatus(err, http.StatusInternalServerError), writer, "error deleting volume: %v", err)
 				return
 			}
 		}
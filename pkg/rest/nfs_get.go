@@ -2,11 +2,14 @@ package rest
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/gorilla/mux"
 	log "github.com/sirupsen/logrus"
+
+	"github.com/LINBIT/linstor-gateway/pkg/common"
 )
 
 func (s *server) NFSGet(all bool) http.HandlerFunc {
@@ -14,13 +17,12 @@ func (s *server) NFSGet(all bool) http.HandlerFunc {
 		resource := mux.Vars(r)["resource"]
 
 		cfg, err := s.nfs.Get(r.Context(), resource)
-		if err != nil {
-			MustError(http.StatusInternalServerError, w, "failed to fetch resource status: %v", err)
+		if errors.Is(err, common.ErrNotFound) {
+			MustError(http.StatusNotFound, w, "no resource found")
 			return
 		}
-
-		if cfg == nil {
-			MustError(http.StatusNotFound, w, "no resource found")
+		if err != nil {
+			MustError(http.StatusInternalServerError, w, "failed to fetch resource status: %v", err)
 			return
 		}
 
@@ -9,7 +9,13 @@ import (
 
 func (s *server) NFSList() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		targets, err := s.nfs.List(r.Context())
+		offset, limit, err := queryPage(r)
+		if err != nil {
+			MustError(http.StatusBadRequest, w, "%v", err)
+			return
+		}
+
+		targets, err := s.nfs.ListPage(r.Context(), offset, limit)
 		if err != nil {
 			MustError(http.StatusInternalServerError, w, "Could not list exports: %v", err)
 			return
@@ -0,0 +1,46 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/LINBIT/linstor-gateway/pkg/common"
+)
+
+// NFSRename renames an NFS export via the REST-API
+func (s *server) NFSRename() http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		resource := mux.Vars(request)["resource"]
+
+		var req struct {
+			Name string `json:"name"`
+		}
+		err := json.NewDecoder(request.Body).Decode(&req)
+		if err != nil {
+			MustError(http.StatusBadRequest, writer, "failed to parse request body: %v", err)
+			return
+		}
+
+		cfg, err := s.nfs.Rename(request.Context(), resource, req.Name)
+		if errors.Is(err, common.ErrNotFound) {
+			MustError(http.StatusNotFound, writer, "no resource found")
+			return
+		}
+		if err != nil {
+			MustError(errorStatus(err, http.StatusBadRequest), writer, "failed to rename export: %v", err)
+			return
+		}
+
+		writer.Header().Add("Location", fmt.Sprintf("../%s", cfg.Name))
+		writer.WriteHeader(http.StatusOK)
+		err = json.NewEncoder(writer).Encode(cfg)
+		if err != nil {
+			log.WithError(err).Warn("failed to write response")
+		}
+	}
+}
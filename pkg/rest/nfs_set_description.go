@@ -0,0 +1,44 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/LINBIT/linstor-gateway/pkg/common"
+)
+
+// NFSSetDescription changes the description of an NFS export via the REST-API
+func (s *server) NFSSetDescription() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		resource := mux.Vars(r)["resource"]
+
+		var req struct {
+			Description string `json:"description"`
+		}
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			MustError(http.StatusBadRequest, w, "failed to parse request body: %v", err)
+			return
+		}
+
+		cfg, err := s.nfs.SetDescription(r.Context(), resource, req.Description)
+		if errors.Is(err, common.ErrNotFound) {
+			MustError(http.StatusNotFound, w, "no resource %s found", resource)
+			return
+		}
+		if err != nil {
+			MustError(errorStatus(err, http.StatusInternalServerError), w, "failed to set description: %v", err)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		err = json.NewEncoder(w).Encode(cfg)
+		if err != nil {
+			log.WithError(err).Warn("failed to write response")
+		}
+	}
+}
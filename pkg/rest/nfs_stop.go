@@ -2,10 +2,13 @@ package rest
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 
 	"github.com/gorilla/mux"
 	log "github.com/sirupsen/logrus"
+
+	"github.com/LINBIT/linstor-gateway/pkg/common"
 )
 
 func (s *server) NFSStop() http.HandlerFunc {
@@ -13,13 +16,12 @@ func (s *server) NFSStop() http.HandlerFunc {
 		resource := mux.Vars(request)["resource"]
 
 		cfg, err := s.nfs.Stop(request.Context(), resource)
-		if err != nil {
-			MustError(http.StatusInternalServerError, writer, "failed to stop export: %v", err)
+		if errors.Is(err, common.ErrNotFound) {
+			MustError(http.StatusNotFound, writer, "no resource found")
 			return
 		}
-
-		if cfg == nil {
-			MustError(http.StatusNotFound, writer, "no resource found")
+		if err != nil {
+			MustError(http.StatusInternalServerError, writer, "failed to stop export: %v", err)
 			return
 		}
 
@@ -2,6 +2,7 @@ package rest
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -50,13 +51,12 @@ func (s *server) NVMeoFAddVolume() func(http.ResponseWriter, *http.Request) {
 		}
 
 		cfg, err := s.nvmeof.AddVolume(ctx, nqn, &vCfg)
-		if err != nil {
-			MustError(http.StatusInternalServerError, writer, "failed to add volume to resource: %v", err)
+		if errors.Is(err, common.ErrNotFound) {
+			MustError(http.StatusNotFound, writer, "no resource found for nqn %s", nqn)
 			return
 		}
-
-		if cfg == nil {
-			MustError(http.StatusNotFound, writer, "no resource found for nqn %s", nqn)
+		if err != nil {
+			MustError(errorStatus(err, http.StatusInternalServerError), writer, "failed to add volume to resource: %v", err)
 			return
 		}
 
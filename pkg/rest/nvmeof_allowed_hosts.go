@@ -0,0 +1,48 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/LINBIT/linstor-gateway/pkg/common"
+	"github.com/LINBIT/linstor-gateway/pkg/nvmeof"
+)
+
+func (s *server) NVMeoFAddAllowedHost() http.HandlerFunc {
+	return s.nvmeofModifyAllowedHosts("add", s.nvmeof.AddAllowedHost)
+}
+
+func (s *server) NVMeoFRemoveAllowedHost() http.HandlerFunc {
+	return s.nvmeofModifyAllowedHosts("remove", s.nvmeof.RemoveAllowedHost)
+}
+
+func (s *server) nvmeofModifyAllowedHosts(op string, modify func(ctx context.Context, nqn nvmeof.Nqn, host string) (*nvmeof.ResourceConfig, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		nqn, err := nvmeof.NewNqn(mux.Vars(r)["nqn"])
+		if err != nil {
+			MustError(http.StatusBadRequest, w, "malformed nqn: %v", err)
+			return
+		}
+
+		cfg, err := modify(r.Context(), nqn, mux.Vars(r)["host"])
+		if errors.Is(err, common.ErrNotFound) {
+			MustError(http.StatusNotFound, w, "no resource found for nqn %s", nqn)
+			return
+		}
+		if err != nil {
+			MustError(errorStatus(err, http.StatusInternalServerError), w, "failed to %s allowed host: %v", op, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		err = json.NewEncoder(w).Encode(cfg)
+		if err != nil {
+			log.WithError(err).Warn("failed to write response")
+		}
+	}
+}
@@ -20,9 +20,15 @@ func (s *server) NVMeoFCreate() func(http.ResponseWriter, *http.Request) {
 			return
 		}
 
-		result, err := s.nvmeof.Create(request.Context(), &rsc)
+		opts, err := createOptions(request)
 		if err != nil {
-			_, _ = Errorf(http.StatusBadRequest, writer, "failed to create nvmeof resource: %v", err)
+			_, _ = Errorf(http.StatusBadRequest, writer, "%v", err)
+			return
+		}
+
+		result, err := s.nvmeof.Create(request.Context(), &rsc, opts)
+		if err != nil {
+			_, _ = Errorf(errorStatus(err, http.StatusBadRequest), writer, "failed to create nvmeof resource: %v", err)
 			return
 		}
 
@@ -2,12 +2,14 @@ package rest
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/gorilla/mux"
 	log "github.com/sirupsen/logrus"
 
+	"github.com/LINBIT/linstor-gateway/pkg/common"
 	"github.com/LINBIT/linstor-gateway/pkg/nvmeof"
 )
 
@@ -22,14 +24,16 @@ func (s *server) NVMeoFDelete(all bool) func(http.ResponseWriter, *http.Request)
 		}
 
 		if all {
-			deployed, err := s.nvmeof.Get(ctx, nqn)
+			_, err := s.nvmeof.Get(ctx, nqn)
+			if errors.Is(err, common.ErrNotFound) {
+				MustError(http.StatusNotFound, writer, "no resource found for nqn %s", nqn)
+				return
+			}
 			if err != nil {
 				MustError(http.StatusInternalServerError, writer, "failed to query target: %v", err)
+				return
 			}
-			if deployed == nil {
-				MustError(http.StatusNotFound, writer, "no resource found for nqn %s", nqn)
-			}
-			err = s.nvmeof.Delete(ctx, nqn)
+			err = s.nvmeof.Delete(ctx, nqn, deleteOptions(request))
 			if err != nil {
 				MustError(http.StatusInternalServerError, writer, "nvmeof delete failed: %v", err)
 				return
@@ -41,14 +45,13 @@ func (s *server) NVMeoFDelete(all bool) func(http.ResponseWriter, *http.Request)
 				return
 			}
 
-			oldCfg, err := s.nvmeof.DeleteVolume(ctx, nqn, nsid)
-			if err != nil {
-				MustError(http.StatusInternalServerError, writer, "error deleting volume: %v", err)
+			_, err = s.nvmeof.DeleteVolume(ctx, nqn, nsid)
+			if errors.Is(err, common.ErrNotFound) {
+				MustError(http.StatusNotFound, writer, "no resource found for nqn %s", nqn)
 				return
 			}
-
-			if oldCfg == nil {
-				MustError(http.StatusNotFound, writer, "no resource found for nqn %s", nqn)
+			if err != nil {
+				MustError(http.StatusInternalServerError, writer, "error deleting volume: %v", err)
 				return
 			}
 		}
@@ -2,12 +2,14 @@ package rest
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/gorilla/mux"
 	log "github.com/sirupsen/logrus"
 
+	"github.com/LINBIT/linstor-gateway/pkg/common"
 	"github.com/LINBIT/linstor-gateway/pkg/nvmeof"
 )
 
@@ -22,13 +24,12 @@ func (s *server) NVMeoFGet(all bool) func(http.ResponseWriter, *http.Request) {
 		}
 
 		cfg, err := s.nvmeof.Get(ctx, nqn)
-		if err != nil {
-			MustError(http.StatusInternalServerError, writer, "failed to fetch resource status: %v", err)
+		if errors.Is(err, common.ErrNotFound) {
+			MustError(http.StatusNotFound, writer, "no resource found for nqn %s", nqn)
 			return
 		}
-
-		if cfg == nil {
-			MustError(http.StatusNotFound, writer, "no resource found for nqn %s", nqn)
+		if err != nil {
+			MustError(http.StatusInternalServerError, writer, "failed to fetch resource status: %v", err)
 			return
 		}
 
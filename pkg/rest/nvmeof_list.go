@@ -11,7 +11,13 @@ func (s *server) NVMeoFList() func(http.ResponseWriter, *http.Request) {
 	return func(writer http.ResponseWriter, request *http.Request) {
 		ctx := request.Context()
 
-		cfgs, err := s.nvmeof.List(ctx)
+		offset, limit, err := queryPage(request)
+		if err != nil {
+			MustError(http.StatusBadRequest, writer, "%v", err)
+			return
+		}
+
+		cfgs, err := s.nvmeof.ListPage(ctx, offset, limit)
 		if err != nil {
 			_, err := Errorf(http.StatusInternalServerError, writer, "nvmeof list failed: %v", err)
 			if err != nil {
@@ -0,0 +1,49 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/LINBIT/linstor-gateway/pkg/common"
+	"github.com/LINBIT/linstor-gateway/pkg/nvmeof"
+)
+
+// NVMeoFSetDescription changes the description of an NVMe-oF target via the REST-API
+func (s *server) NVMeoFSetDescription() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		nqn, err := nvmeof.NewNqn(mux.Vars(r)["nqn"])
+		if err != nil {
+			MustError(http.StatusBadRequest, w, "malformed nqn: %v", err)
+			return
+		}
+
+		var req struct {
+			Description string `json:"description"`
+		}
+		err = json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			MustError(http.StatusBadRequest, w, "failed to parse request body: %v", err)
+			return
+		}
+
+		cfg, err := s.nvmeof.SetDescription(r.Context(), nqn, req.Description)
+		if errors.Is(err, common.ErrNotFound) {
+			MustError(http.StatusNotFound, w, "no resource with nqn %s found", nqn)
+			return
+		}
+		if err != nil {
+			MustError(errorStatus(err, http.StatusInternalServerError), w, "failed to set description: %v", err)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		err = json.NewEncoder(w).Encode(cfg)
+		if err != nil {
+			log.WithError(err).Warn("failed to write response")
+		}
+	}
+}
@@ -2,11 +2,13 @@ package rest
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 
 	"github.com/gorilla/mux"
 	log "github.com/sirupsen/logrus"
 
+	"github.com/LINBIT/linstor-gateway/pkg/common"
 	"github.com/LINBIT/linstor-gateway/pkg/nvmeof"
 )
 
@@ -21,13 +23,12 @@ func (s *server) NVMeoFStop() func(http.ResponseWriter, *http.Request) {
 		}
 
 		cfg, err := s.nvmeof.Stop(ctx, nqn)
-		if err != nil {
-			MustError(http.StatusInternalServerError, writer, "failed to stop resource: %v", err)
+		if errors.Is(err, common.ErrNotFound) {
+			MustError(http.StatusNotFound, writer, "no resource found for nqn %s", nqn)
 			return
 		}
-
-		if cfg == nil {
-			MustError(http.StatusNotFound, writer, "no resource found for nqn %s", nqn)
+		if err != nil {
+			MustError(http.StatusInternalServerError, writer, "failed to stop resource: %v", err)
 			return
 		}
 
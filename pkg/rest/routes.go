@@ -10,16 +10,28 @@ func (s *server) routes() {
 			handler.ServeHTTP(w, r)
 		})
 	})
+	apiv2.Use(s.observeRequests)
+	apiv2.Use(s.auditRequests)
 
 	apiv2.HandleFunc("/status", s.APIStatus()).Methods("GET")
+	apiv2.HandleFunc("/events", s.Events()).Methods("GET")
 
 	iscsiv2 := apiv2.PathPrefix("/iscsi").Subrouter()
 	iscsiv2.HandleFunc("", s.ISCSIList()).Methods("GET")
 	iscsiv2.HandleFunc("", s.ISCSICreate()).Methods("POST")
-	iscsiv2.HandleFunc("({iqn}", s.ISCSIGet(true)).Methods("GET")
+	iscsiv2.HandleFunc("/{iqn}", s.ISCSIGet(true)).Methods("GET")
 	iscsiv2.HandleFunc("/{iqn}", s.ISCSIDelete(true)).Methods("DELETE")
 	iscsiv2.HandleFunc("/{iqn}/start", s.ISCSIStart()).Methods("POST")
 	iscsiv2.HandleFunc("/{iqn}/stop", s.ISCSIStop()).Methods("POST")
+	iscsiv2.HandleFunc("/{iqn}/chap", s.ISCSISetCHAP()).Methods("PUT")
+	iscsiv2.HandleFunc("/{iqn}/description", s.ISCSISetDescription()).Methods("PUT")
+	iscsiv2.HandleFunc("/{iqn}/repair", s.ISCSIRepair()).Methods("POST")
+	iscsiv2.HandleFunc("/{iqn}/repair-private-volume", s.ISCSIRecreatePrivateVolume()).Methods("POST")
+	iscsiv2.HandleFunc("/{iqn}/config", s.ISCSIShowConfig()).Methods("GET")
+	iscsiv2.HandleFunc("/{iqn}/failover", s.ISCSIFailover()).Methods("POST")
+	iscsiv2.HandleFunc("/{iqn}/delete-volumes", s.ISCSIDeleteVolumes()).Methods("POST")
+	iscsiv2.HandleFunc("/{iqn}/{lun}/export", s.ISCSISetExported(true)).Methods("POST")
+	iscsiv2.HandleFunc("/{iqn}/{lun}/unexport", s.ISCSISetExported(false)).Methods("POST")
 	iscsiv2.HandleFunc("/{iqn}/{lun}", s.ISCSIGet(false)).Methods("GET")
 	iscsiv2.HandleFunc("/{iqn}/{lun}", s.ISCSIAddVolume()).Methods("PUT")
 	iscsiv2.HandleFunc("/{iqn}/{lun}", s.ISCSIDelete(false)).Methods("DELETE")
@@ -31,8 +43,10 @@ func (s *server) routes() {
 	nfsv2.HandleFunc("/{resource}", s.NFSDelete(true)).Methods("DELETE")
 	nfsv2.HandleFunc("/{resource}/start", s.NFSStart()).Methods("POST")
 	nfsv2.HandleFunc("/{resource}/stop", s.NFSStop()).Methods("POST")
+	nfsv2.HandleFunc("/{resource}/rename", s.NFSRename()).Methods("POST")
+	nfsv2.HandleFunc("/{resource}/description", s.NFSSetDescription()).Methods("PUT")
 	nfsv2.HandleFunc("/{resource}/{id}", s.NFSGet(false)).Methods("GET")
-	// No add volume: LINSTOR refuses to create a filesystem on volume that are added after the resource is deployed.
+	nfsv2.HandleFunc("/{resource}/{id}", s.NFSAddVolume()).Methods("PUT")
 	nfsv2.HandleFunc("/{resource}/{id}", s.NFSDelete(false)).Methods("DELETE")
 
 	nvmeofv2 := apiv2.PathPrefix("/nvme-of").Subrouter()
@@ -42,6 +56,9 @@ func (s *server) routes() {
 	nvmeofv2.HandleFunc("/{nqn}", s.NVMeoFDelete(true)).Methods("DELETE")
 	nvmeofv2.HandleFunc("/{nqn}/start", s.NVMeoFStart()).Methods("POST")
 	nvmeofv2.HandleFunc("/{nqn}/stop", s.NVMeoFStop()).Methods("POST")
+	nvmeofv2.HandleFunc("/{nqn}/description", s.NVMeoFSetDescription()).Methods("PUT")
+	nvmeofv2.HandleFunc("/{nqn}/allowed-hosts/{host}", s.NVMeoFAddAllowedHost()).Methods("PUT")
+	nvmeofv2.HandleFunc("/{nqn}/allowed-hosts/{host}", s.NVMeoFRemoveAllowedHost()).Methods("DELETE")
 	nvmeofv2.HandleFunc("/{nqn}/{nsid}", s.NVMeoFGet(false)).Methods("GET")
 	nvmeofv2.HandleFunc("/{nqn}/{nsid}", s.NVMeoFAddVolume()).Methods("PUT")
 	nvmeofv2.HandleFunc("/{nqn}/{nsid}", s.NVMeoFDelete(false)).Methods("DELETE")
@@ -2,14 +2,25 @@
 package rest
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
+	"strconv"
 	"sync"
+	"time"
 
+	"github.com/LINBIT/linstor-gateway/pkg/audit"
+	"github.com/LINBIT/linstor-gateway/pkg/common"
 	"github.com/LINBIT/linstor-gateway/pkg/iscsi"
+	"github.com/LINBIT/linstor-gateway/pkg/linstorcontrol"
 	"github.com/LINBIT/linstor-gateway/pkg/nfs"
 	"github.com/LINBIT/linstor-gateway/pkg/nvmeof"
+	"github.com/LINBIT/linstor-gateway/pkg/observe"
 	log "github.com/sirupsen/logrus"
 
 	"github.com/gorilla/mux"
@@ -21,9 +32,24 @@ type server struct {
 	iscsi  *iscsi.ISCSI
 	nfs    *nfs.NFS
 	nvmeof *nvmeof.NVMeoF
+	// observer is notified about every request, if set.
+	observer observe.Observer
+	// audit records mutating requests, if set.
+	audit *audit.Logger
+	// ctx is cancelled when the server shuts down.
+	ctx context.Context
+	// events records the state changes of all targets and exports, once
+	// watching is set.
+	events   *common.EventLog
+	watchMu  sync.Mutex
+	watching bool
 	sync.Mutex
 }
 
+// UserHeader is the header in which clients report the user on whose behalf
+// a request is made, for the audit log. It is not authenticated.
+const UserHeader = "X-Linstor-Gateway-User"
+
 // Error is the type that is returned in case of an error.
 type Error struct {
 	Code    string `json:"code"`
@@ -59,28 +85,287 @@ func MustError(code int, w http.ResponseWriter, format string, a ...interface{})
 	}
 }
 
-// ListenAndServe is the entry point for the REST API
-func ListenAndServe(addr string, controllers []string) {
-	iscsi, err := iscsi.New(controllers)
-	if err != nil {
-		log.Fatalf("Failed to initialize ISCSI: %v", err)
+// errorStatus returns the HTTP status code for errors with a well-known cause,
+// and fallback for all others.
+func errorStatus(err error, fallback int) int {
+	var validationErr common.ValidationError
+	switch {
+	case errors.Is(err, common.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, common.ErrAlreadyExists), errors.Is(err, common.ErrNotRunning), errors.Is(err, common.ErrOperationInProgress):
+		return http.StatusConflict
+	case errors.As(err, &validationErr):
+		return http.StatusBadRequest
+	default:
+		return fallback
 	}
-	nfs, err := nfs.New(controllers)
-	if err != nil {
-		log.Fatalf("Failed to initialize NFS: %v", err)
+}
+
+// queryBool reports whether the boolean query parameter with the given name
+// is set to a true value.
+func queryBool(request *http.Request, name string) bool {
+	val, _ := strconv.ParseBool(request.URL.Query().Get(name))
+	return val
+}
+
+// queryPage parses the "offset" and "limit" query parameters of a list
+// request. Both default to 0, which lists everything.
+func queryPage(request *http.Request) (offset, limit int, err error) {
+	query := request.URL.Query()
+	for name, val := range map[string]*int{"offset": &offset, "limit": &limit} {
+		raw := query.Get(name)
+		if raw == "" {
+			continue
+		}
+
+		*val, err = strconv.Atoi(raw)
+		if err != nil || *val < 0 {
+			return 0, 0, fmt.Errorf("invalid %s '%s': must be a non-negative number", name, raw)
+		}
 	}
-	nvmeof, err := nvmeof.New(controllers)
-	if err != nil {
-		log.Fatalf("Failed to initialize NVMeoF: %v", err)
+
+	return offset, limit, nil
+}
+
+// createOptions returns the options of a create request, which are set with
+// the "keep_on_failure", "overwrite", "skip_capacity_check", "no_start",
+// "skip_collision_check", "wait_sync" and "sync_timeout" query parameters.
+// The sync timeout is given in seconds.
+func createOptions(request *http.Request) (common.CreateOptions, error) {
+	opts := common.CreateOptions{
+		KeepOnFailure:      queryBool(request, "keep_on_failure"),
+		Overwrite:          queryBool(request, "overwrite"),
+		SkipCapacityCheck:  queryBool(request, "skip_capacity_check"),
+		NoStart:            queryBool(request, "no_start"),
+		SkipCollisionCheck: queryBool(request, "skip_collision_check"),
+		WaitSync:           queryBool(request, "wait_sync"),
+	}
+
+	if raw := request.URL.Query().Get("sync_timeout"); raw != "" {
+		seconds, err := strconv.Atoi(raw)
+		if err != nil || seconds < 0 {
+			return common.CreateOptions{}, fmt.Errorf("invalid sync_timeout '%s': must be a non-negative number of seconds", raw)
+		}
+		opts.SyncTimeout = time.Duration(seconds) * time.Second
+	}
+
+	return opts, nil
+}
+
+// deleteOptions returns the options of a delete request, which are set with
+// the "keep_resource" query parameter.
+func deleteOptions(request *http.Request) common.DeleteOptions {
+	return common.DeleteOptions{
+		KeepResource: queryBool(request, "keep_resource"),
 	}
+}
+
+// showSecrets reports whether the "show_secrets" query parameter is set,
+// which includes secrets such as passwords in the response.
+func showSecrets(request *http.Request) bool {
+	return queryBool(request, "show_secrets")
+}
+
+// dryRun reports whether the "dry_run" query parameter is set, which only
+// reports the changes a request would make.
+func dryRun(request *http.Request) bool {
+	return queryBool(request, "dry_run")
+}
+
+// shutdownTimeout is how long the server waits for running requests to finish
+// when it is stopped.
+const shutdownTimeout = 10 * time.Second
+
+// ListenAndServe is the entry point for the REST API. All requests share the
+// given LINSTOR client. The server runs until ctx is cancelled; running
+// requests are cancelled as well.
+//
+// Every request and the steps it consists of are reported to observer, and
+// mutating requests are recorded in auditor. Both may be nil.
+func ListenAndServe(ctx context.Context, addr string, cli *linstorcontrol.Linstor, observer observe.Observer, auditor *audit.Logger) error {
 	s := &server{
-		router: mux.NewRouter(),
-		iscsi:  iscsi,
-		nfs:    nfs,
-		nvmeof: nvmeof,
+		router:   mux.NewRouter(),
+		iscsi:    iscsi.NewWithClient(cli),
+		nfs:      nfs.NewWithClient(cli),
+		nvmeof:   nvmeof.NewWithClient(cli),
+		observer: observer,
+		audit:    auditor,
+		ctx:      ctx,
+		events:   common.NewEventLog(eventLogSize),
 	}
 
 	s.routes()
 
-	log.Fatal(http.ListenAndServe(addr, cors.Default().Handler(s.router)))
+	srv := &http.Server{
+		Addr:    addr,
+		Handler: cors.Default().Handler(s.router),
+		BaseContext: func(net.Listener) context.Context {
+			return ctx
+		},
+	}
+
+	go func() {
+		<-ctx.Done()
+		log.Info("shutting down server")
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		err := srv.Shutdown(shutdownCtx)
+		if err != nil {
+			log.WithError(err).Warn("failed to shut down server cleanly")
+		}
+	}()
+
+	err := srv.ListenAndServe()
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// observeRequests is a middleware that reports every request as a step to the
+// observer of the server. The step is named after the method and the route
+// of the request, e.g. "POST /api/v2/iscsi/{iqn}/start".
+func (s *server) observeRequests(handler http.Handler) http.Handler {
+	if s.observer == nil {
+		return handler
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := r.URL.Path
+		if route := mux.CurrentRoute(r); route != nil {
+			if tmpl, err := route.GetPathTemplate(); err == nil {
+				name = tmpl
+			}
+		}
+
+		ctx := observe.WithObserver(r.Context(), s.observer)
+		ctx, end := observe.Step(ctx, r.Method+" "+name)
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		handler.ServeHTTP(recorder, r.WithContext(ctx))
+
+		var err error
+		if recorder.status >= http.StatusBadRequest {
+			err = fmt.Errorf("request failed with status %d", recorder.status)
+		}
+		end(err)
+	})
+}
+
+// targetVars are the route variables that name a target or export.
+var targetVars = []string{"iqn", "resource", "nqn"}
+
+// auditRequests is a middleware that writes an audit record for every request
+// that changes something. Read-only requests are only recorded if the audit
+// logger of the server is configured to do so.
+func (s *server) auditRequests(handler http.Handler) http.Handler {
+	if s.audit == nil {
+		return handler
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet && !s.audit.Reads {
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		record := audit.Record{
+			Time:       time.Now(),
+			User:       r.Header.Get(UserHeader),
+			RemoteAddr: r.RemoteAddr,
+			Operation:  r.Method + " " + r.URL.Path,
+		}
+		if route := mux.CurrentRoute(r); route != nil {
+			if tmpl, err := route.GetPathTemplate(); err == nil {
+				record.Operation = r.Method + " " + tmpl
+			}
+		}
+
+		vars := mux.Vars(r)
+		for name, value := range vars {
+			if contains(targetVars, name) {
+				record.Target = value
+				continue
+			}
+			if record.Params == nil {
+				record.Params = make(map[string]string)
+			}
+			record.Params[name] = value
+		}
+
+		if record.Target == "" && r.Method == http.MethodPost {
+			record.Target = createdTarget(r)
+		}
+
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		handler.ServeHTTP(recorder, r)
+
+		record.Status = recorder.status
+		record.Outcome = audit.OutcomeSuccess
+		if recorder.status >= http.StatusBadRequest {
+			record.Outcome = audit.OutcomeFailure
+		}
+		record.Duration = time.Since(record.Time)
+		s.audit.Log(record)
+	})
+}
+
+// createdTarget returns the IQN, NQN or name of the target or export in the
+// body of a create request, or "" if there is none. The body is left intact
+// for the handler.
+func createdTarget(r *http.Request) string {
+	if r.Body == nil {
+		return ""
+	}
+
+	body, err := io.ReadAll(r.Body)
+	_ = r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return ""
+	}
+
+	var ids struct {
+		IQN  string `json:"iqn"`
+		NQN  string `json:"nqn"`
+		Name string `json:"name"`
+	}
+	_ = json.Unmarshal(body, &ids)
+
+	switch {
+	case ids.IQN != "":
+		return ids.IQN
+	case ids.NQN != "":
+		return ids.NQN
+	default:
+		return ids.Name
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// statusRecorder remembers the status code written to a ResponseWriter.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Flush implements http.Flusher, which is needed to stream events.
+func (r *statusRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
 }